@@ -12,15 +12,38 @@ It has these top-level messages:
 	VersionResponse
 	ParseRequest
 	ParseResponse
+	ParseBatchRequest
+	ParseBatchResponse
 	ListDriversRequest
 	ListDriversResponse
+	InstallDriverRequest
+	InstallDriverResponse
 	SQLRequest
 	SQLResponse
+	SearchRequest
+	SearchResponse
 	StartComponentRequest
 	StartComponentResponse
 	StopComponentRequest
 	StopComponentResponse
+	RestartComponentRequest
+	RestartComponentResponse
+	ComponentInfo
+	ListComponentsRequest
+	ListComponentsResponse
+	ComponentStatusRequest
+	ComponentStatusResponse
+	EventsRequest
+	Event
 	VersionedDriver
+	CapabilitiesRequest
+	CapabilitiesResponse
+	Job
+	StartJobRequest
+	ListJobsRequest
+	ListJobsResponse
+	CancelJobRequest
+	WatchJobRequest
 */
 package api
 
@@ -116,6 +139,69 @@ func (x ParseResponse_Kind) String() string {
 }
 func (ParseResponse_Kind) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3, 0} }
 
+type Event_Kind int32
+
+const (
+	Event_INVALID           Event_Kind = 0
+	Event_PULL_STARTED      Event_Kind = 1
+	Event_PULL_FINISHED     Event_Kind = 2
+	Event_COMPONENT_STARTED Event_Kind = 3
+	Event_COMPONENT_STOPPED Event_Kind = 4
+	Event_HEALTH_DEGRADED   Event_Kind = 5
+	Event_UPGRADE_AVAILABLE Event_Kind = 6
+)
+
+var Event_Kind_name = map[int32]string{
+	0: "INVALID",
+	1: "PULL_STARTED",
+	2: "PULL_FINISHED",
+	3: "COMPONENT_STARTED",
+	4: "COMPONENT_STOPPED",
+	5: "HEALTH_DEGRADED",
+	6: "UPGRADE_AVAILABLE",
+}
+var Event_Kind_value = map[string]int32{
+	"INVALID":           0,
+	"PULL_STARTED":      1,
+	"PULL_FINISHED":     2,
+	"COMPONENT_STARTED": 3,
+	"COMPONENT_STOPPED": 4,
+	"HEALTH_DEGRADED":   5,
+	"UPGRADE_AVAILABLE": 6,
+}
+
+func (x Event_Kind) String() string {
+	return proto.EnumName(Event_Kind_name, int32(x))
+}
+func (Event_Kind) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{26, 0} }
+
+type Job_State int32
+
+const (
+	Job_RUNNING   Job_State = 0
+	Job_SUCCEEDED Job_State = 1
+	Job_FAILED    Job_State = 2
+	Job_CANCELED  Job_State = 3
+)
+
+var Job_State_name = map[int32]string{
+	0: "RUNNING",
+	1: "SUCCEEDED",
+	2: "FAILED",
+	3: "CANCELED",
+}
+var Job_State_value = map[string]int32{
+	"RUNNING":   0,
+	"SUCCEEDED": 1,
+	"FAILED":    2,
+	"CANCELED":  3,
+}
+
+func (x Job_State) String() string {
+	return proto.EnumName(Job_State_name, int32(x))
+}
+func (Job_State) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{30, 0} }
+
 type VersionRequest struct {
 }
 
@@ -237,13 +323,89 @@ func (m *ParseResponse) GetLog() string {
 	return ""
 }
 
+type ParseBatchRequest struct {
+	// Files are parsed in order, the LANG and UAST kinds can be mixed.
+	Files []*ParseRequest `protobuf:"bytes,1,rep,name=files" json:"files,omitempty"`
+}
+
+func (m *ParseBatchRequest) Reset()                    { *m = ParseBatchRequest{} }
+func (m *ParseBatchRequest) String() string            { return proto.CompactTextString(m) }
+func (*ParseBatchRequest) ProtoMessage()               {}
+func (*ParseBatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ParseBatchRequest) GetFiles() []*ParseRequest {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+type ParseBatchResponse struct {
+	// Results has a result for each file of the request, in the same order.
+	Results []*ParseBatchResponse_Result `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *ParseBatchResponse) Reset()                    { *m = ParseBatchResponse{} }
+func (m *ParseBatchResponse) String() string            { return proto.CompactTextString(m) }
+func (*ParseBatchResponse) ProtoMessage()               {}
+func (*ParseBatchResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *ParseBatchResponse) GetResults() []*ParseBatchResponse_Result {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type ParseBatchResponse_Result struct {
+	Name string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Lang string   `protobuf:"bytes,2,opt,name=lang" json:"lang,omitempty"`
+	Uast [][]byte `protobuf:"bytes,3,rep,name=uast,proto3" json:"uast,omitempty"`
+	// Error is set if the file could not be parsed, the rest of the
+	// files are still parsed.
+	Error string `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ParseBatchResponse_Result) Reset()                    { *m = ParseBatchResponse_Result{} }
+func (m *ParseBatchResponse_Result) String() string            { return proto.CompactTextString(m) }
+func (*ParseBatchResponse_Result) ProtoMessage()               {}
+func (*ParseBatchResponse_Result) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5, 0} }
+
+func (m *ParseBatchResponse_Result) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ParseBatchResponse_Result) GetLang() string {
+	if m != nil {
+		return m.Lang
+	}
+	return ""
+}
+
+func (m *ParseBatchResponse_Result) GetUast() [][]byte {
+	if m != nil {
+		return m.Uast
+	}
+	return nil
+}
+
+func (m *ParseBatchResponse_Result) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 type ListDriversRequest struct {
 }
 
 func (m *ListDriversRequest) Reset()                    { *m = ListDriversRequest{} }
 func (m *ListDriversRequest) String() string            { return proto.CompactTextString(m) }
 func (*ListDriversRequest) ProtoMessage()               {}
-func (*ListDriversRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*ListDriversRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 type ListDriversResponse struct {
 	Drivers []*ListDriversResponse_DriverInfo `protobuf:"bytes,1,rep,name=drivers" json:"drivers,omitempty"`
@@ -252,7 +414,7 @@ type ListDriversResponse struct {
 func (m *ListDriversResponse) Reset()                    { *m = ListDriversResponse{} }
 func (m *ListDriversResponse) String() string            { return proto.CompactTextString(m) }
 func (*ListDriversResponse) ProtoMessage()               {}
-func (*ListDriversResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*ListDriversResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 func (m *ListDriversResponse) GetDrivers() []*ListDriversResponse_DriverInfo {
 	if m != nil {
@@ -270,7 +432,7 @@ func (m *ListDriversResponse_DriverInfo) Reset()         { *m = ListDriversRespo
 func (m *ListDriversResponse_DriverInfo) String() string { return proto.CompactTextString(m) }
 func (*ListDriversResponse_DriverInfo) ProtoMessage()    {}
 func (*ListDriversResponse_DriverInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor0, []int{5, 0}
+	return fileDescriptor0, []int{7, 0}
 }
 
 func (m *ListDriversResponse_DriverInfo) GetLang() string {
@@ -287,14 +449,55 @@ func (m *ListDriversResponse_DriverInfo) GetVersion() string {
 	return ""
 }
 
+type InstallDriverRequest struct {
+	Lang string `protobuf:"bytes,1,opt,name=lang" json:"lang,omitempty"`
+	// Image is the driver image, like bblfsh/go-driver:latest. If empty, the
+	// latest image of the language driver is used.
+	Image string `protobuf:"bytes,2,opt,name=image" json:"image,omitempty"`
+}
+
+func (m *InstallDriverRequest) Reset()                    { *m = InstallDriverRequest{} }
+func (m *InstallDriverRequest) String() string            { return proto.CompactTextString(m) }
+func (*InstallDriverRequest) ProtoMessage()               {}
+func (*InstallDriverRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *InstallDriverRequest) GetLang() string {
+	if m != nil {
+		return m.Lang
+	}
+	return ""
+}
+
+func (m *InstallDriverRequest) GetImage() string {
+	if m != nil {
+		return m.Image
+	}
+	return ""
+}
+
+type InstallDriverResponse struct {
+}
+
+func (m *InstallDriverResponse) Reset()                    { *m = InstallDriverResponse{} }
+func (m *InstallDriverResponse) String() string            { return proto.CompactTextString(m) }
+func (*InstallDriverResponse) ProtoMessage()               {}
+func (*InstallDriverResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
 type SQLRequest struct {
 	Query string `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	// MaxRows stops reading the result after the given number of rows.
+	// If 0, all the rows are returned.
+	MaxRows int64 `protobuf:"varint,2,opt,name=max_rows,json=maxRows" json:"max_rows,omitempty"`
+	// BatchSize is the maximum number of rows sent in each response, using
+	// the rows field. Batches are also sent early once they reach 1 MiB.
+	// If 0, each row is sent in its own response, using the row field.
+	BatchSize int32 `protobuf:"varint,3,opt,name=batch_size,json=batchSize" json:"batch_size,omitempty"`
 }
 
 func (m *SQLRequest) Reset()                    { *m = SQLRequest{} }
 func (m *SQLRequest) String() string            { return proto.CompactTextString(m) }
 func (*SQLRequest) ProtoMessage()               {}
-func (*SQLRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*SQLRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 func (m *SQLRequest) GetQuery() string {
 	if m != nil {
@@ -303,14 +506,32 @@ func (m *SQLRequest) GetQuery() string {
 	return ""
 }
 
+func (m *SQLRequest) GetMaxRows() int64 {
+	if m != nil {
+		return m.MaxRows
+	}
+	return 0
+}
+
+func (m *SQLRequest) GetBatchSize() int32 {
+	if m != nil {
+		return m.BatchSize
+	}
+	return 0
+}
+
 type SQLResponse struct {
-	Row *SQLResponse_Row `protobuf:"bytes,1,opt,name=row" json:"row,omitempty"`
+	Row  *SQLResponse_Row   `protobuf:"bytes,1,opt,name=row" json:"row,omitempty"`
+	Rows []*SQLResponse_Row `protobuf:"bytes,2,rep,name=rows" json:"rows,omitempty"`
+	// Truncated is set in the last response if the result had more rows than
+	// the requested MaxRows.
+	Truncated bool `protobuf:"varint,3,opt,name=truncated" json:"truncated,omitempty"`
 }
 
 func (m *SQLResponse) Reset()                    { *m = SQLResponse{} }
 func (m *SQLResponse) String() string            { return proto.CompactTextString(m) }
 func (*SQLResponse) ProtoMessage()               {}
-func (*SQLResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*SQLResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 func (m *SQLResponse) GetRow() *SQLResponse_Row {
 	if m != nil {
@@ -319,6 +540,20 @@ func (m *SQLResponse) GetRow() *SQLResponse_Row {
 	return nil
 }
 
+func (m *SQLResponse) GetRows() []*SQLResponse_Row {
+	if m != nil {
+		return m.Rows
+	}
+	return nil
+}
+
+func (m *SQLResponse) GetTruncated() bool {
+	if m != nil {
+		return m.Truncated
+	}
+	return false
+}
+
 type SQLResponse_Row struct {
 	Cell [][]byte `protobuf:"bytes,1,rep,name=cell,proto3" json:"cell,omitempty"`
 }
@@ -326,7 +561,7 @@ type SQLResponse_Row struct {
 func (m *SQLResponse_Row) Reset()                    { *m = SQLResponse_Row{} }
 func (m *SQLResponse_Row) String() string            { return proto.CompactTextString(m) }
 func (*SQLResponse_Row) ProtoMessage()               {}
-func (*SQLResponse_Row) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7, 0} }
+func (*SQLResponse_Row) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11, 0} }
 
 func (m *SQLResponse_Row) GetCell() [][]byte {
 	if m != nil {
@@ -335,6 +570,90 @@ func (m *SQLResponse_Row) GetCell() [][]byte {
 	return nil
 }
 
+type SearchRequest struct {
+	// Pattern is a regular expression.
+	Pattern string `protobuf:"bytes,1,opt,name=pattern" json:"pattern,omitempty"`
+	// Lang restricts the search to the files of the given language.
+	Lang       string `protobuf:"bytes,2,opt,name=lang" json:"lang,omitempty"`
+	IgnoreCase bool   `protobuf:"varint,3,opt,name=ignore_case,json=ignoreCase" json:"ignore_case,omitempty"`
+	// Repos is a comma separated list of repository names to search in.
+	// If empty, all the repositories are searched.
+	Repos string `protobuf:"bytes,4,opt,name=repos" json:"repos,omitempty"`
+}
+
+func (m *SearchRequest) Reset()                    { *m = SearchRequest{} }
+func (m *SearchRequest) String() string            { return proto.CompactTextString(m) }
+func (*SearchRequest) ProtoMessage()               {}
+func (*SearchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *SearchRequest) GetPattern() string {
+	if m != nil {
+		return m.Pattern
+	}
+	return ""
+}
+
+func (m *SearchRequest) GetLang() string {
+	if m != nil {
+		return m.Lang
+	}
+	return ""
+}
+
+func (m *SearchRequest) GetIgnoreCase() bool {
+	if m != nil {
+		return m.IgnoreCase
+	}
+	return false
+}
+
+func (m *SearchRequest) GetRepos() string {
+	if m != nil {
+		return m.Repos
+	}
+	return ""
+}
+
+type SearchResponse struct {
+	Repository string `protobuf:"bytes,1,opt,name=repository" json:"repository,omitempty"`
+	File       string `protobuf:"bytes,2,opt,name=file" json:"file,omitempty"`
+	LineNumber int32  `protobuf:"varint,3,opt,name=line_number,json=lineNumber" json:"line_number,omitempty"`
+	Line       string `protobuf:"bytes,4,opt,name=line" json:"line,omitempty"`
+}
+
+func (m *SearchResponse) Reset()                    { *m = SearchResponse{} }
+func (m *SearchResponse) String() string            { return proto.CompactTextString(m) }
+func (*SearchResponse) ProtoMessage()               {}
+func (*SearchResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *SearchResponse) GetRepository() string {
+	if m != nil {
+		return m.Repository
+	}
+	return ""
+}
+
+func (m *SearchResponse) GetFile() string {
+	if m != nil {
+		return m.File
+	}
+	return ""
+}
+
+func (m *SearchResponse) GetLineNumber() int32 {
+	if m != nil {
+		return m.LineNumber
+	}
+	return 0
+}
+
+func (m *SearchResponse) GetLine() string {
+	if m != nil {
+		return m.Line
+	}
+	return ""
+}
+
 type StartComponentRequest struct {
 	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	// Port is the public port binding.
@@ -346,7 +665,7 @@ type StartComponentRequest struct {
 func (m *StartComponentRequest) Reset()                    { *m = StartComponentRequest{} }
 func (m *StartComponentRequest) String() string            { return proto.CompactTextString(m) }
 func (*StartComponentRequest) ProtoMessage()               {}
-func (*StartComponentRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*StartComponentRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *StartComponentRequest) GetName() string {
 	if m != nil {
@@ -371,7 +690,7 @@ type StartComponentResponse struct {
 func (m *StartComponentResponse) Reset()                    { *m = StartComponentResponse{} }
 func (m *StartComponentResponse) String() string            { return proto.CompactTextString(m) }
 func (*StartComponentResponse) ProtoMessage()               {}
-func (*StartComponentResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*StartComponentResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *StartComponentResponse) GetPort() int32 {
 	if m != nil {
@@ -387,7 +706,7 @@ type StopComponentRequest struct {
 func (m *StopComponentRequest) Reset()                    { *m = StopComponentRequest{} }
 func (m *StopComponentRequest) String() string            { return proto.CompactTextString(m) }
 func (*StopComponentRequest) ProtoMessage()               {}
-func (*StopComponentRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*StopComponentRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func (m *StopComponentRequest) GetName() string {
 	if m != nil {
@@ -402,153 +721,873 @@ type StopComponentResponse struct {
 func (m *StopComponentResponse) Reset()                    { *m = StopComponentResponse{} }
 func (m *StopComponentResponse) String() string            { return proto.CompactTextString(m) }
 func (*StopComponentResponse) ProtoMessage()               {}
-func (*StopComponentResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*StopComponentResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
 
-type VersionedDriver struct {
-	Language string `protobuf:"bytes,1,opt,name=language" json:"language,omitempty"`
-	Version  string `protobuf:"bytes,2,opt,name=version" json:"version,omitempty"`
+type RestartComponentRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// Hard removes the anonymous volumes of the container too, instead of
+	// mounting them in the new one.
+	Hard bool `protobuf:"varint,2,opt,name=hard" json:"hard,omitempty"`
 }
 
-func (m *VersionedDriver) Reset()                    { *m = VersionedDriver{} }
-func (m *VersionedDriver) String() string            { return proto.CompactTextString(m) }
-func (*VersionedDriver) ProtoMessage()               {}
-func (*VersionedDriver) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (m *RestartComponentRequest) Reset()                    { *m = RestartComponentRequest{} }
+func (m *RestartComponentRequest) String() string            { return proto.CompactTextString(m) }
+func (*RestartComponentRequest) ProtoMessage()               {}
+func (*RestartComponentRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
 
-func (m *VersionedDriver) GetLanguage() string {
+func (m *RestartComponentRequest) GetName() string {
 	if m != nil {
-		return m.Language
+		return m.Name
 	}
 	return ""
 }
 
-func (m *VersionedDriver) GetVersion() string {
+func (m *RestartComponentRequest) GetHard() bool {
 	if m != nil {
-		return m.Version
+		return m.Hard
 	}
-	return ""
+	return false
 }
 
-func init() {
-	proto.RegisterType((*VersionRequest)(nil), "VersionRequest")
-	proto.RegisterType((*VersionResponse)(nil), "VersionResponse")
-	proto.RegisterType((*ParseRequest)(nil), "ParseRequest")
-	proto.RegisterType((*ParseResponse)(nil), "ParseResponse")
-	proto.RegisterType((*ListDriversRequest)(nil), "ListDriversRequest")
-	proto.RegisterType((*ListDriversResponse)(nil), "ListDriversResponse")
-	proto.RegisterType((*ListDriversResponse_DriverInfo)(nil), "ListDriversResponse.DriverInfo")
-	proto.RegisterType((*SQLRequest)(nil), "SQLRequest")
-	proto.RegisterType((*SQLResponse)(nil), "SQLResponse")
-	proto.RegisterType((*SQLResponse_Row)(nil), "SQLResponse.Row")
-	proto.RegisterType((*StartComponentRequest)(nil), "StartComponentRequest")
-	proto.RegisterType((*StartComponentResponse)(nil), "StartComponentResponse")
-	proto.RegisterType((*StopComponentRequest)(nil), "StopComponentRequest")
-	proto.RegisterType((*StopComponentResponse)(nil), "StopComponentResponse")
-	proto.RegisterType((*VersionedDriver)(nil), "VersionedDriver")
-	proto.RegisterEnum("ParseRequest_Kind", ParseRequest_Kind_name, ParseRequest_Kind_value)
-	proto.RegisterEnum("ParseRequest_UastMode", ParseRequest_UastMode_name, ParseRequest_UastMode_value)
-	proto.RegisterEnum("ParseResponse_Kind", ParseResponse_Kind_name, ParseResponse_Kind_value)
+type RestartComponentResponse struct {
+	// Port is the public port binding for the container.
+	// It may be 0 if the container does not have a port binding.
+	Port int32 `protobuf:"varint,1,opt,name=port" json:"port,omitempty"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// Client API for Engine service
+func (m *RestartComponentResponse) Reset()                    { *m = RestartComponentResponse{} }
+func (m *RestartComponentResponse) String() string            { return proto.CompactTextString(m) }
+func (*RestartComponentResponse) ProtoMessage()               {}
+func (*RestartComponentResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
 
-type EngineClient interface {
-	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
-	// A single response with the parsing result.
-	Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*ParseResponse, error)
-	// A stream of responses with logs and finally the parsing result.
-	ParseWithLogs(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (Engine_ParseWithLogsClient, error)
-	// Driver management.
-	// List all drivers.
-	ListDrivers(ctx context.Context, in *ListDriversRequest, opts ...grpc.CallOption) (*ListDriversResponse, error)
-	// SQL stuff.
-	SQL(ctx context.Context, in *SQLRequest, opts ...grpc.CallOption) (Engine_SQLClient, error)
-	// Start a component.
-	StartComponent(ctx context.Context, in *StartComponentRequest, opts ...grpc.CallOption) (*StartComponentResponse, error)
-	// Stop a component.
-	StopComponent(ctx context.Context, in *StopComponentRequest, opts ...grpc.CallOption) (*StopComponentResponse, error)
+func (m *RestartComponentResponse) GetPort() int32 {
+	if m != nil {
+		return m.Port
+	}
+	return 0
 }
 
-type engineClient struct {
-	cc *grpc.ClientConn
+type ComponentInfo struct {
+	// Name is the container name of the component.
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// Image is the image with the version used by the component.
+	Image string `protobuf:"bytes,2,opt,name=image" json:"image,omitempty"`
+	// State is the docker state of the container, such as running or
+	// exited, or not_created if there is no container.
+	State string `protobuf:"bytes,3,opt,name=state" json:"state,omitempty"`
+	// Ports are the public port bindings of the container.
+	Ports []int32 `protobuf:"varint,4,rep,packed,name=ports" json:"ports,omitempty"`
 }
 
-func NewEngineClient(cc *grpc.ClientConn) EngineClient {
-	return &engineClient{cc}
-}
+func (m *ComponentInfo) Reset()                    { *m = ComponentInfo{} }
+func (m *ComponentInfo) String() string            { return proto.CompactTextString(m) }
+func (*ComponentInfo) ProtoMessage()               {}
+func (*ComponentInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
-func (c *engineClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
-	out := new(VersionResponse)
-	err := grpc.Invoke(ctx, "/Engine/Version", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *ComponentInfo) GetName() string {
+	if m != nil {
+		return m.Name
 	}
-	return out, nil
+	return ""
 }
 
-func (c *engineClient) Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*ParseResponse, error) {
-	out := new(ParseResponse)
-	err := grpc.Invoke(ctx, "/Engine/Parse", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *ComponentInfo) GetImage() string {
+	if m != nil {
+		return m.Image
 	}
-	return out, nil
+	return ""
 }
 
-func (c *engineClient) ParseWithLogs(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (Engine_ParseWithLogsClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Engine_serviceDesc.Streams[0], c.cc, "/Engine/ParseWithLogs", opts...)
-	if err != nil {
-		return nil, err
-	}
-	x := &engineParseWithLogsClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
-		return nil, err
+func (m *ComponentInfo) GetState() string {
+	if m != nil {
+		return m.State
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
+	return ""
+}
+
+func (m *ComponentInfo) GetPorts() []int32 {
+	if m != nil {
+		return m.Ports
 	}
-	return x, nil
+	return nil
 }
 
-type Engine_ParseWithLogsClient interface {
-	Recv() (*ParseResponse, error)
-	grpc.ClientStream
+type ListComponentsRequest struct {
 }
 
-type engineParseWithLogsClient struct {
-	grpc.ClientStream
+func (m *ListComponentsRequest) Reset()                    { *m = ListComponentsRequest{} }
+func (m *ListComponentsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListComponentsRequest) ProtoMessage()               {}
+func (*ListComponentsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+type ListComponentsResponse struct {
+	Components []*ComponentInfo `protobuf:"bytes,1,rep,name=components" json:"components,omitempty"`
 }
 
-func (x *engineParseWithLogsClient) Recv() (*ParseResponse, error) {
-	m := new(ParseResponse)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+func (m *ListComponentsResponse) Reset()                    { *m = ListComponentsResponse{} }
+func (m *ListComponentsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListComponentsResponse) ProtoMessage()               {}
+func (*ListComponentsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *ListComponentsResponse) GetComponents() []*ComponentInfo {
+	if m != nil {
+		return m.Components
+	}
+	return nil
+}
+
+type ComponentStatusRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *ComponentStatusRequest) Reset()                    { *m = ComponentStatusRequest{} }
+func (m *ComponentStatusRequest) String() string            { return proto.CompactTextString(m) }
+func (*ComponentStatusRequest) ProtoMessage()               {}
+func (*ComponentStatusRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *ComponentStatusRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type ComponentStatusResponse struct {
+	Component *ComponentInfo `protobuf:"bytes,1,opt,name=component" json:"component,omitempty"`
+}
+
+func (m *ComponentStatusResponse) Reset()                    { *m = ComponentStatusResponse{} }
+func (m *ComponentStatusResponse) String() string            { return proto.CompactTextString(m) }
+func (*ComponentStatusResponse) ProtoMessage()               {}
+func (*ComponentStatusResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *ComponentStatusResponse) GetComponent() *ComponentInfo {
+	if m != nil {
+		return m.Component
+	}
+	return nil
+}
+
+type EventsRequest struct {
+}
+
+func (m *EventsRequest) Reset()                    { *m = EventsRequest{} }
+func (m *EventsRequest) String() string            { return proto.CompactTextString(m) }
+func (*EventsRequest) ProtoMessage()               {}
+func (*EventsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+type Event struct {
+	Kind Event_Kind `protobuf:"varint,1,opt,name=kind,enum=Event_Kind" json:"kind,omitempty"`
+	// Component is the container name of the component.
+	Component string `protobuf:"bytes,2,opt,name=component" json:"component,omitempty"`
+	// Image is the image with the version the event refers to.
+	Image   string `protobuf:"bytes,3,opt,name=image" json:"image,omitempty"`
+	Message string `protobuf:"bytes,4,opt,name=message" json:"message,omitempty"`
+	// Time is the unix time of the event, in nanoseconds.
+	Time int64 `protobuf:"varint,5,opt,name=time" json:"time,omitempty"`
+}
+
+func (m *Event) Reset()                    { *m = Event{} }
+func (m *Event) String() string            { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()               {}
+func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *Event) GetKind() Event_Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return Event_INVALID
+}
+
+func (m *Event) GetComponent() string {
+	if m != nil {
+		return m.Component
+	}
+	return ""
+}
+
+func (m *Event) GetImage() string {
+	if m != nil {
+		return m.Image
+	}
+	return ""
+}
+
+func (m *Event) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Event) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+type VersionedDriver struct {
+	Language string `protobuf:"bytes,1,opt,name=language" json:"language,omitempty"`
+	Version  string `protobuf:"bytes,2,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *VersionedDriver) Reset()                    { *m = VersionedDriver{} }
+func (m *VersionedDriver) String() string            { return proto.CompactTextString(m) }
+func (*VersionedDriver) ProtoMessage()               {}
+func (*VersionedDriver) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *VersionedDriver) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
+
+func (m *VersionedDriver) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+type CapabilitiesRequest struct {
+	// API version of the client.
+	ApiVersion int32 `protobuf:"varint,1,opt,name=api_version,json=apiVersion" json:"api_version,omitempty"`
+}
+
+func (m *CapabilitiesRequest) Reset()                    { *m = CapabilitiesRequest{} }
+func (m *CapabilitiesRequest) String() string            { return proto.CompactTextString(m) }
+func (*CapabilitiesRequest) ProtoMessage()               {}
+func (*CapabilitiesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *CapabilitiesRequest) GetApiVersion() int32 {
+	if m != nil {
+		return m.ApiVersion
+	}
+	return 0
+}
+
+type CapabilitiesResponse struct {
+	// API version of the daemon, increased with the changes that break older
+	// clients or servers.
+	ApiVersion int32 `protobuf:"varint,1,opt,name=api_version,json=apiVersion" json:"api_version,omitempty"`
+	// Oldest API version of the clients the daemon works with.
+	MinApiVersion int32 `protobuf:"varint,2,opt,name=min_api_version,json=minApiVersion" json:"min_api_version,omitempty"`
+	// Version of the daemon.
+	Version string `protobuf:"bytes,3,opt,name=version" json:"version,omitempty"`
+	// Optional features supported by the daemon, like "search" or "events".
+	Features []string `protobuf:"bytes,4,rep,name=features" json:"features,omitempty"`
+}
+
+func (m *CapabilitiesResponse) Reset()                    { *m = CapabilitiesResponse{} }
+func (m *CapabilitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*CapabilitiesResponse) ProtoMessage()               {}
+func (*CapabilitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *CapabilitiesResponse) GetApiVersion() int32 {
+	if m != nil {
+		return m.ApiVersion
+	}
+	return 0
+}
+
+func (m *CapabilitiesResponse) GetMinApiVersion() int32 {
+	if m != nil {
+		return m.MinApiVersion
+	}
+	return 0
+}
+
+func (m *CapabilitiesResponse) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *CapabilitiesResponse) GetFeatures() []string {
+	if m != nil {
+		return m.Features
+	}
+	return nil
+}
+
+type Job struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	// Kind is start-component, restart-component, install-driver,
+	// create-index, fetch-dataset or pull-image.
+	Kind string `protobuf:"bytes,2,opt,name=kind" json:"kind,omitempty"`
+	// Name is the component, the language of the driver, the dataset or the
+	// image of the job.
+	Name  string    `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	State Job_State `protobuf:"varint,4,opt,name=state,enum=Job_State" json:"state,omitempty"`
+	// Message is the last progress of the job.
+	Message string `protobuf:"bytes,5,opt,name=message" json:"message,omitempty"`
+	Error   string `protobuf:"bytes,6,opt,name=error" json:"error,omitempty"`
+	// User is the one that started the job.
+	User string `protobuf:"bytes,7,opt,name=user" json:"user,omitempty"`
+	// Started and Finished are unix times, in nanoseconds.
+	Started  int64 `protobuf:"varint,8,opt,name=started" json:"started,omitempty"`
+	Finished int64 `protobuf:"varint,9,opt,name=finished" json:"finished,omitempty"`
+	// Port is the public port of the component started or restarted.
+	Port int32 `protobuf:"varint,10,opt,name=port" json:"port,omitempty"`
+}
+
+func (m *Job) Reset()                    { *m = Job{} }
+func (m *Job) String() string            { return proto.CompactTextString(m) }
+func (*Job) ProtoMessage()               {}
+func (*Job) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *Job) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Job) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *Job) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Job) GetState() Job_State {
+	if m != nil {
+		return m.State
+	}
+	return Job_RUNNING
+}
+
+func (m *Job) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Job) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *Job) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *Job) GetStarted() int64 {
+	if m != nil {
+		return m.Started
+	}
+	return 0
+}
+
+func (m *Job) GetFinished() int64 {
+	if m != nil {
+		return m.Finished
+	}
+	return 0
+}
+
+func (m *Job) GetPort() int32 {
+	if m != nil {
+		return m.Port
+	}
+	return 0
+}
+
+type StartJobRequest struct {
+	Kind string `protobuf:"bytes,1,opt,name=kind" json:"kind,omitempty"`
+	// Name is the component to start or restart, the language of the
+	// driver to install, the dataset to fetch or the image:version to pull.
+	Name string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	// Image is the image of the driver to install, the default one of the
+	// language if it is empty.
+	Image string `protobuf:"bytes,3,opt,name=image" json:"image,omitempty"`
+	// Hard removes the anonymous volumes of the component restarted.
+	Hard bool `protobuf:"varint,4,opt,name=hard" json:"hard,omitempty"`
+	// Query is the CREATE INDEX statement of create-index.
+	Query string `protobuf:"bytes,5,opt,name=query" json:"query,omitempty"`
+	// Langs, Size, Workers, Streams, Retries and Verify are the options of
+	// fetch-dataset, see srcd datasets get. Size is in bytes, and Size,
+	// Workers and Streams use the defaults of srcd datasets get if they are 0.
+	Langs   []string `protobuf:"bytes,6,rep,name=langs" json:"langs,omitempty"`
+	Size    int64    `protobuf:"varint,7,opt,name=size" json:"size,omitempty"`
+	Workers int32    `protobuf:"varint,8,opt,name=workers" json:"workers,omitempty"`
+	Streams int32    `protobuf:"varint,9,opt,name=streams" json:"streams,omitempty"`
+	Retries int32    `protobuf:"varint,10,opt,name=retries" json:"retries,omitempty"`
+	Verify  bool     `protobuf:"varint,11,opt,name=verify" json:"verify,omitempty"`
+}
+
+func (m *StartJobRequest) Reset()                    { *m = StartJobRequest{} }
+func (m *StartJobRequest) String() string            { return proto.CompactTextString(m) }
+func (*StartJobRequest) ProtoMessage()               {}
+func (*StartJobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *StartJobRequest) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *StartJobRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *StartJobRequest) GetImage() string {
+	if m != nil {
+		return m.Image
+	}
+	return ""
+}
+
+func (m *StartJobRequest) GetHard() bool {
+	if m != nil {
+		return m.Hard
+	}
+	return false
+}
+
+func (m *StartJobRequest) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *StartJobRequest) GetLangs() []string {
+	if m != nil {
+		return m.Langs
+	}
+	return nil
+}
+
+func (m *StartJobRequest) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *StartJobRequest) GetWorkers() int32 {
+	if m != nil {
+		return m.Workers
+	}
+	return 0
+}
+
+func (m *StartJobRequest) GetStreams() int32 {
+	if m != nil {
+		return m.Streams
+	}
+	return 0
+}
+
+func (m *StartJobRequest) GetRetries() int32 {
+	if m != nil {
+		return m.Retries
+	}
+	return 0
+}
+
+func (m *StartJobRequest) GetVerify() bool {
+	if m != nil {
+		return m.Verify
+	}
+	return false
+}
+
+type ListJobsRequest struct {
+}
+
+func (m *ListJobsRequest) Reset()                    { *m = ListJobsRequest{} }
+func (m *ListJobsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListJobsRequest) ProtoMessage()               {}
+func (*ListJobsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+type ListJobsResponse struct {
+	Jobs []*Job `protobuf:"bytes,1,rep,name=jobs" json:"jobs,omitempty"`
+}
+
+func (m *ListJobsResponse) Reset()                    { *m = ListJobsResponse{} }
+func (m *ListJobsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListJobsResponse) ProtoMessage()               {}
+func (*ListJobsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *ListJobsResponse) GetJobs() []*Job {
+	if m != nil {
+		return m.Jobs
+	}
+	return nil
+}
+
+type CancelJobRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *CancelJobRequest) Reset()                    { *m = CancelJobRequest{} }
+func (m *CancelJobRequest) String() string            { return proto.CompactTextString(m) }
+func (*CancelJobRequest) ProtoMessage()               {}
+func (*CancelJobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+func (m *CancelJobRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type WatchJobRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *WatchJobRequest) Reset()                    { *m = WatchJobRequest{} }
+func (m *WatchJobRequest) String() string            { return proto.CompactTextString(m) }
+func (*WatchJobRequest) ProtoMessage()               {}
+func (*WatchJobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *WatchJobRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*VersionRequest)(nil), "VersionRequest")
+	proto.RegisterType((*VersionResponse)(nil), "VersionResponse")
+	proto.RegisterType((*ParseRequest)(nil), "ParseRequest")
+	proto.RegisterType((*ParseResponse)(nil), "ParseResponse")
+	proto.RegisterType((*ParseBatchRequest)(nil), "ParseBatchRequest")
+	proto.RegisterType((*ParseBatchResponse)(nil), "ParseBatchResponse")
+	proto.RegisterType((*ParseBatchResponse_Result)(nil), "ParseBatchResponse.Result")
+	proto.RegisterType((*ListDriversRequest)(nil), "ListDriversRequest")
+	proto.RegisterType((*ListDriversResponse)(nil), "ListDriversResponse")
+	proto.RegisterType((*ListDriversResponse_DriverInfo)(nil), "ListDriversResponse.DriverInfo")
+	proto.RegisterType((*InstallDriverRequest)(nil), "InstallDriverRequest")
+	proto.RegisterType((*InstallDriverResponse)(nil), "InstallDriverResponse")
+	proto.RegisterType((*SQLRequest)(nil), "SQLRequest")
+	proto.RegisterType((*SQLResponse)(nil), "SQLResponse")
+	proto.RegisterType((*SQLResponse_Row)(nil), "SQLResponse.Row")
+	proto.RegisterType((*SearchRequest)(nil), "SearchRequest")
+	proto.RegisterType((*SearchResponse)(nil), "SearchResponse")
+	proto.RegisterType((*StartComponentRequest)(nil), "StartComponentRequest")
+	proto.RegisterType((*StartComponentResponse)(nil), "StartComponentResponse")
+	proto.RegisterType((*StopComponentRequest)(nil), "StopComponentRequest")
+	proto.RegisterType((*StopComponentResponse)(nil), "StopComponentResponse")
+	proto.RegisterType((*RestartComponentRequest)(nil), "RestartComponentRequest")
+	proto.RegisterType((*RestartComponentResponse)(nil), "RestartComponentResponse")
+	proto.RegisterType((*ComponentInfo)(nil), "ComponentInfo")
+	proto.RegisterType((*ListComponentsRequest)(nil), "ListComponentsRequest")
+	proto.RegisterType((*ListComponentsResponse)(nil), "ListComponentsResponse")
+	proto.RegisterType((*ComponentStatusRequest)(nil), "ComponentStatusRequest")
+	proto.RegisterType((*ComponentStatusResponse)(nil), "ComponentStatusResponse")
+	proto.RegisterType((*EventsRequest)(nil), "EventsRequest")
+	proto.RegisterType((*Event)(nil), "Event")
+	proto.RegisterType((*VersionedDriver)(nil), "VersionedDriver")
+	proto.RegisterType((*CapabilitiesRequest)(nil), "CapabilitiesRequest")
+	proto.RegisterType((*CapabilitiesResponse)(nil), "CapabilitiesResponse")
+	proto.RegisterType((*Job)(nil), "Job")
+	proto.RegisterType((*StartJobRequest)(nil), "StartJobRequest")
+	proto.RegisterType((*ListJobsRequest)(nil), "ListJobsRequest")
+	proto.RegisterType((*ListJobsResponse)(nil), "ListJobsResponse")
+	proto.RegisterType((*CancelJobRequest)(nil), "CancelJobRequest")
+	proto.RegisterType((*WatchJobRequest)(nil), "WatchJobRequest")
+	proto.RegisterEnum("ParseRequest_Kind", ParseRequest_Kind_name, ParseRequest_Kind_value)
+	proto.RegisterEnum("ParseRequest_UastMode", ParseRequest_UastMode_name, ParseRequest_UastMode_value)
+	proto.RegisterEnum("ParseResponse_Kind", ParseResponse_Kind_name, ParseResponse_Kind_value)
+	proto.RegisterEnum("Event_Kind", Event_Kind_name, Event_Kind_value)
+	proto.RegisterEnum("Job_State", Job_State_name, Job_State_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Engine service
+
+type EngineClient interface {
+	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
+	// The API version of the daemon and the optional features it supports,
+	// so the clients can check they can use it.
+	Capabilities(ctx context.Context, in *CapabilitiesRequest, opts ...grpc.CallOption) (*CapabilitiesResponse, error)
+	// A single response with the parsing result.
+	Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*ParseResponse, error)
+	// A stream of responses with logs and finally the parsing result.
+	ParseWithLogs(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (Engine_ParseWithLogsClient, error)
+	// A single response with the parsing result of each file, reusing the
+	// connection to bblfshd.
+	ParseBatch(ctx context.Context, in *ParseBatchRequest, opts ...grpc.CallOption) (*ParseBatchResponse, error)
+	// Driver management.
+	// List all drivers.
+	ListDrivers(ctx context.Context, in *ListDriversRequest, opts ...grpc.CallOption) (*ListDriversResponse, error)
+	// Install the driver of a language in bblfshd.
+	InstallDriver(ctx context.Context, in *InstallDriverRequest, opts ...grpc.CallOption) (*InstallDriverResponse, error)
+	// SQL stuff.
+	// The first response holds the column names in row, and the following
+	// ones the result rows, read from gitbase as the client consumes them.
+	SQL(ctx context.Context, in *SQLRequest, opts ...grpc.CallOption) (Engine_SQLClient, error)
+	// Code search over the working directory. A response is sent for each
+	// matching line.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (Engine_SearchClient, error)
+	// Start a component.
+	StartComponent(ctx context.Context, in *StartComponentRequest, opts ...grpc.CallOption) (*StartComponentResponse, error)
+	// Stop a component.
+	StopComponent(ctx context.Context, in *StopComponentRequest, opts ...grpc.CallOption) (*StopComponentResponse, error)
+	// Restart a component, keeping its public port if it was running.
+	RestartComponent(ctx context.Context, in *RestartComponentRequest, opts ...grpc.CallOption) (*RestartComponentResponse, error)
+	// List the components managed by the daemon and their state.
+	ListComponents(ctx context.Context, in *ListComponentsRequest, opts ...grpc.CallOption) (*ListComponentsResponse, error)
+	// State of a single component.
+	ComponentStatus(ctx context.Context, in *ComponentStatusRequest, opts ...grpc.CallOption) (*ComponentStatusResponse, error)
+	// A response for each event of the components, such as image pulls or
+	// containers started, sent as they happen until the client cancels the
+	// call. The available upgrades already found are sent first.
+	Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (Engine_EventsClient, error)
+	// Jobs are the long operations, like starting a component that pulls
+	// its images, run by the daemon in the background. They keep running if
+	// the client disconnects, until they finish or are cancelled.
+	// Start a job, returning it as soon as it is running.
+	StartJob(ctx context.Context, in *StartJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// List the jobs running and the last ones finished.
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	// Cancel a running job.
+	CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// A response with the job each time its progress changes, until it
+	// finishes.
+	WatchJob(ctx context.Context, in *WatchJobRequest, opts ...grpc.CallOption) (Engine_WatchJobClient, error)
+}
+
+type engineClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewEngineClient(cc *grpc.ClientConn) EngineClient {
+	return &engineClient{cc}
+}
+
+func (c *engineClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	err := grpc.Invoke(ctx, "/Engine/Version", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) Capabilities(ctx context.Context, in *CapabilitiesRequest, opts ...grpc.CallOption) (*CapabilitiesResponse, error) {
+	out := new(CapabilitiesResponse)
+	err := grpc.Invoke(ctx, "/Engine/Capabilities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*ParseResponse, error) {
+	out := new(ParseResponse)
+	err := grpc.Invoke(ctx, "/Engine/Parse", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) ParseWithLogs(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (Engine_ParseWithLogsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Engine_serviceDesc.Streams[0], c.cc, "/Engine/ParseWithLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &engineParseWithLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Engine_ParseWithLogsClient interface {
+	Recv() (*ParseResponse, error)
+	grpc.ClientStream
+}
+
+type engineParseWithLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *engineParseWithLogsClient) Recv() (*ParseResponse, error) {
+	m := new(ParseResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *engineClient) ParseBatch(ctx context.Context, in *ParseBatchRequest, opts ...grpc.CallOption) (*ParseBatchResponse, error) {
+	out := new(ParseBatchResponse)
+	err := grpc.Invoke(ctx, "/Engine/ParseBatch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) ListDrivers(ctx context.Context, in *ListDriversRequest, opts ...grpc.CallOption) (*ListDriversResponse, error) {
+	out := new(ListDriversResponse)
+	err := grpc.Invoke(ctx, "/Engine/ListDrivers", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) InstallDriver(ctx context.Context, in *InstallDriverRequest, opts ...grpc.CallOption) (*InstallDriverResponse, error) {
+	out := new(InstallDriverResponse)
+	err := grpc.Invoke(ctx, "/Engine/InstallDriver", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) SQL(ctx context.Context, in *SQLRequest, opts ...grpc.CallOption) (Engine_SQLClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Engine_serviceDesc.Streams[1], c.cc, "/Engine/SQL", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &engineSQLClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Engine_SQLClient interface {
+	Recv() (*SQLResponse, error)
+	grpc.ClientStream
+}
+
+type engineSQLClient struct {
+	grpc.ClientStream
+}
+
+func (x *engineSQLClient) Recv() (*SQLResponse, error) {
+	m := new(SQLResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *engineClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (Engine_SearchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Engine_serviceDesc.Streams[2], c.cc, "/Engine/Search", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &engineSearchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Engine_SearchClient interface {
+	Recv() (*SearchResponse, error)
+	grpc.ClientStream
+}
+
+type engineSearchClient struct {
+	grpc.ClientStream
+}
+
+func (x *engineSearchClient) Recv() (*SearchResponse, error) {
+	m := new(SearchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *engineClient) StartComponent(ctx context.Context, in *StartComponentRequest, opts ...grpc.CallOption) (*StartComponentResponse, error) {
+	out := new(StartComponentResponse)
+	err := grpc.Invoke(ctx, "/Engine/StartComponent", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return m, nil
+	return out, nil
 }
 
-func (c *engineClient) ListDrivers(ctx context.Context, in *ListDriversRequest, opts ...grpc.CallOption) (*ListDriversResponse, error) {
-	out := new(ListDriversResponse)
-	err := grpc.Invoke(ctx, "/Engine/ListDrivers", in, out, c.cc, opts...)
+func (c *engineClient) StopComponent(ctx context.Context, in *StopComponentRequest, opts ...grpc.CallOption) (*StopComponentResponse, error) {
+	out := new(StopComponentResponse)
+	err := grpc.Invoke(ctx, "/Engine/StopComponent", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *engineClient) SQL(ctx context.Context, in *SQLRequest, opts ...grpc.CallOption) (Engine_SQLClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Engine_serviceDesc.Streams[1], c.cc, "/Engine/SQL", opts...)
+func (c *engineClient) RestartComponent(ctx context.Context, in *RestartComponentRequest, opts ...grpc.CallOption) (*RestartComponentResponse, error) {
+	out := new(RestartComponentResponse)
+	err := grpc.Invoke(ctx, "/Engine/RestartComponent", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &engineSQLClient{stream}
+	return out, nil
+}
+
+func (c *engineClient) ListComponents(ctx context.Context, in *ListComponentsRequest, opts ...grpc.CallOption) (*ListComponentsResponse, error) {
+	out := new(ListComponentsResponse)
+	err := grpc.Invoke(ctx, "/Engine/ListComponents", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) ComponentStatus(ctx context.Context, in *ComponentStatusRequest, opts ...grpc.CallOption) (*ComponentStatusResponse, error) {
+	out := new(ComponentStatusResponse)
+	err := grpc.Invoke(ctx, "/Engine/ComponentStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (Engine_EventsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Engine_serviceDesc.Streams[3], c.cc, "/Engine/Events", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &engineEventsClient{stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -558,58 +1597,134 @@ func (c *engineClient) SQL(ctx context.Context, in *SQLRequest, opts ...grpc.Cal
 	return x, nil
 }
 
-type Engine_SQLClient interface {
-	Recv() (*SQLResponse, error)
+type Engine_EventsClient interface {
+	Recv() (*Event, error)
 	grpc.ClientStream
 }
 
-type engineSQLClient struct {
+type engineEventsClient struct {
 	grpc.ClientStream
 }
 
-func (x *engineSQLClient) Recv() (*SQLResponse, error) {
-	m := new(SQLResponse)
+func (x *engineEventsClient) Recv() (*Event, error) {
+	m := new(Event)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func (c *engineClient) StartComponent(ctx context.Context, in *StartComponentRequest, opts ...grpc.CallOption) (*StartComponentResponse, error) {
-	out := new(StartComponentResponse)
-	err := grpc.Invoke(ctx, "/Engine/StartComponent", in, out, c.cc, opts...)
+func (c *engineClient) StartJob(ctx context.Context, in *StartJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := grpc.Invoke(ctx, "/Engine/StartJob", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *engineClient) StopComponent(ctx context.Context, in *StopComponentRequest, opts ...grpc.CallOption) (*StopComponentResponse, error) {
-	out := new(StopComponentResponse)
-	err := grpc.Invoke(ctx, "/Engine/StopComponent", in, out, c.cc, opts...)
+func (c *engineClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	out := new(ListJobsResponse)
+	err := grpc.Invoke(ctx, "/Engine/ListJobs", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
+func (c *engineClient) CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := grpc.Invoke(ctx, "/Engine/CancelJob", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *engineClient) WatchJob(ctx context.Context, in *WatchJobRequest, opts ...grpc.CallOption) (Engine_WatchJobClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Engine_serviceDesc.Streams[4], c.cc, "/Engine/WatchJob", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &engineWatchJobClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Engine_WatchJobClient interface {
+	Recv() (*Job, error)
+	grpc.ClientStream
+}
+
+type engineWatchJobClient struct {
+	grpc.ClientStream
+}
+
+func (x *engineWatchJobClient) Recv() (*Job, error) {
+	m := new(Job)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for Engine service
 
 type EngineServer interface {
 	Version(context.Context, *VersionRequest) (*VersionResponse, error)
+	// The API version of the daemon and the optional features it supports,
+	// so the clients can check they can use it.
+	Capabilities(context.Context, *CapabilitiesRequest) (*CapabilitiesResponse, error)
 	// A single response with the parsing result.
 	Parse(context.Context, *ParseRequest) (*ParseResponse, error)
 	// A stream of responses with logs and finally the parsing result.
 	ParseWithLogs(*ParseRequest, Engine_ParseWithLogsServer) error
+	// A single response with the parsing result of each file, reusing the
+	// connection to bblfshd.
+	ParseBatch(context.Context, *ParseBatchRequest) (*ParseBatchResponse, error)
 	// Driver management.
 	// List all drivers.
 	ListDrivers(context.Context, *ListDriversRequest) (*ListDriversResponse, error)
+	// Install the driver of a language in bblfshd.
+	InstallDriver(context.Context, *InstallDriverRequest) (*InstallDriverResponse, error)
 	// SQL stuff.
+	// The first response holds the column names in row, and the following
+	// ones the result rows, read from gitbase as the client consumes them.
 	SQL(*SQLRequest, Engine_SQLServer) error
+	// Code search over the working directory. A response is sent for each
+	// matching line.
+	Search(*SearchRequest, Engine_SearchServer) error
 	// Start a component.
 	StartComponent(context.Context, *StartComponentRequest) (*StartComponentResponse, error)
 	// Stop a component.
 	StopComponent(context.Context, *StopComponentRequest) (*StopComponentResponse, error)
+	// Restart a component, keeping its public port if it was running.
+	RestartComponent(context.Context, *RestartComponentRequest) (*RestartComponentResponse, error)
+	// List the components managed by the daemon and their state.
+	ListComponents(context.Context, *ListComponentsRequest) (*ListComponentsResponse, error)
+	// State of a single component.
+	ComponentStatus(context.Context, *ComponentStatusRequest) (*ComponentStatusResponse, error)
+	// A response for each event of the components, such as image pulls or
+	// containers started, sent as they happen until the client cancels the
+	// call. The available upgrades already found are sent first.
+	Events(*EventsRequest, Engine_EventsServer) error
+	// Jobs are the long operations, like starting a component that pulls
+	// its images, run by the daemon in the background. They keep running if
+	// the client disconnects, until they finish or are cancelled.
+	// Start a job, returning it as soon as it is running.
+	StartJob(context.Context, *StartJobRequest) (*Job, error)
+	// List the jobs running and the last ones finished.
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	// Cancel a running job.
+	CancelJob(context.Context, *CancelJobRequest) (*Job, error)
+	// A response with the job each time its progress changes, until it
+	// finishes.
+	WatchJob(*WatchJobRequest, Engine_WatchJobServer) error
 }
 
 func RegisterEngineServer(s *grpc.Server, srv EngineServer) {
@@ -634,6 +1749,24 @@ func _Engine_Version_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Engine_Capabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).Capabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/Capabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).Capabilities(ctx, req.(*CapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Engine_Parse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ParseRequest)
 	if err := dec(in); err != nil {
@@ -673,6 +1806,24 @@ func (x *engineParseWithLogsServer) Send(m *ParseResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Engine_ParseBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).ParseBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/ParseBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).ParseBatch(ctx, req.(*ParseBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Engine_ListDrivers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListDriversRequest)
 	if err := dec(in); err != nil {
@@ -691,6 +1842,24 @@ func _Engine_ListDrivers_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Engine_InstallDriver_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InstallDriverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).InstallDriver(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/InstallDriver",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).InstallDriver(ctx, req.(*InstallDriverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Engine_SQL_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(SQLRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -712,6 +1881,27 @@ func (x *engineSQLServer) Send(m *SQLResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Engine_Search_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EngineServer).Search(m, &engineSearchServer{stream})
+}
+
+type Engine_SearchServer interface {
+	Send(*SearchResponse) error
+	grpc.ServerStream
+}
+
+type engineSearchServer struct {
+	grpc.ServerStream
+}
+
+func (x *engineSearchServer) Send(m *SearchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Engine_StartComponent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(StartComponentRequest)
 	if err := dec(in); err != nil {
@@ -748,6 +1938,156 @@ func _Engine_StopComponent_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Engine_RestartComponent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestartComponentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).RestartComponent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/RestartComponent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).RestartComponent(ctx, req.(*RestartComponentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Engine_ListComponents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListComponentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).ListComponents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/ListComponents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).ListComponents(ctx, req.(*ListComponentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Engine_ComponentStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ComponentStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).ComponentStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/ComponentStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).ComponentStatus(ctx, req.(*ComponentStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Engine_Events_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EngineServer).Events(m, &engineEventsServer{stream})
+}
+
+type Engine_EventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type engineEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *engineEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Engine_StartJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).StartJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/StartJob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).StartJob(ctx, req.(*StartJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Engine_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/ListJobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Engine_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EngineServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Engine/CancelJob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EngineServer).CancelJob(ctx, req.(*CancelJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Engine_WatchJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchJobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EngineServer).WatchJob(m, &engineWatchJobServer{stream})
+}
+
+type Engine_WatchJobServer interface {
+	Send(*Job) error
+	grpc.ServerStream
+}
+
+type engineWatchJobServer struct {
+	grpc.ServerStream
+}
+
+func (x *engineWatchJobServer) Send(m *Job) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _Engine_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "Engine",
 	HandlerType: (*EngineServer)(nil),
@@ -756,14 +2096,26 @@ var _Engine_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Version",
 			Handler:    _Engine_Version_Handler,
 		},
+		{
+			MethodName: "Capabilities",
+			Handler:    _Engine_Capabilities_Handler,
+		},
 		{
 			MethodName: "Parse",
 			Handler:    _Engine_Parse_Handler,
 		},
+		{
+			MethodName: "ParseBatch",
+			Handler:    _Engine_ParseBatch_Handler,
+		},
 		{
 			MethodName: "ListDrivers",
 			Handler:    _Engine_ListDrivers_Handler,
 		},
+		{
+			MethodName: "InstallDriver",
+			Handler:    _Engine_InstallDriver_Handler,
+		},
 		{
 			MethodName: "StartComponent",
 			Handler:    _Engine_StartComponent_Handler,
@@ -772,6 +2124,30 @@ var _Engine_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StopComponent",
 			Handler:    _Engine_StopComponent_Handler,
 		},
+		{
+			MethodName: "RestartComponent",
+			Handler:    _Engine_RestartComponent_Handler,
+		},
+		{
+			MethodName: "ListComponents",
+			Handler:    _Engine_ListComponents_Handler,
+		},
+		{
+			MethodName: "ComponentStatus",
+			Handler:    _Engine_ComponentStatus_Handler,
+		},
+		{
+			MethodName: "StartJob",
+			Handler:    _Engine_StartJob_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _Engine_ListJobs_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _Engine_CancelJob_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -784,6 +2160,21 @@ var _Engine_serviceDesc = grpc.ServiceDesc{
 			Handler:       _Engine_SQL_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Search",
+			Handler:       _Engine_Search_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Events",
+			Handler:       _Engine_Events_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchJob",
+			Handler:       _Engine_WatchJob_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "api.proto",
 }
@@ -791,46 +2182,114 @@ var _Engine_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 656 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xdb, 0x6e, 0xd3, 0x4c,
-	0x10, 0xf6, 0x29, 0xa7, 0xc9, 0xa1, 0xd6, 0x34, 0x4d, 0xfd, 0xfb, 0xe6, 0xaf, 0x56, 0x88, 0x46,
-	0x05, 0xad, 0x50, 0x7a, 0x45, 0x6f, 0xc0, 0x6a, 0x43, 0x15, 0xe1, 0xba, 0xd4, 0x49, 0xcb, 0xb5,
-	0x69, 0x96, 0x60, 0x91, 0x7a, 0x53, 0xdb, 0xa1, 0xe2, 0x1d, 0x78, 0x00, 0x24, 0x1e, 0x16, 0xe4,
-	0xb5, 0x9d, 0xda, 0xc1, 0x08, 0xee, 0x66, 0x67, 0xbe, 0x9d, 0xd3, 0xf7, 0xed, 0x42, 0xcb, 0x5b,
-	0xf9, 0x74, 0x15, 0xf2, 0x98, 0x13, 0x1d, 0x7a, 0x37, 0x2c, 0x8c, 0x7c, 0x1e, 0xb8, 0xec, 0x7e,
-	0xcd, 0xa2, 0x98, 0x3c, 0x83, 0x9d, 0x8d, 0x27, 0x5a, 0xf1, 0x20, 0x62, 0x68, 0x40, 0xe3, 0x4b,
-	0xea, 0x32, 0xe4, 0x03, 0x79, 0xd8, 0x72, 0xf3, 0x23, 0xf9, 0xae, 0x40, 0xe7, 0x9d, 0x17, 0x46,
-	0x2c, 0xbb, 0x8d, 0x4f, 0x41, 0xfb, 0xec, 0x07, 0x73, 0x81, 0xeb, 0x8d, 0x90, 0x16, 0x83, 0xf4,
-	0xad, 0x1f, 0xcc, 0x5d, 0x11, 0x47, 0x04, 0x2d, 0xf0, 0xee, 0x98, 0xa1, 0x88, 0x7c, 0xc2, 0x4e,
-	0xca, 0xdc, 0xf2, 0x20, 0x66, 0x41, 0x6c, 0xa8, 0x07, 0xf2, 0xb0, 0xe3, 0xe6, 0xc7, 0x04, 0xbd,
-	0xf4, 0x82, 0x85, 0xa1, 0xa5, 0xe8, 0xc4, 0xc6, 0x3e, 0xd4, 0xee, 0xd7, 0x2c, 0xfc, 0x6a, 0xd4,
-	0x84, 0x33, 0x3d, 0xe0, 0x11, 0x68, 0x77, 0x7c, 0xce, 0x8c, 0xba, 0xa8, 0x3f, 0x28, 0xd7, 0xbf,
-	0xf6, 0xa2, 0xf8, 0x82, 0xcf, 0x99, 0x2b, 0x30, 0xe4, 0x10, 0xb4, 0xa4, 0x23, 0x6c, 0x43, 0x63,
-	0xe2, 0xdc, 0x58, 0xf6, 0xe4, 0x4c, 0x97, 0xb0, 0x09, 0x9a, 0x6d, 0x39, 0xe7, 0xba, 0x9c, 0x58,
-	0xd7, 0xd6, 0x74, 0xa6, 0x2b, 0xe4, 0x18, 0x9a, 0xf9, 0x55, 0xec, 0x40, 0x73, 0x3a, 0xbe, 0xb0,
-	0x9c, 0xd9, 0xe4, 0x54, 0x97, 0xb0, 0x0b, 0x2d, 0xcb, 0x71, 0x2e, 0x67, 0xd6, 0x6c, 0x7c, 0xa6,
-	0xcb, 0x08, 0x50, 0x77, 0xac, 0xd9, 0xe4, 0x66, 0xac, 0x2b, 0xe4, 0x87, 0x0c, 0xdd, 0xac, 0x7a,
-	0xb6, 0xc6, 0xc3, 0xd2, 0x6e, 0x76, 0x69, 0x29, 0xba, 0xb5, 0x1c, 0x31, 0xae, 0x52, 0x18, 0x17,
-	0x41, 0x5b, 0x7b, 0x51, 0xb2, 0x19, 0x75, 0xd8, 0x71, 0x85, 0x8d, 0x3a, 0xa8, 0x4b, 0x9e, 0x6f,
-	0x25, 0x31, 0xab, 0x47, 0x6a, 0x80, 0x6a, 0x5f, 0x26, 0x13, 0xb5, 0xa0, 0xf6, 0x66, 0xe2, 0x58,
-	0xb6, 0xae, 0x90, 0x3e, 0xa0, 0xed, 0x47, 0xf1, 0x59, 0xe8, 0x27, 0x54, 0xe6, 0xdc, 0x7f, 0x93,
-	0x61, 0xb7, 0xe4, 0xce, 0x3a, 0x7f, 0x09, 0x8d, 0x79, 0xea, 0x32, 0xe4, 0x03, 0x75, 0xd8, 0x1e,
-	0xfd, 0x4f, 0x2b, 0x60, 0x34, 0x3d, 0x4f, 0x82, 0x8f, 0xdc, 0xcd, 0xf1, 0xe6, 0x09, 0xc0, 0xa3,
-	0x7b, 0x33, 0x99, 0x5c, 0x98, 0xac, 0xa0, 0x2e, 0xa5, 0xac, 0x2e, 0x02, 0x30, 0xbd, 0xb2, 0x73,
-	0x69, 0x6d, 0x08, 0x97, 0x0b, 0x84, 0x13, 0x1b, 0xda, 0x02, 0x93, 0x75, 0x4a, 0x40, 0x0d, 0xf9,
-	0x83, 0x80, 0xb4, 0x47, 0x3a, 0x2d, 0x84, 0xa8, 0xcb, 0x1f, 0xdc, 0x24, 0x68, 0xfe, 0x07, 0xaa,
-	0xcb, 0x1f, 0x92, 0x5e, 0x6e, 0xd9, 0x72, 0x29, 0x26, 0xea, 0xb8, 0xc2, 0x26, 0xaf, 0x60, 0x6f,
-	0x1a, 0x7b, 0x61, 0x7c, 0xca, 0xef, 0x56, 0x3c, 0x60, 0x41, 0x9c, 0x17, 0xcf, 0xf5, 0x2a, 0x17,
-	0xf4, 0x8a, 0xa0, 0xad, 0x78, 0x18, 0x8b, 0xae, 0x6b, 0xae, 0xb0, 0xc9, 0x73, 0x18, 0x6c, 0x27,
-	0xc8, 0x3a, 0xcb, 0xd1, 0x72, 0x01, 0x7d, 0x04, 0xfd, 0x69, 0xcc, 0x57, 0xff, 0x52, 0x8d, 0xec,
-	0x27, 0xad, 0x95, 0xb0, 0x69, 0x62, 0x72, 0xbe, 0x79, 0xb0, 0x6c, 0x9e, 0xae, 0x1a, 0x4d, 0x68,
-	0x26, 0xab, 0x5d, 0x7b, 0x8b, 0x3c, 0xc7, 0xe6, 0xfc, 0xe7, 0x75, 0x8f, 0x7e, 0x2a, 0x50, 0x1f,
-	0x07, 0x0b, 0x3f, 0x60, 0x48, 0xa1, 0x91, 0xe5, 0xc4, 0x1d, 0x5a, 0xfe, 0x20, 0x4c, 0x9d, 0x6e,
-	0xfd, 0x0f, 0x44, 0xc2, 0x21, 0xd4, 0x84, 0x9a, 0xb1, 0x5b, 0x7a, 0x71, 0x66, 0xaf, 0x2c, 0x72,
-	0x22, 0xe1, 0x28, 0x7b, 0x15, 0xef, 0xfd, 0xf8, 0x93, 0xcd, 0x17, 0xd1, 0x5f, 0x6f, 0xbc, 0x90,
-	0xf1, 0x04, 0xda, 0x05, 0xb9, 0xe1, 0x2e, 0xfd, 0x5d, 0xba, 0x66, 0xbf, 0x4a, 0x91, 0x44, 0xc2,
-	0x27, 0xa0, 0x4e, 0xaf, 0x6c, 0x6c, 0xd3, 0x47, 0x25, 0x99, 0x9d, 0xa2, 0x2e, 0x44, 0x85, 0x53,
-	0xe8, 0x95, 0x69, 0xc3, 0x01, 0xad, 0x14, 0x82, 0xb9, 0x4f, 0xab, 0xf9, 0x25, 0x12, 0xbe, 0x86,
-	0x6e, 0x89, 0x21, 0xdc, 0xa3, 0x55, 0xec, 0x9a, 0x03, 0x5a, 0x4d, 0xa4, 0xf4, 0xa1, 0x2e, 0x3e,
-	0xe5, 0xe3, 0x5f, 0x01, 0x00, 0x00, 0xff, 0xff, 0xcb, 0xc6, 0x0a, 0x40, 0xa1, 0x05, 0x00, 0x00,
+	// 1737 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x58, 0x5b, 0x6f, 0xe3, 0xc6,
+	0x15, 0x16, 0x45, 0x51, 0x97, 0xa3, 0x1b, 0x3d, 0x96, 0x65, 0x2e, 0x91, 0x76, 0xb7, 0xd3, 0xc5,
+	0xc6, 0x48, 0xb7, 0x83, 0xd4, 0x29, 0x7a, 0x09, 0x10, 0x34, 0x8c, 0xa4, 0xf5, 0x6a, 0xab, 0x95,
+	0x9d, 0x91, 0xbc, 0x01, 0x8a, 0x02, 0x02, 0x65, 0xcd, 0xda, 0x6c, 0x24, 0x52, 0x21, 0xa9, 0x75,
+	0x36, 0xff, 0xa0, 0x40, 0x81, 0x16, 0x05, 0x0a, 0x14, 0xe8, 0x43, 0xdf, 0xfa, 0xd4, 0x1f, 0x59,
+	0xcc, 0x90, 0x43, 0x91, 0x34, 0xed, 0xa4, 0x6f, 0x73, 0xce, 0x9c, 0x99, 0x73, 0x99, 0x73, 0x3e,
+	0x7e, 0x12, 0x34, 0xec, 0xad, 0x43, 0xb6, 0xbe, 0x17, 0x7a, 0x58, 0x87, 0xce, 0x1b, 0xe6, 0x07,
+	0x8e, 0xe7, 0x52, 0xf6, 0xcd, 0x8e, 0x05, 0x21, 0xfe, 0x19, 0x74, 0x13, 0x4d, 0xb0, 0xf5, 0xdc,
+	0x80, 0x21, 0x03, 0x6a, 0xef, 0x22, 0x95, 0xa1, 0x3c, 0x51, 0x4e, 0x1a, 0x54, 0x8a, 0xf8, 0x9f,
+	0x65, 0x68, 0x5d, 0xd8, 0x7e, 0xc0, 0xe2, 0xd3, 0xe8, 0x19, 0x54, 0xbe, 0x76, 0xdc, 0x95, 0xb0,
+	0xeb, 0x9c, 0x22, 0x92, 0xde, 0x24, 0xbf, 0x77, 0xdc, 0x15, 0x15, 0xfb, 0x08, 0x41, 0xc5, 0xb5,
+	0x37, 0xcc, 0x28, 0x8b, 0xfb, 0xc4, 0x9a, 0xbb, 0xb9, 0xf2, 0xdc, 0x90, 0xb9, 0xa1, 0xa1, 0x3e,
+	0x51, 0x4e, 0x5a, 0x54, 0x8a, 0xdc, 0x7a, 0x6d, 0xbb, 0xd7, 0x46, 0x25, 0xb2, 0xe6, 0x6b, 0xd4,
+	0x03, 0xed, 0x9b, 0x1d, 0xf3, 0xdf, 0x1b, 0x9a, 0x50, 0x46, 0x02, 0xfa, 0x08, 0x2a, 0x1b, 0x6f,
+	0xc5, 0x8c, 0xaa, 0xf0, 0xdf, 0xcf, 0xfa, 0xbf, 0xb4, 0x83, 0xf0, 0xb5, 0xb7, 0x62, 0x54, 0xd8,
+	0xe0, 0x0f, 0xa1, 0xc2, 0x23, 0x42, 0x4d, 0xa8, 0x8d, 0xa7, 0x6f, 0xac, 0xc9, 0x78, 0xa8, 0x97,
+	0x50, 0x1d, 0x2a, 0x13, 0x6b, 0x7a, 0xa6, 0x2b, 0x7c, 0x75, 0x69, 0xcd, 0xe6, 0x7a, 0x19, 0x7f,
+	0x02, 0x75, 0x79, 0x14, 0xb5, 0xa0, 0x3e, 0x1b, 0xbd, 0xb6, 0xa6, 0xf3, 0xf1, 0x40, 0x2f, 0xa1,
+	0x36, 0x34, 0xac, 0xe9, 0xf4, 0x7c, 0x6e, 0xcd, 0x47, 0x43, 0x5d, 0x41, 0x00, 0xd5, 0xa9, 0x35,
+	0x1f, 0xbf, 0x19, 0xe9, 0x65, 0xfc, 0x2f, 0x05, 0xda, 0xb1, 0xf7, 0xb8, 0x8c, 0x1f, 0x66, 0x6a,
+	0x73, 0x48, 0x32, 0xbb, 0xb9, 0xe2, 0x88, 0x74, 0xcb, 0xa9, 0x74, 0x11, 0x54, 0x76, 0x76, 0xc0,
+	0x2b, 0xa3, 0x9e, 0xb4, 0xa8, 0x58, 0x23, 0x1d, 0xd4, 0xb5, 0x27, 0xab, 0xc2, 0x97, 0xc5, 0x29,
+	0xd5, 0x40, 0x9d, 0x9c, 0xf3, 0x8c, 0x1a, 0xa0, 0xbd, 0x18, 0x4f, 0xad, 0x89, 0x5e, 0xc6, 0xbf,
+	0x81, 0x03, 0xe1, 0xfe, 0x0b, 0x3b, 0xbc, 0xba, 0x91, 0x8f, 0xf7, 0x53, 0xd0, 0xde, 0x3a, 0x6b,
+	0x16, 0x18, 0xca, 0x13, 0xf5, 0xa4, 0x79, 0xda, 0xce, 0x54, 0x8f, 0x46, 0x7b, 0xf8, 0x3f, 0x0a,
+	0xa0, 0xf4, 0xd1, 0x38, 0xb9, 0x5f, 0x42, 0xcd, 0x67, 0xc1, 0x6e, 0x1d, 0xca, 0xd3, 0x26, 0xb9,
+	0x6b, 0x45, 0xa8, 0x30, 0xa1, 0xd2, 0xd4, 0xfc, 0x03, 0x54, 0x23, 0x55, 0xd2, 0x10, 0x4a, 0xaa,
+	0x21, 0x7e, 0x68, 0x1d, 0x7a, 0xa0, 0x31, 0xdf, 0xf7, 0xfc, 0xb8, 0x12, 0x91, 0x80, 0x7b, 0x80,
+	0x26, 0x4e, 0x10, 0x0e, 0x7d, 0x87, 0x77, 0xab, 0x6c, 0xef, 0xbf, 0x28, 0x70, 0x98, 0x51, 0xc7,
+	0xf1, 0xff, 0x16, 0x6a, 0xab, 0x48, 0x15, 0xc7, 0xff, 0x98, 0x14, 0x98, 0x91, 0x48, 0x1e, 0xbb,
+	0x6f, 0x3d, 0x2a, 0xed, 0xcd, 0x4f, 0x01, 0xf6, 0xea, 0x24, 0x68, 0x25, 0x15, 0x74, 0x6a, 0x80,
+	0xca, 0xd9, 0x01, 0xfa, 0x1c, 0x7a, 0x63, 0x37, 0x08, 0xed, 0xf5, 0x3a, 0xba, 0x42, 0x3e, 0x45,
+	0xd1, 0x2d, 0x3d, 0xd0, 0x9c, 0x8d, 0x7d, 0x2d, 0x87, 0x26, 0x12, 0xf0, 0x31, 0x1c, 0xe5, 0x6e,
+	0x88, 0x42, 0xc5, 0x7f, 0x04, 0x98, 0x7d, 0x39, 0x91, 0x17, 0x26, 0xe3, 0xa2, 0xa4, 0xc7, 0xe5,
+	0x11, 0xd4, 0x37, 0xf6, 0xb7, 0x0b, 0xdf, 0xbb, 0x0d, 0xc4, 0xad, 0x2a, 0xad, 0x6d, 0xec, 0x6f,
+	0xa9, 0x77, 0x1b, 0xa0, 0x1f, 0x01, 0x2c, 0xf9, 0xdb, 0x2d, 0x02, 0xe7, 0x3b, 0x26, 0x06, 0x52,
+	0xa3, 0x0d, 0xa1, 0x99, 0x39, 0xdf, 0x31, 0xfc, 0x37, 0x05, 0x9a, 0xe2, 0xfa, 0xb8, 0x7e, 0x18,
+	0x54, 0xdf, 0xbb, 0x15, 0xb7, 0x37, 0x4f, 0x75, 0x92, 0xda, 0x22, 0xd4, 0xbb, 0xa5, 0x7c, 0x13,
+	0x3d, 0x85, 0x4a, 0xec, 0x49, 0x2d, 0x34, 0x12, 0xbb, 0xe8, 0x03, 0x68, 0x84, 0xfe, 0xce, 0xbd,
+	0xb2, 0x43, 0xb6, 0x12, 0x7e, 0xeb, 0x74, 0xaf, 0x30, 0x1f, 0x81, 0x4a, 0xbd, 0x5b, 0x5e, 0x9f,
+	0x2b, 0xb6, 0x5e, 0x8b, 0xb7, 0x6a, 0x51, 0xb1, 0xc6, 0x21, 0xb4, 0x67, 0xcc, 0xf6, 0xf7, 0xfd,
+	0x6c, 0x40, 0x6d, 0x6b, 0x87, 0x21, 0xf3, 0x13, 0xdc, 0x8a, 0xc5, 0xc2, 0xce, 0x7a, 0x0c, 0x4d,
+	0xe7, 0xda, 0xf5, 0x7c, 0xb6, 0xb8, 0xb2, 0x03, 0x16, 0x7b, 0x86, 0x48, 0x35, 0xb0, 0x03, 0xc6,
+	0x4b, 0xe8, 0xb3, 0xad, 0x17, 0xc8, 0x36, 0x13, 0x02, 0x7e, 0x0f, 0x1d, 0xe9, 0x35, 0x2e, 0xc5,
+	0x8f, 0x01, 0xc4, 0x96, 0x13, 0x7a, 0x49, 0xbd, 0x53, 0x1a, 0xee, 0x9c, 0x8f, 0x92, 0x74, 0xce,
+	0xd7, 0xdc, 0xf9, 0xda, 0x71, 0xd9, 0xc2, 0xdd, 0x6d, 0x96, 0xcc, 0x8f, 0xcb, 0x0d, 0x5c, 0x35,
+	0x15, 0x1a, 0x11, 0xb1, 0xe3, 0xb2, 0x04, 0x02, 0x1d, 0x97, 0xe1, 0xdf, 0xc1, 0xd1, 0x2c, 0xb4,
+	0xfd, 0x70, 0xe0, 0x6d, 0xb6, 0x9e, 0xcb, 0xdc, 0x30, 0xd5, 0x3d, 0x45, 0xc3, 0xb4, 0xf5, 0xfc,
+	0x50, 0x78, 0xd5, 0xa8, 0x58, 0xe3, 0xe7, 0xd0, 0xcf, 0x5f, 0x10, 0xe7, 0x20, 0xad, 0x95, 0x94,
+	0xf5, 0x47, 0xd0, 0x9b, 0x85, 0xde, 0xf6, 0x87, 0x78, 0xe3, 0x5d, 0x99, 0xb3, 0x8d, 0xbb, 0xd2,
+	0x82, 0x63, 0xca, 0x82, 0xff, 0x27, 0xea, 0x1b, 0xdb, 0x5f, 0x89, 0xa8, 0xeb, 0x54, 0xac, 0x31,
+	0x01, 0xe3, 0xee, 0x15, 0x0f, 0xc4, 0xcd, 0xa0, 0x9d, 0x18, 0xca, 0x11, 0xbd, 0xe3, 0xa8, 0x70,
+	0xb8, 0xb8, 0x36, 0x08, 0xed, 0x30, 0xea, 0x86, 0x06, 0x8d, 0x04, 0xae, 0xe5, 0x17, 0xf3, 0x46,
+	0x50, 0x4f, 0x34, 0x1a, 0x09, 0x3c, 0x65, 0x8e, 0x18, 0x89, 0xab, 0x04, 0x72, 0x5e, 0x42, 0x3f,
+	0xbf, 0x11, 0x47, 0x4b, 0x00, 0xae, 0x12, 0x6d, 0x8c, 0x3b, 0x1d, 0x92, 0x09, 0x96, 0xa6, 0x2c,
+	0xf8, 0x7b, 0x25, 0x9b, 0xb3, 0xd0, 0x0e, 0x77, 0xc1, 0x43, 0x6f, 0x70, 0x06, 0xc7, 0x77, 0xac,
+	0x63, 0xc7, 0xcf, 0xa1, 0x91, 0x5c, 0x1b, 0xcf, 0x6c, 0xde, 0xef, 0xde, 0x00, 0x77, 0xa1, 0x3d,
+	0x7a, 0x97, 0xce, 0xe8, 0xdf, 0x65, 0xd0, 0x84, 0x06, 0x3d, 0xce, 0x7c, 0xd3, 0x9a, 0x44, 0x68,
+	0xd3, 0xdf, 0xb2, 0x0f, 0xd2, 0x9e, 0xa2, 0xda, 0xee, 0x15, 0xfb, 0xaa, 0xab, 0xe9, 0xaa, 0x1b,
+	0x50, 0xdb, 0xb0, 0x20, 0xb0, 0xaf, 0x65, 0xbb, 0x4b, 0x91, 0xa7, 0x19, 0x3a, 0x1b, 0x26, 0xbe,
+	0xf9, 0x2a, 0x15, 0x6b, 0xfc, 0x77, 0xa5, 0xe8, 0xa3, 0xa7, 0x43, 0xeb, 0xe2, 0x72, 0x32, 0x59,
+	0xcc, 0xe6, 0x16, 0x8d, 0x3e, 0xce, 0x07, 0xd0, 0x16, 0x9a, 0x17, 0xe3, 0xe9, 0x78, 0xf6, 0x72,
+	0x34, 0xd4, 0xcb, 0xe8, 0x08, 0x0e, 0x06, 0xe7, 0xaf, 0x2f, 0xce, 0xa7, 0xa3, 0xe9, 0x3c, 0xb1,
+	0x54, 0xf3, 0xea, 0xf3, 0x8b, 0x8b, 0xd1, 0x50, 0xaf, 0xa0, 0x43, 0xe8, 0xbe, 0x1c, 0x59, 0x93,
+	0xf9, 0xcb, 0xc5, 0x70, 0x74, 0x46, 0xad, 0xe1, 0x68, 0xa8, 0x6b, 0xdc, 0xf6, 0xf2, 0x42, 0x48,
+	0x0b, 0xeb, 0x8d, 0x35, 0x9e, 0x58, 0x5f, 0x4c, 0x46, 0x7a, 0x15, 0x9f, 0x25, 0x2c, 0x8a, 0xad,
+	0x22, 0x5c, 0x46, 0x26, 0xd4, 0x39, 0xce, 0xec, 0x78, 0x5a, 0xd1, 0x33, 0x25, 0xf2, 0x03, 0x1f,
+	0x88, 0x5f, 0xc1, 0xe1, 0xc0, 0xde, 0xda, 0x4b, 0x67, 0xed, 0x84, 0x0e, 0x4b, 0xde, 0xfb, 0x31,
+	0x34, 0xed, 0xad, 0xb3, 0x48, 0xd3, 0x32, 0x8d, 0x82, 0xbd, 0x75, 0x62, 0xaf, 0xf8, 0x1f, 0x0a,
+	0xf4, 0xb2, 0x07, 0xe3, 0xa7, 0xff, 0xbe, 0x93, 0xe8, 0x19, 0x74, 0x37, 0x8e, 0xbb, 0x48, 0x1b,
+	0x45, 0x98, 0xd1, 0xde, 0x38, 0xae, 0xb5, 0xb7, 0x4b, 0xc5, 0xac, 0x66, 0x62, 0xe6, 0x99, 0xbe,
+	0x65, 0x76, 0xb8, 0xf3, 0x59, 0x34, 0x22, 0x0d, 0x9a, 0xc8, 0xf8, 0xbf, 0x65, 0x50, 0x5f, 0x79,
+	0x4b, 0xd4, 0x81, 0xb2, 0xb3, 0x8a, 0xeb, 0x50, 0x76, 0x04, 0xe7, 0x11, 0x8d, 0x14, 0x83, 0x62,
+	0x86, 0x24, 0xaa, 0xa9, 0x39, 0x7d, 0x22, 0x27, 0xb2, 0x22, 0x3a, 0x0e, 0xc8, 0x2b, 0x6f, 0x49,
+	0x78, 0x77, 0x33, 0x39, 0x9d, 0xa9, 0xee, 0xd1, 0xb2, 0xdd, 0x93, 0xf0, 0x84, 0x6a, 0x8a, 0x27,
+	0x08, 0x46, 0x11, 0x30, 0xdf, 0xa8, 0x45, 0x5e, 0xf8, 0x9a, 0xdf, 0x21, 0x00, 0x86, 0xad, 0x8c,
+	0x7a, 0xf4, 0x59, 0x8c, 0x45, 0x91, 0x9b, 0xe3, 0x3a, 0xc1, 0x0d, 0x5b, 0x19, 0x0d, 0xb1, 0x95,
+	0xc8, 0x09, 0xf8, 0x40, 0x0a, 0x7c, 0x3e, 0x03, 0x4d, 0x44, 0xc7, 0xbb, 0x93, 0x5e, 0x4e, 0xa7,
+	0xe3, 0xe9, 0x59, 0xc4, 0x1b, 0x67, 0x97, 0x83, 0xc1, 0x68, 0x34, 0x94, 0xbc, 0xf1, 0x85, 0x35,
+	0x9e, 0x88, 0x9e, 0x6c, 0x41, 0x7d, 0x60, 0x4d, 0x07, 0x23, 0x2e, 0xa9, 0xf8, 0xcf, 0x65, 0xe8,
+	0x0a, 0x88, 0x7e, 0xe5, 0x2d, 0x53, 0xb3, 0x9e, 0xcc, 0x5c, 0xe3, 0x01, 0x3e, 0x5d, 0x3c, 0x5c,
+	0x12, 0x51, 0x2b, 0x7b, 0x44, 0xbd, 0x87, 0x4b, 0xf7, 0x40, 0xe3, 0x0d, 0x1a, 0x18, 0x55, 0xf1,
+	0x86, 0x91, 0xc0, 0xcf, 0x0b, 0x46, 0x50, 0x8b, 0x46, 0x90, 0xaf, 0x79, 0xb9, 0x6e, 0x3d, 0xff,
+	0x6b, 0x4e, 0x9e, 0xea, 0x22, 0x77, 0x29, 0x46, 0x85, 0xf4, 0x99, 0xbd, 0x09, 0x44, 0xb5, 0x34,
+	0x2a, 0x45, 0xbe, 0xe3, 0xb3, 0xd0, 0x77, 0x58, 0x10, 0xd7, 0x4b, 0x8a, 0xa8, 0x0f, 0xd5, 0x77,
+	0xcc, 0x77, 0xde, 0xbe, 0x37, 0x9a, 0x22, 0xc6, 0x58, 0xc2, 0x07, 0xd0, 0xe5, 0x38, 0xfa, 0xca,
+	0x5b, 0x26, 0x40, 0xf4, 0x1c, 0xf4, 0xbd, 0x2a, 0xf9, 0xb5, 0x52, 0xf9, 0x93, 0xb7, 0x94, 0x70,
+	0x5a, 0xe1, 0x0d, 0x42, 0x85, 0x06, 0x63, 0xd0, 0x07, 0xb6, 0x7b, 0xc5, 0xd6, 0xa9, 0x62, 0xe6,
+	0xfa, 0x10, 0xff, 0x04, 0xba, 0x5f, 0x71, 0x92, 0x73, 0xbf, 0xc9, 0xe9, 0x5f, 0xeb, 0x50, 0x1d,
+	0xb9, 0xd7, 0x8e, 0xcb, 0x01, 0xbc, 0x26, 0xc7, 0xa1, 0x4b, 0xb2, 0x3f, 0xa4, 0x4c, 0x9d, 0xe4,
+	0x7e, 0x47, 0xe1, 0x12, 0xfa, 0x0c, 0x5a, 0xe9, 0xa1, 0x44, 0x3d, 0x52, 0x30, 0xdc, 0xe6, 0x11,
+	0x29, 0x9a, 0x5c, 0x5c, 0x42, 0x27, 0xa0, 0x09, 0x52, 0x8d, 0xb2, 0xd4, 0xdc, 0xec, 0x64, 0x7f,
+	0x4b, 0xe0, 0x12, 0x3a, 0x8d, 0x7f, 0x7c, 0x7c, 0xe5, 0x84, 0x37, 0x13, 0xef, 0x3a, 0xf8, 0xde,
+	0x13, 0x1f, 0x2b, 0xe8, 0xd7, 0x00, 0x7b, 0xca, 0x8e, 0x10, 0xb9, 0xf3, 0x03, 0xc1, 0x3c, 0x2c,
+	0xe0, 0xf4, 0xb8, 0x84, 0x3e, 0x85, 0x66, 0x8a, 0x2b, 0xa3, 0x43, 0x72, 0x97, 0x77, 0x9b, 0xbd,
+	0x22, 0x3a, 0x8d, 0x4b, 0xe8, 0x73, 0x68, 0x67, 0xe8, 0x2b, 0x3a, 0x22, 0x45, 0x84, 0xd8, 0xec,
+	0x93, 0x62, 0x96, 0x5b, 0x42, 0x4f, 0x41, 0x9d, 0x7d, 0x39, 0x41, 0x4d, 0xb2, 0x67, 0xbb, 0x66,
+	0x2b, 0xcd, 0x2d, 0x45, 0x72, 0x3f, 0x87, 0x6a, 0x44, 0xd3, 0x50, 0x87, 0x64, 0x58, 0xa2, 0xd9,
+	0x25, 0x59, 0xfe, 0x26, 0xcc, 0x07, 0xd0, 0xc9, 0x32, 0x23, 0xd4, 0x27, 0x85, 0x5c, 0xcb, 0x3c,
+	0x26, 0xc5, 0x14, 0x2a, 0xca, 0x2d, 0x43, 0x82, 0xd0, 0x11, 0x29, 0x22, 0x50, 0x66, 0x9f, 0x14,
+	0x73, 0xa5, 0x12, 0x1a, 0x83, 0x9e, 0xa7, 0x3a, 0xc8, 0x20, 0xf7, 0x10, 0x28, 0xf3, 0x11, 0xb9,
+	0x8f, 0x17, 0xe1, 0x12, 0xcf, 0x28, 0xcb, 0x42, 0x50, 0x9f, 0x14, 0xf2, 0x15, 0xf3, 0x98, 0x14,
+	0xd3, 0x15, 0x5c, 0x42, 0x2f, 0xa0, 0x9b, 0xa3, 0x14, 0xe8, 0x98, 0x14, 0x53, 0x12, 0xd3, 0x20,
+	0xf7, 0xb0, 0x0f, 0xf1, 0x66, 0xd5, 0x88, 0x51, 0xa0, 0x0e, 0xc9, 0x50, 0x0b, 0xb3, 0x1a, 0xc9,
+	0xe2, 0x11, 0x9e, 0x42, 0x5d, 0x62, 0x1f, 0xd2, 0x49, 0x0e, 0x06, 0x4d, 0x31, 0xd9, 0xb8, 0x84,
+	0x7e, 0x01, 0x75, 0x89, 0x01, 0x48, 0x27, 0x39, 0x84, 0x30, 0x0f, 0x48, 0x1e, 0x20, 0x70, 0x09,
+	0x3d, 0x83, 0x46, 0x02, 0x04, 0xe8, 0x80, 0xe4, 0x41, 0x21, 0xb9, 0xfa, 0x19, 0xd4, 0x25, 0x18,
+	0x20, 0x9d, 0xe4, 0x70, 0x41, 0x5a, 0x7d, 0xac, 0x2c, 0xab, 0xe2, 0xcf, 0x94, 0x4f, 0xfe, 0x37,
+	0x00, 0xfc, 0x0d, 0xfe, 0x7b, 0x59, 0x11, 0x00, 0x00,
 }
@@ -0,0 +1,58 @@
+package api
+
+const (
+	// APIVersion is the version of the engine API implemented by this
+	// package. It is increased with the changes that break the clients or the
+	// servers of older versions, not with the new optional features
+	APIVersion = 1
+	// MinAPIVersion is the oldest API version of the clients that the daemon
+	// works with, and of the daemons that the clients work with
+	MinAPIVersion = 1
+)
+
+// Optional features of the engine API. A daemon that predates the
+// Capabilities method does not report them, and the clients should assume
+// it does not support any.
+const (
+	// FeatureParseBatch is the ParseBatch method
+	FeatureParseBatch = "parse-batch"
+	// FeatureSearch is the Search method
+	FeatureSearch = "search"
+	// FeatureRestartComponent is the RestartComponent method
+	FeatureRestartComponent = "restart-component"
+	// FeatureComponentStatus is the ComponentStatus method
+	FeatureComponentStatus = "component-status"
+	// FeatureEvents is the Events method
+	FeatureEvents = "events"
+	// FeatureJobs are the StartJob, ListJobs, CancelJob and WatchJob methods
+	FeatureJobs = "jobs"
+	// FeatureFetchDatasetJob is the fetch-dataset kind of job
+	FeatureFetchDatasetJob = "fetch-dataset-job"
+	// FeaturePullImageJob is the pull-image kind of job
+	FeaturePullImageJob = "pull-image-job"
+)
+
+// Features returns the optional features implemented by this package
+func Features() []string {
+	return []string{
+		FeatureParseBatch,
+		FeatureSearch,
+		FeatureRestartComponent,
+		FeatureComponentStatus,
+		FeatureEvents,
+		FeatureJobs,
+		FeatureFetchDatasetJob,
+		FeaturePullImageJob,
+	}
+}
+
+// HasFeature returns true if the capabilities include the given feature
+func (m *CapabilitiesResponse) HasFeature(name string) bool {
+	for _, f := range m.GetFeatures() {
+		if f == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,10 +1,23 @@
 package api
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
 
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -14,21 +27,129 @@ type Config struct {
 		Bblfshd struct {
 			// Port is the public exposed port for this component's container
 			Port int
+			// Version is the image version, set for the components upgraded
+			// with srcd components upgrade. The default one is used if empty
+			Version string `yaml:"version,omitempty"`
+			// Env has extra environment variables for the container, which
+			// override the ones set by default
+			Env map[string]string `yaml:"env,omitempty"`
+			// Args are extra command line arguments, appended to the ones of
+			// the container
+			Args []string `yaml:"args,omitempty"`
 		}
 
 		BblfshWeb struct {
 			// Port is the public exposed port for this component's container
 			Port int
+			// Version is the image version, set for the components upgraded
+			// with srcd components upgrade. The default one is used if empty
+			Version string `yaml:"version,omitempty"`
+			// Env has extra environment variables for the container, which
+			// override the ones set by default
+			Env map[string]string `yaml:"env,omitempty"`
+			// Args are extra command line arguments, appended to the ones of
+			// the container
+			Args []string `yaml:"args,omitempty"`
 		} `yaml:"bblfsh_web"`
 
 		GitbaseWeb struct {
 			// Port is the public exposed port for this component's container
 			Port int
+			// Version is the image version, set for the components upgraded
+			// with srcd components upgrade. The default one is used if empty
+			Version string `yaml:"version,omitempty"`
+			// Env has extra environment variables for the container, which
+			// override the ones set by default
+			Env map[string]string `yaml:"env,omitempty"`
+			// Args are extra command line arguments, appended to the ones of
+			// the container
+			Args []string `yaml:"args,omitempty"`
 		} `yaml:"gitbase_web"`
 
 		Gitbase struct {
 			// Port is the public exposed port for this component's container
 			Port int
+			// Version is the image version, set for the components upgraded
+			// with srcd components upgrade. The default one is used if empty
+			Version string `yaml:"version,omitempty"`
+			// Env has extra environment variables for the container, which
+			// override the ones set by default
+			Env map[string]string `yaml:"env,omitempty"`
+			// Args are extra command line arguments, appended to the ones of
+			// the container
+			Args []string `yaml:"args,omitempty"`
+			// IndexQuota is the disk space, e.g. 10GB, above which srcd status
+			// warns about the size of the gitbase index volumes. No warning
+			// is shown if it is empty
+			IndexQuota string `yaml:"index_quota,omitempty"`
+			// User and Password are the credentials gitbase requires from
+			// its clients, see GitbaseCredentials. Anyone reaching its port
+			// can run queries if Password is empty
+			User     string `yaml:"user,omitempty"`
+			Password string `yaml:"password,omitempty"`
+			// Publish is where the gitbase port is published in the host:
+			// internal to only reach it from the srcd network, localhost, the
+			// default, or all for every interface, see GitbasePublishIP
+			Publish string `yaml:"publish,omitempty"`
+			// Shards split the repositories of the workdir between several
+			// gitbase instances, and the queries of the daemon run in all of
+			// them, see GitbaseShards. A single gitbase is used if it is
+			// empty
+			Shards []GitbaseShard `yaml:"shards,omitempty"`
+			// Format is the format of the repositories of the workdir:
+			// git, the default, for the ones with a working tree, bare for
+			// bare repositories, or siva for the rooted siva files written
+			// by borges, see GitbaseFormatArgs
+			Format string `yaml:"format,omitempty"`
+			// SivaBucket is the number of characters of the name of the
+			// siva files used to split them in directories, like borges
+			// does. They are all in the workdir if it is 0
+			SivaBucket int `yaml:"siva_bucket,omitempty"`
+		}
+
+		Search struct {
+			// Port is the public exposed port for this component's container
+			Port int
+			// Version is the image version, set for the components upgraded
+			// with srcd components upgrade. The default one is used if empty
+			Version string `yaml:"version,omitempty"`
+			// Env has extra environment variables for the container, which
+			// override the ones set by default
+			Env map[string]string `yaml:"env,omitempty"`
+			// Args are extra command line arguments, appended to the ones of
+			// the container
+			Args []string `yaml:"args,omitempty"`
+		}
+
+		Analytics struct {
+			// Port is the public exposed port for this component's container
+			Port int
+			// Version is the image version, set for the components upgraded
+			// with srcd components upgrade. The default one is used if empty
+			Version string `yaml:"version,omitempty"`
+			// Env has extra environment variables for the container, which
+			// override the ones set by default
+			Env map[string]string `yaml:"env,omitempty"`
+			// Args are extra command line arguments, appended to the ones of
+			// the container
+			Args []string `yaml:"args,omitempty"`
+			// User and Password are the email and password of the Metabase
+			// admin, set when the component is started for the first time,
+			// see AnalyticsCredentials. A password is generated if it is empty
+			User     string `yaml:"user,omitempty"`
+			Password string `yaml:"password,omitempty"`
+		}
+
+		Notebook struct {
+			// Port is the public exposed port for this component's container
+			Port int
+		}
+
+		Spark struct {
+			// Port is the public exposed port of the notebook of srcd spark
+			Port int
+			// UIPort is the public exposed port of the Spark web UI
+			UIPort int `yaml:"ui_port"`
 		}
 
 		Daemon struct {
@@ -36,6 +157,329 @@ type Config struct {
 			Port int
 		}
 	}
+
+	// Performance tunes the gitbase cache, the memory limits of gitbase and
+	// bblfshd and the default of Daemon.MaxQueries for the size of the
+	// docker host: small, medium, large, or auto to pick the one fitted to
+	// its memory. The defaults of the components are kept if it is empty,
+	// see PerformanceProfile
+	Performance string `yaml:"performance,omitempty"`
+
+	// Drivers pins the bblfsh driver of each language, like go, to an image
+	// version, e.g. v2.7.1, or to an image like bblfsh/go-driver:v2.7.1. The
+	// daemon installs, upgrades or downgrades the drivers of bblfshd to
+	// match them, see DriverImage
+	Drivers map[string]string `yaml:"drivers,omitempty"`
+
+	Images struct {
+		// Keep is the number of versions of each component image, counting
+		// the one in use, that the daemon keeps installed. It removes the
+		// older ones when it starts and once a day, see
+		// components.OldImages. No image is removed if it is 0
+		Keep int `yaml:"keep,omitempty"`
+	}
+
+	Daemon struct {
+		// Listen is the host address where the daemon port is published, in
+		// the form ip[:port]. Use 127.0.0.1 to only accept local connections.
+		// If the port is omitted, components.daemon.port is used
+		Listen string
+		// HTTPPort is the public port for the REST/JSON gateway, published on
+		// the same address as Listen. The gateway is disabled if it is 0
+		HTTPPort int `yaml:"http_port"`
+		// MaxQueries is the number of gitbase queries the daemon runs
+		// concurrently. Negative values remove the limit
+		MaxQueries int `yaml:"max_queries"`
+		// QueryQueue is the number of queries waiting for a free slot when
+		// MaxQueries are running; any other query is rejected. Negative values
+		// reject the excess queries without queueing them
+		QueryQueue int `yaml:"query_queue"`
+		// RestartPolicy is the docker restart policy of the daemon and the
+		// component containers, e.g. unless-stopped to start them again
+		// after a reboot. See docker.ParseRestartPolicy
+		RestartPolicy string `yaml:"restart_policy,omitempty"`
+		// QueryCache keeps the results of the read-only queries while the
+		// repositories don't change, see QueryCache
+		QueryCache struct {
+			// Size is the memory used by the cached results, e.g. 256MB.
+			// The cache is disabled if it is empty
+			Size string `yaml:"size,omitempty"`
+			// TTL is the time a result is reused, e.g. 10m. 5m if it is
+			// empty
+			TTL string `yaml:"ttl,omitempty"`
+		} `yaml:"query_cache,omitempty"`
+		// Audit is the log of the orchestration actions run by the daemon,
+		// with the users that requested them, see AuditLog
+		Audit struct {
+			// Disabled stops writing the audit log
+			Disabled bool `yaml:"disabled,omitempty"`
+			// MaxSize is the size of the log file, e.g. 10MB, after which it
+			// is rotated. 10MB if it is empty
+			MaxSize string `yaml:"max_size,omitempty"`
+			// MaxFiles is the number of rotated files kept. 5 if it is 0,
+			// none if it is negative
+			MaxFiles int `yaml:"max_files,omitempty"`
+		} `yaml:"audit,omitempty"`
+		// Auth is the token authentication of the daemon API, for the
+		// servers shared by a team. Anyone can use the API if there are no
+		// users
+		Auth struct {
+			// Users are the users of the API by name, see AuthUser
+			Users map[string]AuthUser `yaml:"users,omitempty"`
+		} `yaml:"auth,omitempty"`
+		// Limits are the quotas of each user of the daemon API, so a single
+		// runaway client can't take over a shared daemon, see UserLimits
+		Limits struct {
+			// QueriesPerMinute is the number of gitbase queries each user
+			// can run in a minute. There is no limit if it is 0
+			QueriesPerMinute int `yaml:"queries_per_minute,omitempty"`
+			// MaxParses is the number of parse requests each user can run
+			// at the same time. There is no limit if it is 0
+			MaxParses int `yaml:"max_parses,omitempty"`
+		} `yaml:"limits,omitempty"`
+	}
+
+	SQL struct {
+		// MaxRows is the LIMIT added to the SELECT queries without one run
+		// with srcd sql when the output is a terminal. Negative values
+		// disable it
+		MaxRows int `yaml:"max_rows"`
+		// Connections are external MySQL servers, like a gitbase cluster,
+		// by name, that srcd sql can query with --connection instead of the
+		// gitbase of the daemon, see SQLConnection
+		Connections map[string]SQLConnection `yaml:"connections,omitempty"`
+	} `yaml:"sql"`
+
+	Network struct {
+		// Name is the docker network the daemon and the components are
+		// connected to. If it is empty, srcd-<profile>-network is used
+		Name string `yaml:"name,omitempty"`
+		// Driver is the driver of the network created by srcd, bridge if it
+		// is empty
+		Driver string `yaml:"driver,omitempty"`
+		// Subnet is the subnet of the network created by srcd in CIDR form,
+		// e.g. 172.30.0.0/16. Docker picks one if it is empty
+		Subnet string `yaml:"subnet,omitempty"`
+		// External connects the containers to the existing network Name,
+		// like the one of a docker compose stack, instead of creating it.
+		// srcd prune does not remove it
+		External bool `yaml:"external,omitempty"`
+	}
+
+	Proxy struct {
+		// Inject passes the proxy settings to the component containers and
+		// the notebook. It is enabled if not set, see InjectsProxy. The
+		// daemon always gets them, to query the docker registries
+		Inject *bool `yaml:"inject,omitempty"`
+		// HTTP, HTTPS and NoProxy override the HTTP_PROXY, HTTPS_PROXY and
+		// NO_PROXY environment variables of the host
+		HTTP    string `yaml:"http_proxy,omitempty"`
+		HTTPS   string `yaml:"https_proxy,omitempty"`
+		NoProxy string `yaml:"no_proxy,omitempty"`
+	}
+
+	Warmup struct {
+		// Queries are run by srcd warmup to fill the gitbase caches. Its
+		// default ones are used if it is empty
+		Queries []string `yaml:"queries,omitempty"`
+		// Languages are the bblfsh drivers parsed by srcd warmup, all the
+		// installed ones if it is empty
+		Languages []string `yaml:"languages,omitempty"`
+	} `yaml:"warmup,omitempty"`
+
+	Rules struct {
+		// File is the rules file of srcd rules run, relative to the directory
+		// of the config file that sets it. .srcd-rules.yml in the working
+		// directory is used if it is empty
+		File string `yaml:"file,omitempty"`
+	} `yaml:"rules,omitempty"`
+
+	Retrieval struct {
+		// Workers is the number of repositories borges downloads at the
+		// same time with srcd retrieve. 8 if it is 0
+		Workers int `yaml:"workers,omitempty"`
+		// GithubToken is the GitHub API token rovers uses to discover the
+		// repositories with srcd retrieve start --discover, see
+		// RetrievalGithubToken
+		GithubToken string `yaml:"github_token,omitempty"`
+	} `yaml:"retrieval,omitempty"`
+
+	Telemetry struct {
+		// Enabled sends anonymous usage metrics of srcd. If it is not set,
+		// the choice made with srcd telemetry enable or disable is used
+		Enabled *bool `yaml:"enabled,omitempty"`
+		// Endpoint is the URL the metrics are sent to, for testing
+		Endpoint string `yaml:"endpoint,omitempty"`
+	}
+
+	Tracing struct {
+		// Endpoint is the OTLP/HTTP endpoint of an OpenTelemetry collector,
+		// like http://collector:4318, the spans of the commands run with
+		// --trace are sent to. The daemon sends its spans to it too, so it
+		// must be reachable from the daemon container. If it is empty, a
+		// local Jaeger is started
+		Endpoint string `yaml:"endpoint,omitempty"`
+	} `yaml:"tracing,omitempty"`
+
+	Security struct {
+		// Signatures requires the images of each registry, like docker.io or
+		// gcr.io, to be signed with cosign before their containers are
+		// started. The images of other registries are not verified
+		Signatures map[string]ImageSignature `yaml:"signatures,omitempty"`
+		// User is the user the component containers run as, in the form
+		// uid[:gid], so the files they write in the host directories are not
+		// owned by root. If it is empty, the user running srcd is used on
+		// Linux, and the user of each image on the other systems, see
+		// SetDefaultUser. Use root to run them as root. bblfshd always runs
+		// as root, as it needs privileges to run the drivers
+		User string `yaml:"user,omitempty"`
+		// GroupAdd are supplementary groups, by name or gid, of the user the
+		// component containers run as
+		GroupAdd []string `yaml:"group_add,omitempty"`
+		// Hardening applies the default security options to the component
+		// containers, except bblfshd, which is privileged: all the Linux
+		// capabilities are dropped and they can't gain new privileges. It
+		// is enabled if not set, see IsHardened
+		Hardening *bool `yaml:"hardening,omitempty"`
+		// ReadOnlyRootFS mounts the root filesystem of the component
+		// containers, except bblfshd, as read only. If it is not set, only
+		// the ones known to work that way are, with Hardening enabled
+		ReadOnlyRootFS *bool `yaml:"read_only_root_fs,omitempty"`
+		// CapDrop are extra Linux capabilities dropped from the component
+		// containers, except bblfshd
+		CapDrop []string `yaml:"cap_drop,omitempty"`
+		// SecurityOpt are extra docker security options of the component
+		// containers, except bblfshd, like apparmor=<profile>
+		SecurityOpt []string `yaml:"security_opt,omitempty"`
+	}
+
+	// Profiles override the values of the rest of the file, by profile name,
+	// when the profile is selected with --config-profile, like a ci one with
+	// other ports and timeouts. Only the values they set are overridden, see
+	// ApplyConfigProfile
+	Profiles map[string]Config `yaml:"profiles,omitempty"`
+}
+
+// Roles of the users of the daemon API, see AuthUser
+const (
+	// RoleAdmin can use the whole API
+	RoleAdmin = "admin"
+	// RoleAnalyst can only run queries and searches, parse files, and list
+	// the drivers and the status of the components
+	RoleAnalyst = "analyst"
+)
+
+// AuthUser is a user of the daemon API in Daemon.Auth.Users
+type AuthUser struct {
+	// Role is admin or analyst
+	Role string
+	// TokenSHA256 is the SHA-256 of the token of the user in hex, so the
+	// token itself is not kept in the config. See srcd auth token
+	TokenSHA256 string `yaml:"token_sha256"`
+	// QueriesPerMinute and MaxParses override the ones of Daemon.Limits
+	// for the user. Daemon.Limits is used if they are 0, and there is no
+	// limit if they are negative
+	QueriesPerMinute int `yaml:"queries_per_minute,omitempty"`
+	MaxParses        int `yaml:"max_parses,omitempty"`
+}
+
+// AuthEnabled returns whether the daemon API requires a token, which is when
+// there are users in Daemon.Auth.Users
+func (c *Config) AuthEnabled() bool {
+	return len(c.Daemon.Auth.Users) > 0
+}
+
+// AuthUser returns the name and role of the user of Daemon.Auth.Users with
+// the given token, and false if there is none
+func (c *Config) AuthUser(token string) (string, string, bool) {
+	sum := sha256.Sum256([]byte(token))
+	hash := []byte(hex.EncodeToString(sum[:]))
+
+	for name, u := range c.Daemon.Auth.Users {
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(u.TokenSHA256))) == 1 {
+			return name, u.Role, true
+		}
+	}
+
+	return "", "", false
+}
+
+// validateAuthUsers checks the roles and token hashes of Daemon.Auth.Users
+func (c *Config) validateAuthUsers() error {
+	for name, u := range c.Daemon.Auth.Users {
+		if u.Role != RoleAdmin && u.Role != RoleAnalyst {
+			return fmt.Errorf("invalid role %q of daemon.auth.users.%s, it must be %s or %s",
+				u.Role, name, RoleAdmin, RoleAnalyst)
+		}
+
+		if b, err := hex.DecodeString(u.TokenSHA256); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid daemon.auth.users.%s.token_sha256, "+
+				"it must be the SHA-256 of the token in hex", name)
+		}
+	}
+
+	return nil
+}
+
+// UserLimits returns the number of queries per minute and of concurrent
+// parse requests allowed to the given user of the daemon API, 0 if there is
+// no limit. The ones of Daemon.Auth.Users override Daemon.Limits
+func (c *Config) UserLimits(user string) (int, int) {
+	queries, parses := c.Daemon.Limits.QueriesPerMinute, c.Daemon.Limits.MaxParses
+	if u, ok := c.Daemon.Auth.Users[user]; ok {
+		if u.QueriesPerMinute != 0 {
+			queries = u.QueriesPerMinute
+		}
+
+		if u.MaxParses != 0 {
+			parses = u.MaxParses
+		}
+	}
+
+	if queries < 0 {
+		queries = 0
+	}
+
+	if parses < 0 {
+		parses = 0
+	}
+
+	return queries, parses
+}
+
+// SQLConnection is an external MySQL server of SQL.Connections
+type SQLConnection struct {
+	// Host and Port are the address of the server. The port is 3306 if it
+	// is 0
+	Host string
+	Port int `yaml:"port,omitempty"`
+	// User and Password are the credentials of the server. The user is root
+	// if it is empty
+	User     string `yaml:"user,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// Database is the database the queries run in, gitbase if it is empty
+	Database string `yaml:"database,omitempty"`
+	// TLS is the TLS mode of the connection: false, the default, true,
+	// skip-verify to not verify the certificate, or preferred to use TLS
+	// only if the server supports it
+	TLS string `yaml:"tls,omitempty"`
+}
+
+// defaultMySQLPort is the port of the SQL connections without one
+const defaultMySQLPort = 3306
+
+// GitbaseShard is a gitbase instance of Components.Gitbase.Shards
+type GitbaseShard struct {
+	// Repositories are the directories of the workdir, relative to it, that
+	// the instance analyzes
+	Repositories []string
+}
+
+// ImageSignature configures the signature verification of a registry
+type ImageSignature struct {
+	// Key is the PEM encoded public key the images are signed with, as
+	// created by cosign generate-key-pair
+	Key string
 }
 
 // SetDefaults fills the default values for any fields that are not set
@@ -56,9 +500,483 @@ func (c *Config) SetDefaults() {
 		c.Components.Gitbase.Port = components.GitbasePort
 	}
 
+	if c.Components.Search.Port == 0 {
+		c.Components.Search.Port = components.SearchPort
+	}
+
+	if c.Components.Analytics.Port == 0 {
+		c.Components.Analytics.Port = components.AnalyticsPort
+	}
+
+	if c.Components.Notebook.Port == 0 {
+		c.Components.Notebook.Port = components.NotebookPort
+	}
+
+	if c.Components.Spark.Port == 0 {
+		c.Components.Spark.Port = components.SparkNotebookPort
+	}
+
+	if c.Components.Spark.UIPort == 0 {
+		c.Components.Spark.UIPort = components.SparkUIPort
+	}
+
 	if c.Components.Daemon.Port == 0 {
 		c.Components.Daemon.Port = components.DaemonPort
 	}
+
+	if c.Daemon.Listen == "" {
+		c.Daemon.Listen = "0.0.0.0"
+	}
+
+	if c.Daemon.MaxQueries == 0 {
+		c.Daemon.MaxQueries = 4
+		// the memory of the host is unknown here, so auto keeps the default
+		if p, ok := performanceProfiles[c.Performance]; ok {
+			c.Daemon.MaxQueries = p.MaxQueries
+		}
+	}
+
+	if c.Daemon.QueryQueue == 0 {
+		c.Daemon.QueryQueue = 16
+	}
+
+	if c.SQL.MaxRows == 0 {
+		c.SQL.MaxRows = 1000
+	}
+
+	if c.Retrieval.Workers == 0 {
+		c.Retrieval.Workers = 8
+	}
+}
+
+// SetDefaultUser sets Security.User, if it is empty, to the user running srcd
+// in hostOS, see docker.HostUser. It is not part of SetDefaults, as the user
+// must be resolved in the host, not by the daemon
+func (c *Config) SetDefaultUser(hostOS string) {
+	if c.Security.User == "" {
+		c.Security.User = docker.HostUser(hostOS)
+	}
+}
+
+// IsHardened returns whether the default security options are applied to the
+// component containers, see Security.Hardening
+func (c *Config) IsHardened() bool {
+	return c.Security.Hardening == nil || *c.Security.Hardening
+}
+
+// DriverImage returns the image of the bblfsh driver of the language pinned in
+// Drivers, and whether it is pinned. The versions are images of the
+// bblfsh/<lang>-driver repository
+func (c *Config) DriverImage(lang string) (string, bool) {
+	pin, ok := c.Drivers[lang]
+	if !ok || pin == "" {
+		return "", false
+	}
+
+	if strings.Contains(pin, "/") || strings.Contains(pin, ":") {
+		return pin, true
+	}
+
+	return fmt.Sprintf("bblfsh/%s-driver:%s", lang, pin), true
+}
+
+// SignatureKeys returns the public keys of the registries that require signed
+// images, by registry
+func (c *Config) SignatureKeys() map[string]string {
+	keys := make(map[string]string, len(c.Security.Signatures))
+	for registry, s := range c.Security.Signatures {
+		keys[registry] = s.Key
+	}
+
+	return keys
+}
+
+// DockerNetwork returns the docker network set in Network, see
+// docker.SetNetwork
+func (c *Config) DockerNetwork() docker.NetworkConfig {
+	return docker.NetworkConfig{
+		Name:     c.Network.Name,
+		Driver:   c.Network.Driver,
+		Subnet:   c.Network.Subnet,
+		External: c.Network.External,
+	}
+}
+
+// DockerProxy returns the proxy settings set in Proxy, see docker.SetProxy
+func (c *Config) DockerProxy() docker.ProxyConfig {
+	return docker.ProxyConfig{
+		HTTP:    c.Proxy.HTTP,
+		HTTPS:   c.Proxy.HTTPS,
+		NoProxy: c.Proxy.NoProxy,
+	}
+}
+
+// InjectsProxy returns whether the proxy settings are passed to the component
+// containers, see Proxy.Inject
+func (c *Config) InjectsProxy() bool {
+	return c.Proxy.Inject == nil || *c.Proxy.Inject
+}
+
+// Validate checks the options that are only used when the containers are
+// created, so the errors are reported before any command runs
+func (c *Config) Validate() error {
+	if _, err := c.RestartPolicy(); err != nil {
+		return err
+	}
+
+	if _, _, err := c.PerformanceProfile(0); err != nil {
+		return err
+	}
+
+	if _, _, err := c.GitbasePublishIP(); err != nil {
+		return err
+	}
+
+	if c.Images.Keep < 0 {
+		return fmt.Errorf("invalid images.keep: %d, it can't be negative", c.Images.Keep)
+	}
+
+	if c.Retrieval.Workers < 0 {
+		return fmt.Errorf("invalid retrieval.workers: %d, it can't be negative", c.Retrieval.Workers)
+	}
+
+	if c.Daemon.Limits.QueriesPerMinute < 0 {
+		return fmt.Errorf("invalid daemon.limits.queries_per_minute: %d, it can't be negative",
+			c.Daemon.Limits.QueriesPerMinute)
+	}
+
+	if c.Daemon.Limits.MaxParses < 0 {
+		return fmt.Errorf("invalid daemon.limits.max_parses: %d, it can't be negative",
+			c.Daemon.Limits.MaxParses)
+	}
+
+	for name := range c.SQL.Connections {
+		if _, err := c.SQLConnection(name); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.GitbaseShards(); err != nil {
+		return err
+	}
+
+	if _, err := c.GitbaseFormatArgs(); err != nil {
+		return err
+	}
+
+	if _, _, err := c.QueryCache(); err != nil {
+		return err
+	}
+
+	if _, _, err := c.AuditLog(); err != nil {
+		return err
+	}
+
+	if err := c.validateAuthUsers(); err != nil {
+		return err
+	}
+
+	if _, err := c.TracingEndpoint(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// defaultGitbaseUser is the user gitbase accepts without a password when no
+// credentials are set
+const defaultGitbaseUser = "root"
+
+// GitbaseCredentials returns the user and password to connect to gitbase.
+// The user is root if it is not set
+func (c *Config) GitbaseCredentials() (string, string) {
+	user := c.Components.Gitbase.User
+	if user == "" {
+		user = defaultGitbaseUser
+	}
+
+	return user, c.Components.Gitbase.Password
+}
+
+// RetrievalGithubToken returns the GitHub API token of rovers, the one of the
+// GITHUB_TOKEN environment variable if Retrieval.GithubToken is not set
+func (c *Config) RetrievalGithubToken() string {
+	if c.Retrieval.GithubToken != "" {
+		return c.Retrieval.GithubToken
+	}
+
+	return os.Getenv("GITHUB_TOKEN")
+}
+
+// SQLConnection returns the connection of SQL.Connections with the given name,
+// with the defaults of the fields that are not set
+func (c *Config) SQLConnection(name string) (SQLConnection, error) {
+	conn, ok := c.SQL.Connections[name]
+	if !ok {
+		return conn, fmt.Errorf("unknown connection %q, add it to sql.connections in the config file", name)
+	}
+
+	if conn.Host == "" {
+		return conn, fmt.Errorf("invalid sql.connections.%s: the host is required", name)
+	}
+
+	if conn.Port < 0 || conn.Port > 65535 {
+		return conn, fmt.Errorf("invalid sql.connections.%s: invalid port %d", name, conn.Port)
+	}
+
+	switch conn.TLS {
+	case "", "false", "true", "skip-verify", "preferred":
+	default:
+		return conn, fmt.Errorf("invalid sql.connections.%s: invalid tls %q, "+
+			"it must be false, true, skip-verify or preferred", name, conn.TLS)
+	}
+
+	if conn.Port == 0 {
+		conn.Port = defaultMySQLPort
+	}
+
+	if conn.User == "" {
+		conn.User = defaultGitbaseUser
+	}
+
+	if conn.Database == "" {
+		conn.Database = "gitbase"
+	}
+
+	return conn, nil
+}
+
+// defaultAnalyticsUser is the email of the Metabase admin when none is set
+const defaultAnalyticsUser = "admin@srcd.local"
+
+// AnalyticsCredentials returns the email and password of the admin of the
+// analytics component. The email is admin@srcd.local if it is not set, and
+// the password is empty if it must be generated
+func (c *Config) AnalyticsCredentials() (string, string) {
+	user := c.Components.Analytics.User
+	if user == "" {
+		user = defaultAnalyticsUser
+	}
+
+	return user, c.Components.Analytics.Password
+}
+
+// formats of the repositories of Components.Gitbase.Format
+const (
+	RepositoryFormatGit  = "git"
+	RepositoryFormatBare = "bare"
+	RepositoryFormatSiva = "siva"
+)
+
+// GitbaseFormatArgs returns the gitbase arguments for the format of the
+// repositories of the workdir, set in Components.Gitbase.Format. There are
+// none for the repositories with a working tree, the gitbase default
+func (c *Config) GitbaseFormatArgs() ([]string, error) {
+	g := c.Components.Gitbase
+	if g.Format != RepositoryFormatSiva && g.SivaBucket != 0 {
+		return nil, fmt.Errorf("invalid components.gitbase.siva_bucket: it can only be set with the %s format",
+			RepositoryFormatSiva)
+	}
+
+	switch g.Format {
+	case "", RepositoryFormatGit:
+		return nil, nil
+	case RepositoryFormatBare:
+		return []string{"--bare"}, nil
+	case RepositoryFormatSiva:
+		if g.SivaBucket < 0 {
+			return nil, fmt.Errorf("invalid components.gitbase.siva_bucket: %d, it can't be negative", g.SivaBucket)
+		}
+
+		if len(g.Shards) > 0 {
+			return nil, fmt.Errorf("invalid components.gitbase.shards: the repositories can't be split "+
+				"in shards with the %s format", RepositoryFormatSiva)
+		}
+
+		return []string{"--format=siva", fmt.Sprintf("--bucket=%d", g.SivaBucket)}, nil
+	default:
+		return nil, fmt.Errorf("invalid components.gitbase.format %q, it must be %s, %s or %s",
+			g.Format, RepositoryFormatGit, RepositoryFormatBare, RepositoryFormatSiva)
+	}
+}
+
+// The values of Components.Gitbase.Publish
+const (
+	PublishInternal  = "internal"
+	PublishLocalhost = "localhost"
+	PublishAll       = "all"
+)
+
+// GitbaseShards returns Components.Gitbase.Shards, checking that every shard
+// has repositories, given by a directory of the workdir, and that no
+// repository is in more than one shard
+func (c *Config) GitbaseShards() ([]GitbaseShard, error) {
+	seen := map[string]int{}
+	for i, shard := range c.Components.Gitbase.Shards {
+		if len(shard.Repositories) == 0 {
+			return nil, fmt.Errorf("invalid components.gitbase.shards[%d]: it has no repositories", i)
+		}
+
+		for _, repo := range shard.Repositories {
+			clean := path.Clean(repo)
+			if repo == "" || path.IsAbs(clean) || clean == "." || clean == ".." ||
+				strings.HasPrefix(clean, "../") {
+				return nil, fmt.Errorf("invalid components.gitbase.shards[%d]: "+
+					"%q is not a directory of the workdir", i, repo)
+			}
+
+			if j, ok := seen[clean]; ok {
+				return nil, fmt.Errorf("invalid components.gitbase.shards[%d]: "+
+					"%s is already in shard %d", i, repo, j)
+			}
+
+			seen[clean] = i
+		}
+	}
+
+	return c.Components.Gitbase.Shards, nil
+}
+
+// GitbasePublishIP returns the host IP where the gitbase port is published,
+// and false if it must not be published, according to
+// Components.Gitbase.Publish
+func (c *Config) GitbasePublishIP() (string, bool, error) {
+	switch c.Components.Gitbase.Publish {
+	case "", PublishLocalhost:
+		return "127.0.0.1", true, nil
+	case PublishAll:
+		return "0.0.0.0", true, nil
+	case PublishInternal:
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("invalid components.gitbase.publish %q, it must be %s, %s or %s",
+			c.Components.Gitbase.Publish, PublishInternal, PublishLocalhost, PublishAll)
+	}
+}
+
+// RestartPolicy returns the docker restart policy set in Daemon.RestartPolicy
+func (c *Config) RestartPolicy() (container.RestartPolicy, error) {
+	policy, err := docker.ParseRestartPolicy(c.Daemon.RestartPolicy)
+	if err != nil {
+		return policy, fmt.Errorf("invalid daemon.restart_policy: %s", err)
+	}
+
+	return policy, nil
+}
+
+// DaemonListenAddr returns the host IP and port where the daemon port must be
+// published, according to Daemon.Listen and Components.Daemon.Port
+func (c *Config) DaemonListenAddr() (string, int, error) {
+	ip, port := c.Daemon.Listen, c.Components.Daemon.Port
+	if h, p, err := net.SplitHostPort(c.Daemon.Listen); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid port in daemon.listen address %q", c.Daemon.Listen)
+		}
+
+		ip, port = h, n
+	}
+
+	if net.ParseIP(ip) == nil {
+		return "", 0, fmt.Errorf("invalid IP in daemon.listen address %q", c.Daemon.Listen)
+	}
+
+	return ip, port, nil
+}
+
+// GitbaseIndexQuota returns Components.Gitbase.IndexQuota in bytes, or 0 if it
+// is not set
+func (c *Config) GitbaseIndexQuota() (int64, error) {
+	if c.Components.Gitbase.IndexQuota == "" {
+		return 0, nil
+	}
+
+	quota, err := units.FromHumanSize(c.Components.Gitbase.IndexQuota)
+	if err != nil || quota <= 0 {
+		return 0, fmt.Errorf("invalid components.gitbase.index_quota %q", c.Components.Gitbase.IndexQuota)
+	}
+
+	return quota, nil
+}
+
+// defaultQueryCacheTTL is the time the cached query results are reused when
+// Daemon.QueryCache.TTL is not set
+const defaultQueryCacheTTL = 5 * time.Minute
+
+// QueryCache returns the memory, in bytes, and the TTL of the cache of the
+// query results set in Daemon.QueryCache. The size is 0 if it is disabled
+func (c *Config) QueryCache() (int64, time.Duration, error) {
+	qc := c.Daemon.QueryCache
+	if qc.Size == "" {
+		return 0, 0, nil
+	}
+
+	size, err := units.FromHumanSize(qc.Size)
+	if err != nil || size <= 0 {
+		return 0, 0, fmt.Errorf("invalid daemon.query_cache.size %q", qc.Size)
+	}
+
+	ttl := defaultQueryCacheTTL
+	if qc.TTL != "" {
+		ttl, err = time.ParseDuration(qc.TTL)
+		if err != nil || ttl <= 0 {
+			return 0, 0, fmt.Errorf("invalid daemon.query_cache.ttl %q, it must be a duration like 10m", qc.TTL)
+		}
+	}
+
+	return size, ttl, nil
+}
+
+// defaultAuditMaxSize and defaultAuditMaxFiles are used when
+// Daemon.Audit.MaxSize and Daemon.Audit.MaxFiles are not set
+const (
+	defaultAuditMaxSize  = 10000000
+	defaultAuditMaxFiles = 5
+)
+
+// AuditLog returns the size, in bytes, after which the audit log is rotated,
+// and the number of rotated files kept, set in Daemon.Audit. The size is 0 if
+// it is disabled
+func (c *Config) AuditLog() (int64, int, error) {
+	a := c.Daemon.Audit
+	if a.Disabled {
+		return 0, 0, nil
+	}
+
+	size := int64(defaultAuditMaxSize)
+	if a.MaxSize != "" {
+		var err error
+		size, err = units.FromHumanSize(a.MaxSize)
+		if err != nil || size <= 0 {
+			return 0, 0, fmt.Errorf("invalid daemon.audit.max_size %q", a.MaxSize)
+		}
+	}
+
+	files := a.MaxFiles
+	switch {
+	case files == 0:
+		files = defaultAuditMaxFiles
+	case files < 0:
+		files = 0
+	}
+
+	return size, files, nil
+}
+
+// TracingEndpoint returns the OTLP/HTTP endpoint the spans are sent to, or
+// an empty string if it is not set and the local Jaeger is used
+func (c *Config) TracingEndpoint() (string, error) {
+	endpoint := c.Tracing.Endpoint
+	if endpoint == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid tracing.endpoint %q, it must be an http or https URL like http://collector:4318", endpoint)
+	}
+
+	return strings.TrimRight(endpoint, "/"), nil
 }
 
 // AsYaml encodes config into yaml string
@@ -69,3 +987,63 @@ func (c *Config) AsYaml() string {
 	}
 	return string(bs)
 }
+
+// componentVersion returns the Version field of the component with the given
+// container name, or nil if it has none
+func (c *Config) componentVersion(name string) *string {
+	switch name {
+	case components.Bblfshd.Name:
+		return &c.Components.Bblfshd.Version
+	case components.BblfshWeb.Name:
+		return &c.Components.BblfshWeb.Version
+	case components.GitbaseWeb.Name:
+		return &c.Components.GitbaseWeb.Version
+	case components.Gitbase.Name:
+		return &c.Components.Gitbase.Version
+	case components.Search.Name:
+		return &c.Components.Search.Version
+	case components.Analytics.Name:
+		return &c.Components.Analytics.Version
+	default:
+		return nil
+	}
+}
+
+// ComponentOverrides returns the extra environment variables and command line
+// arguments set for the component with the given container name
+func (c *Config) ComponentOverrides(name string) (map[string]string, []string) {
+	switch name {
+	case components.Bblfshd.Name:
+		return c.Components.Bblfshd.Env, c.Components.Bblfshd.Args
+	case components.BblfshWeb.Name:
+		return c.Components.BblfshWeb.Env, c.Components.BblfshWeb.Args
+	case components.GitbaseWeb.Name:
+		return c.Components.GitbaseWeb.Env, c.Components.GitbaseWeb.Args
+	case components.Gitbase.Name:
+		return c.Components.Gitbase.Env, c.Components.Gitbase.Args
+	case components.Search.Name:
+		return c.Components.Search.Env, c.Components.Search.Args
+	case components.Analytics.Name:
+		return c.Components.Analytics.Env, c.Components.Analytics.Args
+	default:
+		return nil, nil
+	}
+}
+
+// ComponentVersion returns the image version set for the component with the
+// given container name, or an empty string if the default one must be used
+func (c *Config) ComponentVersion(name string) string {
+	if v := c.componentVersion(name); v != nil {
+		return *v
+	}
+
+	return ""
+}
+
+// SetComponentVersion sets the image version of the component with the given
+// container name. Components without a Version field are ignored
+func (c *Config) SetComponentVersion(name, version string) {
+	if v := c.componentVersion(name); v != nil {
+		*v = version
+	}
+}
@@ -0,0 +1,454 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestDaemonListenAddr(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		listen string
+		ip     string
+		port   int
+	}{
+		{"", "0.0.0.0", 4242},
+		{"127.0.0.1", "127.0.0.1", 4242},
+		{"192.168.1.10:5000", "192.168.1.10", 5000},
+		{"::1", "::1", 4242},
+		{"[::1]:5000", "::1", 5000},
+	}
+
+	for _, c := range cases {
+		var config Config
+		config.Daemon.Listen = c.listen
+		config.SetDefaults()
+
+		ip, port, err := config.DaemonListenAddr()
+		assert.NoError(err, c.listen)
+		assert.Equal(c.ip, ip, c.listen)
+		assert.Equal(c.port, port, c.listen)
+	}
+
+	for _, listen := range []string{"localhost", "0.0.0.0:port", "my-host:4242"} {
+		var config Config
+		config.Daemon.Listen = listen
+		config.SetDefaults()
+
+		_, _, err := config.DaemonListenAddr()
+		assert.Error(err, listen)
+	}
+}
+
+func TestGitbaseIndexQuota(t *testing.T) {
+	assert := assert.New(t)
+
+	var config Config
+	quota, err := config.GitbaseIndexQuota()
+	assert.NoError(err)
+	assert.Equal(int64(0), quota)
+
+	config.Components.Gitbase.IndexQuota = "10GB"
+	quota, err = config.GitbaseIndexQuota()
+	assert.NoError(err)
+	assert.Equal(int64(10*1000*1000*1000), quota)
+
+	for _, q := range []string{"lots", "-1GB", "0"} {
+		config.Components.Gitbase.IndexQuota = q
+		_, err = config.GitbaseIndexQuota()
+		assert.Error(err, q)
+	}
+}
+
+func TestComponentVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	var config Config
+	assert.Equal("", config.ComponentVersion(components.Gitbase.Name))
+
+	config.SetComponentVersion(components.Gitbase.Name, "v0.19.1")
+	assert.Equal("v0.19.1", config.Components.Gitbase.Version)
+	assert.Equal("v0.19.1", config.ComponentVersion(components.Gitbase.Name))
+
+	// the daemon version can't be set
+	config.SetComponentVersion(components.Daemon.Name, "v1.0.0")
+	assert.Equal("", config.ComponentVersion(components.Daemon.Name))
+}
+
+func TestComponentOverrides(t *testing.T) {
+	assert := assert.New(t)
+
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+components:
+  gitbase:
+    env:
+      GITBASE_UNSTABLE_SQUASH: "true"
+  bblfshd:
+    args: [-log-level=debug]
+`), &config)
+	assert.NoError(err)
+
+	env, args := config.ComponentOverrides(components.Gitbase.Name)
+	assert.Equal(map[string]string{"GITBASE_UNSTABLE_SQUASH": "true"}, env)
+	assert.Empty(args)
+
+	env, args = config.ComponentOverrides(components.Bblfshd.Name)
+	assert.Empty(env)
+	assert.Equal([]string{"-log-level=debug"}, args)
+
+	env, args = config.ComponentOverrides(components.Daemon.Name)
+	assert.Empty(env)
+	assert.Empty(args)
+}
+
+func TestSetDefaultUser(t *testing.T) {
+	assert := assert.New(t)
+
+	var config Config
+	config.SetDefaultUser("darwin")
+	assert.Equal("", config.Security.User)
+
+	config.SetDefaultUser("linux")
+	assert.Equal(docker.HostUser("linux"), config.Security.User)
+
+	config.Security.User = "root"
+	config.SetDefaultUser("linux")
+	assert.Equal("root", config.Security.User)
+}
+
+func TestDriverImage(t *testing.T) {
+	assert := assert.New(t)
+
+	var config Config
+	_, ok := config.DriverImage("go")
+	assert.False(ok)
+
+	config.Drivers = map[string]string{
+		"go":     "v2.7.1",
+		"python": "my-registry.io/python-driver:dev",
+		"java":   "",
+	}
+
+	image, ok := config.DriverImage("go")
+	assert.True(ok)
+	assert.Equal("bblfsh/go-driver:v2.7.1", image)
+
+	image, ok = config.DriverImage("python")
+	assert.True(ok)
+	assert.Equal("my-registry.io/python-driver:dev", image)
+
+	_, ok = config.DriverImage("java")
+	assert.False(ok)
+}
+
+func TestValidateImagesKeep(t *testing.T) {
+	assert := assert.New(t)
+
+	var config Config
+	assert.NoError(config.Validate())
+
+	config.Images.Keep = 2
+	assert.NoError(config.Validate())
+
+	config.Images.Keep = -1
+	assert.Error(config.Validate())
+}
+
+func TestGitbaseCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	var config Config
+	user, password := config.GitbaseCredentials()
+	assert.Equal("root", user)
+	assert.Equal("", password)
+
+	config.Components.Gitbase.User = "analyst"
+	config.Components.Gitbase.Password = "secret"
+	user, password = config.GitbaseCredentials()
+	assert.Equal("analyst", user)
+	assert.Equal("secret", password)
+}
+
+func TestGitbasePublishIP(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	cases := map[string]string{"": "127.0.0.1", "localhost": "127.0.0.1", "all": "0.0.0.0"}
+	for publish, expected := range cases {
+		config.Components.Gitbase.Publish = publish
+		ip, ok, err := config.GitbasePublishIP()
+		require.NoError(err, publish)
+		require.True(ok, publish)
+		require.Equal(expected, ip, publish)
+	}
+
+	config.Components.Gitbase.Publish = "internal"
+	_, ok, err := config.GitbasePublishIP()
+	require.NoError(err)
+	require.False(ok)
+
+	config.Components.Gitbase.Publish = "everywhere"
+	_, _, err = config.GitbasePublishIP()
+	require.Error(err)
+	require.Error(config.Validate())
+}
+
+func TestSQLConnection(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	_, err := config.SQLConnection("prod")
+	require.EqualError(err, `unknown connection "prod", add it to sql.connections in the config file`)
+
+	config.SQL.Connections = map[string]SQLConnection{
+		"prod":    {Host: "gitbase.example.com"},
+		"staging": {Host: "10.0.0.2", Port: 3307, User: "analyst", Database: "repos", TLS: "skip-verify"},
+	}
+
+	conn, err := config.SQLConnection("prod")
+	require.NoError(err)
+	require.Equal(SQLConnection{Host: "gitbase.example.com", Port: 3306, User: "root", Database: "gitbase"}, conn)
+
+	conn, err = config.SQLConnection("staging")
+	require.NoError(err)
+	require.Equal(config.SQL.Connections["staging"], conn)
+	require.NoError(config.Validate())
+
+	config.SQL.Connections["broken"] = SQLConnection{Host: "10.0.0.3", TLS: "always"}
+	_, err = config.SQLConnection("broken")
+	require.Error(err)
+	require.Error(config.Validate())
+
+	config.SQL.Connections["broken"] = SQLConnection{Port: 3306}
+	_, err = config.SQLConnection("broken")
+	require.EqualError(err, "invalid sql.connections.broken: the host is required")
+}
+
+func TestGitbaseShards(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	shards, err := config.GitbaseShards()
+	require.NoError(err)
+	require.Empty(shards)
+
+	config.Components.Gitbase.Shards = []GitbaseShard{
+		{Repositories: []string{"engine", "gitbase"}},
+		{Repositories: []string{"orgs/src-d/go-git"}},
+	}
+	shards, err = config.GitbaseShards()
+	require.NoError(err)
+	require.Len(shards, 2)
+	require.NoError(config.Validate())
+
+	cases := []struct {
+		repos    []string
+		expected string
+	}{
+		{nil, "invalid components.gitbase.shards[2]: it has no repositories"},
+		{[]string{"../other"}, `invalid components.gitbase.shards[2]: "../other" is not a directory of the workdir`},
+		{[]string{"/repos"}, `invalid components.gitbase.shards[2]: "/repos" is not a directory of the workdir`},
+		{[]string{"."}, `invalid components.gitbase.shards[2]: "." is not a directory of the workdir`},
+		{[]string{"engine/"}, "invalid components.gitbase.shards[2]: engine/ is already in shard 0"},
+	}
+
+	for _, c := range cases {
+		config.Components.Gitbase.Shards = append(config.Components.Gitbase.Shards[:2],
+			GitbaseShard{Repositories: c.repos})
+
+		_, err := config.GitbaseShards()
+		require.EqualError(err, c.expected, c.expected)
+		require.Error(config.Validate())
+	}
+}
+
+func TestGitbaseFormatArgs(t *testing.T) {
+	require := require.New(t)
+
+	cases := []struct {
+		format   string
+		bucket   int
+		shards   bool
+		expected []string
+		err      string
+	}{
+		{"", 0, false, nil, ""},
+		{RepositoryFormatGit, 0, true, nil, ""},
+		{RepositoryFormatBare, 0, false, []string{"--bare"}, ""},
+		{RepositoryFormatSiva, 0, false, []string{"--format=siva", "--bucket=0"}, ""},
+		{RepositoryFormatSiva, 2, false, []string{"--format=siva", "--bucket=2"}, ""},
+		{"zip", 0, false, nil, `invalid components.gitbase.format "zip", it must be git, bare or siva`},
+		{RepositoryFormatGit, 2, false, nil, "invalid components.gitbase.siva_bucket: it can only be set with the siva format"},
+		{RepositoryFormatSiva, -1, false, nil, "invalid components.gitbase.siva_bucket: -1, it can't be negative"},
+		{RepositoryFormatSiva, 0, true, nil, "invalid components.gitbase.shards: the repositories can't be split in shards with the siva format"},
+	}
+
+	for _, c := range cases {
+		var config Config
+		config.Components.Gitbase.Format = c.format
+		config.Components.Gitbase.SivaBucket = c.bucket
+		if c.shards {
+			config.Components.Gitbase.Shards = []GitbaseShard{{Repositories: []string{"engine"}}}
+		}
+
+		args, err := config.GitbaseFormatArgs()
+		if c.err != "" {
+			require.EqualError(err, c.err, c.format)
+			require.Error(config.Validate())
+			continue
+		}
+
+		require.NoError(err, c.format)
+		require.Equal(c.expected, args, c.format)
+		require.NoError(config.Validate())
+	}
+}
+
+func TestAuthUsers(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	require.False(config.AuthEnabled())
+
+	// the SHA-256 of "secret"
+	hash := "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+	config.Daemon.Auth.Users = map[string]AuthUser{
+		"alice": {Role: RoleAnalyst, TokenSHA256: hash},
+	}
+	require.True(config.AuthEnabled())
+	require.NoError(config.Validate())
+
+	name, role, ok := config.AuthUser("secret")
+	require.True(ok)
+	require.Equal("alice", name)
+	require.Equal(RoleAnalyst, role)
+
+	_, _, ok = config.AuthUser("other")
+	require.False(ok)
+
+	config.Daemon.Auth.Users["bob"] = AuthUser{Role: "root", TokenSHA256: hash}
+	require.EqualError(config.Validate(), `invalid role "root" of daemon.auth.users.bob, it must be admin or analyst`)
+
+	config.Daemon.Auth.Users["bob"] = AuthUser{Role: RoleAdmin, TokenSHA256: "secret"}
+	require.EqualError(config.Validate(), "invalid daemon.auth.users.bob.token_sha256, "+
+		"it must be the SHA-256 of the token in hex")
+}
+
+func TestUserLimits(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	queries, parses := config.UserLimits("alice")
+	require.Zero(queries)
+	require.Zero(parses)
+
+	config.Daemon.Limits.QueriesPerMinute = 60
+	config.Daemon.Limits.MaxParses = 4
+	config.Daemon.Auth.Users = map[string]AuthUser{
+		"alice": {Role: RoleAnalyst, QueriesPerMinute: 10},
+		"bob":   {Role: RoleAdmin, QueriesPerMinute: -1, MaxParses: -1},
+	}
+
+	queries, parses = config.UserLimits("alice")
+	require.Equal(10, queries)
+	require.Equal(4, parses)
+
+	queries, parses = config.UserLimits("bob")
+	require.Zero(queries)
+	require.Zero(parses)
+
+	queries, parses = config.UserLimits("carol")
+	require.Equal(60, queries)
+	require.Equal(4, parses)
+
+	config.Daemon.Auth.Users = nil
+	config.Daemon.Limits.MaxParses = -1
+	require.EqualError(config.Validate(), "invalid daemon.limits.max_parses: -1, it can't be negative")
+}
+
+func TestAuditLog(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	size, files, err := config.AuditLog()
+	require.NoError(err)
+	require.Equal(int64(10000000), size)
+	require.Equal(5, files)
+
+	config.Daemon.Audit.MaxSize = "1MB"
+	config.Daemon.Audit.MaxFiles = -1
+	size, files, err = config.AuditLog()
+	require.NoError(err)
+	require.Equal(int64(1000000), size)
+	require.Zero(files)
+	require.NoError(config.Validate())
+
+	config.Daemon.Audit.MaxSize = "big"
+	_, _, err = config.AuditLog()
+	require.EqualError(err, `invalid daemon.audit.max_size "big"`)
+	require.Error(config.Validate())
+
+	config.Daemon.Audit.Disabled = true
+	size, _, err = config.AuditLog()
+	require.NoError(err)
+	require.Zero(size)
+}
+
+func TestQueryCache(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	size, _, err := config.QueryCache()
+	require.NoError(err)
+	require.Zero(size)
+
+	config.Daemon.QueryCache.Size = "256MB"
+	size, ttl, err := config.QueryCache()
+	require.NoError(err)
+	require.Equal(int64(256000000), size)
+	require.Equal(5*time.Minute, ttl)
+
+	config.Daemon.QueryCache.TTL = "30s"
+	_, ttl, err = config.QueryCache()
+	require.NoError(err)
+	require.Equal(30*time.Second, ttl)
+	require.NoError(config.Validate())
+
+	config.Daemon.QueryCache.TTL = "often"
+	_, _, err = config.QueryCache()
+	require.EqualError(err, `invalid daemon.query_cache.ttl "often", it must be a duration like 10m`)
+	require.Error(config.Validate())
+
+	config.Daemon.QueryCache.TTL = ""
+	config.Daemon.QueryCache.Size = "-1"
+	_, _, err = config.QueryCache()
+	require.EqualError(err, `invalid daemon.query_cache.size "-1"`)
+}
+
+func TestTracingEndpoint(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	endpoint, err := config.TracingEndpoint()
+	require.NoError(err)
+	require.Empty(endpoint)
+
+	config.Tracing.Endpoint = "http://collector:4318/"
+	endpoint, err = config.TracingEndpoint()
+	require.NoError(err)
+	require.Equal("http://collector:4318", endpoint)
+	require.NoError(config.Validate())
+
+	config.Tracing.Endpoint = "collector:4318"
+	_, err = config.TracingEndpoint()
+	require.EqualError(err, `invalid tracing.endpoint "collector:4318", it must be an http or https URL like http://collector:4318`)
+	require.Error(config.Validate())
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigKeys returns the keys of each level of a key of the config file, like
+// components.gitbase.port. The keys of the mappings by name, like
+// sql.connections, may have dots, like the registries of security.signatures.
+func ConfigKeys(key string) ([]string, error) {
+	keys, _, err := resolveConfigKey(key)
+	return keys, err
+}
+
+// resolveConfigKey returns the keys of each level of a key of the config
+// file, and the type of its value
+func resolveConfigKey(key string) ([]string, reflect.Type, error) {
+	if key == "" {
+		return nil, nil, fmt.Errorf("the key is empty")
+	}
+
+	keys, t, ok := resolveKeys(reflect.TypeOf(Config{}), strings.Split(key, "."))
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown key %s, the keys are listed by srcd config schema", key)
+	}
+
+	return keys, t, nil
+}
+
+func resolveKeys(t reflect.Type, parts []string) ([]string, reflect.Type, bool) {
+	if len(parts) == 0 {
+		return nil, t, true
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for _, f := range configFields(t) {
+			if f.key != parts[0] {
+				continue
+			}
+
+			keys, typ, ok := resolveKeys(f.typ, parts[1:])
+			if ok {
+				return append([]string{f.key}, keys...), typ, true
+			}
+		}
+	case reflect.Map:
+		// the shortest name that leaves a valid key wins
+		for n := 1; n <= len(parts); n++ {
+			keys, typ, ok := resolveKeys(t.Elem(), parts[n:])
+			if ok {
+				return append([]string{strings.Join(parts[:n], ".")}, keys...), typ, true
+			}
+		}
+	}
+
+	return nil, nil, false
+}
+
+// SetConfigValue returns the content of a config file with the key, like
+// components.gitbase.port, set to the value. The value is parsed for the type
+// of the key, and the lists and mappings are given in YAML flow style, like
+// [a, b] or {key: value}. The rest of the file is kept as it is, with its
+// comments, and the missing parent keys are added. The result is not
+// validated, see ValidateConfig.
+func SetConfigValue(content []byte, key, value string) ([]byte, error) {
+	keys, t, err := resolveConfigKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	scalar, err := encodeConfigValue(t, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q for %s: %s", value, key, err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+
+	// the line of the key, or of its deepest parent in the file
+	var parent *yamlKeyLine
+	for _, l := range yamlKeyLines(content) {
+		l := l
+		if len(l.keys) <= len(keys) && hasKeys(keys, l.keys) &&
+			(parent == nil || len(l.keys) > len(parent.keys)) {
+			parent = &l
+		}
+	}
+
+	if parent != nil && len(parent.keys) == len(keys) {
+		line := parent.text[:parent.valueCol] + " " + scalar +
+			lineComment(parent.text[parent.valueCol:])
+		end := blockEnd(lines, parent)
+		lines = append(lines[:parent.line], append([]string{line}, lines[end:]...)...)
+		return joinLines(lines), nil
+	}
+
+	var depth, indent, insertAt int
+	if parent == nil {
+		insertAt = len(lines)
+		for insertAt > 0 && strings.TrimSpace(lines[insertAt-1]) == "" {
+			insertAt--
+		}
+	} else {
+		value := parent.text[parent.valueCol:]
+		comment := lineComment(value)
+		switch strings.TrimSpace(strings.TrimSuffix(value, comment)) {
+		case "":
+		case "{}", "null", "~":
+			lines[parent.line] = parent.text[:parent.valueCol] + comment
+		default:
+			return nil, fmt.Errorf("%s has a value in a single line, set the whole value of %s instead",
+				strings.Join(parent.keys, "."), strings.Join(parent.keys, "."))
+		}
+
+		depth = len(parent.keys)
+		indent = childIndent(lines, parent)
+		insertAt = blockEnd(lines, parent)
+	}
+
+	var added []string
+	for i, k := range keys[depth:] {
+		line := strings.Repeat(" ", indent+2*i) + quoteKey(k) + ":"
+		if depth+i == len(keys)-1 {
+			line += " " + scalar
+		}
+
+		added = append(added, line)
+	}
+
+	lines = append(lines[:insertAt], append(added, lines[insertAt:]...)...)
+	return joinLines(lines), nil
+}
+
+// encodeConfigValue returns the value as YAML for the type of a key
+func encodeConfigValue(t reflect.Type, value string) (string, error) {
+	if strings.Contains(value, "\n") {
+		return "", fmt.Errorf("it has several lines")
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return encodeConfigValue(t.Elem(), value)
+	case reflect.String:
+		b, err := yaml.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSuffix(string(b), "\n"), nil
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("it must be an integer")
+		}
+
+		return strconv.FormatInt(n, 10), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("it must be true or false")
+		}
+
+		return strconv.FormatBool(b), nil
+	default:
+		if err := yaml.UnmarshalStrict([]byte(value), reflect.New(t).Interface()); err != nil {
+			return "", fmt.Errorf("it must be YAML in flow style, like [a, b] or {key: value}: %s",
+				yamlError(err).Message)
+		}
+
+		return value, nil
+	}
+}
+
+// hasKeys returns whether keys starts with prefix
+func hasKeys(keys, prefix []string) bool {
+	for i, k := range prefix {
+		if keys[i] != k {
+			return false
+		}
+	}
+
+	return true
+}
+
+// blockEnd returns the index of the line after the last line of the value of
+// the key, which is the key line itself if its value is in a single line
+func blockEnd(lines []string, l *yamlKeyLine) int {
+	last := l.line
+	for i := l.line + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+		// the items of a list may have the indentation of its key
+		if indent > l.indent || (indent == l.indent && strings.HasPrefix(trimmed, "- ")) {
+			last = i
+			continue
+		}
+
+		break
+	}
+
+	return last + 1
+}
+
+// childIndent returns the indentation of the keys of the value of the key,
+// the one of its first child, or 2 more spaces than the key
+func childIndent(lines []string, l *yamlKeyLine) int {
+	if end := blockEnd(lines, l); end > l.line+1 {
+		for _, line := range lines[l.line+1 : end] {
+			trimmed := strings.TrimSpace(line)
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				return len(line) - len(strings.TrimLeft(line, " "))
+			}
+		}
+	}
+
+	return l.indent + 2
+}
+
+// lineComment returns the comment at the end of a value, with the spaces
+// before it, or an empty string if it has none
+func lineComment(value string) string {
+	var quote rune
+	for i, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t'):
+			start := i
+			for start > 0 && (value[start-1] == ' ' || value[start-1] == '\t') {
+				start--
+			}
+
+			return value[start:]
+		}
+	}
+
+	return ""
+}
+
+// quoteKey returns the key as YAML, quoted if it needs it
+func quoteKey(key string) string {
+	b, err := yaml.Marshal(key)
+	if err != nil {
+		return key
+	}
+
+	return strings.TrimSuffix(string(b), "\n")
+}
+
+// joinLines joins the lines of a file, which ends with a new line
+func joinLines(lines []string) []byte {
+	content := strings.Join(lines, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	return []byte(content)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigKeys(t *testing.T) {
+	require := require.New(t)
+
+	keys, err := ConfigKeys("components.gitbase.port")
+	require.NoError(err)
+	require.Equal([]string{"components", "gitbase", "port"}, keys)
+
+	keys, err = ConfigKeys("security.signatures.docker.io.key")
+	require.NoError(err)
+	require.Equal([]string{"security", "signatures", "docker.io", "key"}, keys)
+
+	keys, err = ConfigKeys("drivers.go")
+	require.NoError(err)
+	require.Equal([]string{"drivers", "go"}, keys)
+
+	_, err = ConfigKeys("components.gitbase.prot")
+	require.EqualError(err, "unknown key components.gitbase.prot, the keys are listed by srcd config schema")
+
+	_, err = ConfigKeys("")
+	require.Error(err)
+}
+
+func TestSetConfigValue(t *testing.T) {
+	content := []byte(`# srcd config
+components:
+  gitbase:
+    port: 3306 # the default one
+    args:
+      - -v
+  bblfshd:
+    port: 9432
+
+images: {}
+
+# the drivers
+drivers:
+    go: v2.7.1
+`)
+
+	cases := []struct {
+		key      string
+		value    string
+		expected string
+	}{
+		{"components.gitbase.port", "3307", `# srcd config
+components:
+  gitbase:
+    port: 3307 # the default one
+    args:
+      - -v
+  bblfshd:
+    port: 9432
+
+images: {}
+
+# the drivers
+drivers:
+    go: v2.7.1
+`},
+		{"components.gitbase.args", "[-v, --trace]", `# srcd config
+components:
+  gitbase:
+    port: 3306 # the default one
+    args: [-v, --trace]
+  bblfshd:
+    port: 9432
+
+images: {}
+
+# the drivers
+drivers:
+    go: v2.7.1
+`},
+		{"components.bblfshd.version", "v2.14.0", `# srcd config
+components:
+  gitbase:
+    port: 3306 # the default one
+    args:
+      - -v
+  bblfshd:
+    port: 9432
+    version: v2.14.0
+
+images: {}
+
+# the drivers
+drivers:
+    go: v2.7.1
+`},
+		{"drivers.python", "v2.9.0", `# srcd config
+components:
+  gitbase:
+    port: 3306 # the default one
+    args:
+      - -v
+  bblfshd:
+    port: 9432
+
+images: {}
+
+# the drivers
+drivers:
+    go: v2.7.1
+    python: v2.9.0
+`},
+		{"images.keep", "2", `# srcd config
+components:
+  gitbase:
+    port: 3306 # the default one
+    args:
+      - -v
+  bblfshd:
+    port: 9432
+
+images:
+  keep: 2
+
+# the drivers
+drivers:
+    go: v2.7.1
+`},
+		{"security.signatures.docker.io.key", "123", `# srcd config
+components:
+  gitbase:
+    port: 3306 # the default one
+    args:
+      - -v
+  bblfshd:
+    port: 9432
+
+images: {}
+
+# the drivers
+drivers:
+    go: v2.7.1
+security:
+  signatures:
+    docker.io:
+      key: "123"
+`},
+	}
+
+	for _, c := range cases {
+		result, err := SetConfigValue(content, c.key, c.value)
+		require.NoError(t, err, c.key)
+		require.Equal(t, c.expected, string(result), c.key)
+		require.Empty(t, ValidateConfig(result), c.key)
+	}
+
+	result, err := SetConfigValue(nil, "daemon.max_queries", "8")
+	require.NoError(t, err)
+	require.Equal(t, "daemon:\n  max_queries: 8\n", string(result))
+
+	_, err = SetConfigValue(content, "components.gitbase.port", "high")
+	require.EqualError(t, err, `invalid value "high" for components.gitbase.port: it must be an integer`)
+
+	_, err = SetConfigValue(content, "security.hardening", "maybe")
+	require.EqualError(t, err, `invalid value "maybe" for security.hardening: it must be true or false`)
+
+	_, err = SetConfigValue([]byte("components: {gitbase: {port: 3306}}\n"), "components.gitbase.user", "root")
+	require.EqualError(t, err, "components has a value in a single line, set the whole value of components instead")
+}
+
+func TestLineComment(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("", lineComment(" 3306"))
+	require.Equal(" # port", lineComment(" 3306 # port"))
+	require.Equal("", lineComment(` "a # b"`))
+	require.Equal("", lineComment(" a#b"))
+}
@@ -0,0 +1,36 @@
+package api
+
+import "regexp"
+
+// Kinds of the jobs run by the daemon, see StartJobRequest
+const (
+	// JobStartComponent starts the component Name, pulling its images and
+	// the ones of its dependencies if they are not installed
+	JobStartComponent = "start-component"
+	// JobRestartComponent restarts the component Name
+	JobRestartComponent = "restart-component"
+	// JobInstallDriver installs the bblfsh driver of the language Name
+	JobInstallDriver = "install-driver"
+	// JobCreateIndex runs the CREATE INDEX statement Query in gitbase
+	JobCreateIndex = "create-index"
+	// JobFetchDataset downloads the public dataset Name to the datasets
+	// directory of the daemon, with the options Langs, Size, Workers,
+	// Streams, Retries and Verify
+	JobFetchDataset = "fetch-dataset"
+	// JobPullImage pulls the image Name, in the form image:version
+	JobPullImage = "pull-image"
+)
+
+// Done returns whether the job has finished, successfully or not
+func (m *Job) Done() bool {
+	return m.GetState() != Job_RUNNING
+}
+
+// createIndexQuery matches the statements run by the create-index jobs
+var createIndexQuery = regexp.MustCompile(`(?is)^\s*CREATE\s+INDEX\s`)
+
+// IsCreateIndex returns whether the query is a CREATE INDEX statement, which
+// can be run as a create-index job
+func IsCreateIndex(query string) bool {
+	return createIndexQuery.MatchString(query)
+}
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsCreateIndex(t *testing.T) {
+	require := require.New(t)
+
+	require.True(IsCreateIndex("CREATE INDEX i ON refs USING pilosa (ref_name)"))
+	require.True(IsCreateIndex("  create\n index i ON refs USING pilosa (ref_name)"))
+	require.False(IsCreateIndex("SELECT 'CREATE INDEX i'"))
+	require.False(IsCreateIndex("CREATE TABLE t (i int)"))
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+
+	units "github.com/docker/go-units"
+)
+
+// performance profiles of the Performance config option
+const (
+	PerformanceSmall  = "small"
+	PerformanceMedium = "medium"
+	PerformanceLarge  = "large"
+	// PerformanceAuto picks the profile fitted to the memory of the docker
+	// host, see PerformanceProfile
+	PerformanceAuto = "auto"
+)
+
+// PerformanceProfile has the settings of the components for a Performance
+// profile
+type PerformanceProfile struct {
+	Name string
+	// GitbaseCacheMB is the size of the object cache of gitbase, in
+	// megabytes
+	GitbaseCacheMB int
+	// GitbaseUASTCache is the number of UASTs cached by gitbase
+	GitbaseUASTCache int
+	// MaxQueries is the default of Daemon.MaxQueries
+	MaxQueries int
+	// GitbaseMemory and BblfshdMemory are the fractions of the memory of the
+	// docker host the gitbase and bblfshd containers can use
+	GitbaseMemory float64
+	BblfshdMemory float64
+}
+
+var performanceProfiles = map[string]PerformanceProfile{
+	PerformanceSmall: {
+		Name:             PerformanceSmall,
+		GitbaseCacheMB:   256,
+		GitbaseUASTCache: 1000,
+		MaxQueries:       2,
+		GitbaseMemory:    0.5,
+		BblfshdMemory:    0.25,
+	},
+	PerformanceMedium: {
+		Name:             PerformanceMedium,
+		GitbaseCacheMB:   1024,
+		GitbaseUASTCache: 10000,
+		MaxQueries:       4,
+		GitbaseMemory:    0.5,
+		BblfshdMemory:    0.25,
+	},
+	PerformanceLarge: {
+		Name:             PerformanceLarge,
+		GitbaseCacheMB:   8192,
+		GitbaseUASTCache: 50000,
+		MaxQueries:       8,
+		GitbaseMemory:    0.6,
+		BblfshdMemory:    0.2,
+	},
+}
+
+// the auto profile is small below mediumMemory and large from largeMemory
+const (
+	mediumMemory = 12 * units.GiB
+	largeMemory  = 48 * units.GiB
+)
+
+// PerformanceProfile returns the profile set in Performance. The auto one is
+// resolved with the given memory of the docker host in bytes. It returns
+// false if there is no profile, so the defaults of the components are kept
+func (c *Config) PerformanceProfile(hostMemory int64) (PerformanceProfile, bool, error) {
+	name := c.Performance
+	switch name {
+	case "":
+		return PerformanceProfile{}, false, nil
+	case PerformanceAuto:
+		switch {
+		case hostMemory < mediumMemory:
+			name = PerformanceSmall
+		case hostMemory < largeMemory:
+			name = PerformanceMedium
+		default:
+			name = PerformanceLarge
+		}
+	}
+
+	p, ok := performanceProfiles[name]
+	if !ok {
+		return PerformanceProfile{}, false, fmt.Errorf(
+			"invalid performance %q, it must be one of %s, %s, %s or %s",
+			c.Performance, PerformanceSmall, PerformanceMedium, PerformanceLarge, PerformanceAuto)
+	}
+
+	return p, true, nil
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"testing"
+
+	units "github.com/docker/go-units"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerformanceProfile(t *testing.T) {
+	require := require.New(t)
+
+	var config Config
+	_, ok, err := config.PerformanceProfile(8 * units.GiB)
+	require.NoError(err)
+	require.False(ok)
+
+	config.Performance = "large"
+	p, ok, err := config.PerformanceProfile(8 * units.GiB)
+	require.NoError(err)
+	require.True(ok)
+	require.Equal(PerformanceLarge, p.Name)
+
+	config.Performance = "auto"
+	for memory, expected := range map[int64]string{
+		0:               PerformanceSmall,
+		8 * units.GiB:   PerformanceSmall,
+		16 * units.GiB:  PerformanceMedium,
+		128 * units.GiB: PerformanceLarge,
+	} {
+		p, ok, err := config.PerformanceProfile(memory)
+		require.NoError(err)
+		require.True(ok)
+		require.Equal(expected, p.Name, memory)
+	}
+
+	config.Performance = "huge"
+	_, _, err = config.PerformanceProfile(8 * units.GiB)
+	require.Error(err)
+	require.Error(config.Validate())
+}
+
+func TestPerformanceMaxQueries(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{Performance: PerformanceSmall}
+	config.SetDefaults()
+	require.Equal(2, config.Daemon.MaxQueries)
+
+	config = Config{Performance: PerformanceAuto}
+	config.SetDefaults()
+	require.Equal(4, config.Daemon.MaxQueries)
+
+	config = Config{Performance: PerformanceLarge}
+	config.Daemon.MaxQueries = 3
+	config.SetDefaults()
+	require.Equal(3, config.Daemon.MaxQueries)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+)
+
+// ApplyConfigProfile sets the values of the profile with the given name, in
+// the profiles key of the values decoded from a config file, over the rest
+// of them, see MergeConfigValues. The profiles key is removed, so the result
+// is the config of the profile. Nothing is overridden if the name is empty,
+// and it returns an error if the file has no profile with that name.
+func ApplyConfigProfile(values map[interface{}]interface{}, name string) error {
+	raw := values["profiles"]
+	delete(values, "profiles")
+	if name == "" {
+		return nil
+	}
+
+	profiles, ok := raw.(map[interface{}]interface{})
+	if raw != nil && !ok {
+		return fmt.Errorf("profiles must be a mapping")
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown config profile %q, it is not set in profiles", name)
+	}
+
+	if profile == nil {
+		return nil
+	}
+
+	m, ok := profile.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("profiles.%s must be a mapping", name)
+	}
+
+	if _, ok := m["profiles"]; ok {
+		return fmt.Errorf("profiles.%s can't have profiles", name)
+	}
+
+	MergeConfigValues(values, m)
+	return nil
+}
+
+// MergeConfigValues sets the values decoded from a config file in src over
+// the ones in dst, merging the mappings of both. The rest of the values of
+// src, including the lists, replace the ones of dst.
+func MergeConfigValues(dst, src map[interface{}]interface{}) {
+	for k, v := range src {
+		srcMap, srcOK := v.(map[interface{}]interface{})
+		dstMap, dstOK := dst[k].(map[interface{}]interface{})
+		if srcOK && dstOK {
+			MergeConfigValues(dstMap, srcMap)
+			continue
+		}
+
+		dst[k] = v
+	}
+}
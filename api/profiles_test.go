@@ -0,0 +1,70 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestApplyConfigProfile(t *testing.T) {
+	require := require.New(t)
+
+	content := []byte(`
+components:
+  gitbase:
+    port: 3307
+    user: reader
+drivers:
+  go: v2.7.1
+warmup:
+  queries: [SELECT 1, SELECT 2]
+profiles:
+  ci:
+    components:
+      gitbase:
+        port: 3308
+    drivers:
+      python: v2.9.0
+    warmup:
+      queries: [SELECT 3]
+`)
+
+	load := func(name string) Config {
+		values := make(map[interface{}]interface{})
+		require.NoError(yaml.Unmarshal(content, &values))
+		require.NoError(ApplyConfigProfile(values, name))
+
+		b, err := yaml.Marshal(values)
+		require.NoError(err)
+
+		var c Config
+		require.NoError(yaml.UnmarshalStrict(b, &c))
+		return c
+	}
+
+	c := load("ci")
+	require.Equal(3308, c.Components.Gitbase.Port)
+	require.Equal("reader", c.Components.Gitbase.User)
+	require.Equal(map[string]string{"go": "v2.7.1", "python": "v2.9.0"}, c.Drivers)
+	require.Equal([]string{"SELECT 3"}, c.Warmup.Queries)
+	require.Empty(c.Profiles)
+
+	c = load("")
+	require.Equal(3307, c.Components.Gitbase.Port)
+	require.Equal(map[string]string{"go": "v2.7.1"}, c.Drivers)
+	require.Empty(c.Profiles)
+
+	values := make(map[interface{}]interface{})
+	require.NoError(yaml.Unmarshal(content, &values))
+	err := ApplyConfigProfile(values, "other")
+	require.EqualError(err, `unknown config profile "other", it is not set in profiles`)
+
+	err = ApplyConfigProfile(map[interface{}]interface{}{}, "ci")
+	require.EqualError(err, `unknown config profile "ci", it is not set in profiles`)
+
+	err = ApplyConfigProfile(map[interface{}]interface{}{
+		"profiles": map[interface{}]interface{}{"ci": "fast"},
+	}, "ci")
+	require.EqualError(err, "profiles.ci must be a mapping")
+}
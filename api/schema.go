@@ -0,0 +1,479 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigError is an error of a config file found by ValidateConfig
+type ConfigError struct {
+	// Line is the line of the key with the error, or 0 if it is not known
+	Line int
+	// Key is the path of the key with the error, like
+	// components.gitbase.port, or empty if the error is not about a key
+	Key     string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	var prefix string
+	if e.Line > 0 {
+		prefix = fmt.Sprintf("line %d: ", e.Line)
+	}
+
+	// the messages of Config.Validate already have the key
+	if e.Key != "" && !strings.Contains(e.Message, e.Key) {
+		prefix += e.Key + ": "
+	}
+
+	return prefix + e.Message
+}
+
+// ConfigSchema returns the JSON schema of the config file, generated from the
+// fields of Config
+func ConfigSchema() map[string]interface{} {
+	s := typeSchema(reflect.TypeOf(Config{}))
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "srcd config file"
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		for _, f := range configFields(t) {
+			props[f.key] = typeSchema(f.typ)
+		}
+
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		items := map[string]interface{}{"$ref": "#"}
+		// the profiles are a Config too, which refers to the whole schema
+		if t.Elem() != reflect.TypeOf(Config{}) {
+			items = typeSchema(t.Elem())
+		}
+
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": items,
+		}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// configField is a field of a struct of Config, by its key in the file
+type configField struct {
+	key string
+	typ reflect.Type
+}
+
+// configFields returns the fields of a struct of Config with their keys, as
+// decoded by yaml: the name of the yaml tag, or the lower case field name
+func configFields(t reflect.Type) []configField {
+	var fields []configField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		key := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if key == "-" {
+			continue
+		}
+
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+
+		fields = append(fields, configField{key: key, typ: f.Type})
+	}
+
+	return fields
+}
+
+// ValidateConfig validates the content of a config file, returning all the
+// errors found, with the line of their key when it is known. It checks the
+// YAML syntax, the keys and the types of the values against ConfigSchema,
+// and the values with Config.Validate, with each of its profiles applied
+// too.
+func ValidateConfig(content []byte) []ConfigError {
+	var raw interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return []ConfigError{yamlError(err)}
+	}
+
+	v := &configValidator{content: content}
+	v.validate(reflect.TypeOf(Config{}), raw, "")
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	// the schema should catch all the errors of the strict decoding
+	var c Config
+	if err := yaml.UnmarshalStrict(content, &c); err != nil {
+		return []ConfigError{yamlError(err)}
+	}
+
+	if err := c.Validate(); err != nil {
+		key := configKeyRegexp.FindString(err.Error())
+		return []ConfigError{{
+			Line:    findKeyLine(content, key),
+			Key:     key,
+			Message: err.Error(),
+		}}
+	}
+
+	var errs []ConfigError
+	for _, name := range sortedProfileNames(c.Profiles) {
+		if err := validateProfile(content, name); err != nil {
+			key := "profiles." + name
+			errs = append(errs, ConfigError{
+				Line:    findKeyLine(content, key),
+				Key:     key,
+				Message: fmt.Sprintf("with the profile %s applied: %s", name, err),
+			})
+		}
+	}
+
+	return errs
+}
+
+// validateProfile validates the values of a config file with the profile
+// applied, for the errors of the values that are only wrong together, like
+// the same port for two components
+func validateProfile(content []byte, name string) error {
+	values := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return err
+	}
+
+	if err := ApplyConfigProfile(values, name); err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	var c Config
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return yamlError(err)
+	}
+
+	return c.Validate()
+}
+
+func sortedProfileNames(profiles map[string]Config) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// configKeyRegexp matches the keys in the messages of Config.Validate, like
+// components.gitbase.shards[0]
+var configKeyRegexp = regexp.MustCompile(`\b[a-z_]+(\.[a-zA-Z0-9_-]+|\[\d+\])+`)
+
+// yamlLineRegexp matches the line of the errors of the yaml package
+var yamlLineRegexp = regexp.MustCompile(`line (\d+): `)
+
+// yamlError returns the error of the yaml package as a ConfigError, with
+// its line
+func yamlError(err error) ConfigError {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	if e, ok := err.(*yaml.TypeError); ok {
+		msg = strings.Join(e.Errors, ", ")
+	}
+
+	var line int
+	if m := yamlLineRegexp.FindStringSubmatch(msg); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		msg = strings.Replace(msg, m[0], "", 1)
+	}
+
+	return ConfigError{Line: line, Message: msg}
+}
+
+// configValidator checks the values decoded from a config file against the
+// types of Config
+type configValidator struct {
+	content []byte
+	errors  []ConfigError
+}
+
+func (v *configValidator) errorf(key string, format string, args ...interface{}) {
+	v.errors = append(v.errors, ConfigError{
+		Line:    findKeyLine(v.content, key),
+		Key:     key,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *configValidator) validate(t reflect.Type, value interface{}, key string) {
+	// a null value keeps the default one
+	if value == nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		v.validate(t.Elem(), value, key)
+	case reflect.Struct:
+		m, ok := value.(map[interface{}]interface{})
+		if !ok {
+			v.errorf(key, "must be a mapping, not %s", describeValue(value))
+			return
+		}
+
+		fields := make(map[string]configField)
+		var known []string
+		for _, f := range configFields(t) {
+			fields[f.key] = f
+			known = append(known, f.key)
+		}
+
+		for _, mk := range sortedKeys(m) {
+			k := fmt.Sprint(mk)
+			f, ok := fields[k]
+			if ok && k == "profiles" && key != "" {
+				v.errorf(joinKey(key, k), "profiles can only be set at the top level")
+				continue
+			}
+
+			if !ok {
+				msg := "unknown key"
+				if s := suggestKey(k, known); s != "" {
+					msg += fmt.Sprintf(", did you mean %s?", s)
+				} else {
+					msg += fmt.Sprintf(", the valid ones are: %s", strings.Join(known, ", "))
+				}
+
+				v.errorf(joinKey(key, k), msg)
+				continue
+			}
+
+			v.validate(f.typ, m[mk], joinKey(key, k))
+		}
+	case reflect.Map:
+		m, ok := value.(map[interface{}]interface{})
+		if !ok {
+			v.errorf(key, "must be a mapping, not %s", describeValue(value))
+			return
+		}
+
+		for _, k := range sortedKeys(m) {
+			v.validate(t.Elem(), m[k], joinKey(key, fmt.Sprint(k)))
+		}
+	case reflect.Slice:
+		s, ok := value.([]interface{})
+		if !ok {
+			v.errorf(key, "must be a list, not %s", describeValue(value))
+			return
+		}
+
+		for i, e := range s {
+			v.validate(t.Elem(), e, fmt.Sprintf("%s[%d]", key, i))
+		}
+	case reflect.String:
+		switch value.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			v.errorf(key, "must be a string, not %s", describeValue(value))
+		}
+	case reflect.Int, reflect.Int64:
+		switch value.(type) {
+		case int, int64, uint64:
+		default:
+			v.errorf(key, "must be an integer, not %s", describeValue(value))
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			v.errorf(key, "must be true or false, not %s", describeValue(value))
+		}
+	}
+}
+
+// describeValue returns the kind of a decoded YAML value, with the value
+// itself for the scalars
+func describeValue(value interface{}) string {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		return "a mapping"
+	case []interface{}:
+		return "a list"
+	case string:
+		return fmt.Sprintf("the string %q", value)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// sortedKeys returns the keys of a decoded YAML mapping, sorted as strings
+func sortedKeys(m map[interface{}]interface{}) []interface{} {
+	keys := make([]interface{}, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+
+	return parent + "." + key
+}
+
+// suggestKey returns the known key closest to an unknown one, if it is only
+// a few edits away, to point out typos
+func suggestKey(key string, known []string) string {
+	var best string
+	bestDistance := 3
+	for _, k := range known {
+		if d := editDistance(key, k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
+
+// yamlKeyRegexp matches the lines with a key of a block mapping, with the
+// dashes of the list items before it
+var yamlKeyRegexp = regexp.MustCompile(`^(\s*)((?:-\s+)*)("[^"]*"|'[^']*'|[^\s#'"][^:#]*?)\s*:(\s|$)`)
+
+// indexRegexp matches the list indexes of a key, like [0]
+var indexRegexp = regexp.MustCompile(`\[\d+\]`)
+
+// yamlKeyLine is a line of a YAML document with a key of a block mapping
+type yamlKeyLine struct {
+	// line is the index of the line, from 0
+	line   int
+	indent int
+	// keys are the keys of the parent mappings and the one of the line
+	keys []string
+	// text is the line, and valueCol the index where its value starts, after
+	// the colon
+	text     string
+	valueCol int
+}
+
+// yamlKeyLines returns the lines of content with a key of a block mapping,
+// following their indentation to find the keys of their parents. The keys
+// of the list items are children of the list key.
+func yamlKeyLines(content []byte) []yamlKeyLine {
+	var lines []yamlKeyLine
+	var stack []yamlKeyLine
+	for i, line := range strings.Split(string(content), "\n") {
+		loc := yamlKeyRegexp.FindStringSubmatchIndex(line)
+		if loc == nil {
+			continue
+		}
+
+		indent := (loc[3] - loc[2]) + (loc[5] - loc[4])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		var keys []string
+		if len(stack) > 0 {
+			keys = append(keys, stack[len(stack)-1].keys...)
+		}
+
+		keys = append(keys, strings.Trim(line[loc[6]:loc[7]], `"'`))
+		l := yamlKeyLine{line: i, indent: indent, keys: keys, text: line, valueCol: loc[8]}
+		stack = append(stack, l)
+		lines = append(lines, l)
+	}
+
+	return lines
+}
+
+// findKeyLine returns the line of the key in the content of a config file,
+// following the indentation of the block mappings, or 0 if it is not found.
+// The list indexes of the key are ignored, so the line of the key in the
+// first item with it is returned.
+func findKeyLine(content []byte, key string) int {
+	key = indexRegexp.ReplaceAllString(key, "")
+	if key == "" {
+		return 0
+	}
+
+	for _, l := range yamlKeyLines(content) {
+		if strings.Join(l.keys, ".") == key {
+			return l.line + 1
+		}
+	}
+
+	return 0
+}
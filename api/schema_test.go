@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	require := require.New(t)
+
+	require.Empty(ValidateConfig(nil))
+	require.Empty(ValidateConfig([]byte(`
+components:
+  gitbase:
+    port: 3307
+    shards:
+      - repositories: [a, b]
+sql:
+  connections:
+    prod:
+      host: mysql.local
+security:
+  hardening: false
+`)))
+
+	errs := ValidateConfig([]byte(`
+components:
+  gitbase:
+    prot: 3307
+  bblfshd:
+    port: high
+    args: -log-level=debug
+daemon:
+  query_cache:
+    size: 1GB
+    tll: 5m
+security:
+  hardening: "no"
+  signatures:
+    docker.io:
+      key: [a]
+`))
+	require.Equal([]ConfigError{
+		{Line: 7, Key: "components.bblfshd.args", Message: `must be a list, not the string "-log-level=debug"`},
+		{Line: 6, Key: "components.bblfshd.port", Message: `must be an integer, not the string "high"`},
+		{Line: 4, Key: "components.gitbase.prot", Message: "unknown key, did you mean port?"},
+		{Line: 11, Key: "daemon.query_cache.tll", Message: "unknown key, did you mean ttl?"},
+		{Line: 13, Key: "security.hardening", Message: `must be true or false, not the string "no"`},
+		{Line: 16, Key: "security.signatures.docker.io.key", Message: "must be a string, not a list"},
+	}, errs)
+
+	errs = ValidateConfig([]byte("components:\n  gitbase:\n  port: 3307\n    user: root\n"))
+	require.Len(errs, 1)
+	require.Equal(4, errs[0].Line)
+	require.Equal("", errs[0].Key)
+	require.Equal("line 4: mapping values are not allowed in this context", errs[0].Error())
+
+	errs = ValidateConfig([]byte(`
+components:
+  gitbase:
+    shards:
+      - repositories: [a]
+      - repositories: []
+`))
+	require.Equal([]ConfigError{{
+		Line:    4,
+		Key:     "components.gitbase.shards[1]",
+		Message: "invalid components.gitbase.shards[1]: it has no repositories",
+	}}, errs)
+
+	errs = ValidateConfig([]byte("images:\n  keep: -1\n"))
+	require.Equal("line 2: invalid images.keep: -1, it can't be negative", errs[0].Error())
+}
+
+func TestFindKeyLine(t *testing.T) {
+	content := []byte(`# comment
+components:
+  "gitbase":
+    port: 3307 # the port: of gitbase
+  bblfshd:
+    port: 9432
+security:
+  signatures:
+    docker.io:
+      key: |
+        -----BEGIN PUBLIC KEY-----
+`)
+
+	cases := map[string]int{
+		"components":                        2,
+		"components.gitbase.port":           4,
+		"components.bblfshd.port":           6,
+		"security.signatures.docker.io.key": 10,
+		"components.search":                 0,
+		"":                                  0,
+	}
+
+	for key, line := range cases {
+		require.Equal(t, line, findKeyLine(content, key), key)
+	}
+}
+
+// TestConfigSchemaFile checks that the published schema of the config file
+// is up to date, it is written with srcd config schema
+func TestConfigSchemaFile(t *testing.T) {
+	require := require.New(t)
+
+	content, err := ioutil.ReadFile(filepath.Join("..", "docs", "config.schema.json"))
+	require.NoError(err)
+
+	expected, err := json.Marshal(ConfigSchema())
+	require.NoError(err)
+	require.JSONEq(string(expected), string(content),
+		"docs/config.schema.json is outdated, run srcd config schema > docs/config.schema.json")
+}
+
+func TestValidateConfigProfiles(t *testing.T) {
+	require := require.New(t)
+
+	require.Empty(ValidateConfig([]byte(`
+components:
+  gitbase:
+    port: 3307
+profiles:
+  ci:
+    components:
+      gitbase:
+        port: 3308
+    daemon:
+      max_queries: 2
+`)))
+
+	errs := ValidateConfig([]byte(`
+profiles:
+  ci:
+    components:
+      gitbase:
+        prot: 3308
+    profiles:
+      other: {}
+`))
+	require.Equal([]ConfigError{
+		{Line: 6, Key: "profiles.ci.components.gitbase.prot", Message: "unknown key, did you mean port?"},
+		{Line: 7, Key: "profiles.ci.profiles", Message: "profiles can only be set at the top level"},
+	}, errs)
+
+	errs = ValidateConfig([]byte(`
+images:
+  keep: 2
+profiles:
+  ci:
+    images:
+      keep: -1
+`))
+	require.Equal([]ConfigError{{
+		Line:    5,
+		Key:     "profiles.ci",
+		Message: "with the profile ci applied: invalid images.keep: -1, it can't be negative",
+	}}, errs)
+
+	// the names are not the ones of the stacks of --profile
+	require.Empty(ValidateConfig([]byte("profiles:\n  my-ci: {}\n  Dev: {}\n")))
+}
@@ -0,0 +1,387 @@
+// Package audit writes and reads the log of the orchestration actions run by
+// the daemon, like the components started or the queries run, with the user
+// that requested them. It is meant for the servers where several users drive
+// the same engine.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// FileName is the name of the audit log in its directory. The rotated files
+// are named FileName.1, FileName.2... from the newest to the oldest
+const FileName = "audit.log"
+
+// Metadata keys of the gRPC requests that identify the client
+const (
+	UserKey = "srcd-user"
+	HostKey = "srcd-host"
+)
+
+// Actions of the records
+const (
+	ActionStartComponent   = "start-component"
+	ActionStopComponent    = "stop-component"
+	ActionRestartComponent = "restart-component"
+	ActionInstallDriver    = "install-driver"
+	ActionSQL              = "sql"
+	ActionSearch           = "search"
+	// the actions run by the daemon on its own, or as part of the requests
+	ActionImagePulled      = "image-pulled"
+	ActionComponentStarted = "component-started"
+	ActionComponentStopped = "component-stopped"
+	ActionDaemonStopped    = "daemon-stopped"
+)
+
+// Record is an action run by the daemon, written as a JSON line
+type Record struct {
+	Time time.Time `json:"time"`
+	// User and Host identify the client that requested the action, they are
+	// empty for the ones the daemon runs on its own
+	User string `json:"user,omitempty"`
+	Host string `json:"host,omitempty"`
+	// Addr is the address the request came from
+	Addr      string `json:"addr,omitempty"`
+	Action    string `json:"action"`
+	Component string `json:"component,omitempty"`
+	Image     string `json:"image,omitempty"`
+	// Hash is the SHA-256 of the SQL query or the search pattern, which are
+	// not kept as they may be sensitive
+	Hash    string `json:"hash,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Error is set if the action failed
+	Error string `json:"error,omitempty"`
+}
+
+// Who returns the identity of the client of the record, in the form
+// user@host, or daemon for the actions it runs on its own
+func (r Record) Who() string {
+	switch {
+	case r.User != "" && r.Host != "":
+		return r.User + "@" + r.Host
+	case r.User != "":
+		return r.User
+	case r.Addr != "":
+		return r.Addr
+	default:
+		return "daemon"
+	}
+}
+
+// Hash returns the value of Record.Hash for a query or a pattern
+func Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// FromContext returns a record with the identity of the client of the gRPC
+// request of the context, given by UserKey and HostKey, and its address
+func FromContext(ctx context.Context, action string) Record {
+	r := Record{Action: action}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(UserKey); len(v) > 0 {
+			r.User = v[0]
+		}
+
+		if v := md.Get(HostKey); len(v) > 0 {
+			r.Host = v[0]
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.Addr = p.Addr.String()
+	}
+
+	return r
+}
+
+// Log appends the records to the audit log of a directory. The file is
+// rotated when it grows beyond its maximum size, and the oldest ones are
+// removed. It is safe for concurrent use
+type Log struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens the audit log of the directory, rotated when it reaches maxSize
+// bytes, keeping maxFiles rotated files
+func Open(dir string, maxSize int64, maxFiles int) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "could not create the audit log directory %s", dir)
+	}
+
+	l := &Log{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(filepath.Join(l.dir, FileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "could not open the audit log")
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "could not open the audit log")
+	}
+
+	l.f, l.size = f, fi.Size()
+	return nil
+}
+
+// Write appends the record to the log, with the current time if it has none
+func (l *Log) Write(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return errors.Wrap(err, "could not write the audit log")
+}
+
+// rotate renames the files, from FileName to FileName.1 and so on, and opens
+// a new one. The ones beyond maxFiles are removed
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return errors.Wrap(err, "could not close the audit log")
+	}
+
+	base := filepath.Join(l.dir, FileName)
+	rotated := func(i int) string {
+		if i == 0 {
+			return base
+		}
+
+		return fmt.Sprintf("%s.%d", base, i)
+	}
+
+	if err := os.Remove(rotated(l.maxFiles)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "could not rotate the audit log")
+	}
+
+	for i := l.maxFiles - 1; i >= 0; i-- {
+		err := os.Rename(rotated(i), rotated(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "could not rotate the audit log")
+		}
+	}
+
+	return l.open()
+}
+
+// Sync commits the records written to the log file to disk
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return errors.Wrap(l.f.Sync(), "could not sync the audit log")
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// Tail returns the last n records of the audit log of the directory, from
+// the oldest to the newest, reading the rotated files if the current one has
+// less. All of them are returned if n is not positive
+func Tail(dir string, n int) ([]Record, error) {
+	files, err := filepath.Glob(filepath.Join(dir, FileName+".*"))
+	if err != nil {
+		return nil, err
+	}
+
+	// from the newest to the oldest
+	files = append([]string{filepath.Join(dir, FileName)}, sortRotated(files)...)
+
+	var records []Record
+	for _, file := range files {
+		rs, err := readFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(rs, records...)
+		if n > 0 && len(records) >= n {
+			return records[len(records)-n:], nil
+		}
+	}
+
+	return records, nil
+}
+
+// sortRotated sorts the rotated files by their number, from the newest to
+// the oldest, skipping the ones that are not rotated audit logs
+func sortRotated(files []string) []string {
+	byNum := make(map[int]string)
+	max := 0
+	for _, f := range files {
+		i, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(f), FileName+"."))
+		if err != nil || i <= 0 {
+			continue
+		}
+
+		byNum[i] = f
+		if i > max {
+			max = i
+		}
+	}
+
+	var sorted []string
+	for i := 1; i <= max; i++ {
+		if f, ok := byNum[i]; ok {
+			sorted = append(sorted, f)
+		}
+	}
+
+	return sorted
+}
+
+func readFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	_, err = readRecords(f, func(r Record) {
+		records = append(records, r)
+	})
+
+	return records, err
+}
+
+// readRecords reads the complete JSON lines of r, calling fn with each
+// record, and returns the number of bytes read. The lines that are not
+// records are skipped
+func readRecords(r io.Reader, fn func(Record)) (int64, error) {
+	var read int64
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// an incomplete line is read again once it is written
+			return read, nil
+		}
+
+		if err != nil {
+			return read, errors.Wrap(err, "could not read the audit log")
+		}
+
+		read += int64(len(line))
+
+		var rec Record
+		if json.Unmarshal(line, &rec) == nil {
+			fn(rec)
+		}
+	}
+}
+
+// Follow calls fn with the records written to the audit log of the directory
+// from now on, checking it at the given interval, until the context is
+// cancelled. The rotations of the file are followed
+func Follow(ctx context.Context, dir string, interval time.Duration, fn func(Record)) error {
+	path := filepath.Join(dir, FileName)
+
+	var offset int64
+	if fi, err := os.Stat(path); err == nil {
+		offset = fi.Size()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+
+		fi, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// the file was rotated, the new one is read from the start
+		if fi.Size() < offset {
+			offset = 0
+		}
+
+		if fi.Size() == offset {
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return err
+		}
+
+		n, err := readRecords(f, fn)
+		f.Close()
+		offset += n
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestLogRotation(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-audit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// each record takes around 80 bytes
+	l, err := Open(dir, 200, 2)
+	require.NoError(err)
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		require.NoError(l.Write(Record{Action: ActionStartComponent, Component: name}))
+	}
+	require.NoError(l.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, FileName+"*"))
+	require.NoError(err)
+	require.Len(files, 3)
+
+	records, err := Tail(dir, 3)
+	require.NoError(err)
+	require.Len(records, 3)
+	require.Equal("f", records[0].Component)
+	require.Equal("h", records[2].Component)
+	require.False(records[2].Time.IsZero())
+
+	// the oldest records were removed with the third rotated file
+	all, err := Tail(dir, 0)
+	require.NoError(err)
+	require.True(len(all) < 8)
+	require.Equal("h", all[len(all)-1].Component)
+
+	// the log is appended when it is opened again
+	l, err = Open(dir, 200, 2)
+	require.NoError(err)
+	require.NoError(l.Write(Record{Action: ActionSQL, Hash: Hash("SELECT 1")}))
+	require.NoError(l.Close())
+
+	records, err = Tail(dir, 2)
+	require.NoError(err)
+	require.Equal("h", records[0].Component)
+	require.Equal(Hash("SELECT 1"), records[1].Hash)
+}
+
+func TestTailEmpty(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-audit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	records, err := Tail(dir, 10)
+	require.NoError(err)
+	require.Empty(records)
+}
+
+func TestFollow(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-audit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	l, err := Open(dir, 200, 1)
+	require.NoError(err)
+	defer l.Close()
+
+	require.NoError(l.Write(Record{Action: ActionStartComponent, Component: "before"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	records := make(chan Record, 10)
+	done := make(chan error)
+	go func() {
+		done <- Follow(ctx, dir, 10*time.Millisecond, func(r Record) {
+			records <- r
+		})
+	}()
+
+	// the records written before are not followed, and the rotated ones are
+	time.Sleep(50 * time.Millisecond)
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(l.Write(Record{Action: ActionStartComponent, Component: name}))
+		select {
+		case r := <-records:
+			require.Equal(name, r.Component)
+		case <-time.After(5 * time.Second):
+			require.FailNow("record not followed", name)
+		}
+	}
+
+	cancel()
+	require.NoError(<-done)
+}
+
+func TestFromContext(t *testing.T) {
+	require := require.New(t)
+
+	r := FromContext(context.Background(), ActionSQL)
+	require.Equal(Record{Action: ActionSQL}, r)
+	require.Equal("daemon", r.Who())
+
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(UserKey, "alice", HostKey, "laptop"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}})
+
+	r = FromContext(ctx, ActionStopComponent)
+	require.Equal(Record{
+		User:   "alice",
+		Host:   "laptop",
+		Addr:   "10.0.0.1:5000",
+		Action: ActionStopComponent,
+	}, r)
+	require.Equal("alice@laptop", r.Who())
+}
@@ -0,0 +1,23 @@
+// Tests of the client that don't need a daemon, run with `npm test`.
+
+import * as assert from "assert";
+import * as grpc from "grpc";
+
+import { tokenInterceptor } from "./index";
+
+let sent: grpc.Metadata | undefined;
+const last = new grpc.InterceptingCall(null as any, {
+  start(metadata, listener, next) {
+    sent = metadata;
+  },
+});
+
+const call = tokenInterceptor("secret")({} as grpc.CallOptions, () => last);
+const metadata = new grpc.Metadata();
+metadata.add("srcd-user", "alice");
+call.start(metadata, {});
+
+assert.ok(sent);
+assert.deepStrictEqual(sent!.get("authorization"), ["Bearer secret"]);
+assert.deepStrictEqual(sent!.get("srcd-user"), ["alice"]);
+console.log("ok");
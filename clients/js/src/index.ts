@@ -0,0 +1,295 @@
+// TypeScript client for the source{d} Engine daemon API.
+//
+// The gRPC stubs in ./generated are created from api/api.proto with
+// `make clients`.
+//
+//   const engine = new Engine();
+//   for await (const row of engine.sql("SELECT repository_id FROM repositories")) {
+//     console.log(row);
+//   }
+
+import * as grpc from "grpc";
+
+import { EngineClient } from "./generated/api_grpc_pb";
+import {
+  CancelJobRequest,
+  CapabilitiesRequest,
+  CapabilitiesResponse,
+  ComponentInfo,
+  Job,
+  ListComponentsRequest,
+  ListDriversRequest,
+  ListJobsRequest,
+  ParseBatchRequest,
+  ParseRequest,
+  SQLRequest,
+  SQLResponse,
+  StartJobRequest,
+  VersionRequest,
+  WatchJobRequest,
+} from "./generated/api_pb";
+
+export * from "./generated/api_pb";
+export { EngineClient } from "./generated/api_grpc_pb";
+
+export const DEFAULT_HOST = "localhost";
+export const DEFAULT_PORT = 4242;
+export const DEFAULT_BATCH_SIZE = 100;
+// TOKEN_ENV is the environment variable with the token used when none is
+// given, like in srcd
+export const TOKEN_ENV = "SRCD_TOKEN";
+
+export type UastMode = "semantic" | "annotated" | "native";
+
+const uastModes: { [mode in UastMode]: ParseRequest.UastMode } = {
+  semantic: ParseRequest.UastMode.SEMANTIC,
+  annotated: ParseRequest.UastMode.ANNOTATED,
+  native: ParseRequest.UastMode.NATIVE,
+};
+
+export interface ParseOptions {
+  lang?: string;
+  query?: string;
+  mode?: UastMode;
+}
+
+export interface ParseResult {
+  lang: string;
+  uast: any[];
+}
+
+// ParseBatchResult is the result of each file given to parseBatch, error is
+// set if the file could not be parsed.
+export interface ParseBatchResult extends ParseResult {
+  name: string;
+  error: string;
+}
+
+export interface ParseFile {
+  name: string;
+  content: string | Uint8Array;
+}
+
+// JobOptions are the rest of the fields of StartJobRequest, like query for
+// create-index.
+export interface JobOptions {
+  image?: string;
+  hard?: boolean;
+  query?: string;
+  langs?: string[];
+  size?: number;
+  workers?: number;
+  streams?: number;
+  retries?: number;
+  verify?: boolean;
+}
+
+export interface Driver {
+  lang: string;
+  version: string;
+}
+
+export interface EngineOptions {
+  // token of the user, required when the daemon has users in
+  // daemon.auth.users of its config. It defaults to SRCD_TOKEN.
+  token?: string;
+}
+
+// tokenInterceptor sends the token of the user with every call.
+export function tokenInterceptor(token: string): grpc.Interceptor {
+  return (options, nextCall) => new grpc.InterceptingCall(nextCall(options), {
+    start(metadata, listener, next) {
+      metadata.add("authorization", `Bearer ${token}`);
+      next(metadata, listener);
+    },
+  });
+}
+
+// Engine is a connection to a running srcd daemon. The daemon must have been
+// started with `srcd init`.
+export class Engine {
+  readonly client: EngineClient;
+
+  constructor(host: string = DEFAULT_HOST, port: number = DEFAULT_PORT, opts: EngineOptions = {}) {
+    const token = (opts.token !== undefined ? opts.token : process.env[TOKEN_ENV] || "").trim();
+    const interceptors = token ? [tokenInterceptor(token)] : [];
+    this.client = new EngineClient(`${host}:${port}`, grpc.credentials.createInsecure(), { interceptors });
+  }
+
+  close(): void {
+    this.client.close();
+  }
+
+  version(): Promise<string> {
+    return new Promise((resolve, reject) => {
+      this.client.version(new VersionRequest(), (err, res) => {
+        err ? reject(err) : resolve(res.getVersion());
+      });
+    });
+  }
+
+  // sql runs the query in gitbase, yielding the column names first and then
+  // each row as they are received from the daemon. If maxRows is not 0, at
+  // most that many rows are returned.
+  async *sql(query: string, maxRows = 0, batchSize = DEFAULT_BATCH_SIZE): AsyncIterableIterator<string[]> {
+    const req = new SQLRequest().setQuery(query).setMaxRows(maxRows).setBatchSize(batchSize);
+    const stream = this.client.sQL(req);
+    for await (const res of stream as AsyncIterable<SQLResponse>) {
+      const row = res.getRow();
+      const rows = row ? [row, ...res.getRowsList()] : res.getRowsList();
+      for (const r of rows) {
+        yield r.getCellList_asU8().map((c) => Buffer.from(c).toString());
+      }
+    }
+  }
+
+  // capabilities returns the API version of the daemon and the optional
+  // features it supports.
+  capabilities(): Promise<CapabilitiesResponse> {
+    return new Promise((resolve, reject) => {
+      this.client.capabilities(new CapabilitiesRequest(), (err, res) => {
+        err ? reject(err) : resolve(res);
+      });
+    });
+  }
+
+  // parse returns the language of the file and its UAST nodes.
+  parse(name: string, content: string | Uint8Array, opts: ParseOptions = {}): Promise<ParseResult> {
+    let req: ParseRequest;
+    try {
+      req = uastRequest(name, content, opts);
+    } catch (err) {
+      return Promise.reject(err);
+    }
+
+    return new Promise((resolve, reject) => {
+      this.client.parse(req, (err, res) => {
+        if (err) {
+          return reject(err);
+        }
+
+        resolve({ lang: res.getLang(), uast: decodeUast(res.getUastList_asU8()) });
+      });
+    });
+  }
+
+  // parseBatch parses the files in a single call, returning a result for
+  // each file in the same order. A file that can't be parsed has its error
+  // set instead of failing the call.
+  parseBatch(files: ParseFile[], opts: ParseOptions = {}): Promise<ParseBatchResult[]> {
+    let req: ParseBatchRequest;
+    try {
+      req = new ParseBatchRequest().setFilesList(files.map((f) => uastRequest(f.name, f.content, opts)));
+    } catch (err) {
+      return Promise.reject(err);
+    }
+
+    return new Promise((resolve, reject) => {
+      this.client.parseBatch(req, (err, res) => {
+        if (err) {
+          return reject(err);
+        }
+
+        resolve(res.getResultsList().map((r) => ({
+          name: r.getName(),
+          lang: r.getLang(),
+          uast: decodeUast(r.getUastList_asU8()),
+          error: r.getError(),
+        })));
+      });
+    });
+  }
+
+  listDrivers(): Promise<Driver[]> {
+    return new Promise((resolve, reject) => {
+      this.client.listDrivers(new ListDriversRequest(), (err, res) => {
+        if (err) {
+          return reject(err);
+        }
+
+        resolve(res.getDriversList().map((d) => ({ lang: d.getLang(), version: d.getVersion() })));
+      });
+    });
+  }
+
+  // listComponents returns the components managed by the daemon and their
+  // state.
+  listComponents(): Promise<ComponentInfo[]> {
+    return new Promise((resolve, reject) => {
+      this.client.listComponents(new ListComponentsRequest(), (err, res) => {
+        err ? reject(err) : resolve(res.getComponentsList());
+      });
+    });
+  }
+
+  // startJob starts a job in the daemon, returning it as soon as it is
+  // running. kind is one of start-component, restart-component,
+  // install-driver, create-index, fetch-dataset or pull-image.
+  startJob(kind: string, name = "", opts: JobOptions = {}): Promise<Job> {
+    const req = new StartJobRequest()
+      .setKind(kind)
+      .setName(name)
+      .setImage(opts.image || "")
+      .setHard(!!opts.hard)
+      .setQuery(opts.query || "")
+      .setLangsList(opts.langs || [])
+      .setSize(opts.size || 0)
+      .setWorkers(opts.workers || 0)
+      .setStreams(opts.streams || 0)
+      .setRetries(opts.retries || 0)
+      .setVerify(!!opts.verify);
+
+    return new Promise((resolve, reject) => {
+      this.client.startJob(req, (err, res) => {
+        err ? reject(err) : resolve(res);
+      });
+    });
+  }
+
+  // listJobs returns the jobs running and the last ones finished.
+  listJobs(): Promise<Job[]> {
+    return new Promise((resolve, reject) => {
+      this.client.listJobs(new ListJobsRequest(), (err, res) => {
+        err ? reject(err) : resolve(res.getJobsList());
+      });
+    });
+  }
+
+  cancelJob(id: string): Promise<Job> {
+    return new Promise((resolve, reject) => {
+      this.client.cancelJob(new CancelJobRequest().setId(id), (err, res) => {
+        err ? reject(err) : resolve(res);
+      });
+    });
+  }
+
+  // watchJob yields the job each time its progress changes, until it
+  // finishes.
+  async *watchJob(id: string): AsyncIterableIterator<Job> {
+    const stream = this.client.watchJob(new WatchJobRequest().setId(id));
+    for await (const job of stream as AsyncIterable<Job>) {
+      yield job;
+    }
+  }
+}
+
+// uastRequest returns the request to parse the UAST of a file, throwing an
+// error if the mode is not valid.
+function uastRequest(name: string, content: string | Uint8Array, opts: ParseOptions): ParseRequest {
+  const mode = opts.mode || "semantic";
+  if (!(mode in uastModes)) {
+    throw new Error(`incorrect UAST mode '${mode}'. Allowed values: ${Object.keys(uastModes).join(", ")}`);
+  }
+
+  return new ParseRequest()
+    .setKind(ParseRequest.Kind.UAST)
+    .setName(name)
+    .setContent(typeof content === "string" ? Buffer.from(content) : content)
+    .setLang(opts.lang || "")
+    .setQuery(opts.query || "")
+    .setMode(uastModes[mode]);
+}
+
+function decodeUast(nodes: Uint8Array[]): any[] {
+  return nodes.map((n) => JSON.parse(Buffer.from(n).toString()));
+}
@@ -0,0 +1,11 @@
+from setuptools import setup
+
+setup(
+    name="srcd-engine",
+    version="0.1.0",
+    description="Python client for the source{d} Engine daemon API",
+    url="https://github.com/src-d/engine",
+    license="Apache-2.0",
+    packages=["srcd_engine"],
+    install_requires=["grpcio>=1.20.1", "protobuf>=3.7.1"],
+)
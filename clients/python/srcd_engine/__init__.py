@@ -0,0 +1,206 @@
+"""Python client for the source{d} Engine daemon API.
+
+The gRPC stubs are generated from api/api.proto with `make clients`.
+
+    from srcd_engine import Engine
+
+    engine = Engine()
+    for row in engine.sql("SELECT repository_id FROM repositories"):
+        print(row)
+"""
+
+import collections
+import json
+import os
+
+import grpc
+
+from . import api_pb2
+from . import api_pb2_grpc
+
+__all__ = ["Engine", "ParseBatchResult", "api_pb2", "api_pb2_grpc"]
+
+DEFAULT_HOST = "localhost"
+DEFAULT_PORT = 4242
+DEFAULT_BATCH_SIZE = 100
+
+# TOKEN_ENV is the environment variable with the token used when none is
+# given, like in srcd
+TOKEN_ENV = "SRCD_TOKEN"
+
+_UAST_MODES = {
+    "semantic": api_pb2.ParseRequest.SEMANTIC,
+    "annotated": api_pb2.ParseRequest.ANNOTATED,
+    "native": api_pb2.ParseRequest.NATIVE,
+}
+
+
+# ParseBatchResult is the result of each file given to Engine.parse_batch,
+# error is set if the file could not be parsed
+ParseBatchResult = collections.namedtuple(
+    "ParseBatchResult", ("name", "lang", "uast", "error"))
+
+
+class _CallDetails(
+        collections.namedtuple(
+            "_CallDetails", ("method", "timeout", "metadata", "credentials")),
+        grpc.ClientCallDetails):
+    pass
+
+
+class _TokenInterceptor(grpc.UnaryUnaryClientInterceptor,
+                        grpc.UnaryStreamClientInterceptor):
+    """Sends the token of the user with every call, as the daemon requires
+    when it has users in daemon.auth.users of its config."""
+
+    def __init__(self, token):
+        self._metadata = [("authorization", "Bearer " + token)]
+
+    def _details(self, details):
+        metadata = list(details.metadata or []) + self._metadata
+        return _CallDetails(details.method, details.timeout, metadata,
+                            details.credentials)
+
+    def intercept_unary_unary(self, continuation, details, request):
+        return continuation(self._details(details), request)
+
+    def intercept_unary_stream(self, continuation, details, request):
+        return continuation(self._details(details), request)
+
+
+class Engine(object):
+    """Connection to a running srcd daemon.
+
+    The daemon must have been started with `srcd init`. Use host and port to
+    connect to a daemon listening on a remote host, and token when the daemon
+    requires one. It defaults to the SRCD_TOKEN environment variable.
+    """
+
+    def __init__(self, host=DEFAULT_HOST, port=DEFAULT_PORT, token=None):
+        if token is None:
+            token = os.environ.get(TOKEN_ENV, "")
+
+        self._channel = grpc.insecure_channel("%s:%d" % (host, port))
+        channel = self._channel
+        token = token.strip()
+        if token:
+            channel = grpc.intercept_channel(channel, _TokenInterceptor(token))
+
+        self.stub = api_pb2_grpc.EngineStub(channel)
+
+    def close(self):
+        self._channel.close()
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *args):
+        self.close()
+
+    def version(self):
+        """Returns the version of the daemon."""
+        return self.stub.Version(api_pb2.VersionRequest()).version
+
+    def sql(self, query, max_rows=0, batch_size=DEFAULT_BATCH_SIZE):
+        """Runs the query in gitbase.
+
+        Yields the column names first, and then each row as a list of bytes
+        as they are received from the daemon. If max_rows is not 0, at most
+        that many rows are returned.
+        """
+        req = api_pb2.SQLRequest(query=query, max_rows=max_rows,
+                                 batch_size=batch_size)
+        for res in self.stub.SQL(req):
+            if res.HasField("row"):
+                yield list(res.row.cell)
+            for row in res.rows:
+                yield list(row.cell)
+
+    def capabilities(self):
+        """Returns the CapabilitiesResponse of the daemon, with its API
+        version and the optional features it supports."""
+        return self.stub.Capabilities(api_pb2.CapabilitiesRequest())
+
+    def parse(self, name, content, lang="", query="", mode="semantic"):
+        """Parses a file, returning its language and the list of UAST nodes
+        decoded from JSON."""
+        res = self.stub.Parse(_uast_request(name, content, lang, query, mode))
+        return res.lang, [json.loads(node) for node in res.uast]
+
+    def parse_batch(self, files, query="", mode="semantic"):
+        """Parses the (name, content) pairs of files in a single call,
+        returning a ParseBatchResult for each file, in the same order. A file
+        that can't be parsed has its error set instead of failing the call."""
+        req = api_pb2.ParseBatchRequest(files=[
+            _uast_request(name, content, "", query, mode)
+            for name, content in files
+        ])
+        res = self.stub.ParseBatch(req)
+        return [ParseBatchResult(r.name, r.lang,
+                                 [json.loads(node) for node in r.uast],
+                                 r.error)
+                for r in res.results]
+
+    def language(self, name, content):
+        """Detects the language of a file."""
+        if isinstance(content, str):
+            content = content.encode("utf-8")
+
+        res = self.stub.Parse(api_pb2.ParseRequest(
+            kind=api_pb2.ParseRequest.LANG,
+            name=name,
+            content=content,
+        ))
+        return res.lang
+
+    def list_drivers(self):
+        """Returns the installed bblfsh drivers as (language, version) pairs."""
+        res = self.stub.ListDrivers(api_pb2.ListDriversRequest())
+        return [(d.lang, d.version) for d in res.drivers]
+
+    def list_components(self):
+        """Returns the components managed by the daemon and their state."""
+        res = self.stub.ListComponents(api_pb2.ListComponentsRequest())
+        return list(res.components)
+
+    def start_job(self, kind, name="", **options):
+        """Starts a job in the daemon, returning it as soon as it is running.
+
+        kind is one of start-component, restart-component, install-driver,
+        create-index, fetch-dataset or pull-image, and options are the rest of
+        the fields of StartJobRequest, like query for create-index.
+        """
+        return self.stub.StartJob(
+            api_pb2.StartJobRequest(kind=kind, name=name, **options))
+
+    def list_jobs(self):
+        """Returns the jobs running and the last ones finished."""
+        return list(self.stub.ListJobs(api_pb2.ListJobsRequest()).jobs)
+
+    def cancel_job(self, id):
+        """Cancels a running job, returning it."""
+        return self.stub.CancelJob(api_pb2.CancelJobRequest(id=id))
+
+    def watch_job(self, id):
+        """Yields the job each time its progress changes, until it
+        finishes."""
+        for job in self.stub.WatchJob(api_pb2.WatchJobRequest(id=id)):
+            yield job
+
+
+def _uast_request(name, content, lang, query, mode):
+    if mode not in _UAST_MODES:
+        raise ValueError("incorrect UAST mode %r, allowed values: %s"
+                         % (mode, ", ".join(sorted(_UAST_MODES))))
+
+    if isinstance(content, str):
+        content = content.encode("utf-8")
+
+    return api_pb2.ParseRequest(
+        kind=api_pb2.ParseRequest.UAST,
+        name=name,
+        content=content,
+        lang=lang,
+        query=query,
+        mode=_UAST_MODES[mode],
+    )
@@ -0,0 +1,72 @@
+import os
+import unittest
+from unittest import mock
+
+from srcd_engine import (Engine, ParseBatchResult, _CallDetails,
+                         _TokenInterceptor, api_pb2)
+
+
+class TokenTest(unittest.TestCase):
+    def test_interceptor(self):
+        calls = []
+
+        def continuation(details, request):
+            calls.append((details, request))
+            return "response"
+
+        details = _CallDetails("/Engine/Version", None, [("srcd-user", "alice")], None)
+        interceptor = _TokenInterceptor("secret")
+        self.assertEqual("response",
+                         interceptor.intercept_unary_unary(continuation, details, "req"))
+        interceptor.intercept_unary_stream(continuation, details._replace(metadata=None), "req")
+
+        self.assertEqual([("srcd-user", "alice"), ("authorization", "Bearer secret")],
+                         calls[0][0].metadata)
+        self.assertEqual("/Engine/Version", calls[0][0].method)
+        self.assertEqual([("authorization", "Bearer secret")], calls[1][0].metadata)
+
+    def test_engine_token(self):
+        with mock.patch("srcd_engine._TokenInterceptor") as interceptor:
+            with mock.patch.dict(os.environ, {"SRCD_TOKEN": "from-env"}):
+                Engine().close()
+                Engine(token="given").close()
+                Engine(token="").close()
+
+            with mock.patch.dict(os.environ, {}, clear=True):
+                Engine().close()
+
+        self.assertEqual([mock.call("from-env"), mock.call("given")],
+                         interceptor.call_args_list)
+
+
+class ParseBatchTest(unittest.TestCase):
+    def test_parse_batch(self):
+        Result = api_pb2.ParseBatchResponse.Result
+        with Engine() as engine:
+            engine.stub = mock.Mock()
+            engine.stub.ParseBatch.return_value = api_pb2.ParseBatchResponse(
+                results=[
+                    Result(name="a.go", lang="go", uast=[b'{"@type": "File"}']),
+                    Result(name="b.xyz", error="unknown language"),
+                ])
+
+            results = engine.parse_batch(
+                [("a.go", "package a"), ("b.xyz", b"xyz")], mode="native")
+
+        req = engine.stub.ParseBatch.call_args[0][0]
+        self.assertEqual(["a.go", "b.xyz"], [f.name for f in req.files])
+        self.assertEqual(b"package a", req.files[0].content)
+        self.assertEqual(api_pb2.ParseRequest.NATIVE, req.files[1].mode)
+        self.assertEqual([
+            ParseBatchResult("a.go", "go", [{"@type": "File"}], ""),
+            ParseBatchResult("b.xyz", "", [], "unknown language"),
+        ], results)
+
+    def test_parse_batch_mode(self):
+        with Engine() as engine:
+            with self.assertRaises(ValueError):
+                engine.parse_batch([("a.go", "")], mode="wrong")
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/src-d/engine/audit"
+	sdk "github.com/src-d/engine/engine"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// auditActions are the actions recorded for the events of the engine
+var auditActions = map[sdk.EventKind]string{
+	sdk.EventPullFinished:     audit.ActionImagePulled,
+	sdk.EventComponentStarted: audit.ActionComponentStarted,
+	sdk.EventComponentStopped: audit.ActionComponentStopped,
+}
+
+// SetAuditLog makes the server record the orchestration actions, and the
+// clients that requested them, in the audit log. Nothing is recorded if it is
+// nil
+func (s *Server) SetAuditLog(l *audit.Log) {
+	s.audit = l
+}
+
+// record writes the action requested by the client of the context, with the
+// fields of r, in the audit log. The error of the action is recorded too
+func (s *Server) record(ctx context.Context, action string, r audit.Record, err error) {
+	if s.audit == nil {
+		return
+	}
+
+	rec := audit.FromContext(ctx, action)
+	rec.Component, rec.Image, rec.Hash = r.Component, r.Image, r.Hash
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	if err := s.audit.Write(rec); err != nil {
+		log.Errorf(err, "could not write the audit log")
+	}
+}
+
+// AuditEvents records the images pulled and the components started and
+// stopped in the audit log, as they happen, until the context is cancelled.
+func (s *Server) AuditEvents(ctx context.Context) {
+	if s.audit == nil {
+		return
+	}
+
+	events, cancel := s.engine.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			action, ok := auditActions[ev.Kind]
+			if !ok {
+				continue
+			}
+
+			err := s.audit.Write(audit.Record{
+				Time:      ev.Time,
+				Action:    action,
+				Component: ev.Component,
+				Image:     ev.Image,
+				Message:   ev.Message,
+			})
+			if err != nil {
+				log.Errorf(err, "could not write the audit log")
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-audit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	l, err := audit.Open(dir, 1<<20, 1)
+	require.NoError(err)
+	defer l.Close()
+
+	s := NewServer("v1.2.3", "/tmp", "linux", "", api.Config{})
+
+	// nothing is recorded without the audit log
+	s.record(context.Background(), audit.ActionSQL, audit.Record{}, nil)
+
+	s.SetAuditLog(l)
+
+	req := httptest.NewRequest("POST", "/api/v1/sql", nil)
+	req.Header.Set(HTTPUserHeader, "alice")
+	req.RemoteAddr = "10.0.0.1:5000"
+	ctx := withHTTPClient(req).Context()
+
+	s.record(ctx, audit.ActionSQL, audit.Record{Hash: audit.Hash("SELECT 1")}, fmt.Errorf("boom"))
+
+	records, err := audit.Tail(dir, 0)
+	require.NoError(err)
+	require.Len(records, 1)
+
+	r := records[0]
+	require.Equal("alice", r.User)
+	require.Equal("10.0.0.1:5000", r.Addr)
+	require.Equal(audit.ActionSQL, r.Action)
+	require.Equal(audit.Hash("SELECT 1"), r.Hash)
+	require.Equal("boom", r.Error)
+	require.False(r.Time.IsZero())
+}
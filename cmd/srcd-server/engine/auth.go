@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+	"github.com/src-d/engine/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenKey is the metadata key of the gRPC requests, and the header of the
+// REST ones, with the token of the user, in the form "Bearer <token>"
+const TokenKey = "authorization"
+
+// analystMethods are the methods of the API that the users with the analyst
+// role can call: the queries, searches and parses, and the ones that only read
+// the drivers and the status of the components. The rest, including the ones
+// added to the API later, can only be called by the admin users
+var analystMethods = map[string]bool{
+	"/Engine/Version":         true,
+	"/Engine/Capabilities":    true,
+	"/Engine/SQL":             true,
+	"/Engine/Search":          true,
+	"/Engine/Parse":           true,
+	"/Engine/ParseWithLogs":   true,
+	"/Engine/ParseBatch":      true,
+	"/Engine/ListDrivers":     true,
+	"/Engine/ListComponents":  true,
+	"/Engine/ComponentStatus": true,
+}
+
+// publicMethods are the methods of the API that can be called without a
+// token, so the clients can check the daemon before using it
+var publicMethods = map[string]bool{
+	"/Engine/Version":      true,
+	"/Engine/Capabilities": true,
+}
+
+// ServerOptions returns the options of the gRPC server of the API: the
+// tracing of the calls, and their authorization when Daemon.Auth has users.
+func (s *Server) ServerOptions() []grpc.ServerOption {
+	unary, stream := tracing.ServerInterceptors()
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(
+			ctx context.Context,
+			req interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			return unary(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				ctx, err := s.authorize(ctx, info.FullMethod)
+				if err != nil {
+					return nil, err
+				}
+
+				return handler(ctx, req)
+			})
+		}),
+		grpc.StreamInterceptor(func(
+			srv interface{},
+			ss grpc.ServerStream,
+			info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler,
+		) error {
+			return stream(srv, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+				ctx, err := s.authorize(ss.Context(), info.FullMethod)
+				if err != nil {
+					return err
+				}
+
+				return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+			})
+		}),
+	}
+}
+
+// authorizedStream is a ServerStream with the context returned by authorize
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorize checks that the token of the request belongs to a user whose
+// role can call the given method, when Daemon.Auth has users. It returns the
+// context with the name of the user as the one of the audit log, instead of
+// the one sent by the client
+func (s *Server) authorize(ctx context.Context, method string) (context.Context, error) {
+	if !s.config.AuthEnabled() || publicMethods[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if v := md.Get(TokenKey); len(v) > 0 {
+		token = strings.TrimSpace(strings.TrimPrefix(v[0], "Bearer "))
+	}
+
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated,
+			"the daemon requires a token, set it with --token or SRCD_TOKEN")
+	}
+
+	name, role, ok := s.config.AuthUser(token)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	if role != api.RoleAdmin && !analystMethods[method] {
+		return nil, status.Errorf(codes.PermissionDenied,
+			"the user %s with the %s role can't call %s", name, role, path.Base(method))
+	}
+
+	md = md.Copy()
+	md.Set(audit.UserKey, name)
+	return metadata.NewIncomingContext(ctx, md), nil
+}
+
+// httpMethod returns the API method called by a REST request, to authorize
+// it like the gRPC ones. It returns an empty string for the unknown ones,
+// which only the admin users can call
+func httpMethod(r *http.Request) string {
+	switch r.URL.Path {
+	case HTTPPrefix + "/version":
+		return "/Engine/Version"
+	case HTTPPrefix + "/capabilities":
+		return "/Engine/Capabilities"
+	case HTTPPrefix + "/status", HTTPPrefix + "/components":
+		return "/Engine/ListComponents"
+	case HTTPPrefix + "/sql":
+		return "/Engine/SQL"
+	case HTTPPrefix + "/parse":
+		return "/Engine/Parse"
+	case HTTPPrefix + "/jobs":
+		if r.Method == "POST" {
+			return "/Engine/StartJob"
+		}
+
+		return "/Engine/ListJobs"
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, HTTPPrefix+"/components/"):
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, HTTPPrefix+"/components/"), "/")
+		if len(parts) == 1 {
+			return "/Engine/ComponentStatus"
+		}
+
+		switch path.Base(r.URL.Path) {
+		case "start":
+			return "/Engine/StartComponent"
+		case "stop":
+			return "/Engine/StopComponent"
+		case "restart":
+			return "/Engine/RestartComponent"
+		}
+	case strings.HasPrefix(r.URL.Path, HTTPPrefix+"/jobs/"):
+		if path.Base(r.URL.Path) == "cancel" {
+			return "/Engine/CancelJob"
+		}
+
+		return "/Engine/WatchJob"
+	}
+
+	return ""
+}
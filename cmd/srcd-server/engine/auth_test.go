@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func authConfig() api.Config {
+	hash := func(token string) string {
+		sum := sha256.Sum256([]byte(token))
+		return hex.EncodeToString(sum[:])
+	}
+
+	var conf api.Config
+	conf.Daemon.Auth.Users = map[string]api.AuthUser{
+		"alice": {Role: api.RoleAdmin, TokenSHA256: hash("admin-token")},
+		"bob":   {Role: api.RoleAnalyst, TokenSHA256: hash("analyst-token")},
+	}
+
+	return conf
+}
+
+func TestAuthorize(t *testing.T) {
+	require := require.New(t)
+
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(),
+			metadata.Pairs(TokenKey, "Bearer "+token, audit.UserKey, "mallory"))
+	}
+
+	// anyone can use the API without users
+	s := NewServer("v1.2.3", "/tmp", "linux", "", api.Config{})
+	_, err := s.authorize(context.Background(), "/Engine/StartComponent")
+	require.NoError(err)
+
+	s = NewServer("v1.2.3", "/tmp", "linux", "", authConfig())
+
+	_, err = s.authorize(context.Background(), "/Engine/Version")
+	require.NoError(err)
+
+	_, err = s.authorize(context.Background(), "/Engine/SQL")
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	_, err = s.authorize(withToken("wrong"), "/Engine/SQL")
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	ctx, err := s.authorize(withToken("analyst-token"), "/Engine/SQL")
+	require.NoError(err)
+	require.Equal("bob", audit.FromContext(ctx, audit.ActionSQL).User)
+
+	_, err = s.authorize(withToken("analyst-token"), "/Engine/StopComponent")
+	require.Equal(codes.PermissionDenied, status.Code(err))
+	require.EqualError(err, "rpc error: code = PermissionDenied desc = "+
+		"the user bob with the analyst role can't call StopComponent")
+
+	ctx, err = s.authorize(withToken("admin-token"), "/Engine/StopComponent")
+	require.NoError(err)
+	require.Equal("alice", audit.FromContext(ctx, audit.ActionStopComponent).User)
+
+	for _, method := range []string{"/Engine/ParseBatch", "/Engine/ListDrivers", "/Engine/ComponentStatus"} {
+		_, err = s.authorize(withToken("analyst-token"), method)
+		require.NoError(err, method)
+	}
+
+	// the methods that are not allowed to the analysts, even the new ones,
+	// require an admin
+	for _, method := range []string{"/Engine/StartJob", "/Engine/CancelJob", "/Engine/Events",
+		"/Engine/InstallDriver", "/Engine/NewMethod", ""} {
+		_, err = s.authorize(withToken("analyst-token"), method)
+		require.Equal(codes.PermissionDenied, status.Code(err), method)
+
+		_, err = s.authorize(withToken("admin-token"), method)
+		require.NoError(err, method)
+	}
+}
+
+func TestHTTPAuthorize(t *testing.T) {
+	assert := assert.New(t)
+
+	h := NewHTTPHandler(NewServer("v1.2.3", "/tmp", "linux", "", authConfig()))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/version", nil))
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/components", nil))
+	assert.Equal(http.StatusUnauthorized, w.Code)
+	assert.JSONEq(`{"error":"the daemon requires a token, set it with --token or SRCD_TOKEN"}`,
+		w.Body.String())
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/components/srcd-cli-gitbase/stop", nil)
+	req.Header.Set("Authorization", "Bearer analyst-token")
+	h.ServeHTTP(w, req)
+	assert.Equal(http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader(`{"kind": "create-index"}`))
+	req.Header.Set("Authorization", "Bearer analyst-token")
+	h.ServeHTTP(w, req)
+	assert.Equal(http.StatusForbidden, w.Code)
+}
+
+func TestHTTPMethod(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []struct{ method, path, expected string }{
+		{"GET", "/api/v1/version", "/Engine/Version"},
+		{"GET", "/api/v1/status", "/Engine/ListComponents"},
+		{"GET", "/api/v1/components", "/Engine/ListComponents"},
+		{"GET", "/api/v1/components/srcd-cli-gitbase", "/Engine/ComponentStatus"},
+		{"POST", "/api/v1/components/srcd-cli-gitbase/restart", "/Engine/RestartComponent"},
+		{"POST", "/api/v1/sql", "/Engine/SQL"},
+		{"POST", "/api/v1/parse", "/Engine/Parse"},
+		{"GET", "/api/v1/jobs", "/Engine/ListJobs"},
+		{"POST", "/api/v1/jobs", "/Engine/StartJob"},
+		{"GET", "/api/v1/jobs/abc", "/Engine/WatchJob"},
+		{"POST", "/api/v1/jobs/abc/cancel", "/Engine/CancelJob"},
+		{"GET", "/api/v1/other", ""},
+		{"POST", "/api/v1/components/srcd-cli-gitbase/other", ""},
+	} {
+		assert.Equal(c.expected, httpMethod(httptest.NewRequest(c.method, c.path, nil)), c.path)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	require := require.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+
+	s := NewServer("v1.2.3", "/tmp", "linux", "", authConfig())
+	srv := grpc.NewServer(s.ServerOptions()...)
+	api.RegisterEngineServer(srv, s)
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	require.NoError(err)
+	defer conn.Close()
+
+	client := api.NewEngineClient(conn)
+	ctx := context.Background()
+
+	res, err := client.Version(ctx, &api.VersionRequest{})
+	require.NoError(err)
+	require.Equal("v1.2.3", res.Version)
+
+	_, err = client.ListComponents(ctx, &api.ListComponentsRequest{})
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	stream, err := client.Events(ctx, &api.EventsRequest{})
+	require.NoError(err)
+	_, err = stream.Recv()
+	require.Equal(codes.Unauthenticated, status.Code(err))
+}
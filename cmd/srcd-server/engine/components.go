@@ -2,59 +2,18 @@ package engine
 
 import (
 	"context"
-	"fmt"
-	"time"
 
-	"github.com/pkg/errors"
 	"github.com/src-d/engine/api"
-	"github.com/src-d/engine/components"
-	"github.com/src-d/engine/docker"
+	"github.com/src-d/engine/audit"
+	sdk "github.com/src-d/engine/engine"
 )
 
-const (
-	startComponentTimeout = 60 * time.Second
-)
-
-// Component to be run.
-type Component struct {
-	Name         string
-	Start        docker.StartFunc
-	Dependencies []Component
-}
-
-// Run the given components if they're not already running. It will recursively
-// run all the component dependencies.
-func Run(ctx context.Context, cs ...Component) error {
-	return run(ctx, cs, make(map[string]struct{}))
-}
-
-func run(ctx context.Context, cs []Component, seen map[string]struct{}) error {
-	for _, c := range cs {
-		if len(c.Dependencies) > 0 {
-			if err := run(ctx, c.Dependencies, seen); err != nil {
-				return err
-			}
-		}
-
-		if _, ok := seen[c.Name]; ok {
-			continue
-		}
-
-		seen[c.Name] = struct{}{}
-		_, err := docker.InfoOrStart(ctx, c.Name, c.Start)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 func (s *Server) StartComponent(
 	ctx context.Context,
 	r *api.StartComponentRequest,
 ) (*api.StartComponentResponse, error) {
-	port, err := s.startComponentAtPort(ctx, r.Name, int(r.Port))
+	port, err := s.engine.StartAtPort(ctx, r.Name, int(r.Port))
+	s.record(ctx, audit.ActionStartComponent, audit.Record{Component: r.Name}, err)
 	return &api.StartComponentResponse{Port: int32(port)}, err
 }
 
@@ -62,132 +21,64 @@ func (s *Server) StopComponent(
 	ctx context.Context,
 	r *api.StopComponentRequest,
 ) (*api.StopComponentResponse, error) {
-	return &api.StopComponentResponse{}, docker.RemoveContainer(r.Name)
+	err := s.engine.Stop(ctx, r.Name)
+	s.record(ctx, audit.ActionStopComponent, audit.Record{Component: r.Name}, err)
+	return &api.StopComponentResponse{}, err
 }
 
-func (s *Server) startComponent(ctx context.Context, name string) error {
-	_, err := s.startComponentAtPort(ctx, name, 0)
-	return err
+func (s *Server) RestartComponent(
+	ctx context.Context,
+	r *api.RestartComponentRequest,
+) (*api.RestartComponentResponse, error) {
+	port, err := s.engine.Restart(ctx, r.Name, r.Hard)
+	s.record(ctx, audit.ActionRestartComponent, audit.Record{Component: r.Name}, err)
+	return &api.RestartComponentResponse{Port: int32(port)}, err
 }
 
-// startComponentAtPort starts the container with the given public port binding.
-// If port is 0, the one set in the initial --config will be used.
-// If port is -1, the public port will be the same as the private one.
-func (s *Server) startComponentAtPort(
-	ctx context.Context, name string, port int,
-) (int, error) {
-
-	var err error
-	publicPort := s.getPublicPort(name, port)
-
-	switch name {
-	case gitbaseWeb.Name:
-		gbComp, err := s.gitbaseComponent(0)
-		if err != nil {
-			break
-		}
-
-		return publicPort, Run(ctx, Component{
-			Name:         gitbaseWeb.Name,
-			Start:        createGitbaseWeb(docker.WithPort(publicPort, components.GitbaseWebPort)),
-			Dependencies: []Component{*gbComp},
-		})
-	case bblfshWeb.Name:
-		bbfComp, err := s.bblfshComponent(0)
-		if err != nil {
-			break
-		}
-
-		return publicPort, Run(ctx, Component{
-			Name:         bblfshWeb.Name,
-			Start:        createBblfshWeb(docker.WithPort(publicPort, components.BblfshWebPort)),
-			Dependencies: []Component{*bbfComp},
-		})
-	case bblfshd.Name:
-		bbfComp, err := s.bblfshComponent(port)
-		if err != nil {
-			break
-		}
-
-		return publicPort, Run(ctx, *bbfComp)
-	case gitbase.Name:
-		gbComp, err := s.gitbaseComponent(port)
-		if err != nil {
-			break
-		}
+func (s *Server) ListComponents(
+	ctx context.Context,
+	r *api.ListComponentsRequest,
+) (*api.ListComponentsResponse, error) {
+	sts, err := s.engine.Components(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		return publicPort, Run(ctx, *gbComp)
-	default:
-		return 0, fmt.Errorf("can't start unknown component %s", name)
+	res := &api.ListComponentsResponse{}
+	for _, st := range sts {
+		res.Components = append(res.Components, toComponentInfo(st))
 	}
 
-	return 0, errors.Wrapf(err, "can't start component %s", name)
+	return res, nil
 }
 
-func (s *Server) getPublicPort(name string, requestedPort int) int {
-	var defaultPort, privatePort int
-
-	switch name {
-	case gitbaseWeb.Name:
-		defaultPort = s.config.Components.GitbaseWeb.Port
-		privatePort = components.GitbaseWebPort
-	case bblfshWeb.Name:
-		defaultPort = s.config.Components.BblfshWeb.Port
-		privatePort = components.BblfshWebPort
-	case bblfshd.Name:
-		defaultPort = s.config.Components.Bblfshd.Port
-		privatePort = components.BblfshParsePort
-	case gitbase.Name:
-		defaultPort = s.config.Components.Gitbase.Port
-		privatePort = components.GitbasePort
+func (s *Server) ComponentStatus(
+	ctx context.Context,
+	r *api.ComponentStatusRequest,
+) (*api.ComponentStatusResponse, error) {
+	st, err := s.engine.Status(ctx, r.Name)
+	if err != nil {
+		return nil, err
 	}
 
-	switch requestedPort {
-	case 0:
-		return defaultPort
-	case -1:
-		return privatePort
-	default:
-		return requestedPort
-	}
+	return &api.ComponentStatusResponse{Component: toComponentInfo(st)}, nil
 }
 
-func (s *Server) gitbaseComponent(port int) (*Component, error) {
-	port = s.getPublicPort(gitbase.Name, port)
-
-	indexVolumeName := fmt.Sprintf("srcd-cli-gitbase-%s", s.workdirHash)
-	if err := docker.CreateVolume(context.TODO(), indexVolumeName); err != nil {
-		return nil, errors.Wrapf(err, "can't create volume for gitbase index")
-	}
-
-	workdirHostPath, err := docker.HostPath(s.workdir)
-	if err != nil {
-		return nil, errors.Wrapf(err, "can't process host path for workdir %s", s.workdir)
+func toComponentInfo(st *sdk.ComponentStatus) *api.ComponentInfo {
+	info := &api.ComponentInfo{
+		Name:  st.Name,
+		Image: st.Image,
+		State: st.State,
 	}
-
-	bblfshComponent, err := s.bblfshComponent(0)
-	if err != nil {
-		return nil, errors.Wrapf(err, "can't create %s component", bblfshd.Name)
+	for _, p := range st.Ports {
+		info.Ports = append(info.Ports, int32(p))
 	}
 
-	return &Component{
-		Name: gitbase.Name,
-		Start: createGitbase(
-			docker.WithROSharedDirectory(workdirHostPath, gitbaseMountPath, s.hostOS),
-			docker.WithVolume(indexVolumeName, gitbaseIndexMountPath, s.hostOS),
-			docker.WithPort(port, components.GitbasePort),
-		),
-		Dependencies: []Component{*bblfshComponent},
-	}, nil
+	return info
 }
 
-func (s *Server) bblfshComponent(port int) (*Component, error) {
-	port = s.getPublicPort(bblfshd.Name, port)
-
-	return &Component{
-		Name: bblfshd.Name,
-		Start: createBbblfshd(
-			docker.WithPort(port, components.BblfshParsePort),
-		),
-	}, nil
+// Reconcile starts again the components whose containers exist but are not
+// running, see engine.Reconcile.
+func (s *Server) Reconcile(ctx context.Context) {
+	s.engine.Reconcile(ctx)
 }
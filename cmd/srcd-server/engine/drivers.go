@@ -4,49 +4,47 @@ import (
 	"context"
 	"fmt"
 
-	drivers "github.com/bblfsh/bblfshd/daemon/protocol"
-	"github.com/pkg/errors"
 	"github.com/src-d/engine/api"
-	"github.com/src-d/engine/components"
-	"google.golang.org/grpc"
-	"gopkg.in/src-d/go-log.v1"
+	"github.com/src-d/engine/audit"
+	sdk "github.com/src-d/engine/engine"
 )
 
-var ErrDriverAlreadyInstalled = errors.New("driver already installed")
-
-func (s *Server) bblfshDriverClient(ctx context.Context) (drivers.ProtocolServiceClient, error) {
-	if err := s.startComponent(ctx, bblfshd.Name); err != nil {
+func (s *Server) ListDrivers(ctx context.Context, req *api.ListDriversRequest) (*api.ListDriversResponse, error) {
+	drivers, err := s.engine.ListDrivers(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	addr := fmt.Sprintf("%s:%d", bblfshd.Name, components.BblfshControlPort)
-	log.Infof("connecting to bblfsh management on %s", addr)
-	conn, err := grpc.Dial(addr, grpc.WithInsecure())
-	if err != nil {
-		return nil, errors.Wrap(err, "could not connect to bblfsh drivers")
+	var list api.ListDriversResponse
+	for _, d := range drivers {
+		list.Drivers = append(list.Drivers, &api.ListDriversResponse_DriverInfo{
+			Lang:    d.Lang,
+			Version: d.Version,
+		})
 	}
 
-	return drivers.NewProtocolServiceClient(conn), nil
+	return &list, nil
 }
 
-func (s *Server) ListDrivers(ctx context.Context, req *api.ListDriversRequest) (*api.ListDriversResponse, error) {
-	client, err := s.bblfshDriverClient(ctx)
-	if err != nil {
-		return nil, err
+func (s *Server) InstallDriver(ctx context.Context, req *api.InstallDriverRequest) (*api.InstallDriverResponse, error) {
+	if req.Lang == "" {
+		return nil, fmt.Errorf("the language of the driver is required")
 	}
 
-	res, err := client.DriverStates(ctx, &drivers.DriverStatesRequest{})
-	if err != nil {
-		return nil, errors.Wrap(err, "could not list drivers from bblfsh")
+	err := s.engine.InstallDriver(ctx, req.Lang, req.Image)
+	if err != sdk.ErrDriverAlreadyInstalled {
+		s.record(ctx, audit.ActionInstallDriver, audit.Record{Image: req.Image, Component: req.Lang}, err)
 	}
 
-	var list api.ListDriversResponse
-	for _, state := range res.State {
-		list.Drivers = append(list.Drivers, &api.ListDriversResponse_DriverInfo{
-			Lang:    state.Language,
-			Version: state.Version,
-		})
+	if err != nil && err != sdk.ErrDriverAlreadyInstalled {
+		return nil, err
 	}
 
-	return &list, nil
+	return &api.InstallDriverResponse{}, nil
+}
+
+// SyncDrivers makes the drivers of bblfshd match the ones pinned in the
+// config, see engine.SyncDrivers.
+func (s *Server) SyncDrivers(ctx context.Context) error {
+	return s.engine.SyncDrivers(ctx)
 }
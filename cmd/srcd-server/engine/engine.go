@@ -2,33 +2,84 @@ package engine
 
 import (
 	"context"
-	"crypto/sha1"
-	"encoding/hex"
 
 	api "github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+	sdk "github.com/src-d/engine/engine"
 )
 
 var _ api.EngineServer = new(Server)
 
+// Server implements the Engine API, delegating the orchestration of the
+// components to the engine package.
 type Server struct {
-	version     string
-	workdir     string
-	hostOS      string
-	workdirHash string
-	config      api.Config
+	version string
+	config  api.Config
+	engine  *sdk.Engine
+	queries *queryLimiter
+	// quotas limits the queries and parses of each user
+	quotas *userQuotas
+	// cache has the results of the queries, nil if it is disabled
+	cache *queryCache
+	// audit records the orchestration actions, nil if it is disabled
+	audit *audit.Log
+	// jobs are the long operations run in the background
+	jobs *jobList
+	// drain tracks the running SQL and parse requests, see Shutdown
+	drain *drain
+	// datasetsDir is where the fetch-dataset jobs download the datasets,
+	// they fail if it is empty
+	datasetsDir string
 }
 
-func NewServer(version, workdir, hostOS string, config api.Config) *Server {
-	h := sha1.Sum([]byte(workdir))
-	return &Server{
-		version:     version,
-		workdir:     workdir,
-		hostOS:      hostOS,
-		workdirHash: hex.EncodeToString(h[:]),
-		config:      config,
+func NewServer(version, workdir, hostOS, uastCacheDir string, config api.Config) *Server {
+	return newServer(version, sdk.Options{
+		Workdir:      workdir,
+		HostOS:       hostOS,
+		Config:       config,
+		InNetwork:    true,
+		UASTCacheDir: uastCacheDir,
+	})
+}
+
+// NewLocalServer returns a Server that runs in the host instead of the
+// engine docker network, so it reaches the components through their public
+// ports. It is used by the CLI to run without the daemon.
+func NewLocalServer(version, workdir, uastCacheDir string, config api.Config) *Server {
+	return newServer(version, sdk.Options{
+		Workdir:      workdir,
+		Config:       config,
+		UASTCacheDir: uastCacheDir,
+	})
+}
+
+func newServer(version string, opts sdk.Options) *Server {
+	opts.Config.SetDefaults()
+
+	// the config is validated when it is read
+	cacheSize, cacheTTL, _ := opts.Config.QueryCache()
+	s := &Server{
+		version: version,
+		config:  opts.Config,
+		engine:  sdk.New(opts),
+		queries: newQueryLimiter(opts.Config.Daemon.MaxQueries, opts.Config.Daemon.QueryQueue),
+		cache:   newQueryCache(cacheSize, cacheTTL),
+		jobs:    newJobList(),
+		drain:   newDrain(),
 	}
+	s.quotas = newUserQuotas(s.config.UserLimits)
+	return s
 }
 
 func (s *Server) Version(ctx context.Context, req *api.VersionRequest) (*api.VersionResponse, error) {
 	return &api.VersionResponse{Version: s.version}, nil
 }
+
+func (s *Server) Capabilities(ctx context.Context, req *api.CapabilitiesRequest) (*api.CapabilitiesResponse, error) {
+	return &api.CapabilitiesResponse{
+		ApiVersion:    api.APIVersion,
+		MinApiVersion: api.MinAPIVersion,
+		Version:       s.version,
+		Features:      api.Features(),
+	}, nil
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/src-d/engine/api"
+	sdk "github.com/src-d/engine/engine"
+)
+
+var eventKinds = map[sdk.EventKind]api.Event_Kind{
+	sdk.EventPullStarted:      api.Event_PULL_STARTED,
+	sdk.EventPullFinished:     api.Event_PULL_FINISHED,
+	sdk.EventComponentStarted: api.Event_COMPONENT_STARTED,
+	sdk.EventComponentStopped: api.Event_COMPONENT_STOPPED,
+	sdk.EventHealthDegraded:   api.Event_HEALTH_DEGRADED,
+	sdk.EventUpgradeAvailable: api.Event_UPGRADE_AVAILABLE,
+}
+
+func (s *Server) Events(r *api.EventsRequest, stream api.Engine_EventsServer) error {
+	events, cancel := s.engine.Subscribe()
+	defer cancel()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-events:
+			if err := stream.Send(toEvent(ev)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toEvent(ev sdk.Event) *api.Event {
+	return &api.Event{
+		Kind:      eventKinds[ev.Kind],
+		Component: ev.Component,
+		Image:     ev.Image,
+		Message:   ev.Message,
+		Time:      ev.Time.UnixNano(),
+	}
+}
+
+// WatchHealth reports the running components that become unhealthy, see
+// engine.WatchHealth.
+func (s *Server) WatchHealth(ctx context.Context, interval time.Duration) {
+	s.engine.WatchHealth(ctx, interval)
+}
+
+// WatchExits reports the components that exit without being stopped, with
+// the reason, see engine.WatchExits.
+func (s *Server) WatchExits(ctx context.Context, interval time.Duration) {
+	s.engine.WatchExits(ctx, interval)
+}
+
+// WatchImages removes the old images of the components periodically, see
+// engine.WatchImages.
+func (s *Server) WatchImages(ctx context.Context, interval time.Duration) {
+	s.engine.WatchImages(ctx, interval)
+}
+
+// CheckUpgrades reports the components with a newer compatible image version,
+// see engine.CheckUpgrades.
+func (s *Server) CheckUpgrades(ctx context.Context) error {
+	return s.engine.CheckUpgrades(ctx)
+}
@@ -0,0 +1,613 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+	"github.com/src-d/engine/components"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// HTTPPrefix is the path prefix of all the REST endpoints
+const HTTPPrefix = "/api/v1"
+
+// NewHTTPHandler returns a REST/JSON gateway for the Engine API served by s.
+// The endpoints are documented in api/openapi.yaml.
+func NewHTTPHandler(s *Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HTTPPrefix+"/version", method("GET", s.httpVersion))
+	mux.HandleFunc(HTTPPrefix+"/capabilities", method("GET", s.httpCapabilities))
+	mux.HandleFunc(HTTPPrefix+"/status", method("GET", s.httpStatus))
+	mux.HandleFunc(HTTPPrefix+"/components", method("GET", s.httpListComponents))
+	mux.HandleFunc(HTTPPrefix+"/components/", s.httpComponent)
+	mux.HandleFunc(HTTPPrefix+"/sql", method("POST", s.httpSQL))
+	mux.HandleFunc(HTTPPrefix+"/parse", method("POST", s.httpParse))
+	mux.HandleFunc(HTTPPrefix+"/jobs", s.httpJobs)
+	mux.HandleFunc(HTTPPrefix+"/jobs/", s.httpJob)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = withHTTPClient(r)
+		ctx, err := s.authorize(r.Context(), httpMethod(r))
+		if err != nil {
+			code := http.StatusUnauthorized
+			if status.Code(err) == codes.PermissionDenied {
+				code = http.StatusForbidden
+			}
+
+			writeError(w, code, fmt.Errorf("%s", status.Convert(err).Message()))
+			return
+		}
+
+		mux.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// HTTPUserHeader identifies the user of the REST requests in the audit log
+const HTTPUserHeader = "X-Srcd-User"
+
+// withHTTPClient returns the request with the identity of its client in the
+// context, like the one of the gRPC requests, for the audit log and the
+// authorization
+func withHTTPClient(r *http.Request) *http.Request {
+	md := metadata.MD{}
+	if user := r.Header.Get(HTTPUserHeader); user != "" {
+		md.Set(audit.UserKey, user)
+	}
+
+	if token := r.Header.Get(TokenKey); token != "" {
+		md.Set(TokenKey, token)
+	}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+
+	return r.WithContext(ctx)
+}
+
+type httpError struct {
+	Error string `json:"error"`
+}
+
+func method(m string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		log.Infof("http request %s %s", r.Method, r.URL.Path)
+		h(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf(err, "could not write http response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, httpError{Error: err.Error()})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) httpVersion(w http.ResponseWriter, r *http.Request) {
+	res, err := s.Version(r.Context(), &api.VersionRequest{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"version": res.Version})
+}
+
+func (s *Server) httpCapabilities(w http.ResponseWriter, r *http.Request) {
+	res, err := s.Capabilities(r.Context(), &api.CapabilitiesRequest{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"api_version":     res.ApiVersion,
+		"min_api_version": res.MinApiVersion,
+		"version":         res.Version,
+		"features":        res.Features,
+	})
+}
+
+type componentStatus struct {
+	Name    string `json:"name"`
+	Image   string `json:"image"`
+	Running bool   `json:"running"`
+	Ports   []int  `json:"ports"`
+}
+
+func (s *Server) httpStatus(w http.ResponseWriter, r *http.Request) {
+	cmps, err := components.List(r.Context(), false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := struct {
+		Components []componentStatus `json:"components"`
+	}{Components: []componentStatus{}}
+
+	for _, cmp := range cmps {
+		running, err := cmp.IsRunning()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		ports, err := cmp.GetPorts()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		st := componentStatus{
+			Name:    cmp.Name,
+			Image:   cmp.ImageWithVersion(),
+			Running: running,
+			Ports:   []int{},
+		}
+		for _, p := range ports {
+			if p.PublicPort != 0 {
+				st.Ports = append(st.Ports, int(p.PublicPort))
+			}
+		}
+
+		res.Components = append(res.Components, st)
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// componentInfo is the JSON representation of api.ComponentInfo, with ports
+// as an empty list instead of null
+type componentInfo struct {
+	Name  string  `json:"name"`
+	Image string  `json:"image"`
+	State string  `json:"state"`
+	Ports []int32 `json:"ports"`
+}
+
+func newComponentInfo(c *api.ComponentInfo) componentInfo {
+	info := componentInfo{
+		Name:  c.Name,
+		Image: c.Image,
+		State: c.State,
+		Ports: c.Ports,
+	}
+	if info.Ports == nil {
+		info.Ports = []int32{}
+	}
+
+	return info
+}
+
+// httpListComponents handles GET /components
+func (s *Server) httpListComponents(w http.ResponseWriter, r *http.Request) {
+	res, err := s.ListComponents(r.Context(), &api.ListComponentsRequest{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cmps := make([]componentInfo, len(res.Components))
+	for i, c := range res.Components {
+		cmps[i] = newComponentInfo(c)
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]componentInfo{"components": cmps})
+}
+
+// httpComponent handles GET /components/{name} and
+// POST /components/{name}/{start,stop,restart}
+func (s *Server) httpComponent(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, HTTPPrefix+"/components/")
+	parts := strings.Split(path, "/")
+	if parts[0] == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+		return
+	}
+
+	name := parts[0]
+	if len(parts) == 1 {
+		method("GET", func(w http.ResponseWriter, r *http.Request) {
+			s.httpComponentStatus(w, r, name)
+		})(w, r)
+		return
+	}
+
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+		return
+	}
+
+	var h func(http.ResponseWriter, *http.Request, string)
+	switch parts[1] {
+	case "start":
+		h = s.httpStartComponent
+	case "stop":
+		h = s.httpStopComponent
+	case "restart":
+		h = s.httpRestartComponent
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+		return
+	}
+
+	method("POST", func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, name)
+	})(w, r)
+}
+
+func (s *Server) httpComponentStatus(w http.ResponseWriter, r *http.Request, name string) {
+	res, err := s.ComponentStatus(r.Context(), &api.ComponentStatusRequest{Name: name})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newComponentInfo(res.Component))
+}
+
+func (s *Server) httpStartComponent(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		Port int32 `json:"port"`
+	}
+	if r.ContentLength != 0 && !readJSON(w, r, &req) {
+		return
+	}
+
+	res, err := s.StartComponent(r.Context(), &api.StartComponentRequest{
+		Name: name,
+		Port: req.Port,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int32{"port": res.Port})
+}
+
+func (s *Server) httpStopComponent(w http.ResponseWriter, r *http.Request, name string) {
+	if _, err := s.StopComponent(r.Context(), &api.StopComponentRequest{Name: name}); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func (s *Server) httpRestartComponent(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		Hard bool `json:"hard"`
+	}
+	if r.ContentLength != 0 && !readJSON(w, r, &req) {
+		return
+	}
+
+	res, err := s.RestartComponent(r.Context(), &api.RestartComponentRequest{
+		Name: name,
+		Hard: req.Hard,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int32{"port": res.Port})
+}
+
+// requestErrorStatus returns the HTTP status of the errors of the SQL and
+// parse requests
+func requestErrorStatus(err error) int {
+	switch status.Code(err) {
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// httpJobCodes are the HTTP status of the errors of the job methods
+var httpJobCodes = map[codes.Code]int{
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.NotFound:           http.StatusNotFound,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.FailedPrecondition: http.StatusConflict,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+}
+
+func writeJobError(w http.ResponseWriter, err error) {
+	code, ok := httpJobCodes[status.Code(err)]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+
+	writeError(w, code, fmt.Errorf("%s", status.Convert(err).Message()))
+}
+
+// jobInfo is the JSON representation of api.Job, with the state as text and
+// the times in RFC 3339 format
+type jobInfo struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Message  string `json:"message"`
+	Error    string `json:"error,omitempty"`
+	User     string `json:"user"`
+	Started  string `json:"started"`
+	Finished string `json:"finished,omitempty"`
+	Port     int32  `json:"port,omitempty"`
+}
+
+func newJobInfo(j *api.Job) jobInfo {
+	info := jobInfo{
+		ID:      j.Id,
+		Kind:    j.Kind,
+		Name:    j.Name,
+		State:   strings.ToLower(j.State.String()),
+		Message: j.Message,
+		Error:   j.Error,
+		User:    j.User,
+		Started: time.Unix(0, j.Started).UTC().Format(time.RFC3339Nano),
+		Port:    j.Port,
+	}
+	if j.Finished != 0 {
+		info.Finished = time.Unix(0, j.Finished).UTC().Format(time.RFC3339Nano)
+	}
+
+	return info
+}
+
+// httpJobs handles GET /jobs and POST /jobs
+func (s *Server) httpJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		method("POST", s.httpStartJob)(w, r)
+		return
+	}
+
+	method("GET", func(w http.ResponseWriter, r *http.Request) {
+		res, err := s.ListJobs(r.Context(), &api.ListJobsRequest{})
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+
+		jobs := make([]jobInfo, len(res.Jobs))
+		for i, j := range res.Jobs {
+			jobs[i] = newJobInfo(j)
+		}
+
+		writeJSON(w, http.StatusOK, map[string][]jobInfo{"jobs": jobs})
+	})(w, r)
+}
+
+func (s *Server) httpStartJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Kind    string   `json:"kind"`
+		Name    string   `json:"name"`
+		Image   string   `json:"image"`
+		Hard    bool     `json:"hard"`
+		Query   string   `json:"query"`
+		Langs   []string `json:"langs"`
+		Size    int64    `json:"size"`
+		Workers int32    `json:"workers"`
+		Streams int32    `json:"streams"`
+		Retries int32    `json:"retries"`
+		Verify  bool     `json:"verify"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	res, err := s.StartJob(r.Context(), &api.StartJobRequest{
+		Kind:    req.Kind,
+		Name:    req.Name,
+		Image:   req.Image,
+		Hard:    req.Hard,
+		Query:   req.Query,
+		Langs:   req.Langs,
+		Size:    req.Size,
+		Workers: req.Workers,
+		Streams: req.Streams,
+		Retries: req.Retries,
+		Verify:  req.Verify,
+	})
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, newJobInfo(res))
+}
+
+// httpJob handles GET /jobs/{id} and POST /jobs/{id}/cancel
+func (s *Server) httpJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, HTTPPrefix+"/jobs/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		method("GET", func(w http.ResponseWriter, r *http.Request) {
+			j, err := s.jobs.get(parts[0])
+			if err != nil {
+				writeJobError(w, err)
+				return
+			}
+
+			info, _ := j.state()
+			writeJSON(w, http.StatusOK, newJobInfo(info))
+		})(w, r)
+	case len(parts) == 2 && parts[0] != "" && parts[1] == "cancel":
+		method("POST", func(w http.ResponseWriter, r *http.Request) {
+			res, err := s.CancelJob(r.Context(), &api.CancelJobRequest{Id: parts[0]})
+			if err != nil {
+				writeJobError(w, err)
+				return
+			}
+
+			writeJSON(w, http.StatusOK, newJobInfo(res))
+		})(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path))
+	}
+}
+
+// httpSQL handles POST /sql. The rows are written as they are read from
+// gitbase, so the whole result set is never held in memory
+func (s *Server) httpSQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query   string `json:"query"`
+		MaxRows int64  `json:"max_rows"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("query is required"))
+		return
+	}
+
+	if req.MaxRows < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("max_rows can't be negative"))
+		return
+	}
+
+	// the status code can't be changed once the first row has been written
+	started := false
+	truncated, err := s.sql(r.Context(), req.Query, req.MaxRows, func(row [][]byte) error {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = string(c)
+		}
+
+		b, err := json.Marshal(cells)
+		if err != nil {
+			return err
+		}
+
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = fmt.Fprintf(w, `{"columns":%s,"rows":[`, b)
+			return err
+		}
+
+		if _, err := w.Write([]byte(",")); err != nil {
+			return err
+		}
+
+		_, err = w.Write(b)
+		return err
+	})
+
+	if !started {
+		if err == nil {
+			err = fmt.Errorf("query returned no columns")
+		}
+
+		writeError(w, requestErrorStatus(err), err)
+		return
+	}
+
+	if err != nil {
+		// the JSON document is left unfinished, so the client can't mistake a
+		// partial result for a complete one
+		log.Errorf(err, "http sql request failed after writing the response")
+		return
+	}
+
+	fmt.Fprintf(w, `],"truncated":%t}`, truncated)
+}
+
+type httpParseRequest struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Lang    string `json:"lang"`
+	Query   string `json:"query"`
+	Mode    string `json:"mode"`
+	// OnlyLang skips the parsing, returning only the detected language
+	OnlyLang bool `json:"only_lang"`
+}
+
+type httpParseResponse struct {
+	Lang string            `json:"lang"`
+	UAST []json.RawMessage `json:"uast,omitempty"`
+}
+
+// httpParse handles POST /parse
+func (s *Server) httpParse(w http.ResponseWriter, r *http.Request) {
+	var req httpParseRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	preq := &api.ParseRequest{
+		Kind:    api.ParseRequest_UAST,
+		Name:    req.Name,
+		Content: []byte(req.Content),
+		Lang:    req.Lang,
+		Query:   req.Query,
+	}
+
+	if req.OnlyLang {
+		preq.Kind = api.ParseRequest_LANG
+	}
+
+	switch req.Mode {
+	case "", "semantic":
+		preq.Mode = api.ParseRequest_SEMANTIC
+	case "annotated":
+		preq.Mode = api.ParseRequest_ANNOTATED
+	case "native":
+		preq.Mode = api.ParseRequest_NATIVE
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf(
+			"incorrect UAST mode '%s'. Allowed values: semantic, annotated, native", req.Mode))
+		return
+	}
+
+	res, err := s.Parse(r.Context(), preq)
+	if err != nil {
+		writeError(w, requestErrorStatus(err), err)
+		return
+	}
+
+	resp := httpParseResponse{Lang: res.Lang}
+	for _, node := range res.Uast {
+		resp.UAST = append(resp.UAST, json.RawMessage(node))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/src-d/engine/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	h := NewHTTPHandler(NewServer("v1.2.3", "/tmp", "linux", "", api.Config{}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/version", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.JSONEq(`{"version":"v1.2.3"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/version", nil))
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHTTPCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	h := NewHTTPHandler(NewServer("v1.2.3", "/tmp", "linux", "", api.Config{}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/capabilities", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.JSONEq(`{
+		"api_version": 1,
+		"min_api_version": 1,
+		"version": "v1.2.3",
+		"features": ["parse-batch", "search", "restart-component", "component-status", "events", "jobs",
+			"fetch-dataset-job", "pull-image-job"]
+	}`, w.Body.String())
+}
+
+func TestHTTPBadRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	h := NewHTTPHandler(NewServer("dev", "/tmp", "linux", "", api.Config{}))
+
+	cases := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/api/v1/sql", `not json`, http.StatusBadRequest},
+		{"/api/v1/sql", `{"query": " "}`, http.StatusBadRequest},
+		{"/api/v1/sql", `{"query": "SELECT 1", "max_rows": -1}`, http.StatusBadRequest},
+		{"/api/v1/parse", `{"mode": "foo"}`, http.StatusBadRequest},
+		{"/api/v1/components/srcd-cli-gitbase/pause", ``, http.StatusNotFound},
+		{"/api/v1/components/srcd-cli-gitbase/start/now", ``, http.StatusNotFound},
+		{"/api/v1/components/srcd-cli-gitbase", ``, http.StatusMethodNotAllowed},
+		{"/api/v1/components/", ``, http.StatusNotFound},
+		{"/api/v1/jobs", `{"kind": "pause-component", "name": "srcd-cli-gitbase"}`, http.StatusBadRequest},
+		{"/api/v1/jobs", `{"kind": "create-index", "query": "SELECT 1"}`, http.StatusBadRequest},
+		{"/api/v1/jobs/0123456789ab/cancel", ``, http.StatusNotFound},
+		{"/api/v1/jobs/0123456789ab/pause", ``, http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", c.path, strings.NewReader(c.body)))
+		assert.Equal(c.code, w.Code, c.path)
+		assert.Contains(w.Body.String(), `"error"`, c.path)
+	}
+}
@@ -0,0 +1,456 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/datasets"
+	"github.com/src-d/engine/docker"
+	sdk "github.com/src-d/engine/engine"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// maxFinishedJobs is the number of finished jobs kept to be listed, the
+// oldest ones are forgotten
+const maxFinishedJobs = 100
+
+// jobFunc runs a job until it finishes or the context is cancelled, calling
+// progress as it advances. It returns the public port of the component of
+// the job, if any
+type jobFunc func(ctx context.Context, progress func(string)) (int, error)
+
+// job is a long operation run by the daemon in the background, see
+// jobList.start
+type job struct {
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	info api.Job
+	// changed is closed, and replaced, each time info changes
+	changed chan struct{}
+}
+
+// state returns a copy of the job, and a channel closed when it changes
+func (j *job) state() (*api.Job, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := j.info
+	return &info, j.changed
+}
+
+func (j *job) update(fn func(info *api.Job)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fn(&j.info)
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// jobList keeps the running jobs and the last ones finished
+type jobList struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	// ids are the ones of jobs, oldest first
+	ids []string
+}
+
+func newJobList() *jobList {
+	return &jobList{jobs: make(map[string]*job)}
+}
+
+// start runs fn in the background as a job of the given kind, name and user,
+// until it finishes or the job is cancelled. ctx must not be the one of the
+// request, so the job keeps running when the client disconnects
+func (l *jobList) start(ctx context.Context, kind, name, user string, fn jobFunc) *job {
+	ctx, cancel := context.WithCancel(ctx)
+	j := &job{
+		cancel: cancel,
+		info: api.Job{
+			Id:      newJobID(),
+			Kind:    kind,
+			Name:    name,
+			User:    user,
+			State:   api.Job_RUNNING,
+			Started: time.Now().UnixNano(),
+		},
+		changed: make(chan struct{}),
+	}
+
+	l.mu.Lock()
+	l.jobs[j.info.Id] = j
+	l.ids = append(l.ids, j.info.Id)
+	l.mu.Unlock()
+
+	go func() {
+		defer cancel()
+
+		port, err := fn(ctx, func(msg string) {
+			j.update(func(info *api.Job) { info.Message = msg })
+		})
+
+		j.update(func(info *api.Job) {
+			info.Port = int32(port)
+			info.Finished = time.Now().UnixNano()
+			switch {
+			case err != nil && ctx.Err() == context.Canceled:
+				info.State = api.Job_CANCELED
+				info.Error = "the job was cancelled"
+			case err != nil:
+				info.State = api.Job_FAILED
+				info.Error = err.Error()
+			default:
+				info.State = api.Job_SUCCEEDED
+			}
+		})
+
+		if err != nil {
+			log.Warningf("%s job %s of %s failed: %s", kind, j.info.Id, name, err)
+		}
+
+		l.prune()
+	}()
+
+	return j
+}
+
+// get returns the job with the given id, or a codes.NotFound error
+func (l *jobList) get(id string) (*job, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	j, ok := l.jobs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "there is no job %s", id)
+	}
+
+	return j, nil
+}
+
+// list returns the state of the jobs, oldest first
+func (l *jobList) list() []*api.Job {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	res := make([]*api.Job, 0, len(l.ids))
+	for _, id := range l.ids {
+		info, _ := l.jobs[id].state()
+		res = append(res, info)
+	}
+
+	return res
+}
+
+// running returns the running job of the given kind and name, or nil if
+// there is none
+func (l *jobList) running(kind, name string) *api.Job {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range l.ids {
+		info, _ := l.jobs[id].state()
+		if info.Kind == kind && info.Name == name && !info.Done() {
+			return info
+		}
+	}
+
+	return nil
+}
+
+// cancelAll cancels the running jobs, and returns how many there were
+func (l *jobList) cancelAll() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var n int
+	for _, j := range l.jobs {
+		if info, _ := j.state(); !info.Done() {
+			j.cancel()
+			n++
+		}
+	}
+
+	return n
+}
+
+// prune forgets the oldest finished jobs beyond maxFinishedJobs
+func (l *jobList) prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var finished int
+	for _, id := range l.ids {
+		if info, _ := l.jobs[id].state(); info.Done() {
+			finished++
+		}
+	}
+
+	ids := l.ids[:0]
+	for _, id := range l.ids {
+		info, _ := l.jobs[id].state()
+		if info.Done() && finished > maxFinishedJobs {
+			finished--
+			delete(l.jobs, id)
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	l.ids = ids
+}
+
+// newJobID returns a random id for a job, in the short form of the docker
+// container ids
+func newJobID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%012x", time.Now().UnixNano()&0xffffffffffff)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// detach returns a context with the client of the request, for the audit log,
+// that is not cancelled when the request finishes
+func detach(ctx context.Context) context.Context {
+	res := context.Background()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		res = metadata.NewIncomingContext(res, md.Copy())
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		res = peer.NewContext(res, p)
+	}
+
+	return res
+}
+
+func (s *Server) StartJob(ctx context.Context, req *api.StartJobRequest) (*api.Job, error) {
+	if s.drain.isClosed() {
+		return nil, errShuttingDown
+	}
+
+	fn, err := s.jobFunc(req)
+	if err != nil {
+		return nil, err
+	}
+
+	user := audit.FromContext(ctx, "").Who()
+	j := s.jobs.start(detach(ctx), req.Kind, req.Name, user, fn)
+	info, _ := j.state()
+	return info, nil
+}
+
+// jobFunc returns the function that runs the job of the request
+func (s *Server) jobFunc(req *api.StartJobRequest) (jobFunc, error) {
+	if req.Kind != api.JobCreateIndex && req.Name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "the name of the %s job is required", req.Kind)
+	}
+
+	switch req.Kind {
+	case api.JobStartComponent:
+		return func(ctx context.Context, progress func(string)) (int, error) {
+			defer s.componentProgress(req.Name, progress)()
+
+			progress(fmt.Sprintf("starting %s", req.Name))
+			res, err := s.StartComponent(ctx, &api.StartComponentRequest{Name: req.Name})
+			return int(res.GetPort()), err
+		}, nil
+	case api.JobRestartComponent:
+		return func(ctx context.Context, progress func(string)) (int, error) {
+			defer s.componentProgress(req.Name, progress)()
+
+			progress(fmt.Sprintf("restarting %s", req.Name))
+			res, err := s.RestartComponent(ctx, &api.RestartComponentRequest{
+				Name: req.Name,
+				Hard: req.Hard,
+			})
+			return int(res.GetPort()), err
+		}, nil
+	case api.JobInstallDriver:
+		return func(ctx context.Context, progress func(string)) (int, error) {
+			progress(fmt.Sprintf("installing the bblfsh driver of %s", req.Name))
+			_, err := s.InstallDriver(ctx, &api.InstallDriverRequest{Lang: req.Name, Image: req.Image})
+			return 0, err
+		}, nil
+	case api.JobCreateIndex:
+		if !api.IsCreateIndex(req.Query) {
+			return nil, status.Error(codes.InvalidArgument,
+				"the query of the create-index job must be a CREATE INDEX statement")
+		}
+
+		return func(ctx context.Context, progress func(string)) (int, error) {
+			progress("creating the index")
+			_, err := s.sql(ctx, req.Query, 0, func([][]byte) error { return nil })
+			return 0, err
+		}, nil
+	case api.JobFetchDataset:
+		return s.fetchDatasetJob(req)
+	case api.JobPullImage:
+		image, version := docker.SplitImageID(req.Name)
+		return func(ctx context.Context, progress func(string)) (int, error) {
+			progress(fmt.Sprintf("pulling %s:%s", image, version))
+			return 0, docker.Pull(ctx, image, version)
+		}, nil
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown job kind %q", req.Kind)
+	}
+}
+
+// SetDatasetsDir sets the directory where the fetch-dataset jobs download the
+// datasets, in a directory per dataset
+func (s *Server) SetDatasetsDir(dir string) {
+	s.datasetsDir = dir
+}
+
+// fetchDatasetJob returns the function that runs the fetch-dataset job of the
+// request. Only one job can download each dataset at the same time
+func (s *Server) fetchDatasetJob(req *api.StartJobRequest) (jobFunc, error) {
+	if s.datasetsDir == "" {
+		return nil, status.Error(codes.FailedPrecondition,
+			"the daemon has no datasets directory, restart it with srcd restart")
+	}
+
+	if req.Name != datasets.PGA {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"unknown dataset %q, the only one available is %s", req.Name, datasets.PGA)
+	}
+
+	opts := datasets.Options{
+		Langs:   req.Langs,
+		Limit:   req.Size,
+		Bucket:  s.config.Components.Gitbase.SivaBucket,
+		Workers: int(req.Workers),
+		Streams: int(req.Streams),
+		Retries: int(req.Retries),
+		Verify:  req.Verify,
+	}
+	if opts.Limit == 0 {
+		opts.Limit = datasets.DefaultLimit
+	}
+
+	if opts.Workers == 0 {
+		opts.Workers = datasets.DefaultWorkers
+	}
+
+	if opts.Streams == 0 {
+		opts.Streams = datasets.DefaultStreams
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if j := s.jobs.running(api.JobFetchDataset, req.Name); j != nil {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"the dataset %s is already being downloaded by the job %s", req.Name, j.Id)
+	}
+
+	dir := filepath.Join(s.datasetsDir, req.Name)
+	return func(ctx context.Context, progress func(string)) (int, error) {
+		res, err := datasets.Fetch(ctx, req.Name, dir, opts, progress)
+		if err != nil {
+			return 0, err
+		}
+
+		progress(res.String())
+		return 0, nil
+	}, nil
+}
+
+// componentProgress reports the images pulled and the containers started for
+// the component with the given container name and its dependencies, until
+// the returned function is called
+func (s *Server) componentProgress(name string, progress func(string)) func() {
+	names := map[string]bool{name: true}
+	for _, dep := range components.Dependencies(name) {
+		names[dep.Name] = true
+	}
+
+	events, cancel := s.engine.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case ev := <-events:
+				if ev.Kind == sdk.EventUpgradeAvailable || ev.Kind == sdk.EventHealthDegraded {
+					continue
+				}
+
+				if names[ev.Component] && ev.Message != "" {
+					progress(ev.Message)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		close(done)
+	}
+}
+
+func (s *Server) ListJobs(ctx context.Context, req *api.ListJobsRequest) (*api.ListJobsResponse, error) {
+	return &api.ListJobsResponse{Jobs: s.jobs.list()}, nil
+}
+
+// CancelJob cancels a running job
+func (s *Server) CancelJob(ctx context.Context, req *api.CancelJobRequest) (*api.Job, error) {
+	j, err := s.jobs.get(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	info, _ := j.state()
+	if info.Done() {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"the job %s has already finished", info.Id)
+	}
+
+	j.cancel()
+	return info, nil
+}
+
+// WatchJob sends the job each time it changes, until it finishes or the
+// client cancels the call
+func (s *Server) WatchJob(req *api.WatchJobRequest, stream api.Engine_WatchJobServer) error {
+	j, err := s.jobs.get(req.Id)
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	for {
+		info, changed := j.state()
+		if err := stream.Send(info); err != nil {
+			return err
+		}
+
+		if info.Done() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+	}
+}
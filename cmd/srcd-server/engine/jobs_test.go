@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+	"github.com/src-d/engine/datasets"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// waitJob waits until the job finishes and returns its last state
+func waitJob(j *job) *api.Job {
+	for {
+		info, changed := j.state()
+		if info.Done() {
+			return info
+		}
+
+		<-changed
+	}
+}
+
+func TestJobList(t *testing.T) {
+	require := require.New(t)
+
+	l := newJobList()
+	proceed := make(chan struct{})
+	j := l.start(context.Background(), api.JobStartComponent, "srcd-cli-gitbase", "alice",
+		func(ctx context.Context, progress func(string)) (int, error) {
+			progress("pulling")
+			<-proceed
+			return 3306, nil
+		})
+
+	info, _ := j.state()
+	require.Len(info.Id, 12)
+	require.Equal(api.Job_RUNNING, info.State)
+	require.Equal("alice", info.User)
+
+	close(proceed)
+	info = waitJob(j)
+	require.Equal(api.Job_SUCCEEDED, info.State)
+	require.Equal("pulling", info.Message)
+	require.Equal(int32(3306), info.Port)
+	require.NotZero(info.Finished)
+
+	failed := l.start(context.Background(), api.JobInstallDriver, "go", "alice",
+		func(ctx context.Context, progress func(string)) (int, error) {
+			return 0, fmt.Errorf("no such image")
+		})
+	info = waitJob(failed)
+	require.Equal(api.Job_FAILED, info.State)
+	require.Equal("no such image", info.Error)
+
+	cancelled := l.start(context.Background(), api.JobCreateIndex, "", "alice",
+		func(ctx context.Context, progress func(string)) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+	cancelled.cancel()
+	info = waitJob(cancelled)
+	require.Equal(api.Job_CANCELED, info.State)
+
+	jobs := l.list()
+	require.Len(jobs, 3)
+	require.Equal(j.info.Id, jobs[0].Id)
+
+	_, err := l.get("unknown")
+	require.Equal(codes.NotFound, status.Code(err))
+}
+
+func TestJobListPrune(t *testing.T) {
+	require := require.New(t)
+
+	l := newJobList()
+	running := l.start(context.Background(), api.JobCreateIndex, "", "alice",
+		func(ctx context.Context, progress func(string)) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+	defer running.cancel()
+
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		waitJob(l.start(context.Background(), api.JobInstallDriver, "go", "alice",
+			func(ctx context.Context, progress func(string)) (int, error) {
+				return 0, nil
+			}))
+	}
+
+	l.prune()
+	jobs := l.list()
+	require.Len(jobs, maxFinishedJobs+1)
+	require.Equal(running.info.Id, jobs[0].Id)
+}
+
+func TestStartCancelJob(t *testing.T) {
+	require := require.New(t)
+
+	s := NewServer("v1.2.3", "/tmp", "linux", "", authConfig())
+	alice := metadata.NewIncomingContext(context.Background(), metadata.Pairs(audit.UserKey, "alice"))
+
+	_, err := s.StartJob(alice, &api.StartJobRequest{Kind: api.JobStartComponent})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = s.StartJob(alice, &api.StartJobRequest{Kind: api.JobCreateIndex, Query: "DROP INDEX i ON refs"})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	j := s.jobs.start(detach(alice), api.JobCreateIndex, "", "alice",
+		func(ctx context.Context, progress func(string)) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+
+	_, err = s.CancelJob(alice, &api.CancelJobRequest{Id: j.info.Id})
+	require.NoError(err)
+	require.Equal(api.Job_CANCELED, waitJob(j).State)
+
+	_, err = s.CancelJob(alice, &api.CancelJobRequest{Id: j.info.Id})
+	require.Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func TestFetchDatasetJob(t *testing.T) {
+	require := require.New(t)
+
+	var index bytes.Buffer
+	gz := gzip.NewWriter(&index)
+	gz.Write([]byte("URL,SIVA_FILENAMES,LANGS\nhttps://github.com/a/go,aa1.siva,Go\n"))
+	require.NoError(gz.Close())
+
+	proceed := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/csv/latest.csv.gz" {
+			<-proceed
+			w.Write(index.Bytes())
+			return
+		}
+
+		w.Write([]byte("siva"))
+	}))
+	defer srv.Close()
+
+	url := datasets.PGAURL
+	datasets.PGAURL = srv.URL
+	defer func() { datasets.PGAURL = url }()
+
+	dir, err := ioutil.TempDir("", "srcd-datasets")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	s := NewServer("v1.2.3", "/tmp", "linux", "", api.Config{})
+	_, err = s.StartJob(ctx, &api.StartJobRequest{Kind: api.JobFetchDataset, Name: datasets.PGA})
+	require.Equal(codes.FailedPrecondition, status.Code(err))
+
+	s.SetDatasetsDir(dir)
+	_, err = s.StartJob(ctx, &api.StartJobRequest{Kind: api.JobFetchDataset, Name: "other"})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = s.StartJob(ctx, &api.StartJobRequest{Kind: api.JobFetchDataset, Name: datasets.PGA, Workers: -1})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	info, err := s.StartJob(ctx, &api.StartJobRequest{
+		Kind:  api.JobFetchDataset,
+		Name:  datasets.PGA,
+		Langs: []string{"go"},
+	})
+	require.NoError(err)
+
+	// the same dataset can't be downloaded by two jobs at the same time
+	_, err = s.StartJob(ctx, &api.StartJobRequest{Kind: api.JobFetchDataset, Name: datasets.PGA})
+	require.Equal(codes.FailedPrecondition, status.Code(err))
+
+	close(proceed)
+	j, err := s.jobs.get(info.Id)
+	require.NoError(err)
+	info = waitJob(j)
+	require.Equal(api.Job_SUCCEEDED, info.State, info.Error)
+	require.Equal("1 repositories in 1 siva files (4B, 1 downloaded now)", info.Message)
+
+	_, err = os.Stat(filepath.Join(dir, datasets.PGA, datasets.ManifestFile))
+	require.NoError(err)
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// queryLimiter is an admission controller for the gitbase queries. Running
+// several heavy queries at the same time can make gitbase run out of memory,
+// taking down every component that depends on it.
+type queryLimiter struct {
+	// slots has a buffer of the maximum concurrent queries, nil if unlimited
+	slots     chan struct{}
+	maxQueued int
+
+	mu     sync.Mutex
+	queued int
+}
+
+// newQueryLimiter returns a limiter allowing max concurrent queries, with up
+// to maxQueued queries waiting for a slot. Negative values of max mean no
+// limit, and of maxQueued no queueing.
+func newQueryLimiter(max, maxQueued int) *queryLimiter {
+	l := &queryLimiter{maxQueued: maxQueued}
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+
+	return l
+}
+
+// acquire waits until the query can run, and returns the function to call
+// once it has finished. It fails with codes.ResourceExhausted if the queue is
+// full.
+func (l *queryLimiter) acquire(ctx context.Context) (func(), error) {
+	if l.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	l.mu.Lock()
+	if l.queued >= l.maxQueued {
+		l.mu.Unlock()
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"too many concurrent queries: %d running and %d queued, try again later",
+			cap(l.slots), l.queued)
+	}
+	l.queued++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		l.queued--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *queryLimiter) release() {
+	<-l.slots
+}
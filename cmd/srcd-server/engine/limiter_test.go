@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQueryLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	l := newQueryLimiter(1, 1)
+	ctx := context.Background()
+
+	release, err := l.acquire(ctx)
+	assert.NoError(err)
+
+	queued := make(chan error)
+	go func() {
+		release, err := l.acquire(ctx)
+		if err == nil {
+			release()
+		}
+		queued <- err
+	}()
+
+	// wait for the second query to be queued
+	for {
+		l.mu.Lock()
+		n := l.queued
+		l.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err = l.acquire(ctx)
+	assert.Equal(codes.ResourceExhausted, status.Code(err))
+
+	release()
+	assert.NoError(<-queued)
+}
+
+func TestQueryLimiterNoQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	l := newQueryLimiter(1, -1)
+	release, err := l.acquire(context.Background())
+	assert.NoError(err)
+	defer release()
+
+	_, err = l.acquire(context.Background())
+	assert.Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func TestQueryLimiterCanceled(t *testing.T) {
+	assert := assert.New(t)
+
+	l := newQueryLimiter(1, 1)
+	release, err := l.acquire(context.Background())
+	assert.NoError(err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = l.acquire(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+}
+
+func TestQueryLimiterUnlimited(t *testing.T) {
+	assert := assert.New(t)
+
+	l := newQueryLimiter(-1, -1)
+	for i := 0; i < 100; i++ {
+		_, err := l.acquire(context.Background())
+		assert.NoError(err)
+	}
+}
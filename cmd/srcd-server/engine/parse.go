@@ -7,19 +7,13 @@ import (
 	"strings"
 
 	bblfsh "github.com/bblfsh/go-client/v4"
-	"github.com/bblfsh/go-client/v4/tools"
-	"github.com/docker/docker/api/types/container"
 	"github.com/pkg/errors"
 	"github.com/src-d/engine/api"
-	"github.com/src-d/engine/components"
-	"github.com/src-d/engine/docker"
+	sdk "github.com/src-d/engine/engine"
 	"gopkg.in/bblfsh/sdk.v2/uast/nodes"
-	enry "gopkg.in/src-d/enry.v1"
 	"gopkg.in/src-d/go-log.v1"
 )
 
-var bblfshd = components.Bblfshd
-
 type logf func(format string, args ...interface{})
 
 func (s *Server) ParseWithLogs(req *api.ParseRequest, stream api.Engine_ParseWithLogsServer) error {
@@ -47,92 +41,129 @@ func (s *Server) Parse(ctx context.Context, req *api.ParseRequest) (*api.ParseRe
 
 func (s *Server) parse(ctx context.Context, req *api.ParseRequest, log logf) (*api.ParseResponse, error) {
 	log("got parse request")
-	lang := req.Lang
-	if lang == "" {
-		lang = enry.GetLanguage(req.Name, req.Content)
-	}
-	lang = strings.ToLower(lang)
 	if req.Kind == api.ParseRequest_LANG {
+		lang := strings.ToLower(req.Lang)
+		if lang == "" {
+			lang = sdk.DetectLanguage(req.Name, req.Content)
+		}
+
 		return &api.ParseResponse{Lang: lang}, nil
 	}
 
-	// TODO(campoy): this should be a bit more flexible, might need to a table somewhere.
-
-	if err := s.startComponent(ctx, bblfshd.Name); err != nil {
+	leave, err := s.drain.enter()
+	if err != nil {
 		return nil, err
 	}
+	defer leave()
 
-	addr := fmt.Sprintf("%s:%d", bblfshd.Name, components.BblfshParsePort)
-	log("connecting to bblfsh parsing on %s", addr)
-	client, err := bblfsh.NewClient(addr)
+	release, err := s.quotas.parse(s.quotaUser(ctx))
 	if err != nil {
-		return nil, errors.Wrap(err, "could not connect to bblfsh")
+		return nil, err
 	}
+	defer release()
 
-	mode := bblfsh.Semantic
-	switch req.Mode {
-	case api.ParseRequest_ANNOTATED:
-		mode = bblfsh.Annotated
-	case api.ParseRequest_NATIVE:
-		mode = bblfsh.Native
+	res, err := s.engine.ParseUAST(ctx, parseRequest(req, log))
+	if err != nil {
+		return nil, err
 	}
 
-	res, _, err := client.NewParseRequest().
-		Language(lang).
-		Content(string(req.Content)).
-		Filename(req.Name).
-		Context(ctx).
-		Mode(mode).
-		UAST()
+	uast, err := marshalNodes(res.Nodes)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not parse")
+		return nil, err
 	}
 
-	var ns = []nodes.Node{res}
-	if req.Query != "" {
-		var filtered nodes.Array
-		iter, err := tools.Filter(res, req.Query)
-		if err != nil {
-			return nil, errors.Wrapf(err, "could not apply query %s", req.Query)
-		}
-		for iter.Next() {
-			filtered = append(filtered, iter.Node().(nodes.Node))
+	return &api.ParseResponse{Kind: api.ParseResponse_FINAL, Lang: res.Lang, Uast: uast}, nil
+}
+
+// ParseBatch parses all the files of the request with the same connection to
+// bblfshd, returning an error only if none of them can be parsed. The whole
+// batch counts as a single parse request for the quotas of the user.
+func (s *Server) ParseBatch(ctx context.Context, req *api.ParseBatchRequest) (*api.ParseBatchResponse, error) {
+	log.Infof("got parse batch request with %d files", len(req.Files))
+
+	results := make([]*api.ParseBatchResponse_Result, len(req.Files))
+	var uastReqs []sdk.ParseRequest
+	var uastIdx []int
+	for i, f := range req.Files {
+		results[i] = &api.ParseBatchResponse_Result{Name: f.Name}
+		switch f.Kind {
+		case api.ParseRequest_LANG:
+			results[i].Lang = strings.ToLower(f.Lang)
+			if results[i].Lang == "" {
+				results[i].Lang = sdk.DetectLanguage(f.Name, f.Content)
+			}
+		case api.ParseRequest_UAST:
+			uastReqs = append(uastReqs, parseRequest(f, log.Infof))
+			uastIdx = append(uastIdx, i)
+		default:
+			results[i].Error = fmt.Sprintf("invalid parse kind %s", f.Kind)
 		}
-		ns = filtered
 	}
 
-	resp := &api.ParseResponse{Kind: api.ParseResponse_FINAL, Lang: lang}
-	for _, node := range ns {
-		uast, err := json.MarshalIndent(node, "", "  ")
-		if err != nil {
-			return nil, errors.Wrap(err, "could not marshal uast")
-		}
-		resp.Uast = append(resp.Uast, uast)
+	leave, err := s.drain.enter()
+	if err != nil {
+		return nil, err
 	}
-	return resp, nil
-}
+	defer leave()
 
-func createBbblfshd(opts ...docker.ConfigOption) docker.StartFunc {
-	return func(ctx context.Context) error {
-		if err := docker.EnsureInstalled(bblfshd.Image, bblfshd.Version); err != nil {
-			return err
-		}
+	release, err := s.quotas.parse(s.quotaUser(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-		log.Infof("starting bblfshd daemon")
+	parsed, err := s.engine.ParseUASTBatch(ctx, uastReqs)
+	if err != nil {
+		return nil, err
+	}
 
-		ctx, cancel := context.WithTimeout(ctx, startComponentTimeout)
-		defer cancel()
+	for j, res := range parsed {
+		r := results[uastIdx[j]]
+		if res.Err != nil {
+			r.Error = res.Err.Error()
+			continue
+		}
 
-		config := &container.Config{
-			Image: bblfshd.ImageWithVersion(),
-			Cmd: []string{
-				fmt.Sprintf("-ctl-address=0.0.0.0:%d", components.BblfshControlPort),
-				"-ctl-network=tcp"},
+		r.Lang = res.Response.Lang
+		r.Uast, err = marshalNodes(res.Response.Nodes)
+		if err != nil {
+			r.Error = err.Error()
 		}
+	}
 
-		host := &container.HostConfig{Privileged: true}
-		docker.ApplyOptions(config, host, opts...)
+	return &api.ParseBatchResponse{Results: results}, nil
+}
 
-		return docker.Start(ctx, config, host, bblfshd.Name)
+// parseRequest converts the API request of an UAST to the engine one
+func parseRequest(req *api.ParseRequest, log logf) sdk.ParseRequest {
+	mode := bblfsh.Semantic
+	switch req.Mode {
+	case api.ParseRequest_ANNOTATED:
+		mode = bblfsh.Annotated
+	case api.ParseRequest_NATIVE:
+		mode = bblfsh.Native
 	}
+
+	return sdk.ParseRequest{
+		Name:    req.Name,
+		Content: req.Content,
+		Lang:    req.Lang,
+		Query:   req.Query,
+		Mode:    mode,
+		Logf:    log,
+	}
+}
+
+// marshalNodes encodes each one of the nodes as indented JSON
+func marshalNodes(ns []nodes.Node) ([][]byte, error) {
+	var uast [][]byte
+	for _, node := range ns {
+		b, err := json.MarshalIndent(node, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(err, "could not marshal uast")
+		}
+		uast = append(uast, b)
+	}
+
+	return uast, nil
 }
@@ -0,0 +1,266 @@
+package engine
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// fingerprintQuery lists the references of all the repositories, which
+// change whenever a repository is added, removed or updated
+const fingerprintQuery = "SELECT repository_id, ref_name, commit_hash FROM refs"
+
+// cacheableRegexp matches the read-only statements, whose results are cached
+var cacheableRegexp = regexp.MustCompile(`(?i)^\s*(select|show|describe|desc|explain|with)\b`)
+
+// volatileRegexp matches the functions whose result changes between runs of
+// the same query
+var volatileRegexp = regexp.MustCompile(`(?i)\b(now|rand|uuid|sysdate|curdate|curtime|unix_timestamp|connection_id|sleep)\s*\(|\bcurrent_(date|time|timestamp|user)\b`)
+
+// isCacheable returns whether the result of the query only depends on the
+// repositories
+func isCacheable(query string) bool {
+	return cacheableRegexp.MatchString(query) && !volatileRegexp.MatchString(query)
+}
+
+// normalizeQuery returns the query without the spaces and trailing
+// semicolons that don't change its result, so the same query written
+// differently uses the same cached result. The quoted strings are kept
+func normalizeQuery(query string) string {
+	var b strings.Builder
+	var quote byte
+	space := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else if ch == '\\' && i+1 < len(query) {
+				b.WriteByte(ch)
+				i++
+				ch = query[i]
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			space = true
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+
+		space = false
+		b.WriteByte(ch)
+	}
+
+	return strings.TrimRight(b.String(), "; ")
+}
+
+// queryCacheKey returns the key of the result of the query, with the given
+// maximum of rows, in the repositories with the given fingerprint
+func queryCacheKey(query string, maxRows int64, fingerprint string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s", normalizeQuery(query), maxRows, fingerprint)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// queryCache keeps the results of the queries in memory, up to maxBytes, for
+// ttl. The least recently used ones are removed first to make room
+type queryCache struct {
+	maxBytes int64
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	bytes   int64
+}
+
+// cachedResult is the result of a query, where the first row are the column
+// names, and whether it was truncated
+type cachedResult struct {
+	key       string
+	rows      [][][]byte
+	truncated bool
+	bytes     int64
+	expires   time.Time
+}
+
+// newQueryCache returns a cache of up to maxBytes, or nil if it is 0
+func newQueryCache(maxBytes int64, ttl time.Duration) *queryCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+
+	return &queryCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// get returns the result with the given key, if it is cached and has not
+// expired
+func (c *queryCache) get(key string) (*cachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	r := e.Value.(*cachedResult)
+	if c.now().After(r.expires) {
+		c.remove(e)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+	return r, true
+}
+
+// put caches the result with the given key, removing the least recently used
+// ones until it fits. Results larger than the cache are not kept
+func (c *queryCache) put(key string, rows [][][]byte, truncated bool) {
+	size := rowsSize(rows)
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+
+	for c.bytes+size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+
+	c.entries[key] = c.lru.PushFront(&cachedResult{
+		key:       key,
+		rows:      rows,
+		truncated: truncated,
+		bytes:     size,
+		expires:   c.now().Add(c.ttl),
+	})
+	c.bytes += size
+}
+
+func (c *queryCache) remove(e *list.Element) {
+	r := c.lru.Remove(e).(*cachedResult)
+	delete(c.entries, r.key)
+	c.bytes -= r.bytes
+}
+
+func rowsSize(rows [][][]byte) int64 {
+	var size int64
+	for _, row := range rows {
+		for _, cell := range row {
+			size += int64(len(cell))
+		}
+	}
+
+	return size
+}
+
+// cachedSQL runs the query like runSQL, reusing the result of the same
+// query while the repositories have the same references. The references are
+// read before each query, and if they can't be the query is not cached
+func (s *Server) cachedSQL(
+	ctx context.Context,
+	query string,
+	maxRows int64,
+	send func(row [][]byte) error,
+) (bool, error) {
+	fingerprint, err := s.repositoriesFingerprint(ctx)
+	if err != nil {
+		log.Debugf("the query result is not cached: %s", err)
+		return s.runSQL(ctx, query, maxRows, send)
+	}
+
+	key := queryCacheKey(query, maxRows, fingerprint)
+	if r, ok := s.cache.get(key); ok {
+		log.Debugf("sending the cached result of the query")
+		for _, row := range r.rows {
+			if err := send(row); err != nil {
+				return false, err
+			}
+		}
+
+		return r.truncated, nil
+	}
+
+	// the rows are only kept while they fit in the cache
+	keep := true
+	var rows [][][]byte
+	var size int64
+	truncated, err := s.runSQL(ctx, query, maxRows, func(row [][]byte) error {
+		if keep {
+			size += rowsSize([][][]byte{row})
+			rows = append(rows, row)
+			if keep = size <= s.cache.maxBytes; !keep {
+				rows = nil
+			}
+		}
+
+		return send(row)
+	})
+
+	if err == nil && keep {
+		s.cache.put(key, rows, truncated)
+	}
+
+	return truncated, err
+}
+
+// repositoriesFingerprint returns a hash of the references of all the
+// repositories
+func (s *Server) repositoriesFingerprint(ctx context.Context) (string, error) {
+	rows, err := s.engine.Query(ctx, fingerprintQuery)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var id, name, hash string
+	var refs []string
+	for rows.Next() {
+		if err := rows.Scan(&id, &name, &hash); err != nil {
+			return "", errors.Wrap(err, "could not scan row")
+		}
+
+		refs = append(refs, id+"\x00"+name+"\x00"+hash)
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", errors.Wrap(err, "could not read the references")
+	}
+
+	// the repositories are not always read in the same order
+	sort.Strings(refs)
+	h := sha256.New()
+	for _, ref := range refs {
+		fmt.Fprintln(h, ref)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
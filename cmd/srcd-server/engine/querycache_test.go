@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCacheable(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, q := range []string{
+		"SELECT * FROM repositories",
+		"  select 1",
+		"SHOW TABLES",
+		"DESCRIBE TABLE refs",
+		"WITH r AS (SELECT 1) SELECT * FROM r",
+	} {
+		assert.True(isCacheable(q), q)
+	}
+
+	for _, q := range []string{
+		"CREATE INDEX i ON refs USING pilosa (ref_name)",
+		"DROP INDEX i ON refs",
+		"SELECT NOW()",
+		"SELECT * FROM commits WHERE committer_when > current_date",
+		"SELECT RAND ()",
+		"selection",
+	} {
+		assert.False(isCacheable(q), q)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("SELECT * FROM refs WHERE ref_name = 'HEAD'",
+		normalizeQuery("\n  SELECT *\n\tFROM   refs WHERE ref_name = 'HEAD' ;\n"))
+	assert.Equal(`SELECT 'a  b', "c\"  d"`, normalizeQuery(`SELECT 'a  b',  "c\"  d";`))
+	assert.Equal(queryCacheKey("SELECT 1;", 0, "f"), queryCacheKey(" SELECT  1", 0, "f"))
+	assert.NotEqual(queryCacheKey("SELECT 1", 0, "f"), queryCacheKey("SELECT 1", 10, "f"))
+	assert.NotEqual(queryCacheKey("SELECT 1", 0, "f"), queryCacheKey("SELECT 1", 0, "g"))
+}
+
+func TestQueryCache(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(newQueryCache(0, time.Minute))
+
+	now := time.Now()
+	c := newQueryCache(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	row := func(s string) [][]byte { return [][]byte{[]byte(s)} }
+
+	c.put("a", [][][]byte{row("col"), row("aa")}, false)
+	c.put("b", [][][]byte{row("col"), row("bb")}, true)
+	r, ok := c.get("b")
+	assert.True(ok)
+	assert.True(r.truncated)
+	assert.Equal(int64(10), c.bytes)
+
+	// a is the least recently used
+	c.put("c", [][][]byte{row("col")}, false)
+	_, ok = c.get("a")
+	assert.False(ok)
+	_, ok = c.get("b")
+	assert.True(ok)
+	_, ok = c.get("c")
+	assert.True(ok)
+
+	// larger than the cache
+	c.put("d", [][][]byte{row("0123456789a")}, false)
+	_, ok = c.get("d")
+	assert.False(ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.get("b")
+	assert.False(ok)
+	assert.Equal(int64(3), c.bytes)
+}
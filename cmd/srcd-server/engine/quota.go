@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/src-d/engine/audit"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// quotaWindow is the period of the queries per minute quota
+const quotaWindow = time.Minute
+
+// userQuotas enforces the limits of each user of the daemon API, so a single
+// runaway client, like a notebook running queries in a loop, can't take over
+// a daemon shared by a team. See api.Config.UserLimits
+type userQuotas struct {
+	// limits returns the queries per minute and concurrent parses allowed to
+	// a user, 0 if there is no limit
+	limits func(user string) (int, int)
+	now    func() time.Time
+
+	mu sync.Mutex
+	// queries are the times of the queries run by each user in the last
+	// quotaWindow, oldest first
+	queries map[string][]time.Time
+	// parses are the parse requests each user is running
+	parses map[string]int
+}
+
+func newUserQuotas(limits func(user string) (int, int)) *userQuotas {
+	return &userQuotas{
+		limits:  limits,
+		now:     time.Now,
+		queries: make(map[string][]time.Time),
+		parses:  make(map[string]int),
+	}
+}
+
+// query counts a query of the user, failing with codes.ResourceExhausted if
+// it has already run all the ones allowed in the last minute.
+func (q *userQuotas) query(user string) error {
+	max, _ := q.limits(user)
+	if max <= 0 {
+		return nil
+	}
+
+	now := q.now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	times := q.queries[user]
+	for len(times) > 0 && now.Sub(times[0]) >= quotaWindow {
+		times = times[1:]
+	}
+
+	if len(times) >= max {
+		q.queries[user] = times
+		wait := quotaWindow - now.Sub(times[0])
+		return status.Errorf(codes.ResourceExhausted,
+			"the user %s has run its limit of %d queries per minute, try again in %s",
+			user, max, wait.Round(time.Second))
+	}
+
+	q.queries[user] = append(times, now)
+	return nil
+}
+
+// parse counts a parse request of the user, and returns the function to call
+// once it has finished. It fails with codes.ResourceExhausted if the user is
+// already running all the ones allowed.
+func (q *userQuotas) parse(user string) (func(), error) {
+	_, max := q.limits(user)
+	if max <= 0 {
+		return func() {}, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.parses[user] >= max {
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"the user %s is already running its limit of %d concurrent parse requests, "+
+				"wait for them to finish", user, max)
+	}
+
+	q.parses[user]++
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		q.parses[user]--
+		if q.parses[user] <= 0 {
+			delete(q.parses, user)
+		}
+	}, nil
+}
+
+// quotaUser returns the user the quotas of a request are counted for: the
+// authenticated one when Daemon.Auth has users, and the user and host sent
+// by the client, or its IP address, otherwise.
+func (s *Server) quotaUser(ctx context.Context) string {
+	rec := audit.FromContext(ctx, "")
+	if s.config.AuthEnabled() {
+		return rec.User
+	}
+
+	if rec.User != "" {
+		return rec.Who()
+	}
+
+	if host, _, err := net.SplitHostPort(rec.Addr); err == nil {
+		return host
+	}
+
+	return rec.Who()
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUserQuotasQuery(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := newUserQuotas(func(user string) (int, int) {
+		if user == "bob" {
+			return 0, 0
+		}
+
+		return 2, 0
+	})
+	q.now = func() time.Time { return now }
+
+	require.NoError(q.query("alice"))
+	now = now.Add(20 * time.Second)
+	require.NoError(q.query("alice"))
+
+	err := q.query("alice")
+	require.Equal(codes.ResourceExhausted, status.Code(err))
+	require.Contains(err.Error(), "limit of 2 queries per minute, try again in 40s")
+
+	// the quotas are counted for each user
+	for i := 0; i < 5; i++ {
+		require.NoError(q.query("bob"))
+	}
+	require.NoError(q.query("carol"))
+
+	// the first query leaves the window
+	now = now.Add(40 * time.Second)
+	require.NoError(q.query("alice"))
+	require.Error(q.query("alice"))
+}
+
+func TestUserQuotasParse(t *testing.T) {
+	require := require.New(t)
+
+	q := newUserQuotas(func(string) (int, int) { return 0, 1 })
+
+	release, err := q.parse("alice")
+	require.NoError(err)
+
+	_, err = q.parse("alice")
+	require.Equal(codes.ResourceExhausted, status.Code(err))
+
+	other, err := q.parse("bob")
+	require.NoError(err)
+	other()
+
+	release()
+	release, err = q.parse("alice")
+	require.NoError(err)
+	release()
+	require.Empty(q.parses)
+}
+
+func TestQuotaUser(t *testing.T) {
+	require := require.New(t)
+
+	s := NewServer("v1.2.3", "/tmp", "linux", "", api.Config{})
+
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(audit.UserKey, "alice", audit.HostKey, "laptop"))
+	require.Equal("alice@laptop", s.quotaUser(ctx))
+
+	s.config.Daemon.Auth.Users = map[string]api.AuthUser{"alice": {Role: api.RoleAnalyst}}
+	require.Equal("alice", s.quotaUser(ctx))
+}
@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+	sdk "github.com/src-d/engine/engine"
+)
+
+func (s *Server) Search(req *api.SearchRequest, stream api.Engine_SearchServer) error {
+	var repos []string
+	for _, r := range strings.Split(req.Repos, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			repos = append(repos, r)
+		}
+	}
+
+	err := s.engine.Search(stream.Context(), sdk.SearchRequest{
+		Pattern:    req.Pattern,
+		Lang:       req.Lang,
+		IgnoreCase: req.IgnoreCase,
+		Repos:      repos,
+	}, func(m sdk.SearchMatch) error {
+		return stream.Send(&api.SearchResponse{
+			Repository: m.Repository,
+			File:       m.File,
+			LineNumber: int32(m.LineNumber),
+			Line:       m.Line,
+		})
+	})
+
+	s.record(stream.Context(), audit.ActionSearch, audit.Record{Hash: audit.Hash(req.Pattern)}, err)
+	return err
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/src-d/engine/audit"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// errShuttingDown is returned to the requests that arrive once the daemon
+// has started to shut down
+var errShuttingDown = status.Error(codes.Unavailable, "the daemon is shutting down")
+
+// drain tracks the SQL and parse requests running in the daemon, so it can
+// wait for them to finish before it stops
+type drain struct {
+	mu      sync.Mutex
+	closed  bool
+	running int
+	// idle is closed once the drain is closed and no requests are running
+	idle chan struct{}
+}
+
+func newDrain() *drain {
+	return &drain{idle: make(chan struct{})}
+}
+
+// enter counts a new request, and returns the function to call when it
+// finishes. It fails with errShuttingDown if the drain is closed
+func (d *drain) enter() (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, errShuttingDown
+	}
+
+	d.running++
+	var once sync.Once
+	return func() { once.Do(d.leave) }, nil
+}
+
+func (d *drain) leave() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.running--
+	if d.closed && d.running == 0 {
+		close(d.idle)
+	}
+}
+
+// close makes enter fail from now on, and returns a channel closed when the
+// running requests finish
+func (d *drain) close() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.closed {
+		d.closed = true
+		if d.running == 0 {
+			close(d.idle)
+		}
+	}
+
+	return d.idle
+}
+
+// isClosed returns whether close was called
+func (d *drain) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+// count returns the number of running requests
+func (d *drain) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.running
+}
+
+// Shutdown prepares the daemon to stop. The new SQL and parse requests and
+// jobs are rejected with codes.Unavailable, and the running requests are
+// given until ctx is done to finish. Then the running jobs are cancelled, and
+// the shutdown is recorded in the audit log, which is synced to disk. It
+// returns an error if some requests were still running when ctx was done,
+// they fail once the API server is stopped.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var err error
+	select {
+	case <-s.drain.close():
+	case <-ctx.Done():
+		err = fmt.Errorf("%d SQL or parse requests did not finish in time", s.drain.count())
+	}
+
+	cancelled := s.jobs.cancelAll()
+	if s.audit == nil {
+		return err
+	}
+
+	rec := audit.Record{
+		Action:  audit.ActionDaemonStopped,
+		Message: fmt.Sprintf("%d jobs cancelled", cancelled),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	if werr := s.audit.Write(rec); werr != nil {
+		log.Errorf(werr, "could not write the audit log")
+	}
+
+	if serr := s.audit.Sync(); serr != nil {
+		log.Errorf(serr, "could not sync the audit log")
+	}
+
+	return err
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDrain(t *testing.T) {
+	require := require.New(t)
+
+	d := newDrain()
+	leave, err := d.enter()
+	require.NoError(err)
+
+	idle := d.close()
+	_, err = d.enter()
+	require.Equal(codes.Unavailable, status.Code(err))
+
+	select {
+	case <-idle:
+		t.Fatal("the drain is idle with a running request")
+	default:
+	}
+
+	leave()
+	leave()
+	<-idle
+	require.Equal(0, d.count())
+
+	// closing it again doesn't close idle twice
+	<-d.close()
+}
+
+func TestShutdown(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-shutdown")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	l, err := audit.Open(dir, 1<<20, 1)
+	require.NoError(err)
+	defer l.Close()
+
+	s := NewServer("v1.2.3", "/tmp", "linux", "", api.Config{})
+	s.SetAuditLog(l)
+
+	leave, err := s.drain.enter()
+	require.NoError(err)
+
+	j := s.jobs.start(context.Background(), api.JobCreateIndex, "", "alice",
+		func(ctx context.Context, progress func(string)) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+
+	done := make(chan error)
+	go func() { done <- s.Shutdown(context.Background()) }()
+
+	// the running requests are waited for, and the new ones rejected
+	time.Sleep(10 * time.Millisecond)
+	_, err = s.StartJob(context.Background(), &api.StartJobRequest{Kind: api.JobCreateIndex})
+	require.Equal(codes.Unavailable, status.Code(err))
+	info, _ := j.state()
+	require.False(info.Done())
+
+	leave()
+	require.NoError(<-done)
+	require.Equal(api.Job_CANCELED, waitJob(j).State)
+
+	records, err := audit.Tail(dir, 1)
+	require.NoError(err)
+	require.Len(records, 1)
+	require.Equal(audit.ActionDaemonStopped, records[0].Action)
+	require.Equal("1 jobs cancelled", records[0].Message)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	require := require.New(t)
+
+	s := NewServer("v1.2.3", "/tmp", "linux", "", api.Config{})
+	leave, err := s.drain.enter()
+	require.NoError(err)
+	defer leave()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.EqualError(s.Shutdown(ctx), "1 SQL or parse requests did not finish in time")
+}
@@ -3,52 +3,103 @@ package engine
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"database/sql"
-
-	"github.com/docker/docker/api/types/container"
-	"github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
 	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
 	"github.com/src-d/engine/components"
-	"github.com/src-d/engine/docker"
-	"gopkg.in/src-d/go-log.v1"
-)
+	sdk "github.com/src-d/engine/engine"
 
-const (
-	gitbaseMountPath      = "/opt/repos"
-	gitbaseIndexMountPath = "/var/lib/gitbase/index"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-var (
-	gitbase = components.Gitbase
-)
+// sqlBatchMaxBytes is the size after which a batch of rows is sent even if it
+// has less than the requested batch size, to stay well below the default gRPC
+// message size limit of 4 MiB
+const sqlBatchMaxBytes = 1 << 20
 
+// SQL streams the query result. The rows are read from gitbase only as fast as
+// they can be sent, since stream.Send blocks when the client is not consuming
+// them, so the daemon never holds more than one batch in memory.
 func (s *Server) SQL(req *api.SQLRequest, stream api.Engine_SQLServer) error {
-	err := s.startComponent(stream.Context(), gitbase.Name)
+	if req.MaxRows < 0 {
+		return fmt.Errorf("max rows can't be negative")
+	}
+	if req.BatchSize < 0 {
+		return fmt.Errorf("batch size can't be negative")
+	}
+
+	b := newRowBatcher(int(req.BatchSize), stream.Send)
+	truncated, err := s.sql(stream.Context(), req.Query, req.MaxRows, b.add)
 	if err != nil {
 		return err
 	}
 
-	cfg := mysql.Config{
-		User:                 "root",
-		Net:                  "tcp",
-		Addr:                 gitbase.Name,
-		AllowNativePasswords: true,
-		MaxAllowedPacket:     32 << 20, // 32 MiB
+	return b.close(truncated)
+}
+
+// sql runs the query in gitbase, calling send first with the column names and
+// then with each one of the result rows. If maxRows is not 0, it stops after
+// that many rows, and returns true if the result had more. The query fails if
+// the daemon is shutting down or the user has run its limit of queries per
+// minute, and waits for a free slot if the daemon is already running its
+// maximum of queries.
+// The results of the read-only queries are cached if the cache is enabled.
+// If gitbase dies while running it, the error explains why.
+func (s *Server) sql(
+	ctx context.Context,
+	query string,
+	maxRows int64,
+	send func(row [][]byte) error,
+) (bool, error) {
+	leave, err := s.drain.enter()
+	if err != nil {
+		return false, err
 	}
-	log.Infof("connecting to mysql %q", cfg.FormatDSN())
-	db, err := sql.Open("mysql", cfg.FormatDSN())
+	defer leave()
+
+	if err := s.quotas.query(s.quotaUser(ctx)); err != nil {
+		return false, err
+	}
+
+	release, err := s.queries.acquire(ctx)
 	if err != nil {
-		return errors.Wrap(err, "could not connect to gitbase")
+		return false, err
+	}
+	defer release()
+
+	run := s.runSQL
+	if s.cache != nil && isCacheable(query) {
+		run = s.cachedSQL
+	}
+
+	start := time.Now()
+	truncated, err := run(ctx, query, maxRows, send)
+	err = s.engine.ExitError(components.Gitbase.Name, start, err)
+	s.record(ctx, audit.ActionSQL, audit.Record{Hash: audit.Hash(query)}, err)
+	return truncated, err
+}
+
+func (s *Server) runSQL(
+	ctx context.Context,
+	query string,
+	maxRows int64,
+	send func(row [][]byte) error,
+) (bool, error) {
+	rows, err := s.engine.Query(ctx, query)
+	if _, ok := err.(*sdk.ShardedQueryError); ok {
+		return false, status.Error(codes.InvalidArgument, err.Error())
 	}
-	rows, err := db.Query(req.Query)
 	if err != nil {
-		return errors.Wrap(err, "SQL query failed")
+		return false, err
 	}
+	defer rows.Close()
+
 	columns, err := rows.Columns()
 	if err != nil {
-		return errors.Wrap(err, "could not fetch columns")
+		return false, errors.Wrap(err, "could not fetch columns")
 	}
 
 	columnsBytes := make([][]byte, len(columns))
@@ -56,52 +107,86 @@ func (s *Server) SQL(req *api.SQLRequest, stream api.Engine_SQLServer) error {
 		columnsBytes[i] = []byte(c)
 	}
 
-	if err := stream.Send(&api.SQLResponse{
-		Row: &api.SQLResponse_Row{Cell: columnsBytes},
-	}); err != nil {
-		return err
+	if err := send(columnsBytes); err != nil {
+		return false, err
 	}
 
 	values := make([]interface{}, len(columns))
 	for i := range values {
 		values[i] = new([]byte)
 	}
+
+	var n int64
 	for rows.Next() {
+		if maxRows > 0 && n == maxRows {
+			return true, nil
+		}
+
 		if err := rows.Scan(values...); err != nil {
-			return errors.Wrap(err, "could not scan row")
+			return false, errors.Wrap(err, "could not scan row")
 		}
-		row := &api.SQLResponse_Row{}
+		var row [][]byte
 		for _, v := range values {
-			row.Cell = append(row.Cell, *v.(*[]byte))
+			row = append(row, *v.(*[]byte))
 		}
-		if err := stream.Send(&api.SQLResponse{
-			Row: row,
-		}); err != nil {
-			return err
+		if err := send(row); err != nil {
+			return false, err
 		}
+
+		n++
 	}
 
-	return errors.Wrap(rows.Err(), "closing row iterator")
+	return false, errors.Wrap(rows.Err(), "closing row iterator")
 }
 
-func createGitbase(opts ...docker.ConfigOption) docker.StartFunc {
-	return func(ctx context.Context) error {
-		if err := docker.EnsureInstalled(gitbase.Image, gitbase.Version); err != nil {
-			return err
-		}
+// rowBatcher groups the result rows of a query into SQLResponses. The first
+// row, with the column names, is always sent alone.
+type rowBatcher struct {
+	size    int
+	send    func(*api.SQLResponse) error
+	started bool
+	batch   []*api.SQLResponse_Row
+	bytes   int
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), startComponentTimeout)
-		defer cancel()
+// newRowBatcher returns a rowBatcher sending batches of up to size rows. If
+// size is 0, each row is sent in the Row field of its own response.
+func newRowBatcher(size int, send func(*api.SQLResponse) error) *rowBatcher {
+	return &rowBatcher{size: size, send: send}
+}
 
-		config := &container.Config{
-			Image: gitbase.ImageWithVersion(),
-			Env: []string{
-				fmt.Sprintf("BBLFSH_ENDPOINT=%s:%d", bblfshd.Name, components.BblfshParsePort),
-			},
-		}
-		host := &container.HostConfig{}
-		docker.ApplyOptions(config, host, opts...)
+func (b *rowBatcher) add(cells [][]byte) error {
+	row := &api.SQLResponse_Row{Cell: cells}
+	if !b.started || b.size == 0 {
+		b.started = true
+		return b.send(&api.SQLResponse{Row: row})
+	}
 
-		return docker.Start(ctx, config, host, gitbase.Name)
+	b.batch = append(b.batch, row)
+	for _, c := range cells {
+		b.bytes += len(c)
 	}
+
+	if len(b.batch) >= b.size || b.bytes >= sqlBatchMaxBytes {
+		return b.flush(false)
+	}
+
+	return nil
+}
+
+func (b *rowBatcher) flush(truncated bool) error {
+	if len(b.batch) == 0 && !truncated {
+		return nil
+	}
+
+	err := b.send(&api.SQLResponse{Rows: b.batch, Truncated: truncated})
+	b.batch = nil
+	b.bytes = 0
+	return err
+}
+
+// close sends the pending rows, setting Truncated in the last response if
+// truncated is true.
+func (b *rowBatcher) close(truncated bool) error {
+	return b.flush(truncated)
 }
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRowBatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	var sent []*api.SQLResponse
+	b := newRowBatcher(2, func(r *api.SQLResponse) error {
+		sent = append(sent, r)
+		return nil
+	})
+
+	for _, row := range []string{"columns", "a", "b", "c"} {
+		assert.NoError(b.add([][]byte{[]byte(row)}))
+	}
+	assert.NoError(b.close(true))
+
+	assert.Len(sent, 3)
+	assert.Equal("columns", string(sent[0].Row.Cell[0]))
+	assert.Len(sent[1].Rows, 2)
+	assert.False(sent[1].Truncated)
+	assert.Len(sent[2].Rows, 1)
+	assert.Equal("c", string(sent[2].Rows[0].Cell[0]))
+	assert.True(sent[2].Truncated)
+}
+
+func TestRowBatcherMaxBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	var sent []*api.SQLResponse
+	b := newRowBatcher(100, func(r *api.SQLResponse) error {
+		sent = append(sent, r)
+		return nil
+	})
+
+	blob := bytes.Repeat([]byte("x"), sqlBatchMaxBytes/2)
+	// the first row is the one with the column names
+	for i := 0; i < 6; i++ {
+		assert.NoError(b.add([][]byte{blob}))
+	}
+	assert.NoError(b.close(false))
+
+	// columns, then 2 full batches of 2 blobs each, and the remaining one
+	assert.Len(sent, 4)
+	assert.Len(sent[1].Rows, 2)
+	assert.Len(sent[3].Rows, 1)
+}
+
+func TestRowBatcherNoBatching(t *testing.T) {
+	assert := assert.New(t)
+
+	var sent []*api.SQLResponse
+	b := newRowBatcher(0, func(r *api.SQLResponse) error {
+		sent = append(sent, r)
+		return nil
+	})
+
+	assert.NoError(b.add([][]byte{[]byte("columns")}))
+	assert.NoError(b.add([][]byte{[]byte("a")}))
+	assert.NoError(b.close(false))
+
+	assert.Len(sent, 2)
+	assert.Equal("a", string(sent[1].Row.Cell[0]))
+	assert.Empty(sent[1].Rows)
+}
@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
 	"github.com/src-d/engine/cmd/srcd-server/engine"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"github.com/src-d/engine/logging"
+	"github.com/src-d/engine/tracing"
 
+	"github.com/docker/docker/api/types"
 	"github.com/pkg/errors"
 	grpc "google.golang.org/grpc"
 	"gopkg.in/src-d/go-cli.v0"
@@ -21,6 +34,14 @@ var (
 	build   = "dev"
 )
 
+// healthCheckInterval is how often the daemon checks that the running
+// components did not exit
+const healthCheckInterval = 10 * time.Second
+
+// imageCollectionInterval is how often the daemon removes the old images of
+// the components, following images.keep in the config
+const imageCollectionInterval = 24 * time.Hour
+
 func main() {
 	cmd := cli.New("srcd-server", version, build, "The Code as Data solution by source{d}")
 	cmd.AddCommand(&serveCmd{})
@@ -31,10 +52,22 @@ func main() {
 type serveCmd struct {
 	cli.Command `name:"serve" short-description:"Start the server" long-description:"Start the server"`
 
-	Addr    string `long:"address" short:"a" default:"0.0.0.0:4242"`
-	Workdir string `long:"workdir" short:"w" default:""`
-	HostOS  string `long:"host-os" default:""`
-	Config  string `long:"config" short:"c" default:""`
+	Addr     string `long:"address" short:"a" default:"0.0.0.0:4242"`
+	HTTPAddr string `long:"http-address" default:"" description:"address for the REST/JSON gateway, disabled if empty"`
+	Workdir  string `long:"workdir" short:"w" default:""`
+	HostOS   string `long:"host-os" default:""`
+	Config   string `long:"config" short:"c" default:""`
+	Profile  string `long:"profile" default:"" description:"profile of the containers, volumes and network the daemon manages"`
+	// UASTCacheDir is a volume mounted by srcd init
+	UASTCacheDir string `long:"uast-cache-dir" default:"" description:"directory where the parsed UASTs are cached, disabled if empty"`
+	// AuditDir is a directory of the host shared by srcd init
+	AuditDir string `long:"audit-dir" default:"" description:"directory of the audit log of the orchestration actions, disabled if empty"`
+	// DatasetsDir is a directory of the host shared by srcd init too
+	DatasetsDir string `long:"datasets-dir" default:"" description:"directory where the fetch-dataset jobs download the public datasets, disabled if empty"`
+	// RegistryAuth is read from the environment, so the credentials are not
+	// part of the command line
+	RegistryAuth string   `long:"registry-auth" env:"SRCD_REGISTRY_AUTH" default:"" description:"credentials of the registries of the component images, as a JSON object by registry"`
+	Verbose      []string `long:"verbose" env:"SRCD_VERBOSE" env-delim:"," description:"log level of a subsystem, in the form subsystem[:level], can be repeated"`
 }
 
 func (c *serveCmd) Execute(args []string) error {
@@ -43,6 +76,26 @@ func (c *serveCmd) Execute(args []string) error {
 		return fmt.Errorf("No work directory provided!")
 	}
 
+	if err := components.SetProfile(c.Profile); err != nil {
+		return err
+	}
+
+	levels, err := logging.ParseLevels(c.Verbose)
+	if err != nil {
+		return err
+	}
+
+	if level, ok := levels[logging.All]; ok && c.LogLevel == log.InfoLevel {
+		c.LogLevel = level
+		if err := c.LogOptions.Init(nil); err != nil {
+			return err
+		}
+	}
+
+	if err := logging.Configure(log.DefaultFactory, levels); err != nil {
+		return err
+	}
+
 	var config api.Config
 	if c.Config != "" {
 		err := yaml.Unmarshal([]byte(c.Config), &config)
@@ -52,14 +105,150 @@ func (c *serveCmd) Execute(args []string) error {
 	}
 	config.SetDefaults()
 
+	if err := docker.SetSignatureKeys(config.SignatureKeys()); err != nil {
+		return err
+	}
+
+	if err := docker.SetNetwork(config.DockerNetwork()); err != nil {
+		return err
+	}
+
+	if err := docker.SetProxy(config.DockerProxy()); err != nil {
+		return err
+	}
+
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if c.RegistryAuth != "" {
+		var auths map[string]types.AuthConfig
+		if err := json.Unmarshal([]byte(c.RegistryAuth), &auths); err != nil {
+			return errors.Wrapf(err, "Error reading --registry-auth option")
+		}
+
+		docker.SetRegistryAuths(auths)
+	}
+
+	// the daemon image version is the same as the server one
+	components.SetCliVersion(version)
+
+	// only the traces of the commands run with srcd --trace are recorded
+	endpoint, err := config.TracingEndpoint()
+	if err != nil {
+		return err
+	}
+
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("http://%s:%d", components.Jaeger.Name, components.JaegerOTLPPort)
+	}
+
+	tracer := tracing.Start("srcd-server", endpoint, false)
+	defer tracer.Close()
+
 	l, err := net.Listen("tcp", c.Addr)
 	if err != nil {
 		return err
 	}
 
-	srv := grpc.NewServer()
-	api.RegisterEngineServer(srv, engine.NewServer(version, workdir, c.HostOS, config))
+	server := engine.NewServer(version, workdir, c.HostOS, c.UASTCacheDir, config)
+	server.SetDatasetsDir(c.DatasetsDir)
+
+	// the config is validated above
+	auditSize, auditFiles, _ := config.AuditLog()
+	if c.AuditDir != "" && auditSize > 0 {
+		l, err := audit.Open(c.AuditDir, auditSize, auditFiles)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+
+		server.SetAuditLog(l)
+		go server.AuditEvents(context.Background())
+	}
+
+	// the components left stopped by a restart of the host or docker are
+	// started again, and then the drivers are synced, both in the
+	// background as they may need to pull images
+	go func() {
+		server.Reconcile(context.Background())
+		if err := server.SyncDrivers(context.Background()); err != nil {
+			log.Errorf(err, "could not sync the bblfsh drivers with the config")
+		}
+	}()
+
+	go server.WatchHealth(context.Background(), healthCheckInterval)
+	go server.WatchExits(context.Background(), healthCheckInterval)
+	go server.WatchImages(context.Background(), imageCollectionInterval)
+	go func() {
+		if err := server.CheckUpgrades(context.Background()); err != nil {
+			log.Errorf(err, "could not check for component upgrades")
+		}
+	}()
+
+	var gateway *http.Server
+	if c.HTTPAddr != "" {
+		gateway = &http.Server{Addr: c.HTTPAddr, Handler: engine.NewHTTPHandler(server)}
+		go func() {
+			log.Infof("http gateway listening on %s", c.HTTPAddr)
+			err := gateway.ListenAndServe()
+			if err != http.ErrServerClosed {
+				log.Errorf(err, "http gateway stopped")
+			}
+		}()
+	}
+
+	srv := grpc.NewServer(server.ServerOptions()...)
+	api.RegisterEngineServer(srv, server)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		<-signals
+		shutdown(server, srv, gateway)
+	}()
 
 	log.Infof("listening on %s", c.Addr)
-	return srv.Serve(l)
+	if err := srv.Serve(l); err != nil {
+		return err
+	}
+
+	// Serve returns as soon as the shutdown begins
+	<-stopped
+	return nil
+}
+
+// shutdown stops the daemon without breaking the results of the running
+// requests. The new connections and requests are rejected, the running SQL
+// and parse requests are given components.DaemonShutdownTimeout to finish,
+// and then the API servers are stopped, cancelling the rest of the calls,
+// such as the ones that watch the events. The components are stopped by
+// srcd stop once the daemon exits.
+func shutdown(server *engine.Server, srv *grpc.Server, gateway *http.Server) {
+	log.Infof("shutting down, waiting up to %s for the running queries", components.DaemonShutdownTimeout)
+
+	// GracefulStop and Shutdown close the listeners and refuse the new
+	// calls, but they would wait for the calls that never end, so they are
+	// interrupted by Stop and Close
+	go srv.GracefulStop()
+	if gateway != nil {
+		go gateway.Shutdown(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), components.DaemonShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warningf("stopping the daemon anyway: %s", err)
+	}
+
+	if gateway != nil {
+		gateway.Close()
+	}
+
+	srv.Stop()
+	log.Infof("daemon stopped")
 }
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/audit"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+
+	"gopkg.in/src-d/go-cli.v0"
+)
+
+// auditFollowInterval is how often srcd audit tail --follow checks the audit
+// log for new records
+const auditFollowInterval = time.Second
+
+// auditCmd represents the audit command
+type auditCmd struct {
+	cli.PlainCommand `name:"audit" short-description:"Show the audit log of the daemon" long-description:"Show the audit log of the orchestration actions run by the daemon: the components started, stopped and restarted, the drivers installed, the queries and searches run, and the images pulled and containers started and stopped by the daemon itself.\n\nEach record has the user and host of srcd that requested the action, and the address the request came from. The queries and search patterns are recorded as their SHA-256 hash."`
+}
+
+// auditTailCmd represents the audit tail command
+type auditTailCmd struct {
+	Command `name:"tail" short-description:"Show the last records of the audit log" long-description:"Show the last records of the audit log of the local daemon, kept in $HOME/.srcd/audit. The log of a remote daemon is in its host.\n\nWith --follow, the new records are shown as they are written, until the command is interrupted."`
+
+	Lines  int  `short:"n" long:"lines" default:"20" description:"number of records to show, all of them if 0"`
+	Follow bool `short:"f" long:"follow" description:"show the new records as they are written"`
+}
+
+// auditTailOutput is the output of srcd audit tail
+type auditTailOutput struct {
+	Records []audit.Record `json:"records" yaml:"records"`
+}
+
+func (c *auditTailCmd) Execute(args []string) error {
+	if daemon.IsRemote() {
+		return fmt.Errorf("the audit log of a remote daemon is in its host, run srcd audit tail there")
+	}
+
+	dir, err := daemon.AuditDir()
+	if err != nil {
+		return humanizef(err, "could not get the audit log directory")
+	}
+
+	records, err := audit.Tail(dir, c.Lines)
+	if err != nil {
+		return humanizef(err, "could not read the audit log")
+	}
+
+	if !c.Follow {
+		out := auditTailOutput{Records: records}
+		if out.Records == nil {
+			out.Records = []audit.Record{}
+		}
+
+		return render(os.Stdout, out, func(w io.Writer) error {
+			for _, r := range records {
+				fmt.Fprintln(w, auditLine(r))
+			}
+
+			return nil
+		})
+	}
+
+	// the records are written one by line, so they can be read as they come
+	print := func(r audit.Record) {
+		if isTextOutput() {
+			fmt.Println(auditLine(r))
+			return
+		}
+
+		b, err := json.Marshal(r)
+		if err == nil {
+			fmt.Println(string(b))
+		}
+	}
+
+	for _, r := range records {
+		print(r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		<-ch
+		cancel()
+	}()
+
+	if err := audit.Follow(ctx, dir, auditFollowInterval, print); err != nil {
+		return humanizef(err, "could not read the audit log")
+	}
+
+	return nil
+}
+
+// auditLine returns the text of a record shown by srcd audit tail
+func auditLine(r audit.Record) string {
+	fields := []string{r.Time.Local().Format(time.RFC3339), r.Who(), r.Action}
+	for _, f := range []string{r.Component, r.Image} {
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+
+	if r.Hash != "" {
+		fields = append(fields, "hash="+r.Hash)
+	}
+
+	if r.Error != "" {
+		fields = append(fields, fmt.Sprintf("error=%q", r.Error))
+	}
+
+	return strings.Join(fields, " ")
+}
+
+func init() {
+	c := rootCmd.AddCommand(&auditCmd{})
+	c.AddCommand(&auditTailCmd{})
+}
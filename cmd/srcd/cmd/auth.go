@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/src-d/engine/api"
+
+	"gopkg.in/src-d/go-cli.v0"
+)
+
+// authTokenBytes is the number of random bytes of the tokens
+const authTokenBytes = 32
+
+// authUserName are the valid names of the users of the daemon API
+var authUserName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// authCmd represents the auth command
+type authCmd struct {
+	cli.PlainCommand `name:"auth" short-description:"Manage the users of the daemon API" long-description:"Manage the users of the daemon API, for the servers shared by a team. When there are users in daemon.auth.users of the config file, each request to the daemon needs the token of one of them, given with --token or SRCD_TOKEN.\n\nThe users with the admin role can use the whole API. The ones with the analyst role can only run queries and searches, parse files, and list the drivers and the status of the components."`
+}
+
+// authTokenCmd represents the auth token command
+type authTokenCmd struct {
+	Command `name:"token" short-description:"Create the token of a user of the daemon API" long-description:"Create a random token for the given user, with the given role, and save its SHA-256 hash in daemon.auth.users of the config file. The token of a user that already has one is replaced.\n\nThe token is only shown now. The daemon uses it once it is restarted with srcd init."`
+
+	Role string `long:"role" choice:"admin" choice:"analyst" default:"analyst" description:"role of the user"`
+
+	Args struct {
+		User string `positional-arg-name:"user" required:"yes" description:"name of the user"`
+	} `positional-args:"yes"`
+}
+
+// authTokenOutput is the output of srcd auth token
+type authTokenOutput struct {
+	User  string `json:"user" yaml:"user"`
+	Role  string `json:"role" yaml:"role"`
+	Token string `json:"token" yaml:"token"`
+}
+
+func (c *authTokenCmd) Execute(args []string) error {
+	if !authUserName.MatchString(c.Args.User) {
+		return fmt.Errorf("invalid user name %q, it can only have letters, digits, _ and -", c.Args.User)
+	}
+
+	b := make([]byte, authTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return humanizef(err, "could not create the token")
+	}
+
+	token := hex.EncodeToString(b)
+	sum := sha256.Sum256([]byte(token))
+
+	key := "daemon.auth.users." + c.Args.User
+	value := fmt.Sprintf("{role: %s, token_sha256: %s}", c.Role, hex.EncodeToString(sum[:]))
+	path, err := setConfigValue(c.Config, key, value)
+	if err != nil {
+		return err
+	}
+
+	out := authTokenOutput{User: c.Args.User, Role: c.Role, Token: token}
+	return render(os.Stdout, out, func(w io.Writer) error {
+		fmt.Fprintf(w, "token of %s, with the %s role, saved in %s:\n\n%s\n\n", out.User, out.Role, path, out.Token)
+		fmt.Fprintf(w, "It is only shown now. Restart the daemon with srcd init to use it, "+
+			"and give it to %s to use with --token or SRCD_TOKEN.\n", out.User)
+		if out.Role != api.RoleAdmin {
+			fmt.Fprintln(w, "Starting and stopping the components needs the token of an admin user.")
+		}
+
+		return nil
+	})
+}
+
+func init() {
+	c := rootCmd.AddCommand(&authCmd{})
+	c.AddCommand(&authTokenCmd{})
+}
@@ -0,0 +1,540 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	units "github.com/docker/go-units"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// benchQueryComment is prepended to the benchmarked queries, so their results
+// are not taken from the query cache of the daemon
+const benchQueryComment = "/* srcd bench */ "
+
+// benchNameRegexp matches the valid names of the saved baselines
+var benchNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// benchCmd represents the bench command
+type benchCmd struct {
+	Command `name:"bench" short-description:"Measure the performance of the current stack" long-description:"Run a standard workload of queries and parses against the current stack, and report the throughput, the latency percentiles of each query and language, and the resources used by gitbase and bblfshd.\n\nThe queries and languages are the ones of srcd warmup, or the ones given with --query and --lang. Each one runs once before it is measured, so the caches and drivers are ready.\n\nThe results can be saved with --save and compared with a saved baseline with --baseline, to evaluate a new machine or version of the components."`
+
+	Iterations  int      `short:"n" long:"iterations" default:"5" description:"number of measured runs of each query and parse"`
+	Concurrency int      `short:"c" long:"concurrency" default:"1" description:"number of runs at the same time"`
+	Queries     []string `short:"q" long:"query" description:"query to run instead of the ones of srcd warmup, can be repeated"`
+	Languages   []string `short:"l" long:"lang" description:"language to parse instead of all the installed ones, can be repeated"`
+	NoParse     bool     `long:"no-parse" description:"don't benchmark the bblfsh drivers"`
+	Save        string   `long:"save" description:"save the results as a baseline with the given name"`
+	Baseline    string   `long:"baseline" description:"compare the results with the saved baseline with the given name"`
+}
+
+func (c *benchCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected none")
+	}
+
+	if c.Iterations < 1 {
+		return fmt.Errorf("invalid --iterations %d, it must be at least 1", c.Iterations)
+	}
+
+	if c.Concurrency < 1 {
+		return fmt.Errorf("invalid --concurrency %d, it must be at least 1", c.Concurrency)
+	}
+
+	for _, name := range []string{c.Save, c.Baseline} {
+		if name != "" && !benchNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid baseline name %q, it can only have letters, numbers, dots, dashes and underscores", name)
+		}
+	}
+
+	dir, err := benchDir()
+	if err != nil {
+		return err
+	}
+
+	var baseline *benchReport
+	if c.Baseline != "" {
+		baseline, err = loadBenchReport(dir, c.Baseline)
+		if err != nil {
+			return err
+		}
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	queries := c.Queries
+	if len(queries) == 0 {
+		queries = config.File.Warmup.Queries
+	}
+
+	if len(queries) == 0 {
+		queries = warmupQueries
+	}
+
+	languages := c.Languages
+	if len(languages) == 0 {
+		languages = config.File.Warmup.Languages
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	if _, err := startGitbaseWithClient(client); err != nil {
+		return humanizef(err, "could not start gitbase")
+	}
+
+	report := &benchReport{
+		Iterations:  c.Iterations,
+		Concurrency: c.Concurrency,
+		Workloads:   []benchWorkload{},
+		Resources:   []benchResource{},
+	}
+
+	before := componentStats(ctx)
+	for _, q := range queries {
+		log.Debugf("benchmarking %s", q)
+		report.add("sql", q, c.run(func() error {
+			_, _, err := queryRows(ctx, client, benchQueryComment+q)
+			return err
+		}))
+	}
+
+	if !c.NoParse {
+		if err := c.benchDrivers(ctx, client, languages, report); err != nil {
+			return err
+		}
+	}
+
+	report.Resources = resourceUsage(before, componentStats(ctx))
+
+	if c.Save != "" {
+		if err := saveBenchReport(dir, c.Save, report); err != nil {
+			return err
+		}
+	}
+
+	if baseline != nil {
+		report.compare(c.Baseline, baseline)
+	}
+
+	if err := render(os.Stdout, report, report.Print); err != nil {
+		return err
+	}
+
+	if n := report.failed(); n > 0 {
+		return fmt.Errorf("%d of %d workloads failed", n, len(report.Workloads))
+	}
+
+	return nil
+}
+
+// benchDrivers benchmarks the parsing of a small file with the drivers of the
+// given languages, or of all the installed ones if there are none
+func (c *benchCmd) benchDrivers(ctx context.Context, client api.EngineClient, languages []string, report *benchReport) error {
+	resp, err := client.ListDrivers(ctx, &api.ListDriversRequest{})
+	if err != nil {
+		return humanizef(err, "could not list drivers")
+	}
+
+	all := len(languages) == 0
+	languages = append([]string(nil), languages...)
+	installed := make(map[string]bool, len(resp.Drivers))
+	for _, d := range resp.Drivers {
+		installed[d.Lang] = true
+		if all {
+			languages = append(languages, d.Lang)
+		}
+	}
+
+	sort.Strings(languages)
+	for _, lang := range languages {
+		if !installed[lang] {
+			report.add("parse", lang, benchRuns{errors: []error{fmt.Errorf("the driver is not installed")}})
+			continue
+		}
+
+		log.Debugf("benchmarking the %s driver", lang)
+		lang := lang
+		report.add("parse", lang, c.run(func() error {
+			_, err := client.Parse(ctx, &api.ParseRequest{
+				Kind:    api.ParseRequest_UAST,
+				Name:    "bench",
+				Content: []byte(warmupSnippets[lang]),
+				Lang:    lang,
+			})
+			return err
+		}))
+	}
+
+	return nil
+}
+
+// benchRuns are the durations of the successful runs of a workload, the
+// errors of the failed ones and the time all of them took
+type benchRuns struct {
+	durations []time.Duration
+	errors    []error
+	elapsed   time.Duration
+}
+
+// run calls fn once, and then the given number of iterations, with up to
+// the given concurrency at the same time, measuring each call
+func (c *benchCmd) run(fn func() error) benchRuns {
+	if err := fn(); err != nil {
+		return benchRuns{errors: []error{err}}
+	}
+
+	var mu sync.Mutex
+	var runs benchRuns
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < c.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				start := time.Now()
+				err := fn()
+				d := time.Since(start)
+
+				mu.Lock()
+				if err != nil {
+					runs.errors = append(runs.errors, err)
+				} else {
+					runs.durations = append(runs.durations, d)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := 0; i < c.Iterations; i++ {
+		jobs <- struct{}{}
+	}
+
+	close(jobs)
+	wg.Wait()
+	runs.elapsed = time.Since(start)
+	return runs
+}
+
+// percentile returns the duration below which are the given percent of the
+// sorted durations, using the nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// componentStats returns the resource usage of gitbase and bblfshd, or none
+// if they can't be read, such as with a remote daemon
+func componentStats(ctx context.Context) map[string]*benchResource {
+	stats := make(map[string]*benchResource)
+	if daemon.IsRemote() {
+		return stats
+	}
+
+	for _, name := range []string{components.Gitbase.Name, components.Bblfshd.Name} {
+		s, err := docker.Stats(ctx, name)
+		if err != nil {
+			log.Debugf("could not read the resource usage of %s: %s", name, err)
+			continue
+		}
+
+		stats[name] = &benchResource{
+			Component: name,
+			CPU:       time.Duration(s.CPUStats.CPUUsage.TotalUsage),
+			Memory:    s.MemoryStats.Usage,
+			MaxMemory: s.MemoryStats.MaxUsage,
+		}
+	}
+
+	return stats
+}
+
+// resourceUsage returns the CPU time used by each component between before
+// and after, and its memory after
+func resourceUsage(before, after map[string]*benchResource) []benchResource {
+	usage := []benchResource{}
+	for name, a := range after {
+		r := *a
+		if b, ok := before[name]; ok {
+			r.CPU -= b.CPU
+		}
+
+		usage = append(usage, r)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Component < usage[j].Component
+	})
+
+	return usage
+}
+
+// benchReport is the output of srcd bench, and the format of the saved
+// baselines
+type benchReport struct {
+	Iterations  int             `json:"iterations" yaml:"iterations"`
+	Concurrency int             `json:"concurrency" yaml:"concurrency"`
+	Workloads   []benchWorkload `json:"workloads" yaml:"workloads"`
+	Resources   []benchResource `json:"resources" yaml:"resources"`
+	// Baseline is the name of the baseline the workloads are compared with
+	Baseline string `json:"baseline,omitempty" yaml:"baseline,omitempty"`
+}
+
+// benchWorkload is a query or language benchmarked by srcd bench
+type benchWorkload struct {
+	Kind   string `json:"kind" yaml:"kind"`
+	Target string `json:"target" yaml:"target"`
+	Runs   int    `json:"runs" yaml:"runs"`
+	Errors int    `json:"errors" yaml:"errors"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Throughput is the number of successful runs per second
+	Throughput float64       `json:"throughput" yaml:"throughput"`
+	Min        time.Duration `json:"min" yaml:"min"`
+	P50        time.Duration `json:"p50" yaml:"p50"`
+	P90        time.Duration `json:"p90" yaml:"p90"`
+	P99        time.Duration `json:"p99" yaml:"p99"`
+	Max        time.Duration `json:"max" yaml:"max"`
+	// P50Change and ThroughputChange are the percent changes from the
+	// baseline, when it has the same workload
+	P50Change        *float64 `json:"p50_change,omitempty" yaml:"p50_change,omitempty"`
+	ThroughputChange *float64 `json:"throughput_change,omitempty" yaml:"throughput_change,omitempty"`
+}
+
+// benchResource is the resource usage of a component during srcd bench
+type benchResource struct {
+	Component string        `json:"component" yaml:"component"`
+	CPU       time.Duration `json:"cpu" yaml:"cpu"`
+	Memory    uint64        `json:"memory" yaml:"memory"`
+	MaxMemory uint64        `json:"max_memory" yaml:"max_memory"`
+}
+
+// add appends the workload with the statistics of its runs
+func (r *benchReport) add(kind, target string, runs benchRuns) {
+	w := benchWorkload{
+		Kind:   kind,
+		Target: target,
+		Runs:   len(runs.durations) + len(runs.errors),
+		Errors: len(runs.errors),
+	}
+
+	if len(runs.errors) > 0 {
+		w.Error = humanize(runs.errors[0]).Error()
+	}
+
+	sorted := append([]time.Duration(nil), runs.durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if len(sorted) > 0 {
+		w.Min = sorted[0]
+		w.P50 = percentile(sorted, 50)
+		w.P90 = percentile(sorted, 90)
+		w.P99 = percentile(sorted, 99)
+		w.Max = sorted[len(sorted)-1]
+	}
+
+	if runs.elapsed > 0 {
+		w.Throughput = float64(len(sorted)) / runs.elapsed.Seconds()
+	}
+
+	r.Workloads = append(r.Workloads, w)
+}
+
+func (r *benchReport) failed() int {
+	var n int
+	for _, w := range r.Workloads {
+		if w.Errors > 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// compare sets the changes of each workload from the same one of the
+// baseline with the given name
+func (r *benchReport) compare(name string, baseline *benchReport) {
+	r.Baseline = name
+
+	type key struct{ kind, target string }
+	previous := make(map[key]benchWorkload, len(baseline.Workloads))
+	for _, w := range baseline.Workloads {
+		previous[key{w.Kind, w.Target}] = w
+	}
+
+	for i, w := range r.Workloads {
+		b, ok := previous[key{w.Kind, w.Target}]
+		if !ok || w.Errors > 0 || b.Errors > 0 {
+			continue
+		}
+
+		r.Workloads[i].P50Change = percentChange(float64(b.P50), float64(w.P50))
+		r.Workloads[i].ThroughputChange = percentChange(b.Throughput, w.Throughput)
+	}
+}
+
+// percentChange returns the change from before to after in percent, or nil if
+// before is 0
+func percentChange(before, after float64) *float64 {
+	if before == 0 {
+		return nil
+	}
+
+	change := (after - before) / before * 100
+	return &change
+}
+
+func (r *benchReport) Print(w io.Writer) error {
+	header := []string{"KIND", "TARGET", "RUNS", "OPS/S", "MIN", "P50", "P90", "P99", "MAX", "RESULT"}
+	if r.Baseline != "" {
+		header = append(header, "P50 VS "+r.Baseline, "OPS/S VS "+r.Baseline)
+	}
+
+	formats := make([]string, len(header))
+	for i := range formats {
+		formats[i] = "%s"
+	}
+
+	t := NewTable(formats...)
+	t.Header(header...)
+	for _, wl := range r.Workloads {
+		result := "ok"
+		if wl.Errors > 0 {
+			result = fmt.Sprintf("%d failed: %s", wl.Errors, wl.Error)
+		}
+
+		row := []interface{}{
+			wl.Kind,
+			wl.Target,
+			fmt.Sprint(wl.Runs),
+			fmt.Sprintf("%.2f", wl.Throughput),
+			benchDuration(wl.Min),
+			benchDuration(wl.P50),
+			benchDuration(wl.P90),
+			benchDuration(wl.P99),
+			benchDuration(wl.Max),
+			result,
+		}
+
+		if r.Baseline != "" {
+			row = append(row, benchChange(wl.P50Change), benchChange(wl.ThroughputChange))
+		}
+
+		t.Row(row...)
+	}
+
+	if err := t.Print(w); err != nil {
+		return err
+	}
+
+	if len(r.Resources) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	t = NewTable("%s", "%s", "%s", "%s")
+	t.Header("COMPONENT", "CPU TIME", "MEMORY", "MAX MEMORY")
+	for _, res := range r.Resources {
+		t.Row(
+			res.Component,
+			benchDuration(res.CPU),
+			units.BytesSize(float64(res.Memory)),
+			units.BytesSize(float64(res.MaxMemory)),
+		)
+	}
+
+	return t.Print(w)
+}
+
+func benchDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func benchChange(change *float64) string {
+	if change == nil {
+		return "-"
+	}
+
+	return fmt.Sprintf("%+.1f%%", *change)
+}
+
+// benchDir returns the directory of the saved baselines, next to the config
+// file
+func benchDir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "bench"), nil
+}
+
+// saveBenchReport saves the report in the directory as the baseline with the
+// given name
+func saveBenchReport(dir, name string, r *benchReport) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return humanizef(err, "could not create directory %s", dir)
+	}
+
+	path := filepath.Join(dir, name+".json")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return humanizef(err, "could not save the baseline %s", name)
+	}
+
+	return nil
+}
+
+// loadBenchReport reads the baseline with the given name from the directory
+func loadBenchReport(dir, name string) (*benchReport, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("there is no baseline %s, save one with srcd bench --save %s", name, name)
+	}
+	if err != nil {
+		return nil, humanizef(err, "could not read the baseline %s", name)
+	}
+
+	var r benchReport
+	if err := json.Unmarshal(content, &r); err != nil {
+		return nil, humanizef(err, "the baseline %s does not follow the expected format", name)
+	}
+
+	return &r, nil
+}
+
+func init() {
+	rootCmd.AddCommand(&benchCmd{})
+}
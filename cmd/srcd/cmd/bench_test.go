@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentile(t *testing.T) {
+	require := require.New(t)
+
+	var sorted []time.Duration
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	require.Equal(time.Duration(0), percentile(nil, 50))
+	require.Equal(5*time.Millisecond, percentile(sorted, 50))
+	require.Equal(9*time.Millisecond, percentile(sorted, 90))
+	require.Equal(10*time.Millisecond, percentile(sorted, 99))
+	require.Equal(time.Millisecond, percentile(sorted, 0))
+	require.Equal(3*time.Millisecond, percentile(sorted[:3], 99))
+}
+
+func TestBenchRun(t *testing.T) {
+	require := require.New(t)
+
+	var calls int32
+	cmd := &benchCmd{Iterations: 7, Concurrency: 3}
+	runs := cmd.run(func() error {
+		if atomic.AddInt32(&calls, 1) == 3 {
+			return fmt.Errorf("timeout")
+		}
+
+		return nil
+	})
+
+	// the first call is not measured
+	require.Equal(int32(8), calls)
+	require.Len(runs.durations, 6)
+	require.Len(runs.errors, 1)
+	require.True(runs.elapsed > 0)
+
+	calls = 0
+	runs = cmd.run(func() error {
+		atomic.AddInt32(&calls, 1)
+		return fmt.Errorf("no such table")
+	})
+
+	require.Equal(int32(1), calls)
+	require.Len(runs.durations, 0)
+	require.Len(runs.errors, 1)
+}
+
+func TestBenchDrivers(t *testing.T) {
+	require := require.New(t)
+
+	client := &driversClient{}
+	report := &benchReport{}
+	cmd := &benchCmd{Iterations: 2, Concurrency: 1}
+	require.NoError(cmd.benchDrivers(context.Background(), client, []string{"go", "ruby"}, report))
+	require.Len(client.parsed, 3)
+	require.Len(report.Workloads, 2)
+	require.Equal("go", report.Workloads[0].Target)
+	require.Equal(2, report.Workloads[0].Runs)
+	require.Equal(0, report.Workloads[0].Errors)
+	require.Equal("ruby", report.Workloads[1].Target)
+	require.Equal("the driver is not installed", report.Workloads[1].Error)
+	require.Equal(1, report.failed())
+}
+
+func TestBenchReport(t *testing.T) {
+	require := require.New(t)
+
+	ms := time.Millisecond
+	report := &benchReport{Iterations: 4, Concurrency: 1}
+	report.add("sql", "SELECT 1", benchRuns{
+		durations: []time.Duration{40 * ms, 10 * ms, 30 * ms, 20 * ms},
+		elapsed:   time.Second,
+	})
+	report.add("sql", "SELECT 2", benchRuns{
+		durations: []time.Duration{10 * ms},
+		errors:    []error{fmt.Errorf("timeout")},
+		elapsed:   time.Second,
+	})
+	report.add("parse", "go", benchRuns{
+		durations: []time.Duration{5 * ms, 5 * ms},
+		elapsed:   10 * ms,
+	})
+
+	w := report.Workloads[0]
+	require.Equal(4, w.Runs)
+	require.Equal(0, w.Errors)
+	require.Equal(4.0, w.Throughput)
+	require.Equal(10*ms, w.Min)
+	require.Equal(20*ms, w.P50)
+	require.Equal(40*ms, w.P90)
+	require.Equal(40*ms, w.Max)
+
+	require.Equal(2, report.Workloads[1].Runs)
+	require.Equal(1, report.Workloads[1].Errors)
+	require.Equal("timeout", report.Workloads[1].Error)
+	require.Equal(1, report.failed())
+
+	baseline := &benchReport{Workloads: []benchWorkload{
+		{Kind: "sql", Target: "SELECT 1", P50: 10 * ms, Throughput: 8},
+		{Kind: "sql", Target: "SELECT 2", P50: 10 * ms, Throughput: 1},
+	}}
+
+	report.compare("old", baseline)
+	require.Equal("old", report.Baseline)
+	require.Equal(100.0, *report.Workloads[0].P50Change)
+	require.Equal(-50.0, *report.Workloads[0].ThroughputChange)
+	require.Nil(report.Workloads[1].P50Change)
+	require.Nil(report.Workloads[2].P50Change)
+
+	var buf bytes.Buffer
+	require.NoError(report.Print(&buf))
+	out := buf.String()
+	require.Contains(out, "P50 VS old")
+	require.Contains(out, "+100.0%")
+	require.Contains(out, "-50.0%")
+	require.Contains(out, "1 failed: timeout")
+}
+
+func TestResourceUsage(t *testing.T) {
+	require := require.New(t)
+
+	before := map[string]*benchResource{
+		"srcd-cli-gitbase": {Component: "srcd-cli-gitbase", CPU: time.Second, Memory: 10},
+	}
+	after := map[string]*benchResource{
+		"srcd-cli-gitbase": {Component: "srcd-cli-gitbase", CPU: 3 * time.Second, Memory: 20, MaxMemory: 30},
+		"srcd-cli-bblfshd": {Component: "srcd-cli-bblfshd", CPU: time.Second, Memory: 5, MaxMemory: 5},
+	}
+
+	require.Equal([]benchResource{
+		{Component: "srcd-cli-bblfshd", CPU: time.Second, Memory: 5, MaxMemory: 5},
+		{Component: "srcd-cli-gitbase", CPU: 2 * time.Second, Memory: 20, MaxMemory: 30},
+	}, resourceUsage(before, after))
+}
+
+func TestBenchBaseline(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-bench")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	_, err = loadBenchReport(dir, "old")
+	require.EqualError(err, "there is no baseline old, save one with srcd bench --save old")
+
+	report := &benchReport{
+		Iterations:  5,
+		Concurrency: 2,
+		Workloads: []benchWorkload{
+			{Kind: "sql", Target: "SELECT 1", Runs: 5, P50: time.Millisecond, Throughput: 10},
+		},
+		Resources: []benchResource{},
+	}
+
+	require.NoError(saveBenchReport(dir, "old", report))
+	loaded, err := loadBenchReport(dir, "old")
+	require.NoError(err)
+	require.Equal(report, loaded)
+}
@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/report"
+
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// status codes of srcd ci run
+const (
+	// ciExitFailed is used when a check does not meet its thresholds
+	ciExitFailed = 1
+	// ciExitInvalid is used when the checks file or the arguments are wrong
+	ciExitInvalid = 2
+	// ciExitError is used when the engine can't be started, or a check can't
+	// be run, like a query with a syntax error
+	ciExitError = 3
+)
+
+// ciParseQuery is the XPath query of the parse checks. It matches no node,
+// so the daemon doesn't send back the UASTs
+const ciParseQuery = "/*[false()]"
+
+// ciCmd represents the ci command
+type ciCmd struct {
+	cli.PlainCommand `name:"ci" short-description:"Run checks over the repositories in continuous integration" long-description:"Run checks over the repositories in continuous integration"`
+}
+
+// ciRunCmd represents the ci run command
+type ciRunCmd struct {
+	Command `name:"run" short-description:"Start the engine, run the checks of a file and stop it" long-description:"Start the engine for the working directory without asking anything, run the SQL and parse checks of the checks file, and stop the engine.\n\nThe status code is 0 if all the checks pass, 1 if any of them fails, 2 if\nthe checks file or the arguments are invalid, and 3 if the engine can't be\nstarted or a check can't be run."`
+
+	Checks string `short:"c" long:"checks" description:"checks file (default: .srcd-ci.yml in the working directory)"`
+	JUnit  string `long:"junit" description:"write a JUnit XML report of the checks to this path"`
+	SARIF  string `long:"sarif" description:"write a SARIF report of the checks to this path"`
+	Range  string `long:"range" description:"only query the commits of this range, like HEAD~10..HEAD, and parse the files they changed"`
+	Keep   bool   `long:"keep" description:"keep the engine running after the checks"`
+
+	Args struct {
+		Workdir string `positional-arg-name:"workdir"`
+	} `positional-args:"yes"`
+}
+
+func (c *ciRunCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return &exitError{ciExitInvalid, fmt.Errorf("too many arguments, expected only one path")}
+	}
+
+	if err := config.Read(c.Config); err != nil {
+		return &exitError{ciExitInvalid, humanizef(err, "could not read the config file")}
+	}
+
+	workdir, err := workdirArg(c.Args.Workdir)
+	if err != nil {
+		return &exitError{ciExitInvalid, err}
+	}
+
+	path := c.Checks
+	if path == "" {
+		path = filepath.Join(workdir, ciChecksFile)
+	}
+
+	checks, err := loadCIChecks(path)
+	if err != nil {
+		return &exitError{ciExitInvalid, err}
+	}
+
+	var rng *gitRange
+	if c.Range != "" {
+		if rng, err = resolveRange(workdir, c.Range); err != nil {
+			return &exitError{ciExitInvalid, err}
+		}
+	}
+
+	if !c.Keep {
+		defer func() {
+			log.Infof("stopping the engine")
+			if err := components.Stop(components.ComponentGracePeriod); err != nil {
+				log.Errorf(humanize(err), "could not stop the engine")
+			}
+		}()
+	}
+
+	client, err := startCI(workdir)
+	if err != nil {
+		return &exitError{ciExitError, err}
+	}
+
+	out := runCIChecks(client, workdir, checks.Checks, rng)
+
+	for _, e := range []struct {
+		path   string
+		format report.Format
+	}{{c.JUnit, report.JUnit}, {c.SARIF, report.SARIF}} {
+		if e.path == "" {
+			continue
+		}
+
+		if err := report.WriteFile(e.path, e.format, out.export()); err != nil {
+			return &exitError{ciExitError, humanizef(err, "could not write the %s report", e.format)}
+		}
+	}
+
+	if err := render(os.Stdout, out, out.Print); err != nil {
+		return err
+	}
+
+	switch {
+	case out.Errors > 0:
+		return &exitError{ciExitError, fmt.Errorf("%d of %d checks could not be run", out.Errors, out.Total)}
+	case out.Failures > 0:
+		return &exitError{ciExitFailed, fmt.Errorf("%d of %d checks failed", out.Failures, out.Total)}
+	default:
+		return nil
+	}
+}
+
+// startCI starts the daemon for the working directory, installs the bblfsh
+// drivers of its languages and starts gitbase and bblfshd
+func startCI(workdir string) (api.EngineClient, error) {
+	log.Infof("starting the engine with working directory: %s", workdir)
+	if _, err := daemon.Init(workdir, false); err != nil {
+		return nil, humanizef(err, "could not start daemon")
+	}
+
+	installWorkdirDrivers(workdir)
+
+	client, err := daemon.Client()
+	if err != nil {
+		return nil, humanizef(err, "could not get daemon client")
+	}
+
+	_, err = startComponents(client, []string{components.Bblfshd.Name, components.Gitbase.Name}, false, nil)
+	return client, err
+}
+
+// ciCheckOutput is the result of a check in the srcd ci run output
+type ciCheckOutput struct {
+	Name   string        `json:"name" yaml:"name"`
+	Result report.Status `json:"result" yaml:"result"`
+	// Message explains why the check failed or could not be run
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Details are some of the rows returned by a failed SQL check
+	Details []string `json:"details,omitempty" yaml:"details,omitempty"`
+	// Findings are the rows returned by a failed SQL check, or the files
+	// that could not be parsed
+	Findings []report.Finding `json:"findings,omitempty" yaml:"findings,omitempty"`
+	Duration float64          `json:"duration" yaml:"duration"`
+}
+
+// ciReport is the schema of the srcd ci run output
+type ciReport struct {
+	Checks   []ciCheckOutput `json:"checks" yaml:"checks"`
+	Total    int             `json:"total" yaml:"total"`
+	Failures int             `json:"failures" yaml:"failures"`
+	Errors   int             `json:"errors" yaml:"errors"`
+	Duration float64         `json:"duration" yaml:"duration"`
+}
+
+// runCIChecks runs the checks in order, and returns their results. With a
+// range, the queries only see its commits, and only the files it changed are
+// parsed
+func runCIChecks(client api.EngineClient, workdir string, checks []ciCheck, rng *gitRange) *ciReport {
+	res := &ciReport{Checks: []ciCheckOutput{}}
+	for _, check := range checks {
+		log.Infof("running check %s", check.Name)
+
+		start := time.Now()
+		out := ciCheckOutput{Name: check.Name, Result: report.Passed}
+		var err error
+		if check.SQL != "" {
+			err = runSQLCheck(client, check, rng, &out)
+		} else {
+			err = runParseCheck(client, workdir, check, rng, &out)
+		}
+
+		out.Duration = time.Since(start).Seconds()
+		switch {
+		case err != nil:
+			out.Result, out.Message = report.Error, humanize(err).Error()
+			res.Errors++
+		case out.Message != "":
+			out.Result = report.Failed
+			res.Failures++
+		}
+
+		res.Checks = append(res.Checks, out)
+		res.Total++
+		res.Duration += out.Duration
+	}
+
+	return res
+}
+
+// runSQLCheck runs the query of the check, setting in the output why it
+// failed and the rows returned, if it did
+func runSQLCheck(client api.EngineClient, check ciCheck, rng *gitRange, out *ciCheckOutput) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	query := check.SQL
+	if rng != nil {
+		query = restrictQuery(query, rng.Commits)
+	}
+
+	columns, rows, truncated, err := queryRowsLimit(ctx, client, query, check.rowsLimit())
+	if err != nil {
+		return err
+	}
+
+	out.Message = check.evaluateSQL(rows, truncated)
+	if out.Message == "" {
+		return nil
+	}
+
+	out.Details = []string{strings.Join(columns, "\t")}
+	for i, row := range rows {
+		if i == ciShownRows {
+			break
+		}
+
+		out.Details = append(out.Details, strings.Join(row, "\t"))
+	}
+
+	// a failed value threshold is about the value, not the rows
+	if check.MaxRows != nil || check.MinRows != nil {
+		out.Findings = report.RowFindings(columns, rows)
+	}
+
+	return nil
+}
+
+// runParseCheck parses the files of the check, setting in the output why it
+// failed and the errors of the files, if it did
+func runParseCheck(client api.EngineClient, workdir string, check ciCheck, rng *gitRange, out *ciCheckOutput) error {
+	files, err := check.parseFiles(workdir)
+	if err != nil {
+		return err
+	}
+
+	if rng != nil {
+		files = intersectFiles(files, rng.Files)
+		// there is nothing to check
+		if len(files) == 0 {
+			return nil
+		}
+	}
+
+	if len(files) == 0 {
+		out.Message = "no file matches the parse patterns"
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	var findings []report.Finding
+	var batch []*api.ParseRequest
+	var size int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		resp, err := parseFiles(ctx, client, &api.ParseBatchRequest{Files: batch})
+		if err != nil {
+			return err
+		}
+
+		for _, r := range resp.Results {
+			if r.Error != "" {
+				findings = append(findings, report.Finding{
+					Message:  r.Error,
+					Location: report.Location{Path: r.Name},
+				})
+			}
+		}
+
+		batch, size = nil, 0
+		return nil
+	}
+
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(workdir, filepath.FromSlash(f)))
+		if err != nil {
+			return err
+		}
+
+		if size+len(b) > parseBatchMaxBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		batch = append(batch, &api.ParseRequest{
+			Kind:    api.ParseRequest_UAST,
+			Name:    f,
+			Content: b,
+			Lang:    check.Lang,
+			Query:   ciParseQuery,
+		})
+		size += len(b)
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if len(findings) <= check.MaxErrors {
+		return nil
+	}
+
+	out.Message = fmt.Sprintf("%d of %d files could not be parsed, the maximum is %d",
+		len(findings), len(files), check.MaxErrors)
+	out.Findings = findings
+	return nil
+}
+
+// intersectFiles returns the files that are in both lists, in the order of
+// the first one
+func intersectFiles(files, changed []string) []string {
+	set := make(map[string]bool, len(changed))
+	for _, f := range changed {
+		set[f] = true
+	}
+
+	var res []string
+	for _, f := range files {
+		if set[f] {
+			res = append(res, f)
+		}
+	}
+
+	return res
+}
+
+// Print writes the result of each check and a summary as text
+func (r *ciReport) Print(w io.Writer) error {
+	for _, c := range r.Checks {
+		line := fmt.Sprintf("%-6s %s (%.1fs)", strings.ToUpper(string(c.Result)), c.Name, c.Duration)
+		if c.Message != "" {
+			line += ": " + c.Message
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+
+		lines := c.Details
+		if len(lines) == 0 {
+			for i, f := range c.Findings {
+				if i == ciShownRows {
+					lines = append(lines, fmt.Sprintf("and %d more", len(c.Findings)-i))
+					break
+				}
+
+				lines = append(lines, f.String())
+			}
+		}
+
+		for _, l := range lines {
+			if _, err := fmt.Fprintf(w, "       %s\n", l); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d checks, %d failed, %d errors in %.1fs\n",
+		r.Total, r.Failures, r.Errors, r.Duration)
+	return err
+}
+
+// export returns the results of the checks to write them with the report
+// package
+func (r *ciReport) export() *report.Report {
+	res := &report.Report{Tool: "srcd ci", Version: version}
+	for _, c := range r.Checks {
+		check := report.Check{
+			Name:     c.Name,
+			Status:   c.Result,
+			Message:  c.Message,
+			Details:  c.Details,
+			Findings: c.Findings,
+			Duration: time.Duration(c.Duration * float64(time.Second)),
+		}
+
+		// the details are the first findings
+		if len(check.Findings) > 0 {
+			check.Details = nil
+		}
+
+		res.Checks = append(res.Checks, check)
+	}
+
+	return res
+}
+
+func init() {
+	c := rootCmd.AddCommand(&ciCmd{})
+	c.AddCommand(&ciRunCmd{})
+}
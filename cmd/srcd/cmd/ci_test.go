@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/report"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCode(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(ciExitInvalid, exitCode(&exitError{ciExitInvalid, nil}))
+	require.Equal(1, exitCode(errors.New("foo")))
+}
+
+func TestCIReport(t *testing.T) {
+	require := require.New(t)
+
+	out := &ciReport{
+		Checks: []ciCheckOutput{
+			{Name: "ok", Result: report.Passed, Duration: 1},
+			{
+				Name:     "big files",
+				Result:   report.Failed,
+				Message:  "too many",
+				Details:  []string{"file_path", "a.bin"},
+				Findings: []report.Finding{{Message: "", Location: report.Location{Path: "a.bin"}}},
+				Duration: 2,
+			},
+			{
+				Name:     "go files parse",
+				Result:   report.Failed,
+				Message:  "1 of 3 files could not be parsed, the maximum is 0",
+				Findings: []report.Finding{{Message: "syntax error", Location: report.Location{Path: "a.go"}}},
+				Duration: 1.5,
+			},
+			{Name: "broken", Result: report.Error, Message: "syntax error", Duration: 0.5},
+		},
+		Total:    4,
+		Failures: 2,
+		Errors:   1,
+		Duration: 5,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(out.Print(&buf))
+	require.Equal(`PASSED ok (1.0s)
+FAILED big files (2.0s): too many
+       file_path
+       a.bin
+FAILED go files parse (1.5s): 1 of 3 files could not be parsed, the maximum is 0
+       a.go: syntax error
+ERROR  broken (0.5s): syntax error
+
+4 checks, 2 failed, 1 errors in 5.0s
+`, buf.String())
+
+	r := out.export()
+	require.Equal("srcd ci", r.Tool)
+	require.Len(r.Checks, 4)
+	require.Nil(r.Checks[1].Details)
+	require.Equal(2*time.Second, r.Checks[1].Duration)
+	require.Equal(1, r.Count(report.Error))
+}
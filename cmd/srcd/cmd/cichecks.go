@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ciChecksFile is the checks file of srcd ci run, in the working directory,
+// when none is given
+const ciChecksFile = ".srcd-ci.yml"
+
+// ciShownRows is the number of rows of a failed SQL check included in its
+// report
+const ciShownRows = 10
+
+// ciChecks is the schema of the checks file of srcd ci run
+type ciChecks struct {
+	Checks []ciCheck `yaml:"checks"`
+}
+
+// ciCheck is a SQL query with thresholds on its result, or a list of files
+// that must be parsed by bblfsh
+type ciCheck struct {
+	Name string `yaml:"name"`
+	// SQL is the query run in gitbase
+	SQL string `yaml:"sql,omitempty"`
+	// MaxRows and MinRows are the limits of the number of rows returned by
+	// the query
+	MaxRows *int64 `yaml:"max_rows,omitempty"`
+	MinRows *int64 `yaml:"min_rows,omitempty"`
+	// MaxValue and MinValue are the limits of the number in the first column
+	// of the first row, like the result of a COUNT(*)
+	MaxValue *float64 `yaml:"max_value,omitempty"`
+	MinValue *float64 `yaml:"min_value,omitempty"`
+
+	// Parse are the patterns of the files parsed, relative to the working
+	// directory. ** matches any number of directories
+	Parse []string `yaml:"parse,omitempty"`
+	// Lang skips the language detection of the parsed files
+	Lang string `yaml:"lang,omitempty"`
+	// MaxErrors is the number of files that can fail to parse
+	MaxErrors int `yaml:"max_errors,omitempty"`
+}
+
+// loadCIChecks reads and validates the checks file at the given path
+func loadCIChecks(path string) (*ciChecks, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the checks file %s", path)
+	}
+
+	var checks ciChecks
+	if err := yaml.UnmarshalStrict(content, &checks); err != nil {
+		return nil, errors.Wrapf(err, "checks file %s does not follow the expected format", path)
+	}
+
+	if err := checks.validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid checks file %s", path)
+	}
+
+	return &checks, nil
+}
+
+func (c *ciChecks) validate() error {
+	if len(c.Checks) == 0 {
+		return fmt.Errorf("there are no checks")
+	}
+
+	names := make(map[string]bool, len(c.Checks))
+	for i, check := range c.Checks {
+		if check.Name == "" {
+			return fmt.Errorf("check %d has no name", i+1)
+		}
+
+		if names[check.Name] {
+			return fmt.Errorf("check %q is repeated", check.Name)
+		}
+		names[check.Name] = true
+
+		if err := check.validate(); err != nil {
+			return errors.Wrapf(err, "check %q", check.Name)
+		}
+	}
+
+	return nil
+}
+
+func (c *ciCheck) validate() error {
+	hasThreshold := c.MaxRows != nil || c.MinRows != nil || c.MaxValue != nil || c.MinValue != nil
+	switch {
+	case c.SQL != "" && len(c.Parse) > 0:
+		return fmt.Errorf("it can't have both sql and parse")
+	case c.SQL != "":
+		if !hasThreshold {
+			return fmt.Errorf("it needs max_rows, min_rows, max_value or min_value")
+		}
+
+		if c.Lang != "" || c.MaxErrors != 0 {
+			return fmt.Errorf("lang and max_errors can only be used with parse")
+		}
+	case len(c.Parse) > 0:
+		if hasThreshold {
+			return fmt.Errorf("the sql thresholds can't be used with parse")
+		}
+
+		if c.MaxErrors < 0 {
+			return fmt.Errorf("max_errors can't be negative")
+		}
+
+		for _, p := range c.Parse {
+			if _, err := globRegexp(p); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("it needs sql or parse")
+	}
+
+	return nil
+}
+
+// rowsLimit returns the number of rows of the query read to evaluate the
+// check, enough to know that max_rows was exceeded and to show some of them,
+// or 0 for all of them
+func (c *ciCheck) rowsLimit() int64 {
+	if c.MinRows != nil {
+		return 0
+	}
+
+	if c.MaxRows != nil {
+		return *c.MaxRows + ciShownRows
+	}
+
+	// only the value thresholds
+	return 1
+}
+
+// evaluateSQL returns an empty string if the result of the query meets the
+// thresholds of the check, or why it doesn't otherwise. With truncated the
+// query returned more rows than the given ones
+func (c *ciCheck) evaluateSQL(rows [][]string, truncated bool) string {
+	n := int64(len(rows))
+	if c.MaxRows != nil && (n > *c.MaxRows || truncated) {
+		more := ""
+		if truncated {
+			more = "more than "
+		}
+
+		return fmt.Sprintf("the query returned %s%d rows, the maximum is %d", more, n, *c.MaxRows)
+	}
+
+	if c.MinRows != nil && n < *c.MinRows {
+		return fmt.Sprintf("the query returned %d rows, the minimum is %d", n, *c.MinRows)
+	}
+
+	if c.MaxValue == nil && c.MinValue == nil {
+		return ""
+	}
+
+	if n == 0 || len(rows[0]) == 0 {
+		return "the query returned no value"
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(rows[0][0]), 64)
+	if err != nil {
+		return fmt.Sprintf("the query returned %q, which is not a number", rows[0][0])
+	}
+
+	if c.MaxValue != nil && v > *c.MaxValue {
+		return fmt.Sprintf("the query returned %s, the maximum is %s", formatNumber(v), formatNumber(*c.MaxValue))
+	}
+
+	if c.MinValue != nil && v < *c.MinValue {
+		return fmt.Sprintf("the query returned %s, the minimum is %s", formatNumber(v), formatNumber(*c.MinValue))
+	}
+
+	return ""
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parseFiles returns the files of the working directory matching the parse
+// patterns of the check, relative to it, using forward slashes. The .git
+// directories are skipped
+func (c *ciCheck) parseFiles(workdir string) ([]string, error) {
+	var res []*regexp.Regexp
+	for _, p := range c.Parse {
+		re, err := globRegexp(p)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, re)
+	}
+
+	var files []string
+	err := filepath.Walk(workdir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		rel, err := filepath.Rel(workdir, path)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		for _, re := range res {
+			if re.MatchString(rel) {
+				files = append(files, rel)
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+// globRegexp returns the regular expression matching the paths of the given
+// pattern, where * and ? don't match slashes, and ** matches any number of
+// directories
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" || strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("invalid pattern %q, it must be relative to the working directory", pattern)
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case ch == '*':
+			b.WriteString("[^/]*")
+		case ch == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func int64p(v int64) *int64       { return &v }
+func float64p(v float64) *float64 { return &v }
+
+func TestLoadCIChecks(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-ci")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ciChecksFile)
+	require.NoError(ioutil.WriteFile(path, []byte(`
+checks:
+- name: no big files
+  sql: SELECT file_path FROM files WHERE LENGTH(blob_content) > 1000000
+  max_rows: 0
+- name: go files parse
+  parse: ["**/*.go"]
+  max_errors: 1
+`), 0644))
+
+	checks, err := loadCIChecks(path)
+	require.NoError(err)
+	require.Len(checks.Checks, 2)
+	require.Equal(int64(0), *checks.Checks[0].MaxRows)
+	require.Equal([]string{"**/*.go"}, checks.Checks[1].Parse)
+
+	require.NoError(ioutil.WriteFile(path, []byte("checks:\n- name: a\n  sql: SELECT 1\n  maxrows: 1\n"), 0644))
+	_, err = loadCIChecks(path)
+	require.Error(err)
+}
+
+func TestCICheckValidate(t *testing.T) {
+	require := require.New(t)
+
+	valid := []ciCheck{
+		{SQL: "SELECT 1", MaxRows: int64p(0)},
+		{SQL: "SELECT 1", MinValue: float64p(1)},
+		{Parse: []string{"*.go"}, Lang: "go", MaxErrors: 2},
+	}
+	for _, c := range valid {
+		require.NoError(c.validate())
+	}
+
+	invalid := []ciCheck{
+		{},
+		{SQL: "SELECT 1"},
+		{SQL: "SELECT 1", MaxRows: int64p(0), Parse: []string{"*.go"}},
+		{SQL: "SELECT 1", MaxRows: int64p(0), Lang: "go"},
+		{Parse: []string{"*.go"}, MaxRows: int64p(0)},
+		{Parse: []string{"*.go"}, MaxErrors: -1},
+		{Parse: []string{"/abs/*.go"}},
+	}
+	for _, c := range invalid {
+		require.Error(c.validate(), "%+v", c)
+	}
+
+	require.Error((&ciChecks{}).validate())
+	require.Error((&ciChecks{Checks: []ciCheck{valid[0]}}).validate())
+	require.Error((&ciChecks{Checks: []ciCheck{
+		{Name: "a", SQL: "SELECT 1", MaxRows: int64p(0)},
+		{Name: "a", SQL: "SELECT 1", MaxRows: int64p(0)},
+	}}).validate())
+}
+
+func TestCICheckEvaluateSQL(t *testing.T) {
+	require := require.New(t)
+
+	rows := [][]string{{"3"}, {"5"}}
+
+	c := ciCheck{MaxRows: int64p(2)}
+	require.Equal(int64(12), c.rowsLimit())
+	require.Equal("", c.evaluateSQL(rows, false))
+	require.Equal("the query returned more than 2 rows, the maximum is 2", c.evaluateSQL(rows, true))
+
+	c = ciCheck{MinRows: int64p(3)}
+	require.Equal(int64(0), c.rowsLimit())
+	require.Equal("the query returned 2 rows, the minimum is 3", c.evaluateSQL(rows, false))
+
+	c = ciCheck{MaxValue: float64p(2.5)}
+	require.Equal(int64(1), c.rowsLimit())
+	require.Equal("the query returned 3, the maximum is 2.5", c.evaluateSQL(rows, true))
+	require.Equal("the query returned no value", c.evaluateSQL(nil, false))
+	require.Equal(`the query returned "foo", which is not a number`, c.evaluateSQL([][]string{{"foo"}}, false))
+
+	c = ciCheck{MinValue: float64p(3)}
+	require.Equal("", c.evaluateSQL(rows, true))
+}
+
+func TestGlobRegexp(t *testing.T) {
+	require := require.New(t)
+
+	cases := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"*.go", []string{"main.go"}, []string{"cmd/main.go", "main.gox"}},
+		{"**/*.go", []string{"main.go", "a/b/main.go"}, []string{"main.py"}},
+		{"src/**", []string{"src/a", "src/a/b.c"}, []string{"srca", "lib/src/a"}},
+		{"src/**/test_?.py", []string{"src/test_1.py", "src/a/b/test_2.py"}, []string{"src/test_10.py"}},
+	}
+
+	for _, c := range cases {
+		re, err := globRegexp(c.pattern)
+		require.NoError(err)
+		for _, m := range c.match {
+			require.True(re.MatchString(m), "%s should match %s", c.pattern, m)
+		}
+
+		for _, m := range c.noMatch {
+			require.False(re.MatchString(m), "%s should not match %s", c.pattern, m)
+		}
+	}
+
+	_, err := globRegexp("")
+	require.Error(err)
+}
+
+func TestCICheckParseFiles(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-ci")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for _, f := range []string{"main.go", "a/b/lib.go", "a/readme.md", ".git/hooks/x.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		require.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(ioutil.WriteFile(path, nil, 0644))
+	}
+
+	files, err := (&ciCheck{Parse: []string{"**/*.go"}}).parseFiles(dir)
+	require.NoError(err)
+	require.Equal([]string{"a/b/lib.go", "main.go"}, files)
+}
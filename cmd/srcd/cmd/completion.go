@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+
+	flags "github.com/jessevdk/go-flags"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// completionScripts are the scripts printed by srcd completion. They call
+// srcd with GO_FLAGS_COMPLETION set, so go-flags prints the completions of
+// the last argument instead of running the command
+var completionScripts = map[string]string{
+	"bash": `# srcd bash completion, load it with: source <(srcd completion bash)
+_srcd() {
+    local args=("${COMP_WORDS[@]:1:$COMP_CWORD}")
+    local IFS=$'\n'
+    COMPREPLY=($(GO_FLAGS_COMPLETION=1 "${COMP_WORDS[0]}" "${args[@]}"))
+    return 0
+}
+complete -o default -F _srcd srcd
+`,
+	"zsh": `# srcd zsh completion, load it with: source <(srcd completion zsh)
+autoload -U +X bashcompinit && bashcompinit
+_srcd() {
+    local args=("${COMP_WORDS[@]:1:$COMP_CWORD}")
+    local IFS=$'\n'
+    COMPREPLY=($(GO_FLAGS_COMPLETION=1 "${COMP_WORDS[0]}" "${args[@]}"))
+    return 0
+}
+complete -o default -F _srcd srcd
+`,
+	"fish": `# srcd fish completion, load it with: srcd completion fish | source
+function __srcd_complete
+    set -l args (commandline -opc)[2..-1] (commandline -ct)
+    env GO_FLAGS_COMPLETION=1 srcd $args
+end
+complete -c srcd -f -a '(__srcd_complete)'
+`,
+	"powershell": `# srcd PowerShell completion, load it with:
+# srcd completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName srcd -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '""' }
+    $env:GO_FLAGS_COMPLETION = '1'
+    $items = & srcd @words
+    Remove-Item Env:GO_FLAGS_COMPLETION
+    $items | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`,
+}
+
+// completionCmd represents the completion command
+type completionCmd struct {
+	Command `name:"completion" short-description:"Generate a shell completion script" long-description:"Generate the completion script of srcd for bash, zsh, fish or powershell. Besides commands and flags, it completes the names of the components, plugins, snapshots and profiles"`
+
+	Args struct {
+		Shell string `positional-arg-name:"shell" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *completionCmd) Execute(args []string) error {
+	script, ok := completionScripts[c.Args.Shell]
+	if !ok {
+		var shells []string
+		for shell := range completionScripts {
+			shells = append(shells, shell)
+		}
+		sort.Strings(shells)
+
+		return fmt.Errorf("%s is not supported. Shell must be one of [%s]", c.Args.Shell, strings.Join(shells, ", "))
+	}
+
+	_, err := fmt.Print(script)
+	return err
+}
+
+// completeNames returns the given names with the prefix, with their
+// descriptions, if any
+func completeNames(names []string, descriptions map[string]string, match string) []flags.Completion {
+	var res []flags.Completion
+	for _, name := range names {
+		if strings.HasPrefix(name, match) {
+			res = append(res, flags.Completion{Item: name, Description: descriptions[name]})
+		}
+	}
+
+	return res
+}
+
+// completionSetup applies the global options given as environment variables
+// before completing. The flags are not parsed while completing, so
+// --profile and --host are not taken into account
+func completionSetup() {
+	if err := components.SetProfile(os.Getenv("SRCD_PROFILE")); err != nil {
+		return
+	}
+
+	daemon.SetHost(os.Getenv("SRCD_HOST"))
+	daemon.SetToken(os.Getenv("SRCD_TOKEN"))
+}
+
+// componentArg is a component given by container or image name
+type componentArg string
+
+// Complete implements the flags.Completer interface. The state of the
+// components is read from the daemon, if it is running.
+func (componentArg) Complete(match string) []flags.Completion {
+	completionSetup()
+
+	var names []string
+	for _, cmp := range components.Upgradable() {
+		names = append(names, cmp.Name, cmp.Image)
+	}
+
+	return completeNames(names, daemonComponentStates(), match)
+}
+
+// daemonComponentStates returns the state of each component by container
+// name, read from the daemon. It is empty if the daemon is not running, as
+// completing must not start it
+func daemonComponentStates() map[string]string {
+	states := make(map[string]string)
+	if daemon.IsDaemonless() {
+		return states
+	}
+
+	if !daemon.IsRemote() {
+		if running, err := daemon.IsRunning(); err != nil || !running {
+			return states
+		}
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return states
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	res, err := client.ListComponents(ctx, &api.ListComponentsRequest{})
+	if err != nil {
+		return states
+	}
+
+	for _, c := range res.Components {
+		states[c.Name] = stateFmt(c.State)
+	}
+
+	return states
+}
+
+// componentArgs returns the names of the given components
+func componentArgs(args []componentArg) []string {
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = string(arg)
+	}
+
+	return names
+}
+
+// pluginArg is the name of a plugin
+type pluginArg string
+
+// Complete implements the flags.Completer interface.
+func (pluginArg) Complete(match string) []flags.Completion {
+	completionSetup()
+	if err := loadPlugins(); err != nil {
+		return nil
+	}
+
+	var names []string
+	descriptions := make(map[string]string)
+	for _, m := range installedPlugins {
+		cmp := m.Component()
+		names = append(names, m.Name)
+		descriptions[m.Name] = cmp.ImageWithVersion()
+	}
+
+	return completeNames(names, descriptions, match)
+}
+
+// snapshotArg is the name of a snapshot
+type snapshotArg string
+
+// Complete implements the flags.Completer interface.
+func (snapshotArg) Complete(match string) []flags.Completion {
+	dir, err := snapshotsDir()
+	if err != nil {
+		return nil
+	}
+
+	return completeNames(subdirs(dir), nil, match)
+}
+
+// profileArg is the name of a profile
+type profileArg string
+
+// Complete implements the flags.Completer interface.
+func (profileArg) Complete(match string) []flags.Completion {
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil
+	}
+
+	return completeNames(subdirs(filepath.Join(home, ".srcd", "profiles")), nil, match)
+}
+
+// subdirs returns the names of the directories in dir
+func subdirs(dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, info := range infos {
+		if info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(&completionCmd{})
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	flags "github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		assert.Contains(t, completionScripts[shell], "GO_FLAGS_COMPLETION", shell)
+	}
+}
+
+func TestCompleteNames(t *testing.T) {
+	assert := assert.New(t)
+
+	names := []string{"srcd-cli-gitbase", "srcd-cli-gitbase-web", "srcd-cli-bblfshd"}
+	descriptions := map[string]string{"srcd-cli-gitbase": "running"}
+
+	assert.Equal([]flags.Completion{
+		{Item: "srcd-cli-gitbase", Description: "running"},
+		{Item: "srcd-cli-gitbase-web"},
+	}, completeNames(names, descriptions, "srcd-cli-g"))
+
+	assert.Len(completeNames(names, nil, ""), 3)
+	assert.Empty(completeNames(names, nil, "foo"))
+}
+
+func TestSubdirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srcd-completion")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "a"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "b"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c"), nil, 0644))
+
+	assert.Equal(t, []string{"a", "b"}, subdirs(dir))
+	assert.Nil(t, subdirs(filepath.Join(dir, "missing")))
+}
+
+func TestComponentArgs(t *testing.T) {
+	assert.Equal(t,
+		[]string{"srcd-cli-gitbase", "bblfsh/web"},
+		componentArgs([]componentArg{"srcd-cli-gitbase", "bblfsh/web"}),
+	)
+}
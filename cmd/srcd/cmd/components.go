@@ -19,10 +19,16 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
 	"github.com/src-d/engine/components"
 	"github.com/src-d/engine/docker"
+	sdk "github.com/src-d/engine/engine"
 
+	"github.com/blang/semver"
 	"gopkg.in/src-d/go-cli.v0"
 	"gopkg.in/src-d/go-log.v1"
 )
@@ -47,19 +53,46 @@ func (c *componentsListCmd) Execute(args []string) error {
 		return humanizef(err, "could not list images")
 	}
 
+	out := componentsListOutput{Components: []componentOutput{}}
 	t := NewTable("%s", "%s", "%v", "%v", "%v")
 	t.Header("IMAGE", "INSTALLED", "RUNNING", "PORT", "CONTAINER NAME")
 	for _, cmp := range cmps {
+		installed, installedErr := cmp.IsInstalled()
+		running, runningErr := cmp.IsRunning()
+		ports, portsErr := cmp.GetPorts()
+
 		t.Row(
 			cmp.ImageWithVersion(),
-			boolFmt(cmp.IsInstalled()),
-			boolFmt(cmp.IsRunning()),
-			publicPortsFmt(cmp.GetPorts()),
+			boolFmt(installed, installedErr),
+			boolFmt(running, runningErr),
+			publicPortsFmt(ports, portsErr),
 			cmp.Name,
 		)
+
+		out.Components = append(out.Components, componentOutput{
+			Name:      cmp.Name,
+			Image:     cmp.ImageWithVersion(),
+			Installed: optionalBool(installed, installedErr),
+			Running:   optionalBool(running, runningErr),
+			Ports:     publicPorts(ports),
+		})
 	}
 
-	return t.Print(os.Stdout)
+	return render(os.Stdout, out, t.Print)
+}
+
+// componentsListOutput is the schema of the srcd components list output.
+// Installed and Running are null if they could not be checked
+type componentsListOutput struct {
+	Components []componentOutput `json:"components" yaml:"components"`
+}
+
+type componentOutput struct {
+	Name      string `json:"name" yaml:"name"`
+	Image     string `json:"image" yaml:"image"`
+	Installed *bool  `json:"installed" yaml:"installed"`
+	Running   *bool  `json:"running" yaml:"running"`
+	Ports     []int  `json:"ports" yaml:"ports"`
 }
 
 func boolFmt(b bool, err error) string {
@@ -90,20 +123,23 @@ func publicPortsFmt(ps []docker.Port, err error) string {
 
 // componentsInstallCmd represents the components install command
 type componentsInstallCmd struct {
-	Command `name:"install" short-description:"Install source{d} component" long-description:"Install source{d} component"`
+	Command `name:"install" short-description:"Install source{d} component" long-description:"Install source{d} component.\n\nWhen the daemon is running its image is pulled by a pull-image job of the daemon, that keeps running if srcd is interrupted. The command returns once the job is started, or follows it until it finishes with --wait."`
+
+	Wait bool `long:"wait" description:"follow the pull run by the daemon until it finishes"`
 
 	Args struct {
-		Components []string `positional-arg-name:"component(s)" required:"1"`
+		Components []componentArg `positional-arg-name:"component(s)" required:"1"`
 	} `positional-args:"yes" required:"yes"`
 }
 
 func (c *componentsInstallCmd) Execute(args []string) error {
+	wait := c.Wait
 	cmps, err := components.List(context.Background(), false)
 	if err != nil {
 		return humanizef(err, "could not list images")
 	}
 
-	for _, arg := range c.Args.Components {
+	for _, arg := range componentArgs(c.Args.Components) {
 		var c *components.Component
 		for _, cmp := range cmps {
 			// We allow to match by container name or by image name
@@ -139,17 +175,649 @@ func (c *componentsInstallCmd) Execute(args []string) error {
 
 		log.Infof("installing %s", c.ImageWithVersion())
 
-		err = c.Install()
+		j, err := pullImage(context.Background(), c.Image, c.Version, wait)
 		if err != nil {
 			return humanizef(err, "could not install %s", arg)
 		}
+
+		if j != nil && !wait {
+			if err := printJobStarted(j); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// componentsUpgradeCmd represents the components upgrade command
+type componentsUpgradeCmd struct {
+	Command `name:"upgrade" short-description:"Upgrade source{d} components" long-description:"Upgrade the installed components to the newest image versions compatible with the current ones, recreating their containers and keeping their volumes. All the components are upgraded if none is given"`
+
+	Check bool `long:"check" description:"Only report the available upgrades"`
+
+	Args struct {
+		Components []componentArg `positional-arg-name:"component(s)"`
+	} `positional-args:"yes"`
+}
+
+// componentUpgrade is a component with the version it is switched to
+type componentUpgrade struct {
+	cmp     components.Component
+	version string
+}
+
+func (c *componentsUpgradeCmd) Execute(args []string) error {
+	cmps, err := selectUpgradable(componentArgs(c.Args.Components))
+	if err != nil {
+		return err
+	}
+
+	upgrades, err := findUpgrades(cmps)
+	if err != nil {
+		return err
+	}
+
+	if c.Check {
+		out := upgradesOutput{Upgrades: []upgradeOutput{}}
+		t := NewTable("%s", "%s", "%s")
+		t.Header("IMAGE", "CURRENT", "AVAILABLE")
+		for _, u := range upgrades {
+			t.Row(u.cmp.Image, u.cmp.Version, u.version)
+			out.Upgrades = append(out.Upgrades, upgradeOutput{
+				Image:     u.cmp.Image,
+				Current:   u.cmp.Version,
+				Available: u.version,
+			})
+		}
+
+		return render(os.Stdout, out, t.Print)
+	}
+
+	var pending []componentUpgrade
+	for _, u := range upgrades {
+		if u.version != u.cmp.Version {
+			pending = append(pending, u)
+		}
+	}
+
+	if len(pending) == 0 {
+		log.Infof("the components are up to date")
+		return nil
+	}
+
+	return upgradeComponents(pending)
+}
+
+// upgradesOutput is the schema of the srcd components upgrade --check output.
+// Available is the same as Current if the component is up to date
+type upgradesOutput struct {
+	Upgrades []upgradeOutput `json:"upgrades" yaml:"upgrades"`
+}
+
+type upgradeOutput struct {
+	Image     string `json:"image" yaml:"image"`
+	Current   string `json:"current" yaml:"current"`
+	Available string `json:"available" yaml:"available"`
+}
+
+// selectUpgradable returns the upgradable components matching the given
+// container or image names, or all of them if there are no names
+func selectUpgradable(names []string) ([]components.Component, error) {
+	cmps := components.Upgradable()
+	if len(names) == 0 {
+		return cmps, nil
+	}
+
+	var selected []components.Component
+	for _, name := range names {
+		var found bool
+		for _, cmp := range cmps {
+			// We allow to match by container name or by image name
+			if name == cmp.Name || name == cmp.Image {
+				selected = append(selected, cmp)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			images := make([]string, len(cmps))
+			for i, cmp := range cmps {
+				images[i] = cmp.Image
+			}
+
+			return nil, fmt.Errorf("%s is not valid. Component must be one of [%s]", name, strings.Join(images, ", "))
+		}
+	}
+
+	return selected, nil
+}
+
+// findUpgrades returns the newest compatible version of each installed
+// component. Components whose versions can't be compared are skipped
+func findUpgrades(cmps []components.Component) ([]componentUpgrade, error) {
+	var upgrades []componentUpgrade
+	for _, cmp := range cmps {
+		installed, err := cmp.IsInstalled()
+		if err != nil {
+			return nil, humanizef(err, "could not check if %s is installed", cmp.Image)
+		}
+
+		if !installed {
+			log.Debugf("skipping %s, it is not installed", cmp.ImageWithVersion())
+			continue
+		}
+
+		// the version set in the config file can be a floating tag, like latest
+		if _, err := semver.ParseTolerant(cmp.Version); err != nil {
+			log.Debugf("skipping %s, its version can't be compared", cmp.ImageWithVersion())
+			continue
+		}
+
+		tag, _, err := docker.GetCompatibleTag(cmp.Image, cmp.Version)
+		if err != nil {
+			log.Warningf("could not find a newer version of %s: %s", cmp.ImageWithVersion(), err)
+			continue
+		}
+
+		upgrades = append(upgrades, componentUpgrade{cmp: cmp, version: tag})
+	}
+
+	return upgrades, nil
+}
+
+// upgradeComponents pulls the new images and switches the components to them,
+// see switchUpgrades
+func upgradeComponents(upgrades []componentUpgrade) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	for _, u := range upgrades {
+		log.Infof("upgrading %s from %s to %s", u.cmp.Image, u.cmp.Version, u.version)
+		if _, err := pullImage(ctx, u.cmp.Image, u.version, true); err != nil {
+			return humanizef(err, "could not install %s:%s", u.cmp.Image, u.version)
+		}
+	}
+
+	if err := switchUpgrades(ctx, upgrades); err != nil {
+		return err
+	}
+
+	log.Infof("components upgraded")
+	return nil
+}
+
+// switchVersionsFunc is switchVersions, a var so the tests can replace it
+var switchVersionsFunc = switchVersions
+
+// switchUpgrades switches the components to the new versions and, once they
+// are switched, saves their current versions for srcd components rollback. A
+// failed upgrade keeps the version saved by the last one that succeeded
+func switchUpgrades(ctx context.Context, upgrades []componentUpgrade) error {
+	if err := switchVersionsFunc(ctx, upgrades); err != nil {
+		return err
+	}
+
+	for _, u := range upgrades {
+		if err := daemon.SetPreviousVersion(u.cmp.Name, u.cmp.Version); err != nil {
+			return humanizef(err, "could not save the version of %s", u.cmp.Name)
+		}
+	}
+
+	return nil
+}
+
+// switchVersions saves the new versions of the components and replaces their
+// containers, keeping their named volumes. The daemon is restarted so it uses
+// the new versions, and the components that were running are started again
+// with the same port. The images must be installed
+func switchVersions(ctx context.Context, upgrades []componentUpgrade) error {
+	versions, err := config.ReadVersions()
+	if err != nil {
+		return humanizef(err, "could not read the component versions")
+	}
+
+	for _, u := range upgrades {
+		versions[u.cmp.Name] = u.version
+	}
+
+	if err := config.WriteVersions(versions); err != nil {
+		return humanizef(err, "could not save the component versions")
+	}
+
+	components.SetVersions(versions)
+
+	var restart []*api.StartComponentRequest
+	for _, u := range upgrades {
+		ports, err := u.cmp.GetPorts()
+		if err != nil {
+			return humanizef(err, "could not get the ports of %s", u.cmp.Name)
+		}
+
+		running, err := docker.IsRunning(u.cmp.Name, "")
+		if err != nil {
+			return humanizef(err, "could not check if %s is running", u.cmp.Name)
+		}
+
+		if running {
+			req := &api.StartComponentRequest{Name: u.cmp.Name}
+			for _, p := range ports {
+				if p.PublicPort != 0 {
+					req.Port = int32(p.PublicPort)
+					break
+				}
+			}
+
+			restart = append(restart, req)
+		}
+
+		// the named volumes are kept
+		if err := u.cmp.Stop(); err != nil {
+			return humanizef(err, "could not remove the container of %s", u.cmp.Name)
+		}
+	}
+
+	if err := daemon.Restart(); err != nil {
+		return humanizef(err, "could not restart the daemon")
+	}
+
+	if len(restart) == 0 {
+		log.Infof("the new versions will be used when the components are started")
+		return nil
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	for _, req := range restart {
+		log.Infof("starting %s", req.Name)
+		if _, err := client.StartComponent(ctx, req); err != nil {
+			return humanizef(err, "could not start %s", req.Name)
+		}
 	}
 
 	return nil
 }
 
+// componentsRollbackCmd represents the components rollback command
+type componentsRollbackCmd struct {
+	Command `name:"rollback" short-description:"Roll back the upgrade of a source{d} component" long-description:"Switch a component back to the image version it used before its last srcd components upgrade, recreating its container and keeping its volumes"`
+
+	Args struct {
+		Component componentArg `positional-arg-name:"component" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *componentsRollbackCmd) Execute(args []string) error {
+	cmps, err := selectUpgradable([]string{string(c.Args.Component)})
+	if err != nil {
+		return err
+	}
+
+	cmp := cmps[0]
+	previous, err := rollbackVersion(cmp)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	log.Infof("rolling back %s from %s to %s", cmp.Image, cmp.Version, previous)
+	installed, err := docker.IsInstalled(ctx, cmp.Image, previous)
+	if err != nil {
+		return humanizef(err, "could not check if %s:%s is installed", cmp.Image, previous)
+	}
+
+	if !installed {
+		if _, err := pullImage(ctx, cmp.Image, previous, true); err != nil {
+			return humanizef(err, "could not install %s:%s", cmp.Image, previous)
+		}
+	}
+
+	if err := switchVersions(ctx, []componentUpgrade{{cmp: cmp, version: previous}}); err != nil {
+		return err
+	}
+
+	// the previous version is only used once, so a second rollback fails
+	// instead of undoing this one
+	if err := daemon.SetPreviousVersion(cmp.Name, ""); err != nil {
+		return humanizef(err, "could not save the version of %s", cmp.Name)
+	}
+
+	log.Infof("%s rolled back to %s", cmp.Image, previous)
+	return nil
+}
+
+// rollbackVersion returns the version the component is rolled back to, the
+// one it used before its last successful upgrade
+func rollbackVersion(cmp components.Component) (string, error) {
+	previous, err := daemon.PreviousVersion(cmp.Name)
+	if err != nil {
+		return "", humanizef(err, "could not read the previous version of %s", cmp.Image)
+	}
+
+	if previous == "" {
+		return "", fmt.Errorf("%s was not upgraded, there is no version to roll back to", cmp.Image)
+	}
+
+	return previous, nil
+}
+
+// componentsStatusCmd represents the components status command
+type componentsStatusCmd struct {
+	Command `name:"status" short-description:"Show the state of source{d} components" long-description:"Show the state of the containers of the components managed by the daemon. All the components are shown if none is given"`
+
+	Args struct {
+		Components []componentArg `positional-arg-name:"component(s)"`
+	} `positional-args:"yes"`
+}
+
+func (c *componentsStatusCmd) Execute(args []string) error {
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var infos []*api.ComponentInfo
+	if len(c.Args.Components) == 0 {
+		res, err := client.ListComponents(ctx, &api.ListComponentsRequest{})
+		if err != nil {
+			return humanizef(err, "could not list the components")
+		}
+
+		infos = res.Components
+	} else {
+		cmps, err := selectUpgradable(componentArgs(c.Args.Components))
+		if err != nil {
+			return err
+		}
+
+		// older daemons only list all the components
+		var all map[string]*api.ComponentInfo
+		if !daemon.Supports(api.FeatureComponentStatus) {
+			res, err := client.ListComponents(ctx, &api.ListComponentsRequest{})
+			if err != nil {
+				return humanizef(err, "could not list the components")
+			}
+
+			all = make(map[string]*api.ComponentInfo)
+			for _, info := range res.Components {
+				all[info.Name] = info
+			}
+		}
+
+		for _, cmp := range cmps {
+			if all != nil {
+				if info, ok := all[cmp.Name]; ok {
+					infos = append(infos, info)
+				}
+
+				continue
+			}
+
+			res, err := client.ComponentStatus(ctx, &api.ComponentStatusRequest{Name: cmp.Name})
+			if err != nil {
+				return humanizef(err, "could not get the status of %s", cmp.Name)
+			}
+
+			infos = append(infos, res.Component)
+		}
+	}
+
+	out := componentsStatusOutput{Components: []componentStatusOutput{}}
+	t := NewTable("%s", "%s", "%s", "%s")
+	t.Header("CONTAINER NAME", "IMAGE", "STATE", "PORT")
+	for _, info := range infos {
+		t.Row(info.Name, info.Image, stateFmt(info.State), portsFmt(info.Ports))
+
+		ports := []int{}
+		for _, p := range info.Ports {
+			ports = append(ports, int(p))
+		}
+
+		out.Components = append(out.Components, componentStatusOutput{
+			Name:  info.Name,
+			Image: info.Image,
+			State: info.State,
+			Ports: ports,
+		})
+	}
+
+	return render(os.Stdout, out, t.Print)
+}
+
+// componentsStatusOutput is the schema of the srcd components status output.
+// State is a docker state, such as running or exited, or not_created
+type componentsStatusOutput struct {
+	Components []componentStatusOutput `json:"components" yaml:"components"`
+}
+
+type componentStatusOutput struct {
+	Name  string `json:"name" yaml:"name"`
+	Image string `json:"image" yaml:"image"`
+	State string `json:"state" yaml:"state"`
+	Ports []int  `json:"ports" yaml:"ports"`
+}
+
+func stateFmt(state string) string {
+	if state == sdk.StateNotCreated {
+		return "not created"
+	}
+
+	return state
+}
+
+func portsFmt(ports []int32) string {
+	strs := make([]string, len(ports))
+	for i, p := range ports {
+		strs[i] = fmt.Sprint(p)
+	}
+
+	return strings.Join(strs, ",")
+}
+
+// componentsStartCmd represents the components start command
+type componentsStartCmd struct {
+	Command `name:"start" short-description:"Start source{d} components" long-description:"Start the components managed by the daemon, and their dependencies, publishing them on the ports set in the config file.\n\nBefore starting gitbase or bblfshd it checks that the docker host has enough\nCPUs, memory and free disk space for them, unless --force is used."`
+
+	Force bool `long:"force" description:"start the components even if the docker host doesn't have enough resources for them"`
+
+	Args struct {
+		Components []componentArg `positional-arg-name:"component(s)" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *componentsStartCmd) Execute(args []string) error {
+	cmps, err := selectUpgradable(componentArgs(c.Args.Components))
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, cmp := range cmps {
+		names = append(names, cmp.Name)
+	}
+
+	if err := checkRequirements(names, c.Force); err != nil {
+		return err
+	}
+
+	return runComponentsAction(componentArgs(c.Args.Components), "start", "starting", func(ctx context.Context, client api.EngineClient, name string) error {
+		port, err := startComponent(ctx, client, name)
+		if err == nil && port != 0 {
+			log.Infof("%s is listening on port %d", name, port)
+		}
+
+		return err
+	})
+}
+
+// componentsStopCmd represents the components stop command
+type componentsStopCmd struct {
+	Command `name:"stop" short-description:"Stop source{d} components" long-description:"Stop and remove the containers of the components managed by the daemon, keeping their volumes"`
+
+	Args struct {
+		Components []componentArg `positional-arg-name:"component(s)" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *componentsStopCmd) Execute(args []string) error {
+	return runComponentsAction(componentArgs(c.Args.Components), "stop", "stopping", func(ctx context.Context, client api.EngineClient, name string) error {
+		_, err := client.StopComponent(ctx, &api.StopComponentRequest{Name: name})
+		return err
+	})
+}
+
+// componentsRestartCmd represents the components restart command
+type componentsRestartCmd struct {
+	Command `name:"restart" short-description:"Restart source{d} components" long-description:"Recreate the containers of the components managed by the daemon with their current configuration, keeping their volumes, also the anonymous ones unless --hard is used. Running components keep their public port"`
+
+	Hard bool `long:"hard" description:"remove the anonymous volumes of the containers too, e.g. to recover a bblfshd that doesn't respond"`
+
+	Args struct {
+		Components []componentArg `positional-arg-name:"component(s)" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *componentsRestartCmd) Execute(args []string) error {
+	return runComponentsAction(componentArgs(c.Args.Components), "restart", "restarting", func(ctx context.Context, client api.EngineClient, name string) error {
+		if err := daemon.RequireFeature(api.FeatureRestartComponent); err != nil {
+			return err
+		}
+
+		port, err := restartComponent(ctx, client, name, c.Hard)
+		if err == nil && port != 0 {
+			log.Infof("%s is listening on port %d", name, port)
+		}
+
+		return err
+	})
+}
+
+// runComponentsAction calls the daemon to run the given lifecycle action on
+// each of the components matching the given container or image names. The
+// progressive form of the action is used in the logs
+func runComponentsAction(
+	names []string,
+	action, progressive string,
+	fn func(ctx context.Context, client api.EngineClient, name string) error,
+) error {
+	cmps, err := selectUpgradable(names)
+	if err != nil {
+		return err
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	// starting a component might have to pull its images
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for _, cmp := range cmps {
+		log.Infof("%s %s", progressive, cmp.Name)
+		if err := fn(ctx, client, cmp.Name); err != nil {
+			return humanizef(err, "could not %s %s", action, cmp.Name)
+		}
+	}
+
+	return nil
+}
+
+// startDependencies starts the components the one with the given container
+// name depends on, see components.Dependencies, and returns their public
+// ports by name. The daemon starts their own dependencies, and waits until
+// all of them are ready
+func startDependencies(client api.EngineClient, name string) (map[string]int, error) {
+	var names []string
+	for _, dep := range components.Dependencies(name) {
+		names = append(names, dep.Name)
+	}
+
+	return startComponents(client, names, false, nil)
+}
+
+// startComponents starts the components with the given container names at
+// the same time, and returns their public ports by name. The daemon starts
+// each one once, after its dependencies are ready. It fails before starting
+// any if the docker host doesn't meet their requirements, unless force is
+// true, see checkRequirements. If started is not nil, it is called as each
+// component is started
+func startComponents(
+	client api.EngineClient,
+	names []string,
+	force bool,
+	started func(name string, port int),
+) (map[string]int, error) {
+	if err := checkRequirements(names, force); err != nil {
+		return nil, err
+	}
+
+	// starting a component might have to pull its images
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	type result struct {
+		name string
+		port int
+		err  error
+	}
+
+	results := make(chan result, len(names))
+	for _, name := range names {
+		go func(name string) {
+			port, err := startComponent(ctx, client, name)
+			if err != nil {
+				results <- result{name: name, err: err}
+				return
+			}
+
+			results <- result{name: name, port: port}
+		}(name)
+	}
+
+	ports := make(map[string]int, len(names))
+	var firstErr error
+	for range names {
+		r := <-results
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = humanizef(r.err, "could not start %s", r.name)
+			}
+
+			continue
+		}
+
+		ports[r.name] = r.port
+		if started != nil {
+			started(r.name, r.port)
+		}
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return ports, nil
+}
+
 func init() {
 	c := rootCmd.AddCommand(&componentsCmd{})
 	c.AddCommand(&componentsListCmd{})
 	c.AddCommand(&componentsInstallCmd{})
+	c.AddCommand(&componentsStatusCmd{})
+	c.AddCommand(&componentsStartCmd{})
+	c.AddCommand(&componentsStopCmd{})
+	c.AddCommand(&componentsRestartCmd{})
+	c.AddCommand(&componentsUpgradeCmd{})
+	c.AddCommand(&componentsRollbackCmd{})
 }
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/src-d/engine/components"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectUpgradable(t *testing.T) {
+	assert := assert.New(t)
+
+	cmps, err := selectUpgradable(nil)
+	assert.NoError(err)
+	assert.Equal(components.Upgradable(), cmps)
+
+	cmps, err = selectUpgradable([]string{"srcd-cli-gitbase", "bblfsh/web"})
+	assert.NoError(err)
+	if assert.Len(cmps, 2) {
+		assert.Equal(components.Gitbase.Name, cmps[0].Name)
+		assert.Equal(components.BblfshWeb.Name, cmps[1].Name)
+	}
+
+	_, err = selectUpgradable([]string{"srcd/cli-daemon"})
+	assert.Error(err)
+}
+
+func TestComponentStatusFmt(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("running", stateFmt("running"))
+	assert.Equal("not created", stateFmt("not_created"))
+
+	assert.Equal("", portsFmt(nil))
+	assert.Equal("3306,8080", portsFmt([]int32{3306, 8080}))
+}
+
+func TestSwitchUpgradesPrevious(t *testing.T) {
+	require := require.New(t)
+
+	home, err := ioutil.TempDir("", "srcd-home")
+	require.NoError(err)
+	defer os.RemoveAll(home)
+
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	require.NoError(os.Setenv("HOME", home))
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	var switchErr error
+	defer func(fn func(context.Context, []componentUpgrade) error) { switchVersionsFunc = fn }(switchVersionsFunc)
+	switchVersionsFunc = func(context.Context, []componentUpgrade) error { return switchErr }
+
+	cmp := components.Gitbase
+	cmp.Version = "v0.23.0"
+	upgrade := []componentUpgrade{{cmp: cmp, version: "v0.24.0"}}
+
+	// a failed switch does not save the version
+	switchErr = fmt.Errorf("could not restart the daemon")
+	require.EqualError(switchUpgrades(context.Background(), upgrade), "could not restart the daemon")
+	_, err = rollbackVersion(cmp)
+	require.EqualError(err, "srcd/gitbase was not upgraded, there is no version to roll back to")
+
+	switchErr = nil
+	require.NoError(switchUpgrades(context.Background(), upgrade))
+	previous, err := rollbackVersion(cmp)
+	require.NoError(err)
+	require.Equal("v0.23.0", previous)
+
+	// the next upgrade fails, so the rollback goes to the same version
+	cmp.Version = "v0.24.0"
+	switchErr = fmt.Errorf("could not start srcd-cli-gitbase")
+	require.Error(switchUpgrades(context.Background(), []componentUpgrade{{cmp: cmp, version: "v0.25.0"}}))
+	previous, err = rollbackVersion(cmp)
+	require.NoError(err)
+	require.Equal("v0.23.0", previous)
+}
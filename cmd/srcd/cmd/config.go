@@ -0,0 +1,429 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"gopkg.in/src-d/go-cli.v0"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// hiddenSecret replaces the passwords in the output of srcd config show
+const hiddenSecret = "********"
+
+// configCmd represents the config command
+type configCmd struct {
+	cli.PlainCommand `name:"config" short-description:"Check the config file" long-description:"Validate the config file and show the configuration in use"`
+}
+
+// configValidateCmd represents the config validate command
+type configValidateCmd struct {
+	Command `name:"validate" short-description:"Validate the config file" long-description:"Validate the config file, or the given one, against the schema of the config file and the rules of its values, listing all the errors found with their line.\n\nThe schema is printed by srcd config schema."`
+
+	Args struct {
+		File string `positional-arg-name:"file" description:"config file to validate, the one in use by default"`
+	} `positional-args:"yes"`
+}
+
+// Init doesn't read the config file, so its errors are reported by Execute
+func (c *configValidateCmd) Init(a *cli.App) error {
+	return c.initWithoutConfig(a)
+}
+
+// initWithoutConfig sets the logging and the profile like Init, but doesn't
+// read the config file, for the commands that work with invalid ones
+func (c Command) initWithoutConfig(a *cli.App) error {
+	if err := c.initLogging(a); err != nil {
+		return err
+	}
+
+	return components.SetProfile(string(globalOptions.Profile))
+}
+
+func (c *configValidateCmd) Execute(args []string) error {
+	file := c.Args.File
+	if file == "" {
+		file = c.Config
+	}
+
+	path, err := config.FilePath(file)
+	if err != nil {
+		return humanizef(err, "could not find the config file")
+	}
+
+	result := &configValidation{File: path, Exists: true, Errors: []configValidationError{}}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && file == "" {
+		result.Exists = false
+	} else if err != nil {
+		return humanizef(err, "could not read the config file")
+	}
+
+	for _, e := range api.ValidateConfig(content) {
+		result.Errors = append(result.Errors, configValidationError{
+			Line:    e.Line,
+			Key:     e.Key,
+			Message: e.Message,
+		})
+	}
+
+	result.Valid = len(result.Errors) == 0
+	if err := render(os.Stdout, result, result.Print); err != nil {
+		return err
+	}
+
+	if !result.Valid {
+		return fmt.Errorf("the config file %s is not valid", path)
+	}
+
+	return nil
+}
+
+// configValidation is the output of srcd config validate
+type configValidation struct {
+	File string `json:"file" yaml:"file"`
+	// Exists is false when the default config file does not exist
+	Exists bool                    `json:"exists" yaml:"exists"`
+	Valid  bool                    `json:"valid" yaml:"valid"`
+	Errors []configValidationError `json:"errors" yaml:"errors"`
+}
+
+type configValidationError struct {
+	// Line is 0 when it is not known
+	Line    int    `json:"line" yaml:"line"`
+	Key     string `json:"key" yaml:"key"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (r *configValidation) Print(w io.Writer) error {
+	if !r.Exists {
+		_, err := fmt.Fprintf(w, "there is no config file at %s, the default values are used\n", r.File)
+		return err
+	}
+
+	if r.Valid {
+		_, err := fmt.Fprintf(w, "%s is valid\n", r.File)
+		return err
+	}
+
+	for _, e := range r.Errors {
+		location := r.File
+		if e.Line > 0 {
+			location = fmt.Sprintf("%s:%d", r.File, e.Line)
+		}
+
+		msg := e.Message
+		if e.Key != "" && !strings.Contains(msg, e.Key) {
+			msg = e.Key + ": " + msg
+		}
+
+		if _, err := fmt.Fprintf(w, "%s: %s\n", location, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configShowCmd represents the config show command
+type configShowCmd struct {
+	Command `name:"show" short-description:"Show the configuration in use" long-description:"Show the configuration in use: the values of the config file, with the default values of the missing ones, the gitbase shards planned with srcd shards plan, the component versions upgraded with srcd components upgrade, and the proxy settings of the environment.\n\nThe passwords are hidden unless --show-secrets is given."`
+
+	ShowSecrets bool `long:"show-secrets" description:"show the passwords instead of hiding them"`
+}
+
+func (c *configShowCmd) Execute(args []string) error {
+	conf, err := effectiveConfig()
+	if err != nil {
+		return humanizef(err, "could not resolve the configuration")
+	}
+
+	if !c.ShowSecrets {
+		hideSecrets(conf)
+	}
+
+	path, err := config.FilePath(c.Config)
+	if err != nil {
+		return humanizef(err, "could not find the config file")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		path = ""
+	}
+
+	project, err := config.ProjectFile()
+	if err != nil {
+		return humanizef(err, "could not find the project config file")
+	}
+
+	doc, err := jsonDocument(conf)
+	if err != nil {
+		return err
+	}
+
+	return render(os.Stdout, doc, func(w io.Writer) error {
+		if path == "" {
+			fmt.Fprintln(w, "# config file: none, the default values are used")
+		} else {
+			fmt.Fprintf(w, "# config file: %s\n", path)
+		}
+
+		if project != "" {
+			fmt.Fprintf(w, "# project config file: %s\n", project)
+		}
+
+		if p := components.Profile(); p != "" {
+			fmt.Fprintf(w, "# profile: %s\n", p)
+		}
+
+		_, err := io.WriteString(w, conf.AsYaml())
+		return err
+	})
+}
+
+// effectiveConfig returns a copy of the config in use, with the values
+// resolved by srcd
+func effectiveConfig() (*api.Config, error) {
+	// the copy is decoded from the file, so the maps are not shared
+	var conf api.Config
+	if err := yaml.Unmarshal([]byte(config.File.AsYaml()), &conf); err != nil {
+		return nil, err
+	}
+
+	conf.SetDefaults()
+	conf.SetDefaultUser(runtime.GOOS)
+	for _, cmp := range components.Upgradable() {
+		conf.SetComponentVersion(cmp.Name, cmp.Version)
+	}
+
+	// the proxy settings of the config file are set in the environment too
+	proxy := docker.ProxyFromEnvironment()
+	conf.Proxy.HTTP = proxy.HTTP
+	conf.Proxy.HTTPS = proxy.HTTPS
+	conf.Proxy.NoProxy = proxy.NoProxy
+
+	if conf.Network.Name == "" {
+		conf.Network.Name = docker.NetworkName
+	}
+
+	endpoint, err := conf.TracingEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	conf.Tracing.Endpoint = endpoint
+	return &conf, nil
+}
+
+// hideSecrets replaces the passwords of the config
+func hideSecrets(conf *api.Config) {
+	hide := func(s *string) {
+		if *s != "" {
+			*s = hiddenSecret
+		}
+	}
+
+	hide(&conf.Components.Gitbase.Password)
+	hide(&conf.Components.Analytics.Password)
+	hide(&conf.Retrieval.GithubToken)
+	for name, conn := range conf.SQL.Connections {
+		hide(&conn.Password)
+		conf.SQL.Connections[name] = conn
+	}
+}
+
+// jsonDocument returns v as decoded from its YAML document, with string keys
+// so it can be encoded as JSON with the keys of the yaml tags
+func jsonDocument(v interface{}) (interface{}, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	return stringKeys(doc), nil
+}
+
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+
+		return v
+	default:
+		return v
+	}
+}
+
+// configGetCmd represents the config get command
+type configGetCmd struct {
+	Command `name:"get" short-description:"Print a value of the configuration in use" long-description:"Print the value of a key of the configuration in use, like components.gitbase.port, which is the default one if it is not set in the config file. See srcd config show."`
+
+	Args struct {
+		Key string `positional-arg-name:"key" required:"yes" description:"key of the value, like components.gitbase.port"`
+	} `positional-args:"yes"`
+}
+
+func (c *configGetCmd) Execute(args []string) error {
+	keys, err := api.ConfigKeys(c.Args.Key)
+	if err != nil {
+		return err
+	}
+
+	conf, err := effectiveConfig()
+	if err != nil {
+		return humanizef(err, "could not resolve the configuration")
+	}
+
+	doc, err := jsonDocument(conf)
+	if err != nil {
+		return err
+	}
+
+	// the keys that are not set are omitted from the document
+	value := doc
+	for _, k := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			value = nil
+			break
+		}
+
+		value = m[k]
+	}
+
+	result := &configValue{Key: c.Args.Key, Value: value}
+	return render(os.Stdout, result, result.Print)
+}
+
+// configValue is the output of srcd config get
+type configValue struct {
+	Key   string      `json:"key" yaml:"key"`
+	Value interface{} `json:"value" yaml:"value"`
+}
+
+func (v *configValue) Print(w io.Writer) error {
+	switch value := v.Value.(type) {
+	case nil:
+		_, err := fmt.Fprintln(w)
+		return err
+	case map[string]interface{}, []interface{}:
+		b, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(b)
+		return err
+	default:
+		_, err := fmt.Fprintln(w, value)
+		return err
+	}
+}
+
+// configSetCmd represents the config set command
+type configSetCmd struct {
+	Command `name:"set" short-description:"Set a value in the config file" long-description:"Set the value of a key of the config file, like components.gitbase.port, keeping the rest of the file and its comments. The lists and mappings are given in YAML flow style, like [a, b] or {key: value}.\n\nThe file is only saved if it is valid with the new value. Most changes are applied when the daemon is restarted with srcd init."`
+
+	Args struct {
+		Key   string `positional-arg-name:"key" required:"yes" description:"key of the value, like components.gitbase.port"`
+		Value string `positional-arg-name:"value" required:"yes" description:"new value"`
+	} `positional-args:"yes"`
+}
+
+// Init doesn't read the config file, so an invalid one can be fixed
+func (c *configSetCmd) Init(a *cli.App) error {
+	return c.initWithoutConfig(a)
+}
+
+func (c *configSetCmd) Execute(args []string) error {
+	path, err := setConfigValue(c.Config, c.Args.Key, c.Args.Value)
+	if err != nil {
+		return err
+	}
+
+	if isTextOutput() {
+		fmt.Printf("%s set in %s\n", c.Args.Key, path)
+	}
+
+	return nil
+}
+
+// setConfigValue sets the value of a key of the config file given with
+// --config, or the default one, and returns its path. The file is only saved
+// if it is valid with the new value
+func setConfigValue(file, key, value string) (string, error) {
+	path, err := config.FilePath(file)
+	if err != nil {
+		return "", humanizef(err, "could not find the config file")
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !(os.IsNotExist(err) && file == "") {
+		return "", humanizef(err, "could not read the config file")
+	}
+
+	content, err = api.SetConfigValue(content, key, value)
+	if err != nil {
+		return "", err
+	}
+
+	if errs := api.ValidateConfig(content); len(errs) > 0 {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+
+		return "", fmt.Errorf("the config file would not be valid, it was not changed:\n%s",
+			strings.Join(msgs, "\n"))
+	}
+
+	if err := config.Write(file, content); err != nil {
+		return "", humanizef(err, "could not save the config file")
+	}
+
+	return path, nil
+}
+
+// configSchemaCmd represents the config schema command
+type configSchemaCmd struct {
+	cli.PlainCommand `name:"schema" short-description:"Print the JSON schema of the config file" long-description:"Print the JSON schema of the config file, to validate it or complete its keys in an editor"`
+}
+
+func (c *configSchemaCmd) Execute(args []string) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(api.ConfigSchema())
+}
+
+func init() {
+	c := rootCmd.AddCommand(&configCmd{})
+	c.AddCommand(&configValidateCmd{})
+	c.AddCommand(&configShowCmd{})
+	c.AddCommand(&configGetCmd{})
+	c.AddCommand(&configSetCmd{})
+	c.AddCommand(&configSchemaCmd{})
+}
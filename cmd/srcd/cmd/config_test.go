@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/src-d/engine/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidationPrint(t *testing.T) {
+	require := require.New(t)
+
+	r := &configValidation{
+		File:   "config.yml",
+		Exists: true,
+		Errors: []configValidationError{
+			{Line: 3, Key: "components.gitbase.prot", Message: "unknown key, did you mean port?"},
+			{Line: 8, Key: "images.keep", Message: "invalid images.keep: -1, it can't be negative"},
+			{Message: "did not find expected key"},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(r.Print(&buf))
+	require.Equal(`config.yml:3: components.gitbase.prot: unknown key, did you mean port?
+config.yml:8: invalid images.keep: -1, it can't be negative
+config.yml: did not find expected key
+`, buf.String())
+
+	buf.Reset()
+	r = &configValidation{File: "config.yml", Exists: true, Valid: true}
+	require.NoError(r.Print(&buf))
+	require.Equal("config.yml is valid\n", buf.String())
+}
+
+func TestHideSecrets(t *testing.T) {
+	require := require.New(t)
+
+	var conf api.Config
+	conf.Components.Gitbase.Password = "secret"
+	conf.SQL.Connections = map[string]api.SQLConnection{
+		"prod": {Host: "mysql", Password: "secret"},
+		"dev":  {Host: "localhost"},
+	}
+
+	hideSecrets(&conf)
+	require.Equal(hiddenSecret, conf.Components.Gitbase.Password)
+	require.Equal("", conf.Components.Analytics.Password)
+	require.Equal(hiddenSecret, conf.SQL.Connections["prod"].Password)
+	require.Equal("", conf.SQL.Connections["dev"].Password)
+
+	doc, err := jsonDocument(&conf)
+	require.NoError(err)
+	sql := doc.(map[string]interface{})["sql"].(map[string]interface{})
+	require.Equal("mysql", sql["connections"].(map[string]interface{})["prod"].(map[string]interface{})["host"])
+}
+
+func TestConfigValuePrint(t *testing.T) {
+	require := require.New(t)
+
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{3306, "3306\n"},
+		{"v2.7.1", "v2.7.1\n"},
+		{nil, "\n"},
+		{map[string]interface{}{"go": "v2.7.1"}, "go: v2.7.1\n"},
+		{[]interface{}{"a", "b"}, "- a\n- b\n"},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		v := &configValue{Key: "key", Value: c.value}
+		require.NoError(v.Print(&buf))
+		require.Equal(c.expected, buf.String())
+	}
+}
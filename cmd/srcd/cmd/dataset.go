@@ -0,0 +1,576 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+
+	units "github.com/docker/go-units"
+	enry "gopkg.in/src-d/enry.v1"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	// datasetManifest is the file that describes a dataset version
+	datasetManifest = "manifest.json"
+	// datasetFormat is the format of the table files, compressed with gzip
+	datasetFormat = "jsonl"
+	// datasetBatchSize is the number of rows read from gitbase at a time
+	datasetBatchSize = 100
+)
+
+// datasetVersionRegexp matches the versions numbered by srcd export dataset
+var datasetVersionRegexp = regexp.MustCompile(`^v([0-9]+)$`)
+
+// exportDatasetCmd represents the export dataset command
+type exportDatasetCmd struct {
+	Command `name:"dataset" short-description:"Write the files, commits or UASTs of the repositories as a dataset" long-description:"Extract the files, commits or UASTs of the analyzed repositories, filtered by language and repository, and write them as a dataset for machine learning pipelines.\n\nEach table is written in a JSON Lines file compressed with gzip, and manifest.json describes the columns of each table, the checksums of the files, the options of the extraction and the repositories it was extracted from, with their HEAD commit. Every export is a new version of the dataset, in <dir>/<name>/<version>, numbered v1, v2 and so on by default.\n\ngitbase is started if it is not running."`
+
+	Name        string   `long:"name" default:"dataset" description:"name of the dataset, the directory of its versions"`
+	Version     string   `long:"version" description:"version of the dataset, the next one of the form vN by default"`
+	Tables      []string `short:"t" long:"table" choice:"files" choice:"commits" choice:"uasts" description:"table to extract, can be repeated (default: files and commits)"`
+	Langs       []string `short:"l" long:"lang" description:"only extract the files and UASTs of this language, can be repeated"`
+	Repos       []string `short:"r" long:"repo" description:"only extract the data of this repository, can be repeated"`
+	MaxFileSize string   `long:"max-file-size" default:"1MB" description:"skip the files bigger than this size"`
+
+	Args struct {
+		Dir string `positional-arg-name:"dir" required:"yes" description:"directory of the datasets"`
+	} `positional-args:"yes"`
+}
+
+func (c *exportDatasetCmd) Execute(args []string) error {
+	opts, err := c.options()
+	if err != nil {
+		return err
+	}
+
+	root := filepath.Join(c.Args.Dir, c.Name)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return humanizef(err, "could not create the dataset directory")
+	}
+
+	v := c.Version
+	if v == "" {
+		if v, err = nextDatasetVersion(root); err != nil {
+			return humanizef(err, "could not read the versions of the dataset")
+		}
+	}
+
+	dir := filepath.Join(root, v)
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("version %s of the dataset already exists in %s", v, dir)
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	if _, err := startGitbaseWithClient(client); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for _, t := range datasetTables(opts) {
+		for _, q := range t.queries {
+			if err := checkUASTDrivers(ctx, client, q, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	manifest := &datasetManifestFile{
+		Name:        c.Name,
+		Version:     v,
+		CreatedAt:   time.Now().UTC(),
+		Format:      datasetFormat,
+		Compression: "gzip",
+		Generator:   datasetGenerator{Name: "srcd export dataset", Version: version},
+		Extraction:  opts,
+	}
+
+	manifest.Source, err = readDatasetSource(ctx, client, opts)
+	if err != nil {
+		return humanizef(err, "could not read the repositories")
+	}
+
+	// the version is written in a temporary directory, so a failed export
+	// doesn't leave an incomplete one
+	tmp, err := ioutil.TempDir(root, "."+v+"-")
+	if err != nil {
+		return humanizef(err, "could not create the dataset directory")
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, t := range datasetTables(opts) {
+		stop := logAfterTimeoutWithSpinner(fmt.Sprintf("extracting the %s", t.name), time.Second, 0)
+		table, err := writeDatasetTable(tmp, t, func(fn func([]string) error) error {
+			for _, q := range t.queries {
+				if err := streamRows(ctx, client, q, fn); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		stop()
+		if err != nil {
+			return humanizef(err, "could not extract the %s", t.name)
+		}
+
+		log.Infof("%d %s extracted", table.Rows, t.name)
+		manifest.Tables = append(manifest.Tables, *table)
+	}
+
+	if err := writeDatasetManifest(tmp, manifest); err != nil {
+		return humanizef(err, "could not write the dataset manifest")
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		return humanizef(err, "could not save the dataset in %s", dir)
+	}
+
+	result := &datasetResult{Dir: dir, Version: v, Tables: manifest.Tables}
+	return render(os.Stdout, result, result.Print)
+}
+
+// options returns the extraction options of the flags, with the canonical
+// names of the languages
+func (c *exportDatasetCmd) options() (datasetOptions, error) {
+	opts := datasetOptions{
+		Tables:       c.Tables,
+		Languages:    []string{},
+		Repositories: c.Repos,
+	}
+
+	if len(opts.Tables) == 0 {
+		opts.Tables = []string{"files", "commits"}
+	}
+
+	if opts.Repositories == nil {
+		opts.Repositories = []string{}
+	}
+
+	for _, l := range c.Langs {
+		name, ok := enry.GetLanguageByAlias(l)
+		if !ok {
+			return opts, fmt.Errorf("unknown language %s", l)
+		}
+
+		opts.Languages = append(opts.Languages, name)
+	}
+
+	if containsString(opts.Tables, "uasts") && len(opts.Languages) == 0 {
+		return opts, fmt.Errorf("the uasts table needs the languages to parse, " +
+			"give them with --lang, like --lang go")
+	}
+
+	size, err := units.FromHumanSize(c.MaxFileSize)
+	if err != nil || size <= 0 {
+		return opts, fmt.Errorf("invalid --max-file-size %q, it must be a size like 1MB", c.MaxFileSize)
+	}
+
+	opts.MaxFileSize = size
+	return opts, nil
+}
+
+// datasetOptions are the options of the extraction, saved in the manifest
+type datasetOptions struct {
+	Tables       []string `json:"tables"`
+	Languages    []string `json:"languages"`
+	Repositories []string `json:"repositories"`
+	MaxFileSize  int64    `json:"max_file_size"`
+}
+
+// datasetTable is a table of the dataset, extracted with gitbase queries
+// whose rows are concatenated
+type datasetTable struct {
+	name    string
+	columns []datasetColumn
+	queries []string
+}
+
+// datasetColumn is a column of a table of the dataset. The type is string,
+// int64, timestamp, in the format of gitbase, or bytes, encoded in base64
+type datasetColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Description is set for the columns that need it, like the encoding of
+	// the UASTs
+	Description string `json:"description,omitempty"`
+}
+
+// datasetTables returns the tables of the extraction, with their queries
+func datasetTables(opts datasetOptions) []datasetTable {
+	// the files of the HEAD of each repository
+	head := []string{"r.ref_name = 'HEAD'", "NOT IS_BINARY(f.blob_content)", "NOT IS_VENDOR(f.file_path)",
+		fmt.Sprintf("f.blob_size <= %d", opts.MaxFileSize)}
+	if len(opts.Repositories) > 0 {
+		head = append(head, "f.repository_id IN "+sqlStringList(opts.Repositories))
+	}
+
+	files := head[:len(head):len(head)]
+	if len(opts.Languages) > 0 {
+		files = append(files, "LANGUAGE(f.file_path, f.blob_content) IN "+sqlStringList(opts.Languages))
+	}
+
+	var tables []datasetTable
+	for _, name := range opts.Tables {
+		switch name {
+		case "files":
+			tables = append(tables, datasetTable{
+				name: name,
+				columns: []datasetColumn{
+					{Name: "repository_id", Type: "string"},
+					{Name: "file_path", Type: "string"},
+					{Name: "blob_hash", Type: "string"},
+					{Name: "language", Type: "string"},
+					{Name: "size", Type: "int64"},
+					{Name: "content", Type: "string"},
+				},
+				queries: []string{"SELECT f.repository_id, f.file_path, f.blob_hash, " +
+					"LANGUAGE(f.file_path, f.blob_content), f.blob_size, f.blob_content " +
+					"FROM refs r NATURAL JOIN commit_files cf NATURAL JOIN files f " +
+					"WHERE " + strings.Join(files, " AND ")},
+			})
+		case "commits":
+			query := "SELECT repository_id, commit_hash, commit_author_name, commit_author_email, " +
+				"commit_author_when, committer_name, committer_email, committer_when, commit_message " +
+				"FROM commits"
+			if len(opts.Repositories) > 0 {
+				query += " WHERE repository_id IN " + sqlStringList(opts.Repositories)
+			}
+
+			tables = append(tables, datasetTable{
+				name: name,
+				columns: []datasetColumn{
+					{Name: "repository_id", Type: "string"},
+					{Name: "commit_hash", Type: "string"},
+					{Name: "author_name", Type: "string"},
+					{Name: "author_email", Type: "string"},
+					{Name: "author_when", Type: "timestamp"},
+					{Name: "committer_name", Type: "string"},
+					{Name: "committer_email", Type: "string"},
+					{Name: "committer_when", Type: "timestamp"},
+					{Name: "message", Type: "string"},
+				},
+				queries: []string{query},
+			})
+		case "uasts":
+			// one query per language, to parse it with the driver named after it
+			var queries []string
+			for _, lang := range opts.Languages {
+				conds := append(head[:len(head):len(head)],
+					"LANGUAGE(f.file_path, f.blob_content) = "+sqlString(lang))
+				queries = append(queries, fmt.Sprintf("SELECT f.repository_id, f.file_path, f.blob_hash, "+
+					"%s, UAST(f.blob_content, %s) "+
+					"FROM refs r NATURAL JOIN commit_files cf NATURAL JOIN files f "+
+					"WHERE %s",
+					sqlString(lang), sqlString(strings.ToLower(lang)), strings.Join(conds, " AND ")))
+			}
+
+			tables = append(tables, datasetTable{
+				name: name,
+				columns: []datasetColumn{
+					{Name: "repository_id", Type: "string"},
+					{Name: "file_path", Type: "string"},
+					{Name: "blob_hash", Type: "string"},
+					{Name: "language", Type: "string"},
+					{Name: "uast", Type: "bytes", Description: "nodes of the bblfsh UAST, " +
+						"in the format of the gitbase UAST function, encoded in base64"},
+				},
+				queries: queries,
+			})
+		}
+	}
+
+	return tables
+}
+
+// sqlString returns s as a SQL string literal
+func sqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// sqlStringList returns the values as a SQL list of string literals
+func sqlStringList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = sqlString(v)
+	}
+
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// streamRows runs a query and calls fn with each row of the result, without
+// keeping them in memory. The first row of the result, with the column names,
+// is skipped
+func streamRows(ctx context.Context, client api.EngineClient, query string, fn func([]string) error) error {
+	stream, err := client.SQL(ctx, &api.SQLRequest{Query: query, BatchSize: datasetBatchSize})
+	if err != nil {
+		return err
+	}
+
+	header := true
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		batch := resp.Rows
+		if resp.Row != nil {
+			batch = append(batch, resp.Row)
+		}
+
+		for _, r := range batch {
+			if header {
+				header = false
+				continue
+			}
+
+			row := make([]string, len(r.Cell))
+			for i, c := range r.Cell {
+				row[i] = string(c)
+			}
+
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeDatasetTable writes the rows of a table, read with the given function,
+// in its file of the directory, and returns its description for the manifest
+func writeDatasetTable(
+	dir string,
+	t datasetTable,
+	read func(func([]string) error) error,
+) (*datasetTableFile, error) {
+	table := &datasetTableFile{
+		Name:    t.name,
+		File:    t.name + "." + datasetFormat + ".gz",
+		Columns: t.columns,
+		Queries: t.queries,
+	}
+
+	f, err := os.Create(filepath.Join(dir, table.File))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sum := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, sum)}
+	gz := gzip.NewWriter(counter)
+	enc := json.NewEncoder(gz)
+	enc.SetEscapeHTML(false)
+
+	err = read(func(row []string) error {
+		if len(row) != len(t.columns) {
+			return fmt.Errorf("the query returned %d columns, expected %d", len(row), len(t.columns))
+		}
+
+		record := make(map[string]interface{}, len(row))
+		for i, col := range t.columns {
+			record[col.Name] = datasetValue(col.Type, row[i])
+		}
+
+		table.Rows++
+		return enc.Encode(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	table.Size = counter.n
+	table.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	return table, nil
+}
+
+// datasetValue returns the value of a cell for the JSON record, with the type
+// of its column. The cells that can't be converted are kept as strings
+func datasetValue(typ, cell string) interface{} {
+	switch typ {
+	case "int64":
+		if n, err := strconv.ParseInt(cell, 10, 64); err == nil {
+			return n
+		}
+	case "bytes":
+		return []byte(cell)
+	}
+
+	return cell
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// nextDatasetVersion returns the version after the newest one of the form vN
+// in the directory of the dataset, v1 if there is none
+func nextDatasetVersion(root string) (string, error) {
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		return "", err
+	}
+
+	last := 0
+	for _, info := range infos {
+		m := datasetVersionRegexp.FindStringSubmatch(info.Name())
+		if !info.IsDir() || m == nil {
+			continue
+		}
+
+		if n, err := strconv.Atoi(m[1]); err == nil && n > last {
+			last = n
+		}
+	}
+
+	return fmt.Sprintf("v%d", last+1), nil
+}
+
+// datasetManifestFile is the content of manifest.json
+type datasetManifestFile struct {
+	Name        string             `json:"name"`
+	Version     string             `json:"version"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Format      string             `json:"format"`
+	Compression string             `json:"compression"`
+	Generator   datasetGenerator   `json:"generator"`
+	Source      datasetSource      `json:"source"`
+	Extraction  datasetOptions     `json:"extraction"`
+	Tables      []datasetTableFile `json:"tables"`
+}
+
+type datasetGenerator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// datasetSource is the provenance of the dataset
+type datasetSource struct {
+	GitbaseVersion string              `json:"gitbase_version"`
+	Repositories   []datasetRepository `json:"repositories"`
+}
+
+type datasetRepository struct {
+	ID string `json:"id"`
+	// Head is the commit of the HEAD reference when the dataset was
+	// extracted
+	Head string `json:"head"`
+}
+
+// datasetTableFile describes a table file of the dataset
+type datasetTableFile struct {
+	Name    string          `json:"name" yaml:"name"`
+	File    string          `json:"file" yaml:"file"`
+	Rows    int64           `json:"rows" yaml:"rows"`
+	Size    int64           `json:"size" yaml:"size"`
+	SHA256  string          `json:"sha256" yaml:"sha256"`
+	Columns []datasetColumn `json:"columns" yaml:"-"`
+	Queries []string        `json:"queries" yaml:"-"`
+}
+
+// readDatasetSource reads the gitbase version and the HEAD commit of the
+// repositories of the extraction
+func readDatasetSource(ctx context.Context, client api.EngineClient, opts datasetOptions) (datasetSource, error) {
+	source := datasetSource{Repositories: []datasetRepository{}}
+
+	_, rows, err := queryRows(ctx, client, "SELECT VERSION()")
+	if err != nil {
+		return source, err
+	}
+
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		source.GitbaseVersion = rows[0][0]
+	}
+
+	query := "SELECT repository_id, commit_hash FROM refs WHERE ref_name = 'HEAD'"
+	if len(opts.Repositories) > 0 {
+		query += " AND repository_id IN " + sqlStringList(opts.Repositories)
+	}
+
+	_, rows, err = queryRows(ctx, client, query)
+	if err != nil {
+		return source, err
+	}
+
+	for _, row := range rows {
+		if len(row) == 2 {
+			source.Repositories = append(source.Repositories, datasetRepository{ID: row[0], Head: row[1]})
+		}
+	}
+
+	sort.Slice(source.Repositories, func(i, j int) bool {
+		return source.Repositories[i].ID < source.Repositories[j].ID
+	})
+
+	return source, nil
+}
+
+func writeDatasetManifest(dir string, m *datasetManifestFile) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, datasetManifest), append(b, '\n'), 0644)
+}
+
+// datasetResult is the output of srcd export dataset
+type datasetResult struct {
+	Dir     string             `json:"dir" yaml:"dir"`
+	Version string             `json:"version" yaml:"version"`
+	Tables  []datasetTableFile `json:"tables" yaml:"tables"`
+}
+
+func (r *datasetResult) Print(w io.Writer) error {
+	table := NewTable("%s", "%s", "%d", "%s")
+	table.Header("TABLE", "FILE", "ROWS", "SIZE")
+	for _, t := range r.Tables {
+		table.Row(t.Name, t.File, t.Rows, units.BytesSize(float64(t.Size)))
+	}
+
+	if err := table.Print(w); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\ndataset %s saved in %s\n", r.Version, r.Dir)
+	return err
+}
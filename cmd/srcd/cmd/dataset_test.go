@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportDatasetOptions(t *testing.T) {
+	require := require.New(t)
+
+	c := &exportDatasetCmd{MaxFileSize: "1MB"}
+	opts, err := c.options()
+	require.NoError(err)
+	require.Equal(datasetOptions{
+		Tables:       []string{"files", "commits"},
+		Languages:    []string{},
+		Repositories: []string{},
+		MaxFileSize:  1000000,
+	}, opts)
+
+	c = &exportDatasetCmd{Tables: []string{"uasts"}, Langs: []string{"golang", "python"}, MaxFileSize: "10KB"}
+	opts, err = c.options()
+	require.NoError(err)
+	require.Equal([]string{"Go", "Python"}, opts.Languages)
+
+	_, err = (&exportDatasetCmd{Tables: []string{"uasts"}, MaxFileSize: "1MB"}).options()
+	require.EqualError(err, "the uasts table needs the languages to parse, give them with --lang, like --lang go")
+
+	_, err = (&exportDatasetCmd{Langs: []string{"nope"}, MaxFileSize: "1MB"}).options()
+	require.EqualError(err, "unknown language nope")
+
+	_, err = (&exportDatasetCmd{MaxFileSize: "big"}).options()
+	require.Error(err)
+}
+
+func TestDatasetTables(t *testing.T) {
+	require := require.New(t)
+
+	tables := datasetTables(datasetOptions{
+		Tables:       []string{"files", "commits", "uasts"},
+		Languages:    []string{"Go", "Python"},
+		Repositories: []string{"engine", "o'brien"},
+		MaxFileSize:  100,
+	})
+	require.Len(tables, 3)
+
+	require.Equal("files", tables[0].name)
+	require.Equal([]string{"SELECT f.repository_id, f.file_path, f.blob_hash, " +
+		"LANGUAGE(f.file_path, f.blob_content), f.blob_size, f.blob_content " +
+		"FROM refs r NATURAL JOIN commit_files cf NATURAL JOIN files f " +
+		"WHERE r.ref_name = 'HEAD' AND NOT IS_BINARY(f.blob_content) AND NOT IS_VENDOR(f.file_path) " +
+		"AND f.blob_size <= 100 AND f.repository_id IN ('engine', 'o''brien') " +
+		"AND LANGUAGE(f.file_path, f.blob_content) IN ('Go', 'Python')"}, tables[0].queries)
+
+	require.Equal("commits", tables[1].name)
+	require.Len(tables[1].queries, 1)
+	require.Contains(tables[1].queries[0], "FROM commits WHERE repository_id IN ('engine', 'o''brien')")
+
+	require.Equal("uasts", tables[2].name)
+	require.Len(tables[2].queries, 2)
+	require.Contains(tables[2].queries[0], "'Go', UAST(f.blob_content, 'go')")
+	require.Contains(tables[2].queries[1], "AND LANGUAGE(f.file_path, f.blob_content) = 'Python'")
+	require.Equal([]uastCall{{name: "uast", args: []string{"f.blob_content", "'python'"}}},
+		parseUASTCalls(tables[2].queries[1]))
+}
+
+func TestWriteDatasetTable(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-dataset")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	table := datasetTable{
+		name: "files",
+		columns: []datasetColumn{
+			{Name: "file_path", Type: "string"},
+			{Name: "size", Type: "int64"},
+			{Name: "uast", Type: "bytes"},
+		},
+		queries: []string{"SELECT 1"},
+	}
+
+	rows := [][]string{{"main.go", "12", "\x00\x01"}, {"<b>.go", "NULL", ""}}
+	file, err := writeDatasetTable(dir, table, func(fn func([]string) error) error {
+		for _, r := range rows {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	require.NoError(err)
+	require.Equal("files", file.Name)
+	require.Equal("files.jsonl.gz", file.File)
+	require.Equal(int64(2), file.Rows)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, file.File))
+	require.NoError(err)
+	require.Equal(int64(len(content)), file.Size)
+	sum := sha256.Sum256(content)
+	require.Equal(hex.EncodeToString(sum[:]), file.SHA256)
+
+	f, err := os.Open(filepath.Join(dir, file.File))
+	require.NoError(err)
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(err)
+
+	var records []map[string]interface{}
+	s := bufio.NewScanner(gz)
+	for s.Scan() {
+		var r map[string]interface{}
+		require.NoError(json.Unmarshal(s.Bytes(), &r))
+		records = append(records, r)
+	}
+
+	require.NoError(s.Err())
+	require.Equal([]map[string]interface{}{
+		{"file_path": "main.go", "size": float64(12), "uast": "AAE="},
+		{"file_path": "<b>.go", "size": "NULL", "uast": ""},
+	}, records)
+
+	_, err = writeDatasetTable(dir, table, func(fn func([]string) error) error {
+		return fn([]string{"main.go"})
+	})
+	require.EqualError(err, "the query returned 1 columns, expected 3")
+}
+
+func TestNextDatasetVersion(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-dataset")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	v, err := nextDatasetVersion(dir)
+	require.NoError(err)
+	require.Equal("v1", v)
+
+	for _, name := range []string{"v1", "v10", "v2", "latest", ".v11-123"} {
+		require.NoError(os.Mkdir(filepath.Join(dir, name), 0755))
+	}
+
+	v, err = nextDatasetVersion(dir)
+	require.NoError(err)
+	require.Equal("v11", v)
+}
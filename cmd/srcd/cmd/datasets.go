@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/datasets"
+
+	units "github.com/docker/go-units"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// datasetsCmd represents the datasets command
+type datasetsCmd struct {
+	cli.PlainCommand `name:"datasets" short-description:"Download public datasets of repositories" long-description:"Download public datasets of repositories to analyze them with gitbase, without retrieving the repositories one by one.\n\nThe datasets are kept in $HOME/.srcd/datasets, in a directory per dataset."`
+}
+
+// datasetsGetCmd represents the datasets get command
+type datasetsGetCmd struct {
+	Command `name:"get" short-description:"Download a public dataset" long-description:"Download the repositories of a public dataset, selected by language up to the given size, to $HOME/.srcd/datasets/<name> or the directory given with --dir. The files downloaded before are kept, so running it again with other languages or a bigger size adds more repositories.\n\nThe only dataset available is pga, the Public Git Archive: the rooted siva files of the most starred repositories of GitHub. They are split in directories by the first components.gitbase.siva_bucket characters of their name, so gitbase reads them as they are.\n\nWhen the daemon is remote, or a local one is running, the dataset is downloaded by a fetch-dataset job of the daemon to the same directory of its host, unless --dir is given. The command returns once the job is started, or follows it until it finishes with --wait.\n\nWith --init the daemon is restarted with the dataset as the working directory, like srcd init --format siva <dir>. It waits for the job."`
+
+	Langs   []string `short:"l" long:"lang" description:"only download the repositories with files of this language, can be repeated"`
+	Size    string   `long:"size" default:"1GB" description:"stop downloading when the files of the selected repositories reach this size"`
+	Dir     string   `long:"dir" description:"directory of the dataset, instead of $HOME/.srcd/datasets/<name>"`
+	Workers int      `long:"workers" default:"4" description:"number of files downloaded at the same time"`
+	Streams int      `long:"streams" default:"4" description:"number of parallel requests that download each big file, when the server supports ranges"`
+	Retries int      `long:"retries" default:"5" description:"number of times a failed request is retried before giving up"`
+	Verify  bool     `long:"verify" description:"check the files downloaded before against their checksums, and download again the ones that do not match"`
+	Init    bool     `long:"init" description:"restart the daemon with the dataset as the working directory"`
+	Wait    bool     `long:"wait" description:"follow the download run by the daemon until it finishes"`
+
+	Args struct {
+		Name string `positional-arg-name:"name" required:"yes" description:"name of the dataset, only pga is available"`
+	} `positional-args:"yes"`
+}
+
+func (c *datasetsGetCmd) Execute(args []string) error {
+	if c.Args.Name != datasets.PGA {
+		return fmt.Errorf("unknown dataset %q, the only one available is %s", c.Args.Name, datasets.PGA)
+	}
+
+	if c.Init && daemon.IsRemote() {
+		return fmt.Errorf("srcd datasets get --init uses a directory of this host as the working directory, " +
+			"it can't be used with --host")
+	}
+
+	limit, err := units.FromHumanSize(c.Size)
+	if err != nil || limit <= 0 {
+		return fmt.Errorf("invalid --size %q, it must be a size like 10GB", c.Size)
+	}
+
+	if c.Workers < 1 {
+		return fmt.Errorf("invalid --workers %d, it must be at least 1", c.Workers)
+	}
+
+	if c.Streams < 1 {
+		return fmt.Errorf("invalid --streams %d, it must be at least 1", c.Streams)
+	}
+
+	if c.Retries < 0 {
+		return fmt.Errorf("invalid --retries %d, it can't be negative", c.Retries)
+	}
+
+	opts := datasets.Options{
+		Langs:   c.Langs,
+		Limit:   limit,
+		Bucket:  config.File.Components.Gitbase.SivaBucket,
+		Workers: c.Workers,
+		Streams: c.Streams,
+		Retries: c.Retries,
+		Verify:  c.Verify,
+	}
+
+	// the daemon can only download to its datasets directory
+	if c.Dir == "" {
+		client, err := jobsDaemon(api.FeatureFetchDatasetJob)
+		if err != nil {
+			return err
+		}
+
+		if client != nil {
+			return c.fetchJob(client, opts)
+		}
+	}
+
+	dir, err := datasetDir(c.Args.Name, c.Dir)
+	if err != nil {
+		return humanizef(err, "could not find the directory of the dataset")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res, err := datasets.Fetch(ctx, c.Args.Name, dir, opts, func(msg string) {
+		log.Infof(msg)
+	})
+	if err != nil {
+		return humanize(err)
+	}
+
+	if err := render(os.Stdout, res, func(w io.Writer) error {
+		return printDatasetsGet(w, res.String(), dir)
+	}); err != nil {
+		return err
+	}
+
+	return c.init(dir)
+}
+
+// fetchJob downloads the dataset as a fetch-dataset job of the daemon, to its
+// datasets directory. Without --wait it only starts the job
+func (c *datasetsGetCmd) fetchJob(client api.EngineClient, opts datasets.Options) error {
+	wait := c.Wait || c.Init
+	var started bool
+	j, err := startJob(context.Background(), client, &api.StartJobRequest{
+		Kind:    api.JobFetchDataset,
+		Name:    c.Args.Name,
+		Langs:   opts.Langs,
+		Size:    opts.Limit,
+		Workers: int32(opts.Workers),
+		Streams: int32(opts.Streams),
+		Retries: int32(opts.Retries),
+		Verify:  opts.Verify,
+	}, wait, func(j *api.Job) {
+		if !started {
+			started = true
+			log.Infof("downloading the dataset in the job %s, it keeps running if srcd is interrupted", j.Id)
+		}
+
+		if j.Message != "" {
+			log.Infof(j.Message)
+		}
+	})
+	if err != nil {
+		return humanizef(err, "could not download the dataset %s", c.Args.Name)
+	}
+
+	if !wait {
+		return printJobStarted(j)
+	}
+
+	// the daemon uses the same directory as srcd, in its own host
+	dir := "$HOME/.srcd/datasets/" + c.Args.Name + " of the daemon host"
+	if !daemon.IsRemote() {
+		if dir, err = datasetDir(c.Args.Name, ""); err != nil {
+			return humanizef(err, "could not find the directory of the dataset")
+		}
+	}
+
+	if err := render(os.Stdout, newJobOutput(j), func(w io.Writer) error {
+		return printDatasetsGet(w, j.Message, dir)
+	}); err != nil {
+		return err
+	}
+
+	return c.init(dir)
+}
+
+// init restarts the daemon with the dataset in dir as the working directory,
+// with --init
+func (c *datasetsGetCmd) init(dir string) error {
+	if !c.Init {
+		return nil
+	}
+
+	cmd := &initCmd{Command: c.Command, Detach: "true", Format: api.RepositoryFormatSiva}
+	cmd.Args.Workdir = dir
+	return cmd.Execute(nil)
+}
+
+// printDatasetsGet writes the text output of srcd datasets get, with the
+// summary of the download
+func printDatasetsGet(w io.Writer, summary, dir string) error {
+	_, err := fmt.Fprintf(w, "%s saved in %s\nanalyze them with: srcd init --format siva %s\n",
+		summary, dir, dir)
+	return err
+}
+
+// datasetDir returns the directory of the dataset, dir if it is given or
+// $HOME/.srcd/datasets/<name> otherwise
+func datasetDir(name, dir string) (string, error) {
+	if dir != "" {
+		return filepath.Abs(dir)
+	}
+
+	root, err := daemon.DatasetsDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(root, name), nil
+}
+
+// datasetsListCmd represents the datasets list command
+type datasetsListCmd struct {
+	Command `name:"list" short-description:"List the public datasets downloaded" long-description:"List the public datasets downloaded to $HOME/.srcd/datasets, with the number of repositories and the size of their siva files."`
+}
+
+func (c *datasetsListCmd) Execute(args []string) error {
+	root, err := datasetDir("", "")
+	if err != nil {
+		return humanizef(err, "could not find the directory of the datasets")
+	}
+
+	result, err := listDatasets(root)
+	if err != nil {
+		return humanizef(err, "could not list the datasets")
+	}
+
+	return render(os.Stdout, result, result.Print)
+}
+
+// listDatasets returns the datasets downloaded to the directories of root
+func listDatasets(root string) (*datasetsList, error) {
+	list := &datasetsList{Datasets: []datasetsListItem{}}
+	infos, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(root, info.Name())
+		m, err := datasets.ReadManifest(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		files, size, err := sivaFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		list.Datasets = append(list.Datasets, datasetsListItem{
+			Name:         info.Name(),
+			Dir:          dir,
+			Repositories: len(m.Repositories),
+			Files:        files,
+			Size:         size,
+		})
+	}
+
+	return list, nil
+}
+
+// datasetsList is the output of srcd datasets list
+type datasetsList struct {
+	Datasets []datasetsListItem `json:"datasets" yaml:"datasets"`
+}
+
+type datasetsListItem struct {
+	Name         string `json:"name" yaml:"name"`
+	Dir          string `json:"dir" yaml:"dir"`
+	Repositories int    `json:"repositories" yaml:"repositories"`
+	Files        int    `json:"files" yaml:"files"`
+	Size         int64  `json:"size" yaml:"size"`
+}
+
+func (l *datasetsList) Print(w io.Writer) error {
+	table := NewTable("%s", "%d", "%d", "%s", "%s")
+	table.Header("NAME", "REPOSITORIES", "FILES", "SIZE", "DIRECTORY")
+	for _, d := range l.Datasets {
+		table.Row(d.Name, d.Repositories, d.Files, units.BytesSize(float64(d.Size)), d.Dir)
+	}
+
+	return table.Print(w)
+}
+
+func init() {
+	c := rootCmd.AddCommand(&datasetsCmd{})
+	c.AddCommand(&datasetsGetCmd{})
+	c.AddCommand(&datasetsListCmd{})
+}
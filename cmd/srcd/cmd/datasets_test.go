@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/engine/datasets"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListDatasets(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "srcd-datasets")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	list, err := listDatasets(filepath.Join(root, "missing"))
+	require.NoError(err)
+	require.Empty(list.Datasets)
+
+	dir := filepath.Join(root, datasets.PGA)
+	require.NoError(os.MkdirAll(filepath.Join(dir, "aa"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "aa", "aa1.siva"), []byte("12345"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "aa", "aa2.siva"), []byte("12345"), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, datasets.ManifestFile),
+		[]byte(`{"name": "pga", "repositories": [{"url": "a"}, {"url": "b"}, {"url": "c"}]}`), 0644))
+
+	list, err = listDatasets(root)
+	require.NoError(err)
+	require.Equal([]datasetsListItem{{
+		Name:         datasets.PGA,
+		Dir:          dir,
+		Repositories: 3,
+		Files:        2,
+		Size:         10,
+	}}, list.Datasets)
+}
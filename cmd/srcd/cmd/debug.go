@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/daemon"
+
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// debugProfiles are the profiles srcd debug profile captures, by kind, with
+// the pprof path and the name of their file
+var debugProfiles = map[string]struct {
+	path string
+	file string
+}{
+	"cpu":       {"profile", "cpu.pprof"},
+	"heap":      {"heap", "heap.pprof"},
+	"allocs":    {"allocs", "allocs.pprof"},
+	"goroutine": {"goroutine?debug=2", "goroutines.txt"},
+	"trace":     {"trace", "trace.out"},
+}
+
+// debugProfileOrder is the order the profiles are captured in. The heap
+// and the goroutines are read first, so the CPU profile does not change them
+var debugProfileOrder = []string{"heap", "allocs", "goroutine", "cpu", "trace"}
+
+// debugCmd represents the debug command
+type debugCmd struct {
+	cli.PlainCommand `name:"debug" short-description:"Debug the daemon" long-description:"Collect information about the daemon for bug reports"`
+}
+
+// debugProfileCmd represents the debug profile command
+type debugProfileCmd struct {
+	Command `name:"profile" short-description:"Capture profiles of the daemon" long-description:"Capture profiles of the daemon from its pprof endpoints, published on a port of the loopback interface of the host, and save them in a directory to attach them to a bug report.\n\nBy default a CPU profile, a heap profile and a dump of the goroutines are captured. The profiles can be read with go tool pprof."`
+
+	Kinds    []string      `short:"k" long:"kind" choice:"cpu" choice:"heap" choice:"allocs" choice:"goroutine" choice:"trace" description:"profile to capture, can be repeated (default: cpu, heap and goroutine)"`
+	Duration time.Duration `short:"d" long:"duration" default:"30s" description:"duration of the CPU profile and the execution trace"`
+	Dir      string        `long:"dir" description:"directory the profiles are saved in (default: srcd-profile-<time> in the current directory)"`
+}
+
+func (c *debugProfileCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected none")
+	}
+
+	if c.Duration <= 0 {
+		return fmt.Errorf("invalid --duration %s, it must be positive", c.Duration)
+	}
+
+	addr, err := daemon.PprofAddr()
+	if err != nil {
+		return humanizef(err, "could not find the pprof endpoints")
+	}
+
+	kinds := make(map[string]bool)
+	for _, k := range c.Kinds {
+		kinds[k] = true
+	}
+
+	if len(kinds) == 0 {
+		kinds = map[string]bool{"cpu": true, "heap": true, "goroutine": true}
+	}
+
+	dir := c.Dir
+	if dir == "" {
+		dir = "srcd-profile-" + time.Now().Format("20060102-150405")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return humanizef(err, "could not create directory %s", dir)
+	}
+
+	result := &debugProfileResult{Dir: dir, Profiles: []debugProfile{}}
+	for _, kind := range debugProfileOrder {
+		if !kinds[kind] {
+			continue
+		}
+
+		p := debugProfiles[kind]
+		if kind == "cpu" || kind == "trace" {
+			log.Infof("capturing the %s profile of the daemon for %s", kind, c.Duration)
+		}
+
+		path := filepath.Join(dir, p.file)
+		size, err := captureProfile(addr, p.path, path, c.Duration)
+		if err != nil {
+			return humanizef(err, "could not capture the %s profile", kind)
+		}
+
+		result.Profiles = append(result.Profiles, debugProfile{Kind: kind, File: path, Size: size})
+	}
+
+	return render(os.Stdout, result, result.Print)
+}
+
+// captureProfile saves the profile in the pprof path of the given address in
+// a file, and returns its size. The CPU profile and the execution trace last
+// the given duration
+func captureProfile(addr, path, file string, duration time.Duration) (int64, error) {
+	u := url.URL{Scheme: "http", Host: addr, Path: "/debug/pprof/"}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return 0, err
+	}
+
+	u = *u.ResolveReference(ref)
+	q := u.Query()
+	q.Set("seconds", fmt.Sprint(int64(duration.Seconds()+0.5)))
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration+time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("the daemon replied %s", res.Status)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := io.Copy(f, res.Body)
+	if err != nil {
+		f.Close()
+		return 0, errors.Wrapf(err, "could not write %s", file)
+	}
+
+	return size, f.Close()
+}
+
+// debugProfileResult is the output of srcd debug profile
+type debugProfileResult struct {
+	Dir      string         `json:"dir" yaml:"dir"`
+	Profiles []debugProfile `json:"profiles" yaml:"profiles"`
+}
+
+type debugProfile struct {
+	Kind string `json:"kind" yaml:"kind"`
+	File string `json:"file" yaml:"file"`
+	Size int64  `json:"size" yaml:"size"`
+}
+
+func (r *debugProfileResult) Print(w io.Writer) error {
+	t := NewTable("%s", "%s", "%s")
+	t.Header("KIND", "FILE", "SIZE")
+	for _, p := range r.Profiles {
+		t.Row(p.Kind, p.File, units.HumanSize(float64(p.Size)))
+	}
+
+	if err := t.Print(w); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nattach the files of %s to the bug report\n", r.Dir)
+	return err
+}
+
+func init() {
+	c := rootCmd.AddCommand(&debugCmd{})
+	c.AddCommand(&debugProfileCmd{})
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureProfile(t *testing.T) {
+	require := require.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/debug/pprof/profile":
+			w.Write([]byte("cpu " + r.URL.Query().Get("seconds")))
+		case "/debug/pprof/goroutine":
+			w.Write([]byte("goroutine debug=" + r.URL.Query().Get("debug")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	dir, err := ioutil.TempDir("", "srcd-debug")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "cpu.pprof")
+	size, err := captureProfile(addr, debugProfiles["cpu"].path, file, 2*time.Second)
+	require.NoError(err)
+	require.Equal(int64(5), size)
+	content, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	require.Equal("cpu 2", string(content))
+
+	file = filepath.Join(dir, "goroutines.txt")
+	_, err = captureProfile(addr, debugProfiles["goroutine"].path, file, time.Second)
+	require.NoError(err)
+	content, err = ioutil.ReadFile(file)
+	require.NoError(err)
+	require.Equal("goroutine debug=2", string(content))
+
+	_, err = captureProfile(addr, debugProfiles["heap"].path, filepath.Join(dir, "heap.pprof"), time.Second)
+	require.EqualError(err, "the daemon replied 404 Not Found")
+}
@@ -25,6 +25,9 @@ type defered struct {
 	Spinner bool
 	// SpinnerInterval allows to change speed of spinner (200ms by default)
 	SpinnerInterval time.Duration
+	// StatusFn, if set, provides a status shown along with the message in
+	// each frame of the spinner, like the progress of a long operation
+	StatusFn func() string
 
 	// logger is the go-log DefaultLogger. Can be changed for tests
 	logger log.Logger
@@ -125,7 +128,7 @@ func (d *defered) spinner(msg string, stop <-chan bool, done chan<- bool) {
 		}
 	}
 
-	charset := []int{'⠋', '⠙', '⠹', '⠸', '⠼', '⠴', '⠦', '⠧', '⠇', '⠏'}
+	charset := []rune{'⠋', '⠙', '⠹', '⠸', '⠼', '⠴', '⠦', '⠧', '⠇', '⠏'}
 	i := 0
 	for {
 		select {
@@ -135,9 +138,11 @@ func (d *defered) spinner(msg string, stop <-chan bool, done chan<- bool) {
 			return
 		default:
 			spinner := string(charset[i%len(charset)])
-			d.logger.Infof("%s %s", d.Msg, spinner)
+			d.logger.Infof("%s %s", d.status(), spinner)
 			time.Sleep(interval)
-			fmt.Fprint(d.logWriter, "\033[A")
+			// the status can be shorter than the previous one, so the line
+			// is cleared too
+			fmt.Fprint(d.logWriter, "\033[A\033[K")
 		}
 
 		i++
@@ -146,3 +151,17 @@ func (d *defered) spinner(msg string, stop <-chan bool, done chan<- bool) {
 		}
 	}
 }
+
+// status returns the message with the current status, if any
+func (d *defered) status() string {
+	if d.StatusFn == nil {
+		return d.Msg
+	}
+
+	status := d.StatusFn()
+	if status == "" {
+		return d.Msg
+	}
+
+	return fmt.Sprintf("%s (%s)", d.Msg, status)
+}
@@ -138,6 +138,39 @@ func (s *DeferedTestSuite) TestPrintWithSpinner() {
 	})
 }
 
+func (s *DeferedTestSuite) TestPrintWithSpinnerStatus() {
+	log.DefaultFactory = &log.LoggerFactory{
+		Level:       log.InfoLevel,
+		Format:      log.TextFormat,
+		ForceFormat: true,
+	}
+
+	require := require.New(s.T())
+
+	d := s.buildDefered(true, nil)
+	var n int
+	d.StatusFn = func() string {
+		n++
+		if n == 1 {
+			return ""
+		}
+
+		return fmt.Sprintf("step %d", n)
+	}
+
+	s.logAction(d, 500*time.Millisecond)
+
+	expected := []string{
+		"Start",
+		"Hello World! ⠋",
+		"Hello World! (step 2) ⠙",
+		"Hello World! (step 3) ⠹",
+		"Hello World!, done",
+		"End",
+	}
+	require.Equal(expected, s.mockLogger.msgs)
+}
+
 func (s *DeferedTestSuite) TestPrintWithInputFn() {
 	inputFn := func(stop <-chan bool) <-chan string {
 		ch := make(chan string)
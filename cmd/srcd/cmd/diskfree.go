@@ -0,0 +1,16 @@
+// +build !windows
+
+package cmd
+
+import "syscall"
+
+// diskFree returns the bytes available to unprivileged users in the
+// filesystem of the given path
+func diskFree(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+
+	return int64(uint64(st.Bavail) * uint64(st.Bsize)), nil
+}
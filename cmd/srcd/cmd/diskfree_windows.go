@@ -0,0 +1,9 @@
+package cmd
+
+import "fmt"
+
+// diskFree is not implemented on Windows, where docker runs in a VM whose
+// disk can't be inspected from the host anyway
+func diskFree(path string) (int64, error) {
+	return 0, fmt.Errorf("not supported on windows")
+}
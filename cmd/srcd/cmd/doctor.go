@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"github.com/docker/docker/api/types"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+)
+
+const (
+	// minimum and recommended memory of the docker host, gitbase indexes and
+	// bblfshd drivers use most of it
+	minMemory         = 2 * units.GiB
+	recommendedMemory = 4 * units.GiB
+
+	// minimum and recommended free disk space for the images and volumes
+	minDiskFree         = 5 * units.GB
+	recommendedDiskFree = 10 * units.GB
+)
+
+// macSharedPaths are the directories shared by default by Docker Desktop for
+// Mac
+var macSharedPaths = []string{"/Users", "/Volumes", "/private", "/tmp", "/var/folders"}
+
+type checkStatus int
+
+const (
+	checkPass checkStatus = iota
+	checkWarn
+	checkFail
+	checkSkip
+)
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkPass:
+		return "PASS"
+	case checkWarn:
+		return "WARN"
+	case checkFail:
+		return "FAIL"
+	default:
+		return "SKIP"
+	}
+}
+
+// checkResult is the result of one of the checks of srcd doctor
+type checkResult struct {
+	name   string
+	status checkStatus
+	msg    string
+	// fix explains how to solve a failure or warning
+	fix string
+}
+
+// doctorCmd represents the doctor command
+type doctorCmd struct {
+	Command `name:"doctor" short-description:"Check that the environment can run the engine" long-description:"Check the Docker installation, the memory and disk space available to it, the ports used by the components, and that the working directory is shared with Docker, explaining how to fix any problem found"`
+
+	Args struct {
+		Workdir string `positional-arg-name:"workdir"`
+	} `positional-args:"yes"`
+}
+
+func (c *doctorCmd) Execute(args []string) error {
+	if err := config.Read(c.Config); err != nil {
+		return humanizef(err, "could not read the config file")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	info, version, err := docker.SystemInfo(ctx)
+	if err != nil {
+		return printChecks(os.Stdout, []checkResult{{
+			name:   "Docker",
+			status: checkFail,
+			msg:    "Docker is not usable",
+			fix:    humanize(err).Error(),
+		}})
+	}
+
+	backend, err := docker.GetBackend(runtime.GOOS)
+	if err != nil {
+		return humanizef(err, "could not detect the docker installation")
+	}
+
+	results := []checkResult{
+		{
+			name:   "Docker",
+			status: checkPass,
+			msg: fmt.Sprintf("version %s, API %s, %s/%s, running on %s",
+				version.Version, version.APIVersion, version.Os, version.Arch, backend),
+		},
+		checkMemory(info.MemTotal, backend),
+		checkDisk(info, backend),
+		checkWorkdir(c.Args.Workdir, backend),
+	}
+
+	results = append(results, checkPortsFree()...)
+	return printChecks(os.Stdout, results)
+}
+
+func printChecks(w io.Writer, results []checkResult) error {
+	var failed int
+	for _, r := range results {
+		fmt.Fprintf(w, "[%s] %s: %s\n", r.status, r.name, r.msg)
+		if r.fix != "" && (r.status == checkFail || r.status == checkWarn) {
+			for _, line := range strings.Split(r.fix, "\n") {
+				fmt.Fprintf(w, "       %s\n", line)
+			}
+		}
+
+		if r.status == checkFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+
+	return nil
+}
+
+func isDesktop(backend docker.Backend) bool {
+	return backend == docker.BackendDesktopMac ||
+		backend == docker.BackendHyperV ||
+		backend == docker.BackendWSL2
+}
+
+func checkMemory(total int64, backend docker.Backend) checkResult {
+	r := checkResult{
+		name:   "Memory",
+		status: checkPass,
+		msg:    fmt.Sprintf("%s available to Docker", units.BytesSize(float64(total))),
+	}
+
+	switch {
+	case total < minMemory:
+		r.status = checkFail
+	case total < recommendedMemory:
+		r.status = checkWarn
+	default:
+		return r
+	}
+
+	r.fix = fmt.Sprintf("gitbase and bblfshd need at least %s, %s is recommended.",
+		units.BytesSize(minMemory), units.BytesSize(recommendedMemory))
+	if isDesktop(backend) {
+		r.fix += "\nIncrease the memory of Docker Desktop in Settings > Resources."
+	}
+
+	return r
+}
+
+func checkDisk(info types.Info, backend docker.Backend) checkResult {
+	r := checkResult{name: "Disk space"}
+	if backend != docker.BackendNative {
+		r.status = checkSkip
+		r.msg = "the disk of the Docker Desktop virtual machine can't be checked"
+		return r
+	}
+
+	free, err := dockerDiskFree(info)
+	if err != nil {
+		r.status = checkSkip
+		r.msg = fmt.Sprintf("could not check %s: %s", info.DockerRootDir, err)
+		return r
+	}
+
+	return diskResult(r, free, info.DockerRootDir)
+}
+
+// dockerDiskFree returns the free disk space in the docker root dir, only
+// accessible with the native backend
+func dockerDiskFree(info types.Info) (int64, error) {
+	// the docker root dir is usually only readable by root
+	free, err := diskFree(info.DockerRootDir)
+	if err != nil {
+		free, err = diskFree(filepath.Dir(info.DockerRootDir))
+	}
+
+	return free, err
+}
+
+func diskResult(r checkResult, free int64, dir string) checkResult {
+	r.status = checkPass
+	r.msg = fmt.Sprintf("%s free in %s", units.HumanSize(float64(free)), dir)
+
+	switch {
+	case free < minDiskFree:
+		r.status = checkFail
+	case free < recommendedDiskFree:
+		r.status = checkWarn
+	default:
+		return r
+	}
+
+	r.fix = fmt.Sprintf("The images and volumes of the components need at least %s, %s is recommended.\n"+
+		"Free some space, e.g. removing unused images with docker image prune.",
+		units.HumanSize(minDiskFree), units.HumanSize(recommendedDiskFree))
+	return r
+}
+
+func checkWorkdir(workdir string, backend docker.Backend) checkResult {
+	r := checkResult{name: "Working directory"}
+
+	var err error
+	if workdir == "" {
+		workdir, err = os.Getwd()
+	} else {
+		workdir, err = filepath.Abs(workdir)
+	}
+
+	if err != nil {
+		r.status = checkFail
+		r.msg = err.Error()
+		return r
+	}
+
+	r.msg = fmt.Sprintf("%s is shared with Docker", workdir)
+	if _, err := docker.HostPath(filepath.ToSlash(workdir), runtime.GOOS); err != nil {
+		if errors.Cause(err) != docker.ErrPathNotShared {
+			r.status = checkSkip
+			r.msg = fmt.Sprintf("could not check %s: %s", workdir, err)
+			return r
+		}
+
+		r.status = checkFail
+		r.msg = err.Error()
+		r.fix = humanize(err).Error()
+		return r
+	}
+
+	if backend == docker.BackendDesktopMac && !underAny(workdir, macSharedPaths) {
+		r.status = checkWarn
+		r.msg = fmt.Sprintf("%s might not be shared with Docker", workdir)
+		r.fix = "Make sure it is listed in the Docker Desktop Settings > Resources > File Sharing."
+		return r
+	}
+
+	r.status = checkPass
+	return r
+}
+
+func underAny(path string, dirs []string) bool {
+	for _, d := range dirs {
+		if path == d || strings.HasPrefix(path, d+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkPortsFree() []checkResult {
+	ports, err := publishedPorts(config.File)
+	if err != nil {
+		return []checkResult{{name: "Ports", status: checkFail, msg: err.Error()}}
+	}
+
+	var results []checkResult
+	var free []string
+	for _, p := range ports {
+		err := docker.CheckPort(*p.port, components.Prefix())
+		if err == nil {
+			free = append(free, strconv.Itoa(*p.port))
+			continue
+		}
+
+		r := checkResult{name: "Ports", status: checkFail, msg: err.Error()}
+		if _, ok := err.(*docker.PortConflictErr); ok {
+			r.fix = fmt.Sprintf("Stop it, set a different port in %s in the config file,\n"+
+				"or run srcd init --auto-port to use a free port.", p.key)
+		}
+
+		results = append(results, r)
+	}
+
+	if len(free) > 0 {
+		results = append([]checkResult{{
+			name:   "Ports",
+			status: checkPass,
+			msg:    strings.Join(free, ", ") + " are free",
+		}}, results...)
+	}
+
+	return results
+}
+
+func init() {
+	rootCmd.AddCommand(&doctorCmd{})
+}
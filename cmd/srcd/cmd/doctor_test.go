@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	units "github.com/docker/go-units"
+	"github.com/src-d/engine/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(checkPass, checkMemory(8*units.GiB, docker.BackendNative).status)
+
+	r := checkMemory(3*units.GiB, docker.BackendNative)
+	assert.Equal(checkWarn, r.status)
+	assert.NotContains(r.fix, "Docker Desktop")
+
+	r = checkMemory(1*units.GiB, docker.BackendDesktopMac)
+	assert.Equal(checkFail, r.status)
+	assert.Contains(r.fix, "Docker Desktop")
+}
+
+func TestDiskResult(t *testing.T) {
+	assert := assert.New(t)
+
+	r := diskResult(checkResult{name: "Disk space"}, 20*units.GB, "/var/lib/docker")
+	assert.Equal(checkPass, r.status)
+	assert.Equal("20GB free in /var/lib/docker", r.msg)
+
+	assert.Equal(checkWarn, diskResult(checkResult{}, 8*units.GB, "/").status)
+	assert.Equal(checkFail, diskResult(checkResult{}, 1*units.GB, "/").status)
+}
+
+func TestUnderAny(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(underAny("/Users/me/repos", macSharedPaths))
+	assert.True(underAny("/tmp", macSharedPaths))
+	assert.False(underAny("/opt/repos", macSharedPaths))
+	assert.False(underAny("/Users2/repos", macSharedPaths))
+}
+
+func TestPrintChecks(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	err := printChecks(&out, []checkResult{
+		{name: "Docker", status: checkPass, msg: "version 18.09", fix: "ignored"},
+		{name: "Memory", status: checkFail, msg: "1GiB available to Docker", fix: "first\nsecond"},
+		{name: "Disk space", status: checkSkip, msg: "can't be checked"},
+	})
+	assert.EqualError(err, "1 of 3 checks failed")
+	assert.Equal("[PASS] Docker: version 18.09\n"+
+		"[FAIL] Memory: 1GiB available to Docker\n"+
+		"       first\n"+
+		"       second\n"+
+		"[SKIP] Disk space: can't be checked\n", out.String())
+}
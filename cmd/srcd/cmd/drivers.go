@@ -29,11 +29,26 @@ func (cmd *parseDriversListCmd) Execute(args []string) error {
 		return humanizef(err, "could not list drivers")
 	}
 
+	out := driversOutput{Drivers: []driverOutput{}}
 	t := NewTable("%s", "%s")
 	t.Header("LANGUAGE", "VERSION")
 	for _, driver := range drivers.Drivers {
 		t.Row(driver.Lang, driver.Version)
+		out.Drivers = append(out.Drivers, driverOutput{
+			Language: driver.Lang,
+			Version:  driver.Version,
+		})
 	}
 
-	return t.Print(os.Stdout)
+	return render(os.Stdout, out, t.Print)
+}
+
+// driversOutput is the schema of the srcd parse drivers list output
+type driversOutput struct {
+	Drivers []driverOutput `json:"drivers" yaml:"drivers"`
+}
+
+type driverOutput struct {
+	Language string `json:"language" yaml:"language"`
+	Version  string `json:"version" yaml:"version"`
 }
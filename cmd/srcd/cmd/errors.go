@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/pkg/errors"
+	"github.com/src-d/engine/cmd/srcd/daemon"
 	"github.com/src-d/engine/docker"
 )
 
@@ -32,7 +33,97 @@ func humanize(err error) error {
 			"You can define the port to be bound by " + e.Service + " in " + confFile + ", and then run:\n" +
 			"srcd init " + workdir + " --config " + confFile + "\n\n" +
 			"Read more in the documentation: https://docs.sourced.tech/engine/learn-more/commands#srcd"
+	case *daemon.UnreachableErr:
+		errString = "Could not connect to the daemon at " + e.Addr + ".\n" +
+			"Make sure the daemon was started with srcd init on the remote host, and that\n" +
+			"daemon.listen in its config file publishes the port on an address reachable\n" +
+			"from this machine.\n\n" +
+			"Reason: " + e.Err.Error()
+	default:
+		switch errors.Cause(err) {
+		case docker.ErrDaemonNotRunning:
+			errString = "Could not connect to Docker.\n" +
+				"Make sure Docker is installed and running, and that your user has permission\n" +
+				"to use it, e.g. by belonging to the docker group. If you use a remote Docker,\n" +
+				"check the DOCKER_HOST environment variable.\n\n" +
+				"Reason: " + errString
+		case docker.ErrIncompatibleAPIVersion:
+			errString = "Your Docker version is too old for srcd.\n" +
+				"Please upgrade Docker to a more recent version, see https://docs.docker.com/install/\n\n" +
+				"Reason: " + errString
+		case docker.ErrImageNotFound:
+			errString = "A docker image could not be found in the registry.\n" +
+				"Make sure you have a working internet connection and access to Docker Hub,\n" +
+				"and that the image versions in your plugin manifests exist.\n\n" +
+				"Reason: " + errString
+		case docker.ErrPlatformNotSupported:
+			errString = "A docker image is not published for the platform of your Docker daemon.\n" +
+				"Some components don't have images for ARM machines, like Apple Silicon or\n" +
+				"ARM servers, yet. With Docker Desktop you can still run the amd64 images\n" +
+				"using emulation by setting DOCKER_DEFAULT_PLATFORM=linux/amd64.\n\n" +
+				"Reason: " + errString
+		case docker.ErrUnsignedImage:
+			errString = "A docker image was not started because its signature could not be verified.\n" +
+				"The registries in security.signatures of $HOME/.srcd/config.yml only allow\n" +
+				"images signed with cosign using their key. Check that the image is signed,\n" +
+				"and that the key in the config file is the right one.\n\n" +
+				"Reason: " + errString
+		case docker.ErrPathNotShared:
+			errString = "A directory is not shared with Docker.\n" +
+				"With Docker Desktop, add it in Settings > Resources > File Sharing, or\n" +
+				"share its drive on Windows. Docker Desktop with the Hyper-V backend can't\n" +
+				"access the WSL filesystem: use a directory in a Windows drive, like\n" +
+				"/mnt/c/Users/..., or enable the WSL 2 backend.\n\n" +
+				"Reason: " + errString
+		case docker.ErrPortInUse:
+			errString = "A port required by srcd is already allocated.\n" +
+				"You can change the ports used by the components in $HOME/.srcd/config.yml.\n\n" +
+				"Reason: " + errString
+		}
 	}
 
-	return errors.New(errString)
+	return &humanizedErr{msg: errString, cause: errors.Cause(err)}
+}
+
+// humanizedErr keeps the cause of the original error, so errors.Cause can
+// still be used on the friendly message
+type humanizedErr struct {
+	msg   string
+	cause error
+}
+
+// Error implements error interface
+func (e *humanizedErr) Error() string {
+	return e.msg
+}
+
+// Cause returns the cause of the original error, for errors.Cause
+func (e *humanizedErr) Cause() error {
+	return e.cause
+}
+
+// exitError makes srcd exit with the given status code instead of 1, for the
+// commands whose status codes are part of their interface, like srcd ci run
+type exitError struct {
+	code int
+	err  error
+}
+
+// Error implements error interface
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the cause of the wrapped error, for errors.Cause
+func (e *exitError) Cause() error {
+	return errors.Cause(e.err)
+}
+
+// exitCode returns the status code srcd exits with after the given error
+func exitCode(err error) int {
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+
+	return 1
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanize(t *testing.T) {
+	assert := assert.New(t)
+
+	err := humanizef(errors.Wrap(docker.ErrDaemonNotRunning, "dial unix /var/run/docker.sock"), "could not start gitbase")
+	assert.Contains(err.Error(), "could not start gitbase: Could not connect to Docker.")
+	assert.Contains(err.Error(), "Reason: dial unix /var/run/docker.sock: docker daemon is not running")
+
+	err = humanize(errors.New("Error response from daemon: driver failed programming external connectivity on endpoint srcd-cli-gitbase (9669036f7e68): Bind for 0.0.0.0:3306 failed: port is already allocated"))
+	assert.Contains(err.Error(), "Port 3306 is already allocated.")
+
+	err = humanize(errors.New("rpc error: client version 1.39 is too new. Maximum supported API version is 1.24"))
+	assert.Contains(err.Error(), "Your Docker version is too old")
+
+	err = humanize(errors.New("something else"))
+	assert.EqualError(err, "something else")
+}
+
+func TestErrorCategory(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", errorCategory(nil))
+	assert.Equal("other", errorCategory(errors.New("something else")))
+
+	err := humanizef(errors.Wrap(docker.ErrDaemonNotRunning, "dial unix /var/run/docker.sock"), "could not start gitbase")
+	assert.Equal("docker-not-running", errorCategory(err))
+
+	err = humanizef(&docker.PortConflictErr{Port: 3306}, "could not start gitbase")
+	assert.Equal("port-in-use", errorCategory(err))
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// eventsBufferSize is the number of daemon events kept until they are shown
+const eventsBufferSize = 64
+
+// subscribeEvents subscribes to the events of the daemon. It returns a
+// function for defered.InputFn with their messages, and the one that must be
+// called to unsubscribe. The events are buffered from now on, so the ones
+// sent before the messages are shown, like an image pull that started right
+// away, are not lost
+func subscribeEvents(client api.EngineClient) (func(stop <-chan bool) <-chan string, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := make(chan string, eventsBufferSize)
+
+	var stream api.Engine_EventsClient
+	var err error
+	if daemon.Supports(api.FeatureEvents) {
+		stream, err = client.Events(ctx, &api.EventsRequest{})
+	} else {
+		// older daemons don't send events, their messages are just not shown
+		err = fmt.Errorf("the daemon does not send events")
+	}
+
+	if err != nil {
+		log.Debugf("could not subscribe to the daemon events: %s", err)
+		close(msgs)
+	} else {
+		go func() {
+			defer close(msgs)
+			for {
+				ev, err := stream.Recv()
+				if err != nil {
+					// daemons older than the client don't send events
+					code := status.Code(err)
+					if code != codes.Canceled && code != codes.Unimplemented {
+						log.Debugf("could not read the daemon events: %s", err)
+					}
+
+					return
+				}
+
+				select {
+				case msgs <- eventMessage(ev):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	input := func(stop <-chan bool) <-chan string {
+		out := make(chan string)
+		go func() {
+			defer close(out)
+			for {
+				select {
+				case <-stop:
+					return
+				case msg, ok := <-msgs:
+					if !ok {
+						return
+					}
+
+					select {
+					case out <- msg:
+					case <-stop:
+						return
+					}
+				}
+			}
+		}()
+
+		return out
+	}
+
+	return input, cancel
+}
+
+// eventMessage returns the message shown for a daemon event
+func eventMessage(ev *api.Event) string {
+	switch ev.Kind {
+	case api.Event_HEALTH_DEGRADED:
+		return fmt.Sprintf("%s, check its logs with docker logs %s", ev.Message, ev.Component)
+	case api.Event_UPGRADE_AVAILABLE:
+		return fmt.Sprintf("%s, run srcd components upgrade to use it", ev.Message)
+	default:
+		return ev.Message
+	}
+}
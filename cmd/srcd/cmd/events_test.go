@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type eventsClient struct {
+	api.EngineClient
+	events []*api.Event
+}
+
+func (c *eventsClient) Events(
+	ctx context.Context,
+	in *api.EventsRequest,
+	opts ...grpc.CallOption,
+) (api.Engine_EventsClient, error) {
+	return &eventsStream{events: c.events}, nil
+}
+
+type eventsStream struct {
+	grpc.ClientStream
+	events []*api.Event
+}
+
+func (s *eventsStream) Recv() (*api.Event, error) {
+	if len(s.events) == 0 {
+		return nil, io.EOF
+	}
+
+	ev := s.events[0]
+	s.events = s.events[1:]
+	return ev, nil
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	client := &eventsClient{events: []*api.Event{
+		{Kind: api.Event_PULL_STARTED, Message: "installing srcd/gitbase:v0.24.0"},
+		{Kind: api.Event_COMPONENT_STARTED, Message: "started srcd-cli-gitbase"},
+	}}
+
+	input, unsubscribe := subscribeEvents(client)
+	defer unsubscribe()
+
+	var msgs []string
+	for msg := range input(make(chan bool)) {
+		msgs = append(msgs, msg)
+	}
+
+	assert.Equal([]string{
+		"installing srcd/gitbase:v0.24.0",
+		"started srcd-cli-gitbase",
+	}, msgs)
+}
+
+func TestSubscribeEventsStop(t *testing.T) {
+	input, unsubscribe := subscribeEvents(&eventsClient{})
+	defer unsubscribe()
+
+	stop := make(chan bool)
+	close(stop)
+	for range input(stop) {
+	}
+}
+
+func TestEventMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("started srcd-cli-gitbase", eventMessage(&api.Event{
+		Kind:    api.Event_COMPONENT_STARTED,
+		Message: "started srcd-cli-gitbase",
+	}))
+
+	assert.Equal(
+		"srcd-cli-gitbase exited unexpectedly, check its logs with docker logs srcd-cli-gitbase",
+		eventMessage(&api.Event{
+			Kind:      api.Event_HEALTH_DEGRADED,
+			Component: "srcd-cli-gitbase",
+			Message:   "srcd-cli-gitbase exited unexpectedly",
+		}),
+	)
+}
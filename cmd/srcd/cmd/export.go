@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/daemon"
+
+	"gopkg.in/src-d/go-cli.v0"
+)
+
+// exportCmd represents the export command
+type exportCmd struct {
+	cli.PlainCommand `name:"export" short-description:"Export information of the engine for other tools" long-description:"Export information of the engine for other tools"`
+}
+
+// exportGitbaseSchemaCmd represents the export gitbase-schema command
+type exportGitbaseSchemaCmd struct {
+	Command `name:"gitbase-schema" short-description:"Print the gitbase tables, columns, indexes and functions" long-description:"Print the gitbase tables, columns, indexes and functions, read from information_schema, as markdown, or as JSON or YAML with --output.\n\ngitbase is started if it is not running."`
+}
+
+func (c *exportGitbaseSchemaCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected none")
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	if _, err := startGitbaseWithClient(client); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	schema, err := readGitbaseSchema(ctx, client, gitbaseDatabase)
+	if err != nil {
+		return humanizef(err, "could not read the gitbase schema")
+	}
+
+	return render(os.Stdout, schema, schema.Print)
+}
+
+func init() {
+	c := rootCmd.AddCommand(&exportCmd{})
+	c.AddCommand(&exportGitbaseSchemaCmd{})
+	c.AddCommand(&exportDatasetCmd{})
+}
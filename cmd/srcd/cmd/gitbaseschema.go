@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/engine/api"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// gitbaseFunctions are the functions of gitbase, used when they can't be
+// read from information_schema.routines
+var gitbaseFunctions = []schemaFunction{
+	{Name: "blame", Description: "the commit that last changed each line of a file"},
+	{Name: "commit_file_stats", Description: "the lines added and removed in each file by a commit"},
+	{Name: "commit_stats", Description: "the lines added and removed by a commit"},
+	{Name: "is_remote", Description: "whether a reference is a remote one"},
+	{Name: "is_tag", Description: "whether a reference is a tag"},
+	{Name: "is_vendor", Description: "whether a file path is a vendored one"},
+	{Name: "language", Description: "the language of a file, from its path and optionally its content"},
+	{Name: "loc", Description: "the lines of code, comments and blanks of a file"},
+	{Name: "uast", Description: "the semantic UAST of a blob, optionally filtered by an XPath query"},
+	{Name: "uast_children", Description: "the children of the UAST nodes"},
+	{Name: "uast_extract", Description: "the values of a property of the UAST nodes"},
+	{Name: "uast_mode", Description: "the UAST of a blob in the given mode: semantic, annotated or native"},
+	{Name: "uast_xpath", Description: "the UAST nodes matching an XPath query"},
+}
+
+// gitbaseSchema is the schema of the srcd export gitbase-schema output
+type gitbaseSchema struct {
+	Database  string           `json:"database" yaml:"database"`
+	Tables    []schemaTable    `json:"tables" yaml:"tables"`
+	Functions []schemaFunction `json:"functions" yaml:"functions"`
+}
+
+type schemaTable struct {
+	Name    string         `json:"name" yaml:"name"`
+	Columns []schemaColumn `json:"columns" yaml:"columns"`
+	Indexes []schemaIndex  `json:"indexes" yaml:"indexes"`
+}
+
+type schemaColumn struct {
+	Name     string `json:"name" yaml:"name"`
+	Type     string `json:"type" yaml:"type"`
+	Nullable bool   `json:"nullable" yaml:"nullable"`
+}
+
+type schemaIndex struct {
+	Name    string   `json:"name" yaml:"name"`
+	Type    string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Columns []string `json:"columns" yaml:"columns"`
+}
+
+type schemaFunction struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// queryResult is the result of a query, with the cells looked up by the
+// column names, in any case, so the columns missing in some gitbase versions
+// don't break the introspection
+type queryResult struct {
+	index map[string]int
+	rows  [][]string
+}
+
+func newQueryResult(columns []string, rows [][]string) *queryResult {
+	index := make(map[string]int, len(columns))
+	for i, c := range columns {
+		index[strings.ToLower(c)] = i
+	}
+
+	return &queryResult{index: index, rows: rows}
+}
+
+// cell returns the cell of the row in the first of the given columns that
+// exists, or an empty string
+func (r *queryResult) cell(row []string, columns ...string) string {
+	for _, c := range columns {
+		if i, ok := r.index[c]; ok && i < len(row) {
+			return row[i]
+		}
+	}
+
+	return ""
+}
+
+// readGitbaseSchema reads the tables and columns of the given database, the
+// gitbase one for the daemon, from information_schema, the indexes of each
+// table, and the functions
+func readGitbaseSchema(ctx context.Context, client api.EngineClient, database string) (*gitbaseSchema, error) {
+	query := func(q string) (*queryResult, error) {
+		columns, rows, err := queryRows(ctx, client, q)
+		if err != nil {
+			return nil, err
+		}
+
+		return newQueryResult(columns, rows), nil
+	}
+
+	columns, err := query(fmt.Sprintf(
+		"SELECT * FROM information_schema.columns WHERE table_schema = '%s'",
+		strings.Replace(database, "'", "''", -1)))
+	if err != nil {
+		return nil, err
+	}
+
+	schema := newGitbaseSchema(columns)
+	schema.Database = database
+	for i := range schema.Tables {
+		t := &schema.Tables[i]
+		indexes, err := query(fmt.Sprintf("SHOW INDEX FROM %s", t.Name))
+		if err != nil {
+			log.Debugf("could not read the indexes of %s: %s", t.Name, err)
+			continue
+		}
+
+		t.Indexes = schemaIndexes(indexes)
+	}
+
+	routines, err := query("SELECT * FROM information_schema.routines")
+	if err != nil {
+		log.Debugf("could not read the functions: %s", err)
+		schema.Functions = gitbaseFunctions
+		return schema, nil
+	}
+
+	schema.Functions = schemaFunctions(routines)
+	return schema, nil
+}
+
+// newGitbaseSchema returns the schema with the tables of the result of a
+// query of information_schema.columns, sorted by name, and their columns in
+// order
+func newGitbaseSchema(r *queryResult) *gitbaseSchema {
+	type column struct {
+		schemaColumn
+		pos int
+	}
+
+	tables := map[string][]column{}
+	for i, row := range r.rows {
+		table := r.cell(row, "table_name")
+		pos, err := strconv.Atoi(r.cell(row, "ordinal_position"))
+		if err != nil {
+			pos = i
+		}
+
+		tables[table] = append(tables[table], column{
+			schemaColumn: schemaColumn{
+				Name:     r.cell(row, "column_name"),
+				Type:     r.cell(row, "column_type", "data_type"),
+				Nullable: strings.EqualFold(r.cell(row, "is_nullable"), "yes"),
+			},
+			pos: pos,
+		})
+	}
+
+	schema := &gitbaseSchema{
+		Database:  gitbaseDatabase,
+		Tables:    []schemaTable{},
+		Functions: []schemaFunction{},
+	}
+
+	for name, columns := range tables {
+		sort.SliceStable(columns, func(i, j int) bool { return columns[i].pos < columns[j].pos })
+		t := schemaTable{Name: name, Columns: []schemaColumn{}, Indexes: []schemaIndex{}}
+		for _, c := range columns {
+			t.Columns = append(t.Columns, c.schemaColumn)
+		}
+
+		schema.Tables = append(schema.Tables, t)
+	}
+
+	sort.Slice(schema.Tables, func(i, j int) bool { return schema.Tables[i].Name < schema.Tables[j].Name })
+	return schema
+}
+
+// schemaIndexes returns the indexes of the result of SHOW INDEX, with their
+// columns in order
+func schemaIndexes(r *queryResult) []schemaIndex {
+	indexes := []schemaIndex{}
+	byName := map[string]int{}
+	for _, row := range r.rows {
+		name := r.cell(row, "key_name")
+		i, ok := byName[name]
+		if !ok {
+			i = len(indexes)
+			byName[name] = i
+			indexes = append(indexes, schemaIndex{
+				Name: name,
+				Type: r.cell(row, "index_type"),
+			})
+		}
+
+		column := r.cell(row, "column_name", "expression")
+		indexes[i].Columns = append(indexes[i].Columns, column)
+	}
+
+	return indexes
+}
+
+// schemaFunctions returns the functions of the result of a query of
+// information_schema.routines, sorted by name
+func schemaFunctions(r *queryResult) []schemaFunction {
+	functions := []schemaFunction{}
+	for _, row := range r.rows {
+		if t := r.cell(row, "routine_type"); t != "" && !strings.EqualFold(t, "function") {
+			continue
+		}
+
+		functions = append(functions, schemaFunction{
+			Name:        strings.ToLower(r.cell(row, "routine_name", "specific_name")),
+			Description: r.cell(row, "routine_comment"),
+		})
+	}
+
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Name < functions[j].Name })
+	return functions
+}
+
+// table returns the table with the given name, in any case, or nil if there
+// is none
+func (s *gitbaseSchema) table(name string) *schemaTable {
+	for i, t := range s.Tables {
+		if strings.EqualFold(t.Name, name) {
+			return &s.Tables[i]
+		}
+	}
+
+	return nil
+}
+
+// Print writes the schema as markdown
+func (s *gitbaseSchema) Print(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s schema\n\n## Tables\n", s.Database)
+	for _, t := range s.Tables {
+		fmt.Fprintf(&b, "\n### %s\n\n", t.Name)
+		b.WriteString("| Column | Type | Nullable |\n|--------|------|----------|\n")
+		for _, c := range t.Columns {
+			nullable := "no"
+			if c.Nullable {
+				nullable = "yes"
+			}
+
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", markdownCell(c.Name), markdownCell(c.Type), nullable)
+		}
+
+		if len(t.Indexes) > 0 {
+			b.WriteString("\nIndexes:\n\n")
+			for _, i := range t.Indexes {
+				typ := ""
+				if i.Type != "" {
+					typ = " (" + i.Type + ")"
+				}
+
+				fmt.Fprintf(&b, "- `%s`%s: %s\n", i.Name, typ, strings.Join(i.Columns, ", "))
+			}
+		}
+	}
+
+	if len(s.Functions) > 0 {
+		b.WriteString("\n## Functions\n\n| Function | Description |\n|----------|-------------|\n")
+		for _, f := range s.Functions {
+			fmt.Fprintf(&b, "| %s | %s |\n", markdownCell(f.Name), markdownCell(f.Description))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes the text for a cell of a markdown table
+func markdownCell(s string) string {
+	return strings.Replace(s, "|", `\|`, -1)
+}
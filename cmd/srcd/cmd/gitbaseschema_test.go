@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGitbaseSchema(t *testing.T) {
+	require := require.New(t)
+
+	columns := newQueryResult(
+		[]string{"TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION", "IS_NULLABLE", "COLUMN_TYPE"},
+		[][]string{
+			{"gitbase", "refs", "commit_hash", "3", "NO", "TEXT"},
+			{"gitbase", "refs", "repository_id", "1", "NO", "TEXT"},
+			{"gitbase", "commits", "commit_hash", "1", "NO", "TEXT"},
+			{"gitbase", "refs", "ref_name", "2", "YES", "TEXT"},
+		},
+	)
+
+	schema := newGitbaseSchema(columns)
+	require.Equal("gitbase", schema.Database)
+	require.Len(schema.Tables, 2)
+	require.Equal("commits", schema.Tables[0].Name)
+	require.Equal("refs", schema.Tables[1].Name)
+	require.Equal([]schemaColumn{
+		{Name: "repository_id", Type: "TEXT"},
+		{Name: "ref_name", Type: "TEXT", Nullable: true},
+		{Name: "commit_hash", Type: "TEXT"},
+	}, schema.Tables[1].Columns)
+
+	// older versions only have the data type
+	columns = newQueryResult(
+		[]string{"table_name", "column_name", "data_type"},
+		[][]string{{"blobs", "blob_size", "INT64"}},
+	)
+
+	schema = newGitbaseSchema(columns)
+	require.Equal([]schemaColumn{{Name: "blob_size", Type: "INT64"}}, schema.Tables[0].Columns)
+}
+
+func TestSchemaIndexes(t *testing.T) {
+	require := require.New(t)
+
+	indexes := newQueryResult(
+		[]string{"Table", "Key_name", "Column_name", "Index_type"},
+		[][]string{
+			{"files", "files_lang", "language(file_path)", "pilosa"},
+			{"commits", "commits_author", "committer_name", "pilosa"},
+			{"commits", "commits_author", "committer_email", "pilosa"},
+		},
+	)
+
+	require.Equal([]schemaIndex{
+		{Name: "files_lang", Type: "pilosa", Columns: []string{"language(file_path)"}},
+		{Name: "commits_author", Type: "pilosa", Columns: []string{"committer_name", "committer_email"}},
+	}, schemaIndexes(indexes))
+}
+
+func TestSchemaFunctions(t *testing.T) {
+	require := require.New(t)
+
+	routines := newQueryResult(
+		[]string{"ROUTINE_NAME", "ROUTINE_TYPE", "ROUTINE_COMMENT"},
+		[][]string{
+			{"UAST", "FUNCTION", "the UAST of a blob"},
+			{"refresh", "PROCEDURE", ""},
+			{"language", "FUNCTION", ""},
+		},
+	)
+
+	require.Equal([]schemaFunction{
+		{Name: "language"},
+		{Name: "uast", Description: "the UAST of a blob"},
+	}, schemaFunctions(routines))
+}
+
+func TestGitbaseSchemaPrint(t *testing.T) {
+	require := require.New(t)
+
+	schema := &gitbaseSchema{
+		Database: "gitbase",
+		Tables: []schemaTable{{
+			Name: "commits",
+			Columns: []schemaColumn{
+				{Name: "commit_hash", Type: "TEXT"},
+				{Name: "commit_message", Type: "TEXT", Nullable: true},
+			},
+			Indexes: []schemaIndex{
+				{Name: "commits_author", Type: "pilosa", Columns: []string{"committer_name", "committer_email"}},
+			},
+		}},
+		Functions: []schemaFunction{{Name: "is_tag", Description: "whether a | is a tag"}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(schema.Print(&buf))
+	require.Equal(`# gitbase schema
+
+## Tables
+
+### commits
+
+| Column | Type | Nullable |
+|--------|------|----------|
+| commit_hash | TEXT | no |
+| commit_message | TEXT | yes |
+
+Indexes:
+
+- `+"`commits_author`"+` (pilosa): committer_name, committer_email
+
+## Functions
+
+| Function | Description |
+|----------|-------------|
+| is_tag | whether a \| is a tag |
+`, buf.String())
+}
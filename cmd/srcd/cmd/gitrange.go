@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// gitRange is a range of commits, like HEAD~10..HEAD, resolved in the
+// repositories of the working directory
+type gitRange struct {
+	// Commits are the hashes of the commits of the range
+	Commits []string
+	// Files are the files changed by the commits, except the deleted ones,
+	// relative to the working directory and using forward slashes
+	Files []string
+}
+
+// resolveRange resolves the range with git in the working directory if it is
+// a repository, or in each of the repositories in its subdirectories
+// otherwise. The repositories where the range doesn't exist are skipped
+func resolveRange(workdir, rng string) (*gitRange, error) {
+	if rng == "" || strings.HasPrefix(rng, "-") {
+		return nil, fmt.Errorf("invalid commit range %q", rng)
+	}
+
+	repos, err := workdirRepositories(workdir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &gitRange{Commits: []string{}, Files: []string{}}
+	var found bool
+	for _, repo := range repos {
+		dir := filepath.Join(workdir, filepath.FromSlash(repo))
+		commits, err := gitOutput(dir, "rev-list", rng, "--")
+		if err != nil {
+			log.Debugf("skipping %s: %s", dir, err)
+			continue
+		}
+
+		files, err := gitOutput(dir, "-c", "core.quotePath=false",
+			"log", "--name-only", "--format=", "--diff-filter=ACMR", rng, "--")
+		if err != nil {
+			return nil, err
+		}
+
+		found = true
+		res.Commits = append(res.Commits, splitLines(commits)...)
+		for _, f := range splitLines(files) {
+			res.Files = append(res.Files, path.Join(repo, f))
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("the commit range %s does not exist in any repository of %s", rng, workdir)
+	}
+
+	return res, nil
+}
+
+// workdirRepositories returns the paths of the git repositories of the
+// working directory, relative to it: . if it is a repository, or its
+// subdirectories that are
+func workdirRepositories(workdir string) ([]string, error) {
+	if isGitRepository(workdir) {
+		return []string{"."}, nil
+	}
+
+	infos, err := ioutil.ReadDir(workdir)
+	if err != nil {
+		return nil, humanizef(err, "could not read the working directory")
+	}
+
+	var repos []string
+	for _, info := range infos {
+		if info.IsDir() && isGitRepository(filepath.Join(workdir, info.Name())) {
+			repos = append(repos, info.Name())
+		}
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("there are no git repositories with a working tree in %s", workdir)
+	}
+
+	return repos, nil
+}
+
+// isGitRepository returns whether the directory is the top directory of a
+// repository with a working tree. The .git of submodules and worktrees is a
+// file
+func isGitRepository(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// gitOutput runs git in the given directory, returning its standard output,
+// or its standard error as the error if it fails
+func gitOutput(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		return "", fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+	}
+
+	if err != nil {
+		return "", humanizef(err, "could not run git")
+	}
+
+	return string(out), nil
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimRight(l, "\r"); l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines
+}
+
+// rangeTableRegexp matches the gitbase tables with a commit_hash column in
+// the FROM and JOIN clauses of a query, and their alias
+var rangeTableRegexp = regexp.MustCompile(
+	`(?i)(\b(?:from|join)\s+|,\s*)(commits|commit_files|commit_blobs|commit_trees|ref_commits)\b(?:(\s+as)?\s+([a-z_][a-z0-9_]*))?`)
+
+// sqlKeywords are the words that can follow a table, which are not aliases
+var sqlKeywords = map[string]bool{
+	"where": true, "natural": true, "join": true, "inner": true, "left": true,
+	"right": true, "outer": true, "cross": true, "on": true, "using": true,
+	"group": true, "order": true, "limit": true, "having": true,
+	"union": true, "straight_join": true,
+}
+
+// restrictQuery returns the query with the gitbase tables with a commit_hash
+// column, like commits or commit_files, replaced with a subquery of the rows
+// of the given commits, keeping their names or aliases
+func restrictQuery(query string, commits []string) string {
+	cond := "1 = 0"
+	if len(commits) > 0 {
+		quoted := make([]string, len(commits))
+		for i, c := range commits {
+			quoted[i] = "'" + c + "'"
+		}
+
+		cond = "commit_hash IN (" + strings.Join(quoted, ", ") + ")"
+	}
+
+	quotes := quotedRanges(query)
+	var b strings.Builder
+	last := 0
+	for _, m := range rangeTableRegexp.FindAllStringSubmatchIndex(query, -1) {
+		if inRanges(quotes, m[0]) {
+			continue
+		}
+
+		table := query[m[4]:m[5]]
+		alias, end := table, m[5]
+		if m[8] >= 0 {
+			word := query[m[8]:m[9]]
+			switch {
+			case m[6] >= 0:
+				// AS is always followed by an alias
+				alias, end = word, m[9]
+			case !sqlKeywords[strings.ToLower(word)]:
+				alias, end = word, m[9]
+			}
+		}
+
+		b.WriteString(query[last:m[3]])
+		fmt.Fprintf(&b, "(SELECT * FROM %s WHERE %s) AS %s", table, cond, alias)
+		last = end
+	}
+
+	b.WriteString(query[last:])
+	return b.String()
+}
+
+// quotedRanges returns the start and end of the quoted strings and
+// identifiers of the query
+func quotedRanges(query string) [][2]int {
+	var ranges [][2]int
+	var quote byte
+	start := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				ranges = append(ranges, [2]int{start, i})
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote, start = c, i
+		}
+	}
+
+	if quote != 0 {
+		ranges = append(ranges, [2]int{start, len(query)})
+	}
+
+	return ranges
+}
+
+func inRanges(ranges [][2]int, pos int) bool {
+	for _, r := range ranges {
+		if pos > r[0] && pos < r[1] {
+			return true
+		}
+	}
+
+	return false
+}
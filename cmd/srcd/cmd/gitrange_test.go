@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestrictQuery(t *testing.T) {
+	require := require.New(t)
+
+	commits := []string{"a1", "b2"}
+	sub := func(table, alias string) string {
+		return "(SELECT * FROM " + table + " WHERE commit_hash IN ('a1', 'b2')) AS " + alias
+	}
+
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			"SELECT COUNT(*) FROM commits",
+			"SELECT COUNT(*) FROM " + sub("commits", "commits"),
+		},
+		{
+			"SELECT * FROM commits c WHERE c.committer_name = 'from commits'",
+			"SELECT * FROM " + sub("commits", "c") + " WHERE c.committer_name = 'from commits'",
+		},
+		{
+			"select file_path from refs natural join commit_files AS cf natural join files where ref_name = 'HEAD'",
+			"select file_path from refs natural join " + sub("commit_files", "cf") + " natural join files where ref_name = 'HEAD'",
+		},
+		{
+			"SELECT * FROM repositories, ref_commits\nWHERE history_index = 0",
+			"SELECT * FROM repositories, " + sub("ref_commits", "ref_commits") + "\nWHERE history_index = 0",
+		},
+		{
+			"SELECT * FROM commits NATURAL JOIN commit_blobs LIMIT 10",
+			"SELECT * FROM " + sub("commits", "commits") + " NATURAL JOIN " + sub("commit_blobs", "commit_blobs") + " LIMIT 10",
+		},
+		{
+			"SELECT * FROM refs WHERE ref_name = 'HEAD'",
+			"SELECT * FROM refs WHERE ref_name = 'HEAD'",
+		},
+	}
+
+	for _, c := range cases {
+		require.Equal(c.expected, restrictQuery(c.query, commits), c.query)
+	}
+
+	require.Equal(
+		"SELECT * FROM (SELECT * FROM commits WHERE 1 = 0) AS commits",
+		restrictQuery("SELECT * FROM commits", nil),
+	)
+}
+
+func TestResolveRange(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	require := require.New(t)
+
+	workdir, err := ioutil.TempDir("", "srcd-range")
+	require.NoError(err)
+	defer os.RemoveAll(workdir)
+
+	_, err = resolveRange(workdir, "HEAD~1..HEAD")
+	require.Error(err)
+
+	repo := filepath.Join(workdir, "engine")
+	require.NoError(os.MkdirAll(repo, 0755))
+	require.NoError(os.MkdirAll(filepath.Join(workdir, "empty"), 0755))
+
+	git := func(args ...string) string {
+		out, err := gitOutput(repo, append([]string{
+			"-c", "user.name=srcd", "-c", "user.email=srcd@example.com",
+		}, args...)...)
+		require.NoError(err)
+		return strings.TrimSpace(out)
+	}
+
+	var n int
+	commit := func(files ...string) string {
+		n++
+		for _, f := range files {
+			content := []byte(fmt.Sprintf("%s %d", f, n))
+			require.NoError(ioutil.WriteFile(filepath.Join(repo, f), content, 0644))
+		}
+
+		git("add", "-A")
+		git("commit", "-q", "-m", "change")
+		return git("rev-parse", "HEAD")
+	}
+
+	git("init", "-q")
+	commit("a.go")
+	second := commit("b.go")
+	third := commit("c.go", "a.go")
+
+	r, err := resolveRange(workdir, "HEAD~2..HEAD")
+	require.NoError(err)
+	require.Equal([]string{third, second}, r.Commits)
+	require.ElementsMatch([]string{"engine/a.go", "engine/b.go", "engine/c.go"}, r.Files)
+
+	// the working directory is a repository
+	r, err = resolveRange(repo, "HEAD~1..HEAD")
+	require.NoError(err)
+	require.Equal([]string{third}, r.Commits)
+	require.ElementsMatch([]string{"a.go", "c.go"}, r.Files)
+
+	_, err = resolveRange(workdir, "missing..HEAD")
+	require.Error(err)
+
+	_, err = resolveRange(workdir, "--all")
+	require.Error(err)
+}
+
+func TestIntersectFiles(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]string{"b", "c"}, intersectFiles([]string{"a", "b", "c"}, []string{"c", "b", "d"}))
+	require.Empty(intersectFiles([]string{"a"}, nil))
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// hookMarker is a line of every hook installed by srcd, so they can be
+// replaced without --force
+const hookMarker = "# installed by srcd hooks install"
+
+// hookNames are the git hooks srcd can install
+var hookNames = []string{"pre-commit", "pre-push"}
+
+// hookFiles are the commands of each hook that list the files to check, one
+// for each line, relative to the top directory of the repository
+var hookFiles = map[string]string{
+	// the staged files, except the deleted ones
+	"pre-commit": `git -c core.quotePath=false diff --cached --name-only --diff-filter=ACMR`,
+	// the files changed by the pushed commits. git gives the refs in the
+	// standard input, and a new branch is compared with all the remote ones
+	"pre-push": `zero=0000000000000000000000000000000000000000
+	while read local_ref local_sha remote_ref remote_sha; do
+		if [ "$local_sha" = "$zero" ]; then
+			continue
+		fi
+
+		if [ "$remote_sha" = "$zero" ]; then
+			git -c core.quotePath=false log --name-only --diff-filter=ACMR --format= "$local_sha" --not --remotes
+		else
+			git -c core.quotePath=false diff --name-only --diff-filter=ACMR "$remote_sha" "$local_sha"
+		fi
+	done | sort -u`,
+}
+
+var hookTemplate = template.Must(template.New("hook").Parse(`#!/bin/sh
+` + hookMarker + `, run it again to update this hook.
+# It checks the changed files with the UAST rules of srcd rules run.
+
+cd "$(git rev-parse --show-toplevel)" || exit 1
+if [ ! -f {{.Rules}} ]; then
+	exit 0
+fi
+
+files=$({{.Files}})
+if [ -z "$files" ]; then
+	exit 0
+fi
+
+printf '%s\n' "$files" | {{.Srcd}} rules run --rules {{.Rules}} --files-from - .
+`))
+
+// hooksCmd represents the hooks command
+type hooksCmd struct {
+	cli.PlainCommand `name:"hooks" short-description:"Manage the git hooks that check the changed files" long-description:"Manage the git hooks that check the changed files with the UAST rules of srcd rules run"`
+}
+
+// hooksInstallCmd represents the hooks install command
+type hooksInstallCmd struct {
+	Command `name:"install" short-description:"Install git hooks that check the changed files with UAST rules" long-description:"Install git hooks in a repository that run srcd rules run over the files changed by each commit or push, failing if there is any finding with error severity.\n\nThe pre-commit hook checks the staged files, and the pre-push hook the files changed by the pushed commits. The hooks do nothing if the rules file does not exist."`
+
+	Hooks []string `long:"hook" choice:"pre-commit" choice:"pre-push" description:"hook to install, can be repeated (default: pre-commit and pre-push)"`
+	Rules string   `short:"r" long:"rules" default:".srcd-rules.yml" description:"rules file, relative to the top directory of the repository"`
+	Force bool     `short:"f" long:"force" description:"replace the existing hooks that were not installed by srcd"`
+
+	Args struct {
+		Repository string `positional-arg-name:"repository"`
+	} `positional-args:"yes"`
+}
+
+func (c *hooksInstallCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected only one path")
+	}
+
+	repo, err := workdirArg(c.Args.Repository)
+	if err != nil {
+		return err
+	}
+
+	dir, err := hooksDir(repo)
+	if err != nil {
+		return err
+	}
+
+	srcd, err := os.Executable()
+	if err != nil {
+		return humanizef(err, "could not find the srcd executable")
+	}
+
+	hooks := c.Hooks
+	if len(hooks) == 0 {
+		hooks = hookNames
+	}
+
+	for _, name := range hooks {
+		content, err := hookScript(name, srcd, c.Rules)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, name)
+		if err := installHook(path, content, c.Force); err != nil {
+			return err
+		}
+
+		log.Infof("installed the %s hook in %s", name, path)
+	}
+
+	return nil
+}
+
+// hooksDir returns the hooks directory of the git repository, following
+// core.hooksPath if it is set
+func hooksDir(repo string) (string, error) {
+	out, err := gitOutput(repo, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", humanizef(err, "could not find the hooks directory of %s", repo)
+	}
+
+	dir := strings.TrimSpace(out)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repo, dir)
+	}
+
+	return dir, nil
+}
+
+// hookScript returns the shell script of the hook with the given name, that
+// runs the given srcd executable with the rules file
+func hookScript(name, srcd, rules string) ([]byte, error) {
+	files, ok := hookFiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hook %q", name)
+	}
+
+	var buf bytes.Buffer
+	err := hookTemplate.Execute(&buf, map[string]string{
+		"Srcd":  shellQuote(srcd),
+		"Rules": shellQuote(rules),
+		"Files": files,
+	})
+
+	return buf.Bytes(), err
+}
+
+// installHook writes the hook script at the given path, failing if there is
+// a hook that was not installed by srcd, unless force is set
+func installHook(path string, content []byte, force bool) error {
+	old, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return humanizef(err, "could not read the existing hook")
+	}
+
+	if err == nil && !force && !bytes.Contains(old, []byte(hookMarker)) {
+		return fmt.Errorf("there is already a %s hook, use --force to replace it", filepath.Base(path))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return humanizef(err, "could not create the hooks directory")
+	}
+
+	if err := ioutil.WriteFile(path, content, 0755); err != nil {
+		return humanizef(err, "could not write the hook")
+	}
+
+	// WriteFile doesn't change the mode of an existing file
+	return os.Chmod(path, 0755)
+}
+
+// shellQuote quotes the string as a single word for sh
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func init() {
+	c := rootCmd.AddCommand(&hooksCmd{})
+	c.AddCommand(&hooksInstallCmd{})
+}
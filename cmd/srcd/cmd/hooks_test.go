@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellQuote(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(`'/usr/bin/srcd'`, shellQuote("/usr/bin/srcd"))
+	require.Equal(`'it'\''s here'`, shellQuote("it's here"))
+}
+
+func TestInstallHook(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-hooks")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hooks", "pre-commit")
+	content, err := hookScript("pre-commit", "/usr/bin/srcd", ".srcd-rules.yml")
+	require.NoError(err)
+	require.Contains(string(content), hookMarker)
+	require.Contains(string(content), "| '/usr/bin/srcd' rules run --rules '.srcd-rules.yml' --files-from - .\n")
+
+	require.NoError(installHook(path, content, false))
+	info, err := os.Stat(path)
+	require.NoError(err)
+	require.Equal(os.FileMode(0755), info.Mode().Perm())
+
+	// the hooks installed by srcd are replaced
+	require.NoError(installHook(path, content, false))
+
+	require.NoError(ioutil.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0644))
+	require.Error(installHook(path, content, false))
+	require.NoError(installHook(path, content, true))
+	info, err = os.Stat(path)
+	require.NoError(err)
+	require.Equal(os.FileMode(0755), info.Mode().Perm())
+
+	_, err = hookScript("post-merge", "srcd", "rules.yml")
+	require.Error(err)
+}
+
+func TestPreCommitHook(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	require := require.New(t)
+
+	repo, err := ioutil.TempDir("", "srcd-hooks")
+	require.NoError(err)
+	defer os.RemoveAll(repo)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		require.NoError(err, string(out))
+	}
+
+	git("init", "-q")
+	_, err = hooksDir(filepath.Join(repo, "missing"))
+	require.Error(err)
+
+	dir, err := hooksDir(repo)
+	require.NoError(err)
+	require.Equal(filepath.Join(repo, ".git", "hooks"), dir)
+
+	// a fake srcd writing the files it is given
+	srcd := filepath.Join(repo, "srcd.sh")
+	list := filepath.Join(repo, "files.txt")
+	require.NoError(ioutil.WriteFile(srcd, []byte("#!/bin/sh\ncat > "+shellQuote(list)+"\n"), 0755))
+
+	content, err := hookScript("pre-commit", srcd, "rules.yml")
+	require.NoError(err)
+	hook := filepath.Join(dir, "pre-commit")
+	require.NoError(installHook(hook, content, false))
+
+	for _, f := range []string{"a.go", "b c.py", "rules.yml", "unstaged.go"} {
+		require.NoError(ioutil.WriteFile(filepath.Join(repo, f), []byte("x"), 0644))
+	}
+
+	git("add", "a.go", "b c.py", "rules.yml")
+
+	runHook := func() {
+		cmd := exec.Command("sh", hook)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		require.NoError(err, string(out))
+	}
+
+	runHook()
+
+	files, err := readFileList(list)
+	require.NoError(err)
+	require.Equal([]string{"a.go", "b c.py", "rules.yml"}, files)
+
+	// nothing is run without a rules file
+	require.NoError(os.Remove(list))
+	require.NoError(os.Remove(filepath.Join(repo, "rules.yml")))
+	runHook()
+	_, err = os.Stat(list)
+	require.True(os.IsNotExist(err))
+}
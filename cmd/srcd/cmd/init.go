@@ -15,20 +15,38 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/src-d/engine/api"
 	"github.com/src-d/engine/cmd/srcd/config"
 	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
 
 	"gopkg.in/src-d/go-log.v1"
 )
 
 // initCmd represents the init command
 type initCmd struct {
-	Command `name:"init" short-description:"Starts the daemon or restarts it if already running" long-description:"Starts the daemon or restarts it if already running"`
+	Command `name:"init" short-description:"Starts the daemon or restarts it if already running" long-description:"Starts the daemon or restarts it if already running.\n\nIf the daemon is running with the same working directory and config it does\nnothing. Otherwise only the components affected by the changes are\nrecreated, unless --force is used.\n\nWith --start it also starts gitbase, bblfshd and the web clients, the\nindependent ones at the same time. With --detach=false it starts gitbase and\nbblfshd, and streams the combined logs of all the components in the\nforeground. Ctrl-C stops them."`
+
+	Detach   string `long:"detach" optional:"yes" optional-value:"true" default:"true" choice:"true" choice:"false" description:"run the components in the background"`
+	AutoPort bool   `long:"auto-port" description:"publish the components on free ports when the configured ones are in use"`
+	Force    bool   `long:"force" description:"recreate the daemon and all the components, even if nothing changed, and start them even if the docker host doesn't have enough resources"`
+	Start    bool   `long:"start" description:"start gitbase, bblfshd and the web clients at the same time, pulling their images if needed"`
+	Publish  string `long:"publish" choice:"internal" choice:"localhost" choice:"all" description:"where the gitbase port is published: only in the srcd network, on localhost or on all the interfaces, instead of components.gitbase.publish of the config file"`
+	Format   string `long:"format" choice:"git" choice:"bare" choice:"siva" description:"format of the repositories of the working directory: git for the ones with a working tree, bare for bare repositories, or siva for rooted siva files, instead of components.gitbase.format of the config file"`
+
+	SkipDrivers bool `long:"skip-drivers" description:"do not install the bblfsh drivers of the languages found in the working directory"`
 
 	Args struct {
 		Workdir string `positional-arg-name:"workdir"`
@@ -45,39 +63,286 @@ func (c *initCmd) Execute(args []string) error {
 		return humanizef(err, "could not read the config file")
 	}
 
-	workdir := c.Args.Workdir
+	workdir, err := workdirArg(c.Args.Workdir)
+	if err != nil {
+		return err
+	}
+
+	if c.Publish != "" {
+		config.File.Components.Gitbase.Publish = c.Publish
+	}
+
+	if c.Format != "" {
+		config.File.Components.Gitbase.Format = c.Format
+		if err := config.File.Validate(); err != nil {
+			return err
+		}
+	}
+
+	warnRepositoryFormat(workdir, config.File.Components.Gitbase.Format)
+
+	if err := checkPorts(config.File, c.AutoPort); err != nil {
+		return err
+	}
+
+	// without the daemon, the working directory and config are only saved
+	// for the next commands
+	if daemon.IsDaemonless() {
+		log.Infof("using working directory without the daemon: %s", workdir)
+	} else {
+		log.Infof("starting daemon with working directory: %s", workdir)
+	}
+
+	changed, err := daemon.Init(workdir, c.Force)
+	if err != nil {
+		return humanizef(err, "could not start daemon")
+	}
+
+	switch {
+	case daemon.IsDaemonless():
+		log.Infof("working directory and config saved")
+	case changed:
+		log.Infof("daemon started")
+	default:
+		log.Infof("daemon is already running with the same working directory and config, " +
+			"use --force to recreate it")
+	}
+
+	if !c.SkipDrivers {
+		installWorkdirDrivers(workdir)
+	}
+
+	if c.Start || c.Detach == "false" {
+		if err := startInitComponents(initComponents(c.Start), c.Force); err != nil {
+			return err
+		}
+	}
 
-	workdir = strings.TrimSpace(workdir)
-	if workdir == "" {
+	if c.Detach == "false" {
+		return runForeground()
+	}
+
+	return nil
+}
+
+// workdirArg returns the absolute path of the given working directory, or
+// the current one if it is empty, checking that docker can mount it
+func workdirArg(arg string) (string, error) {
+	var workdir string
+	var err error
+	if arg = strings.TrimSpace(arg); arg == "" {
 		workdir, err = os.Getwd()
 	} else {
-		workdir, err = filepath.Abs(workdir)
+		workdir, err = filepath.Abs(arg)
 	}
 
 	if err != nil {
-		return humanizef(err, "could not get working directory")
+		return "", humanizef(err, "could not get working directory")
 	}
 
 	info, err := os.Stat(workdir)
 	if err != nil || !info.IsDir() {
-		return fmt.Errorf("path '%s' is not a valid working directory", workdir)
+		return "", fmt.Errorf("path '%s' is not a valid working directory", workdir)
 	}
 
-	err = daemon.Kill()
+	// the daemon translates the path again, but the errors are clearer here
+	if _, err := docker.HostPath(filepath.ToSlash(workdir), runtime.GOOS); err != nil {
+		return "", humanizef(err, "could not use %s as working directory", workdir)
+	}
+
+	return workdir, nil
+}
+
+// initComponents returns the container names of the components started by
+// srcd init: gitbase and bblfshd, and the web clients too with all
+func initComponents(all bool) []string {
+	names := []string{components.Bblfshd.Name, components.Gitbase.Name}
+	if all {
+		names = append(names, components.BblfshWeb.Name, components.GitbaseWeb.Name)
+	}
+
+	return names
+}
+
+// startInitComponents starts the given components at the same time, logging
+// each one as it is started. With force they are started even if the docker
+// host doesn't meet their requirements
+func startInitComponents(names []string, force bool) error {
+	client, err := daemon.Client()
 	if err != nil {
-		return humanizef(err, "could not stop daemon")
+		return humanizef(err, "could not get daemon client")
 	}
 
-	log.Infof("starting daemon with working directory: %s", workdir)
+	done := logAfterTimeoutWithEvents(client, "this is taking a while, "+
+		"it might take a few more minutes while we install all the required images",
+		5*time.Second)
+	defer done()
 
-	if err := daemon.Start(workdir); err != nil {
-		return humanizef(err, "could not start daemon")
+	count := 0
+	_, err = startComponents(client, names, force, func(name string, port int) {
+		count++
+		log.Infof("started %s on port %d (%d/%d)", name, port, count, len(names))
+	})
+
+	return err
+}
+
+// portSetting is a config setting with a port published in the host
+type portSetting struct {
+	key  string
+	port *int
+}
+
+// publishedPorts returns the settings of conf with the ports published by the
+// daemon and the main components
+func publishedPorts(conf *api.Config) ([]portSetting, error) {
+	conf.SetDefaults()
+
+	// a port in daemon.listen takes precedence over components.daemon.port
+	ip, port, err := conf.DaemonListenAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	conf.Daemon.Listen = ip
+	conf.Components.Daemon.Port = port
+
+	_, gitbasePublished, err := conf.GitbasePublishIP()
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []portSetting{{"components.daemon.port", &conf.Components.Daemon.Port}}
+	if gitbasePublished {
+		ports = append(ports, portSetting{"components.gitbase.port", &conf.Components.Gitbase.Port})
+	}
+
+	ports = append(ports,
+		portSetting{"components.bblfshd.port", &conf.Components.Bblfshd.Port},
+		portSetting{"components.gitbase_web.port", &conf.Components.GitbaseWeb.Port},
+		portSetting{"components.bblfsh_web.port", &conf.Components.BblfshWeb.Port},
+	)
+
+	if conf.Daemon.HTTPPort != 0 {
+		ports = append(ports, portSetting{"daemon.http_port", &conf.Daemon.HTTPPort})
+	}
+
+	return ports, nil
+}
+
+// checkPorts fails if any of the ports the components are published on is
+// in use by a process or a container that does not belong to the engine. With
+// auto, a free port is set in conf instead
+func checkPorts(conf *api.Config, auto bool) error {
+	ports, err := publishedPorts(conf)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range ports {
+		conflict := docker.CheckPort(*p.port, components.Prefix())
+		if conflict == nil {
+			continue
+		}
+
+		if _, ok := conflict.(*docker.PortConflictErr); !ok {
+			return humanizef(conflict, "could not check port %d", *p.port)
+		}
+
+		if !auto {
+			return fmt.Errorf("%s.\n"+
+				"Set a different one in %s in the config file, "+
+				"or run srcd init --auto-port to use a free port", conflict, p.key)
+		}
+
+		free, err := docker.FreePort()
+		if err != nil {
+			return humanizef(err, "could not find a free port")
+		}
+
+		log.Warningf("%s, using port %d for %s instead", conflict, free, p.key)
+		*p.port = free
 	}
 
-	log.Infof("daemon started")
 	return nil
 }
 
+// runForeground prints the logs of every engine container, including the
+// ones started later, until it's interrupted. Then all the containers are
+// stopped
+func runForeground() error {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, os.Kill)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go followLogs(ctx, os.Stdout)
+
+	log.Infof("press Ctrl-C to stop all the components")
+	<-ch
+	cancel()
+
+	if err := components.Stop(components.ComponentGracePeriod); err != nil {
+		return humanizef(err, "could not stop the components")
+	}
+
+	return nil
+}
+
+// followLogs copies to w the logs of all the running engine containers, each
+// line prefixed with the container name, like docker-compose up does. New
+// containers are looked for until ctx is canceled
+func followLogs(ctx context.Context, w io.Writer) {
+	// mu serializes the lines written to w, and fmu guards following
+	var mu, fmu sync.Mutex
+	following := make(map[string]bool)
+
+	for {
+		cs, err := docker.List()
+		if err != nil {
+			log.Errorf(err, "could not list containers")
+		}
+
+		for _, c := range cs {
+			if len(c.Names) == 0 || c.State != "running" {
+				continue
+			}
+
+			name := strings.TrimLeft(c.Names[0], "/")
+			if !strings.HasPrefix(name, components.Prefix()) {
+				continue
+			}
+
+			fmu.Lock()
+			skip := following[name]
+			following[name] = true
+			fmu.Unlock()
+
+			if skip {
+				continue
+			}
+
+			prefix := fmt.Sprintf("%-12s | ", strings.TrimPrefix(name, components.Prefix()))
+			go func(name string) {
+				pw := newPrefixWriter(&mu, w, prefix)
+				if err := docker.CopyLogs(ctx, name, pw); err != nil {
+					log.Debugf("stopped following the logs of %s: %s", name, err)
+				}
+				pw.Flush()
+
+				fmu.Lock()
+				delete(following, name)
+				fmu.Unlock()
+			}(name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(&initCmd{})
 }
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+
+	enry "gopkg.in/src-d/enry.v1"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// driverLanguages are the languages with a bblfsh driver, as returned by
+// enry in lower case, which is how the daemon names them when parsing
+var driverLanguages = map[string]bool{
+	"bash":       true,
+	"go":         true,
+	"java":       true,
+	"javascript": true,
+	"php":        true,
+	"python":     true,
+	"ruby":       true,
+	"typescript": true,
+}
+
+// maxScannedFiles is the number of files of the working directory scanned to
+// detect its languages, so init doesn't take long with huge directories
+const maxScannedFiles = 100000
+
+var errStopScan = errors.New("stop scan")
+
+// detectLanguages returns the number of files of each language in the
+// working directory, detected by their extension. The .git directories,
+// dot files and vendored files are skipped. Only the checked out files are
+// scanned, not the contents of bare or siva repositories
+func detectLanguages(workdir string, maxFiles int) (map[string]int, error) {
+	langs := make(map[string]int)
+	var n int
+	err := filepath.Walk(workdir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// unreadable files are ignored, as gitbase would do
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		rel, err := filepath.Rel(workdir, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if info.Name() == ".git" || enry.IsDotFile(rel) || enry.IsVendor(rel+"/") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.Mode().IsRegular() || enry.IsDotFile(rel) || enry.IsVendor(rel) {
+			return nil
+		}
+
+		if lang, safe := enry.GetLanguageByExtension(rel); safe && lang != "" {
+			langs[strings.ToLower(lang)]++
+		}
+
+		n++
+		if n >= maxFiles {
+			return errStopScan
+		}
+
+		return nil
+	})
+	if err != nil && err != errStopScan {
+		return nil, err
+	}
+
+	return langs, nil
+}
+
+// missingDrivers returns the languages with a bblfsh driver not installed,
+// the most used first
+func missingDrivers(langs map[string]int, installed []*api.ListDriversResponse_DriverInfo) []string {
+	isInstalled := make(map[string]bool, len(installed))
+	for _, d := range installed {
+		isInstalled[strings.ToLower(d.Lang)] = true
+	}
+
+	var missing []string
+	for lang := range langs {
+		if driverLanguages[lang] && !isInstalled[lang] {
+			missing = append(missing, lang)
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool {
+		if langs[missing[i]] != langs[missing[j]] {
+			return langs[missing[i]] > langs[missing[j]]
+		}
+
+		return missing[i] < missing[j]
+	})
+
+	return missing
+}
+
+// installWorkdirDrivers installs in parallel the bblfsh drivers of the
+// languages found in the working directory that are not installed yet, so
+// the first queries using them don't wait for their images. Failures are
+// only warnings, the drivers can still be installed later
+func installWorkdirDrivers(workdir string) {
+	langs, err := detectLanguages(workdir, maxScannedFiles)
+	if err != nil {
+		log.Warningf("could not detect the languages of the working directory: %s", err)
+		return
+	}
+
+	// listing the drivers starts bblfshd, which is not needed if there are
+	// no languages with a driver
+	if len(missingDrivers(langs, nil)) == 0 {
+		return
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		log.Warningf("could not get daemon client: %s", humanize(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	installed, err := client.ListDrivers(ctx, &api.ListDriversRequest{})
+	if err != nil {
+		log.Warningf("could not list the bblfsh drivers: %s", humanize(err))
+		return
+	}
+
+	missing := missingDrivers(langs, installed.Drivers)
+	if len(missing) == 0 {
+		return
+	}
+
+	done := logAfterTimeoutWithSpinner("installing the bblfsh drivers of "+
+		strings.Join(missing, ", "), 0, 0)
+	defer done()
+
+	var wg sync.WaitGroup
+	for _, lang := range missing {
+		wg.Add(1)
+		go func(lang string) {
+			defer wg.Done()
+
+			if err := installDriver(ctx, client, lang); err != nil {
+				log.Warningf("could not install the bblfsh driver of %s: %s", lang, humanize(err))
+			}
+		}(lang)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLanguages(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-langs")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"repo/main.go",
+		"repo/cmd/root.go",
+		"repo/script.py",
+		"repo/README.md",
+		"repo/.git/hooks/pre-commit.py",
+		"repo/vendor/lib/lib.go",
+		"repo/node_modules/x/index.js",
+		"other/app.rb",
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		require.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(ioutil.WriteFile(path, nil, 0644))
+	}
+
+	langs, err := detectLanguages(dir, 100)
+	require.NoError(err)
+	require.Equal(map[string]int{
+		"go":     2,
+		"python": 1,
+		"ruby":   1,
+	}, langs)
+
+	langs, err = detectLanguages(dir, 1)
+	require.NoError(err)
+	require.Len(langs, 1)
+}
+
+func TestMissingDrivers(t *testing.T) {
+	require := require.New(t)
+
+	langs := map[string]int{"go": 2, "python": 5, "markdown": 10, "ruby": 2}
+	require.Equal([]string{"python", "go", "ruby"}, missingDrivers(langs, nil))
+
+	installed := []*api.ListDriversResponse_DriverInfo{{Lang: "python"}, {Lang: "Ruby"}}
+	require.Equal([]string{"go"}, missingDrivers(langs, installed))
+}
@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/docker"
+
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// jobsCmd represents the jobs command
+type jobsCmd struct {
+	cli.PlainCommand `name:"jobs" short-description:"Manage the jobs run by the daemon" long-description:"Manage the long operations run by the daemon in the background as jobs: starting and restarting components, which may pull their images, installing bblfsh drivers, creating gitbase indexes, downloading public datasets and pulling images. The commands that run them follow their progress, and the jobs keep running if they are interrupted or lose the connection to the daemon."`
+}
+
+// jobsListCmd represents the jobs list command
+type jobsListCmd struct {
+	Command `name:"list" short-description:"List the jobs" long-description:"List the jobs running in the daemon and the last ones finished, oldest first"`
+}
+
+// jobsWatchCmd represents the jobs watch command
+type jobsWatchCmd struct {
+	Command `name:"watch" short-description:"Follow the progress of a job" long-description:"Show the progress of a job until it finishes, failing if the job fails or is cancelled. Interrupting the command doesn't stop the job"`
+
+	Args struct {
+		ID string `positional-arg-name:"id" required:"yes" description:"id of the job"`
+	} `positional-args:"yes"`
+}
+
+// jobsCancelCmd represents the jobs cancel command
+type jobsCancelCmd struct {
+	Command `name:"cancel" short-description:"Cancel a running job" long-description:"Cancel a running job. With token authentication, only the admin users can use the jobs"`
+
+	Args struct {
+		ID string `positional-arg-name:"id" required:"yes" description:"id of the job"`
+	} `positional-args:"yes"`
+}
+
+// jobsListOutput is the output of srcd jobs list
+type jobsListOutput struct {
+	Jobs []jobOutput `json:"jobs" yaml:"jobs"`
+}
+
+type jobOutput struct {
+	ID       string     `json:"id" yaml:"id"`
+	Kind     string     `json:"kind" yaml:"kind"`
+	Name     string     `json:"name" yaml:"name"`
+	State    string     `json:"state" yaml:"state"`
+	Message  string     `json:"message" yaml:"message"`
+	Error    string     `json:"error,omitempty" yaml:"error,omitempty"`
+	User     string     `json:"user" yaml:"user"`
+	Started  time.Time  `json:"started" yaml:"started"`
+	Finished *time.Time `json:"finished" yaml:"finished"`
+	Port     int        `json:"port,omitempty" yaml:"port,omitempty"`
+}
+
+func newJobOutput(j *api.Job) jobOutput {
+	out := jobOutput{
+		ID:      j.Id,
+		Kind:    j.Kind,
+		Name:    j.Name,
+		State:   jobState(j),
+		Message: j.Message,
+		Error:   j.Error,
+		User:    j.User,
+		Started: time.Unix(0, j.Started),
+		Port:    int(j.Port),
+	}
+	if j.Finished != 0 {
+		t := time.Unix(0, j.Finished)
+		out.Finished = &t
+	}
+
+	return out
+}
+
+// jobState returns the state of a job in the output of srcd jobs
+func jobState(j *api.Job) string {
+	return strings.ToLower(j.State.String())
+}
+
+func (c *jobsListCmd) Execute(args []string) error {
+	client, err := jobsClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := client.ListJobs(ctx, &api.ListJobsRequest{})
+	if err != nil {
+		return humanizef(err, "could not list the jobs")
+	}
+
+	out := jobsListOutput{Jobs: []jobOutput{}}
+	t := NewTable("%s", "%s", "%s", "%s", "%s", "%s", "%s")
+	t.Header("ID", "KIND", "NAME", "STATE", "USER", "STARTED", "MESSAGE")
+	for _, j := range res.Jobs {
+		o := newJobOutput(j)
+		out.Jobs = append(out.Jobs, o)
+
+		msg := o.Message
+		if o.Error != "" {
+			msg = o.Error
+		}
+
+		t.Row(o.ID, o.Kind, o.Name, o.State, o.User, o.Started.Format(time.RFC3339), msg)
+	}
+
+	return render(os.Stdout, out, t.Print)
+}
+
+func (c *jobsWatchCmd) Execute(args []string) error {
+	client, err := jobsClient()
+	if err != nil {
+		return err
+	}
+
+	j, err := watchJob(context.Background(), client, c.Args.ID, func(j *api.Job) {
+		if isTextOutput() && j.Message != "" {
+			fmt.Println(j.Message)
+		}
+	})
+	if j == nil {
+		return humanizef(err, "could not watch the job %s", c.Args.ID)
+	}
+
+	out := newJobOutput(j)
+	if rerr := render(os.Stdout, out, func(w io.Writer) error {
+		fmt.Fprintf(w, "%s job %s of %s %s\n", out.Kind, out.ID, out.Name, out.State)
+		return nil
+	}); rerr != nil {
+		return rerr
+	}
+
+	return err
+}
+
+func (c *jobsCancelCmd) Execute(args []string) error {
+	client, err := jobsClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	j, err := client.CancelJob(ctx, &api.CancelJobRequest{Id: c.Args.ID})
+	if err != nil {
+		return humanizef(err, "could not cancel the job %s", c.Args.ID)
+	}
+
+	out := newJobOutput(j)
+	return render(os.Stdout, out, func(w io.Writer) error {
+		fmt.Fprintf(w, "cancelling the %s job %s of %s\n", out.Kind, out.ID, out.Name)
+		return nil
+	})
+}
+
+// jobsClient returns the client of a daemon that runs jobs
+func jobsClient() (api.EngineClient, error) {
+	client, err := daemon.Client()
+	if err != nil {
+		return nil, humanizef(err, "could not get daemon client")
+	}
+
+	if err := daemon.RequireFeature(api.FeatureJobs); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// jobsDaemon returns the client of the daemon that runs the jobs of the given
+// feature instead of srcd: a remote one, or a local one that is already
+// running. It returns nil if srcd has to do the work itself, because there
+// is no such daemon or it is too old
+func jobsDaemon(feature string) (api.EngineClient, error) {
+	if daemon.IsDaemonless() {
+		return nil, nil
+	}
+
+	if !daemon.IsRemote() {
+		if running, err := daemon.IsRunning(); err != nil || !running {
+			return nil, nil
+		}
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return nil, humanizef(err, "could not get daemon client")
+	}
+
+	if !daemon.Supports(api.FeatureJobs) || !daemon.Supports(feature) {
+		return nil, nil
+	}
+
+	return client, nil
+}
+
+// startJob starts a job in the daemon and, with wait, follows it until it
+// finishes like runJob. Otherwise it returns the job once it is started
+func startJob(
+	ctx context.Context,
+	client api.EngineClient,
+	req *api.StartJobRequest,
+	wait bool,
+	progress func(*api.Job),
+) (*api.Job, error) {
+	if wait {
+		return runJob(ctx, client, req, progress)
+	}
+
+	return client.StartJob(ctx, req)
+}
+
+// printJobStarted shows the job started by a command without --wait
+func printJobStarted(j *api.Job) error {
+	out := newJobOutput(j)
+	return render(os.Stdout, out, func(w io.Writer) error {
+		fmt.Fprintf(w, "the %s job %s of %s is running in the daemon, follow it with srcd jobs watch %s\n",
+			out.Kind, out.ID, out.Name, out.ID)
+		return nil
+	})
+}
+
+// runJob starts a job in the daemon and waits until it finishes, calling
+// progress each time it changes. The job keeps running if ctx is cancelled
+// or the connection to the daemon is lost, and the error explains how to
+// follow it
+func runJob(
+	ctx context.Context,
+	client api.EngineClient,
+	req *api.StartJobRequest,
+	progress func(*api.Job),
+) (*api.Job, error) {
+	j, err := client.StartJob(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := watchJob(ctx, client, j.Id, progress)
+	if res == nil || !res.Done() {
+		return res, fmt.Errorf("%s; the job %s keeps running in the daemon, "+
+			"follow it with srcd jobs watch %s", humanize(err), j.Id, j.Id)
+	}
+
+	return res, err
+}
+
+// watchJob follows a job until it finishes, calling progress each time it
+// changes. It returns the last state of the job received, with the error of
+// the job if it failed or was cancelled
+func watchJob(
+	ctx context.Context,
+	client api.EngineClient,
+	id string,
+	progress func(*api.Job),
+) (*api.Job, error) {
+	stream, err := client.WatchJob(ctx, &api.WatchJobRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	var last *api.Job
+	for {
+		j, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return last, err
+		}
+
+		if last == nil || last.Message != j.Message {
+			progress(j)
+		}
+
+		last = j
+	}
+
+	if last == nil || !last.Done() {
+		return last, fmt.Errorf("the daemon stopped sending the progress of the job %s", id)
+	}
+
+	if last.State != api.Job_SUCCEEDED {
+		return last, errors.New(last.Error)
+	}
+
+	return last, nil
+}
+
+// logJobProgress logs the progress of the jobs run by other commands
+func logJobProgress(j *api.Job) {
+	if j.Message != "" {
+		log.Debugf("%s job %s: %s", j.Kind, j.Id, j.Message)
+	}
+}
+
+// startComponent starts the component with the given container name, as a
+// job if the daemon supports them, and returns its public port
+func startComponent(ctx context.Context, client api.EngineClient, name string) (int, error) {
+	if !daemon.Supports(api.FeatureJobs) {
+		res, err := client.StartComponent(ctx, &api.StartComponentRequest{Name: name})
+		return int(res.GetPort()), err
+	}
+
+	j, err := runJob(ctx, client, &api.StartJobRequest{
+		Kind: api.JobStartComponent,
+		Name: name,
+	}, logJobProgress)
+	return int(j.GetPort()), err
+}
+
+// restartComponent restarts the component with the given container name, as
+// a job if the daemon supports them, and returns its public port
+func restartComponent(ctx context.Context, client api.EngineClient, name string, hard bool) (int, error) {
+	if !daemon.Supports(api.FeatureJobs) {
+		res, err := client.RestartComponent(ctx, &api.RestartComponentRequest{Name: name, Hard: hard})
+		return int(res.GetPort()), err
+	}
+
+	j, err := runJob(ctx, client, &api.StartJobRequest{
+		Kind: api.JobRestartComponent,
+		Name: name,
+		Hard: hard,
+	}, logJobProgress)
+	return int(j.GetPort()), err
+}
+
+// installDriver installs the default bblfsh driver of the language, as a job
+// if the daemon supports them
+func installDriver(ctx context.Context, client api.EngineClient, lang string) error {
+	if !daemon.Supports(api.FeatureJobs) {
+		_, err := client.InstallDriver(ctx, &api.InstallDriverRequest{Lang: lang})
+		return err
+	}
+
+	_, err := runJob(ctx, client, &api.StartJobRequest{
+		Kind: api.JobInstallDriver,
+		Name: lang,
+	}, logJobProgress)
+	return err
+}
+
+// pullImage pulls the image with the given version as a pull-image job of
+// the local daemon, so it keeps running if srcd is interrupted, or with the
+// docker of this host if the daemon is not running or is too old. Without
+// wait it returns the job once it is started, and it returns nil if the
+// image was not pulled by a job
+func pullImage(ctx context.Context, image, version string, wait bool) (*api.Job, error) {
+	var client api.EngineClient
+	// the components are run by the docker of this host, not the one of a
+	// remote daemon
+	if !daemon.IsRemote() {
+		var err error
+		if client, err = jobsDaemon(api.FeaturePullImageJob); err != nil {
+			return nil, err
+		}
+	}
+
+	if client == nil {
+		return nil, docker.Pull(ctx, image, version)
+	}
+
+	return startJob(ctx, client, &api.StartJobRequest{
+		Kind: api.JobPullImage,
+		Name: image + ":" + version,
+	}, wait, logJobProgress)
+}
+
+// createIndex runs the CREATE INDEX statement as a job in the daemon,
+// showing its progress
+func createIndex(client api.EngineClient, query string) error {
+	var started bool
+	_, err := runJob(context.Background(), client, &api.StartJobRequest{
+		Kind:  api.JobCreateIndex,
+		Query: query,
+	}, func(j *api.Job) {
+		if !started {
+			started = true
+			log.Infof("creating the index in the job %s, it keeps running if srcd is interrupted", j.Id)
+		}
+	})
+	if err != nil {
+		return humanizef(err, "could not create the index")
+	}
+
+	log.Infof("index created")
+	return nil
+}
+
+func init() {
+	c := rootCmd.AddCommand(&jobsCmd{})
+	c.AddCommand(&jobsListCmd{})
+	c.AddCommand(&jobsWatchCmd{})
+	c.AddCommand(&jobsCancelCmd{})
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type fakeJobsClient struct {
+	api.EngineClient
+	updates []*api.Job
+	// err is returned by the stream after the updates instead of io.EOF
+	err error
+}
+
+func (c *fakeJobsClient) StartJob(
+	ctx context.Context,
+	in *api.StartJobRequest,
+	opts ...grpc.CallOption,
+) (*api.Job, error) {
+	return &api.Job{Id: "0123456789ab", Kind: in.Kind, Name: in.Name}, nil
+}
+
+func (c *fakeJobsClient) WatchJob(
+	ctx context.Context,
+	in *api.WatchJobRequest,
+	opts ...grpc.CallOption,
+) (api.Engine_WatchJobClient, error) {
+	return &fakeJobsStream{updates: c.updates, err: c.err}, nil
+}
+
+type fakeJobsStream struct {
+	grpc.ClientStream
+	updates []*api.Job
+	err     error
+}
+
+func (s *fakeJobsStream) Recv() (*api.Job, error) {
+	if len(s.updates) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+
+		return nil, io.EOF
+	}
+
+	j := s.updates[0]
+	s.updates = s.updates[1:]
+	return j, nil
+}
+
+func TestRunJob(t *testing.T) {
+	require := require.New(t)
+
+	client := &fakeJobsClient{updates: []*api.Job{
+		{Id: "0123456789ab", Message: "starting srcd-cli-gitbase"},
+		{Id: "0123456789ab", Message: "installing srcd/gitbase:v0.24.0"},
+		{Id: "0123456789ab", Message: "installing srcd/gitbase:v0.24.0"},
+		{Id: "0123456789ab", Message: "started srcd-cli-gitbase", State: api.Job_SUCCEEDED, Port: 3306},
+	}}
+
+	var msgs []string
+	j, err := runJob(context.Background(), client, &api.StartJobRequest{
+		Kind: api.JobStartComponent,
+		Name: "srcd-cli-gitbase",
+	}, func(j *api.Job) { msgs = append(msgs, j.Message) })
+	require.NoError(err)
+	require.Equal(int32(3306), j.Port)
+	require.Equal([]string{
+		"starting srcd-cli-gitbase",
+		"installing srcd/gitbase:v0.24.0",
+		"started srcd-cli-gitbase",
+	}, msgs)
+}
+
+func TestRunJobFailed(t *testing.T) {
+	require := require.New(t)
+
+	client := &fakeJobsClient{updates: []*api.Job{
+		{Id: "0123456789ab", State: api.Job_FAILED, Error: "no such image"},
+	}}
+
+	_, err := runJob(context.Background(), client, &api.StartJobRequest{}, func(*api.Job) {})
+	require.EqualError(err, "no such image")
+}
+
+func TestRunJobDisconnected(t *testing.T) {
+	require := require.New(t)
+
+	client := &fakeJobsClient{
+		updates: []*api.Job{{Id: "0123456789ab", Message: "creating the index"}},
+		err:     fmt.Errorf("connection reset"),
+	}
+
+	j, err := runJob(context.Background(), client, &api.StartJobRequest{}, func(*api.Job) {})
+	require.False(j.Done())
+	require.EqualError(err, "connection reset; the job 0123456789ab keeps running in the daemon, "+
+		"follow it with srcd jobs watch 0123456789ab")
+}
+
+func TestStartJob(t *testing.T) {
+	require := require.New(t)
+
+	client := &fakeJobsClient{updates: []*api.Job{
+		{Id: "0123456789ab", Message: "pulling srcd/gitbase:v0.24.0"},
+		{Id: "0123456789ab", State: api.Job_SUCCEEDED},
+	}}
+	req := &api.StartJobRequest{Kind: api.JobPullImage, Name: "srcd/gitbase:v0.24.0"}
+
+	var msgs []string
+	progress := func(j *api.Job) { msgs = append(msgs, j.Message) }
+
+	// without wait the job is not followed
+	j, err := startJob(context.Background(), client, req, false, progress)
+	require.NoError(err)
+	require.False(j.Done())
+	require.Equal(api.JobPullImage, j.Kind)
+	require.Empty(msgs)
+
+	j, err = startJob(context.Background(), client, req, true, progress)
+	require.NoError(err)
+	require.True(j.Done())
+	require.Equal([]string{"pulling srcd/gitbase:v0.24.0", ""}, msgs)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	var out bytes.Buffer
+	a := newPrefixWriter(&mu, &out, "a | ")
+	b := newPrefixWriter(&mu, &out, "b | ")
+
+	n, err := a.Write([]byte("first\nsec"))
+	assert.NoError(err)
+	assert.Equal(9, n)
+
+	_, err = b.Write([]byte("other\n"))
+	assert.NoError(err)
+
+	_, err = a.Write([]byte("ond\nlast"))
+	assert.NoError(err)
+	assert.NoError(a.Flush())
+	assert.NoError(b.Flush())
+
+	assert.Equal("a | first\nb | other\na | second\na | last\n", out.String())
+}
@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"github.com/docker/docker/api/types/container"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/browser"
+	"github.com/pkg/errors"
+)
+
+const (
+	notebookMountPath = "/home/jovyan/work"
+	// notebookTokenLabel is the container label holding the access token, so
+	// the URL can be printed again while the notebook is running
+	notebookTokenLabel = "srcd.notebook.token"
+	// notebookPackages are installed when the container starts, to connect to
+	// gitbase and bblfshd
+	notebookPackages = "pymysql bblfsh"
+	// notebookUsersGroup is the gid of the users group of the jupyter images
+	notebookUsersGroup = "100"
+)
+
+// notebookCmd represents the notebook command
+type notebookCmd struct {
+	Command `name:"notebook" short-description:"Start a Jupyter notebook connected to the engine" long-description:"Start a Jupyter notebook with clients for gitbase (pymysql) and bblfsh (bblfsh-python) installed.\n\nThe GITBASE_HOST, GITBASE_PORT and BBLFSH_ENDPOINT environment variables\nin the notebook hold the addresses of the components, and GITBASE_USER and\nGITBASE_PASSWORD the credentials of gitbase. The notebooks are\nsaved in the given directory, mounted at ~/work."`
+
+	Dir string `short:"d" long:"dir" description:"directory where the notebooks are saved (default: $HOME/.srcd/notebooks)"`
+}
+
+func (c *notebookCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	if err := config.Read(c.Config); err != nil {
+		return humanizef(err, "could not read the config file")
+	}
+
+	conf := *config.File
+	conf.SetDefaults()
+	conf.SetDefaultUser(runtime.GOOS)
+
+	dir, err := notebooksDir(c.Dir)
+	if err != nil {
+		return humanizef(err, "could not get the notebooks directory")
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	started := logAfterTimeoutWithEvents(client, "this is taking a while, "+
+		"if this is the first time you launch the notebook, "+
+		"it might take a few more minutes while we install all the required images",
+		5*time.Second)
+
+	env, err := notebookEnv(client, components.Notebook.Name)
+	if err != nil {
+		started()
+		return err
+	}
+
+	port := conf.Components.Notebook.Port
+	opts := append(notebookOptions(&conf), docker.WithPort(port, components.NotebookPort))
+	token, err := startNotebook(components.Notebook, dir,
+		[]string{"pip install --quiet " + notebookPackages}, env, opts...)
+	started()
+	if err != nil {
+		return humanizef(err, "could not start the notebook")
+	}
+
+	return serveNotebook(components.Notebook, port, token, dir, "the notebook")
+}
+
+// serveNotebook waits for the notebook of the component to be ready, opens it
+// in the browser and removes its container on Ctrl-C. what describes the
+// notebook in the message with its URL
+func serveNotebook(cmp components.Component, port int, token, dir, what string) error {
+	ready := logAfterTimeoutWithSpinner("waiting for the notebook to be ready", 3*time.Second, 0)
+	err := waitNotebook(port)
+	ready()
+	if err != nil {
+		return humanizef(err, "could not connect to the notebook")
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, os.Kill)
+
+	url := fmt.Sprintf("http://localhost:%d/?token=%s", port, token)
+	fmt.Printf("Go to %s for %s. Notebooks are saved in %s. Press Ctrl-C to stop it.\n", url, what, dir)
+	_ = browser.OpenURL(url)
+
+	<-ch
+
+	if err := docker.RemoveContainer(cmp.Name); err != nil {
+		return humanizef(err, "could not stop the notebook")
+	}
+
+	return nil
+}
+
+func notebooksDir(dir string) (string, error) {
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(home, ".srcd", "notebooks")
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// notebookEnv starts gitbase and bblfshd for the notebook component with the
+// given name, and returns the environment variables with their addresses.
+// When the daemon is remote the notebook can't use the internal network, and
+// it connects to the ports published in the remote host
+func notebookEnv(client api.EngineClient, name string) ([]string, error) {
+	ports, err := startDependencies(client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	gitbaseHost, gitbasePort := components.Gitbase.Name, components.GitbasePort
+	bblfshHost, bblfshPort := components.Bblfshd.Name, components.BblfshParsePort
+	if daemon.IsRemote() {
+		gitbaseHost, gitbasePort = daemon.Hostname(), ports[components.Gitbase.Name]
+		bblfshHost, bblfshPort = daemon.Hostname(), ports[components.Bblfshd.Name]
+		if gitbasePort == 0 {
+			return nil, errGitbaseNotPublished
+		}
+	}
+
+	user, password := config.File.GitbaseCredentials()
+	return []string{
+		"GITBASE_HOST=" + gitbaseHost,
+		"GITBASE_PORT=" + strconv.Itoa(gitbasePort),
+		"GITBASE_USER=" + user,
+		"GITBASE_PASSWORD=" + password,
+		fmt.Sprintf("BBLFSH_ENDPOINT=%s:%d", bblfshHost, bblfshPort),
+	}, nil
+}
+
+// notebookOptions returns the options to run the notebook as the user set in
+// the config, if any, and with the proxy settings pip needs to install the
+// clients when the notebook starts
+func notebookOptions(conf *api.Config) []docker.ConfigOption {
+	opts := notebookUserOptions(conf)
+	if conf.InjectsProxy() {
+		opts = append(opts, docker.WithProxy(
+			docker.ProxyFromEnvironment(), components.NoProxyHosts()...))
+	}
+
+	return opts
+}
+
+// notebookUserOptions returns the options to run the notebook as the user
+// set in the config, if any. The users group owns the conda directory of the
+// jupyter images, so any user in it can install the clients
+func notebookUserOptions(conf *api.Config) []docker.ConfigOption {
+	if conf.Security.User == "" {
+		return nil
+	}
+
+	return []docker.ConfigOption{
+		docker.WithUser(conf.Security.User),
+		docker.WithGroupAdd(append([]string{notebookUsersGroup}, conf.Security.GroupAdd...)...),
+	}
+}
+
+// startNotebook starts the Jupyter container of the component if it's not
+// running, and returns its access token. The setup shell commands are run
+// before the notebook server starts
+func startNotebook(
+	cmp components.Component,
+	dir string,
+	setup, env []string,
+	opts ...docker.ConfigOption,
+) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	info, err := docker.InfoOrStart(ctx, cmp.Name, func(ctx context.Context) error {
+		if err := docker.EnsureInstalled(cmp.Image, cmp.Version); err != nil {
+			return err
+		}
+
+		token, err := notebookToken()
+		if err != nil {
+			return err
+		}
+
+		hostPath, err := docker.HostPath(dir, runtime.GOOS)
+		if err != nil {
+			return err
+		}
+
+		config := &container.Config{
+			Image:  cmp.ImageWithVersion(),
+			Env:    env,
+			Labels: map[string]string{notebookTokenLabel: token},
+			Cmd: []string{"sh", "-c", strings.Join(append(setup,
+				"exec start-notebook.sh --NotebookApp.token="+token,
+			), " && ")},
+		}
+		host := &container.HostConfig{}
+		docker.ApplyOptions(config, host, append([]docker.ConfigOption{
+			docker.WithSharedDirectory(hostPath, notebookMountPath, runtime.GOOS),
+		}, opts...)...)
+
+		return docker.Start(ctx, config, host, cmp.Name)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	token, ok := info.Labels[notebookTokenLabel]
+	if !ok {
+		return "", fmt.Errorf("container %s does not have an access token, remove it with srcd stop", cmp.Name)
+	}
+
+	return token, nil
+}
+
+// waitNotebook polls the Jupyter API until it replies, since the packages are
+// installed before the notebook server starts
+func waitNotebook(port int) error {
+	timeout := time.After(5 * time.Minute)
+	url := fmt.Sprintf("http://localhost:%d/api", port)
+	for {
+		res, err := http.Get(url)
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return nil
+			}
+
+			err = fmt.Errorf("status %d", res.StatusCode)
+		}
+
+		select {
+		case <-timeout:
+			return errors.Wrap(err, "timeout waiting for the notebook")
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func notebookToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func init() {
+	rootCmd.AddCommand(&notebookCmd{})
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/src-d/engine/docker"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// output formats of the --output global option
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// outputFormat returns the format set with --output
+func outputFormat() string {
+	if globalOptions.Output == "" {
+		return outputText
+	}
+
+	return globalOptions.Output
+}
+
+// isTextOutput returns whether the output is meant for humans
+func isTextOutput() bool {
+	return outputFormat() == outputText
+}
+
+// render writes v to w in the format set with --output. The text format is
+// written by text, usually as a table. The JSON and YAML documents use the
+// json and yaml tags of v, which are the stable schema of the command output,
+// so fields can be added but not renamed or removed
+func render(w io.Writer, v interface{}, text func(io.Writer) error) error {
+	return renderFormat(w, outputFormat(), v, text)
+}
+
+func renderFormat(w io.Writer, format string, v interface{}, text func(io.Writer) error) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(b)
+		return err
+	default:
+		return text(w)
+	}
+}
+
+// optionalBool returns nil if b could not be checked, so it is rendered as
+// null instead of false
+func optionalBool(b bool, err error) *bool {
+	if err != nil {
+		return nil
+	}
+
+	return &b
+}
+
+// publicPorts returns the public port bindings of the given ports, never nil
+// so they are rendered as an empty list
+func publicPorts(ps []docker.Port) []int {
+	ports := []int{}
+	for _, p := range ps {
+		if p.PublicPort != 0 {
+			ports = append(ports, int(p.PublicPort))
+		}
+	}
+
+	return ports
+}
+
+// containerOutput is the schema of a container in the output of srcd status
+// and srcd prune --dry-run. The health and the restart count are only part of
+// the status
+type containerOutput struct {
+	Name         string `json:"name" yaml:"name"`
+	Image        string `json:"image" yaml:"image"`
+	State        string `json:"state" yaml:"state"`
+	Ports        []int  `json:"ports" yaml:"ports"`
+	Health       string `json:"health,omitempty" yaml:"health,omitempty"`
+	RestartCount int    `json:"restart_count,omitempty" yaml:"restart_count,omitempty"`
+}
+
+// newContainersOutput returns the output of the given containers, with the
+// details of their inspections by name, if any
+func newContainersOutput(cs []docker.Container, ins map[string]*docker.Inspection) []containerOutput {
+	out := []containerOutput{}
+	for _, c := range cs {
+		o := containerOutput{
+			Name:  strings.TrimLeft(c.Names[0], "/"),
+			Image: c.Image,
+			State: c.State,
+			Ports: publicPorts(c.Ports),
+		}
+
+		if in, ok := ins[o.Name]; ok {
+			o.Health = in.Health
+			o.RestartCount = in.RestartCount
+		}
+
+		out = append(out, o)
+	}
+
+	return out
+}
+
+// volumeOutput is the schema of a volume in the output of srcd status and
+// srcd prune --dry-run. The size is -1 if it could not be computed
+type volumeOutput struct {
+	Name string `json:"name" yaml:"name"`
+	Size int64  `json:"size" yaml:"size"`
+}
+
+func newVolumesOutput(vs []*docker.Volume) []volumeOutput {
+	out := []volumeOutput{}
+	for _, v := range vs {
+		out = append(out, volumeOutput{Name: v.Name, Size: docker.VolumeSize(v)})
+	}
+
+	return out
+}
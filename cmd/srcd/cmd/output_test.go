@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/src-d/engine/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderFormat(t *testing.T) {
+	v := driversOutput{Drivers: []driverOutput{{Language: "go", Version: "v2.7.1"}}}
+	text := func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, "go v2.7.1")
+		return err
+	}
+
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{outputText, "go v2.7.1\n"},
+		{outputJSON, `{
+  "drivers": [
+    {
+      "language": "go",
+      "version": "v2.7.1"
+    }
+  ]
+}
+`},
+		{outputYAML, `drivers:
+- language: go
+  version: v2.7.1
+`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		require.NoError(t, renderFormat(&buf, c.format, v, text), c.format)
+		assert.Equal(t, c.expected, buf.String(), c.format)
+	}
+}
+
+func TestContainersOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	cs := []docker.Container{{
+		Names: []string{"/srcd-cli-gitbase"},
+		Image: "srcd/gitbase:v0.24.0",
+		State: "running",
+		Ports: []docker.Port{{PrivatePort: 3306, PublicPort: 3306}, {PrivatePort: 8080}},
+	}}
+
+	assert.Equal([]containerOutput{{
+		Name:  "srcd-cli-gitbase",
+		Image: "srcd/gitbase:v0.24.0",
+		State: "running",
+		Ports: []int{3306},
+	}}, newContainersOutput(cs, nil))
+
+	out := newContainersOutput(cs, map[string]*docker.Inspection{
+		"srcd-cli-gitbase": {Health: "healthy", RestartCount: 1},
+	})
+	assert.Equal("healthy", out[0].Health)
+	assert.Equal(1, out[0].RestartCount)
+
+	assert.Equal([]containerOutput{}, newContainersOutput(nil, nil))
+}
+
+func TestOptionalBool(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(optionalBool(true, fmt.Errorf("foo")))
+	if b := optionalBool(false, nil); assert.NotNil(b) {
+		assert.False(*b)
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/pkg/term"
+	"github.com/pkg/errors"
+)
+
+// defaultPager is used when $PAGER is not set. -S chops the long lines
+// instead of wrapping them, so the table columns stay aligned
+const defaultPager = "less -S"
+
+// pagerCommand returns the command line of the pager, $PAGER or less -S
+func pagerCommand() string {
+	if p := strings.TrimSpace(os.Getenv("PAGER")); p != "" {
+		return p
+	}
+
+	return defaultPager
+}
+
+// pager is a running pager process. The output written to it is shown
+// page by page in the terminal
+type pager struct {
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+	quit bool
+}
+
+// startPager starts the pager command line using the shell, so $PAGER can
+// contain arguments
+func startPager(cmdline string) (*pager, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", cmdline)
+	} else {
+		cmd = exec.Command("sh", "-c", cmdline)
+	}
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// without these options less would clear the screen and wait for q even
+	// for the results that fit in it
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create pager input")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "could not start pager %q", cmdline)
+	}
+
+	return &pager{cmd: cmd, in: in}, nil
+}
+
+// Write sends the output to the pager. Once the user exits the pager the
+// rest of the output is discarded, so the writer can keep going until it ends
+func (p *pager) Write(b []byte) (int, error) {
+	if p.quit {
+		return len(b), nil
+	}
+
+	if _, err := p.in.Write(b); err != nil {
+		p.quit = true
+	}
+
+	return len(b), nil
+}
+
+// Close closes the pager input and waits until the user exits it
+func (p *pager) Close() error {
+	p.in.Close()
+	return p.cmd.Wait()
+}
+
+// stdoutIsTerminal returns whether the standard output is a terminal, the
+// pager is not used when the output is redirected
+func stdoutIsTerminal() bool {
+	_, out, _ := term.StdStreams()
+	_, isTerminal := term.GetFdInfo(out)
+	return isTerminal
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagerCommand(t *testing.T) {
+	require := require.New(t)
+
+	old, ok := os.LookupEnv("PAGER")
+	defer func() {
+		if ok {
+			os.Setenv("PAGER", old)
+		} else {
+			os.Unsetenv("PAGER")
+		}
+	}()
+
+	os.Unsetenv("PAGER")
+	require.Equal("less -S", pagerCommand())
+
+	os.Setenv("PAGER", " ")
+	require.Equal("less -S", pagerCommand())
+
+	os.Setenv("PAGER", "more")
+	require.Equal("more", pagerCommand())
+}
@@ -36,30 +36,35 @@ type parseCmd struct {
 
 // parseUASTCmd represents the parse uast command
 type parseUASTCmd struct {
-	Command `name:"uast" short-description:"Parse and return the filtered UAST of the given file" long-description:"Parse and return the filtered UAST of the given file\n\nThis command parses the given file, automatically identifying the language\nunless the --lang flag is used. The resulting Universal Abstract Syntax Trees\n(UASTs) are filtered with the given --query XPath expression. By default it\nreturns UAST in semantic mode, it can be changed using --mode flag.\n\nThe remaining nodes are printed to standard output in JSON format."`
+	Command `name:"uast" short-description:"Parse and return the filtered UAST of the given files" long-description:"Parse and return the filtered UAST of the given files\n\nThis command parses the given files, automatically identifying the language\nunless the --lang flag is used. The resulting Universal Abstract Syntax Trees\n(UASTs) are filtered with the given --query XPath expression. By default it\nreturns UAST in semantic mode, it can be changed using --mode flag.\n\nThe remaining nodes are printed to standard output in JSON format. With\nseveral files, a JSON object with the file, lang and uast fields is printed\nfor each one."`
 
 	Lang  string `short:"l" long:"lang" description:"avoid language detection, use this parser"`
 	Query string `short:"q" long:"query" description:"XPath query applied to the parsed UASTs"`
 	Mode  string `short:"m" long:"mode" choice:"semantic" choice:"annotated" choice:"native" default:"semantic" description:"UAST parsing mode"`
 
 	Args struct {
-		Path string `positional-arg-name:"file-path" required:"yes"`
+		Paths []string `positional-arg-name:"file-path" required:"yes"`
 	} `positional-args:"yes"`
 }
 
 func (cmd *parseUASTCmd) Execute(args []string) error {
 	if len(args) > 0 {
-		return fmt.Errorf("too many arguments, expected only one path")
+		return fmt.Errorf("too many arguments, expected only file paths")
 	}
 
-	b, err := ioutil.ReadFile(cmd.Args.Path)
+	c, err := daemon.Client()
 	if err != nil {
-		return humanizef(err, "could not read %s", cmd.Args.Path)
+		return humanizef(err, "could not get daemon client")
 	}
 
-	c, err := daemon.Client()
+	if len(cmd.Args.Paths) > 1 {
+		return cmd.parseBatch(c)
+	}
+
+	path := cmd.Args.Paths[0]
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return humanizef(err, "could not get daemon client")
+		return humanizef(err, "could not read %s", path)
 	}
 
 	// First time it can be quite slow, as it may have to pull images.
@@ -81,7 +86,7 @@ func (cmd *parseUASTCmd) Execute(args []string) error {
 	var resp *api.ListDriversResponse
 
 	if lang == "" {
-		lang, err = parseLang(ctx, c, cmd.Args.Path, b)
+		lang, err = parseLang(ctx, c, path, b)
 		started()
 
 		if err != nil {
@@ -106,7 +111,7 @@ func (cmd *parseUASTCmd) Execute(args []string) error {
 
 	stream, err := c.ParseWithLogs(ctx, &api.ParseRequest{
 		Kind:    api.ParseRequest_UAST,
-		Name:    cmd.Args.Path,
+		Name:    path,
 		Content: b,
 		Lang:    lang,
 		Query:   cmd.Query,
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// parseBatchMaxBytes is the size of the files sent in each ParseBatch
+// request, to stay below the default gRPC message size limit of the daemon
+// of 4 MiB
+const parseBatchMaxBytes = 3 << 20
+
+// parsedFile is the output of each file parsed by srcd parse uast when
+// several files are given
+type parsedFile struct {
+	File string            `json:"file"`
+	Lang string            `json:"lang"`
+	UAST []json.RawMessage `json:"uast"`
+}
+
+// parseBatch parses all the files of the command with ParseBatch requests,
+// printing a JSON object with the UASTs of each file. The files that can't
+// be read or parsed are reported, and don't stop the rest
+func (cmd *parseUASTCmd) parseBatch(c api.EngineClient) error {
+	mode, err := parseModeArg(cmd.Mode)
+	if err != nil {
+		return err
+	}
+
+	// First time it can be quite slow, as it may have to pull images.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	started := logAfterTimeout("this is taking a while, "+
+		"if this is the first time you launch the parsing client, "+
+		"it might take a few more minutes while we install all the required images",
+		3*time.Second)
+	isStarted := false
+
+	var failed int
+	var batch []*api.ParseRequest
+	var size int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		resp, err := parseFiles(ctx, c, &api.ParseBatchRequest{Files: batch})
+		if !isStarted {
+			started()
+			isStarted = true
+		}
+		if err != nil {
+			return humanizef(err, "could not parse files")
+		}
+
+		failed += printParsedFiles(os.Stdout, resp.Results)
+		batch, size = nil, 0
+		return nil
+	}
+
+	for _, path := range cmd.Args.Paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Warningf("could not read %s: %s", path, err)
+			failed++
+			continue
+		}
+
+		if size+len(b) > parseBatchMaxBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		batch = append(batch, &api.ParseRequest{
+			Kind:    api.ParseRequest_UAST,
+			Name:    path,
+			Content: b,
+			Lang:    cmd.Lang,
+			Query:   cmd.Query,
+			Mode:    mode,
+		})
+		size += len(b)
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if !isStarted {
+		started()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not parse %d of %d files", failed, len(cmd.Args.Paths))
+	}
+
+	return nil
+}
+
+// parseFiles parses the files of the request with ParseBatch, or with a Parse
+// request for each one if the daemon is too old to support it. Like with
+// ParseBatch, the files that can't be parsed have an error in their result
+func parseFiles(ctx context.Context, c api.EngineClient, req *api.ParseBatchRequest) (*api.ParseBatchResponse, error) {
+	if daemon.Supports(api.FeatureParseBatch) {
+		return c.ParseBatch(ctx, req)
+	}
+
+	resp := &api.ParseBatchResponse{}
+	for _, f := range req.Files {
+		res, err := c.Parse(ctx, f)
+		switch status.Code(err) {
+		case codes.OK:
+			resp.Results = append(resp.Results, &api.ParseBatchResponse_Result{
+				Name: f.Name,
+				Lang: res.Lang,
+				Uast: res.Uast,
+			})
+		case codes.Canceled, codes.DeadlineExceeded, codes.Unavailable:
+			return nil, err
+		default:
+			resp.Results = append(resp.Results, &api.ParseBatchResponse_Result{
+				Name:  f.Name,
+				Error: status.Convert(err).Message(),
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+// printParsedFiles prints the results of a ParseBatch request, returning the
+// number of files that could not be parsed
+func printParsedFiles(w io.Writer, results []*api.ParseBatchResponse_Result) int {
+	var failed int
+	for _, r := range results {
+		if r.Error != "" {
+			log.Warningf("could not parse %s: %s", r.Name, r.Error)
+			failed++
+			continue
+		}
+
+		f := parsedFile{File: r.Name, Lang: r.Lang, UAST: []json.RawMessage{}}
+		for _, node := range r.Uast {
+			f.UAST = append(f.UAST, json.RawMessage(node))
+		}
+
+		b, err := json.MarshalIndent(f, "", "  ")
+		if err != nil {
+			log.Warningf("could not print the UAST of %s: %s", r.Name, err)
+			failed++
+			continue
+		}
+
+		fmt.Fprintln(w, string(b))
+	}
+
+	return failed
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintParsedFiles(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	failed := printParsedFiles(&buf, []*api.ParseBatchResponse_Result{
+		{Name: "a.go", Lang: "go", Uast: [][]byte{[]byte(`{"@type": "File"}`)}},
+		{Name: "b.go", Error: "could not parse"},
+		{Name: "c.go", Lang: "go"},
+	})
+
+	require.Equal(1, failed)
+	require.Equal(`{
+  "file": "a.go",
+  "lang": "go",
+  "uast": [
+    {
+      "@type": "File"
+    }
+  ]
+}
+{
+  "file": "c.go",
+  "lang": "go",
+  "uast": []
+}
+`, buf.String())
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/plugins"
+
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// manifests of the plugins found in the plugins directory, loaded by
+// Command.Init
+var installedPlugins []*plugins.Manifest
+
+// loadPlugins reads the plugin manifests and registers them as components.
+// Invalid manifests are skipped with a warning, so they don't break the rest
+// of the commands
+func loadPlugins() error {
+	dir, err := plugins.DefaultDir()
+	if err != nil {
+		return err
+	}
+
+	ms, errs := plugins.Load(dir)
+	for _, err := range errs {
+		log.Warningf("%s", err)
+	}
+
+	cmps := make([]components.Component, len(ms))
+	for i, m := range ms {
+		cmps[i] = m.Component()
+	}
+
+	installedPlugins = ms
+	components.SetPlugins(cmps)
+	return nil
+}
+
+func findPlugin(name string) (*plugins.Manifest, error) {
+	names := make([]string, len(installedPlugins))
+	for i, m := range installedPlugins {
+		if m.Name == name {
+			return m, nil
+		}
+
+		names[i] = m.Name
+	}
+
+	return nil, fmt.Errorf("%s is not a valid plugin. Plugin must be one of [%s]", name, strings.Join(names, ", "))
+}
+
+// pluginsCmd represents the plugins command
+type pluginsCmd struct {
+	cli.PlainCommand `name:"plugins" short-description:"Manage third-party services defined in $HOME/.srcd/plugins" long-description:"Manage third-party services defined by the YAML manifests in $HOME/.srcd/plugins"`
+}
+
+// pluginsListCmd represents the plugins list command
+type pluginsListCmd struct {
+	Command `name:"list" short-description:"List plugins" long-description:"List plugins"`
+}
+
+func (c *pluginsListCmd) Execute(args []string) error {
+	out := pluginsOutput{Plugins: []pluginOutput{}}
+	t := NewTable("%s", "%s", "%v", "%v", "%s")
+	t.Header("NAME", "IMAGE", "RUNNING", "PORT", "VERBS")
+	for _, m := range installedPlugins {
+		cmp := m.Component()
+
+		verbs := make([]string, len(m.Verbs))
+		for i, v := range m.Verbs {
+			verbs[i] = v.Name
+		}
+
+		running, runningErr := cmp.IsRunning()
+		ports, portsErr := cmp.GetPorts()
+
+		t.Row(
+			m.Name,
+			cmp.ImageWithVersion(),
+			boolFmt(running, runningErr),
+			publicPortsFmt(ports, portsErr),
+			strings.Join(verbs, ","),
+		)
+
+		out.Plugins = append(out.Plugins, pluginOutput{
+			Name:    m.Name,
+			Image:   cmp.ImageWithVersion(),
+			Running: optionalBool(running, runningErr),
+			Ports:   publicPorts(ports),
+			Verbs:   verbs,
+		})
+	}
+
+	return render(os.Stdout, out, t.Print)
+}
+
+// pluginsOutput is the schema of the srcd plugins list output. Running is
+// null if it could not be checked
+type pluginsOutput struct {
+	Plugins []pluginOutput `json:"plugins" yaml:"plugins"`
+}
+
+type pluginOutput struct {
+	Name    string   `json:"name" yaml:"name"`
+	Image   string   `json:"image" yaml:"image"`
+	Running *bool    `json:"running" yaml:"running"`
+	Ports   []int    `json:"ports" yaml:"ports"`
+	Verbs   []string `json:"verbs" yaml:"verbs"`
+}
+
+// pluginsStartCmd represents the plugins start command
+type pluginsStartCmd struct {
+	Command `name:"start" short-description:"Start a plugin" long-description:"Start a plugin, with the working directory of the daemon, and wait until it is healthy"`
+
+	Args struct {
+		Name pluginArg `positional-arg-name:"plugin" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *pluginsStartCmd) Execute(args []string) error {
+	m, err := findPlugin(string(c.Args.Name))
+	if err != nil {
+		return err
+	}
+
+	return startPlugin(m)
+}
+
+func startPlugin(m *plugins.Manifest) error {
+	workdir, err := daemon.Workdir()
+	if err != nil {
+		return humanizef(err, "could not get the daemon working directory")
+	}
+
+	started := logAfterTimeout("this is taking a while, if this is the first time you start this plugin, "+
+		"it might take a few more minutes while we install its image", 5*time.Second)
+	defer started()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if err := plugins.Start(ctx, m, workdir); err != nil {
+		return humanizef(err, "could not start plugin %s", m.Name)
+	}
+
+	return nil
+}
+
+// pluginsStopCmd represents the plugins stop command
+type pluginsStopCmd struct {
+	Command `name:"stop" short-description:"Stop a plugin" long-description:"Stop a plugin"`
+
+	Args struct {
+		Name pluginArg `positional-arg-name:"plugin" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *pluginsStopCmd) Execute(args []string) error {
+	m, err := findPlugin(string(c.Args.Name))
+	if err != nil {
+		return err
+	}
+
+	if err := plugins.Stop(m); err != nil {
+		return humanizef(err, "could not stop plugin %s", m.Name)
+	}
+
+	return nil
+}
+
+// pluginsRunCmd represents the plugins run command
+type pluginsRunCmd struct {
+	Command `name:"run" short-description:"Run a plugin verb" long-description:"Run a verb provided by a plugin, starting it if needed. Any extra argument is passed to the verb command"`
+
+	Args struct {
+		Name pluginArg `positional-arg-name:"plugin" required:"1"`
+		Verb string    `positional-arg-name:"verb" required:"1"`
+		Args []string  `positional-arg-name:"args"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *pluginsRunCmd) Execute(args []string) error {
+	m, err := findPlugin(string(c.Args.Name))
+	if err != nil {
+		return err
+	}
+
+	if m.Verb(c.Args.Verb) == nil {
+		verbs := make([]string, len(m.Verbs))
+		for i, v := range m.Verbs {
+			verbs[i] = v.Name
+		}
+
+		return fmt.Errorf("%s is not a valid verb. Verb must be one of [%s]", c.Args.Verb, strings.Join(verbs, ", "))
+	}
+
+	if err := startPlugin(m); err != nil {
+		return err
+	}
+
+	code, err := plugins.Run(context.Background(), m, c.Args.Verb, c.Args.Args, os.Stdout, os.Stderr)
+	if err != nil {
+		return humanizef(err, "could not run %s %s", m.Name, c.Args.Verb)
+	}
+
+	if code != 0 {
+		return fmt.Errorf("%s %s exited with status %d", m.Name, c.Args.Verb, code)
+	}
+
+	return nil
+}
+
+func init() {
+	c := rootCmd.AddCommand(&pluginsCmd{})
+	c.AddCommand(&pluginsListCmd{})
+	c.AddCommand(&pluginsStartCmd{})
+	c.AddCommand(&pluginsStopCmd{})
+	c.AddCommand(&pluginsRunCmd{})
+}
@@ -1,8 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
 	"github.com/src-d/engine/cmd/srcd/daemon"
 	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"gopkg.in/src-d/go-log.v1"
 )
 
 // pruneCmd represents the sql command
@@ -10,10 +19,24 @@ type pruneCmd struct {
 	Command `name:"prune" short-description:"Removes all resources used by engine" long-description:"Removes all resources used by engine"`
 
 	WithImages bool `long:"with-images" description:"remove docker images"`
+	OldImages  bool `long:"old-images" description:"only remove the images of the components older than the versions they use, keeping the containers and volumes"`
+	DryRun     bool `long:"dry-run" description:"list the resources that would be removed, and the disk space used by the volumes, without removing them"`
 }
 
 func (c *pruneCmd) Execute(args []string) error {
-	if err := components.Prune(c.WithImages); err != nil {
+	if c.OldImages {
+		if c.WithImages {
+			return fmt.Errorf("--old-images can't be used with --with-images")
+		}
+
+		return c.pruneOldImages()
+	}
+
+	if c.DryRun {
+		return c.dryRun()
+	}
+
+	if err := components.Prune(c.WithImages, false); err != nil {
 		return humanizef(err, "could not prune components")
 	}
 
@@ -24,6 +47,130 @@ func (c *pruneCmd) Execute(args []string) error {
 	return nil
 }
 
+func (c *pruneCmd) dryRun() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	res, err := components.ListResources(ctx, c.WithImages)
+	if err != nil {
+		return humanizef(err, "could not list the resources to prune")
+	}
+
+	// an external network is not removed
+	network := docker.NetworkName
+	if docker.IsExternalNetwork() {
+		network = ""
+	}
+
+	out := pruneOutput{
+		Containers: newContainersOutput(res.Containers, nil),
+		Volumes:    newVolumesOutput(res.Volumes),
+		Network:    network,
+		Images:     res.Images,
+	}
+
+	return render(os.Stdout, out, func(w io.Writer) error {
+		if err := printContainers(w, res.Containers, nil); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w)
+		if err := printVolumes(w, res.Volumes); err != nil {
+			return err
+		}
+
+		if network != "" {
+			fmt.Fprintf(w, "\nNETWORK NAME\n%s\n", network)
+		}
+
+		if !c.WithImages {
+			return nil
+		}
+
+		t := NewTable("%s")
+		t.Header("IMAGE")
+		for _, img := range res.Images {
+			t.Row(img)
+		}
+
+		fmt.Fprintln(w)
+		return t.Print(w)
+	})
+}
+
+// pruneOldImages removes the images superseded by an upgrade, see
+// components.OldImages, keeping the ones srcd components rollback uses
+func (c *pruneCmd) pruneOldImages() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	keep := make(map[string]string)
+	for _, cmp := range components.Upgradable() {
+		previous, err := daemon.PreviousVersion(cmp.Name)
+		if err != nil {
+			return humanizef(err, "could not read the previous version of %s", cmp.Image)
+		}
+
+		keep[cmp.Name] = previous
+	}
+
+	cmps := append([]components.Component{components.Daemon}, components.Upgradable()...)
+	imgs, err := components.OldImages(ctx, cmps, keep, 0)
+	if err != nil {
+		return humanizef(err, "could not list the old images")
+	}
+
+	if c.DryRun {
+		out := oldImagesOutput{Images: []oldImageOutput{}}
+		for _, img := range imgs {
+			out.Images = append(out.Images, oldImageOutput{Image: img.Image, Size: img.Size})
+		}
+
+		return render(os.Stdout, out, func(w io.Writer) error {
+			t := NewTable("%s", "%s")
+			t.Header("IMAGE", "SIZE")
+			for _, img := range imgs {
+				t.Row(img.Image, sizeFmt(img.Size))
+			}
+
+			return t.Print(w)
+		})
+	}
+
+	if len(imgs) == 0 {
+		log.Infof("there are no old images")
+		return nil
+	}
+
+	freed, err := components.RemoveImages(ctx, imgs)
+	if err != nil {
+		return humanizef(err, "could not remove the old images")
+	}
+
+	log.Infof("removed %d old images, reclaimed %s", len(imgs), sizeFmt(freed))
+	return nil
+}
+
+// oldImagesOutput is the schema of the srcd prune --old-images --dry-run
+// output. The size includes the layers shared with other images
+type oldImagesOutput struct {
+	Images []oldImageOutput `json:"images" yaml:"images"`
+}
+
+type oldImageOutput struct {
+	Image string `json:"image" yaml:"image"`
+	Size  int64  `json:"size" yaml:"size"`
+}
+
+// pruneOutput is the schema of the srcd prune --dry-run output. The images
+// are only listed with --with-images
+type pruneOutput struct {
+	Containers []containerOutput `json:"containers" yaml:"containers"`
+	Volumes    []volumeOutput    `json:"volumes" yaml:"volumes"`
+	Network    string            `json:"network" yaml:"network"`
+	Images     []string          `json:"images,omitempty" yaml:"images,omitempty"`
+}
+
 func init() {
 	rootCmd.AddCommand(&pruneCmd{})
 }
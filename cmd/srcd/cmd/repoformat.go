@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/src-d/engine/api"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// warnRepositoryFormat warns when the repositories of the working directory
+// look like they have another format than the one gitbase reads
+func warnRepositoryFormat(workdir, format string) {
+	if format == "" {
+		format = api.RepositoryFormatGit
+	}
+
+	detected := detectRepositoryFormat(workdir)
+	if detected == "" || detected == format {
+		return
+	}
+
+	log.Warningf("the repositories of %s look like %s ones, but gitbase reads %s ones. "+
+		"Use srcd init --format %s, or set components.gitbase.format in the config file",
+		workdir, detected, format, detected)
+}
+
+// detectRepositoryFormat returns the format of most of the repositories of
+// the working directory, or of the directory itself if it is a repository:
+// git for the ones with a working tree, bare or siva, for the siva files in
+// the directory or in its subdirectories, as bucketed by borges. It returns
+// an empty string if there are no repositories
+func detectRepositoryFormat(workdir string) string {
+	switch {
+	case isGitRepository(workdir):
+		return api.RepositoryFormatGit
+	case isBareRepository(workdir):
+		return api.RepositoryFormatBare
+	}
+
+	infos, err := ioutil.ReadDir(workdir)
+	if err != nil {
+		return ""
+	}
+
+	counts := make(map[string]int)
+	for _, info := range infos {
+		dir := filepath.Join(workdir, info.Name())
+		switch {
+		case isSivaFile(info):
+			counts[api.RepositoryFormatSiva]++
+		case !info.IsDir():
+		case isGitRepository(dir):
+			counts[api.RepositoryFormatGit]++
+		case isBareRepository(dir):
+			counts[api.RepositoryFormatBare]++
+		default:
+			// the siva files may be split in directories by their name
+			children, err := ioutil.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+
+			for _, child := range children {
+				if isSivaFile(child) {
+					counts[api.RepositoryFormatSiva]++
+				}
+			}
+		}
+	}
+
+	var format string
+	for _, f := range []string{api.RepositoryFormatGit, api.RepositoryFormatBare, api.RepositoryFormatSiva} {
+		if counts[f] > counts[format] {
+			format = f
+		}
+	}
+
+	return format
+}
+
+func isSivaFile(info os.FileInfo) bool {
+	return info.Mode().IsRegular() && strings.HasSuffix(info.Name(), ".siva")
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/engine/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRepositoryFormat(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-repoformat")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	mkdir := func(path ...string) string {
+		p := filepath.Join(append([]string{dir}, path...)...)
+		require.NoError(os.MkdirAll(p, 0755))
+		return p
+	}
+
+	write := func(path ...string) {
+		require.NoError(ioutil.WriteFile(filepath.Join(append([]string{dir}, path...)...), nil, 0644))
+	}
+
+	require.Equal("", detectRepositoryFormat(mkdir("empty")))
+
+	git := mkdir("git")
+	mkdir("git", "engine", ".git")
+	mkdir("git", "gitbase", ".git")
+	mkdir("git", "docs")
+	require.Equal(api.RepositoryFormatGit, detectRepositoryFormat(git))
+	require.Equal(api.RepositoryFormatGit, detectRepositoryFormat(filepath.Join(git, "engine")))
+
+	bare := mkdir("bare")
+	mkdir("bare", "engine.git", "objects")
+	write("bare", "engine.git", "HEAD")
+	require.Equal(api.RepositoryFormatBare, detectRepositoryFormat(bare))
+	require.Equal(api.RepositoryFormatBare, detectRepositoryFormat(filepath.Join(bare, "engine.git")))
+
+	siva := mkdir("siva")
+	write("siva", "a1b2.siva")
+	mkdir("siva", "c3")
+	write("siva", "c3", "c3d4.siva")
+	mkdir("siva", "engine", ".git")
+	require.Equal(api.RepositoryFormatSiva, detectRepositoryFormat(siva))
+}
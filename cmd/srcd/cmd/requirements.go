@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	units "github.com/docker/go-units"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// checkRequirements fails if the docker host doesn't have the CPUs, memory
+// or free disk space needed to start the components with the given container
+// names and their dependencies, see components.MinRequirements. The ones
+// already running are not counted. With force the problems are only logged
+func checkRequirements(names []string, force bool) error {
+	// the docker host of a remote daemon can't be inspected from here
+	if daemon.IsRemote() {
+		return nil
+	}
+
+	var need components.Requirements
+	var pending []string
+	for _, name := range components.WithDependencies(names...) {
+		req := components.MinRequirements(name)
+		if req == (components.Requirements{}) {
+			continue
+		}
+
+		running, err := docker.IsRunning(name, "")
+		if err != nil {
+			log.Debugf("could not check if %s is running: %s", name, err)
+		}
+
+		if running {
+			continue
+		}
+
+		need = need.Add(req)
+		pending = append(pending, name)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, _, err := docker.SystemInfo(ctx)
+	if err != nil {
+		log.Debugf("could not get the resources of the docker host: %s", err)
+		return nil
+	}
+
+	backend, err := docker.GetBackend(runtime.GOOS)
+	if err != nil {
+		log.Debugf("could not detect the docker backend: %s", err)
+	}
+
+	// the free disk space is only known with the native backend
+	have := components.Requirements{CPUs: info.NCPU, Memory: info.MemTotal, Disk: -1}
+	if backend == docker.BackendNative {
+		if free, err := dockerDiskFree(info); err == nil {
+			have.Disk = free
+		}
+	}
+
+	problems := requirementProblems(need, have, isDesktop(backend))
+	if len(problems) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("the docker host doesn't have enough resources to run %s:\n%s",
+		strings.Join(pending, ", "), strings.Join(problems, "\n"))
+	if force {
+		log.Warningf("%s\nstarting anyway, the components might be killed when they run out of memory", msg)
+		return nil
+	}
+
+	return fmt.Errorf("%s\nRun srcd components start --force %s to start them anyway",
+		msg, strings.Join(pending, " "))
+}
+
+// requirementProblems returns the guidance to fix each of the resources of
+// have below need. A negative have.Disk is not checked
+func requirementProblems(need, have components.Requirements, desktop bool) []string {
+	var problems []string
+	if have.CPUs < need.CPUs {
+		p := fmt.Sprintf("- they need %d CPUs, the docker host has %d.", need.CPUs, have.CPUs)
+		if desktop {
+			p += " Increase the CPUs of Docker Desktop in Settings > Resources."
+		}
+
+		problems = append(problems, p)
+	}
+
+	if have.Memory < need.Memory {
+		p := fmt.Sprintf("- they need %s of memory, the docker host has %s.",
+			units.BytesSize(float64(need.Memory)), units.BytesSize(float64(have.Memory)))
+		if desktop {
+			p += " Increase the memory of Docker Desktop in Settings > Resources."
+		} else {
+			p += " Stop other containers or use a host with more memory."
+		}
+
+		problems = append(problems, p)
+	}
+
+	if have.Disk >= 0 && have.Disk < need.Disk {
+		problems = append(problems, fmt.Sprintf(
+			"- they need %s of free disk space, the docker host has %s."+
+				" Free some space, e.g. removing unused images with docker image prune.",
+			units.HumanSize(float64(need.Disk)), units.HumanSize(float64(have.Disk))))
+	}
+
+	return problems
+}
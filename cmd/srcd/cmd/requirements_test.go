@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"testing"
+
+	units "github.com/docker/go-units"
+	"github.com/src-d/engine/components"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirementProblems(t *testing.T) {
+	assert := assert.New(t)
+
+	need := components.MinRequirements(components.Gitbase.Name).
+		Add(components.MinRequirements(components.Bblfshd.Name))
+
+	have := components.Requirements{CPUs: 4, Memory: 8 * units.GiB, Disk: 50 * units.GB}
+	assert.Empty(requirementProblems(need, have, false))
+
+	have = components.Requirements{CPUs: 1, Memory: 1 * units.GiB, Disk: 1 * units.GB}
+	problems := requirementProblems(need, have, true)
+	assert.Len(problems, 3)
+	assert.Contains(problems[0], "need 2 CPUs, the docker host has 1")
+	assert.Contains(problems[0], "Docker Desktop")
+	assert.Contains(problems[1], "need 2GiB of memory")
+	assert.Contains(problems[2], "docker image prune")
+
+	// the disk is not checked when the free space is unknown
+	have.Disk = -1
+	problems = requirementProblems(need, have, false)
+	assert.Len(problems, 2)
+	assert.NotContains(problems[1], "Docker Desktop")
+}
@@ -0,0 +1,705 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	retrievalSivaPath = "/siva"
+	retrievalJobsPath = "/jobs"
+	// retrievalDirLabel is the label of the borges container with the host
+	// directory of the siva files, so srcd retrieve status can print it
+	retrievalDirLabel = "srcd.retrieve.dir"
+	// retrievalCredentials are the user, password and database name of the
+	// broker and the database, which are only reachable from the srcd network
+	retrievalCredentials = "srcd"
+	// retrievalMentionsQueue is the queue rovers publishes the repositories
+	// it finds to, and the borges producer reads them from
+	retrievalMentionsQueue = "rovers"
+	// retrievalJobTimeout is the time the one-off borges and rovers commands
+	// are retried while the broker and the database start
+	retrievalJobTimeout = 5 * time.Minute
+)
+
+// retrieveCmd represents the retrieve command
+type retrieveCmd struct {
+	cli.PlainCommand `name:"retrieve" short-description:"Download repositories into siva files" long-description:"Run the src-d retrieval pipeline to download many repositories into rooted siva files that gitbase reads with srcd init --format siva.\n\nborges downloads the repositories queued with srcd retrieve add, or the ones of GitHub found by rovers with srcd retrieve start --discover. The queue and the state of the downloads are kept in RabbitMQ and PostgreSQL containers."`
+}
+
+// retrieveStartCmd represents the retrieve start command
+type retrieveStartCmd struct {
+	Command `name:"start" short-description:"Start the retrieval pipeline" long-description:"Start the queue, the database and borges, which writes the siva files of the queued repositories in the given directory. The files are split in directories by the first components.gitbase.siva_bucket characters of their name, so gitbase reads them as they are.\n\nWith --discover, rovers finds the repositories of GitHub, using retrieval.github_token of the config file or the GITHUB_TOKEN environment variable, and they are queued too."`
+
+	Discover bool `long:"discover" description:"queue the repositories of GitHub found by rovers"`
+
+	Args struct {
+		Dir string `positional-arg-name:"dir" required:"yes" description:"directory where the siva files are written"`
+	} `positional-args:"yes"`
+}
+
+func (c *retrieveStartCmd) Execute(args []string) error {
+	if daemon.IsRemote() {
+		return fmt.Errorf("srcd retrieve writes the siva files in this host, it can't be used with --host")
+	}
+
+	dir, err := filepath.Abs(c.Args.Dir)
+	if err != nil {
+		return humanizef(err, "could not get the directory of the siva files")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return humanizef(err, "could not create the directory of the siva files")
+	}
+
+	hostPath, err := docker.HostPath(dir, runtime.GOOS)
+	if err != nil {
+		return humanizef(err, "could not use %s for the siva files", dir)
+	}
+
+	conf := *config.File
+	conf.SetDefaults()
+	conf.SetDefaultUser(runtime.GOOS)
+
+	token := conf.RetrievalGithubToken()
+	if c.Discover && token == "" {
+		return fmt.Errorf("rovers needs a GitHub API token to discover the repositories, " +
+			"set retrieval.github_token in the config file or the GITHUB_TOKEN environment variable")
+	}
+
+	if current, err := retrievalDir(); err != nil {
+		return humanizef(err, "could not check the retrieval pipeline")
+	} else if current != "" && current != dir {
+		return fmt.Errorf("the siva files are already being written in %s, "+
+			"stop the retrieval pipeline first with srcd retrieve stop", current)
+	}
+
+	started := logAfterTimeoutWithSpinner("starting the retrieval pipeline, "+
+		"it might take a few minutes the first time while the images are installed",
+		3*time.Second, 0)
+	err = startRetrieval(&conf, dir, hostPath, c.Discover, token)
+	started()
+	if err != nil {
+		return humanizef(err, "could not start the retrieval pipeline")
+	}
+
+	status, err := newRetrievalStatus()
+	if err != nil {
+		return humanizef(err, "could not get the status of the retrieval pipeline")
+	}
+
+	return render(os.Stdout, status, func(w io.Writer) error {
+		if err := status.Print(w); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintln(w, "\nqueue repositories with: srcd retrieve add <url>...")
+		return err
+	})
+}
+
+// startRetrieval starts the containers of the retrieval pipeline that are not
+// running, creating the tables of the database first
+func startRetrieval(conf *api.Config, dir, hostPath string, discover bool, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Minute)
+	defer cancel()
+
+	if err := startRetrievalBroker(ctx); err != nil {
+		return err
+	}
+
+	if err := startRetrievalDB(ctx); err != nil {
+		return err
+	}
+
+	env := retrievalEnv()
+	var opts []docker.ConfigOption
+	if conf.InjectsProxy() {
+		opts = append(opts, docker.WithProxy(docker.ProxyFromEnvironment(),
+			components.RetrievalBroker.Name, components.RetrievalDB.Name))
+	}
+
+	log.Infof("creating the tables of borges")
+	if err := runRetrievalJob(ctx, components.Borges, []string{"borges", "init"}, withEnv(env)); err != nil {
+		return err
+	}
+
+	if discover {
+		roversEnv := append(retrievalRoversEnv(), "CONFIG_GITHUB_TOKEN="+token)
+
+		log.Infof("creating the tables of rovers")
+		if err := runRetrievalJob(ctx, components.Rovers, []string{"rovers", "initdb"}, withEnv(roversEnv)); err != nil {
+			return err
+		}
+
+		err := startRetrievalService(ctx, conf, components.Rovers,
+			[]string{"rovers", "repos", "--providers=github"},
+			append(opts, withEnv(roversEnv)))
+		if err != nil {
+			return err
+		}
+
+		err = startRetrievalService(ctx, conf, components.BorgesProducer,
+			[]string{"borges", "producer", "mentions", "--queue=" + retrievalMentionsQueue},
+			append(opts, withEnv(env)))
+		if err != nil {
+			return err
+		}
+	}
+
+	consumer := append(opts,
+		withEnv(env),
+		withEnv([]string{
+			"CONFIG_ROOT_REPOSITORIES_DIR=" + retrievalSivaPath,
+			"CONFIG_TEMP_DIR=" + retrievalSivaPath + "/.tmp",
+			fmt.Sprintf("CONFIG_BUCKETSIZE=%d", conf.Components.Gitbase.SivaBucket),
+		}),
+		docker.WithSharedDirectory(hostPath, retrievalSivaPath, runtime.GOOS),
+		docker.WithLabel(retrievalDirLabel, dir),
+	)
+	if conf.Security.User != "" {
+		consumer = append(consumer, docker.WithUser(conf.Security.User))
+	}
+
+	return startRetrievalService(ctx, conf, components.Borges,
+		[]string{"borges", "consumer", fmt.Sprintf("--workers=%d", conf.Retrieval.Workers)},
+		consumer)
+}
+
+// retrievalEnv returns the environment variables of borges with the
+// addresses and credentials of the broker and the database
+func retrievalEnv() []string {
+	return []string{
+		"CONFIG_DBUSER=" + retrievalCredentials,
+		"CONFIG_DBPASS=" + retrievalCredentials,
+		"CONFIG_DBHOST=" + components.RetrievalDB.Name,
+		"CONFIG_DBPORT=5432",
+		"CONFIG_DBNAME=" + retrievalCredentials,
+		"CONFIG_DBSSLMODE=disable",
+		"CONFIG_BROKER=" + retrievalBrokerURL(),
+	}
+}
+
+// retrievalRoversEnv returns the environment variables of rovers, like
+// retrievalEnv
+func retrievalRoversEnv() []string {
+	return []string{
+		"CONFIG_DBUSER=" + retrievalCredentials,
+		"CONFIG_DBPASS=" + retrievalCredentials,
+		"CONFIG_DBHOST=" + components.RetrievalDB.Name,
+		"CONFIG_DBPORT=5432",
+		"CONFIG_DBNAME=" + retrievalCredentials,
+		"CONFIG_DBSSLMODE=disable",
+		"CONFIG_BROKER_URL=" + retrievalBrokerURL(),
+		"CONFIG_BROKER_QUEUE=" + retrievalMentionsQueue,
+	}
+}
+
+func retrievalBrokerURL() string {
+	return fmt.Sprintf("amqp://%s:%s@%s:5672/",
+		retrievalCredentials, retrievalCredentials, components.RetrievalBroker.Name)
+}
+
+func withEnv(env []string) docker.ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		cfg.Env = append(cfg.Env, env...)
+	}
+}
+
+// startRetrievalBroker starts RabbitMQ, keeping the queues in a volume. Its
+// hostname is fixed, as RabbitMQ names its data after it
+func startRetrievalBroker(ctx context.Context) error {
+	cmp := components.RetrievalBroker
+	volume := components.Prefix() + "retrieval-broker"
+	_, err := docker.InfoOrStart(ctx, cmp.Name, func(ctx context.Context) error {
+		if err := docker.CreateVolume(ctx, volume); err != nil {
+			return err
+		}
+
+		config := &container.Config{
+			Image:    cmp.ImageWithVersion(),
+			Hostname: cmp.Name,
+			Env: []string{
+				"RABBITMQ_DEFAULT_USER=" + retrievalCredentials,
+				"RABBITMQ_DEFAULT_PASS=" + retrievalCredentials,
+			},
+		}
+
+		return startRetrievalContainer(ctx, cmp, config,
+			docker.WithVolume(volume, "/var/lib/rabbitmq", runtime.GOOS))
+	})
+
+	return err
+}
+
+// startRetrievalDB starts PostgreSQL, keeping its data in a volume
+func startRetrievalDB(ctx context.Context) error {
+	cmp := components.RetrievalDB
+	volume := components.Prefix() + "retrieval-db"
+	_, err := docker.InfoOrStart(ctx, cmp.Name, func(ctx context.Context) error {
+		if err := docker.CreateVolume(ctx, volume); err != nil {
+			return err
+		}
+
+		config := &container.Config{
+			Image: cmp.ImageWithVersion(),
+			Env: []string{
+				"POSTGRES_USER=" + retrievalCredentials,
+				"POSTGRES_PASSWORD=" + retrievalCredentials,
+				"POSTGRES_DB=" + retrievalCredentials,
+			},
+		}
+
+		return startRetrievalContainer(ctx, cmp, config,
+			docker.WithVolume(volume, "/var/lib/postgresql/data", runtime.GOOS))
+	})
+
+	return err
+}
+
+// startRetrievalService starts a long running borges or rovers command. It
+// is restarted by docker when it fails, like when the broker is restarted,
+// unless daemon.restart_policy sets another policy
+func startRetrievalService(
+	ctx context.Context,
+	conf *api.Config,
+	cmp components.Component,
+	args []string,
+	opts []docker.ConfigOption,
+) error {
+	policy, err := conf.RestartPolicy()
+	if err != nil {
+		return err
+	}
+
+	if policy.Name == "" {
+		policy = container.RestartPolicy{Name: "on-failure"}
+	}
+
+	_, err = docker.InfoOrStart(ctx, cmp.Name, func(ctx context.Context) error {
+		config := &container.Config{
+			Image:      cmp.ImageWithVersion(),
+			Entrypoint: args[:1],
+			Cmd:        args[1:],
+		}
+
+		return startRetrievalContainer(ctx, cmp, config,
+			append(opts, docker.WithRestartPolicy(policy))...)
+	})
+
+	return err
+}
+
+func startRetrievalContainer(
+	ctx context.Context,
+	cmp components.Component,
+	config *container.Config,
+	opts ...docker.ConfigOption,
+) error {
+	if err := docker.EnsureInstalled(cmp.Image, cmp.Version); err != nil {
+		return err
+	}
+
+	host := &container.HostConfig{}
+	docker.ApplyOptions(config, host, opts...)
+
+	log.Infof("starting %s", cmp.Name)
+	return docker.Start(ctx, config, host, cmp.Name)
+}
+
+// runRetrievalJob runs a one-off borges or rovers command in a container that
+// is removed when it exits. It is retried until it succeeds or
+// retrievalJobTimeout passes, as the broker and the database take a while to
+// accept connections after they start
+func runRetrievalJob(
+	ctx context.Context,
+	cmp components.Component,
+	args []string,
+	opts ...docker.ConfigOption,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, retrievalJobTimeout)
+	defer cancel()
+
+	if err := docker.EnsureInstalled(cmp.Image, cmp.Version); err != nil {
+		return err
+	}
+
+	name := cmp.Name + "-job"
+	for {
+		err := runContainer(ctx, name, &container.Config{
+			Image:      cmp.ImageWithVersion(),
+			Entrypoint: args[:1],
+			Cmd:        args[1:],
+		}, opts...)
+		if err == nil {
+			return nil
+		}
+
+		log.Debugf("%s failed, retrying: %s", strings.Join(args, " "), err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "%s failed", strings.Join(args, " "))
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// runContainer runs a container until it exits and removes it. It returns
+// an error with the last line of its output if the exit code is not 0
+func runContainer(ctx context.Context, name string, config *container.Config, opts ...docker.ConfigOption) error {
+	host := &container.HostConfig{}
+	docker.ApplyOptions(config, host, opts...)
+
+	if err := docker.Start(ctx, config, host, name); err != nil {
+		return err
+	}
+	defer docker.RemoveContainer(name)
+
+	var out bytes.Buffer
+	if err := docker.CopyLogs(ctx, name, &out); err != nil {
+		return err
+	}
+
+	st, err := docker.InspectExit(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if st.ExitCode != 0 {
+		return fmt.Errorf("exit code %d: %s", st.ExitCode, lastLine(out.String()))
+	}
+
+	return nil
+}
+
+// lastLine returns the last line of the output of a command that is not
+// empty
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// retrievalDir returns the directory of the siva files of the borges
+// container, or an empty string if there is none
+func retrievalDir() (string, error) {
+	info, err := docker.Info(components.Borges.Name)
+	if err == docker.ErrNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return info.Labels[retrievalDirLabel], nil
+}
+
+// retrieveAddCmd represents the retrieve add command
+type retrieveAddCmd struct {
+	Command `name:"add" short-description:"Queue repositories to download" long-description:"Queue the repositories with the given URLs, or the ones listed in a file, one per line, to be downloaded by borges. The empty lines and the ones starting with # are skipped.\n\nThe retrieval pipeline must be started with srcd retrieve start."`
+
+	File string `short:"f" long:"file" description:"file with the URLs of the repositories, one per line"`
+
+	Args struct {
+		URLs []string `positional-arg-name:"url" description:"URL of a repository, like https://github.com/src-d/engine"`
+	} `positional-args:"yes"`
+}
+
+func (c *retrieveAddCmd) Execute(args []string) error {
+	if daemon.IsRemote() {
+		return fmt.Errorf("srcd retrieve writes the siva files in this host, it can't be used with --host")
+	}
+
+	urls := c.Args.URLs
+	if c.File != "" {
+		f, err := os.Open(c.File)
+		if err != nil {
+			return humanizef(err, "could not read the list of repositories")
+		}
+		defer f.Close()
+
+		listed, err := readRepositoryURLs(f)
+		if err != nil {
+			return humanizef(err, "could not read the list of repositories")
+		}
+
+		urls = append(urls, listed...)
+	}
+
+	if len(urls) == 0 {
+		return fmt.Errorf("no repositories given, pass their URLs or a file with --file")
+	}
+
+	for _, u := range urls {
+		if err := validateRepositoryURL(u); err != nil {
+			return err
+		}
+	}
+
+	dir, err := retrievalDir()
+	if err != nil {
+		return humanizef(err, "could not check the retrieval pipeline")
+	}
+
+	if dir == "" {
+		return fmt.Errorf("the retrieval pipeline is not running, start it first with srcd retrieve start <dir>")
+	}
+
+	// the list is written in $HOME/.srcd, as it is shared with the
+	// docker VM on every system
+	root, err := config.RootDir()
+	if err != nil {
+		return humanizef(err, "could not find the config directory")
+	}
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return humanizef(err, "could not create the config directory")
+	}
+
+	tmp, err := ioutil.TempDir(root, "retrieve")
+	if err != nil {
+		return humanizef(err, "could not write the list of repositories")
+	}
+	defer os.RemoveAll(tmp)
+
+	content := strings.Join(urls, "\n") + "\n"
+	if err := ioutil.WriteFile(filepath.Join(tmp, "repositories.txt"), []byte(content), 0644); err != nil {
+		return humanizef(err, "could not write the list of repositories")
+	}
+
+	hostPath, err := docker.HostPath(tmp, runtime.GOOS)
+	if err != nil {
+		return humanizef(err, "could not use %s for the list of repositories", tmp)
+	}
+
+	err = runRetrievalJob(context.Background(), components.Borges,
+		[]string{"borges", "producer", "file", retrievalJobsPath + "/repositories.txt"},
+		withEnv(retrievalEnv()),
+		docker.WithROSharedDirectory(hostPath, retrievalJobsPath, runtime.GOOS),
+	)
+	if err != nil {
+		return humanizef(err, "could not queue the repositories")
+	}
+
+	if isTextOutput() {
+		fmt.Printf("%d repositories queued, their siva files are written in %s\n", len(urls), dir)
+	}
+
+	return nil
+}
+
+// readRepositoryURLs returns the URLs of a list of repositories, one per
+// line, skipping the empty lines and the comments
+func readRepositoryURLs(r io.Reader) ([]string, error) {
+	var urls []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		urls = append(urls, line)
+	}
+
+	return urls, s.Err()
+}
+
+// validateRepositoryURL checks that a URL can be cloned by borges: an http,
+// https, git or ssh URL, or an scp-like one, like git@github.com:src-d/engine
+func validateRepositoryURL(u string) error {
+	for _, scheme := range []string{"https://", "http://", "git://", "ssh://"} {
+		if strings.HasPrefix(u, scheme) && len(u) > len(scheme) {
+			return nil
+		}
+	}
+
+	if at := strings.Index(u, "@"); at > 0 && strings.Index(u[at:], ":") > 1 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid repository URL %q, it must be like https://github.com/src-d/engine", u)
+}
+
+// retrieveStatusCmd represents the retrieve status command
+type retrieveStatusCmd struct {
+	Command `name:"status" short-description:"Show the state of the retrieval pipeline" long-description:"Show the state of the containers of the retrieval pipeline, and the number and size of the siva files written"`
+}
+
+func (c *retrieveStatusCmd) Execute(args []string) error {
+	status, err := newRetrievalStatus()
+	if err != nil {
+		return humanizef(err, "could not get the status of the retrieval pipeline")
+	}
+
+	return render(os.Stdout, status, status.Print)
+}
+
+// retrievalComponents are the components of the retrieval pipeline, in the
+// order they are started
+func retrievalComponents() []components.Component {
+	return components.StartOrder([]components.Component{
+		components.Borges,
+		components.BorgesProducer,
+		components.Rovers,
+		components.RetrievalBroker,
+		components.RetrievalDB,
+	})
+}
+
+// retrievalStatus is the output of srcd retrieve status
+type retrievalStatus struct {
+	// Dir is the directory of the siva files, empty if borges is not
+	// running
+	Dir        string               `json:"dir" yaml:"dir"`
+	Containers []retrievalContainer `json:"containers" yaml:"containers"`
+	SivaFiles  int                  `json:"siva_files" yaml:"siva_files"`
+	Size       int64                `json:"size" yaml:"size"`
+}
+
+type retrievalContainer struct {
+	Name string `json:"name" yaml:"name"`
+	// State is the docker state of the container, or not_created
+	State string `json:"state" yaml:"state"`
+}
+
+func newRetrievalStatus() (*retrievalStatus, error) {
+	dir, err := retrievalDir()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &retrievalStatus{Dir: dir, Containers: []retrievalContainer{}}
+	for _, cmp := range retrievalComponents() {
+		state := "not_created"
+		info, err := docker.Info(cmp.Name)
+		if err == nil {
+			state = info.State
+		} else if err != docker.ErrNotFound {
+			return nil, err
+		}
+
+		status.Containers = append(status.Containers, retrievalContainer{Name: cmp.Name, State: state})
+	}
+
+	if dir != "" {
+		status.SivaFiles, status.Size, err = sivaFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+func (s *retrievalStatus) Print(w io.Writer) error {
+	if s.Dir == "" {
+		fmt.Fprintln(w, "borges is not running, start it with srcd retrieve start <dir>")
+	} else {
+		fmt.Fprintf(w, "%d siva files (%s) in %s\n",
+			s.SivaFiles, units.BytesSize(float64(s.Size)), s.Dir)
+	}
+
+	fmt.Fprintln(w)
+	table := NewTable("%s", "%s")
+	table.Header("CONTAINER", "STATE")
+	for _, c := range s.Containers {
+		table.Row(c.Name, c.State)
+	}
+
+	if err := table.Print(w); err != nil {
+		return err
+	}
+
+	if s.SivaFiles > 0 {
+		_, err := fmt.Fprintf(w, "\nanalyze them with: srcd init --format siva %s\n", s.Dir)
+		return err
+	}
+
+	return nil
+}
+
+// sivaFiles returns the number and size of the siva files in the directory,
+// or in its subdirectories, where borges writes them when they are split in
+// buckets
+func sivaFiles(dir string) (int, int64, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var count int
+	var size int64
+	for _, info := range infos {
+		if isSivaFile(info) {
+			count++
+			size += info.Size()
+			continue
+		}
+
+		if !info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+
+		children, err := ioutil.ReadDir(filepath.Join(dir, info.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, child := range children {
+			if isSivaFile(child) {
+				count++
+				size += child.Size()
+			}
+		}
+	}
+
+	return count, size, nil
+}
+
+// retrieveStopCmd represents the retrieve stop command
+type retrieveStopCmd struct {
+	Command `name:"stop" short-description:"Stop the retrieval pipeline" long-description:"Stop and remove the containers of the retrieval pipeline. The queue and the database are kept in their volumes, so the pending repositories are downloaded when it is started again. srcd prune removes them."`
+}
+
+func (c *retrieveStopCmd) Execute(args []string) error {
+	if err := components.StopComponents(retrievalComponents(), components.ComponentGracePeriod, false); err != nil {
+		return humanizef(err, "could not stop the retrieval pipeline")
+	}
+
+	if isTextOutput() {
+		fmt.Println("retrieval pipeline stopped")
+	}
+
+	return nil
+}
+
+func init() {
+	c := rootCmd.AddCommand(&retrieveCmd{})
+	c.AddCommand(&retrieveStartCmd{})
+	c.AddCommand(&retrieveAddCmd{})
+	c.AddCommand(&retrieveStatusCmd{})
+	c.AddCommand(&retrieveStopCmd{})
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRepositoryURLs(t *testing.T) {
+	require := require.New(t)
+
+	urls, err := readRepositoryURLs(strings.NewReader(`# src-d
+https://github.com/src-d/engine
+
+  git@github.com:src-d/gitbase.git  
+`))
+	require.NoError(err)
+	require.Equal([]string{
+		"https://github.com/src-d/engine",
+		"git@github.com:src-d/gitbase.git",
+	}, urls)
+}
+
+func TestValidateRepositoryURL(t *testing.T) {
+	require := require.New(t)
+
+	for _, u := range []string{
+		"https://github.com/src-d/engine",
+		"http://example.com/repo.git",
+		"git://github.com/src-d/engine.git",
+		"ssh://git@github.com/src-d/engine.git",
+		"git@github.com:src-d/engine.git",
+	} {
+		require.NoError(validateRepositoryURL(u), u)
+	}
+
+	for _, u := range []string{"", "https://", "github.com/src-d/engine", "/repos/engine", "git@github.com"} {
+		require.Error(validateRepositoryURL(u), u)
+	}
+}
+
+func TestSivaFiles(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-retrieve")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(os.MkdirAll(filepath.Join(dir, "a1"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(dir, ".tmp"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "b2c3.siva"), make([]byte, 10), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "a1", "a1d4.siva"), make([]byte, 20), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, ".tmp", "e5f6.siva"), make([]byte, 40), 0644))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "README.md"), nil, 0644))
+
+	count, size, err := sivaFiles(dir)
+	require.NoError(err)
+	require.Equal(2, count)
+	require.Equal(int64(30), size)
+}
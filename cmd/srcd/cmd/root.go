@@ -16,12 +16,19 @@ package cmd
 
 import (
 	"bufio"
-	"context"
-	"regexp"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
 	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"github.com/src-d/engine/logging"
 
+	flags "github.com/jessevdk/go-flags"
 	"gopkg.in/src-d/go-cli.v0"
 	"gopkg.in/src-d/go-log.v1"
 )
@@ -34,16 +41,169 @@ type Command struct {
 	cli.PlainCommand
 	cli.LogOptions `group:"Log Options"`
 
-	Config string `long:"config" description:"config file (default: $HOME/.srcd/config.yml)"`
+	Config string `long:"config" description:"config file (default: $HOME/.srcd/config.yml, or $HOME/.srcd/profiles/<profile>/config.yml)"`
+	Host   string `long:"host" env:"SRCD_HOST" description:"address of a remote daemon to use instead of the local one, in the form host[:port]"`
+	Token  string `long:"token" env:"SRCD_TOKEN" description:"token of the user of the daemon, required when it has users in daemon.auth.users"`
+}
+
+// globalOptions are the options of the root command, which can be given
+// before the command name too, e.g. srcd --profile work init
+var globalOptions struct {
+	Profile  profileArg `long:"profile" env:"SRCD_PROFILE" description:"name of an independent engine stack, with its own containers, volumes, network and config"`
+	NoDaemon bool       `long:"no-daemon" env:"SRCD_NO_DAEMON" description:"run the engine in the srcd process instead of the daemon container"`
+	// ConfigProfile only changes the config values, the stack is the one of
+	// Profile
+	ConfigProfile string `long:"config-profile" env:"SRCD_CONFIG_PROFILE" description:"name of a profile in the profiles key of the config file, whose values override the rest of the file"`
+	// AutoUpgrade upgrades the local daemon without asking, see
+	// confirmDaemonUpgrade
+	AutoUpgrade bool   `long:"auto-upgrade" env:"SRCD_AUTO_UPGRADE" description:"upgrade the local daemon without asking when it is too old for srcd"`
+	Output      string `long:"output" env:"SRCD_OUTPUT" choice:"text" choice:"json" choice:"yaml" default:"text" description:"format of the command output, json and yaml are meant for scripts"`
+	Trace       bool   `long:"trace" env:"SRCD_TRACE" description:"record a trace of the command, the daemon calls and the docker operations, see tracing in the config file"`
+	// Verbose is given without a value as -v, or --verbose=docker
+	Verbose   []string `short:"v" long:"verbose" env:"SRCD_VERBOSE" env-delim:"," optional:"yes" optional-value:"all" description:"log level of a subsystem, in the form subsystem[:level], where the subsystem is docker, daemon, registry or all, and the level is debug by default, can be repeated"`
+	LogFormat string   `long:"log-format" env:"SRCD_LOG_FORMAT" choice:"text" choice:"json" description:"log format, defaults to text on a terminal and json otherwise"`
+	// NoProjectConfig ignores the .srcd.yml files of the repositories
+	NoProjectConfig bool `long:"no-project-config" env:"SRCD_NO_PROJECT_CONFIG" description:"ignore the project config file, .srcd.yml in the current directory or its parents"`
+}
+
+// Init implements the cli.Initializer interface.
+func (c Command) Init(a *cli.App) error {
+	if err := c.initLogging(a); err != nil {
+		return err
+	}
+
+	daemon.SetHost(c.Host)
+	daemon.SetToken(c.Token)
+	daemon.SetNoDaemon(globalOptions.NoDaemon)
+	daemon.SetUpgradeConfirm(confirmDaemonUpgrade)
+
+	// the profile must be set before the config is read, as it is per profile
+	if err := components.SetProfile(string(globalOptions.Profile)); err != nil {
+		return err
+	}
+
+	components.SetStateDir(config.Dir)
+	config.SetConfigProfile(globalOptions.ConfigProfile)
+
+	if err := c.readConfig(); err != nil {
+		return err
+	}
+
+	if globalOptions.Trace {
+		return startTracing(activeCommand())
+	}
+
+	return nil
+}
+
+// confirmDaemonUpgrade returns whether the local daemon, too old for srcd,
+// must be upgraded. It is asked in a terminal, unless --auto-upgrade is used
+func confirmDaemonUpgrade(daemonVersion string) bool {
+	if globalOptions.AutoUpgrade {
+		return true
+	}
+
+	if !isInteractive() {
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "The daemon %s is too old for srcd %s. Upgrade it now, keeping "+
+		"its working directory, config and running components? [y/N]: ", daemonVersion, version)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	fmt.Fprintln(os.Stderr)
+
+	return answer == "y" || answer == "yes"
+}
+
+// readConfig reads the config file into config.File, and applies its docker
+// settings, the planned shards, the upgraded versions and the plugins
+func (c Command) readConfig() error {
+	// the config is also passed to the daemon when it is started by any command
+	config.SetProjectDisabled(globalOptions.NoProjectConfig)
+	if err := config.Read(c.Config); err != nil {
+		return err
+	}
+
+	if err := docker.SetSignatureKeys(config.File.SignatureKeys()); err != nil {
+		return err
+	}
+
+	if err := docker.SetNetwork(config.File.DockerNetwork()); err != nil {
+		return err
+	}
+
+	if err := docker.SetProxy(config.File.DockerProxy()); err != nil {
+		return err
+	}
+
+	// the shards set in the config file take precedence over the planned ones
+	if len(config.File.Components.Gitbase.Shards) == 0 {
+		shards, err := config.ReadShards()
+		if err != nil {
+			return err
+		}
+
+		config.File.Components.Gitbase.Shards = shards
+	}
+
+	if err := config.File.Validate(); err != nil {
+		return err
+	}
+
+	versions, err := config.ReadVersions()
+	if err != nil {
+		return err
+	}
+
+	components.SetVersions(versions)
+	return loadPlugins()
+}
+
+// initLogging sets the log options of the command and the global ones
+func (c Command) initLogging(a *cli.App) error {
+	levels, err := logging.ParseLevels(globalOptions.Verbose)
+	if err != nil {
+		return err
+	}
+
+	// the log flags of the command take precedence over the global ones
+	if level, ok := levels[logging.All]; ok && c.LogLevel == log.InfoLevel {
+		c.LogLevel = level
+	}
+
+	if c.LogFormat == "" {
+		c.LogFormat = globalOptions.LogFormat
+	}
+
+	if err := c.LogOptions.Init(a); err != nil {
+		return err
+	}
+
+	return logging.Configure(log.DefaultFactory, levels)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	rootCmd.RunMain()
-}
+	if _, err := rootCmd.Parser.AddGroup("Global Options", "", &globalOptions); err != nil {
+		panic(err)
+	}
 
-var logMsgRegex = regexp.MustCompile(`.*msg="(.+)"`)
+	handler := rootCmd.Parser.CommandHandler
+	rootCmd.Parser.CommandHandler = func(cmd flags.Commander, args []string) error {
+		return withTelemetry(cmd, func() error {
+			err := handler(cmd, args)
+			finishTracing(err)
+			return err
+		})
+	}
+
+	// the error is already printed by the parser
+	if err := rootCmd.Run(os.Args); err != nil {
+		os.Exit(exitCode(err))
+	}
+}
 
 func logAfterTimeout(msg string, timeout time.Duration) func() {
 	d := newDefered(timeout, msg, nil, false, 0)
@@ -55,54 +215,20 @@ func logAfterTimeoutWithSpinner(msg string, timeout time.Duration, spinnerInterv
 	return d.Print()
 }
 
-func logAfterTimeoutWithServerLogs(msg string, timeout time.Duration) func() {
-	d := newDefered(timeout, msg, readDaemonLogs, false, 0)
-	return d.Print()
-}
-
-func readDaemonLogs(stop <-chan bool) <-chan string {
-	logs, err := daemon.GetLogs()
-	if err != nil {
-		log.Errorf(err, "could not get logs from server container")
-		return nil
+// logAfterTimeoutWithEvents prints the message after the timeout, followed by
+// the events of the daemon, such as the images being pulled, until the
+// returned function is called
+func logAfterTimeoutWithEvents(client api.EngineClient, msg string, timeout time.Duration) func() {
+	// the components logs of the daemonless mode are already printed
+	if daemon.IsDaemonless() {
+		return logAfterTimeout(msg, timeout)
 	}
 
-	ch := make(chan string)
-	go func() {
-		defer logs.Close()
-		scanner := bufio.NewScanner(logs)
-
-		c := make(chan bool)
-		scan := func() {
-			c <- scanner.Scan()
-		}
-
-		go scan()
-		for {
-			select {
-			case <-stop:
-				close(ch)
-				return
-			case more := <-c:
-				if !more {
-					close(ch)
-					if err := scanner.Err(); err != nil && err != context.Canceled {
-						log.Errorf(err, "can't read logs from server")
-					}
-
-					return
-				}
-
-				match := logMsgRegex.FindStringSubmatch(scanner.Text())
-				if len(match) == 2 {
-					ch <- match[1]
-				}
-
-				go scan()
-			}
-		}
-
-	}()
-
-	return ch
+	events, unsubscribe := subscribeEvents(client)
+	d := newDefered(timeout, msg, events, false, 0)
+	done := d.Print()
+	return func() {
+		done()
+		unsubscribe()
+	}
 }
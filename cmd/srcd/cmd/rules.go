@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/report"
+
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// rulesCmd represents the rules command
+type rulesCmd struct {
+	cli.PlainCommand `name:"rules" short-description:"Check the files of the working directory with UAST rules" long-description:"Check the files of the working directory with UAST rules"`
+}
+
+// rulesRunCmd represents the rules run command
+type rulesRunCmd struct {
+	Command `name:"run" short-description:"Run the rules of a file over the UASTs of the working directory" long-description:"Parse the files of the working directory and run the XPath queries of the rules file over their UASTs, printing a finding for each node matched.\n\nIt fails if there is any finding with error severity."`
+
+	Rules     string `short:"r" long:"rules" description:"rules file (default: rules.file in the config files, or .srcd-rules.yml in the working directory)"`
+	Workers   int    `short:"j" long:"workers" description:"number of files parsed in parallel (default: the number of CPUs)"`
+	FilesFrom string `long:"files-from" description:"only check the files listed in this file, one for each line, relative to the working directory, or - for the standard input"`
+	Range     string `long:"range" description:"only check the files changed by the commits of this range, like HEAD~10..HEAD, of the repositories of the working directory"`
+	SARIF     string `long:"sarif" description:"write a SARIF report of the findings to this path"`
+
+	Args struct {
+		Workdir string `positional-arg-name:"workdir"`
+	} `positional-args:"yes"`
+}
+
+// ruleFinding is a node matched by a rule in the srcd rules run output
+type ruleFinding struct {
+	Rule     string          `json:"rule" yaml:"rule"`
+	Severity report.Severity `json:"severity" yaml:"severity"`
+	Message  string          `json:"message" yaml:"message"`
+	File     string          `json:"file" yaml:"file"`
+	Line     int             `json:"line,omitempty" yaml:"line,omitempty"`
+	Column   int             `json:"column,omitempty" yaml:"column,omitempty"`
+}
+
+// rulesOutput is the schema of the srcd rules run output
+type rulesOutput struct {
+	Findings []ruleFinding `json:"findings" yaml:"findings"`
+	// Files is the number of files checked, and Errors the number of them
+	// that could not be parsed
+	Files  int `json:"files" yaml:"files"`
+	Errors int `json:"errors" yaml:"errors"`
+}
+
+func (c *rulesRunCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected only one path")
+	}
+
+	workdir, err := workdirArg(c.Args.Workdir)
+	if err != nil {
+		return err
+	}
+
+	path := c.Rules
+	if path == "" {
+		path = config.File.Rules.File
+	}
+
+	if path == "" {
+		path = filepath.Join(workdir, rulesFile)
+	}
+
+	rules, err := loadRuleSet(path)
+	if err != nil {
+		return err
+	}
+
+	targets, err := c.targets(rules, workdir)
+	if err != nil {
+		return err
+	}
+
+	// the daemon is not started when there is nothing to check, e.g. in a git
+	// hook for a commit without source files
+	out := &rulesOutput{Findings: []ruleFinding{}}
+	if len(targets) > 0 {
+		client, err := daemon.Client()
+		if err != nil {
+			return humanizef(err, "could not get daemon client")
+		}
+
+		workers := c.Workers
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+
+		start := time.Now()
+		out = runRules(client, workdir, targets, workers)
+		log.Debugf("checked %d files in %s", out.Files, time.Since(start))
+	}
+
+	if c.SARIF != "" {
+		if err := report.WriteFile(c.SARIF, report.SARIF, rulesReport(rules, out)); err != nil {
+			return humanizef(err, "could not write the SARIF report")
+		}
+	}
+
+	if err := render(os.Stdout, out, out.Print); err != nil {
+		return err
+	}
+
+	var n int
+	for _, f := range out.Findings {
+		if f.Severity == report.SeverityError {
+			n++
+		}
+	}
+
+	if n > 0 {
+		return fmt.Errorf("there are %d findings with error severity", n)
+	}
+
+	return nil
+}
+
+// targets returns the files of the working directory checked by the rules:
+// those of --files-from or changed in --range, or all of them
+func (c *rulesRunCmd) targets(rules *ruleSet, workdir string) ([]ruleTarget, error) {
+	if c.FilesFrom != "" && c.Range != "" {
+		return nil, fmt.Errorf("--files-from and --range can't be used together")
+	}
+
+	var paths []string
+	switch {
+	case c.FilesFrom != "":
+		var err error
+		if paths, err = readFileList(c.FilesFrom); err != nil {
+			return nil, humanizef(err, "could not read the list of files")
+		}
+	case c.Range != "":
+		r, err := resolveRange(workdir, c.Range)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = r.Files
+	default:
+		targets, err := rules.ruleTargets(workdir)
+		if err != nil {
+			return nil, humanizef(err, "could not read the working directory")
+		}
+
+		return targets, nil
+	}
+
+	targets, err := rules.fileTargets(workdir, paths)
+	if err != nil {
+		return nil, humanizef(err, "could not read the working directory")
+	}
+
+	return targets, nil
+}
+
+// runRules parses the files with the given number of workers in parallel,
+// returning the nodes matched by their rules sorted by location
+func runRules(client api.EngineClient, workdir string, targets []ruleTarget, workers int) *rulesOutput {
+	// the first time it can be slow, as bblfshd may be started and the
+	// drivers installed
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	out := &rulesOutput{Findings: []ruleFinding{}, Files: len(targets)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ch := make(chan ruleTarget)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range ch {
+				findings, err := checkRuleTarget(ctx, client, workdir, t)
+				if err != nil {
+					log.Warningf("could not check %s: %s", t.Path, humanize(err))
+				}
+
+				mu.Lock()
+				if err != nil {
+					out.Errors++
+				}
+				out.Findings = append(out.Findings, findings...)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, t := range targets {
+		ch <- t
+	}
+	close(ch)
+	wg.Wait()
+
+	sort.Slice(out.Findings, func(i, j int) bool {
+		a, b := out.Findings[i], out.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+
+		return a.Rule < b.Rule
+	})
+
+	return out
+}
+
+// checkRuleTarget runs the rules of a file, parsing it once for each rule,
+// in as few ParseBatch requests as possible. The daemon started by srcd init
+// caches the UASTs, so bblfshd parses the file only once. The findings of the
+// rules that could be run are returned along with the error of any other
+func checkRuleTarget(ctx context.Context, client api.EngineClient, workdir string, t ruleTarget) ([]ruleFinding, error) {
+	content, err := ioutil.ReadFile(filepath.Join(workdir, filepath.FromSlash(t.Path)))
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []ruleFinding
+	var firstErr error
+	perBatch := len(t.Rules)
+	if len(content) > 0 {
+		perBatch = parseBatchMaxBytes / len(content)
+	}
+
+	if perBatch == 0 {
+		return nil, fmt.Errorf("the file is too big to be parsed")
+	}
+
+	for len(t.Rules) > 0 {
+		rules := t.Rules
+		if len(rules) > perBatch {
+			rules = rules[:perBatch]
+		}
+		t.Rules = t.Rules[len(rules):]
+
+		req := &api.ParseBatchRequest{}
+		for _, r := range rules {
+			req.Files = append(req.Files, &api.ParseRequest{
+				Kind:    api.ParseRequest_UAST,
+				Name:    t.Path,
+				Content: content,
+				Lang:    t.Lang,
+				Query:   r.XPath,
+				Mode:    r.mode,
+			})
+		}
+
+		resp, err := parseFiles(ctx, client, req)
+		if err != nil {
+			return findings, err
+		}
+
+		for i, res := range resp.Results {
+			if res.Error != "" {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("rule %s: %s", rules[i].ID, res.Error)
+				}
+
+				continue
+			}
+
+			for _, node := range res.Uast {
+				line, col := nodePosition(node)
+				findings = append(findings, ruleFinding{
+					Rule:     rules[i].ID,
+					Severity: rules[i].Severity,
+					Message:  rules[i].Message,
+					File:     t.Path,
+					Line:     line,
+					Column:   col,
+				})
+			}
+		}
+	}
+
+	return findings, firstErr
+}
+
+// Print writes the findings as a table, followed by a summary
+func (o *rulesOutput) Print(w io.Writer) error {
+	if len(o.Findings) > 0 {
+		t := NewTable("%s", "%s", "%s", "%s")
+		t.Header("LOCATION", "SEVERITY", "RULE", "MESSAGE")
+		for _, f := range o.Findings {
+			t.Row(f.location(), f.Severity, f.Rule, f.Message)
+		}
+
+		if err := t.Print(w); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	summary := fmt.Sprintf("%d findings in %d files", len(o.Findings), o.Files)
+	if o.Errors > 0 {
+		summary += fmt.Sprintf(", %d files could not be checked", o.Errors)
+	}
+
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+func (f ruleFinding) location() string {
+	return report.Location{Path: f.File, Line: f.Line, Column: f.Column}.String()
+}
+
+// rulesReport returns the findings as a report, with a check for each rule
+func rulesReport(rules *ruleSet, out *rulesOutput) *report.Report {
+	r := &report.Report{Tool: "srcd rules", Version: version}
+	index := make(map[string]int, len(rules.Rules))
+	for i, rule := range rules.Rules {
+		index[rule.ID] = i
+		r.Checks = append(r.Checks, report.Check{
+			Name:        rule.ID,
+			Description: rule.Message,
+			Severity:    rule.Severity,
+			Status:      report.Passed,
+		})
+	}
+
+	for _, f := range out.Findings {
+		c := &r.Checks[index[f.Rule]]
+		c.Status = report.Failed
+		c.Message = fmt.Sprintf("%s, found %d times", f.Message, len(c.Findings)+1)
+		c.Findings = append(c.Findings, report.Finding{
+			Message: f.Message,
+			Location: report.Location{
+				Path:   f.File,
+				Line:   f.Line,
+				Column: f.Column,
+			},
+		})
+	}
+
+	return r
+}
+
+func init() {
+	c := rootCmd.AddCommand(&rulesCmd{})
+	c.AddCommand(&rulesRunCmd{})
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/report"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// rulesClient replies to each parse request with the nodes of its query
+type rulesClient struct {
+	api.EngineClient
+	nodes map[string][]string
+}
+
+func (c *rulesClient) ParseBatch(
+	ctx context.Context,
+	in *api.ParseBatchRequest,
+	opts ...grpc.CallOption,
+) (*api.ParseBatchResponse, error) {
+	resp := &api.ParseBatchResponse{}
+	for _, f := range in.Files {
+		res := &api.ParseBatchResponse_Result{Name: f.Name, Lang: f.Lang}
+		nodes, ok := c.nodes[f.Query]
+		if !ok {
+			res.Error = fmt.Sprintf("could not apply query %s", f.Query)
+		}
+
+		for _, n := range nodes {
+			res.Uast = append(res.Uast, []byte(n))
+		}
+
+		resp.Results = append(resp.Results, res)
+	}
+
+	return resp, nil
+}
+
+func writeRulesWorkdir(t *testing.T, files ...string) string {
+	dir, err := ioutil.TempDir("", "srcd-rules")
+	require.NoError(t, err)
+
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte("package main"), 0644))
+	}
+
+	return dir
+}
+
+func TestLoadRuleSet(t *testing.T) {
+	require := require.New(t)
+
+	dir := writeRulesWorkdir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, rulesFile)
+	require.NoError(ioutil.WriteFile(path, []byte(`
+rules:
+- id: no-panic
+  message: do not panic
+  xpath: //uast:Identifier[@Name='panic']
+  lang: Go
+  files: ["cmd/**"]
+`), 0644))
+
+	rules, err := loadRuleSet(path)
+	require.NoError(err)
+	require.Len(rules.Rules, 1)
+	r := rules.Rules[0]
+	require.Equal(report.SeverityWarning, r.Severity)
+	require.Equal("go", r.Lang)
+	require.Equal(api.ParseRequest_SEMANTIC, r.mode)
+	require.True(r.matches("cmd/main.go", "go"))
+	require.False(r.matches("lib/main.go", "go"))
+	require.False(r.matches("cmd/main.py", "python"))
+
+	invalid := []string{
+		"rules: []",
+		"rules:\n- message: a\n  xpath: //a",
+		"rules:\n- id: a\n  xpath: //a",
+		"rules:\n- id: a\n  message: a",
+		"rules:\n- id: a\n  message: a\n  xpath: //a\n  severity: fatal",
+		"rules:\n- id: a\n  message: a\n  xpath: //a\n  lang: cobol",
+		"rules:\n- id: a\n  message: a\n  xpath: //a\n  mode: raw",
+		"rules:\n- id: a\n  message: a\n  xpath: //a\n  files: [/abs]",
+		"rules:\n- id: a\n  message: a\n  xpath: //a\n- id: a\n  message: a\n  xpath: //a",
+		"rules:\n- id: a\n  message: a\n  query: //a",
+	}
+	for _, content := range invalid {
+		require.NoError(ioutil.WriteFile(path, []byte(content), 0644))
+		_, err := loadRuleSet(path)
+		require.Error(err, content)
+	}
+}
+
+func TestRuleTargets(t *testing.T) {
+	require := require.New(t)
+
+	dir := writeRulesWorkdir(t,
+		"main.go", "cmd/a.py", "vendor/b.go", ".git/c.go", "README.md", "lib/d.go")
+	defer os.RemoveAll(dir)
+
+	all := &rule{ID: "all", Message: "a", XPath: "//a"}
+	lib := &rule{ID: "lib", Message: "b", XPath: "//b", Files: []string{"lib/**"}}
+	set := &ruleSet{Rules: []*rule{all, lib}}
+	require.NoError(set.validate())
+
+	targets, err := set.ruleTargets(dir)
+	require.NoError(err)
+	require.Equal([]ruleTarget{
+		{Path: "cmd/a.py", Lang: "python", Rules: []*rule{all}},
+		{Path: "lib/d.go", Lang: "go", Rules: []*rule{all, lib}},
+		{Path: "main.go", Lang: "go", Rules: []*rule{all}},
+	}, targets)
+}
+
+func TestFileTargets(t *testing.T) {
+	require := require.New(t)
+
+	dir := writeRulesWorkdir(t, "main.go", "vendor/b.go", "README.md", "lib/d.go")
+	defer os.RemoveAll(dir)
+
+	all := &rule{ID: "all", Message: "a", XPath: "//a"}
+	set := &ruleSet{Rules: []*rule{all}}
+	require.NoError(set.validate())
+
+	targets, err := set.fileTargets(dir, []string{
+		"main.go", "./lib/d.go", "vendor/b.go", "README.md", "deleted.go", "main.go",
+	})
+	require.NoError(err)
+	require.Equal([]ruleTarget{
+		{Path: "lib/d.go", Lang: "go", Rules: []*rule{all}},
+		{Path: "main.go", Lang: "go", Rules: []*rule{all}},
+	}, targets)
+}
+
+func TestNodePosition(t *testing.T) {
+	require := require.New(t)
+
+	line, col := nodePosition([]byte(`{"@type": "uast:Identifier", "@pos": {"@type": "uast:Positions",
+		"start": {"@type": "uast:Position", "offset": 20, "line": 3, "col": 5}}}`))
+	require.Equal(3, line)
+	require.Equal(5, col)
+
+	line, col = nodePosition([]byte(`{"@type": "uast:Identifier"}`))
+	require.Zero(line)
+	require.Zero(col)
+
+	line, _ = nodePosition([]byte(`["a"]`))
+	require.Zero(line)
+}
+
+func TestRunRules(t *testing.T) {
+	require := require.New(t)
+
+	dir := writeRulesWorkdir(t, "a.go", "b.go")
+	defer os.RemoveAll(dir)
+
+	panics := &rule{ID: "no-panic", Message: "do not panic", XPath: "//panic", Severity: report.SeverityError}
+	todos := &rule{ID: "no-todo", Message: "do not leave TODOs", XPath: "//todo"}
+	broken := &rule{ID: "broken", Message: "broken", XPath: "//["}
+	set := &ruleSet{Rules: []*rule{panics, todos, broken}}
+	require.NoError(set.validate())
+
+	client := &rulesClient{nodes: map[string][]string{
+		"//panic": {`{"@pos": {"start": {"line": 7, "col": 2}}}`, `{"@pos": {"start": {"line": 1, "col": 1}}}`},
+		"//todo":  {`{}`},
+	}}
+
+	targets := []ruleTarget{
+		{Path: "b.go", Lang: "go", Rules: []*rule{panics, todos}},
+		{Path: "a.go", Lang: "go", Rules: []*rule{panics, broken}},
+	}
+
+	out := runRules(client, dir, targets, 2)
+	require.Equal(2, out.Files)
+	require.Equal(1, out.Errors)
+	require.Equal([]ruleFinding{
+		{Rule: "no-panic", Severity: report.SeverityError, Message: "do not panic", File: "a.go", Line: 1, Column: 1},
+		{Rule: "no-panic", Severity: report.SeverityError, Message: "do not panic", File: "a.go", Line: 7, Column: 2},
+		{Rule: "no-todo", Severity: report.SeverityWarning, Message: "do not leave TODOs", File: "b.go"},
+		{Rule: "no-panic", Severity: report.SeverityError, Message: "do not panic", File: "b.go", Line: 1, Column: 1},
+		{Rule: "no-panic", Severity: report.SeverityError, Message: "do not panic", File: "b.go", Line: 7, Column: 2},
+	}, out.Findings)
+
+	var buf bytes.Buffer
+	require.NoError(out.Print(&buf))
+	require.Contains(buf.String(), "a.go:7:2    error       no-panic    do not panic\n")
+	require.Contains(buf.String(), "5 findings in 2 files, 1 files could not be checked\n")
+
+	r := rulesReport(set, out)
+	require.Len(r.Checks, 3)
+	require.Equal(report.Failed, r.Checks[0].Status)
+	require.Len(r.Checks[0].Findings, 4)
+	require.Equal("do not panic, found 4 times", r.Checks[0].Message)
+	require.Equal(report.Failed, r.Checks[1].Status)
+	require.Equal(report.Passed, r.Checks[2].Status)
+}
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/report"
+
+	"github.com/pkg/errors"
+	enry "gopkg.in/src-d/enry.v1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// rulesFile is the rules file of srcd rules run, in the working directory,
+// when none is given
+const rulesFile = ".srcd-rules.yml"
+
+// ruleSet is the schema of the rules file of srcd rules run
+type ruleSet struct {
+	Rules []*rule `yaml:"rules"`
+}
+
+// rule is an XPath query over the UASTs of the files, where each node matched
+// is a finding
+type rule struct {
+	ID      string `yaml:"id"`
+	Message string `yaml:"message"`
+	// Severity is error, warning or note, warning by default
+	Severity report.Severity `yaml:"severity,omitempty"`
+	XPath    string          `yaml:"xpath"`
+	// Lang limits the rule to the files of a language
+	Lang string `yaml:"lang,omitempty"`
+	// Files limits the rule to the files matching the patterns, relative to
+	// the working directory. ** matches any number of directories
+	Files []string `yaml:"files,omitempty"`
+	// Mode is the UAST mode the query is run on, semantic by default
+	Mode string `yaml:"mode,omitempty"`
+
+	files []*regexp.Regexp
+	mode  api.ParseRequest_UastMode
+}
+
+// loadRuleSet reads and validates the rules file at the given path
+func loadRuleSet(path string) (*ruleSet, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the rules file %s", path)
+	}
+
+	var rules ruleSet
+	if err := yaml.UnmarshalStrict(content, &rules); err != nil {
+		return nil, errors.Wrapf(err, "rules file %s does not follow the expected format", path)
+	}
+
+	if err := rules.validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid rules file %s", path)
+	}
+
+	return &rules, nil
+}
+
+// validate checks the rules, setting their defaults
+func (s *ruleSet) validate() error {
+	if len(s.Rules) == 0 {
+		return fmt.Errorf("there are no rules")
+	}
+
+	ids := make(map[string]bool, len(s.Rules))
+	for i, r := range s.Rules {
+		if r.ID == "" {
+			return fmt.Errorf("rule %d has no id", i+1)
+		}
+
+		if ids[r.ID] {
+			return fmt.Errorf("rule %q is repeated", r.ID)
+		}
+		ids[r.ID] = true
+
+		if err := r.validate(); err != nil {
+			return errors.Wrapf(err, "rule %q", r.ID)
+		}
+	}
+
+	return nil
+}
+
+func (r *rule) validate() error {
+	if r.XPath == "" {
+		return fmt.Errorf("it needs an xpath query")
+	}
+
+	if r.Message == "" {
+		return fmt.Errorf("it needs a message")
+	}
+
+	switch r.Severity {
+	case "":
+		r.Severity = report.SeverityWarning
+	case report.SeverityError, report.SeverityWarning, report.SeverityNote:
+	default:
+		return fmt.Errorf("unknown severity %q, it must be error, warning or note", r.Severity)
+	}
+
+	r.Lang = strings.ToLower(r.Lang)
+	if r.Lang != "" && !driverLanguages[r.Lang] {
+		return fmt.Errorf("there is no bblfsh driver for the language %q", r.Lang)
+	}
+
+	mode := r.Mode
+	if mode == "" {
+		mode = "semantic"
+	}
+
+	var err error
+	if r.mode, err = parseModeArg(mode); err != nil {
+		return err
+	}
+
+	r.files = nil
+	for _, p := range r.Files {
+		re, err := globRegexp(p)
+		if err != nil {
+			return err
+		}
+
+		r.files = append(r.files, re)
+	}
+
+	return nil
+}
+
+// matches returns whether the rule is run on the file with the given path,
+// relative to the working directory, and language
+func (r *rule) matches(path, lang string) bool {
+	if r.Lang != "" && r.Lang != lang {
+		return false
+	}
+
+	if len(r.files) == 0 {
+		return true
+	}
+
+	for _, re := range r.files {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ruleTarget is a file of the working directory checked by some rules
+type ruleTarget struct {
+	// Path is relative to the working directory, using forward slashes
+	Path  string
+	Lang  string
+	Rules []*rule
+}
+
+// ruleTargets returns the files of the working directory checked by some
+// rules, see target. The .git and vendored directories are skipped
+func (s *ruleSet) ruleTargets(workdir string) ([]ruleTarget, error) {
+	var targets []ruleTarget
+	err := filepath.Walk(workdir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(workdir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if info.Name() == ".git" || enry.IsDotFile(rel) || enry.IsVendor(rel+"/") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if t, ok := s.target(rel, info); ok {
+			targets = append(targets, t)
+		}
+
+		return nil
+	})
+
+	return targets, err
+}
+
+// fileTargets returns the given files checked by some rules, see target. The
+// paths are relative to the working directory, and the files that don't
+// exist, like the deleted ones, are skipped
+func (s *ruleSet) fileTargets(workdir string, paths []string) ([]ruleTarget, error) {
+	seen := make(map[string]bool, len(paths))
+	var targets []ruleTarget
+	for _, p := range paths {
+		rel := filepath.ToSlash(filepath.Clean(p))
+		if rel == "." || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+
+		info, err := os.Stat(filepath.Join(workdir, filepath.FromSlash(rel)))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if t, ok := s.target(rel, info); ok {
+			targets = append(targets, t)
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Path < targets[j].Path })
+	return targets, nil
+}
+
+// target returns the rules run on the file with the given path, relative to
+// the working directory, and whether there is any. Only the regular files
+// with a bblfsh driver for their language, detected by their extension, are
+// checked. The dot files and vendored files are skipped
+func (s *ruleSet) target(rel string, info os.FileInfo) (ruleTarget, bool) {
+	if !info.Mode().IsRegular() || enry.IsDotFile(rel) || enry.IsVendor(rel) {
+		return ruleTarget{}, false
+	}
+
+	lang, safe := enry.GetLanguageByExtension(rel)
+	lang = strings.ToLower(lang)
+	if !safe || !driverLanguages[lang] {
+		return ruleTarget{}, false
+	}
+
+	t := ruleTarget{Path: rel, Lang: lang}
+	for _, r := range s.Rules {
+		if r.matches(rel, lang) {
+			t.Rules = append(t.Rules, r)
+		}
+	}
+
+	return t, len(t.Rules) > 0
+}
+
+// readFileList returns the paths of a file with one path for each line, or
+// of the standard input if the path is -. The empty lines are skipped
+func readFileList(path string) ([]string, error) {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			paths = append(paths, line)
+		}
+	}
+
+	return paths, nil
+}
+
+// uastNodePosition is the start position of a UAST node, as encoded in JSON
+// by bblfsh
+type uastNodePosition struct {
+	Pos *struct {
+		Start *struct {
+			Line int `json:"line"`
+			Col  int `json:"col"`
+		} `json:"start"`
+	} `json:"@pos"`
+}
+
+// nodePosition returns the line and column where the UAST node in JSON
+// starts, or zeros if it has no position
+func nodePosition(node []byte) (int, int) {
+	var n uastNodePosition
+	if err := json.Unmarshal(node, &n); err != nil || n.Pos == nil || n.Pos.Start == nil {
+		return 0, 0
+	}
+
+	return n.Pos.Start.Line, n.Pos.Start.Col
+}
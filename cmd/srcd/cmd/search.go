@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+)
+
+// searchCmd represents the search command
+type searchCmd struct {
+	Command `name:"search" short-description:"Search code in the analyzed repositories" long-description:"Search a regular expression in the files of the analyzed repositories.\n\nThe repositories are indexed the first time the search component starts,\nso the first search may take a while. The matching lines are printed as\nrepository/file:line:content."`
+
+	Lang       string   `short:"l" long:"lang" description:"only search in files of this language"`
+	IgnoreCase bool     `short:"i" long:"ignore-case" description:"case insensitive search"`
+	Repos      []string `short:"r" long:"repo" description:"only search in this repository, can be repeated"`
+
+	Args struct {
+		Pattern string `positional-arg-name:"pattern" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (c *searchCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected only one pattern")
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	if err := daemon.RequireFeature(api.FeatureSearch); err != nil {
+		return err
+	}
+
+	started := logAfterTimeoutWithEvents(client, "this is taking a while, "+
+		"if this is the first search, it might take a few more minutes "+
+		"while we install the search component and index the repositories",
+		5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Minute)
+	defer cancel()
+
+	stream, err := client.Search(ctx, &api.SearchRequest{
+		Pattern:    c.Args.Pattern,
+		Lang:       c.Lang,
+		IgnoreCase: c.IgnoreCase,
+		Repos:      strings.Join(c.Repos, ","),
+	})
+	if err != nil {
+		started()
+		return humanizef(err, "could not search")
+	}
+
+	first := true
+	for {
+		res, err := stream.Recv()
+		if first {
+			started()
+			first = false
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return humanizef(err, "could not search")
+		}
+
+		fmt.Printf("%s/%s:%d:%s\n", res.Repository, res.File, res.LineNumber, res.Line)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(&searchCmd{})
+}
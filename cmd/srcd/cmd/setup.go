@@ -0,0 +1,426 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	units "github.com/docker/go-units"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// errSetupAborted is returned when the standard input is closed before all
+// the questions of srcd setup are answered
+var errSetupAborted = fmt.Errorf("setup aborted, the config file was not changed")
+
+// setupCmd represents the setup command
+type setupCmd struct {
+	Command `name:"setup" short-description:"Create the config file answering a few questions" long-description:"Check the Docker installation and ask for the working directory, the performance profile, the ports in use, the optional components to install and the telemetry choice, to write a valid config file.\n\nThe values already set in the config file are kept unless they are changed, with its comments. With --defaults the default answers are used without asking."`
+
+	Defaults bool `long:"defaults" description:"use the default answers without asking, for scripts"`
+}
+
+// Init doesn't read the config file, so an invalid one can be fixed
+func (c *setupCmd) Init(a *cli.App) error {
+	return c.initWithoutConfig(a)
+}
+
+func (c *setupCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected none")
+	}
+
+	if !c.Defaults && !isInteractive() {
+		return fmt.Errorf("srcd setup asks its questions in a terminal, use --defaults to use the default answers")
+	}
+
+	path, err := config.FilePath(c.Config)
+	if err != nil {
+		return humanizef(err, "could not find the config file")
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !(os.IsNotExist(err) && c.Config == "") {
+		return humanizef(err, "could not read the config file")
+	}
+
+	var current api.Config
+	if err := yaml.Unmarshal(content, &current); err != nil {
+		return fmt.Errorf("the config file %s is not valid YAML, fix it or remove it first: %s",
+			path, err)
+	}
+
+	w := &setupWizard{in: bufio.NewReader(os.Stdin), out: os.Stderr, defaults: c.Defaults}
+	if len(content) > 0 {
+		fmt.Fprintf(w.out, "Updating %s, the values not asked for are kept.\n\n", path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	info, version, dockerErr := docker.SystemInfo(ctx)
+	cancel()
+
+	var backend docker.Backend
+	if dockerErr == nil {
+		backend, dockerErr = docker.GetBackend(runtime.GOOS)
+	}
+
+	if dockerErr != nil {
+		fmt.Fprintf(w.out, "Docker is not usable, the engine can't run until it is fixed:\n%s\n\n",
+			humanize(dockerErr))
+	} else {
+		fmt.Fprintf(w.out, "Docker %s found, running on %s with %s of memory.\n\n",
+			version.Version, backend, units.BytesSize(float64(info.MemTotal)))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return humanizef(err, "could not get working directory")
+	}
+
+	workdir, err := w.ask("Working directory with the repositories to analyze", cwd,
+		func(answer string) error {
+			_, err := workdirArg(answer)
+			return err
+		})
+	if err != nil {
+		return err
+	}
+
+	workdir, err = workdirArg(workdir)
+	if err != nil {
+		return err
+	}
+
+	if dockerErr == nil {
+		if r := checkWorkdir(workdir, backend); r.status == checkFail || r.status == checkWarn {
+			fmt.Fprintf(w.out, "%s\n%s\n", r.msg, r.fix)
+		}
+	}
+
+	performance := current.Performance
+	if performance == "" {
+		performance = api.PerformanceAuto
+	}
+
+	fmt.Fprintln(w.out, "\nThe performance profile sizes the caches and memory limits of the components:")
+	fmt.Fprintln(w.out, "small, medium, large, or auto to pick the one for the memory of the docker host.")
+	performance, err = w.ask("Performance profile", performance, func(answer string) error {
+		_, _, err := (&api.Config{Performance: answer}).PerformanceProfile(0)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	content, err = api.SetConfigValue(content, "performance", performance)
+	if err != nil {
+		return err
+	}
+
+	if dockerErr == nil {
+		content, err = setupPorts(w, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	var optional []string
+	if dockerErr == nil {
+		optional, err = askOptionalComponents(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w.out)
+	fmt.Fprint(w.out, telemetryInfo)
+	telemetryEnabled, err := w.confirm("Enable telemetry?", false)
+	if err != nil {
+		return err
+	}
+
+	if errs := api.ValidateConfig(content); len(errs) > 0 {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+
+		return fmt.Errorf("the config file would not be valid, it was not changed:\n%s",
+			strings.Join(msgs, "\n"))
+	}
+
+	if err := config.Write(c.Config, content); err != nil {
+		return humanizef(err, "could not save the config file")
+	}
+
+	// the rest of steps use the new config, like any other command
+	if err := c.readConfig(); err != nil {
+		return err
+	}
+
+	client, err := newTelemetry(c.Config)
+	if err != nil {
+		return humanizef(err, "could not read telemetry settings")
+	}
+
+	if err := client.SetEnabled(telemetryEnabled); err != nil {
+		return humanizef(err, "could not save telemetry settings")
+	}
+
+	result := &setupResult{
+		File:        path,
+		Workdir:     workdir,
+		Performance: performance,
+		Installed:   []string{},
+		Telemetry:   telemetryEnabled,
+	}
+
+	// the components are read again, with the versions of the new config
+	for _, cmp := range optionalComponents() {
+		if !containsString(optional, strings.TrimPrefix(cmp.Name, components.Prefix())) {
+			continue
+		}
+
+		if err := installComponent(cmp); err != nil {
+			return err
+		}
+
+		result.Installed = append(result.Installed, cmp.Name)
+	}
+
+	return render(os.Stdout, result, result.Print)
+}
+
+// setupPorts asks to use a free port for each port of the config that is in
+// use by a process or a container that does not belong to the engine, and
+// returns the config file with the new ones
+func setupPorts(w *setupWizard, content []byte) ([]byte, error) {
+	var conf api.Config
+	if err := yaml.Unmarshal(content, &conf); err != nil {
+		return nil, err
+	}
+
+	ports, err := publishedPorts(&conf)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range ports {
+		conflict := docker.CheckPort(*p.port, components.Prefix())
+		if conflict == nil {
+			continue
+		}
+
+		if _, ok := conflict.(*docker.PortConflictErr); !ok {
+			return nil, humanizef(conflict, "could not check port %d", *p.port)
+		}
+
+		free, err := docker.FreePort()
+		if err != nil {
+			return nil, humanizef(err, "could not find a free port")
+		}
+
+		fmt.Fprintf(w.out, "\n%s.\n", conflict)
+		ok, err := w.confirm(fmt.Sprintf("Use port %d for %s instead?", free, p.key), true)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		content, err = api.SetConfigValue(content, p.key, fmt.Sprint(free))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return content, nil
+}
+
+// optionalComponents are the components srcd setup offers to install, as
+// srcd init only needs gitbase and bblfshd
+func optionalComponents() []components.Component {
+	return []components.Component{
+		components.GitbaseWeb,
+		components.BblfshWeb,
+		components.Analytics,
+		components.Search,
+		components.Notebook,
+		components.Spark,
+	}
+}
+
+// askOptionalComponents asks which optional components to install, and
+// returns their names without the profile prefix
+func askOptionalComponents(w *setupWizard) ([]string, error) {
+	var names []string
+	for _, cmp := range optionalComponents() {
+		names = append(names, strings.TrimPrefix(cmp.Name, components.Prefix()))
+	}
+
+	fmt.Fprintf(w.out, "\nThe images of the optional components can be pulled now: %s.\n",
+		strings.Join(names, ", "))
+
+	var selected []string
+	_, err := w.ask("Components to install, separated by commas", "none", func(answer string) error {
+		selected = nil
+		if answer == "none" {
+			return nil
+		}
+
+		for _, name := range strings.Split(answer, ",") {
+			name = strings.TrimSpace(name)
+			if !containsString(names, name) {
+				return fmt.Errorf("unknown component %q, it must be one of %s", name, strings.Join(names, ", "))
+			}
+
+			selected = append(selected, name)
+		}
+
+		return nil
+	})
+
+	return selected, err
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// installComponent pulls the image of the component, if it is not installed
+func installComponent(cmp components.Component) error {
+	if _, err := cmp.RetrieveVersion(); err != nil {
+		return humanizef(err, "could not retrieve the latest compatible version for %s", cmp.Image)
+	}
+
+	installed, err := cmp.IsInstalled()
+	if err != nil {
+		return humanizef(err, "could not check if %s is installed", cmp.Name)
+	}
+
+	if installed {
+		log.Infof("%s is already installed", cmp.Name)
+		return nil
+	}
+
+	log.Infof("installing %s", cmp.ImageWithVersion())
+	if err := cmp.Install(); err != nil {
+		return humanizef(err, "could not install %s", cmp.Name)
+	}
+
+	return nil
+}
+
+// setupWizard asks the questions of srcd setup
+type setupWizard struct {
+	in  *bufio.Reader
+	out io.Writer
+	// defaults answers each question with its default value, without asking
+	defaults bool
+}
+
+// ask asks the question until valid accepts the answer, and returns it. An
+// empty answer is the default one, given in def
+func (w *setupWizard) ask(question, def string, valid func(string) error) (string, error) {
+	for {
+		answer := def
+		if !w.defaults {
+			fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+			line, err := w.in.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				fmt.Fprintln(w.out)
+				return "", errSetupAborted
+			}
+
+			if line = strings.TrimSpace(line); line != "" {
+				answer = line
+			}
+		}
+
+		err := valid(answer)
+		if err == nil {
+			return answer, nil
+		}
+
+		if w.defaults {
+			return "", err
+		}
+
+		fmt.Fprintln(w.out, err)
+	}
+}
+
+// confirm asks a yes or no question, with def as the default answer
+func (w *setupWizard) confirm(question string, def bool) (bool, error) {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+
+	answer, err := w.ask(question, choices, func(answer string) error {
+		if answer == choices {
+			return nil
+		}
+
+		switch strings.ToLower(answer) {
+		case "y", "yes", "n", "no":
+			return nil
+		default:
+			return fmt.Errorf("answer y or n")
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	default:
+		return def, nil
+	}
+}
+
+// setupResult is the output of srcd setup
+type setupResult struct {
+	File        string `json:"file" yaml:"file"`
+	Workdir     string `json:"workdir" yaml:"workdir"`
+	Performance string `json:"performance" yaml:"performance"`
+	// Installed are the container names of the optional components
+	// installed
+	Installed []string `json:"installed" yaml:"installed"`
+	Telemetry bool     `json:"telemetry" yaml:"telemetry"`
+}
+
+func (r *setupResult) Print(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "\nconfig file saved in %s\n"+
+		"start the engine with: srcd init %s\n", r.File, r.Workdir)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(&setupCmd{})
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWizard(input string) (*setupWizard, *bytes.Buffer) {
+	var out bytes.Buffer
+	return &setupWizard{in: bufio.NewReader(strings.NewReader(input)), out: &out}, &out
+}
+
+func TestSetupWizardAsk(t *testing.T) {
+	require := require.New(t)
+
+	valid := func(answer string) error {
+		if answer != "small" && answer != "large" {
+			return fmt.Errorf("invalid %s", answer)
+		}
+
+		return nil
+	}
+
+	w, out := newTestWizard("huge\n\n")
+	answer, err := w.ask("Performance profile", "small", valid)
+	require.NoError(err)
+	require.Equal("small", answer)
+	require.Equal("Performance profile [small]: invalid huge\nPerformance profile [small]: ", out.String())
+
+	w, _ = newTestWizard("  large  \n")
+	answer, err = w.ask("Performance profile", "small", valid)
+	require.NoError(err)
+	require.Equal("large", answer)
+
+	w, _ = newTestWizard("")
+	_, err = w.ask("Performance profile", "small", valid)
+	require.Equal(errSetupAborted, err)
+
+	w, out = newTestWizard("")
+	w.defaults = true
+	answer, err = w.ask("Performance profile", "small", valid)
+	require.NoError(err)
+	require.Equal("small", answer)
+	require.Empty(out.String())
+
+	_, err = w.ask("Performance profile", "huge", valid)
+	require.EqualError(err, "invalid huge")
+}
+
+func TestSetupWizardConfirm(t *testing.T) {
+	require := require.New(t)
+
+	w, out := newTestWizard("maybe\nYes\n\n")
+	ok, err := w.confirm("Enable telemetry?", false)
+	require.NoError(err)
+	require.True(ok)
+	require.Equal("Enable telemetry? [y/N]: answer y or n\nEnable telemetry? [y/N]: ", out.String())
+
+	ok, err = w.confirm("Enable telemetry?", false)
+	require.NoError(err)
+	require.False(ok)
+
+	w, _ = newTestWizard("\nn\n")
+	ok, err = w.confirm("Use port 3307 instead?", true)
+	require.NoError(err)
+	require.True(ok)
+
+	ok, err = w.confirm("Use port 3307 instead?", true)
+	require.NoError(err)
+	require.False(ok)
+}
+
+func TestAskOptionalComponents(t *testing.T) {
+	require := require.New(t)
+
+	w, _ := newTestWizard("\n")
+	names, err := askOptionalComponents(w)
+	require.NoError(err)
+	require.Empty(names)
+
+	w, out := newTestWizard("gitbase, notebook\ngitbase-web, notebook\n")
+	names, err = askOptionalComponents(w)
+	require.NoError(err)
+	require.Equal([]string{"gitbase-web", "notebook"}, names)
+	require.Contains(out.String(), `unknown component "gitbase", it must be one of `+
+		"gitbase-web, bblfsh-web, analytics, search, notebook")
+}
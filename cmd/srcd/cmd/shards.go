@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+
+	units "github.com/docker/go-units"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// shardsCmd represents the shards command
+type shardsCmd struct {
+	cli.PlainCommand `name:"shards" short-description:"Split the repositories between several gitbase instances" long-description:"Split the repositories of the working directory between several gitbase instances, see components.gitbase.shards in the config file"`
+}
+
+// shardsPlanCmd represents the shards plan command
+type shardsPlanCmd struct {
+	Command `name:"plan" short-description:"Propose a layout of the gitbase shards" long-description:"Propose a layout of the gitbase shards with a similar size of repositories each, read from the working directory, and use it with --apply.\n\nThe repositories keep their current shard, the new ones go to the smallest shards and the removed ones are dropped, unless the number of shards changes or --rebalance is given, as the gitbase indexes of the moved repositories are lost.\n\nThe layout is saved in shards.yml, next to the config file, and is not used if the config file sets components.gitbase.shards."`
+
+	Shards    int  `short:"n" long:"shards" description:"number of shards, the current one by default"`
+	Rebalance bool `long:"rebalance" description:"distribute all the repositories again, instead of only the new ones"`
+	Apply     bool `long:"apply" description:"save the layout and restart the daemon to use it"`
+}
+
+func (c *shardsPlanCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected none")
+	}
+
+	if c.Shards < 0 {
+		return fmt.Errorf("invalid --shards %d, it can't be negative", c.Shards)
+	}
+
+	if daemon.IsRemote() {
+		return fmt.Errorf("the shards are planned from the working directory, it can't be used with a remote daemon")
+	}
+
+	if c.Apply {
+		file, err := config.Load(c.Config)
+		if err != nil {
+			return err
+		}
+
+		if len(file.Components.Gitbase.Shards) > 0 {
+			return fmt.Errorf("the config file sets components.gitbase.shards, " +
+				"remove them to use the planned ones")
+		}
+	}
+
+	workdir, err := daemon.Workdir()
+	if err != nil {
+		return humanizef(err, "could not get the working directory")
+	}
+
+	log.Debugf("reading the repositories of %s", workdir)
+	repos, err := shardableRepositories(workdir)
+	if err != nil {
+		return err
+	}
+
+	plan, err := planShards(repos, config.File.Components.Gitbase.Shards, c.Shards, c.Rebalance)
+	if err != nil {
+		return err
+	}
+
+	if c.Apply {
+		shards := plan.config()
+		if err := config.WriteShards(shards); err != nil {
+			return humanizef(err, "could not save the shards")
+		}
+
+		config.File.Components.Gitbase.Shards = shards
+		if _, err := daemon.Init(workdir, false); err != nil {
+			return humanizef(err, "could not restart the daemon")
+		}
+
+		plan.Applied = true
+	}
+
+	return render(os.Stdout, plan, plan.Print)
+}
+
+// shardRepository is a repository of the working directory that can be
+// assigned to a shard
+type shardRepository struct {
+	Name string
+	// Size is the disk usage of the repository in bytes
+	Size int64
+}
+
+// shardableRepositories returns the repositories of the working directory,
+// the directories with a git repository with a working tree or a bare one,
+// with their size. Each one is mounted in a shard on its own
+func shardableRepositories(workdir string) ([]shardRepository, error) {
+	if isGitRepository(workdir) || isBareRepository(workdir) {
+		return nil, fmt.Errorf("the working directory is a single repository, it can't be split")
+	}
+
+	infos, err := ioutil.ReadDir(workdir)
+	if err != nil {
+		return nil, humanizef(err, "could not read the working directory")
+	}
+
+	var repos []shardRepository
+	for _, info := range infos {
+		dir := filepath.Join(workdir, info.Name())
+		if !info.IsDir() || !isGitRepository(dir) && !isBareRepository(dir) {
+			continue
+		}
+
+		size, err := diskUsage(dir)
+		if err != nil {
+			return nil, humanizef(err, "could not read the size of %s", info.Name())
+		}
+
+		repos = append(repos, shardRepository{Name: info.Name(), Size: size})
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("there are no git repositories in %s", workdir)
+	}
+
+	return repos, nil
+}
+
+// isBareRepository returns whether the directory is a bare git repository
+func isBareRepository(dir string) bool {
+	head, err := os.Stat(filepath.Join(dir, "HEAD"))
+	if err != nil || head.IsDir() {
+		return false
+	}
+
+	objects, err := os.Stat(filepath.Join(dir, "objects"))
+	return err == nil && objects.IsDir()
+}
+
+// diskUsage returns the size of the regular files in the directory, without
+// following the symbolic links
+func diskUsage(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
+
+// shardPlan is a layout of the gitbase shards, and the changes from the
+// current one
+type shardPlan struct {
+	Shards []plannedShard `json:"shards" yaml:"shards"`
+	// Added are the repositories without a shard in the current layout,
+	// Removed the ones that no longer exist and Moved the ones assigned to
+	// another shard
+	Added   []string `json:"added" yaml:"added"`
+	Removed []string `json:"removed" yaml:"removed"`
+	Moved   []string `json:"moved" yaml:"moved"`
+	Applied bool     `json:"applied" yaml:"applied"`
+}
+
+type plannedShard struct {
+	Repositories []string `json:"repositories" yaml:"repositories"`
+	Size         int64    `json:"size" yaml:"size"`
+}
+
+// planShards assigns the repositories to n shards, or to as many as in the
+// current layout if n is 0. The repositories of the current layout keep their
+// shard, and the rest go one by one, from the largest, to the smallest shard.
+// With rebalance, or when the number of shards changes, all the repositories
+// are assigned again
+func planShards(repos []shardRepository, current []api.GitbaseShard, n int, rebalance bool) (*shardPlan, error) {
+	if n == 0 {
+		n = len(current)
+	}
+
+	if n == 0 {
+		return nil, fmt.Errorf("there are no shards yet, give their number with --shards")
+	}
+
+	if len(repos) < n {
+		return nil, fmt.Errorf("there are %d repositories, they can't be split in %d shards", len(repos), n)
+	}
+
+	sizes := make(map[string]int64, len(repos))
+	for _, r := range repos {
+		sizes[r.Name] = r.Size
+	}
+
+	previous := make(map[string]int)
+	for i, s := range current {
+		for _, repo := range s.Repositories {
+			previous[filepath.ToSlash(filepath.Clean(repo))] = i
+		}
+	}
+
+	plan := &shardPlan{
+		Shards:  make([]plannedShard, n),
+		Added:   []string{},
+		Removed: []string{},
+		Moved:   []string{},
+	}
+
+	assigned := make(map[string]bool, len(repos))
+	if !rebalance && len(current) == n {
+		for repo, i := range previous {
+			if size, ok := sizes[repo]; ok {
+				plan.Shards[i].Repositories = append(plan.Shards[i].Repositories, repo)
+				plan.Shards[i].Size += size
+				assigned[repo] = true
+			}
+		}
+	}
+
+	var pending []shardRepository
+	for _, r := range repos {
+		if !assigned[r.Name] {
+			pending = append(pending, r)
+		}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		if pending[i].Size != pending[j].Size {
+			return pending[i].Size > pending[j].Size
+		}
+
+		return pending[i].Name < pending[j].Name
+	})
+
+	for _, r := range pending {
+		smallest := 0
+		for i, s := range plan.Shards {
+			if s.Size < plan.Shards[smallest].Size ||
+				s.Size == plan.Shards[smallest].Size && len(s.Repositories) < len(plan.Shards[smallest].Repositories) {
+				smallest = i
+			}
+		}
+
+		plan.Shards[smallest].Repositories = append(plan.Shards[smallest].Repositories, r.Name)
+		plan.Shards[smallest].Size += r.Size
+	}
+
+	for i, s := range plan.Shards {
+		// the repositories of a shard were all removed
+		if len(s.Repositories) == 0 {
+			return planShards(repos, current, n, true)
+		}
+
+		sort.Strings(s.Repositories)
+		for _, repo := range s.Repositories {
+			j, ok := previous[repo]
+			switch {
+			case !ok:
+				plan.Added = append(plan.Added, repo)
+			case i != j:
+				plan.Moved = append(plan.Moved, repo)
+			}
+		}
+	}
+
+	for repo := range previous {
+		if _, ok := sizes[repo]; !ok {
+			plan.Removed = append(plan.Removed, repo)
+		}
+	}
+
+	sort.Strings(plan.Added)
+	sort.Strings(plan.Removed)
+	sort.Strings(plan.Moved)
+	return plan, nil
+}
+
+// config returns the shards of the plan for components.gitbase.shards
+func (p *shardPlan) config() []api.GitbaseShard {
+	shards := make([]api.GitbaseShard, len(p.Shards))
+	for i, s := range p.Shards {
+		shards[i].Repositories = s.Repositories
+	}
+
+	return shards
+}
+
+func (p *shardPlan) Print(w io.Writer) error {
+	t := NewTable("%s", "%s", "%s")
+	t.Header("SHARD", "REPOSITORIES", "SIZE")
+	for i, s := range p.Shards {
+		t.Row(fmt.Sprint(i), strings.Join(s.Repositories, ", "), units.HumanSize(float64(s.Size)))
+	}
+
+	if err := t.Print(w); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%d added, %d removed, %d moved\n", len(p.Added), len(p.Removed), len(p.Moved))
+	if len(p.Moved) > 0 {
+		fmt.Fprintf(w, "the gitbase indexes of the moved repositories must be created again: %s\n",
+			strings.Join(p.Moved, ", "))
+	}
+
+	if p.Applied {
+		fmt.Fprintln(w, "the layout is applied, the shards are recreated on the next query")
+	} else {
+		fmt.Fprintln(w, "run srcd shards plan --apply to use this layout")
+	}
+
+	return nil
+}
+
+func init() {
+	c := rootCmd.AddCommand(&shardsCmd{})
+	c.AddCommand(&shardsPlanCmd{})
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/engine/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardableRepositories(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-shards")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(os.MkdirAll(filepath.Join(dir, "engine", ".git"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "engine", "main.go"), make([]byte, 100), 0644))
+	require.NoError(os.MkdirAll(filepath.Join(dir, "bare.git", "objects"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "bare.git", "HEAD"), []byte("ref: refs/heads/master\n"), 0644))
+	require.NoError(os.MkdirAll(filepath.Join(dir, "docs"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "README.md"), nil, 0644))
+
+	repos, err := shardableRepositories(dir)
+	require.NoError(err)
+	require.Equal([]shardRepository{
+		{Name: "bare.git", Size: 23},
+		{Name: "engine", Size: 100},
+	}, repos)
+
+	_, err = shardableRepositories(filepath.Join(dir, "engine"))
+	require.EqualError(err, "the working directory is a single repository, it can't be split")
+
+	_, err = shardableRepositories(filepath.Join(dir, "docs"))
+	require.Error(err)
+}
+
+func TestPlanShards(t *testing.T) {
+	require := require.New(t)
+
+	repos := []shardRepository{
+		{Name: "a", Size: 50},
+		{Name: "b", Size: 40},
+		{Name: "c", Size: 30},
+		{Name: "d", Size: 20},
+		{Name: "e", Size: 10},
+	}
+
+	_, err := planShards(repos, nil, 0, false)
+	require.EqualError(err, "there are no shards yet, give their number with --shards")
+	_, err = planShards(repos, nil, 6, false)
+	require.EqualError(err, "there are 5 repositories, they can't be split in 6 shards")
+
+	plan, err := planShards(repos, nil, 2, false)
+	require.NoError(err)
+	require.Equal([]plannedShard{
+		{Repositories: []string{"a", "d", "e"}, Size: 80},
+		{Repositories: []string{"b", "c"}, Size: 70},
+	}, plan.Shards)
+	require.Equal([]string{"a", "b", "c", "d", "e"}, plan.Added)
+	require.Empty(plan.Moved)
+
+	// the new repositories go to the smallest shard, the rest stay
+	current := []api.GitbaseShard{
+		{Repositories: []string{"a", "gone"}},
+		{Repositories: []string{"b"}},
+	}
+	plan, err = planShards(repos, current, 0, false)
+	require.NoError(err)
+	require.Equal([]plannedShard{
+		{Repositories: []string{"a", "d", "e"}, Size: 80},
+		{Repositories: []string{"b", "c"}, Size: 70},
+	}, plan.Shards)
+	require.Equal([]string{"c", "d", "e"}, plan.Added)
+	require.Equal([]string{"gone"}, plan.Removed)
+	require.Empty(plan.Moved)
+
+	plan, err = planShards(repos, current, 0, true)
+	require.NoError(err)
+	require.Equal([]string{"a", "d", "e"}, plan.Shards[0].Repositories)
+	require.Equal([]string{"b", "c"}, plan.Shards[1].Repositories)
+	require.Empty(plan.Moved)
+
+	// a shard whose repositories were all removed
+	plan, err = planShards(repos, []api.GitbaseShard{
+		{Repositories: []string{"a", "b", "c", "d", "e"}},
+		{Repositories: []string{"gone"}},
+	}, 0, false)
+	require.NoError(err)
+	require.Equal([]string{"b", "c"}, plan.Moved)
+	require.Equal(api.GitbaseShard{Repositories: []string{"b", "c"}}, plan.config()[1])
+
+	var buf bytes.Buffer
+	require.NoError(plan.Print(&buf))
+	require.Contains(buf.String(), "0 added, 1 removed, 2 moved\n")
+	require.Contains(buf.String(), "run srcd shards plan --apply to use this layout\n")
+}
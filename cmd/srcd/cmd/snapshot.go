@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	sdk "github.com/src-d/engine/engine"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+var snapshotNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// snapshotDir returns the directory of the snapshot with the given name,
+// $HOME/.srcd/snapshots/name
+func snapshotDir(name string) (string, error) {
+	if !snapshotNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name %q, only letters, digits, '_', '.' and '-' are allowed", name)
+	}
+
+	dir, err := snapshotsDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+func snapshotsDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".srcd", "snapshots"), nil
+}
+
+// snapshotEngine returns an Engine for the working directory of the daemon,
+// used to locate the volumes
+func snapshotEngine() (*sdk.Engine, error) {
+	workdir, err := daemon.Workdir()
+	if err != nil {
+		return nil, humanizef(err, "could not get the daemon working directory")
+	}
+
+	var conf api.Config
+	for _, cmp := range components.Upgradable() {
+		conf.SetComponentVersion(cmp.Name, cmp.Version)
+	}
+
+	return sdk.New(sdk.Options{Workdir: workdir, Config: conf}), nil
+}
+
+func volumeKinds(vs []sdk.Volume) string {
+	kinds := make([]string, len(vs))
+	for i, v := range vs {
+		kinds[i] = v.Kind
+	}
+
+	return strings.Join(kinds, ", ")
+}
+
+// snapshotCmd represents the snapshot command
+type snapshotCmd struct {
+	cli.PlainCommand `name:"snapshot" short-description:"Save and restore the gitbase indexes and bblfsh drivers" long-description:"Save and restore the docker volumes with the gitbase indexes, the search index and the bblfsh drivers, as tarballs in $HOME/.srcd/snapshots"`
+}
+
+// snapshotCreateCmd represents the snapshot create command
+type snapshotCreateCmd struct {
+	Command `name:"create" short-description:"Create a snapshot" long-description:"Archive the component volumes of the current working directory into a snapshot. The components must be stopped first with srcd stop"`
+
+	Force bool `short:"f" long:"force" description:"Replace the snapshot if it already exists"`
+
+	Args struct {
+		Name string `positional-arg-name:"name" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *snapshotCreateCmd) Execute(args []string) error {
+	dir, err := snapshotDir(c.Args.Name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		if !c.Force {
+			return fmt.Errorf("snapshot %s already exists, use --force to replace it", c.Args.Name)
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			return humanizef(err, "could not remove snapshot %s", c.Args.Name)
+		}
+	}
+
+	e, err := snapshotEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	vs, err := e.CreateSnapshot(ctx, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return humanizef(err, "could not create snapshot %s", c.Args.Name)
+	}
+
+	if len(vs) == 0 {
+		os.RemoveAll(dir)
+		return fmt.Errorf("there is nothing to archive, the components were never started")
+	}
+
+	log.Infof("snapshot %s created at %s with %s", c.Args.Name, dir, volumeKinds(vs))
+	return nil
+}
+
+// snapshotRestoreCmd represents the snapshot restore command
+type snapshotRestoreCmd struct {
+	Command `name:"restore" short-description:"Restore a snapshot" long-description:"Replace the component volumes of the current working directory with the ones archived in a snapshot. The components must be stopped first with srcd stop"`
+
+	Args struct {
+		Name snapshotArg `positional-arg-name:"name" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *snapshotRestoreCmd) Execute(args []string) error {
+	dir, err := snapshotDir(string(c.Args.Name))
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("snapshot %s does not exist", c.Args.Name)
+	}
+
+	e, err := snapshotEngine()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	vs, err := e.RestoreSnapshot(ctx, dir)
+	if err != nil {
+		return humanizef(err, "could not restore snapshot %s", c.Args.Name)
+	}
+
+	log.Infof("snapshot %s restored with %s", c.Args.Name, volumeKinds(vs))
+	return nil
+}
+
+// snapshotListCmd represents the snapshot list command
+type snapshotListCmd struct {
+	Command `name:"list" short-description:"List snapshots" long-description:"List snapshots"`
+}
+
+func (c *snapshotListCmd) Execute(args []string) error {
+	dir, err := snapshotsDir()
+	if err != nil {
+		return err
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return humanizef(err, "could not read snapshots directory")
+	}
+
+	out := snapshotsOutput{Snapshots: []snapshotOutput{}}
+	t := NewTable("%s", "%s")
+	t.Header("NAME", "CREATED")
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+
+		t.Row(info.Name(), info.ModTime().Format(time.RFC822))
+		out.Snapshots = append(out.Snapshots, snapshotOutput{
+			Name:    info.Name(),
+			Created: info.ModTime(),
+		})
+	}
+
+	return render(os.Stdout, out, t.Print)
+}
+
+// snapshotsOutput is the schema of the srcd snapshot list output
+type snapshotsOutput struct {
+	Snapshots []snapshotOutput `json:"snapshots" yaml:"snapshots"`
+}
+
+type snapshotOutput struct {
+	Name    string    `json:"name" yaml:"name"`
+	Created time.Time `json:"created" yaml:"created"`
+}
+
+func init() {
+	c := rootCmd.AddCommand(&snapshotCmd{})
+	c.AddCommand(&snapshotCreateCmd{})
+	c.AddCommand(&snapshotRestoreCmd{})
+	c.AddCommand(&snapshotListCmd{})
+}
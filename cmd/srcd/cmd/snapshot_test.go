@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotDir(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{"indexes", "v1.2_big-repos"} {
+		dir, err := snapshotDir(name)
+		assert.NoError(err, name)
+		assert.Equal(filepath.Join(".srcd", "snapshots", name), filepath.Join(
+			filepath.Base(filepath.Dir(filepath.Dir(dir))),
+			filepath.Base(filepath.Dir(dir)),
+			filepath.Base(dir),
+		))
+	}
+
+	for _, name := range []string{"", "..", "../x", "a/b", ".hidden", "a b"} {
+		_, err := snapshotDir(name)
+		assert.Error(err, name)
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+)
+
+const (
+	// sparkPackages are the Maven packages of the Spark session, the JDBC
+	// driver for gitbase
+	sparkPackages = "mysql:mysql-connector-java:5.1.47"
+	// sparkIPythonDir is the IPython directory of the Spark notebook, out of
+	// the home directory, which is / when it runs as another user
+	sparkIPythonDir = "/tmp/ipython"
+	// sparkStartup is run by the Python kernels of the Spark notebook, to
+	// create the Spark session and the gitbase helper
+	sparkStartup = `import os
+
+from pyspark.sql import SparkSession
+
+GITBASE_JDBC_URL = "jdbc:mysql://%s:%s/gitbase?useSSL=false" % (
+    os.environ["GITBASE_HOST"], os.environ["GITBASE_PORT"])
+
+spark = (SparkSession.builder
+         .appName("srcd")
+         .master(os.environ["SPARK_MASTER"])
+         .config("spark.jars.packages", os.environ["SPARK_PACKAGES"])
+         .config("spark.driver.memory", os.environ["SPARK_DRIVER_MEMORY"])
+         .getOrCreate())
+
+
+def gitbase(table, **options):
+    """Returns a DataFrame with a gitbase table, or with the result of a query
+    given as "(SELECT ...) AS name". The options are passed to the JDBC source
+    of Spark, like partitionColumn and numPartitions."""
+    reader = (spark.read.format("jdbc")
+              .option("url", GITBASE_JDBC_URL)
+              .option("driver", "com.mysql.jdbc.Driver")
+              .option("dbtable", table)
+              .option("user", os.environ["GITBASE_USER"])
+              .option("password", os.environ["GITBASE_PASSWORD"]))
+    for key, value in options.items():
+        reader = reader.option(key, value)
+
+    return reader.load()
+`
+)
+
+// sparkCmd represents the spark command
+type sparkCmd struct {
+	Command `name:"spark" short-description:"Start a Spark notebook connected to the engine" long-description:"Start a Jupyter notebook with PySpark, the JDBC driver for gitbase and the bblfsh client (bblfsh-python) installed.\n\nThe Python kernels start with a Spark session in the spark variable, and the\ngitbase function, which returns a DataFrame with a gitbase table or the result\nof a query read with JDBC. The GITBASE_* and BBLFSH_ENDPOINT environment\nvariables hold the addresses and credentials of the components, like in srcd\nnotebook. The notebooks are saved in the given directory, mounted at ~/work.\n\nSpark runs in the container with all its cores by default. With --master the\nsession uses a Spark cluster instead, whose workers must reach gitbase."`
+
+	Dir          string `short:"d" long:"dir" description:"directory where the notebooks are saved (default: $HOME/.srcd/notebooks)"`
+	Master       string `long:"master" default:"local[*]" description:"URL of the Spark master, like spark://host:7077"`
+	DriverMemory string `long:"driver-memory" default:"2g" description:"memory of the Spark driver, in the JVM format, like 4g"`
+}
+
+func (c *sparkCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	if err := config.Read(c.Config); err != nil {
+		return humanizef(err, "could not read the config file")
+	}
+
+	conf := *config.File
+	conf.SetDefaults()
+	conf.SetDefaultUser(runtime.GOOS)
+
+	dir, err := notebooksDir(c.Dir)
+	if err != nil {
+		return humanizef(err, "could not get the notebooks directory")
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	started := logAfterTimeoutWithEvents(client, "this is taking a while, "+
+		"if this is the first time you launch the Spark notebook, "+
+		"it might take a few more minutes while we install all the required images",
+		5*time.Second)
+
+	env, err := notebookEnv(client, components.Spark.Name)
+	if err != nil {
+		started()
+		return err
+	}
+
+	env = append(env, sparkEnv(c.Master, c.DriverMemory)...)
+
+	port, uiPort := conf.Components.Spark.Port, conf.Components.Spark.UIPort
+	opts := append(notebookOptions(&conf),
+		docker.WithPort(port, components.NotebookPort),
+		docker.WithPort(uiPort, components.SparkUIPort),
+	)
+
+	token, err := startNotebook(components.Spark, dir, sparkSetup(), env, opts...)
+	started()
+	if err != nil {
+		return humanizef(err, "could not start the Spark notebook")
+	}
+
+	what := fmt.Sprintf("the Spark notebook, and to http://localhost:%d for the Spark UI of its jobs", uiPort)
+	return serveNotebook(components.Spark, port, token, dir, what)
+}
+
+// sparkEnv returns the environment variables read by the startup script of
+// the Spark notebook, see sparkStartup
+func sparkEnv(master, driverMemory string) []string {
+	return []string{
+		"SPARK_MASTER=" + master,
+		"SPARK_DRIVER_MEMORY=" + driverMemory,
+		"SPARK_PACKAGES=" + sparkPackages,
+		"IPYTHONDIR=" + sparkIPythonDir,
+		"SRCD_SPARK_STARTUP=" + sparkStartup,
+	}
+}
+
+// sparkSetup returns the shell commands that install the clients and write
+// the startup script, from the SRCD_SPARK_STARTUP environment variable
+func sparkSetup() []string {
+	startup := sparkIPythonDir + "/profile_default/startup"
+	return []string{
+		"pip install --quiet " + notebookPackages,
+		"mkdir -p " + startup,
+		`printf '%s\n' "$SRCD_SPARK_STARTUP" > ` + startup + "/00-srcd-spark.py",
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(&sparkCmd{})
+}
@@ -15,78 +15,126 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
 	"github.com/src-d/engine/cmd/srcd/daemon"
 	"github.com/src-d/engine/components"
 	"github.com/src-d/engine/docker"
+	"github.com/src-d/engine/report"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/pkg/term"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/src-d/go-log.v1"
 )
 
-// sqlCmd represents the sql command
-
+// sqlCmd represents the sql command. The query is not a positional argument,
+// so the connection-info sub command can be told apart from it
 type sqlCmd struct {
-	Command `name:"sql" short-description:"Run a SQL query over the analyzed repositories" long-description:"Run a SQL query over the analyzed repositories"`
+	Command `name:"sql" short-description:"Run a SQL query over the analyzed repositories" long-description:"Run a SQL query over the analyzed repositories, given as the argument, srcd sql <query>, or piped to the standard input. An interactive session is opened if there is no query.\n\nsrcd sql connection-info prints the details to connect other SQL tools to gitbase."`
+
+	NoPager bool   `long:"no-pager" description:"Print the query result directly instead of using $PAGER"`
+	Stats   bool   `long:"stats" description:"Print the statistics of the query after its result"`
+	Export  string `long:"export" choice:"junit" choice:"sarif" description:"Run the query as a policy check, where each row returned is a violation, and print the result in this format instead of the rows"`
+	Range   string `long:"range" description:"Only query the commits of this range, like HEAD~10..HEAD, of the repositories of the working directory"`
+	Native  bool   `long:"native" description:"Open the interactive session in srcd instead of the mysql client, with tab-completion of the tables and columns"`
+
+	InstallMissingDrivers bool `long:"install-missing-drivers" description:"Install the bblfsh drivers of the languages parsed in the query that are missing"`
 
-	Args struct {
-		Query string `positional-arg-name:"query"`
-	} `positional-args:"yes"`
+	User     string `long:"user" description:"User to connect to gitbase, instead of components.gitbase.user of the config file"`
+	Password bool   `short:"p" long:"password" description:"Prompt for the gitbase password, instead of using components.gitbase.password of the config file"`
+
+	Connection string `short:"c" long:"connection" description:"Query the external MySQL server with this name of sql.connections in the config file instead of the gitbase of the daemon. Without a query the interactive session of --native is opened"`
 }
 
 func (c *sqlCmd) Execute(args []string) error {
-	if len(args) > 0 {
+	if len(args) > 1 {
 		return fmt.Errorf("too many arguments, expected only one query or nothing")
 	}
 
+	if c.Connection != "" {
+		return c.executeConnection(args)
+	}
+
+	user, password, err := c.credentials(config.File.GitbaseCredentials())
+	if err != nil {
+		return err
+	}
+
 	client, err := daemon.Client()
 	if err != nil {
 		return humanizef(err, "could not get daemon client")
 	}
 
-	if err := startGitbaseWithClient(client); err != nil {
+	port, err := startGitbaseWithClient(client)
+	if err != nil {
 		return err
 	}
 
-	connReady := logAfterTimeoutWithSpinner("waiting for gitbase to be ready", 5*time.Second, 0)
-	err = ensureConnReady(client)
-	connReady()
+	query, err := readQuery(args)
 	if err != nil {
-		return humanizef(err, "could not connect to gitbase")
+		return err
 	}
 
-	var query string
-	if c.Args.Query != "" {
-		query = strings.TrimSpace(c.Args.Query)
-	} else {
-		// Support piping
-		// TODO(@smacker): not the most optimal solution
-		// it would read all input into memory first and only then send to gitbase
-		// it must be possible to pipe and running mysql-cli with -B flag
-		// but it would change current client behaviour
-		fi, _ := os.Stdin.Stat()
-		if (fi.Mode() & os.ModeCharDevice) == 0 {
-			b, err := ioutil.ReadAll(os.Stdin)
-			if err != nil {
-				return humanizef(err, "could not read input")
-			}
-
-			query = string(b)
+	if c.Native {
+		if query != "" || c.Stats || c.Export != "" || c.Range != "" {
+			return fmt.Errorf("--native can't be used with a query, --stats, --export or --range")
+		}
+
+		return runSQLREPL(client, gitbaseDatabase, int64(sqlMaxRows()), true)
+	}
+
+	if err := c.validateQueryFlags(query); err != nil {
+		return err
+	}
+
+	if c.Range != "" {
+		if query, err = rangeQuery(query, c.Range); err != nil {
+			return err
+		}
+	}
+
+	// building an index can take longer than the connection to the daemon
+	// lasts, so it runs as a job
+	if api.IsCreateIndex(query) && c.Export == "" && daemon.Supports(api.FeatureJobs) {
+		return createIndex(client, query)
+	}
+
+	if query != "" {
+		// installing a driver may need to pull its image
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		err := checkUASTDrivers(ctx, client, query, c.InstallMissingDrivers)
+		cancel()
+		if err != nil {
+			return err
 		}
 	}
 
-	resp, exit, err := runMysqlCli(context.Background(), query)
+	if c.Export != "" {
+		return exportQuery(os.Stdout, client, query, report.Format(c.Export))
+	}
+
+	query = limitUnlimitedSelect(query)
+	before := c.statusBefore(client)
+
+	start := time.Now()
+	resp, exit, err := runMysqlCli(context.Background(), query, port, user, password)
 	if err != nil {
 		return humanizef(err, "could not run mysql client")
 	}
@@ -102,7 +150,11 @@ func (c *sqlCmd) Execute(args []string) error {
 	}()
 
 	if query != "" {
-		if _, err = io.Copy(os.Stdout, resp.Reader); err != nil {
+		stopProgress := showQueryProgress(client, query)
+		result := newResultCounter(resp.Reader)
+		err := c.printResult(result, stopProgress)
+		stopProgress()
+		if err != nil {
 			return err
 		}
 
@@ -111,96 +163,345 @@ func (c *sqlCmd) Execute(args []string) error {
 			return fmt.Errorf("MySQL exited with status %d", cd)
 		}
 
+		if c.Stats {
+			return c.printStats(client, before, &sqlStats{
+				rows:     result.Rows(),
+				bytes:    result.bytes,
+				duration: time.Since(start),
+			})
+		}
+
 		return nil
 	}
 
 	return attachStdio(resp)
 }
 
-func ensureConnReady(client api.EngineClient) error {
-	ctx := context.Background()
-
-	done := make(chan error)
-	globalTimeout := 5 * time.Minute
-	go func(ctx context.Context) {
-		queryTimeout := 1 * time.Second
-		sleep := 1 * time.Second
-		for {
-			err := pingDB(ctx, client, queryTimeout)
-			if err == nil {
-				break
-			}
-
-			time.Sleep(sleep)
-		}
+// executeConnection runs the query, or the interactive session of --native
+// if there is none, in the external server of --connection
+func (c *sqlCmd) executeConnection(args []string) error {
+	if c.Range != "" || c.InstallMissingDrivers {
+		return fmt.Errorf("--range and --install-missing-drivers can't be used with --connection")
+	}
 
-		done <- nil
-	}(ctx)
+	conn, err := config.File.SQLConnection(c.Connection)
+	if err != nil {
+		return err
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, globalTimeout)
-	defer cancel()
+	user, password, err := c.credentials(conn.User, conn.Password)
+	if err != nil {
+		return err
+	}
 
-	select {
-	case <-ctx.Done():
-		return fmt.Errorf("global timeout of %v exceeded", globalTimeout)
-	case <-done:
-		return nil
+	client, err := openConnection(conn, user, password)
+	if err != nil {
+		return err
 	}
+	defer client.Close()
+
+	query, err := readQuery(args)
+	if err != nil {
+		return err
+	}
+
+	if err := c.validateQueryFlags(query); err != nil {
+		return err
+	}
+
+	if query == "" {
+		return runSQLREPL(client, conn.Database, int64(sqlMaxRows()), false)
+	}
+
+	if c.Export != "" {
+		return exportQuery(os.Stdout, client, query, report.Format(c.Export))
+	}
+
+	query = limitUnlimitedSelect(query)
+	before := c.statusBefore(client)
+
+	start := time.Now()
+	columns, rows, _, err := queryRowsLimit(context.Background(), client, query, 0)
+	if err != nil {
+		return err
+	}
+
+	// the result is printed like the mysql client does
+	var buf bytes.Buffer
+	if len(columns) > 0 && len(rows) > 0 {
+		printSQLRows(&buf, columns, rows)
+	}
+
+	size := int64(buf.Len())
+	if err := c.printResult(&buf, func() {}); err != nil {
+		return err
+	}
+
+	if c.Stats {
+		return c.printStats(client, before, &sqlStats{
+			rows:     int64(len(rows)),
+			bytes:    size,
+			duration: time.Since(start),
+		})
+	}
+
+	return nil
 }
 
-func pingDB(ctx context.Context, client api.EngineClient, queryTimeoutSeconds time.Duration) error {
-	ctx, cancel := context.WithTimeout(ctx, queryTimeoutSeconds)
-	defer cancel()
+// readQuery returns the query given as the argument, or piped to the
+// standard input, or nothing for an interactive session
+func readQuery(args []string) (string, error) {
+	if len(args) == 1 {
+		return strings.TrimSpace(args[0]), nil
+	}
 
-	done := make(chan error)
-	go func(ctx context.Context, done chan error) {
-		stream, err := client.SQL(ctx, &api.SQLRequest{Query: "SELECT 1"})
+	// Support piping
+	// TODO(@smacker): not the most optimal solution
+	// it would read all input into memory first and only then send to gitbase
+	// it must be possible to pipe and running mysql-cli with -B flag
+	// but it would change current client behaviour
+	fi, _ := os.Stdin.Stat()
+	if (fi.Mode() & os.ModeCharDevice) == 0 {
+		b, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
-			done <- err
+			return "", humanizef(err, "could not read input")
 		}
 
-		_, err = stream.Recv()
+		return string(b), nil
+	}
+
+	return "", nil
+}
+
+// validateQueryFlags checks the flags that need a query
+func (c *sqlCmd) validateQueryFlags(query string) error {
+	if c.Stats && query == "" {
+		return fmt.Errorf("--stats can only be used when a query is given")
+	}
+
+	if c.Export != "" && (query == "" || c.Stats) {
+		return fmt.Errorf("--export can only be used when a query is given, and without --stats")
+	}
+
+	return nil
+}
+
+// sqlMaxRows returns sql.max_rows of the config file, or its default
+func sqlMaxRows() int {
+	conf := *config.File
+	conf.SetDefaults()
+	return conf.SQL.MaxRows
+}
+
+// limitUnlimitedSelect returns the query with a LIMIT of sql.max_rows if it is
+// a SELECT without one and the output is a terminal, warning about it
+func limitUnlimitedSelect(query string) string {
+	if !isUnlimitedSelect(query) {
+		return query
+	}
+
+	maxRows := -1
+	if stdoutIsTerminal() {
+		maxRows = sqlMaxRows()
+	}
+
+	query, limited := limitQuery(query, maxRows)
+	if limited {
+		log.Warningf("the query has no LIMIT clause, only the first %d rows "+
+			"are shown, change it with the sql.max_rows option", maxRows)
+	} else {
+		log.Warningf("the query has no LIMIT clause, " +
+			"it may return all the rows of large tables like files or commits")
+	}
+
+	return query
+}
+
+// statusBefore returns the status variables of the server before the query
+// with --stats, or nil
+func (c *sqlCmd) statusBefore(client api.EngineClient) map[string]int64 {
+	if !c.Stats {
+		return nil
+	}
+
+	before, err := gitbaseStatus(context.Background(), client)
+	if err != nil {
+		log.Debugf("could not read gitbase status: %s", err)
+	}
+
+	return before
+}
+
+// printStats prints the statistics of the query of --stats, with the status
+// variables that changed since before
+func (c *sqlCmd) printStats(client api.EngineClient, before map[string]int64, stats *sqlStats) error {
+	if before != nil {
+		after, err := gitbaseStatus(context.Background(), client)
 		if err != nil {
-			done <- err
+			log.Debugf("could not read gitbase status: %s", err)
+		} else {
+			stats.status = statusDiff(before, after)
 		}
+	}
 
-		done <- nil
-	}(ctx, done)
+	// the stats are kept apart from the query result
+	if isTextOutput() {
+		fmt.Fprintln(os.Stderr)
+	}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case err := <-done:
+	return render(os.Stderr, stats.output(), stats.Print)
+}
+
+// rangeQuery returns the query restricted to the commits of the range, in
+// the repositories of the working directory of the daemon
+func rangeQuery(query, rng string) (string, error) {
+	if query == "" {
+		return "", fmt.Errorf("--range can only be used when a query is given")
+	}
+
+	if daemon.IsRemote() {
+		return "", fmt.Errorf("--range needs the working directory, it can't be used with a remote daemon")
+	}
+
+	workdir, err := daemon.Workdir()
+	if err != nil {
+		return "", humanizef(err, "could not get the working directory")
+	}
+
+	r, err := resolveRange(workdir, rng)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debugf("the range %s has %d commits", rng, len(r.Commits))
+	return restrictQuery(query, r.Commits), nil
+}
+
+// credentials returns the user and password to connect to the server, from
+// the flags or the given ones of the config file. With --password the
+// password is read from the terminal, e.g. when the config of a remote daemon
+// is not available
+func (c *sqlCmd) credentials(user, password string) (string, string, error) {
+	if c.User != "" {
+		user = c.User
+	}
+
+	if !c.Password {
+		return user, password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", "", fmt.Errorf("--password needs a terminal to read the password")
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for %s: ", user)
+	b, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", "", humanizef(err, "could not read the password")
+	}
+
+	return user, string(b), nil
+}
+
+// printResult copies the result of the query to the standard output. When it
+// is a terminal the result is shown through the pager, unless --no-pager is
+// given or the pager can't be started. The started function is called once
+// the result starts arriving, before anything is printed
+func (c *sqlCmd) printResult(result io.Reader, started func()) error {
+	r := bufio.NewReader(result)
+	// the errors are returned by the copy below
+	r.Peek(1)
+	started()
+
+	if c.NoPager || !stdoutIsTerminal() {
+		_, err := io.Copy(os.Stdout, r)
 		return err
 	}
+
+	p, err := startPager(pagerCommand())
+	if err != nil {
+		log.Warningf("%s, printing the result without it", err)
+		_, err := io.Copy(os.Stdout, r)
+		return err
+	}
+
+	_, err = io.Copy(p, r)
+	if cerr := p.Close(); err == nil && cerr != nil {
+		err = errors.Wrap(cerr, "pager failed")
+	}
+
+	return err
+}
+
+// showQueryProgress shows a spinner with the elapsed time and the progress of
+// the query reported by gitbase, if it takes a while. It returns the function
+// to stop it, which can be called several times
+func showQueryProgress(client api.EngineClient, query string) func() {
+	p, stopPolling := watchQueryProgress(client, query)
+
+	d := newDefered(3*time.Second, "running query", nil, true, 0)
+	d.StatusFn = p.Status
+	cancel := d.Print()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			stopPolling()
+		})
+	}
 }
 
-func startGitbaseWithClient(client api.EngineClient) error {
-	started := logAfterTimeoutWithServerLogs("this is taking a while, "+
+// startGitbaseWithClient starts gitbase, waiting until it is ready, and
+// installs the mysql client image. It returns the public port of gitbase
+func startGitbaseWithClient(client api.EngineClient) (int, error) {
+	started := logAfterTimeoutWithEvents(client, "this is taking a while, "+
 		"if this is the first time you launch sql client, "+
 		"it might take a few more minutes while we install all the required images",
 		5*time.Second)
 	defer started()
 
 	// Download & run dependencies
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
-	_, err := client.StartComponent(ctx, &api.StartComponentRequest{
-		Name: components.Gitbase.Name,
-	})
+	ports, err := startDependencies(client, components.MysqlCli.Name)
 	if err != nil {
-		return humanizef(err, "could not start gitbase")
+		return 0, err
 	}
 
 	if err := docker.EnsureInstalled(components.MysqlCli.Image, components.MysqlCli.Version); err != nil {
-		return humanizef(err, "could not install mysql client")
+		return 0, humanizef(err, "could not install mysql client")
 	}
 
-	return nil
+	port := ports[components.Gitbase.Name]
+	if daemon.IsRemote() && port == 0 {
+		return 0, errGitbaseNotPublished
+	}
+
+	return port, nil
 }
 
-func runMysqlCli(ctx context.Context, query string, opts ...docker.ConfigOption) (*types.HijackedResponse, chan int64, error) {
+// errGitbaseNotPublished is returned when a client in this host needs the
+// gitbase port of a remote daemon, which only publishes it on request
+var errGitbaseNotPublished = fmt.Errorf("the remote daemon does not publish the gitbase port, " +
+	"run srcd init --publish=all on its host, or set components.gitbase.publish to all in its config file")
+
+// runMysqlCli runs the mysql client connected to gitbase with the given
+// credentials. When the daemon is remote the client can't use the internal
+// network, and it connects to the gitbase port published in the remote host
+func runMysqlCli(
+	ctx context.Context,
+	query string,
+	port int,
+	user, password string,
+	opts ...docker.ConfigOption,
+) (*types.HijackedResponse, chan int64, error) {
 	cmd := []string{"mysql", "-h", components.Gitbase.Name}
+	if daemon.IsRemote() {
+		cmd = []string{"mysql", "-h", daemon.Hostname(), "-P", strconv.Itoa(port)}
+	}
+
+	cmd = append(cmd, "-u", user)
 	if query != "" {
 		cmd = append(cmd, "-e", query)
 	}
@@ -209,6 +510,11 @@ func runMysqlCli(ctx context.Context, query string, opts ...docker.ConfigOption)
 		Image: components.MysqlCli.ImageWithVersion(),
 		Cmd:   cmd,
 	}
+	// the password is kept out of the command line, visible with ps
+	if password != "" {
+		opts = append(opts, docker.WithEnv("MYSQL_PWD", password))
+	}
+
 	host := &container.HostConfig{}
 	docker.ApplyOptions(config, host, opts...)
 
@@ -270,5 +576,8 @@ func stopMysqlClient() {
 }
 
 func init() {
-	rootCmd.AddCommand(&sqlCmd{})
+	c := rootCmd.AddCommand(&sqlCmd{}, func(c *flags.Command) {
+		c.SubcommandsOptional = true
+	})
+	c.AddCommand(&sqlConnectionInfoCmd{})
 }
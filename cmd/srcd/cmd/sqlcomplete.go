@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// schemaLoadTimeout is the maximum time to read the schema from gitbase
+const schemaLoadTimeout = time.Minute
+
+// schemaCache keeps the gitbase schema used by the interactive session. It is
+// loaded in the background, so the prompt is never blocked, and loaded again
+// once it is older than its ttl, or after it is invalidated
+type schemaCache struct {
+	load func(context.Context) (*gitbaseSchema, error)
+	ttl  time.Duration
+
+	mu     sync.Mutex
+	schema *gitbaseSchema
+	loaded time.Time
+	err    error
+	// loading is closed when the current load finishes, nil if there is none
+	loading chan struct{}
+	// generation is increased by each invalidation, so a load started before
+	// it doesn't make the schema fresh
+	generation int
+}
+
+func newSchemaCache(load func(context.Context) (*gitbaseSchema, error), ttl time.Duration) *schemaCache {
+	return &schemaCache{load: load, ttl: ttl}
+}
+
+// refresh starts loading the schema in the background, unless it is already
+// being loaded. The returned channel is closed when the load finishes
+func (c *schemaCache) refresh() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.refreshLocked()
+}
+
+func (c *schemaCache) refreshLocked() chan struct{} {
+	if c.loading != nil {
+		return c.loading
+	}
+
+	done := make(chan struct{})
+	c.loading = done
+	generation := c.generation
+	go func() {
+		defer close(done)
+
+		ctx, cancel := context.WithTimeout(context.Background(), schemaLoadTimeout)
+		schema, err := c.load(ctx)
+		cancel()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.loading = nil
+		c.err = err
+		if err != nil {
+			log.Debugf("could not load the gitbase schema: %s", err)
+			return
+		}
+
+		c.schema = schema
+		c.loaded = time.Now()
+		if generation != c.generation {
+			c.loaded = time.Time{}
+		}
+	}()
+
+	return done
+}
+
+// fresh returns whether the cached schema can be used without loading it
+// again
+func (c *schemaCache) fresh() bool {
+	return c.schema != nil && !c.loaded.IsZero() && time.Since(c.loaded) <= c.ttl
+}
+
+// get returns the cached schema, or nil if it was not loaded yet, without
+// waiting. A stale schema is loaded again in the background
+func (c *schemaCache) get() *gitbaseSchema {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fresh() {
+		c.refreshLocked()
+	}
+
+	return c.schema
+}
+
+// wait returns the schema, waiting for it to be loaded if it is stale
+func (c *schemaCache) wait(ctx context.Context) (*gitbaseSchema, error) {
+	c.mu.Lock()
+	if c.fresh() {
+		defer c.mu.Unlock()
+		return c.schema, nil
+	}
+
+	done := c.refreshLocked()
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return c.schema, nil
+}
+
+// invalidate marks the schema as stale, after a statement that may change
+// it, and loads it again in the background
+func (c *schemaCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.loaded = time.Time{}
+	if c.loading == nil {
+		c.refreshLocked()
+	}
+}
+
+// schemaChangeRegexp matches the statements that may change the schema
+var schemaChangeRegexp = regexp.MustCompile(`(?i)^\s*(create|drop|alter|rename)\b`)
+
+// completionKeywords are the SQL keywords completed in the interactive session
+var completionKeywords = []string{
+	"ALL", "AND", "AS", "ASC", "BETWEEN", "BY", "CASE", "COUNT", "CREATE",
+	"CROSS", "DESC", "DESCRIBE", "DISTINCT", "DROP", "ELSE", "END", "EXISTS",
+	"EXPLAIN", "FROM", "GROUP", "HAVING", "IN", "INDEX", "INNER", "IS", "JOIN",
+	"LEFT", "LIKE", "LIMIT", "NATURAL", "NOT", "NULL", "OFFSET", "ON", "OR",
+	"ORDER", "OUTER", "REGEXP", "RIGHT", "SELECT", "SHOW", "TABLES", "THEN",
+	"UNION", "USING", "VIEW", "WHEN", "WHERE",
+}
+
+// tableContextRegexp matches the text before a word that must be a table
+var tableContextRegexp = regexp.MustCompile(
+	`(?i)((\b(from|join|into|table|describe)|\\d)\s+|\bfrom\s+[a-z0-9_]+(\s+(as\s+)?[a-z0-9_]+)?(\s*,\s*[a-z0-9_]+(\s+(as\s+)?[a-z0-9_]+)?)*\s*,\s*)$`)
+
+// tableAliasRegexp matches the tables in the FROM and JOIN clauses of a
+// statement, and their alias
+var tableAliasRegexp = regexp.MustCompile(
+	`(?i)(?:\b(?:from|join)\s+|,\s*)([a-z_][a-z0-9_]*)(?:\s+(?:as\s+)?([a-z_][a-z0-9_]*))?`)
+
+// tableAliases returns the tables referenced by the statement, by their
+// lower case names and aliases
+func tableAliases(stmt string) map[string]string {
+	aliases := map[string]string{}
+	for _, m := range tableAliasRegexp.FindAllStringSubmatch(stmt, -1) {
+		table := strings.ToLower(m[1])
+		aliases[table] = table
+		if alias := strings.ToLower(m[2]); alias != "" && !sqlKeywords[alias] {
+			aliases[alias] = table
+		}
+	}
+
+	return aliases
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b == '.' ||
+		'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// sqlCompletions returns the start of the word that ends at pos in the
+// statement, and its completions: the columns of a table or alias for
+// qualified names, the tables where a table is expected, and otherwise the
+// keywords, tables, columns of the tables of the statement and functions.
+// The schema is nil if it is not loaded yet
+func sqlCompletions(schema *gitbaseSchema, stmt string, pos int) (int, []string) {
+	start := pos
+	for start > 0 && isWordByte(stmt[start-1]) {
+		start--
+	}
+
+	word := stmt[start:pos]
+	seen := map[string]bool{}
+	var candidates []string
+	add := func(s string) {
+		if hasPrefixFold(s, word) && !seen[s] {
+			seen[s] = true
+			candidates = append(candidates, s)
+		}
+	}
+
+	if i := strings.LastIndex(word, "."); i >= 0 {
+		if schema == nil {
+			return start, nil
+		}
+
+		qualifier := word[:i]
+		table := schema.table(qualifier)
+		if t, ok := tableAliases(stmt)[strings.ToLower(qualifier)]; ok {
+			table = schema.table(t)
+		}
+
+		if table != nil {
+			for _, c := range table.Columns {
+				add(qualifier + "." + c.Name)
+			}
+		}
+
+		sort.Strings(candidates)
+		return start, candidates
+	}
+
+	if tableContextRegexp.MatchString(stmt[:start]) {
+		if schema != nil {
+			for _, t := range schema.Tables {
+				add(t.Name)
+			}
+		}
+
+		sort.Strings(candidates)
+		return start, candidates
+	}
+
+	// the keywords follow the case of the statement
+	typed := strings.TrimSpace(stmt[:pos])
+	lower := typed != "" && strings.ToLower(typed) == typed
+	for _, k := range completionKeywords {
+		if lower {
+			k = strings.ToLower(k)
+		}
+
+		add(k)
+	}
+
+	if schema != nil {
+		var tables []*schemaTable
+		for _, t := range tableAliases(stmt) {
+			if table := schema.table(t); table != nil {
+				tables = append(tables, table)
+			}
+		}
+
+		// the columns of every table if the statement has none yet
+		all := len(tables) == 0
+		for i, t := range schema.Tables {
+			add(t.Name)
+			if all {
+				tables = append(tables, &schema.Tables[i])
+			}
+		}
+
+		for _, t := range tables {
+			for _, c := range t.Columns {
+				add(c.Name)
+			}
+		}
+
+		for _, f := range schema.Functions {
+			add(f.Name + "(")
+		}
+	}
+
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+// completeWord replaces the word between start and pos in the line with its
+// only candidate, or with the longest prefix shared by all of them. When
+// nothing can be completed the candidates are returned, to be listed
+func completeWord(line string, pos, start int, candidates []string) (string, int, []string) {
+	word := line[start:pos]
+	if len(candidates) == 0 {
+		return line, pos, nil
+	}
+
+	completion := candidates[0]
+	if len(candidates) == 1 {
+		if !strings.HasSuffix(completion, "(") {
+			completion += " "
+		}
+	} else {
+		for _, c := range candidates[1:] {
+			n := 0
+			for n < len(completion) && n < len(c) && strings.EqualFold(completion[n:n+1], c[n:n+1]) {
+				n++
+			}
+
+			completion = completion[:n]
+		}
+
+		if len(completion) <= len(word) {
+			return line, pos, candidates
+		}
+	}
+
+	return line[:start] + completion + line[pos:], start + len(completion), nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// gitbaseDatabase is the database of the repositories in gitbase
+const gitbaseDatabase = "gitbase"
+
+// odbcDriver is the name of the MySQL ODBC driver used in the connection
+// strings, gitbase talks the MySQL protocol
+const odbcDriver = "MySQL ODBC 8.0 Unicode Driver"
+
+// sqlConnectionInfoCmd represents the sql connection-info command
+type sqlConnectionInfoCmd struct {
+	Command `name:"connection-info" short-description:"Print the details to connect other SQL tools to gitbase" long-description:"Print the host, port, user, and the JDBC and ODBC connection strings to connect tools like Tableau, Metabase or DBeaver to gitbase. It starts gitbase if it is not running, and fails if its port is not published in the host, see components.gitbase.publish in the config file.\n\nThe password is never printed, nor written to the data source file."`
+
+	DSNFile string `long:"dsn-file" description:"Write an ODBC data source file to this path, e.g. gitbase.dsn, to import it in the tools"`
+}
+
+func (c *sqlConnectionInfoCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected nothing")
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	ports, err := startComponents(client, []string{components.Gitbase.Name}, false, nil)
+	if err != nil {
+		return err
+	}
+
+	host, port := "127.0.0.1", ports[components.Gitbase.Name]
+	if daemon.IsRemote() {
+		host = daemon.Hostname()
+	}
+
+	if port == 0 {
+		if daemon.IsRemote() {
+			return errGitbaseNotPublished
+		}
+
+		return fmt.Errorf("the gitbase port is not published, run srcd init --publish=localhost, " +
+			"or set components.gitbase.publish to localhost or all in the config file")
+	}
+
+	user, password := config.File.GitbaseCredentials()
+	out := newSQLConnectionOutput(host, port, user, password != "")
+
+	if c.DSNFile != "" {
+		if err := ioutil.WriteFile(c.DSNFile, []byte(out.dsn()), 0600); err != nil {
+			return humanizef(err, "could not write the data source file")
+		}
+
+		log.Infof("ODBC data source written to %s", c.DSNFile)
+	}
+
+	return render(os.Stdout, out, out.Print)
+}
+
+// sqlConnectionOutput is the schema of the srcd sql connection-info output
+type sqlConnectionOutput struct {
+	Host     string `json:"host" yaml:"host"`
+	Port     int    `json:"port" yaml:"port"`
+	User     string `json:"user" yaml:"user"`
+	Database string `json:"database" yaml:"database"`
+	// Password is whether the user has a password in the config file
+	Password bool   `json:"password" yaml:"password"`
+	MySQL    string `json:"mysql" yaml:"mysql"`
+	JDBC     string `json:"jdbc" yaml:"jdbc"`
+	ODBC     string `json:"odbc" yaml:"odbc"`
+}
+
+// newSQLConnectionOutput returns the details to connect to gitbase at the
+// given host and port. gitbase doesn't support TLS, so the JDBC URL disables
+// it, or the MySQL driver would fail or warn
+func newSQLConnectionOutput(host string, port int, user string, password bool) sqlConnectionOutput {
+	mysql := []string{"mysql", "-h", host, "-P", strconv.Itoa(port), "-u", user}
+	if password {
+		mysql = append(mysql, "-p")
+	}
+
+	return sqlConnectionOutput{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Database: gitbaseDatabase,
+		Password: password,
+		MySQL:    strings.Join(mysql, " "),
+		JDBC: fmt.Sprintf("jdbc:mysql://%s/%s?user=%s&useSSL=false",
+			net.JoinHostPort(host, strconv.Itoa(port)), gitbaseDatabase, user),
+		ODBC: fmt.Sprintf("DRIVER={%s};SERVER=%s;PORT=%d;DATABASE=%s;UID=%s;",
+			odbcDriver, host, port, gitbaseDatabase, user),
+	}
+}
+
+// dsn returns the ODBC data source file with the connection details
+func (o sqlConnectionOutput) dsn() string {
+	return fmt.Sprintf("[ODBC]\nDRIVER=%s\nSERVER=%s\nPORT=%d\nDATABASE=%s\nUID=%s\n",
+		odbcDriver, o.Host, o.Port, o.Database, o.User)
+}
+
+// Print writes the connection details as text
+func (o sqlConnectionOutput) Print(w io.Writer) error {
+	password := "none"
+	if o.Password {
+		password = "required, see components.gitbase.password in the config file"
+	}
+
+	t := NewTable("%s", "%v")
+	t.Row("host:", o.Host)
+	t.Row("port:", o.Port)
+	t.Row("user:", o.User)
+	t.Row("password:", password)
+	t.Row("database:", o.Database)
+	t.Row("mysql:", o.MySQL)
+	t.Row("JDBC URL:", o.JDBC)
+	t.Row("ODBC:", o.ODBC)
+
+	return t.Print(w)
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLConnectionOutput(t *testing.T) {
+	require := require.New(t)
+
+	out := newSQLConnectionOutput("127.0.0.1", 3306, "root", false)
+	require.Equal("mysql -h 127.0.0.1 -P 3306 -u root", out.MySQL)
+	require.Equal("jdbc:mysql://127.0.0.1:3306/gitbase?user=root&useSSL=false", out.JDBC)
+	require.Equal("DRIVER={MySQL ODBC 8.0 Unicode Driver};SERVER=127.0.0.1;PORT=3306;DATABASE=gitbase;UID=root;", out.ODBC)
+	require.Equal("[ODBC]\nDRIVER=MySQL ODBC 8.0 Unicode Driver\nSERVER=127.0.0.1\nPORT=3306\nDATABASE=gitbase\nUID=root\n", out.dsn())
+
+	out = newSQLConnectionOutput("::1", 3307, "analyst", true)
+	require.Equal("mysql -h ::1 -P 3307 -u analyst -p", out.MySQL)
+	require.Equal("jdbc:mysql://[::1]:3307/gitbase?user=analyst&useSSL=false", out.JDBC)
+
+	var buf bytes.Buffer
+	require.NoError(out.Print(&buf))
+	require.Contains(buf.String(), "port:        3307\n")
+	require.Contains(buf.String(), "password:    required")
+	require.NotContains(buf.String(), "%!")
+}
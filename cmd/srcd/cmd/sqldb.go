@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/src-d/engine/api"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// connectionClient runs the queries of srcd sql --connection in an external
+// MySQL server of sql.connections in the config file, instead of the gitbase
+// of the daemon, so the rest of the command works the same. Only its SQL
+// method can be used
+type connectionClient struct {
+	api.EngineClient
+	db *sql.DB
+}
+
+// openConnection returns the client of the external server with the given
+// credentials. No connection is made until the first query
+func openConnection(conn api.SQLConnection, user, password string) (*connectionClient, error) {
+	cfg := mysql.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(conn.Host, strconv.Itoa(conn.Port))
+	cfg.DBName = conn.Database
+	cfg.TLSConfig = conn.TLS
+	cfg.AllowNativePasswords = true
+	cfg.Timeout = 10 * time.Second
+	cfg.MaxAllowedPacket = 32 << 20 // 32 MiB
+
+	// the DSN would log the password
+	log.Debugf("connecting to mysql %s@%s", cfg.User, cfg.Addr)
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to the server")
+	}
+
+	return &connectionClient{db: db}, nil
+}
+
+// SQL runs the query in the server, streaming the column names and then each
+// row, like the daemon does
+func (c *connectionClient) SQL(
+	ctx context.Context,
+	in *api.SQLRequest,
+	opts ...grpc.CallOption,
+) (api.Engine_SQLClient, error) {
+	rows, err := c.db.QueryContext(ctx, in.Query)
+	if err != nil {
+		return nil, errors.Wrap(err, "SQL query failed")
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, errors.Wrap(err, "could not fetch columns")
+	}
+
+	return &connectionStream{rows: rows, columns: columns, maxRows: in.MaxRows}, nil
+}
+
+// Close closes the connections to the server
+func (c *connectionClient) Close() error {
+	return c.db.Close()
+}
+
+// connectionStream is the result of a query of a connectionClient
+type connectionStream struct {
+	grpc.ClientStream
+	rows    *sql.Rows
+	columns []string
+	maxRows int64
+	n       int64
+	started bool
+	done    bool
+}
+
+func (s *connectionStream) Recv() (*api.SQLResponse, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	if !s.started {
+		s.started = true
+		cells := make([][]byte, len(s.columns))
+		for i, c := range s.columns {
+			cells[i] = []byte(c)
+		}
+
+		return &api.SQLResponse{Row: &api.SQLResponse_Row{Cell: cells}}, nil
+	}
+
+	if !s.rows.Next() {
+		s.done = true
+		err := s.rows.Err()
+		s.rows.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "closing row iterator")
+		}
+
+		return nil, io.EOF
+	}
+
+	if s.maxRows > 0 && s.n == s.maxRows {
+		s.done = true
+		s.rows.Close()
+		return &api.SQLResponse{Truncated: true}, nil
+	}
+
+	values := make([]interface{}, len(s.columns))
+	for i := range values {
+		values[i] = new([]byte)
+	}
+
+	if err := s.rows.Scan(values...); err != nil {
+		s.done = true
+		s.rows.Close()
+		return nil, errors.Wrap(err, "could not scan row")
+	}
+
+	cells := make([][]byte, len(values))
+	for i, v := range values {
+		cells[i] = *v.(*[]byte)
+	}
+
+	s.n++
+	return &api.SQLResponse{Row: &api.SQLResponse_Row{Cell: cells}}, nil
+}
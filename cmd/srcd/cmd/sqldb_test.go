@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLConnectionFlags(t *testing.T) {
+	require := require.New(t)
+
+	old := *config.File
+	defer func() { *config.File = old }()
+	*config.File = api.Config{}
+
+	err := (&sqlCmd{Connection: "prod", Range: "HEAD~1..HEAD"}).Execute(nil)
+	require.EqualError(err, "--range and --install-missing-drivers can't be used with --connection")
+
+	err = (&sqlCmd{Connection: "prod"}).Execute(nil)
+	require.EqualError(err, `unknown connection "prod", add it to sql.connections in the config file`)
+
+	config.File.SQL.Connections = map[string]api.SQLConnection{"prod": {Port: 3306}}
+	err = (&sqlCmd{Connection: "prod"}).Execute(nil)
+	require.EqualError(err, "invalid sql.connections.prod: the host is required")
+}
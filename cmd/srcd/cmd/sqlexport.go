@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/report"
+)
+
+// exportQuery runs the query as a policy check, where each row returned is a
+// violation, and writes the result in the given format. The check fails, and
+// so does the command, if any row is returned
+func exportQuery(w io.Writer, client api.EngineClient, query string, format report.Format) error {
+	start := time.Now()
+	columns, rows, err := queryRows(context.Background(), client, query)
+	if err != nil {
+		return humanizef(err, "could not run the query")
+	}
+
+	r := queryReport(query, columns, rows, time.Since(start))
+	if err := report.Write(w, format, r); err != nil {
+		return humanizef(err, "could not write the %s report", format)
+	}
+
+	if len(rows) > 0 {
+		return fmt.Errorf("the query returned %d rows", len(rows))
+	}
+
+	return nil
+}
+
+// queryReport returns the report of a query run as a policy check, named
+// after the query
+func queryReport(query string, columns []string, rows [][]string, d time.Duration) *report.Report {
+	check := report.Check{
+		Name:     strings.Join(strings.Fields(query), " "),
+		Status:   report.Passed,
+		Duration: d,
+	}
+
+	if len(rows) > 0 {
+		check.Status = report.Failed
+		check.Message = fmt.Sprintf("the query returned %d rows", len(rows))
+		check.Findings = report.RowFindings(columns, rows)
+	}
+
+	return &report.Report{
+		Tool:    "srcd sql",
+		Version: version,
+		Checks:  []report.Check{check},
+	}
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryReport(t *testing.T) {
+	require := require.New(t)
+
+	r := queryReport("SELECT file_path\n  FROM files", []string{"file_path"}, nil, time.Second)
+	require.Len(r.Checks, 1)
+	require.Equal("SELECT file_path FROM files", r.Checks[0].Name)
+	require.Equal(report.Passed, r.Checks[0].Status)
+
+	r = queryReport("SELECT file_path FROM files", []string{"file_path"}, [][]string{{"a.go"}, {"b.go"}}, time.Second)
+	require.Equal(report.Failed, r.Checks[0].Status)
+	require.Equal("the query returned 2 rows", r.Checks[0].Message)
+	require.Len(r.Checks[0].Findings, 2)
+}
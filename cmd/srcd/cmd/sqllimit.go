@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	selectRegexp = regexp.MustCompile(`(?i)^\s*select\b`)
+	limitRegexp  = regexp.MustCompile(`(?i)\blimit\s+\d+`)
+)
+
+// isUnlimitedSelect returns whether the query is a SELECT without a LIMIT
+// clause, which may return all the rows of a large table
+func isUnlimitedSelect(query string) bool {
+	return selectRegexp.MatchString(query) && !limitRegexp.MatchString(query)
+}
+
+// limitQuery appends a LIMIT of maxRows to the query, if it is a SELECT
+// without one and maxRows is positive. It returns whether it was added
+func limitQuery(query string, maxRows int) (string, bool) {
+	if maxRows <= 0 || !isUnlimitedSelect(query) {
+		return query, false
+	}
+
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	return fmt.Sprintf("%s LIMIT %d", query, maxRows), true
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsUnlimitedSelect(t *testing.T) {
+	cases := map[string]bool{
+		"SELECT * FROM files":                        true,
+		"  select repository_id FROM repositories":   true,
+		"SELECT * FROM commits LIMIT 10":             false,
+		"select * from commits limit 5 offset 2":     false,
+		"SELECT * FROM refs WHERE ref_name = 'HEAD'": true,
+		"SHOW TABLES":          false,
+		"DESCRIBE TABLE files": false,
+		"selection":            false,
+	}
+
+	for query, expected := range cases {
+		require.Equal(t, expected, isUnlimitedSelect(query), query)
+	}
+}
+
+func TestLimitQuery(t *testing.T) {
+	require := require.New(t)
+
+	q, ok := limitQuery("SELECT * FROM files;\n", 100)
+	require.True(ok)
+	require.Equal("SELECT * FROM files LIMIT 100", q)
+
+	q, ok = limitQuery("SELECT * FROM files LIMIT 5", 100)
+	require.False(ok)
+	require.Equal("SELECT * FROM files LIMIT 5", q)
+
+	q, ok = limitQuery("SELECT * FROM files", -1)
+	require.False(ok)
+	require.Equal("SELECT * FROM files", q)
+
+	q, ok = limitQuery("SHOW TABLES", 100)
+	require.False(ok)
+	require.Equal("SHOW TABLES", q)
+}
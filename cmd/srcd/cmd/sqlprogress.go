@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// queryProgressInterval is the interval between the polls of the gitbase
+// process list
+const queryProgressInterval = time.Second
+
+// queryProgress tracks a query running in gitbase, polling the process list
+// through the daemon to read its progress
+type queryProgress struct {
+	client api.EngineClient
+	query  string
+	start  time.Time
+
+	mu    sync.Mutex
+	state string
+}
+
+// watchQueryProgress starts polling the progress of the query until stop is
+// called. The progress is read from the State column of SHOW PROCESSLIST,
+// where gitbase reports the partitions of each table already processed
+func watchQueryProgress(client api.EngineClient, query string) (*queryProgress, func()) {
+	p := &queryProgress{
+		client: client,
+		query:  strings.TrimSpace(query),
+		start:  time.Now(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(queryProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.poll(ctx)
+			}
+		}
+	}()
+
+	return p, func() {
+		cancel()
+		<-done
+	}
+}
+
+func (p *queryProgress) poll(ctx context.Context) {
+	columns, rows, err := queryRows(ctx, p.client, "SHOW PROCESSLIST")
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Debugf("could not read gitbase process list: %s", err)
+		}
+
+		return
+	}
+
+	state, ok := processState(columns, rows, p.query)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	p.state = state
+	p.mu.Unlock()
+}
+
+// Status returns the elapsed time and the last progress reported by gitbase
+func (p *queryProgress) Status() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := time.Since(p.start).Round(time.Second)
+	if p.state == "" {
+		return elapsed.String()
+	}
+
+	return fmt.Sprintf("%s, %s", elapsed, p.state)
+}
+
+// processState returns the State of the process running the query in the
+// SHOW PROCESSLIST result, and whether it was found
+func processState(columns []string, rows [][]string, query string) (string, bool) {
+	info, state := -1, -1
+	for i, c := range columns {
+		switch strings.ToLower(c) {
+		case "info":
+			info = i
+		case "state":
+			state = i
+		}
+	}
+
+	if info < 0 || state < 0 {
+		return "", false
+	}
+
+	for _, row := range rows {
+		if len(row) <= info || len(row) <= state {
+			continue
+		}
+
+		if strings.TrimSpace(row[info]) == query {
+			return row[state], true
+		}
+	}
+
+	return "", false
+}
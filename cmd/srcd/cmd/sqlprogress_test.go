@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessState(t *testing.T) {
+	require := require.New(t)
+
+	columns := []string{"Id", "User", "Host", "db", "Command", "Time", "State", "Info"}
+	rows := [][]string{
+		{"1", "root", "", "gitbase", "Query", "0", "running", "SHOW PROCESSLIST"},
+		{"2", "root", "", "gitbase", "Query", "12", "commits(3/10)", "SELECT * FROM commits"},
+	}
+
+	state, ok := processState(columns, rows, "SELECT * FROM commits")
+	require.True(ok)
+	require.Equal("commits(3/10)", state)
+
+	_, ok = processState(columns, rows, "SELECT * FROM files")
+	require.False(ok)
+
+	_, ok = processState([]string{"Id"}, [][]string{{"1"}}, "SELECT 1")
+	require.False(ok)
+}
+
+func TestQueryProgressStatus(t *testing.T) {
+	require := require.New(t)
+
+	p := &queryProgress{start: time.Now().Add(-5 * time.Second)}
+	require.Equal("5s", p.Status())
+
+	p.state = "commits(3/10)"
+	require.Equal("5s, commits(3/10)", p.Status())
+}
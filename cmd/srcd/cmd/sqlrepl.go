@@ -0,0 +1,488 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/src-d/engine/api"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	sqlPrompt             = "gitbase> "
+	sqlContinuationPrompt = "      -> "
+	// schemaCacheTTL is how long the schema used to complete the tables and
+	// columns is kept, it only changes with new indexes or views
+	schemaCacheTTL = 5 * time.Minute
+	// maxListedCompletions is the maximum number of completions listed
+	maxListedCompletions = 100
+	keyCtrlC             = 3
+)
+
+// sqlREPL is the interactive session of srcd sql --native. The statements are
+// run by the daemon, or the server of --connection, and the tables and
+// columns are completed with tab from the schema read from the server
+type sqlREPL struct {
+	client  api.EngineClient
+	term    *terminal.Terminal
+	out     io.Writer
+	schema  *schemaCache
+	maxRows int64
+	// checkDrivers is whether the bblfsh drivers used by the statements are
+	// checked, only for the gitbase of the daemon
+	checkDrivers bool
+	// pending are the lines of the statement being written, until one ends
+	// with a semicolon
+	pending string
+	// last is the last statement run, run again by \g
+	last string
+	// timing is whether the time of each statement is printed
+	timing bool
+	// output is the file the results are written to with \o, nil for the
+	// terminal
+	output *os.File
+}
+
+// sqlMetaCommands are the commands of the interactive session, and their help
+var sqlMetaCommands = [][2]string{
+	{`\d [table]`, "describe the columns and indexes of a table, or list the tables"},
+	{`\l`, "list the tables"},
+	{`\timing [on|off]`, "toggle printing the time of each statement"},
+	{`\o [file]`, "write the results to a file, or to the terminal without it"},
+	{`\g`, "run the statement being written, or the last one again"},
+	{`\?`, "show this help"},
+	{`\q`, "quit"},
+}
+
+func newSQLREPL(client api.EngineClient, rw io.ReadWriter, database string, maxRows int64) *sqlREPL {
+	r := &sqlREPL{
+		client:  client,
+		maxRows: maxRows,
+		timing:  true,
+		schema: newSchemaCache(func(ctx context.Context) (*gitbaseSchema, error) {
+			return readGitbaseSchema(ctx, client, database)
+		}, schemaCacheTTL),
+	}
+
+	r.term = terminal.NewTerminal(rw, sqlPrompt)
+	r.term.AutoCompleteCallback = r.autoComplete
+	r.out = r.term
+	return r
+}
+
+// runSQLREPL runs the interactive session in the terminal of the standard
+// input and output until it is closed. The schema is read from the given
+// database, and the drivers are only checked if checkDrivers is true
+func runSQLREPL(client api.EngineClient, database string, maxRows int64, checkDrivers bool) error {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) || !stdoutIsTerminal() {
+		return fmt.Errorf("--native needs a terminal, pipe the query to srcd sql instead")
+	}
+
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return humanizef(err, "could not set up the terminal")
+	}
+	defer terminal.Restore(fd, state)
+
+	r := newSQLREPL(client, struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, database, maxRows)
+	r.checkDrivers = checkDrivers
+	if width, height, err := terminal.GetSize(fd); err == nil {
+		r.term.SetSize(width, height)
+	}
+
+	return r.run()
+}
+
+func (r *sqlREPL) run() error {
+	defer r.setOutput("")
+
+	r.schema.refresh()
+	fmt.Fprintln(r.out, `Type \? for the commands, and \q or Ctrl-D to quit. `+
+		`Tab completes the tables and columns.`)
+
+	for {
+		line, err := r.term.ReadLine()
+		if err == io.EOF {
+			fmt.Fprintln(r.out)
+			return nil
+		}
+
+		if err != nil && err != terminal.ErrPasteIndicator {
+			return err
+		}
+
+		if r.handleLine(line) {
+			return nil
+		}
+	}
+}
+
+// handleLine runs the line if it is a meta-command, even in the middle of a
+// statement, or adds it to the statement, which is run once a line ends with
+// a semicolon. It returns whether the session has to end
+func (r *sqlREPL) handleLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, `\`) {
+		return r.metaCommand(trimmed)
+	}
+
+	if r.pending == "" {
+		switch strings.ToLower(strings.TrimSuffix(trimmed, ";")) {
+		case "":
+			return false
+		case "quit", "exit":
+			return true
+		}
+	}
+
+	r.pending += line + "\n"
+	if !strings.HasSuffix(trimmed, ";") {
+		r.term.SetPrompt(sqlContinuationPrompt)
+		return false
+	}
+
+	r.executePending()
+	return false
+}
+
+// executePending runs the statement being written
+func (r *sqlREPL) executePending() {
+	query := strings.TrimSuffix(strings.TrimSpace(r.pending), ";")
+	r.pending = ""
+	r.term.SetPrompt(sqlPrompt)
+	r.last = query
+	r.execute(query)
+}
+
+// metaCommand runs a command starting with a backslash, returning whether
+// the session has to end
+func (r *sqlREPL) metaCommand(line string) bool {
+	fields := strings.Fields(strings.TrimSuffix(line, ";"))
+	args := fields[1:]
+	switch fields[0] {
+	case `\q`:
+		return true
+	case `\d`:
+		if len(args) > 1 {
+			r.printError(fmt.Errorf(`usage: \d [table]`))
+		} else if len(args) == 1 {
+			r.describe(args[0])
+		} else {
+			r.listTables()
+		}
+	case `\l`:
+		r.listTables()
+	case `\timing`:
+		switch {
+		case len(args) == 0:
+			r.timing = !r.timing
+		case len(args) == 1 && (args[0] == "on" || args[0] == "off"):
+			r.timing = args[0] == "on"
+		default:
+			r.printError(fmt.Errorf(`usage: \timing [on|off]`))
+			return false
+		}
+
+		state := "off"
+		if r.timing {
+			state = "on"
+		}
+
+		fmt.Fprintf(r.out, "Timing is %s.\n\n", state)
+	case `\o`:
+		if len(args) > 1 {
+			r.printError(fmt.Errorf(`usage: \o [file]`))
+			return false
+		}
+
+		var path string
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		if err := r.setOutput(path); err != nil {
+			r.printError(err)
+		}
+	case `\g`:
+		if r.pending != "" {
+			r.executePending()
+		} else if r.last != "" {
+			r.execute(r.last)
+		} else {
+			r.printError(fmt.Errorf("there is no statement to run"))
+		}
+	case `\?`:
+		t := NewTable("%s", "%s")
+		for _, c := range sqlMetaCommands {
+			t.Row(c[0], c[1])
+		}
+
+		t.Print(r.out)
+		fmt.Fprintln(r.out)
+	default:
+		r.printError(fmt.Errorf(`unknown command %s, type \? for the list of commands`, fields[0]))
+	}
+
+	return false
+}
+
+// setOutput writes the results to the file with the given path, truncating
+// it, or to the terminal if it is empty, closing the previous file
+func (r *sqlREPL) setOutput(path string) error {
+	if r.output != nil {
+		if err := r.output.Close(); err != nil {
+			log.Warningf("could not close %s: %s", r.output.Name(), err)
+		}
+
+		r.output = nil
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return humanizef(err, "could not create the output file")
+	}
+
+	r.output = f
+	return nil
+}
+
+// result returns where the results are written, the output file or the
+// terminal
+func (r *sqlREPL) result() io.Writer {
+	if r.output != nil {
+		return r.output
+	}
+
+	return r.out
+}
+
+// elapsed returns the time since start to print after a result, or nothing
+// if timing is off
+func (r *sqlREPL) elapsed(start time.Time) string {
+	if !r.timing {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%.2f sec)", time.Since(start).Seconds())
+}
+
+// execute runs the query and prints its result
+func (r *sqlREPL) execute(query string) {
+	ctx := context.Background()
+	if r.checkDrivers {
+		if err := checkUASTDrivers(ctx, r.client, query, false); err != nil {
+			r.printError(err)
+			return
+		}
+	}
+
+	start := time.Now()
+	columns, rows, truncated, err := queryRowsLimit(ctx, r.client, query, r.maxRows)
+	if err != nil {
+		r.printError(err)
+		return
+	}
+
+	if schemaChangeRegexp.MatchString(query) {
+		r.schema.invalidate()
+	}
+
+	w := r.result()
+	switch {
+	case len(columns) == 0:
+		fmt.Fprintf(w, "Query OK%s\n", r.elapsed(start))
+	case len(rows) == 0:
+		fmt.Fprintf(w, "Empty set%s\n", r.elapsed(start))
+	default:
+		printSQLRows(w, columns, rows)
+		fmt.Fprintf(w, "%d rows in set%s\n", len(rows), r.elapsed(start))
+	}
+
+	if truncated {
+		fmt.Fprintf(w, "only the first %d rows are shown, "+
+			"change it with the sql.max_rows option\n", r.maxRows)
+	}
+
+	fmt.Fprintln(w)
+}
+
+// listTables prints the tables of the schema, with their number of columns
+// and indexes
+func (r *sqlREPL) listTables() {
+	schema, ok := r.waitSchema()
+	if !ok {
+		return
+	}
+
+	rows := make([][]string, len(schema.Tables))
+	for i, t := range schema.Tables {
+		rows[i] = []string{t.Name, strconv.Itoa(len(t.Columns)), strconv.Itoa(len(t.Indexes))}
+	}
+
+	w := r.result()
+	printSQLRows(w, []string{"Table", "Columns", "Indexes"}, rows)
+	fmt.Fprintln(w)
+}
+
+// waitSchema returns the schema, waiting for it to be loaded, or prints the
+// error if it can't be
+func (r *sqlREPL) waitSchema() (*gitbaseSchema, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), schemaLoadTimeout)
+	defer cancel()
+
+	schema, err := r.schema.wait(ctx)
+	if err != nil {
+		r.printError(humanizef(err, "could not read the gitbase schema"))
+		return nil, false
+	}
+
+	return schema, true
+}
+
+// describe prints the columns and indexes of the table
+func (r *sqlREPL) describe(name string) {
+	schema, ok := r.waitSchema()
+	if !ok {
+		return
+	}
+
+	t := schema.table(name)
+	if t == nil {
+		r.printError(fmt.Errorf("table %s does not exist", name))
+		return
+	}
+
+	rows := make([][]string, len(t.Columns))
+	for i, c := range t.Columns {
+		nullable := "NO"
+		if c.Nullable {
+			nullable = "YES"
+		}
+
+		rows[i] = []string{c.Name, c.Type, nullable}
+	}
+
+	w := r.result()
+	printSQLRows(w, []string{"Column", "Type", "Nullable"}, rows)
+	if len(t.Indexes) > 0 {
+		fmt.Fprintln(w, "Indexes:")
+		for _, i := range t.Indexes {
+			fmt.Fprintf(w, "    %s (%s): %s\n", i.Name, i.Type, strings.Join(i.Columns, ", "))
+		}
+	}
+
+	fmt.Fprintln(w)
+}
+
+func (r *sqlREPL) printError(err error) {
+	fmt.Fprintf(r.out, "ERROR: %s\n\n", humanize(err))
+}
+
+// autoComplete is called by the terminal for each key. Tab completes the
+// word before the cursor, and Ctrl-C discards the statement being written
+func (r *sqlREPL) autoComplete(line string, pos int, key rune) (string, int, bool) {
+	switch key {
+	case keyCtrlC:
+		prompt := sqlPrompt
+		if r.pending != "" {
+			prompt = sqlContinuationPrompt
+		}
+
+		r.pending = ""
+		r.term.SetPrompt(sqlPrompt)
+		fmt.Fprintf(r.out, "%s%s^C\n", prompt, line)
+		return "", 0, true
+	case '\t':
+	default:
+		return "", 0, false
+	}
+
+	offset := len(r.pending)
+	stmt := r.pending + line
+	start, candidates := sqlCompletions(r.schema.get(), stmt, offset+pos)
+	stmt, pos, list := completeWord(stmt, offset+pos, start, candidates)
+	if len(list) > 0 {
+		fmt.Fprintln(r.out, formatCompletions(list))
+	}
+
+	return stmt[offset:], pos - offset, true
+}
+
+// formatCompletions returns the list of completions shown when the word is
+// ambiguous
+func formatCompletions(list []string) string {
+	if len(list) > maxListedCompletions {
+		return fmt.Sprintf("%s ... and %d more",
+			strings.Join(list[:maxListedCompletions], "  "), len(list)-maxListedCompletions)
+	}
+
+	return strings.Join(list, "  ")
+}
+
+// printSQLRows prints the rows as a table with borders, like the mysql client
+func printSQLRows(w io.Writer, columns []string, rows [][]string) {
+	escape := strings.NewReplacer("\r", `\r`, "\n", `\n`, "\t", `\t`)
+	widths := make([]int, len(columns))
+	for i, c := range columns {
+		widths[i] = utf8.RuneCountInString(c)
+	}
+
+	cells := make([][]string, len(rows))
+	for i, row := range rows {
+		cells[i] = make([]string, len(columns))
+		for j := range columns {
+			if j < len(row) {
+				cells[i][j] = escape.Replace(row[j])
+			}
+
+			if n := utf8.RuneCountInString(cells[i][j]); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	var b strings.Builder
+	border := func() {
+		for _, width := range widths {
+			b.WriteString("+" + strings.Repeat("-", width+2))
+		}
+
+		b.WriteString("+\n")
+	}
+
+	line := func(row []string) {
+		for i, c := range row {
+			pad := widths[i] - utf8.RuneCountInString(c)
+			b.WriteString("| " + c + strings.Repeat(" ", pad) + " ")
+		}
+
+		b.WriteString("|\n")
+	}
+
+	border()
+	line(columns)
+	border()
+	for _, row := range cells {
+		line(row)
+	}
+
+	border()
+
+	io.WriteString(w, b.String())
+}
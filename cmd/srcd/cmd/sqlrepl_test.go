@@ -0,0 +1,322 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/api"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// sqlClient returns the results of the known queries, where the first row
+// are the column names, and an error for the rest
+type sqlClient struct {
+	api.EngineClient
+	results map[string][][]string
+	queries []string
+}
+
+func (c *sqlClient) SQL(
+	ctx context.Context,
+	in *api.SQLRequest,
+	opts ...grpc.CallOption,
+) (api.Engine_SQLClient, error) {
+	c.queries = append(c.queries, in.Query)
+	result, ok := c.results[in.Query]
+	if !ok {
+		return nil, fmt.Errorf("unknown query %s", in.Query)
+	}
+
+	resp := &api.SQLResponse{}
+	for _, row := range result {
+		r := &api.SQLResponse_Row{}
+		for _, c := range row {
+			r.Cell = append(r.Cell, []byte(c))
+		}
+
+		resp.Rows = append(resp.Rows, r)
+	}
+
+	return &sqlStream{responses: []*api.SQLResponse{resp}}, nil
+}
+
+type sqlStream struct {
+	grpc.ClientStream
+	responses []*api.SQLResponse
+}
+
+func (s *sqlStream) Recv() (*api.SQLResponse, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+var testSchema = &gitbaseSchema{
+	Database: "gitbase",
+	Tables: []schemaTable{
+		{Name: "commits", Columns: []schemaColumn{
+			{Name: "commit_hash", Type: "TEXT"},
+			{Name: "commit_message", Type: "TEXT"},
+			{Name: "committer_name", Type: "TEXT"},
+		}},
+		{Name: "refs", Columns: []schemaColumn{
+			{Name: "ref_name", Type: "TEXT"},
+			{Name: "commit_hash", Type: "TEXT"},
+		}},
+		{Name: "repositories", Columns: []schemaColumn{
+			{Name: "repository_id", Type: "TEXT"},
+		}},
+	},
+	Functions: []schemaFunction{{Name: "is_remote"}, {Name: "is_tag"}},
+}
+
+func TestSQLCompletions(t *testing.T) {
+	require := require.New(t)
+
+	cases := []struct {
+		stmt     string
+		word     string
+		expected []string
+	}{
+		{"SELECT * FROM re", "re", []string{"refs", "repositories"}},
+		{"select * from refs, c", "c", []string{"commits"}},
+		{`\d co`, "co", []string{"commits"}},
+		{"SELECT c.commit_m", "c.commit_m", nil},
+		{"SELECT c.commit_m FROM commits c", "c.commit_m", []string{"c.commit_message"}},
+		{"SELECT r. FROM refs AS r", "r.", []string{"r.commit_hash", "r.ref_name"}},
+		{"SELECT refs.r FROM refs", "refs.r", []string{"refs.ref_name"}},
+		{"SELECT * FROM refs WHERE r", "r", []string{"REGEXP", "RIGHT", "ref_name", "refs", "repositories"}},
+		{"select * from refs where r", "r", []string{"ref_name", "refs", "regexp", "repositories", "right"}},
+		{"SELECT * FROM refs WHERE is_", "is_", []string{"is_remote(", "is_tag("}},
+		{"SELECT commit_", "commit_", []string{"commit_hash", "commit_message"}},
+		{"SELECT r", "r", []string{"REGEXP", "RIGHT", "ref_name", "refs", "repositories", "repository_id"}},
+		{"SELECT * FROM refs WHERE ref_", "ref_", []string{"ref_name"}},
+	}
+
+	for _, c := range cases {
+		start, candidates := sqlCompletions(testSchema, c.stmt, strings.LastIndex(c.stmt, c.word)+len(c.word))
+		require.Equal(strings.LastIndex(c.stmt, c.word), start, c.stmt)
+		require.Equal(c.expected, candidates, c.stmt)
+	}
+
+	// only the keywords before the schema is loaded
+	_, candidates := sqlCompletions(nil, "SEL", 3)
+	require.Equal([]string{"SELECT"}, candidates)
+	_, candidates = sqlCompletions(nil, "SELECT * FROM re", 16)
+	require.Empty(candidates)
+}
+
+func TestCompleteWord(t *testing.T) {
+	require := require.New(t)
+
+	line, pos, list := completeWord("SELECT * FROM com WHERE", 17, 14, []string{"commits"})
+	require.Equal("SELECT * FROM commits  WHERE", line)
+	require.Equal(22, pos)
+	require.Nil(list)
+
+	line, pos, list = completeWord("SELECT is_", 10, 7, []string{"is_remote("})
+	require.Equal("SELECT is_remote(", line)
+	require.Equal(17, pos)
+	require.Nil(list)
+
+	line, pos, list = completeWord("SELECT commit_h", 15, 7, []string{"commit_hash", "commit_hashes"})
+	require.Equal("SELECT commit_hash", line)
+	require.Equal(18, pos)
+	require.Nil(list)
+
+	line, pos, list = completeWord("SELECT re", 9, 7, []string{"refs", "repositories"})
+	require.Equal("SELECT re", line)
+	require.Equal(9, pos)
+	require.Equal([]string{"refs", "repositories"}, list)
+
+	line, pos, list = completeWord("SELECT x", 8, 7, nil)
+	require.Equal("SELECT x", line)
+	require.Equal(8, pos)
+	require.Nil(list)
+}
+
+func TestSchemaCache(t *testing.T) {
+	require := require.New(t)
+
+	var loads int32
+	release := make(chan struct{})
+	cache := newSchemaCache(func(ctx context.Context) (*gitbaseSchema, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return testSchema, nil
+	}, time.Hour)
+
+	// the schema is loaded in the background
+	require.Nil(cache.get())
+	require.Nil(cache.get())
+	close(release)
+
+	s, err := cache.wait(context.Background())
+	require.NoError(err)
+	require.Equal(testSchema, s)
+	require.Equal(testSchema, cache.get())
+	require.Equal(int32(1), atomic.LoadInt32(&loads))
+
+	// the stale schema is kept until it is loaded again
+	cache.invalidate()
+	require.Equal(testSchema, cache.get())
+	_, err = cache.wait(context.Background())
+	require.NoError(err)
+	require.Equal(int32(2), atomic.LoadInt32(&loads))
+
+	failing := newSchemaCache(func(ctx context.Context) (*gitbaseSchema, error) {
+		return nil, fmt.Errorf("gitbase is not running")
+	}, time.Hour)
+	_, err = failing.wait(context.Background())
+	require.EqualError(err, "gitbase is not running")
+}
+
+func TestSQLREPLHandleLine(t *testing.T) {
+	require := require.New(t)
+
+	client := &sqlClient{results: map[string][][]string{
+		"SELECT ref_name, commit_hash\nFROM refs": {
+			{"ref_name", "commit_hash"},
+			{"HEAD", "a1"},
+			{"refs/heads/master", "a1"},
+		},
+		"SELECT * FROM refs WHERE 1 = 0": {{"ref_name"}},
+		"SELECT * FROM information_schema.columns WHERE table_schema = 'gitbase'": {
+			{"TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION", "IS_NULLABLE", "COLUMN_TYPE"},
+			{"refs", "ref_name", "1", "NO", "TEXT"},
+			{"refs", "commit_hash", "2", "YES", "TEXT"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	r := newSQLREPL(client, &bytes.Buffer{}, gitbaseDatabase, 0)
+	r.out = &buf
+
+	require.False(r.handleLine("SELECT ref_name, commit_hash"))
+	require.Empty(buf.String())
+	require.False(r.handleLine("FROM refs;"))
+	require.Contains(buf.String(), "+-------------------+-------------+\n"+
+		"| ref_name          | commit_hash |\n"+
+		"+-------------------+-------------+\n"+
+		"| HEAD              | a1          |\n"+
+		"| refs/heads/master | a1          |\n"+
+		"+-------------------+-------------+\n"+
+		"2 rows in set (")
+
+	buf.Reset()
+	require.False(r.handleLine("SELECT * FROM refs WHERE 1 = 0;"))
+	require.Contains(buf.String(), "Empty set")
+
+	buf.Reset()
+	require.False(r.handleLine("SELECT * FROM missing;"))
+	require.Equal("ERROR: unknown query SELECT * FROM missing\n\n", buf.String())
+
+	buf.Reset()
+	require.False(r.handleLine(`\d refs`))
+	require.Equal("+-------------+------+----------+\n"+
+		"| Column      | Type | Nullable |\n"+
+		"+-------------+------+----------+\n"+
+		"| ref_name    | TEXT | NO       |\n"+
+		"| commit_hash | TEXT | YES      |\n"+
+		"+-------------+------+----------+\n\n", buf.String())
+
+	buf.Reset()
+	require.False(r.handleLine(`\d missing`))
+	require.Equal("ERROR: table missing does not exist\n\n", buf.String())
+
+	buf.Reset()
+	require.False(r.handleLine(`\x`))
+	require.Equal("ERROR: unknown command \\x, type \\? for the list of commands\n\n", buf.String())
+
+	require.True(r.handleLine(`\q`))
+	require.True(r.handleLine("exit"))
+}
+
+func TestSQLREPLMetaCommands(t *testing.T) {
+	require := require.New(t)
+
+	client := &sqlClient{results: map[string][][]string{
+		"SELECT 1": {{"1"}, {"1"}},
+		"SELECT * FROM information_schema.columns WHERE table_schema = 'gitbase'": {
+			{"TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION", "IS_NULLABLE", "COLUMN_TYPE"},
+			{"refs", "ref_name", "1", "NO", "TEXT"},
+			{"refs", "commit_hash", "2", "YES", "TEXT"},
+			{"repositories", "repository_id", "1", "NO", "TEXT"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	r := newSQLREPL(client, &bytes.Buffer{}, gitbaseDatabase, 0)
+	r.out = &buf
+
+	tables := "+--------------+---------+---------+\n" +
+		"| Table        | Columns | Indexes |\n" +
+		"+--------------+---------+---------+\n" +
+		"| refs         | 2       | 0       |\n" +
+		"| repositories | 1       | 0       |\n" +
+		"+--------------+---------+---------+\n\n"
+	require.False(r.handleLine(`\l`))
+	require.Equal(tables, buf.String())
+
+	buf.Reset()
+	require.False(r.handleLine(`\d`))
+	require.Equal(tables, buf.String())
+
+	buf.Reset()
+	require.False(r.handleLine(`\g`))
+	require.Equal("ERROR: there is no statement to run\n\n", buf.String())
+
+	buf.Reset()
+	require.False(r.handleLine(`\timing off`))
+	require.Equal("Timing is off.\n\n", buf.String())
+	require.False(r.handleLine(`\timing`))
+	require.True(r.timing)
+	require.False(r.handleLine(`\timing`))
+	require.False(r.timing)
+	require.False(r.handleLine(`\timing maybe`))
+	require.False(r.timing)
+
+	result := "+---+\n| 1 |\n+---+\n| 1 |\n+---+\n1 rows in set\n\n"
+	buf.Reset()
+	require.False(r.handleLine("SELECT 1"))
+	require.False(r.handleLine(`\g`))
+	require.Equal(result, buf.String())
+
+	buf.Reset()
+	require.False(r.handleLine(`\g`))
+	require.Equal(result, buf.String())
+	require.Equal([]string{"SELECT 1", "SELECT 1"}, client.queries[len(client.queries)-2:])
+
+	dir, err := ioutil.TempDir("", "srcd-repl")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.txt")
+	buf.Reset()
+	require.False(r.handleLine(`\o ` + path))
+	require.False(r.handleLine("SELECT 1;"))
+	require.False(r.handleLine(`\o`))
+	require.Empty(buf.String())
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	require.Equal(result, string(b))
+
+	require.False(r.handleLine(`\o ` + filepath.Join(dir, "missing", "out.txt")))
+	require.Contains(buf.String(), "ERROR: could not create the output file")
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/src-d/engine/api"
+)
+
+// resultCounter counts the bytes and the table rows of the mysql client
+// output read through it
+type resultCounter struct {
+	r          io.Reader
+	bytes      int64
+	midLine    bool
+	tableLine  bool
+	tableLines int64
+}
+
+func newResultCounter(r io.Reader) *resultCounter {
+	return &resultCounter{r: r}
+}
+
+func (c *resultCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for _, b := range p[:n] {
+		if !c.midLine {
+			c.midLine = true
+			c.tableLine = b == '|'
+		}
+
+		if b == '\n' {
+			c.midLine = false
+			if c.tableLine {
+				c.tableLines++
+			}
+		}
+	}
+
+	c.bytes += int64(n)
+	return n, err
+}
+
+// Rows returns the number of rows of the result table, which are the lines
+// starting with | except the one with the column names
+func (c *resultCounter) Rows() int64 {
+	if c.tableLines == 0 {
+		return 0
+	}
+
+	return c.tableLines - 1
+}
+
+// gitbaseStatus returns the numeric status variables of gitbase, by name
+func gitbaseStatus(ctx context.Context, client api.EngineClient) (map[string]int64, error) {
+	_, rows, err := queryRows(ctx, client, "SHOW STATUS")
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]int64)
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+
+		v, err := strconv.ParseInt(row[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		status[row[0]] = v
+	}
+
+	return status, nil
+}
+
+// queryRows runs a query through the daemon, returning the column names and
+// the rows. It must be used only for queries with small results, like the
+// status ones
+func queryRows(ctx context.Context, client api.EngineClient, query string) ([]string, [][]string, error) {
+	columns, rows, _, err := queryRowsLimit(ctx, client, query, 0)
+	return columns, rows, err
+}
+
+// queryRowsLimit runs a query like queryRows, reading only the given number
+// of rows, or all of them if it is 0. It also returns whether the result had
+// more rows
+func queryRowsLimit(ctx context.Context, client api.EngineClient, query string, maxRows int64) ([]string, [][]string, bool, error) {
+	stream, err := client.SQL(ctx, &api.SQLRequest{Query: query, MaxRows: maxRows})
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var columns []string
+	var rows [][]string
+	var truncated bool
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return columns, rows, truncated, nil
+		}
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		truncated = truncated || resp.Truncated
+		batch := resp.Rows
+		if resp.Row != nil {
+			batch = append(batch, resp.Row)
+		}
+
+		for _, r := range batch {
+			row := make([]string, len(r.Cell))
+			for i, c := range r.Cell {
+				row[i] = string(c)
+			}
+
+			if columns == nil {
+				columns = row
+				continue
+			}
+
+			rows = append(rows, row)
+		}
+	}
+}
+
+// sqlStats are the statistics of a query printed with srcd sql --stats
+type sqlStats struct {
+	rows     int64
+	bytes    int64
+	duration time.Duration
+	// status are the gitbase status variables that changed during the query
+	status map[string]int64
+}
+
+// statusDiff returns the variables whose value changed between before and
+// after, with the difference
+func statusDiff(before, after map[string]int64) map[string]int64 {
+	diff := make(map[string]int64)
+	for name, v := range after {
+		if d := v - before[name]; d != 0 {
+			diff[name] = d
+		}
+	}
+
+	return diff
+}
+
+// sqlStatsOutput is the schema of the srcd sql --stats output. Gitbase holds
+// the status variables that changed during the query
+type sqlStatsOutput struct {
+	Rows    int64            `json:"rows" yaml:"rows"`
+	Bytes   int64            `json:"bytes" yaml:"bytes"`
+	TimeMS  int64            `json:"time_ms" yaml:"time_ms"`
+	Gitbase map[string]int64 `json:"gitbase" yaml:"gitbase"`
+}
+
+func (s *sqlStats) output() sqlStatsOutput {
+	status := s.status
+	if status == nil {
+		status = map[string]int64{}
+	}
+
+	return sqlStatsOutput{
+		Rows:    s.rows,
+		Bytes:   s.bytes,
+		TimeMS:  int64(s.duration / time.Millisecond),
+		Gitbase: status,
+	}
+}
+
+func (s *sqlStats) Print(w io.Writer) error {
+	t := NewTable("%s", "%v")
+	t.Row("rows", s.rows)
+	t.Row("bytes", s.bytes)
+	t.Row("time", s.duration.Round(time.Millisecond))
+
+	var names []string
+	for name := range s.status {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Row(fmt.Sprintf("gitbase %s", name), s.status[name])
+	}
+
+	return t.Print(w)
+}
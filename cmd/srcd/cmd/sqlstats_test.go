@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCounter(t *testing.T) {
+	require := require.New(t)
+
+	output := "+----+------+\r\n" +
+		"| id | name |\r\n" +
+		"+----+------+\r\n" +
+		"|  1 | a    |\r\n" +
+		"|  2 | b    |\r\n" +
+		"+----+------+\r\n"
+
+	c := newResultCounter(strings.NewReader(output))
+	b, err := ioutil.ReadAll(c)
+	require.NoError(err)
+	require.Equal(output, string(b))
+	require.Equal(int64(len(output)), c.bytes)
+	require.Equal(int64(2), c.Rows())
+
+	c = newResultCounter(strings.NewReader(""))
+	_, err = ioutil.ReadAll(c)
+	require.NoError(err)
+	require.Equal(int64(0), c.Rows())
+}
+
+func TestStatusDiff(t *testing.T) {
+	before := map[string]int64{"a": 1, "b": 2}
+	after := map[string]int64{"a": 1, "b": 5, "c": 3}
+
+	require.Equal(t, map[string]int64{"b": 3, "c": 3}, statusDiff(before, after))
+}
+
+func TestSQLStatsPrint(t *testing.T) {
+	s := &sqlStats{
+		rows:     2,
+		bytes:    100,
+		duration: 1500 * time.Millisecond,
+		status:   map[string]int64{"Queries": 1},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, s.Print(&buf))
+	require.Equal(t, "rows               2\n"+
+		"bytes              100\n"+
+		"time               1.5s\n"+
+		"gitbase Queries    1\n", buf.String())
+}
+
+func TestSQLStatsOutput(t *testing.T) {
+	s := &sqlStats{
+		rows:     2,
+		bytes:    100,
+		duration: 1500 * time.Millisecond,
+	}
+
+	require.Equal(t, sqlStatsOutput{
+		Rows:    2,
+		Bytes:   100,
+		TimeMS:  1500,
+		Gitbase: map[string]int64{},
+	}, s.output())
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/src-d/engine/api"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// uastFuncRegexp matches the calls to the gitbase UAST functions
+var uastFuncRegexp = regexp.MustCompile(`(?i)\b(uast|uast_mode|uast_xpath|uast_extract|uast_children)\s*\(`)
+
+// uastFuncArgs are the number of arguments, minimum and maximum, of each
+// gitbase UAST function
+var uastFuncArgs = map[string][2]int{
+	"uast":          {1, 3},
+	"uast_mode":     {3, 3},
+	"uast_xpath":    {2, 2},
+	"uast_extract":  {2, 2},
+	"uast_children": {1, 1},
+}
+
+// uastCall is a call to a gitbase UAST function in a query
+type uastCall struct {
+	name string
+	args []string
+}
+
+// parseUASTCalls returns the calls to the gitbase UAST functions in the
+// query, with their arguments as they are written
+func parseUASTCalls(query string) []uastCall {
+	var calls []uastCall
+	for _, m := range uastFuncRegexp.FindAllStringSubmatchIndex(query, -1) {
+		name := strings.ToLower(query[m[2]:m[3]])
+		args, ok := splitArgs(query[m[1]:])
+		if !ok {
+			continue
+		}
+
+		calls = append(calls, uastCall{name: name, args: args})
+	}
+
+	return calls
+}
+
+// splitArgs splits the arguments of a function call, s being the text after
+// its opening parenthesis. It returns false if the call is not closed
+func splitArgs(s string) ([]string, bool) {
+	var args []string
+	var quote rune
+	depth, start := 0, 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == ')':
+			if arg := strings.TrimSpace(s[start:i]); arg != "" || len(args) > 0 {
+				args = append(args, arg)
+			}
+
+			return args, true
+		case r == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	return nil, false
+}
+
+// stringLiteral returns the value of a SQL string literal, and whether arg is
+// one
+func stringLiteral(arg string) (string, bool) {
+	if len(arg) < 2 {
+		return "", false
+	}
+
+	q := arg[0]
+	if (q != '\'' && q != '"') || arg[len(arg)-1] != q {
+		return "", false
+	}
+
+	return arg[1 : len(arg)-1], true
+}
+
+// validateUASTCalls checks the number of arguments of the calls and the
+// literal UAST modes, returning the languages given as literals, which need
+// their bblfsh driver installed. The languages computed in the query, like
+// with LANGUAGE(file_path), can't be known in advance
+func validateUASTCalls(calls []uastCall) ([]string, error) {
+	langs := make(map[string]bool)
+	for _, c := range calls {
+		n := uastFuncArgs[c.name]
+		if len(c.args) < n[0] || len(c.args) > n[1] {
+			if n[0] == n[1] {
+				return nil, fmt.Errorf("%s expects %d arguments, got %d", c.name, n[0], len(c.args))
+			}
+
+			return nil, fmt.Errorf("%s expects from %d to %d arguments, got %d",
+				c.name, n[0], n[1], len(c.args))
+		}
+
+		var lang string
+		switch c.name {
+		case "uast":
+			if len(c.args) > 1 {
+				lang = c.args[1]
+			}
+		case "uast_mode":
+			if mode, ok := stringLiteral(c.args[0]); ok {
+				if _, err := parseModeArg(mode); err != nil {
+					return nil, err
+				}
+			}
+
+			lang = c.args[2]
+		}
+
+		if l, ok := stringLiteral(lang); ok && l != "" {
+			langs[strings.ToLower(l)] = true
+		}
+	}
+
+	var list []string
+	for l := range langs {
+		list = append(list, l)
+	}
+	sort.Strings(list)
+
+	return list, nil
+}
+
+// checkUASTDrivers validates the calls to the gitbase UAST functions in the
+// query and checks that the drivers of the languages they use are installed.
+// The missing drivers are installed if install is true
+func checkUASTDrivers(ctx context.Context, client api.EngineClient, query string, install bool) error {
+	calls := parseUASTCalls(query)
+	if len(calls) == 0 {
+		return nil
+	}
+
+	langs, err := validateUASTCalls(calls)
+	if err != nil {
+		return err
+	}
+
+	if len(langs) == 0 {
+		return nil
+	}
+
+	res, err := client.ListDrivers(ctx, &api.ListDriversRequest{})
+	if err != nil {
+		return humanizef(err, "could not list drivers")
+	}
+
+	installed := make(map[string]bool)
+	for _, d := range res.Drivers {
+		installed[strings.ToLower(d.Lang)] = true
+	}
+
+	var missing []string
+	for _, l := range langs {
+		if !installed[l] {
+			missing = append(missing, l)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if !install {
+		return fmt.Errorf("the query parses %s, but the bblfsh driver is not installed, "+
+			"use --install-missing-drivers to install it", strings.Join(missing, ", "))
+	}
+
+	for _, l := range missing {
+		log.Infof("installing the bblfsh driver of %s", l)
+		if err := installDriver(ctx, client, l); err != nil {
+			return humanizef(err, "could not install the bblfsh driver of %s", l)
+		}
+	}
+
+	return nil
+}
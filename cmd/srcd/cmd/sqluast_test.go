@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUASTCalls(t *testing.T) {
+	require := require.New(t)
+
+	query := `SELECT UAST(blob_content, 'Go', '//uast:Identifier'),
+		uast_mode('annotated', blob_content, LANGUAGE(file_path, blob_content)),
+		uast_xpath(uast(blob_content), '(//x)')
+		FROM files`
+
+	calls := parseUASTCalls(query)
+	require.Equal([]uastCall{
+		{name: "uast", args: []string{"blob_content", "'Go'", "'//uast:Identifier'"}},
+		{name: "uast_mode", args: []string{"'annotated'", "blob_content", "LANGUAGE(file_path, blob_content)"}},
+		{name: "uast_xpath", args: []string{"uast(blob_content)", "'(//x)'"}},
+		{name: "uast", args: []string{"blob_content"}},
+	}, calls)
+
+	require.Empty(parseUASTCalls("SELECT * FROM files"))
+	require.Empty(parseUASTCalls("SELECT uast_children("))
+}
+
+func TestValidateUASTCalls(t *testing.T) {
+	require := require.New(t)
+
+	langs, err := validateUASTCalls(parseUASTCalls(`SELECT
+		uast(blob_content, 'Go'), uast(blob_content, "python"),
+		uast_mode('semantic', blob_content, 'go'),
+		uast(blob_content, LANGUAGE(file_path))
+		FROM files`))
+	require.NoError(err)
+	require.Equal([]string{"go", "python"}, langs)
+
+	_, err = validateUASTCalls(parseUASTCalls("SELECT uast_mode('full', blob_content, 'go')"))
+	require.EqualError(err, "incorrect UAST mode 'full'. Allowed values: semantic, annotated, native")
+
+	_, err = validateUASTCalls(parseUASTCalls("SELECT uast_xpath(uast(blob_content))"))
+	require.EqualError(err, "uast_xpath expects 2 arguments, got 1")
+
+	_, err = validateUASTCalls(parseUASTCalls("SELECT uast()"))
+	require.EqualError(err, "uast expects from 1 to 3 arguments, got 0")
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/src-d/engine/components"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// startCmd represents the start command
+type startCmd struct {
+	Command `name:"start" short-description:"Starts the containers stopped with srcd stop --pause" long-description:"Starts again the containers stopped with srcd stop --pause, keeping their state and configuration. Use srcd init to start the engine from scratch."`
+}
+
+func (c *startCmd) Execute(args []string) error {
+	started, err := components.Resume(context.Background())
+	if err != nil {
+		return humanizef(err, "could not start containers")
+	}
+
+	if len(started) == 0 {
+		return fmt.Errorf("there are no paused containers, use srcd init to start the engine")
+	}
+
+	log.Infof("started %d containers", len(started))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(&startCmd{})
+}
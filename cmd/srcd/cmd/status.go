@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	units "github.com/docker/go-units"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// statusCmd represents the status command
+type statusCmd struct {
+	Command `name:"status" short-description:"Show the containers and volumes of the engine" long-description:"Show the containers of the engine, with their health and how many times docker restarted them, and its volumes, with the disk space used by each one. It warns about the gitbase index volumes above the quota set in components.gitbase.index_quota and the unhealthy containers, and explains why the exited containers stopped, e.g. when the kernel OOM killer killed them"`
+}
+
+func (c *statusCmd) Execute(args []string) error {
+	if err := config.Read(c.Config); err != nil {
+		return humanizef(err, "could not read config")
+	}
+
+	quota, err := config.File.GitbaseIndexQuota()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	res, err := components.ListResources(ctx, false)
+	if err != nil {
+		return humanizef(err, "could not get the status")
+	}
+
+	ins := inspectContainers(ctx, res.Containers)
+	out := statusOutput{
+		Containers: newContainersOutput(res.Containers, ins),
+		Volumes:    newVolumesOutput(res.Volumes),
+	}
+
+	err = render(os.Stdout, out, func(w io.Writer) error {
+		if err := printContainers(w, res.Containers, ins); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w)
+		return printVolumes(w, res.Volumes)
+	})
+	if err != nil {
+		return err
+	}
+
+	warnContainers(res.Containers, ins)
+
+	if quota > 0 {
+		for _, v := range res.Volumes {
+			size := docker.VolumeSize(v)
+			if strings.HasPrefix(v.Name, components.GitbaseIndexVolumePrefix()) && size > quota {
+				log.Warningf("gitbase index volume %s uses %s, above the quota of %s",
+					v.Name, sizeFmt(size), sizeFmt(quota))
+			}
+		}
+	}
+
+	return nil
+}
+
+// inspectContainers returns the inspections of the given containers by name,
+// skipping the ones that can't be inspected
+func inspectContainers(ctx context.Context, cs []docker.Container) map[string]*docker.Inspection {
+	ins := make(map[string]*docker.Inspection, len(cs))
+	for _, c := range cs {
+		name := strings.TrimLeft(c.Names[0], "/")
+		in, err := docker.Inspect(ctx, name)
+		if err != nil {
+			log.Debugf("could not inspect %s: %s", name, err)
+			continue
+		}
+
+		ins[name] = in
+	}
+
+	return ins
+}
+
+// warnContainers logs the unhealthy containers, and why the exited ones
+// stopped unless it was on purpose, see components.ExitReason
+func warnContainers(cs []docker.Container, ins map[string]*docker.Inspection) {
+	for _, c := range cs {
+		in, ok := ins[strings.TrimLeft(c.Names[0], "/")]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case in.State == "exited":
+			if reason, ok := components.ExitReason(in.Exit); ok {
+				log.Warningf("%s", reason)
+			}
+		case in.Health == "unhealthy":
+			log.Warningf("%s is unhealthy, its docker health check fails", in.Name)
+		}
+	}
+}
+
+// statusOutput is the schema of the srcd status output
+type statusOutput struct {
+	Containers []containerOutput `json:"containers" yaml:"containers"`
+	Volumes    []volumeOutput    `json:"volumes" yaml:"volumes"`
+}
+
+// printContainers prints the table of the given containers. If ins is not
+// nil, the health and the restart count of their inspections by name are
+// printed too
+func printContainers(w io.Writer, cs []docker.Container, ins map[string]*docker.Inspection) error {
+	if ins == nil {
+		t := NewTable("%s", "%s", "%s", "%v")
+		t.Header("CONTAINER NAME", "IMAGE", "STATE", "PORT")
+		for _, c := range cs {
+			t.Row(
+				strings.TrimLeft(c.Names[0], "/"),
+				c.Image,
+				c.State,
+				publicPortsFmt(c.Ports, nil),
+			)
+		}
+
+		return t.Print(w)
+	}
+
+	t := NewTable("%s", "%s", "%s", "%s", "%s", "%v")
+	t.Header("CONTAINER NAME", "IMAGE", "STATE", "HEALTH", "RESTARTS", "PORT")
+	for _, c := range cs {
+		name := strings.TrimLeft(c.Names[0], "/")
+		health, restarts := "-", "-"
+		if in, ok := ins[name]; ok {
+			restarts = strconv.Itoa(in.RestartCount)
+			if in.Health != "" {
+				health = in.Health
+			}
+		}
+
+		t.Row(name, c.Image, c.State, health, restarts, publicPortsFmt(c.Ports, nil))
+	}
+
+	return t.Print(w)
+}
+
+func printVolumes(w io.Writer, vs []*docker.Volume) error {
+	var total int64
+	t := NewTable("%s", "%s")
+	t.Header("VOLUME NAME", "SIZE")
+	for _, v := range vs {
+		size := docker.VolumeSize(v)
+		if size > 0 {
+			total += size
+		}
+
+		t.Row(v.Name, sizeFmt(size))
+	}
+
+	t.Row("TOTAL", sizeFmt(total))
+	return t.Print(w)
+}
+
+// sizeFmt formats a size in bytes, or returns ? if it is negative, as
+// docker.VolumeSize does for the sizes it can't compute
+func sizeFmt(size int64) string {
+	if size < 0 {
+		return "?"
+	}
+
+	return units.HumanSize(float64(size))
+}
+
+func init() {
+	rootCmd.AddCommand(&statusCmd{})
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeFmt(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("?", sizeFmt(-1))
+	assert.Equal("0B", sizeFmt(0))
+	assert.Equal("1.5GB", sizeFmt(1500*1000*1000))
+}
@@ -1,22 +1,127 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"gopkg.in/src-d/go-log.v1"
 )
 
 // stopCmd represents the stop command
 type stopCmd struct {
-	Command `name:"stop" short-description:"Stops all containers" long-description:"Stops all containers"`
+	Command `name:"stop" short-description:"Stops all containers or the given components" long-description:"Stops all containers, or only the containers of the given components. It warns when the components that depend on a stopped one keep running"`
+
+	All     bool           `long:"all" description:"Stop the daemon and all the components, the default when no component is given"`
+	Pause   bool           `long:"pause" description:"Keep the containers after stopping them, so srcd start can restart them with their state"`
+	Timeout *time.Duration `long:"timeout" description:"Grace period given to each container to exit before it is killed, 0 kills them right away. By default each component uses its own, e.g. gitbase is given more time to flush its indexes"`
+
+	Args struct {
+		Components []componentArg `positional-arg-name:"component(s)"`
+	} `positional-args:"yes"`
 }
 
 func (c *stopCmd) Execute(args []string) error {
-	if err := components.Stop(); err != nil {
+	timeout, err := c.timeout()
+	if err != nil {
+		return err
+	}
+
+	names := componentArgs(c.Args.Components)
+	if c.All && len(names) > 0 {
+		return fmt.Errorf("--all can't be used with components")
+	}
+
+	if len(names) > 0 {
+		return c.stopComponents(names, timeout)
+	}
+
+	if c.Pause {
+		if err := components.Pause(timeout); err != nil {
+			return humanizef(err, "could not pause containers")
+		}
+
+		return nil
+	}
+
+	if err := components.Stop(timeout); err != nil {
 		return humanizef(err, "could not stop containers")
 	}
 
 	return nil
 }
 
+// timeout returns the grace period given with --timeout, or
+// components.ComponentGracePeriod if it was not given
+func (c *stopCmd) timeout() (time.Duration, error) {
+	if c.Timeout == nil {
+		return components.ComponentGracePeriod, nil
+	}
+
+	if *c.Timeout < 0 {
+		return 0, fmt.Errorf("the timeout can't be negative")
+	}
+
+	return *c.Timeout, nil
+}
+
+// stopComponents stops the components with the given container or image
+// names, warning about the running ones that depend on them
+func (c *stopCmd) stopComponents(names []string, timeout time.Duration) error {
+	cmps, err := selectUpgradable(names)
+	if err != nil {
+		return err
+	}
+
+	for _, cmp := range cmps {
+		if dependents := runningDependents(cmp.Name, cmps); len(dependents) > 0 {
+			log.Warningf("%s depends on %s, it won't work until %s is started again",
+				strings.Join(dependents, ", "), cmp.Name, cmp.Name)
+		}
+	}
+
+	if err := components.StopComponents(cmps, timeout, c.Pause); err != nil {
+		return humanizef(err, "could not stop components")
+	}
+
+	return nil
+}
+
+// runningDependents returns the container names of the running components
+// that depend on the one with the given name, except the stopped ones
+func runningDependents(name string, stopped []components.Component) []string {
+	var names []string
+	for _, dep := range components.Dependents(name) {
+		if containsComponent(stopped, dep.Name) {
+			continue
+		}
+
+		running, err := docker.IsRunning(dep.Name, "")
+		if err != nil {
+			log.Debugf("could not check if %s is running: %s", dep.Name, err)
+		}
+
+		if running {
+			names = append(names, dep.Name)
+		}
+	}
+
+	return names
+}
+
+func containsComponent(cmps []components.Component, name string) bool {
+	for _, c := range cmps {
+		if c.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func init() {
 	rootCmd.AddCommand(&stopCmd{})
 }
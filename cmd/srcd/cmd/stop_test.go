@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/components"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopTimeout(t *testing.T) {
+	require := require.New(t)
+
+	timeout, err := (&stopCmd{}).timeout()
+	require.NoError(err)
+	require.Equal(components.ComponentGracePeriod, timeout)
+
+	for _, d := range []time.Duration{0, 2 * time.Minute} {
+		timeout, err := (&stopCmd{Timeout: &d}).timeout()
+		require.NoError(err)
+		require.Equal(d, timeout)
+	}
+
+	negative := -time.Second
+	_, err = (&stopCmd{Timeout: &negative}).timeout()
+	require.EqualError(err, "the timeout can't be negative")
+}
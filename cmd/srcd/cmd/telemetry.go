@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/cmd/srcd/telemetry"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	flags "github.com/jessevdk/go-flags"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/src-d/go-cli.v0"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// telemetryInfo explains the telemetry before asking to enable it
+const telemetryInfo = `Help us improve srcd by sending anonymous usage metrics: the commands you
+run, the versions of the components and the kind of errors found. No
+repository data, queries or paths are ever sent. You can change your choice
+at any time with srcd telemetry enable or srcd telemetry disable.
+`
+
+const telemetryPrompt = telemetryInfo + "Enable telemetry? [y/N]: "
+
+// configFile returns the --config flag value, used by withTelemetry to read
+// the config of any command
+func (c Command) configFile() string {
+	return c.Config
+}
+
+type configFiler interface {
+	configFile() string
+}
+
+// newTelemetry returns a telemetry client that keeps its files in
+// $HOME/.srcd, using the telemetry section of the given config file
+func newTelemetry(configFile string) (*telemetry.Client, error) {
+	conf, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not detect home directory")
+	}
+
+	return telemetry.New(filepath.Join(home, ".srcd"),
+		conf.Telemetry.Enabled, conf.Telemetry.Endpoint)
+}
+
+// withTelemetry runs the command, asking the user to enable telemetry the
+// first time, and records its usage if it is enabled. Telemetry failures are
+// never reported to the user
+func withTelemetry(cmd flags.Commander, run func() error) error {
+	name := activeCommand()
+	// srcd uninstall removes the files of the telemetry too
+	if name == "telemetry" || strings.HasPrefix(name, "telemetry ") || name == "uninstall" {
+		return run()
+	}
+
+	var configFile string
+	if c, ok := cmd.(configFiler); ok {
+		configFile = c.configFile()
+	}
+
+	client, err := newTelemetry(configFile)
+	if err != nil {
+		log.Debugf("telemetry is not available: %s", err)
+		return run()
+	}
+
+	// srcd setup asks it with the rest of its questions
+	if !client.Asked() && isInteractive() && name != "setup" {
+		askTelemetry(client)
+	}
+
+	start := time.Now()
+	err = run()
+
+	e := telemetry.Event{
+		Time:       start,
+		Command:    name,
+		Version:    version,
+		OS:         runtime.GOOS + "/" + runtime.GOARCH,
+		Components: componentVersions(),
+		Error:      errorCategory(err),
+		Duration:   int64(time.Since(start) / time.Millisecond),
+	}
+
+	if err := client.Record(e); err != nil {
+		log.Debugf("could not record telemetry event: %s", err)
+	} else if err := client.Flush(context.Background()); err != nil {
+		log.Debugf("could not send telemetry events: %s", err)
+	}
+
+	return err
+}
+
+// activeCommand returns the full name of the command being run, e.g.
+// "components list"
+func activeCommand() string {
+	var names []string
+	for c := rootCmd.Parser.Active; c != nil; c = c.Active {
+		names = append(names, c.Name)
+	}
+
+	return strings.Join(names, " ")
+}
+
+func isInteractive() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd())) &&
+		terminal.IsTerminal(int(os.Stderr.Fd()))
+}
+
+func askTelemetry(client *telemetry.Client) {
+	fmt.Fprint(os.Stderr, telemetryPrompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	fmt.Fprintln(os.Stderr)
+
+	if err := client.SetEnabled(answer == "y" || answer == "yes"); err != nil {
+		log.Debugf("could not save telemetry choice: %s", err)
+	}
+}
+
+func componentVersions() map[string]string {
+	versions := make(map[string]string)
+	for _, c := range []components.Component{
+		components.Gitbase,
+		components.GitbaseWeb,
+		components.Bblfshd,
+		components.BblfshWeb,
+		components.Search,
+		components.Analytics,
+		components.Notebook,
+		components.Spark,
+	} {
+		versions[c.Name] = c.Version
+	}
+
+	return versions
+}
+
+// errorCategory returns the kind of the error, without any detail that could
+// identify the user
+func errorCategory(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	switch cause := errors.Cause(err); cause {
+	case docker.ErrDaemonNotRunning:
+		return "docker-not-running"
+	case docker.ErrIncompatibleAPIVersion:
+		return "docker-incompatible-api"
+	case docker.ErrImageNotFound:
+		return "image-not-found"
+	case docker.ErrPlatformNotSupported:
+		return "platform-not-supported"
+	case docker.ErrUnsignedImage:
+		return "unsigned-image"
+	case docker.ErrPathNotShared:
+		return "path-not-shared"
+	case docker.ErrPortInUse:
+		return "port-in-use"
+	default:
+		if _, ok := cause.(*daemon.UnreachableErr); ok {
+			return "daemon-unreachable"
+		}
+
+		return "other"
+	}
+}
+
+// telemetryCmd represents the telemetry command
+type telemetryCmd struct {
+	cli.PlainCommand `name:"telemetry" short-description:"Manage the anonymous usage metrics" long-description:"Show and change whether srcd sends anonymous usage metrics: the commands run, the versions of the components and the kind of errors found. They help to decide which features to prioritize"`
+}
+
+// telemetryStatusCmd represents the telemetry status command
+type telemetryStatusCmd struct {
+	Command `name:"status" short-description:"Show whether telemetry is enabled" long-description:"Show whether telemetry is enabled, the anonymous identifier and the events not sent yet"`
+}
+
+func (c *telemetryStatusCmd) Execute(args []string) error {
+	client, err := newTelemetry(c.Config)
+	if err != nil {
+		return humanizef(err, "could not read telemetry settings")
+	}
+
+	status := "disabled"
+	if client.Enabled() {
+		status = "enabled"
+	}
+
+	switch {
+	case client.Configured():
+		status += " (set in the config file)"
+	case !client.Asked():
+		status += " (not asked yet)"
+	}
+
+	fmt.Printf("telemetry: %s\n", status)
+	if !client.Enabled() {
+		return nil
+	}
+
+	events, err := client.Pending()
+	if err != nil {
+		return humanizef(err, "could not read telemetry events")
+	}
+
+	fmt.Printf("id: %s\n", client.ID())
+	fmt.Printf("endpoint: %s\n", client.Endpoint())
+	fmt.Printf("pending events: %d\n", len(events))
+	return nil
+}
+
+// telemetryEnableCmd represents the telemetry enable command
+type telemetryEnableCmd struct {
+	Command `name:"enable" short-description:"Enable telemetry" long-description:"Enable sending anonymous usage metrics"`
+}
+
+func (c *telemetryEnableCmd) Execute(args []string) error {
+	return setTelemetry(c.Config, true)
+}
+
+// telemetryDisableCmd represents the telemetry disable command
+type telemetryDisableCmd struct {
+	Command `name:"disable" short-description:"Disable telemetry" long-description:"Disable sending anonymous usage metrics, removing the anonymous identifier and the events not sent yet"`
+}
+
+func (c *telemetryDisableCmd) Execute(args []string) error {
+	return setTelemetry(c.Config, false)
+}
+
+func setTelemetry(configFile string, enabled bool) error {
+	client, err := newTelemetry(configFile)
+	if err != nil {
+		return humanizef(err, "could not read telemetry settings")
+	}
+
+	if err := client.SetEnabled(enabled); err != nil {
+		return humanizef(err, "could not save telemetry settings")
+	}
+
+	status := "disabled"
+	if enabled {
+		status = "enabled"
+	}
+
+	if client.Configured() && client.Enabled() != enabled {
+		log.Warningf("telemetry is %s, but telemetry.enabled in the config file takes precedence", status)
+		return nil
+	}
+
+	log.Infof("telemetry %s", status)
+	return nil
+}
+
+func init() {
+	c := rootCmd.AddCommand(&telemetryCmd{})
+	c.AddCommand(&telemetryStatusCmd{})
+	c.AddCommand(&telemetryEnableCmd{})
+	c.AddCommand(&telemetryDisableCmd{})
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"github.com/src-d/engine/tracing"
+
+	"github.com/docker/docker/api/types/container"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// trace is the trace of the command when it is run with --trace
+var trace struct {
+	tracer *tracing.Tracer
+	span   opentracing.Span
+	// jaeger is whether the spans are sent to the local Jaeger
+	jaeger   bool
+	endpoint string
+}
+
+// startTracing records the spans of the command, sending them to the
+// tracing endpoint of the config file, or to a local Jaeger started if
+// needed. The span of the command is the parent of the ones of the engine API
+// calls and the docker operations, see tracing.SetRoot
+func startTracing(name string) error {
+	endpoint, err := config.File.TracingEndpoint()
+	if err != nil {
+		return err
+	}
+
+	if endpoint == "" {
+		if err := startJaeger(); err != nil {
+			return humanizef(err, "could not start jaeger")
+		}
+
+		trace.jaeger = true
+		endpoint = fmt.Sprintf("http://localhost:%d", components.JaegerOTLPPort)
+	}
+
+	log.Debugf("sending the trace spans to %s", endpoint)
+	trace.endpoint = endpoint
+	trace.tracer = tracing.Start("srcd", endpoint, true)
+	trace.span = opentracing.StartSpan("srcd " + name)
+	tracing.SetRoot(trace.span)
+	return nil
+}
+
+// finishTracing finishes the span of the command, with its error, sends the
+// spans and prints where the trace can be found
+func finishTracing(err error) {
+	if trace.tracer == nil {
+		return
+	}
+
+	if err != nil {
+		ext.Error.Set(trace.span, true)
+		trace.span.LogKV("event", "error", "message", err.Error())
+	}
+
+	tracing.SetRoot(nil)
+	trace.span.Finish()
+	id := tracing.TraceID(trace.span)
+	if err := trace.tracer.Close(); err != nil {
+		log.Warningf("could not send the trace: %s", err)
+		return
+	}
+
+	if trace.jaeger {
+		fmt.Fprintf(os.Stderr, "trace %s: http://localhost:%d/trace/%s\n",
+			id, components.JaegerUIPort, id)
+	} else {
+		fmt.Fprintf(os.Stderr, "trace %s sent to %s\n", id, trace.endpoint)
+	}
+}
+
+// startJaeger starts the Jaeger container if it's not running, receiving
+// the spans with OTLP/HTTP
+func startJaeger() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	_, err := docker.InfoOrStart(ctx, components.Jaeger.Name, func(ctx context.Context) error {
+		cmp := components.Jaeger
+		if err := docker.EnsureInstalled(cmp.Image, cmp.Version); err != nil {
+			return err
+		}
+
+		config := &container.Config{
+			Image: cmp.ImageWithVersion(),
+			Env:   []string{"COLLECTOR_OTLP_ENABLED=true"},
+		}
+		host := &container.HostConfig{}
+		docker.ApplyOptions(config, host,
+			docker.WithPortOn("127.0.0.1", components.JaegerUIPort, components.JaegerUIPort),
+			docker.WithPortOn("127.0.0.1", components.JaegerOTLPPort, components.JaegerOTLPPort),
+		)
+
+		return docker.Start(ctx, config, host, cmp.Name)
+	})
+
+	return err
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// uninstallCmd represents the uninstall command
+type uninstallCmd struct {
+	Command `name:"uninstall" short-description:"Remove the engine from this host" long-description:"Remove the containers, volumes, networks and images of the engine, of every profile, and the config and state files in $HOME/.srcd, asking for confirmation first.\n\nWith --keep-data the volumes and $HOME/.srcd are kept, so the engine can be installed again with its indexes and settings. With --remove-binary the srcd binary is removed too."`
+
+	KeepData     bool `long:"keep-data" description:"keep the volumes and the config and state files in $HOME/.srcd"`
+	RemoveBinary bool `long:"remove-binary" description:"remove the srcd binary too"`
+	Yes          bool `short:"y" long:"yes" description:"do not ask for confirmation"`
+}
+
+func (c *uninstallCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected none")
+	}
+
+	if daemon.IsRemote() {
+		return fmt.Errorf("srcd uninstall removes the engine of this host, it can't be used with --host")
+	}
+
+	root, err := config.RootDir()
+	if err != nil {
+		return humanizef(err, "could not find the config directory")
+	}
+
+	profiles, err := uninstallProfiles()
+	if err != nil {
+		return humanizef(err, "could not list the profiles")
+	}
+
+	var binary string
+	if c.RemoveBinary {
+		binary, err = os.Executable()
+		if err == nil {
+			binary, err = filepath.EvalSymlinks(binary)
+		}
+
+		if err != nil {
+			return humanizef(err, "could not find the srcd binary")
+		}
+	}
+
+	if !c.Yes {
+		if !isInteractive() {
+			return fmt.Errorf("srcd uninstall asks for confirmation in a terminal, use --yes to not ask for it")
+		}
+
+		w := &setupWizard{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+		fmt.Fprint(w.out, uninstallSummary(profiles, root, c.KeepData, binary))
+		ok, err := w.confirm("Continue?", false)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return fmt.Errorf("uninstall canceled, nothing was removed")
+		}
+	}
+
+	current := components.Profile()
+	for i, p := range profiles {
+		if err := pruneProfile(p, p == current, i == len(profiles)-1, c.KeepData); err != nil {
+			components.SetProfile(current)
+			return humanizef(err, "could not remove the engine of the %s profile", profileFmt(p))
+		}
+	}
+
+	if err := components.SetProfile(current); err != nil {
+		return err
+	}
+
+	result := &uninstallResult{Profiles: []string{}, DataKept: c.KeepData, Binary: binary}
+	for _, p := range profiles {
+		result.Profiles = append(result.Profiles, profileFmt(p))
+	}
+
+	if !c.KeepData {
+		log.Infof("removing %s...", root)
+		if err := os.RemoveAll(root); err != nil {
+			return humanizef(err, "could not remove %s", root)
+		}
+	}
+
+	if binary != "" {
+		log.Infof("removing %s...", binary)
+		if err := os.Remove(binary); err != nil {
+			return humanizef(err, "could not remove the srcd binary %s", binary)
+		}
+	}
+
+	return render(os.Stdout, result, result.Print)
+}
+
+// uninstallProfiles returns the profiles srcd uninstall removes: the default
+// one, the ones with a directory in $HOME/.srcd/profiles, and the current one
+func uninstallProfiles() ([]string, error) {
+	names, err := config.Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := append([]string{""}, names...)
+	if p := components.Profile(); p != "" && !containsString(profiles, p) {
+		profiles = append(profiles, p)
+	}
+
+	return profiles, nil
+}
+
+// pruneProfile removes the containers, network and, unless keepVolumes is
+// true, the volumes of a profile, and the images of the components with
+// images. The config of the current profile is already read, the one of the
+// rest is read to know whether their network is external
+func pruneProfile(profile string, current, images, keepVolumes bool) error {
+	if err := components.SetProfile(profile); err != nil {
+		return err
+	}
+
+	conf := config.File
+	if !current {
+		var err error
+		conf, err = config.Load("")
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := docker.SetNetwork(conf.DockerNetwork()); err != nil {
+		return err
+	}
+
+	if err := components.Prune(images, keepVolumes); err != nil {
+		return err
+	}
+
+	return daemon.CleanUp()
+}
+
+// uninstallSummary returns what srcd uninstall removes, to confirm it
+func uninstallSummary(profiles []string, root string, keepData bool, binary string) string {
+	var names []string
+	for _, p := range profiles {
+		names = append(names, profileFmt(p))
+	}
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "This removes from this host:")
+	if keepData {
+		fmt.Fprintf(&b, "  - the containers and networks of the profiles: %s\n", strings.Join(names, ", "))
+	} else {
+		fmt.Fprintf(&b, "  - the containers, volumes and networks of the profiles: %s\n", strings.Join(names, ", "))
+		fmt.Fprintln(&b, "    The gitbase indexes and the analytics dashboards are lost")
+	}
+
+	fmt.Fprintln(&b, "  - the images of the components")
+	if !keepData {
+		fmt.Fprintf(&b, "  - the config and state files in %s\n", root)
+	}
+
+	if binary != "" {
+		fmt.Fprintf(&b, "  - the srcd binary %s\n", binary)
+	}
+
+	return b.String()
+}
+
+// profileFmt returns the name of a profile, "default" for the default one
+func profileFmt(profile string) string {
+	if profile == "" {
+		return "default"
+	}
+
+	return profile
+}
+
+// uninstallResult is the output of srcd uninstall
+type uninstallResult struct {
+	// Profiles are the names of the profiles removed, default for the
+	// default one
+	Profiles []string `json:"profiles" yaml:"profiles"`
+	DataKept bool     `json:"data_kept" yaml:"data_kept"`
+	// Binary is the path of the srcd binary removed, if it was
+	Binary string `json:"binary,omitempty" yaml:"binary,omitempty"`
+}
+
+func (r *uninstallResult) Print(w io.Writer) error {
+	msg := "source{d} Engine uninstalled"
+	if r.DataKept {
+		msg += ", the volumes and the config files were kept"
+	}
+
+	_, err := fmt.Fprintln(w, msg)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(&uninstallCmd{})
+}
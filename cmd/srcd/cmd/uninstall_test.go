@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUninstallSummary(t *testing.T) {
+	require := require.New(t)
+
+	summary := uninstallSummary([]string{"", "work"}, "/home/user/.srcd", false, "/usr/local/bin/srcd")
+	require.Equal(`This removes from this host:
+  - the containers, volumes and networks of the profiles: default, work
+    The gitbase indexes and the analytics dashboards are lost
+  - the images of the components
+  - the config and state files in /home/user/.srcd
+  - the srcd binary /usr/local/bin/srcd
+`, summary)
+
+	summary = uninstallSummary([]string{""}, "/home/user/.srcd", true, "")
+	require.Equal(`This removes from this host:
+  - the containers and networks of the profiles: default
+  - the images of the components
+`, summary)
+}
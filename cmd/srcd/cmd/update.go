@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/src-d/engine/cmd/srcd/update"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"github.com/blang/semver"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// updateCmd represents the update command
+type updateCmd struct {
+	Command `name:"update" short-description:"Update srcd to the newest version" long-description:"Download the newest srcd release compatible with the current version from GitHub, verify its checksum, and replace the running binary with it"`
+
+	Check  bool `long:"check" description:"Only check whether there is a newer version"`
+	Latest bool `long:"latest" description:"Update to the newest version, even if it has breaking changes"`
+}
+
+func (c *updateCmd) Execute(args []string) error {
+	if version == "" || version == "dev" {
+		return fmt.Errorf("development builds of srcd can't be updated")
+	}
+
+	current, err := semver.ParseTolerant(version)
+	if err != nil {
+		return humanizef(err, "invalid srcd version %s", version)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	releases, err := update.Releases(ctx)
+	if err != nil {
+		return humanizef(err, "could not check the srcd releases")
+	}
+
+	compatible, latest := update.Find(releases, current)
+	target := compatible
+	if c.Latest {
+		target = latest
+	}
+
+	if latest != nil && latest != target {
+		log.Warningf("srcd %s is available, but it has breaking changes. "+
+			"Read the changes below and use --latest to install it",
+			latest.Tag)
+		printReleaseNotes(ctx, docker.BreakingVersion(current))
+	}
+
+	if target == nil {
+		log.Infof("srcd %s is up to date", version)
+		return nil
+	}
+
+	if c.Check {
+		log.Infof("srcd %s is available, run srcd update to install it", target.Tag)
+		return nil
+	}
+
+	path, err := os.Executable()
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+
+	if err != nil {
+		return humanizef(err, "could not find the srcd binary")
+	}
+
+	log.Infof("downloading srcd %s", target.Tag)
+	binary, err := update.Download(ctx, target)
+	if err != nil {
+		return humanizef(err, "could not download srcd %s", target.Tag)
+	}
+
+	if err := update.Replace(path, binary); err != nil {
+		return humanizef(err, "could not update %s", path)
+	}
+
+	log.Infof("srcd updated from %s to %s", version, target.Tag)
+	warnOutdatedDaemon(target.Tag)
+	return nil
+}
+
+// printReleaseNotes shows the changelog entries of the releases equal to or
+// newer than the given version. Failing to fetch them is not an error, the
+// changelog can still be read in GitHub
+func printReleaseNotes(ctx context.Context, from semver.Version) {
+	notes, err := update.ReleaseNotes(ctx, from)
+	if err != nil || len(notes) == 0 {
+		if err != nil {
+			log.Debugf("could not fetch the changelog: %s", err)
+		}
+
+		log.Infof("read the changelog at https://github.com/src-d/engine/releases")
+		return
+	}
+
+	for _, n := range notes {
+		fmt.Printf("\n## srcd %s\n\n%s\n", n.Tag, n.Text)
+	}
+
+	fmt.Println()
+}
+
+// warnOutdatedDaemon tells the user to run srcd init if the daemon container
+// uses an image that does not match the given srcd version, as the daemon
+// and the components are only replaced by it
+func warnOutdatedDaemon(cliVersion string) {
+	container, err := docker.Info(components.Daemon.Name)
+	if err != nil {
+		return
+	}
+
+	tag, _, err := docker.GetCompatibleTag(components.Daemon.Image, cliVersion)
+	if err != nil {
+		log.Debugf("could not check the daemon image: %s", err)
+		return
+	}
+
+	if container.Image == components.Daemon.Image+":"+tag {
+		return
+	}
+
+	log.Warningf("the daemon and the components still use the images of the previous version, " +
+		"run srcd init to upgrade them")
+}
+
+func init() {
+	rootCmd.AddCommand(&updateCmd{})
+}
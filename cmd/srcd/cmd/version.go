@@ -17,9 +17,19 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	api "github.com/src-d/engine/api"
 	"github.com/src-d/engine/cmd/srcd/daemon"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+
+	"github.com/blang/semver"
+	"gopkg.in/src-d/go-log.v1"
 )
 
 var version = ""
@@ -31,39 +41,276 @@ func SetVersion(v string) {
 
 // versionCmd represents the version command
 type versionCmd struct {
-	Command `name:"version" short-description:"Show the version information" long-description:"Show the version information"`
+	Command `name:"version" short-description:"Show the version information" long-description:"Show the version information. With --all, also the versions of the daemon components and the bblfsh drivers, and the newest compatible version of each image"`
+
+	All bool `short:"a" long:"all" description:"Show the versions of the components and drivers, and whether there are newer compatible versions"`
+}
+
+// daemon states in the srcd version output
+const (
+	daemonRunning    = "running"
+	daemonNotRunning = "not_running"
+	daemonNone       = "none"
+)
+
+// versionOutput is the schema of the srcd version output. The docker version
+// is empty for a remote daemon, and the daemon one when it is not running.
+// The components and drivers are only listed with --all
+type versionOutput struct {
+	CLI              string                   `json:"cli" yaml:"cli"`
+	APIVersion       int                      `json:"api_version" yaml:"api_version"`
+	Docker           string                   `json:"docker,omitempty" yaml:"docker,omitempty"`
+	Daemon           string                   `json:"daemon,omitempty" yaml:"daemon,omitempty"`
+	DaemonAPIVersion int                      `json:"daemon_api_version,omitempty" yaml:"daemon_api_version,omitempty"`
+	DaemonState      string                   `json:"daemon_state" yaml:"daemon_state"`
+	Components       []componentVersionOutput `json:"components,omitempty" yaml:"components,omitempty"`
+	Drivers          []driverOutput           `json:"drivers,omitempty" yaml:"drivers,omitempty"`
+}
+
+// componentVersionOutput is the version of a component in the srcd version
+// --all output. Latest is the newest compatible version of its image, empty
+// if it could not be found
+type componentVersionOutput struct {
+	Name             string `json:"name" yaml:"name"`
+	Image            string `json:"image" yaml:"image"`
+	Version          string `json:"version" yaml:"version"`
+	State            string `json:"state" yaml:"state"`
+	Latest           string `json:"latest,omitempty" yaml:"latest,omitempty"`
+	UpgradeAvailable bool   `json:"upgrade_available" yaml:"upgrade_available"`
 }
 
 func (c *versionCmd) Execute(args []string) error {
-	fmt.Printf("srcd cli version: %s\n", version)
-	v, err := daemon.DockerVersion()
+	out, client, err := c.versions()
 	if err != nil {
-		return humanizef(err, "could not get docker version")
+		return err
 	}
 
-	fmt.Printf("docker version: %s\n", v)
+	if c.All {
+		if err := c.allVersions(out, client); err != nil {
+			return err
+		}
+	}
+
+	return render(os.Stdout, out, func(w io.Writer) error {
+		fmt.Fprintf(w, "srcd cli version: %s\n", out.CLI)
+		if out.Docker != "" {
+			fmt.Fprintf(w, "docker version: %s\n", out.Docker)
+		}
+
+		switch out.DaemonState {
+		case daemonNone:
+			fmt.Fprintf(w, "srcd daemon version: none, running without the daemon\n")
+		case daemonNotRunning:
+			fmt.Fprintf(w, "srcd daemon version: not running\n")
+		default:
+			fmt.Fprintf(w, "srcd daemon version: %s\n", out.Daemon)
+		}
+
+		if len(out.Components) > 0 {
+			fmt.Fprintln(w)
+			if err := printComponentVersions(w, out.Components); err != nil {
+				return err
+			}
+		}
+
+		if len(out.Drivers) > 0 {
+			fmt.Fprintln(w)
+			t := NewTable("%s", "%s")
+			t.Header("LANGUAGE", "DRIVER VERSION")
+			for _, d := range out.Drivers {
+				t.Row(d.Language, d.Version)
+			}
+
+			return t.Print(w)
+		}
 
-	if ok, err := daemon.IsRunning(); err != nil {
-		return humanizef(err, "could not get srcd daemon version")
-	} else if !ok {
-		fmt.Printf("srcd daemon version: not running\n")
 		return nil
+	})
+}
+
+// versions returns the versions of the cli, docker and the daemon, and the
+// client of the daemon, nil if it is not running
+func (c *versionCmd) versions() (*versionOutput, api.EngineClient, error) {
+	out := &versionOutput{CLI: version, APIVersion: api.APIVersion}
+
+	// the docker installation and daemon container of a remote host cannot be
+	// inspected, the version is requested directly to the remote daemon
+	if !daemon.IsRemote() {
+		v, err := daemon.DockerVersion()
+		if err != nil {
+			return nil, nil, humanizef(err, "could not get docker version")
+		}
+
+		out.Docker = v
+
+		if daemon.IsDaemonless() {
+			out.DaemonState = daemonNone
+			if !c.All {
+				return out, nil, nil
+			}
+
+			client, err := daemon.Client()
+			if err != nil {
+				return nil, nil, humanizef(err, "could not run the engine API")
+			}
+
+			return out, client, nil
+		}
+
+		if ok, err := daemon.IsRunning(); err != nil {
+			return nil, nil, humanizef(err, "could not get srcd daemon version")
+		} else if !ok {
+			out.DaemonState = daemonNotRunning
+			return out, nil, nil
+		}
 	}
 
 	client, err := daemon.Client()
+	if e, ok := err.(*daemon.IncompatibleErr); ok {
+		// the versions are still shown, to see what must be upgraded
+		log.Warningf("%s", e)
+		out.Daemon = e.DaemonVersion
+		out.DaemonState = daemonRunning
+		return out, nil, nil
+	}
+
 	if err != nil {
-		return humanizef(err, "could not get daemon client")
+		return nil, nil, humanizef(err, "could not get daemon client")
+	}
+
+	if c := daemon.Capabilities(); c != nil {
+		out.DaemonAPIVersion = int(c.ApiVersion)
 	}
 
 	res, err := client.Version(context.Background(), &api.VersionRequest{})
 	if err != nil {
-		return humanizef(err, "could not get daemon version")
+		return nil, nil, humanizef(err, "could not get daemon version")
+	}
+
+	out.Daemon = res.Version
+	out.DaemonState = daemonRunning
+	return out, client, nil
+}
+
+// allVersions adds the versions of the daemon and its components, and the
+// drivers installed in bblfshd if it is running. The daemon is not started to
+// read them
+func (c *versionCmd) allVersions(out *versionOutput, client api.EngineClient) error {
+	if client == nil {
+		// an incompatible daemon was already reported by versions
+		if out.DaemonState != daemonRunning {
+			log.Warningf("the daemon is not running, start it with srcd init to see the versions of the components")
+		}
+
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	res, err := client.ListComponents(ctx, &api.ListComponentsRequest{})
+	if err != nil {
+		return humanizef(err, "could not list the components")
+	}
+
+	if out.Daemon != "" {
+		out.Components = append(out.Components, componentVersionOutput{
+			Name:    components.Daemon.Name,
+			Image:   components.Daemon.Image,
+			Version: out.Daemon,
+			State:   daemonRunning,
+		})
+	}
+
+	bblfshdRunning := false
+	for _, info := range res.Components {
+		image, version := splitImage(info.Image)
+		out.Components = append(out.Components, componentVersionOutput{
+			Name:    info.Name,
+			Image:   image,
+			Version: version,
+			State:   info.State,
+		})
+
+		if info.Name == components.Bblfshd.Name && info.State == daemonRunning {
+			bblfshdRunning = true
+		}
+	}
+
+	findLatestVersions(out.Components)
+
+	if !bblfshdRunning {
+		return nil
+	}
+
+	drivers, err := client.ListDrivers(ctx, &api.ListDriversRequest{})
+	if err != nil {
+		return humanizef(err, "could not list drivers")
+	}
+
+	for _, d := range drivers.Drivers {
+		out.Drivers = append(out.Drivers, driverOutput{Language: d.Lang, Version: d.Version})
 	}
 
-	fmt.Printf("srcd daemon version: %s\n", res.Version)
 	return nil
 }
 
+// findLatestVersions sets the newest compatible version of the images of the
+// given components. Components whose versions can't be compared, like the ones
+// using a floating tag, are skipped
+func findLatestVersions(cmps []componentVersionOutput) {
+	var wg sync.WaitGroup
+	for i := range cmps {
+		cmp := &cmps[i]
+		if _, err := semver.ParseTolerant(cmp.Version); err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			tag, _, err := docker.GetCompatibleTag(cmp.Image, cmp.Version)
+			if err != nil {
+				log.Debugf("could not find a newer version of %s: %s", cmp.Image, err)
+				return
+			}
+
+			cmp.Latest = tag
+			cmp.UpgradeAvailable = tag != cmp.Version
+		}()
+	}
+
+	wg.Wait()
+}
+
+func printComponentVersions(w io.Writer, cmps []componentVersionOutput) error {
+	t := NewTable("%s", "%s", "%s", "%s", "%s")
+	t.Header("CONTAINER NAME", "IMAGE", "VERSION", "STATE", "LATEST")
+	for _, cmp := range cmps {
+		latest := cmp.Latest
+		switch {
+		case latest == "":
+			latest = "?"
+		case !cmp.UpgradeAvailable:
+			latest = "up to date"
+		}
+
+		t.Row(cmp.Name, cmp.Image, cmp.Version, stateFmt(cmp.State), latest)
+	}
+
+	return t.Print(w)
+}
+
+// splitImage returns the name and the tag of an image reference
+func splitImage(ref string) (string, string) {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+
+	return ref, ""
+}
+
 func init() {
 	rootCmd.AddCommand(&versionCmd{})
 }
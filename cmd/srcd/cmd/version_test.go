@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitImage(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		ref, image, tag string
+	}{
+		{"srcd/gitbase:v0.24.0", "srcd/gitbase", "v0.24.0"},
+		{"srcd/gitbase", "srcd/gitbase", ""},
+		{"localhost:5000/srcd/gitbase:v0.24.0", "localhost:5000/srcd/gitbase", "v0.24.0"},
+		{"localhost:5000/srcd/gitbase", "localhost:5000/srcd/gitbase", ""},
+	}
+
+	for _, c := range cases {
+		image, tag := splitImage(c.ref)
+		assert.Equal(c.image, image, c.ref)
+		assert.Equal(c.tag, tag, c.ref)
+	}
+}
+
+func TestPrintComponentVersions(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, printComponentVersions(&buf, []componentVersionOutput{
+		{Name: "srcd-cli-gitbase", Image: "srcd/gitbase", Version: "v0.24.0", State: "running", Latest: "v0.24.1", UpgradeAvailable: true},
+		{Name: "srcd-cli-bblfshd", Image: "bblfsh/bblfshd", Version: "v2.14.0", State: "not_created", Latest: "v2.14.0"},
+		{Name: "srcd-cli-search", Image: "etsy/hound", Version: "latest", State: "exited"},
+	}))
+
+	require.Equal(t, "CONTAINER NAME      IMAGE             VERSION    STATE          LATEST\n"+
+		"srcd-cli-gitbase    srcd/gitbase      v0.24.0    running        v0.24.1\n"+
+		"srcd-cli-bblfshd    bblfsh/bblfshd    v2.14.0    not created    up to date\n"+
+		"srcd-cli-search     etsy/hound        latest     exited         ?\n", buf.String())
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
+	"github.com/src-d/engine/cmd/srcd/daemon"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// warmupQueries are the queries run by srcd warmup when warmup.queries is not
+// set in the config file. They read the repositories, references, commits
+// and files of HEAD, which most queries go through
+var warmupQueries = []string{
+	"SELECT COUNT(*) FROM repositories",
+	"SELECT COUNT(*) FROM refs WHERE ref_name = 'HEAD'",
+	"SELECT COUNT(*) FROM refs NATURAL JOIN commits WHERE ref_name = 'HEAD'",
+	"SELECT LANGUAGE(file_path, blob_content) AS lang, COUNT(*) FROM refs " +
+		"NATURAL JOIN commit_files NATURAL JOIN files " +
+		"WHERE ref_name = 'HEAD' GROUP BY lang",
+}
+
+// warmupSnippets are the files parsed by srcd warmup to start the driver of
+// each language. The languages without one parse an empty file
+var warmupSnippets = map[string]string{
+	"bash":       "echo hello\n",
+	"csharp":     "class A { void B() {} }\n",
+	"cpp":        "int main() { return 0; }\n",
+	"go":         "package main\n\nfunc main() {}\n",
+	"java":       "class A { void b() {} }\n",
+	"javascript": "function a() { return 1; }\n",
+	"php":        "<?php echo 1; ?>\n",
+	"python":     "def a():\n    return 1\n",
+	"ruby":       "def a\n  1\nend\n",
+	"typescript": "function a(): number { return 1; }\n",
+}
+
+// warmupCmd represents the warmup command
+type warmupCmd struct {
+	Command `name:"warmup" short-description:"Prime the gitbase caches and bblfsh drivers" long-description:"Run a set of representative queries and parse a small file with each bblfsh driver, so the first queries and parses of a demo or session don't wait for the repositories to be read or the drivers to start. The time of each step is reported.\n\nThe queries and languages are set in warmup.queries and warmup.languages in the config file, or with --query and --lang. By default a few queries reading the repositories, references, commits and files of HEAD are run, and all the installed drivers are started."`
+
+	Queries   []string `short:"q" long:"query" description:"query to run instead of the ones of the config file, can be repeated"`
+	Languages []string `short:"l" long:"lang" description:"language to parse instead of the ones of the config file, can be repeated"`
+	NoParse   bool     `long:"no-parse" description:"don't start the bblfsh drivers"`
+}
+
+func (c *warmupCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("too many arguments, expected none")
+	}
+
+	client, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
+	}
+
+	queries := c.Queries
+	if len(queries) == 0 {
+		queries = config.File.Warmup.Queries
+	}
+
+	if len(queries) == 0 {
+		queries = warmupQueries
+	}
+
+	languages := c.Languages
+	if len(languages) == 0 {
+		languages = config.File.Warmup.Languages
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	report := &warmupReport{Steps: []warmupStep{}}
+
+	start := time.Now()
+	_, err = startGitbaseWithClient(client)
+	report.add("start", "gitbase", start, "", err)
+	if err != nil {
+		return humanizef(err, "could not start gitbase")
+	}
+
+	for _, q := range queries {
+		log.Debugf("running %s", q)
+		start := time.Now()
+		_, rows, err := queryRows(ctx, client, q)
+		report.add("sql", q, start, fmt.Sprintf("%d rows", len(rows)), err)
+	}
+
+	if !c.NoParse {
+		if err := warmupDrivers(ctx, client, languages, report); err != nil {
+			return err
+		}
+	}
+
+	if err := render(os.Stdout, report, report.Print); err != nil {
+		return err
+	}
+
+	if n := report.failed(); n > 0 {
+		return fmt.Errorf("%d of %d warm-up steps failed", n, len(report.Steps))
+	}
+
+	return nil
+}
+
+// warmupDrivers parses a small file with the drivers of the given languages,
+// or of all the installed ones if there are none
+func warmupDrivers(ctx context.Context, client api.EngineClient, languages []string, report *warmupReport) error {
+	resp, err := client.ListDrivers(ctx, &api.ListDriversRequest{})
+	if err != nil {
+		return humanizef(err, "could not list drivers")
+	}
+
+	all := len(languages) == 0
+	languages = append([]string(nil), languages...)
+	installed := make(map[string]bool, len(resp.Drivers))
+	for _, d := range resp.Drivers {
+		installed[d.Lang] = true
+		if all {
+			languages = append(languages, d.Lang)
+		}
+	}
+
+	sort.Strings(languages)
+	for _, lang := range languages {
+		if !installed[lang] {
+			report.add("parse", lang, time.Now(), "", fmt.Errorf("the driver is not installed"))
+			continue
+		}
+
+		log.Debugf("parsing a %s file", lang)
+		start := time.Now()
+		_, err := client.Parse(ctx, &api.ParseRequest{
+			Kind:    api.ParseRequest_UAST,
+			Name:    "warmup",
+			Content: []byte(warmupSnippets[lang]),
+			Lang:    lang,
+		})
+		report.add("parse", lang, start, "", err)
+	}
+
+	return nil
+}
+
+// warmupReport is the output of srcd warmup
+type warmupReport struct {
+	Steps []warmupStep `json:"steps" yaml:"steps"`
+}
+
+// warmupStep is a query or parse run by srcd warmup, and its duration
+type warmupStep struct {
+	Kind     string        `json:"kind" yaml:"kind"`
+	Target   string        `json:"target" yaml:"target"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	Result   string        `json:"result" yaml:"result"`
+	Error    string        `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// add appends the step started at the given time, with its result or error
+func (r *warmupReport) add(kind, target string, start time.Time, result string, err error) {
+	step := warmupStep{
+		Kind:     kind,
+		Target:   target,
+		Duration: time.Since(start),
+		Result:   result,
+	}
+
+	if step.Result == "" {
+		step.Result = "ok"
+	}
+
+	if err != nil {
+		step.Result = "failed"
+		step.Error = humanize(err).Error()
+	}
+
+	r.Steps = append(r.Steps, step)
+}
+
+func (r *warmupReport) failed() int {
+	var n int
+	for _, s := range r.Steps {
+		if s.Error != "" {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (r *warmupReport) Print(w io.Writer) error {
+	t := NewTable("%s", "%s", "%s", "%s")
+	t.Header("STEP", "TARGET", "TIME", "RESULT")
+	var total time.Duration
+	for _, s := range r.Steps {
+		result := s.Result
+		if s.Error != "" {
+			result = "failed: " + s.Error
+		}
+
+		t.Row(s.Kind, s.Target, s.Duration.Round(time.Millisecond).String(), result)
+		total += s.Duration
+	}
+
+	if err := t.Print(w); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nwarmed up in %s\n", total.Round(time.Millisecond))
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(&warmupCmd{})
+}
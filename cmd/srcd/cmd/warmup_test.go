@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/api"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// driversClient has the go and python drivers installed, and fails to parse
+// python
+type driversClient struct {
+	api.EngineClient
+	parsed []string
+}
+
+func (c *driversClient) ListDrivers(
+	ctx context.Context,
+	in *api.ListDriversRequest,
+	opts ...grpc.CallOption,
+) (*api.ListDriversResponse, error) {
+	return &api.ListDriversResponse{Drivers: []*api.ListDriversResponse_DriverInfo{
+		{Lang: "python"},
+		{Lang: "go"},
+	}}, nil
+}
+
+func (c *driversClient) Parse(
+	ctx context.Context,
+	in *api.ParseRequest,
+	opts ...grpc.CallOption,
+) (*api.ParseResponse, error) {
+	c.parsed = append(c.parsed, in.Lang+":"+string(in.Content))
+	if in.Lang == "python" {
+		return nil, fmt.Errorf("driver timeout")
+	}
+
+	return &api.ParseResponse{}, nil
+}
+
+func TestWarmupDrivers(t *testing.T) {
+	require := require.New(t)
+
+	client := &driversClient{}
+	report := &warmupReport{}
+	require.NoError(warmupDrivers(context.Background(), client, nil, report))
+	require.Equal([]string{"go:" + warmupSnippets["go"], "python:" + warmupSnippets["python"]}, client.parsed)
+	require.Len(report.Steps, 2)
+	require.Equal("ok", report.Steps[0].Result)
+	require.Equal("failed", report.Steps[1].Result)
+	require.Equal("driver timeout", report.Steps[1].Error)
+	require.Equal(1, report.failed())
+
+	client = &driversClient{}
+	report = &warmupReport{}
+	require.NoError(warmupDrivers(context.Background(), client, []string{"go", "rust"}, report))
+	require.Equal([]string{"go:" + warmupSnippets["go"]}, client.parsed)
+	require.Equal("the driver is not installed", report.Steps[1].Error)
+}
+
+func TestWarmupReportPrint(t *testing.T) {
+	require := require.New(t)
+
+	r := &warmupReport{Steps: []warmupStep{
+		{Kind: "sql", Target: "SELECT 1", Duration: 1500 * time.Millisecond, Result: "1 rows"},
+		{Kind: "parse", Target: "go", Duration: 250 * time.Millisecond, Result: "failed", Error: "driver timeout"},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(r.Print(&buf))
+	require.Contains(buf.String(), "SELECT 1")
+	require.Contains(buf.String(), "1.5s")
+	require.Contains(buf.String(), "failed: driver timeout")
+	require.Contains(buf.String(), "warmed up in 1.75s\n")
+}
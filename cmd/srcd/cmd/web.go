@@ -17,13 +17,16 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"time"
 
 	api "github.com/src-d/engine/api"
+	"github.com/src-d/engine/cmd/srcd/config"
 	"github.com/src-d/engine/cmd/srcd/daemon"
 	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/engine"
 
 	"github.com/pkg/browser"
 	"gopkg.in/src-d/go-cli.v0"
@@ -40,7 +43,7 @@ type webSQLCmd struct {
 }
 
 func (c *webSQLCmd) Execute(args []string) error {
-	return startWebComponent(components.GitbaseWeb.Name, "gitbase web client", args)
+	return startWebComponent(components.GitbaseWeb.Name, "gitbase web client", args, nil)
 }
 
 // webParseCmd represents the web parse command
@@ -49,41 +52,58 @@ type webParseCmd struct {
 }
 
 func (c *webParseCmd) Execute(args []string) error {
-	return startWebComponent(components.BblfshWeb.Name, "bblfsh web client", args)
+	return startWebComponent(components.BblfshWeb.Name, "bblfsh web client", args, nil)
 }
 
-func startWebComponent(name, desc string, args []string) error {
-	c, err := daemon.Client()
+// webAnalyticsCmd represents the web analytics command
+type webAnalyticsCmd struct {
+	Command `name:"analytics" short-description:"Start Metabase dashboards over gitbase" long-description:"Start Metabase with gitbase as a database and a starter dashboard of repository metrics.\n\nThe admin is created the first time it starts, with the email and password\nof components.analytics.user and components.analytics.password in the\nconfig file. A password is generated if none is set."`
+}
+
+func (c *webAnalyticsCmd) Execute(args []string) error {
+	return startWebComponent(components.Analytics.Name, "analytics dashboards", args, analyticsLogin)
+}
+
+// analyticsLogin returns the credentials of the Metabase admin. The password
+// set in the config is not printed, and the generated one can only be read
+// from the container when the daemon is local
+func analyticsLogin() (*webLogin, error) {
+	user, password := config.File.AnalyticsCredentials()
+	if password != "" {
+		return &webLogin{User: user, Hint: "components.analytics.password in the config file"}, nil
+	}
+
+	if daemon.IsRemote() {
+		return &webLogin{User: user, Hint: fmt.Sprintf(
+			"the output of docker exec %s cat %s in the daemon host",
+			components.Analytics.Name, engine.AnalyticsPasswordFile)}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, password, err := engine.AnalyticsCredentials(ctx, config.File)
 	if err != nil {
-		return humanizef(err, "could not get daemon client")
+		return nil, err
 	}
 
-	// in case of gitbase-web we need to run gitbase first and make sure it started
-	if name == components.GitbaseWeb.Name {
-		timeout := 3 * time.Second
-		started := logAfterTimeoutWithServerLogs("this is taking a while, "+
-			"it might take a few more minutes while we install all the required images",
-			timeout)
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		_, err = c.StartComponent(ctx, &api.StartComponentRequest{
-			Name: components.Gitbase.Name,
-		})
-		started()
-		cancel()
+	return &webLogin{User: user, Password: password}, nil
+}
 
-		if err != nil {
-			return humanizef(err, "could not start gitbase")
-		}
+// startWebComponent starts the web client with the given container name until
+// Ctrl-C is pressed. If login is not nil, it returns the credentials printed
+// along with the URL
+func startWebComponent(name, desc string, args []string, login func() (*webLogin, error)) error {
+	if err := checkRequirements([]string{name}, false); err != nil {
+		return err
+	}
 
-		connReady := logAfterTimeoutWithSpinner("waiting for gitbase to be ready", timeout, 0)
-		err = ensureConnReady(c)
-		connReady()
-		if err != nil {
-			return humanizef(err, "could not connect to gitbase")
-		}
+	c, err := daemon.Client()
+	if err != nil {
+		return humanizef(err, "could not get daemon client")
 	}
 
-	started := logAfterTimeoutWithServerLogs("this is taking a while, if this is the first time you launch this web client, it might take a few more minutes while we install all the required images",
+	started := logAfterTimeoutWithEvents(c, "this is taking a while, if this is the first time you launch this web client, it might take a few more minutes while we install all the required images",
 		3*time.Second)
 
 	// Might have to pull some images
@@ -103,8 +123,23 @@ func startWebComponent(name, desc string, args []string) error {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, os.Interrupt, os.Kill)
 
-	fmt.Printf("Go to http://localhost:%d for the %s. Press Ctrl-C to stop it.\n", res.Port, desc)
-	_ = browser.OpenURL(fmt.Sprintf("http://localhost:%d", res.Port))
+	url := fmt.Sprintf("http://%s:%d", daemon.Hostname(), res.Port)
+	out := webOutput{Component: name, URL: url}
+	if login != nil {
+		if out.Login, err = login(); err != nil {
+			return humanizef(err, "could not get the credentials of the %s", desc)
+		}
+	}
+
+	err = render(os.Stdout, out, out.Print(desc))
+	if err != nil {
+		return err
+	}
+
+	// scripts only need the URL
+	if isTextOutput() {
+		_ = browser.OpenURL(url)
+	}
 
 	<-ch
 
@@ -121,8 +156,43 @@ func startWebComponent(name, desc string, args []string) error {
 	return nil
 }
 
+// webOutput is the schema of the srcd web output, written once the web client
+// is ready
+type webOutput struct {
+	Component string    `json:"component" yaml:"component"`
+	URL       string    `json:"url" yaml:"url"`
+	Login     *webLogin `json:"login,omitempty" yaml:"login,omitempty"`
+}
+
+// webLogin are the credentials to log in the web client. When the password
+// can't be printed, Hint tells where it is
+type webLogin struct {
+	User     string `json:"user" yaml:"user"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	Hint     string `json:"hint,omitempty" yaml:"hint,omitempty"`
+}
+
+// Print returns the function that writes the output of the web client with
+// the given description as text
+func (o webOutput) Print(desc string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		login := ""
+		switch {
+		case o.Login == nil:
+		case o.Login.Password != "":
+			login = fmt.Sprintf(" Log in as %s with the password %s.", o.Login.User, o.Login.Password)
+		default:
+			login = fmt.Sprintf(" Log in as %s with the password of %s.", o.Login.User, o.Login.Hint)
+		}
+
+		_, err := fmt.Fprintf(w, "Go to %s for the %s.%s Press Ctrl-C to stop it.\n", o.URL, desc, login)
+		return err
+	}
+}
+
 func init() {
 	c := rootCmd.AddCommand(&webCmd{})
 	c.AddCommand(&webSQLCmd{})
 	c.AddCommand(&webParseCmd{})
+	c.AddCommand(&webAnalyticsCmd{})
 }
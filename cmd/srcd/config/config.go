@@ -1,11 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/components"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
@@ -16,36 +18,357 @@ import (
 // File contains the config read from the file path used in Read
 var File = &api.Config{}
 
-// Read reads the config file values into File. If configFile path is empty,
-// $HOME/.srcd/config.yml will be used, only if it exists.
-// If configFile is empty and the default file does not exist the return value
-// is nil
+// RootDir returns the directory with the config and state files of all the
+// profiles, $HOME/.srcd
+func RootDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", errors.Wrapf(err, "could not detect home directory")
+	}
+
+	return filepath.Join(home, ".srcd"), nil
+}
+
+// Dir returns the directory with the config and state files of the current
+// profile, $HOME/.srcd for the default one and $HOME/.srcd/profiles/<name>
+// for the rest
+func Dir() (string, error) {
+	dir, err := RootDir()
+	if err != nil {
+		return "", err
+	}
+
+	if p := components.Profile(); p != "" {
+		dir = filepath.Join(dir, "profiles", p)
+	}
+
+	return dir, nil
+}
+
+// Profiles returns the names of the profiles with a directory in RootDir,
+// sorted. The default one is not included
+func Profiles() ([]string, error) {
+	root, err := RootDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(root, "profiles"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list the profiles")
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && components.ValidateProfileName(e.Name()) == nil {
+			names = append(names, e.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// FilePath returns the path of the config file, configFile if it is not
+// empty, or config.yml in Dir otherwise, which may not exist
+func FilePath(configFile string) (string, error) {
+	if configFile != "" {
+		return configFile, nil
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "config.yml"), nil
+}
+
+// profile is the name of the config profile applied by Load, see
+// SetConfigProfile
+var profile string
+
+// SetConfigProfile selects the profile of the config file applied by Load,
+// see api.ApplyConfigProfile. An empty name applies none. Unlike the profiles
+// of components.SetProfile, it only changes the values of the config, not the
+// containers, volumes and network used
+func SetConfigProfile(name string) {
+	profile = name
+}
+
+// Read reads the config file values into File, merged with the ones of the
+// project config file, see Load.
 func Read(configFile string) error {
+	c, err := Load(configFile)
+	if err != nil {
+		return err
+	}
+
+	*File = *c
+	return nil
+}
+
+// Load reads the config file, and the project config file if there is one,
+// see ProjectFile, and returns their values. The profile of the config file
+// selected with SetConfigProfile is applied, and the values of the project
+// file take precedence over both. If configFile is empty, the one of
+// FilePath is used, only if it exists.
+func Load(configFile string) (*api.Config, error) {
+	values := make(map[interface{}]interface{})
 	if configFile == "" {
-		// Find home directory.
-		home, err := homedir.Dir()
+		path, err := FilePath("")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := os.Stat(path); err == nil {
+			configFile = path
+		}
+	}
+
+	if configFile != "" {
+		log.Debugf("Using config file: %s", configFile)
+
+		var err error
+		values, err = loadFile(configFile)
 		if err != nil {
-			return errors.Wrapf(err, "could not detect home directory")
+			return nil, err
 		}
 
-		configFile = filepath.Join(home, ".srcd", "config.yml")
+		if err := api.ApplyConfigProfile(values, profile); err != nil {
+			return nil, errors.Wrapf(err, "invalid config file %s", configFile)
+		}
 
-		if _, err := os.Stat(configFile); os.IsNotExist(err) {
-			return nil
+		if err := resolveRulesFile(values, configFile); err != nil {
+			return nil, err
 		}
 	}
 
-	log.Debugf("Using config file: %s", configFile)
+	if configFile == "" && profile != "" {
+		return nil, fmt.Errorf("unknown config profile %q, there is no config file", profile)
+	}
+
+	project, err := ProjectFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if project != "" {
+		log.Debugf("Using project config file: %s", project)
+
+		projectValues, err := loadFile(project)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkProjectKeys(projectValues); err != nil {
+			return nil, errors.Wrapf(err, "invalid project config file %s", project)
+		}
+
+		if err := resolveRulesFile(projectValues, project); err != nil {
+			return nil, err
+		}
 
-	content, err := ioutil.ReadFile(configFile)
+		api.MergeConfigValues(values, projectValues)
+	}
+
+	content, err := yaml.Marshal(values)
 	if err != nil {
-		return errors.Wrapf(err, "failed to read config file %s", configFile)
+		return nil, err
+	}
+
+	c := &api.Config{}
+	if err := yaml.UnmarshalStrict(content, c); err != nil {
+		return nil, errors.Wrapf(err, "the config files do not follow the expected format")
 	}
 
-	err = yaml.UnmarshalStrict(content, File)
+	return c, nil
+}
+
+// loadFile reads a config file and returns its values
+func loadFile(path string) (map[interface{}]interface{}, error) {
+	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		return errors.Wrapf(err, "config file %s does not follow the expected format", configFile)
+		return nil, errors.Wrapf(err, "failed to read config file %s", path)
+	}
+
+	// the file is decoded as the config first, for the errors of its format
+	var c api.Config
+	if err := yaml.UnmarshalStrict(content, &c); err != nil {
+		return nil, errors.Wrapf(err, "config file %s does not follow the expected format", path)
+	}
+
+	values := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, errors.Wrapf(err, "config file %s does not follow the expected format", path)
+	}
+
+	return values, nil
+}
+
+// resolveRulesFile makes the path of the rules file in the values of a config
+// file absolute, as it is relative to the directory of the config file
+func resolveRulesFile(values map[interface{}]interface{}, path string) error {
+	rules, ok := values["rules"].(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+
+	file, ok := rules["file"].(string)
+	if !ok || file == "" || filepath.IsAbs(file) {
+		return nil
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	rules["file"] = filepath.Join(dir, file)
+	return nil
+}
+
+// Write saves the content of the config file, configFile if it is not empty,
+// or config.yml in Dir otherwise. The file is replaced at once, so it is never
+// left half written
+func Write(configFile string, content []byte) error {
+	path, err := FilePath(configFile)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "could not create directory %s", filepath.Dir(path))
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, mode); err != nil {
+		return errors.Wrapf(err, "failed to write config file %s", tmp)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to write config file %s", path)
 	}
 
 	return nil
 }
+
+// versionsFile returns the path of the file with the component versions set
+// by srcd components upgrade, versions.yml in Dir
+func versionsFile() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "versions.yml"), nil
+}
+
+// ReadVersions returns the image versions of the upgraded components, by
+// container name. It returns an empty map if no component was upgraded
+func ReadVersions() (map[string]string, error) {
+	path, err := versionsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]string)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return versions, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read versions file %s", path)
+	}
+
+	if err := yaml.Unmarshal(content, &versions); err != nil {
+		return nil, errors.Wrapf(err, "versions file %s does not follow the expected format", path)
+	}
+
+	return versions, nil
+}
+
+// WriteVersions saves the image versions of the upgraded components, by
+// container name
+func WriteVersions(versions map[string]string) error {
+	path, err := versionsFile()
+	if err != nil {
+		return err
+	}
+
+	content, err := yaml.Marshal(versions)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "could not create directory %s", filepath.Dir(path))
+	}
+
+	err = ioutil.WriteFile(path, content, 0644)
+	return errors.Wrapf(err, "failed to write versions file %s", path)
+}
+
+// shardsFile returns the path of the file with the gitbase shards planned by
+// srcd shards plan --apply, shards.yml in Dir
+func shardsFile() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "shards.yml"), nil
+}
+
+// ReadShards returns the gitbase shards planned by srcd shards plan, used
+// when the config file doesn't set components.gitbase.shards. It returns nil
+// if they were never planned
+func ReadShards() ([]api.GitbaseShard, error) {
+	path, err := shardsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read shards file %s", path)
+	}
+
+	var shards []api.GitbaseShard
+	if err := yaml.UnmarshalStrict(content, &shards); err != nil {
+		return nil, errors.Wrapf(err, "shards file %s does not follow the expected format", path)
+	}
+
+	return shards, nil
+}
+
+// WriteShards saves the gitbase shards planned by srcd shards plan
+func WriteShards(shards []api.GitbaseShard) error {
+	path, err := shardsFile()
+	if err != nil {
+		return err
+	}
+
+	content, err := yaml.Marshal(shards)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "could not create directory %s", filepath.Dir(path))
+	}
+
+	err = ioutil.WriteFile(path, content, 0644)
+	return errors.Wrapf(err, "failed to write shards file %s", path)
+}
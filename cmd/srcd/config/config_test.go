@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/engine/components"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfile(t *testing.T) {
+	require := require.New(t)
+
+	home, err := ioutil.TempDir("", "srcd-home")
+	require.NoError(err)
+	defer os.RemoveAll(home)
+
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	require.NoError(os.Setenv("HOME", home))
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	SetProjectDisabled(true)
+	defer SetProjectDisabled(false)
+	defer components.SetProfile("")
+
+	path := filepath.Join(home, ".srcd", "config.yml")
+	require.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(ioutil.WriteFile(path, []byte(`
+components:
+  gitbase:
+    port: 3307
+rules:
+  file: rules.yml
+profiles:
+  ci:
+    components:
+      gitbase:
+        port: 3308
+    rules:
+      file: ci/rules.yml
+`), 0644))
+
+	c, err := Load("")
+	require.NoError(err)
+	require.Equal(3307, c.Components.Gitbase.Port)
+	require.Equal(filepath.Join(home, ".srcd", "rules.yml"), c.Rules.File)
+	require.Empty(c.Profiles)
+
+	defer SetConfigProfile("")
+	SetConfigProfile("my-ci")
+	_, err = Load("")
+	require.EqualError(err, fmt.Sprintf("invalid config file %s: unknown config profile "+
+		"\"my-ci\", it is not set in profiles", path))
+
+	// the config profile does not change the stack or its config file
+	SetConfigProfile("ci")
+	file, err := FilePath("")
+	require.NoError(err)
+	require.Equal(path, file)
+	require.Empty(components.Profile())
+
+	c, err = Load("")
+	require.NoError(err)
+	require.Equal(3308, c.Components.Gitbase.Port)
+	require.Equal(filepath.Join(home, ".srcd", "ci", "rules.yml"), c.Rules.File)
+
+	// the stacks of --profile use their own config file
+	require.NoError(components.SetProfile("work"))
+	file, err = FilePath("")
+	require.NoError(err)
+	require.Equal(filepath.Join(home, ".srcd", "profiles", "work", "config.yml"), file)
+
+	_, err = Load("")
+	require.EqualError(err, `unknown config profile "ci", there is no config file`)
+
+	SetConfigProfile("")
+	c, err = Load("")
+	require.NoError(err)
+	require.Equal(0, c.Components.Gitbase.Port)
+
+	// only the directories of the profiles count
+	profiles, err := Profiles()
+	require.NoError(err)
+	require.Empty(profiles)
+
+	require.NoError(os.MkdirAll(filepath.Join(home, ".srcd", "profiles", "work"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(home, ".srcd", "profiles", "ci"), 0755))
+	require.NoError(os.MkdirAll(filepath.Join(home, ".srcd", "profiles", "Invalid"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(home, ".srcd", "profiles", "file"), nil, 0644))
+
+	profiles, err = Profiles()
+	require.NoError(err)
+	require.Equal([]string{"ci", "work"}, profiles)
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ProjectFileName is the name of the project config file, committed in the
+// repositories to share their engine settings
+const ProjectFileName = ".srcd.yml"
+
+// projectDisabled is whether the project config file is ignored
+var projectDisabled bool
+
+// SetProjectDisabled sets whether the project config file is ignored, to
+// only use the config file of the user
+func SetProjectDisabled(disabled bool) {
+	projectDisabled = disabled
+}
+
+// ProjectFile returns the path of the project config file, .srcd.yml in the
+// current directory or in the closest of its parents with one. It returns an
+// empty string if there is none, or if it is disabled with
+// SetProjectDisabled
+func ProjectFile() (string, error) {
+	if projectDisabled {
+		return "", nil
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+
+		dir = parent
+	}
+}
+
+// checkProjectKeys checks that the values of a project config file only set
+// the keys about the analysis of the repositories: the drivers, the versions
+// of the components, the rules and the warmup. The rest configure the srcd
+// installation of the user, like the ports, credentials or security options,
+// and a repository must not change them.
+func checkProjectKeys(values map[interface{}]interface{}) error {
+	for _, k := range sortedKeys(values) {
+		switch k {
+		case "drivers", "rules", "warmup":
+		case "components":
+			cmps, _ := values[k].(map[interface{}]interface{})
+			for _, name := range sortedKeys(cmps) {
+				cmp, _ := cmps[name].(map[interface{}]interface{})
+				for _, ck := range sortedKeys(cmp) {
+					if ck != "version" {
+						return projectKeyError(fmt.Sprintf("components.%s.%s", name, ck))
+					}
+				}
+			}
+		default:
+			return projectKeyError(k)
+		}
+	}
+
+	return nil
+}
+
+func projectKeyError(key string) error {
+	return fmt.Errorf("%s can't be set in a project config file, only drivers, "+
+		"components.<name>.version, rules and warmup can", key)
+}
+
+func sortedKeys(m map[interface{}]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, fmt.Sprint(k))
+	}
+
+	sort.Strings(keys)
+	return keys
+}
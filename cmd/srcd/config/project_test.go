@@ -0,0 +1,94 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProjectFile(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-project")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// the temp dir may be a symlink, like in macOS
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(err)
+
+	user := filepath.Join(dir, "config.yml")
+	require.NoError(ioutil.WriteFile(user, []byte(`
+components:
+  gitbase:
+    port: 3307
+drivers:
+  go: v2.7.1
+  python: v2.9.0
+warmup:
+  queries: [SELECT 1]
+`), 0644))
+
+	project := filepath.Join(dir, "repo")
+	subdir := filepath.Join(project, "src", "pkg")
+	require.NoError(os.MkdirAll(subdir, 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(project, ProjectFileName), []byte(`
+components:
+  gitbase:
+    version: v0.20.0
+drivers:
+  go: v2.8.0
+warmup:
+  queries: [SELECT 2, SELECT 3]
+rules:
+  file: lint/rules.yml
+`), 0644))
+
+	wd, err := os.Getwd()
+	require.NoError(err)
+	defer os.Chdir(wd)
+	require.NoError(os.Chdir(subdir))
+
+	path, err := ProjectFile()
+	require.NoError(err)
+	require.Equal(filepath.Join(project, ProjectFileName), path)
+
+	c, err := Load(user)
+	require.NoError(err)
+	require.Equal(3307, c.Components.Gitbase.Port)
+	require.Equal("v0.20.0", c.Components.Gitbase.Version)
+	require.Equal(map[string]string{"go": "v2.8.0", "python": "v2.9.0"}, c.Drivers)
+	require.Equal([]string{"SELECT 2", "SELECT 3"}, c.Warmup.Queries)
+	require.Equal(filepath.Join(project, "lint", "rules.yml"), c.Rules.File)
+
+	SetProjectDisabled(true)
+	c, err = Load(user)
+	SetProjectDisabled(false)
+	require.NoError(err)
+	require.Equal("", c.Components.Gitbase.Version)
+	require.Equal("v2.7.1", c.Drivers["go"])
+
+	require.NoError(ioutil.WriteFile(filepath.Join(project, ProjectFileName), []byte(`
+components:
+  gitbase:
+    version: v0.20.0
+    password: secret
+`), 0644))
+
+	_, err = Load(user)
+	require.EqualError(err, "invalid project config file "+filepath.Join(project, ProjectFileName)+
+		": components.gitbase.password can't be set in a project config file, "+
+		"only drivers, components.<name>.version, rules and warmup can")
+
+	require.NoError(ioutil.WriteFile(filepath.Join(project, ProjectFileName), []byte("security:\n  hardening: false\n"), 0644))
+	_, err = Load(user)
+	require.Error(err)
+
+	require.NoError(os.Chdir(dir))
+	path, err = ProjectFile()
+	require.NoError(err)
+	require.Equal("", path)
+}
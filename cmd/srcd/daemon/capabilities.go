@@ -0,0 +1,215 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/src-d/engine/api"
+
+	"github.com/blang/semver"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// capabilitiesTimeout is the maximum time to wait for the capabilities of a
+// local daemon, which may still be starting
+const capabilitiesTimeout = 10 * time.Second
+
+var (
+	capabilitiesMu sync.Mutex
+	// negotiated has the capabilities of the daemons used by Client, by
+	// address, so they are only read once by process
+	negotiated = map[string]*api.CapabilitiesResponse{}
+	// capabilities of the daemon used by the last call to Client, nil if
+	// they could not be read
+	capabilities *api.CapabilitiesResponse
+)
+
+// IncompatibleErr is returned by Client when the API versions of srcd and the
+// daemon can't work together, usually because only one of them was upgraded,
+// or when the daemon is older than srcd
+type IncompatibleErr struct {
+	DaemonVersion string
+	// DaemonTooOld is true if the daemon must be upgraded, and false if srcd
+	// must be upgraded
+	DaemonTooOld bool
+	// Usable is true if the daemon is older than srcd but its API version
+	// can still be used, without the features it lacks
+	Usable bool
+}
+
+// Error implements error interface
+func (e *IncompatibleErr) Error() string {
+	if e.DaemonTooOld {
+		return fmt.Sprintf("the daemon %s is too old for srcd %s, %s",
+			e.DaemonVersion, cliVersion, upgradeHint())
+	}
+
+	return fmt.Sprintf("srcd %s is too old for the daemon %s, "+
+		"upgrade srcd to the same version as the daemon", cliVersion, e.DaemonVersion)
+}
+
+// UnsupportedErr is returned by RequireFeature when the daemon does not
+// support an optional feature of the API
+type UnsupportedErr struct {
+	DaemonVersion string
+	Feature       string
+}
+
+// Error implements error interface
+func (e *UnsupportedErr) Error() string {
+	return fmt.Sprintf("the daemon %s does not support %s, %s",
+		e.DaemonVersion, e.Feature, upgradeHint())
+}
+
+// upgradeHint tells how to upgrade the daemon to the version of srcd
+func upgradeHint() string {
+	if IsRemote() {
+		return fmt.Sprintf("upgrade it running srcd init with srcd %s in %s",
+			cliVersion, Hostname())
+	}
+
+	return fmt.Sprintf("run srcd init to restart it with srcd %s", cliVersion)
+}
+
+// forgetCapabilities removes the capabilities read from the daemons, so they
+// are read again after the local daemon is replaced
+func forgetCapabilities() {
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+
+	negotiated = map[string]*api.CapabilitiesResponse{}
+	capabilities = nil
+}
+
+// Capabilities returns the capabilities of the daemon used by the last call
+// to Client, or nil if they are unknown
+func Capabilities() *api.CapabilitiesResponse {
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	return capabilities
+}
+
+// Supports returns false if the daemon used by Client does not support the
+// given feature. It returns true when its capabilities are unknown, so the
+// requests fail like they would without the check
+func Supports(feature string) bool {
+	c := Capabilities()
+	return c == nil || c.HasFeature(feature)
+}
+
+// RequireFeature returns an *UnsupportedErr if the daemon used by Client
+// does not support the given feature. A local daemon is upgraded instead if
+// it is confirmed, see SetUpgradeConfirm
+func RequireFeature(feature string) error {
+	err := checkFeature(Capabilities(), feature)
+	e, ok := err.(*UnsupportedErr)
+	if !ok || IsRemote() || IsDaemonless() || upgradeConfirm == nil || !upgradeConfirm(e.DaemonVersion) {
+		return err
+	}
+
+	if _, err := upgrade(e.DaemonVersion); err != nil {
+		return err
+	}
+
+	return checkFeature(Capabilities(), feature)
+}
+
+// checkFeature returns an *UnsupportedErr if a daemon with the given
+// capabilities does not support the feature. Unknown capabilities support
+// all of them, see Supports
+func checkFeature(c *api.CapabilitiesResponse, feature string) error {
+	if c == nil || c.HasFeature(feature) {
+		return nil
+	}
+
+	return &UnsupportedErr{DaemonVersion: c.Version, Feature: feature}
+}
+
+// negotiate reads the capabilities of the daemon at addr, and returns an
+// *IncompatibleErr if its API version can't be used by srcd, or if it is
+// older than srcd. The daemons that predate the Capabilities method are
+// assumed to have the first API version and no optional features. The
+// capabilities of the usable daemons are kept even if they are too old, see
+// IncompatibleErr.Usable
+func negotiate(ctx context.Context, addr string, client api.EngineClient) error {
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+
+	if c, ok := negotiated[addr]; ok {
+		capabilities = c
+		return nil
+	}
+
+	capabilities = nil
+	c, err := client.Capabilities(ctx, &api.CapabilitiesRequest{ApiVersion: api.APIVersion})
+	legacy := status.Code(err) == codes.Unimplemented
+	if legacy {
+		c, err = legacyCapabilities(ctx, client)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = checkCapabilities(c, legacy)
+	if e, ok := err.(*IncompatibleErr); ok && !e.Usable {
+		return err
+	}
+
+	negotiated[addr] = c
+	capabilities = c
+	return err
+}
+
+// legacyCapabilities returns the capabilities of a daemon that predates the
+// Capabilities method
+func legacyCapabilities(ctx context.Context, client api.EngineClient) (*api.CapabilitiesResponse, error) {
+	v, err := client.Version(ctx, &api.VersionRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.CapabilitiesResponse{
+		ApiVersion:    1,
+		MinApiVersion: 1,
+		Version:       v.Version,
+	}, nil
+}
+
+// checkCapabilities returns an *IncompatibleErr if srcd can't use a daemon
+// with the given capabilities, or if the daemon is too old: it predates the
+// Capabilities method, as legacy tells, or its version is older than the one
+// of srcd. Those are still usable if their API version is
+func checkCapabilities(c *api.CapabilitiesResponse, legacy bool) error {
+	if c.ApiVersion < api.MinAPIVersion {
+		return &IncompatibleErr{DaemonVersion: c.Version, DaemonTooOld: true}
+	}
+
+	if c.MinApiVersion > api.APIVersion {
+		return &IncompatibleErr{DaemonVersion: c.Version}
+	}
+
+	if legacy || olderThanCli(c.Version) {
+		return &IncompatibleErr{DaemonVersion: c.Version, DaemonTooOld: true, Usable: true}
+	}
+
+	return nil
+}
+
+// olderThanCli returns whether the given daemon version is older than the
+// version of srcd. The development builds are never older
+func olderThanCli(version string) bool {
+	daemon, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false
+	}
+
+	cli, err := semver.ParseTolerant(cliVersion)
+	if err != nil {
+		return false
+	}
+
+	return daemon.LT(cli)
+}
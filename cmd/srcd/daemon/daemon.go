@@ -4,26 +4,30 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"net"
 	"os"
+	"os/user"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/audit"
+	"github.com/src-d/engine/cmd/srcd-server/engine"
 	"github.com/src-d/engine/cmd/srcd/config"
 	"github.com/src-d/engine/components"
 	"github.com/src-d/engine/docker"
+	"github.com/src-d/engine/logging"
+	"github.com/src-d/engine/tracing"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/go-connections/nat"
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
 	grpc "google.golang.org/grpc"
-	"gopkg.in/src-d/go-log.v1"
 )
 
 const (
@@ -31,6 +35,22 @@ const (
 	// maxMessageSize overrides default grpc max. message size to receive
 	maxMessageSize = 100 * 1024 * 1024 // 100MB
 	stateFileName  = ".state.json"
+	// remoteDialTimeout is the maximum time to wait for a remote daemon to
+	// accept the connection and answer the health check
+	remoteDialTimeout = 10 * time.Second
+	// uastCacheDirName is the directory of the UAST cache in the data
+	// directory, mounted in the daemon container at uastCacheMountPath
+	uastCacheDirName   = "uast-cache"
+	uastCacheMountPath = "/var/lib/srcd/uast-cache"
+	// auditDirName is the directory of the audit log in the data directory,
+	// mounted in the daemon container at auditMountPath
+	auditDirName   = "audit"
+	auditMountPath = "/var/lib/srcd/audit"
+	// datasetsDirName is the directory of the public datasets in the root
+	// directory, shared by the profiles and mounted in the daemon container
+	// at datasetsMountPath
+	datasetsDirName   = "datasets"
+	datasetsMountPath = "/var/lib/srcd/datasets"
 )
 
 // cli version set by src-d command
@@ -41,11 +61,145 @@ func SetCliVersion(v string) {
 	cliVersion = v
 }
 
+// upgradeConfirm is set by src-d command, see SetUpgradeConfirm
+var upgradeConfirm func(daemonVersion string) bool
+
+// SetUpgradeConfirm sets the function called by Client when the local daemon
+// is too old for srcd, and by RequireFeature when it lacks a feature, with its
+// version, to confirm that it must be upgraded. Without it, or if it returns
+// false, Client returns an *IncompatibleErr unless the daemon is usable, and
+// RequireFeature returns an *UnsupportedErr
+func SetUpgradeConfirm(fn func(daemonVersion string) bool) {
+	upgradeConfirm = fn
+}
+
+// address of a remote daemon set by src-d command
+var host = ""
+
+// SetHost sets the address, in the form host[:port], of a remote daemon to be
+// used by Client instead of the local one. An empty address means the local
+// daemon container will be used
+func SetHost(h string) {
+	h = strings.TrimPrefix(strings.TrimSpace(h), "tcp://")
+	if h != "" {
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			h = net.JoinHostPort(h, strconv.Itoa(components.DaemonPort))
+		}
+	}
+
+	host = h
+}
+
+// IsRemote returns true if the daemon used by Client is a remote one, set
+// with SetHost
+func IsRemote() bool {
+	return host != ""
+}
+
+// Hostname returns the name of the host where the daemon, and the components
+// started by it, are running
+func Hostname() string {
+	if !IsRemote() {
+		return "localhost"
+	}
+
+	h, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+
+	return h
+}
+
+// token of the user of the daemon API, set by src-d command
+var token = ""
+
+// SetToken sets the token sent to the daemon with each request, required
+// when the daemon has users in daemon.auth.users of its config
+func SetToken(t string) {
+	token = strings.TrimSpace(t)
+}
+
+// noDaemon is set by src-d command to run the engine in the CLI process
+var noDaemon = false
+
+// SetNoDaemon makes Client run the engine API in the current process, instead
+// of starting the daemon container. The components are still run in
+// containers, but they are started by the CLI itself
+func SetNoDaemon(b bool) {
+	noDaemon = b
+}
+
+// IsDaemonless returns true if the engine runs in the CLI process, set with
+// SetNoDaemon, and false if it runs in a remote or local daemon
+func IsDaemonless() bool {
+	return noDaemon && !IsRemote()
+}
+
+// InsecureTokenErr is returned by Client when a token is set and the remote
+// daemon is not on the loopback interface, as the connection is not
+// encrypted and the token would be sent in plain text
+type InsecureTokenErr struct {
+	Addr string
+}
+
+// Error implements error interface
+func (e *InsecureTokenErr) Error() string {
+	h, p, err := net.SplitHostPort(e.Addr)
+	if err != nil {
+		h, p = e.Addr, strconv.Itoa(components.DaemonPort)
+	}
+
+	return fmt.Sprintf("the token can't be sent to the daemon at %s over an unencrypted "+
+		"connection, reach it through an SSH tunnel instead, e.g. run "+
+		"ssh -N -L %s:localhost:%s %s and use --host localhost:%s", e.Addr, p, p, h, p)
+}
+
+// isLoopback returns whether the host of the address, in the form
+// host:port, is only reachable from this machine
+func isLoopback(addr string) bool {
+	h, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		h = addr
+	}
+
+	if h == "localhost" {
+		return true
+	}
+
+	ips := []net.IP{net.ParseIP(h)}
+	if ips[0] == nil {
+		if ips, err = net.LookupIP(h); err != nil || len(ips) == 0 {
+			return false
+		}
+	}
+
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// UnreachableErr is returned by Client when the remote daemon does not accept
+// connections or does not reply to the health check
+type UnreachableErr struct {
+	Addr string
+	Err  error
+}
+
+// Error implements error interface
+func (e *UnreachableErr) Error() string {
+	return fmt.Sprintf("daemon at %s is unreachable: %v", e.Addr, e.Err)
+}
+
 func DockerVersion() (string, error) { return docker.Version() }
 func IsRunning() (bool, error)       { return docker.IsRunning(components.Daemon.Name, "") }
 
-// Kill stops the daemon, and any of its dependencies. If it was not running it
-// is ignored and does not produce an error
+// Kill stops the daemon gracefully, and then removes any of its dependencies.
+// If it was not running it is ignored and does not produce an error
 func Kill() error {
 	cmps, err := components.List(
 		context.Background(),
@@ -56,8 +210,17 @@ func Kill() error {
 		return err
 	}
 
+	// the daemon goes first, so its running requests can finish
+	if err := stopDaemon(); err != nil {
+		return err
+	}
+
 	for _, cmp := range cmps {
-		log.Infof("removing container %s", cmp.Name)
+		if cmp.Name == components.Daemon.Name {
+			continue
+		}
+
+		logging.Daemon().Infof("removing container %s", cmp.Name)
 
 		if err := cmp.Kill(); err != nil {
 			return err
@@ -67,6 +230,119 @@ func Kill() error {
 	return nil
 }
 
+// Restart replaces the daemon container with the working directory and config
+// of the last start, so it uses the current component versions. It does
+// nothing if the daemon is not running
+func Restart() error {
+	running, err := IsRunning()
+	if err != nil || !running {
+		return err
+	}
+
+	opts, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	if opts == nil {
+		return fmt.Errorf("the daemon state file is missing")
+	}
+
+	if err := stopDaemon(); err != nil {
+		return err
+	}
+
+	_, err = start(*opts)
+	return err
+}
+
+// stopDaemon stops and removes the daemon container, giving it its grace
+// period to finish the running requests and jobs, see srcd-server. It does
+// nothing if there is no daemon container
+func stopDaemon() error {
+	logging.Daemon().Infof("stopping container %s", components.Daemon.Name)
+	return components.Daemon.Stop()
+}
+
+// Upgrade stops the local daemon gracefully and replaces it with the image of
+// the current srcd version, keeping the working directory and config of its
+// last start, and starts again the components that were running, so only the
+// ones whose configuration changed are recreated. It is used when the daemon
+// is too old for srcd, see IncompatibleErr
+func Upgrade() error {
+	opts, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	if opts == nil {
+		return fmt.Errorf("the daemon state file is missing")
+	}
+
+	restart, err := runningUpgradable()
+	if err != nil {
+		return err
+	}
+
+	if err := stopDaemon(); err != nil {
+		return err
+	}
+
+	if _, err := start(*opts); err != nil {
+		return err
+	}
+
+	// the capabilities of the old daemon were read from the same address
+	forgetCapabilities()
+	if len(restart) == 0 {
+		return nil
+	}
+
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+
+	return startAgain(client, restart)
+}
+
+// PreviousVersion returns the image version used by the component with the
+// given container name before its last upgrade, or an empty string if it was
+// never upgraded
+func PreviousVersion(name string) (string, error) {
+	opts, err := readState()
+	if err != nil || opts == nil {
+		return "", err
+	}
+
+	return opts.Previous[name], nil
+}
+
+// SetPreviousVersion saves the image version used by the component with the
+// given container name before an upgrade. An empty version removes it
+func SetPreviousVersion(name, version string) error {
+	opts, err := readState()
+	if err != nil {
+		return err
+	}
+
+	if opts == nil {
+		opts = &startOptions{}
+	}
+
+	if opts.Previous == nil {
+		opts.Previous = make(map[string]string)
+	}
+
+	if version == "" {
+		delete(opts.Previous, name)
+	} else {
+		opts.Previous[name] = version
+	}
+
+	return opts.Save()
+}
+
 // CleanUp removes all resources created by daemon on host
 func CleanUp() error {
 	datadir, err := datadir()
@@ -78,32 +354,319 @@ func CleanUp() error {
 	return os.RemoveAll(stateFile)
 }
 
+// PprofAddr returns the address, in the form host:port, of the pprof
+// endpoints of the local daemon, which must be running
+func PprofAddr() (string, error) {
+	if IsRemote() {
+		return "", fmt.Errorf("the pprof endpoints are only published on the host of the daemon, they can't be used with a remote daemon")
+	}
+
+	if IsDaemonless() {
+		return "", fmt.Errorf("there is no daemon to profile with --no-daemon")
+	}
+
+	info, err := docker.Info(components.Daemon.Name)
+	if err == docker.ErrNotFound || err == nil && info.State != "running" {
+		return "", fmt.Errorf("the daemon is not running")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range info.Ports {
+		if p.PrivatePort == components.DaemonPprofPort && p.PublicPort != 0 {
+			return net.JoinHostPort("127.0.0.1", strconv.Itoa(int(p.PublicPort))), nil
+		}
+	}
+
+	return "", fmt.Errorf("the daemon does not publish the pprof endpoints, " +
+		"restart it with srcd stop and srcd init")
+}
+
 // Client will return a new EngineClient to interact with the daemon. If the
 // daemon is not started already, it will start it at the working directory.
+// If a remote daemon was set with SetHost, it will connect to it instead, and
+// return an *UnreachableErr if it does not reply. The capabilities of the
+// daemon are checked, and an *IncompatibleErr is returned if srcd can't use it.
+// A local daemon that is too old is upgraded instead if it is confirmed, see
+// SetUpgradeConfirm, otherwise it is used with a warning if it is usable.
 func Client() (api.EngineClient, error) {
+	if IsRemote() {
+		return remoteClient(host)
+	}
+
+	if IsDaemonless() {
+		return localClient()
+	}
+
+	client, err := daemonClient()
+	e, ok := err.(*IncompatibleErr)
+	if !ok || !e.DaemonTooOld {
+		return client, err
+	}
+
+	if upgradeConfirm != nil && upgradeConfirm(e.DaemonVersion) {
+		return upgrade(e.DaemonVersion)
+	}
+
+	if !e.Usable {
+		return nil, err
+	}
+
+	logging.Daemon().Warningf("%s, some commands may not be available", e)
+	return client, nil
+}
+
+// upgrade upgrades the local daemon with the given version, and returns a
+// client of the new one
+func upgrade(daemonVersion string) (api.EngineClient, error) {
+	logging.Daemon().Infof("upgrading the daemon %s to srcd %s", daemonVersion, cliVersion)
+	if err := Upgrade(); err != nil {
+		return nil, errors.Wrap(err, "could not upgrade the daemon")
+	}
+
+	client, err := daemonClient()
+	if e, ok := err.(*IncompatibleErr); ok && e.Usable {
+		return client, nil
+	}
+
+	return client, err
+}
+
+// daemonClient returns a client of the local daemon container, starting it
+// if it is not running
+func daemonClient() (api.EngineClient, error) {
 	info, err := ensureStarted()
 	if err != nil {
 		return nil, err
 	}
 
-	addr := fmt.Sprintf("0.0.0.0:%d", info.Ports[0].PublicPort)
-	// TODO(campoy): add security
-	conn, err := grpc.Dial(addr,
+	var port *docker.Port
+	for i, p := range info.Ports {
+		if p.PrivatePort == components.DaemonPort {
+			port = &info.Ports[i]
+			break
+		}
+	}
+
+	if port == nil {
+		return nil, fmt.Errorf("the daemon container does not publish port %d", components.DaemonPort)
+	}
+
+	ip := port.IP
+	if ip == "" {
+		ip = "0.0.0.0"
+	}
+
+	addr := net.JoinHostPort(ip, strconv.Itoa(int(port.PublicPort)))
+	conn, err := grpc.Dial(addr, dialOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := api.NewEngineClient(conn)
+	if err := negotiateLocal(addr, client); err != nil {
+		if e := err.(*IncompatibleErr); e.Usable {
+			return client, err
+		}
+
+		conn.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// negotiateLocal reads the capabilities of the local daemon, see negotiate.
+// Only an *IncompatibleErr is returned, if the daemon can't be reached the
+// capabilities are left unknown and the requests fail as usual
+func negotiateLocal(addr string, client api.EngineClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), capabilitiesTimeout)
+	defer cancel()
+
+	err := negotiate(ctx, addr, client)
+	if _, ok := err.(*IncompatibleErr); ok {
+		return err
+	}
+
+	if err != nil {
+		logging.Daemon().Debugf("could not read the daemon capabilities: %s", err)
+	}
+
+	return nil
+}
+
+// local is the client of the engine API served by the CLI process, see
+// localClient
+var local api.EngineClient
+
+// localClient returns a client of the engine API served by the CLI process on
+// a loopback port, with the working directory and config of the last init.
+// The server stops when the process exits, but not the components
+func localClient() (api.EngineClient, error) {
+	if local != nil {
+		return local, nil
+	}
+
+	opts, err := localOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	// the component versions may have changed since the last init
+	o := opts.resolved()
+	cacheDir, err := uastCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	server := engine.NewLocalServer(cliVersion, o.WorkDir, cacheDir, *o.Config)
+
+	// the config is validated when it is read
+	auditSize, auditFiles, _ := o.Config.AuditLog()
+	if auditSize > 0 {
+		dir, err := AuditDir()
+		if err != nil {
+			return nil, err
+		}
+
+		l, err := audit.Open(dir, auditSize, auditFiles)
+		if err != nil {
+			return nil, err
+		}
+
+		server.SetAuditLog(l)
+		go server.AuditEvents(context.Background())
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not listen for the engine API")
+	}
+
+	srv := grpc.NewServer(tracing.ServerOptions()...)
+	api.RegisterEngineServer(srv, server)
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			logging.Daemon().Errorf(err, "the engine API server stopped")
+		}
+	}()
+
+	conn, err := grpc.Dial(l.Addr().String(), dialOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	local = api.NewEngineClient(conn)
+	if err := negotiateLocal(l.Addr().String(), local); err != nil {
+		return nil, err
+	}
+
+	return local, nil
+}
+
+func remoteClient(addr string) (api.EngineClient, error) {
+	if token != "" && !isLoopback(addr) {
+		return nil, &InsecureTokenErr{Addr: addr}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteDialTimeout)
+	defer cancel()
+
+	logging.Daemon().Debugf("connecting to remote daemon at %s", addr)
+	opts := append(dialOptions(), grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		return nil, &UnreachableErr{Addr: addr, Err: err}
+	}
+
+	client := api.NewEngineClient(conn)
+	if err := negotiate(ctx, addr, client); err != nil {
+		e, ok := err.(*IncompatibleErr)
+		if ok && e.Usable {
+			logging.Daemon().Warningf("%s, some commands may not be available", e)
+			return client, nil
+		}
+
+		conn.Close()
+		if ok {
+			return nil, err
+		}
+
+		return nil, &UnreachableErr{Addr: addr, Err: err}
+	}
+
+	return client, nil
+}
+
+// dialOptions returns the options of the connections to the daemon, which
+// are not encrypted, so the token is only sent to the local daemon or the
+// remote ones on the loopback interface, see InsecureTokenErr
+func dialOptions() []grpc.DialOption {
+	return append(tracing.DialOptions(),
 		grpc.WithDefaultCallOptions(
 			grpc.MaxCallRecvMsgSize(maxMessageSize),
 		),
-		grpc.WithInsecure())
-	if err != nil {
-		return nil, err
+		grpc.WithInsecure(),
+		grpc.WithPerRPCCredentials(clientIdentity{}),
+	)
+}
+
+// clientIdentity sends the user and host running srcd with each request, to
+// identify them in the audit log of the daemon, and the token set with
+// SetToken
+type clientIdentity struct{}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials interface
+func (clientIdentity) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	host, _ := os.Hostname()
+	md := map[string]string{
+		audit.UserKey: username(),
+		audit.HostKey: host,
 	}
 
-	return api.NewEngineClient(conn), nil
+	if token != "" {
+		md[engine.TokenKey] = "Bearer " + token
+	}
+
+	return md, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials
+// interface. The identity is sent with the insecure connections too, but
+// remoteClient refuses to send the token to the daemons that are not on the
+// loopback interface
+func (clientIdentity) RequireTransportSecurity() bool {
+	return false
+}
+
+// username returns the name of the user running srcd
+func username() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	for _, env := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+
+	return ""
 }
 
 // startOptions is a configuration for src-d daemon
 type startOptions struct {
-	WorkDir string      `json:"workdir"`
-	Config  *api.Config `json:"config"`
+	WorkDir string `json:"workdir"`
+	// Config is the effective config of the daemon, with the default values
+	// and the image versions of the components resolved
+	Config *api.Config `json:"config"`
+	// CliVersion is the version of srcd that started the daemon, which
+	// determines the version of the daemon image
+	CliVersion string `json:"cli_version,omitempty"`
+	// Previous has the image versions the components used before their last
+	// upgrade, by container name
+	Previous map[string]string `json:"previous,omitempty"`
 }
 
 // Save persists configuration to a file
@@ -127,34 +690,154 @@ func (o *startOptions) Save() error {
 	return errors.Wrapf(e.Encode(o), "can't encode state into file")
 }
 
-func Start(workdir string) error {
-	opts, err := saveState(workdir)
+// Init starts the daemon with the given working directory and the current
+// config, and returns whether anything changed. If the daemon is already
+// running with the same working directory, config and srcd version, it does
+// nothing. Otherwise the daemon is replaced, and the running components are
+// started again, so only the ones whose configuration changed are recreated.
+// The components that use the working directory are removed if it changed.
+// With force, the daemon and all the components are always removed
+func Init(workdir string, force bool) (bool, error) {
+	old, err := readState()
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	_, err = start(opts)
-	return err
+	opts := newStartOptions(workdir, old)
+
+	running, err := IsRunning()
+	if err != nil {
+		return false, err
+	}
+
+	// without the daemon, the options are only saved for the next commands
+	if (running || IsDaemonless()) && !force && opts.sameAs(old) {
+		return false, nil
+	}
+
+	var restart []string
+	switch {
+	case force:
+		if err := components.Stop(components.ComponentGracePeriod); err != nil {
+			return false, err
+		}
+	case old == nil || old.WorkDir != workdir:
+		if err := Kill(); err != nil {
+			return false, err
+		}
+	default:
+		if err := stopDaemon(); err != nil {
+			return false, err
+		}
+	}
+
+	if !force {
+		if restart, err = runningUpgradable(); err != nil {
+			return false, err
+		}
+	}
+
+	if IsDaemonless() {
+		err = opts.Save()
+	} else {
+		_, err = start(opts)
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(restart) == 0 {
+		return true, nil
+	}
+
+	client, err := Client()
+	if err != nil {
+		return false, err
+	}
+
+	if err := startAgain(client, restart); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
-func saveState(workdir string) (startOptions, error) {
-	cfg := config.File
+// runningUpgradable returns the container names of the components managed by
+// the daemon that are running
+func runningUpgradable() ([]string, error) {
+	var names []string
+	for _, cmp := range components.Upgradable() {
+		ok, err := docker.IsRunning(cmp.Name, "")
+		if err != nil {
+			return nil, err
+		}
 
-	opts := startOptions{WorkDir: workdir, Config: cfg}
-	if err := opts.Save(); err != nil {
-		return startOptions{}, err
+		if ok {
+			names = append(names, cmp.Name)
+		}
 	}
 
-	return opts, nil
+	return names, nil
 }
 
-func GetLogs() (io.ReadCloser, error) {
-	info, err := ensureStarted()
-	if err != nil {
-		return nil, err
+// startAgain starts the components with the given container names after the
+// daemon was replaced, so they are recreated if their configuration changed
+func startAgain(client api.EngineClient, names []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for _, name := range names {
+		logging.Daemon().Debugf("starting %s again, it is recreated if its configuration changed", name)
+		if _, err := client.StartComponent(ctx, &api.StartComponentRequest{Name: name}); err != nil {
+			return errors.Wrapf(err, "could not start %s", name)
+		}
+	}
+
+	return nil
+}
+
+// newStartOptions returns the options to start the daemon with the given
+// working directory and the current config, resolved like start does. The
+// previous versions in old are kept for srcd components rollback
+func newStartOptions(workdir string, old *startOptions) startOptions {
+	opts := startOptions{WorkDir: workdir, Config: config.File}
+	if old != nil {
+		opts.Previous = old.Previous
+	}
+
+	return opts.resolved()
+}
+
+// resolved returns a copy of the options with the default values of the
+// config, the current image versions of the components and srcd version
+func (o startOptions) resolved() startOptions {
+	var conf api.Config
+	if o.Config != nil {
+		conf = *o.Config
+	}
+
+	conf.SetDefaults()
+	conf.SetDefaultUser(runtime.GOOS)
+	for _, cmp := range components.Upgradable() {
+		conf.SetComponentVersion(cmp.Name, cmp.Version)
 	}
 
-	return docker.GetLogs(context.Background(), info.ID)
+	o.Config = &conf
+	o.CliVersion = cliVersion
+	return o
+}
+
+// sameAs returns whether the daemon started with the old options can be used
+// as if it was started with o
+func (o startOptions) sameAs(old *startOptions) bool {
+	if old == nil || old.Config == nil {
+		return false
+	}
+
+	return o.WorkDir == old.WorkDir &&
+		o.CliVersion == old.CliVersion &&
+		o.Config.AsYaml() == old.Config.AsYaml()
 }
 
 func ensureStarted() (*docker.Container, error) {
@@ -166,26 +849,61 @@ func ensureStarted() (*docker.Container, error) {
 		return docker.Info(components.Daemon.Name)
 	}
 
-	d, err := datadir()
+	opts, err := localOptions()
 	if err != nil {
 		return nil, err
 	}
 
-	statePath := path.Join(d, stateFileName)
-	if _, err := os.Stat(statePath); os.IsNotExist(err) {
-		wd, err := os.Getwd()
-		if err != nil {
-			return nil, err
-		}
-		opts, err := saveState(wd)
-		if err != nil {
-			return nil, err
-		}
+	return start(*opts)
+}
+
+// localOptions returns the options of the last init, or the ones for the
+// current directory if it was never run
+func localOptions() (*startOptions, error) {
+	opts, err := loadState()
+	if err != nil || opts != nil {
+		return opts, err
+	}
 
-		return start(opts)
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
 	}
 
-	f, err := os.Open(statePath)
+	// the state file can exist with the previous versions only
+	old, err := readState()
+	if err != nil {
+		return nil, err
+	}
+
+	o := newStartOptions(wd, old)
+	return &o, nil
+}
+
+// loadState reads the options of the last daemon start. It returns nil if
+// the daemon was never started
+func loadState() (*startOptions, error) {
+	opts, err := readState()
+	if err != nil || opts == nil || opts.WorkDir == "" {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// readState reads the state file, which can exist before the daemon is
+// started to keep the previous versions. It returns nil if there is no state
+// file
+func readState() (*startOptions, error) {
+	d, err := datadir()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path.Join(d, stateFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "can't open state file")
 	}
@@ -197,10 +915,46 @@ func ensureStarted() (*docker.Container, error) {
 		return nil, errors.Wrapf(err, "can't decode state file")
 	}
 
-	return start(opts)
+	return &opts, nil
 }
 
+// Workdir returns the working directory of the local daemon, starting it at
+// the current directory if it was never started. Without the daemon, it is
+// the one of the last init, or the current directory
+func Workdir() (string, error) {
+	if IsDaemonless() {
+		opts, err := localOptions()
+		if err != nil {
+			return "", err
+		}
+
+		return opts.WorkDir, nil
+	}
+
+	if _, err := ensureStarted(); err != nil {
+		return "", err
+	}
+
+	opts, err := loadState()
+	if err != nil {
+		return "", err
+	}
+
+	if opts == nil {
+		return "", fmt.Errorf("the daemon state file is missing")
+	}
+
+	return opts.WorkDir, nil
+}
+
+// start starts the daemon with the options, resolved with the current
+// component versions, and saves them in the state file
 func start(opts startOptions) (*docker.Container, error) {
+	opts = opts.resolved()
+	if err := opts.Save(); err != nil {
+		return nil, err
+	}
+
 	return docker.InfoOrStart(
 		context.Background(),
 		components.Daemon.Name,
@@ -211,24 +965,33 @@ func start(opts startOptions) (*docker.Container, error) {
 func createDaemon(opts startOptions) docker.StartFunc {
 	workdir := filepath.ToSlash(opts.WorkDir)
 	conf := opts.Config
-	conf.SetDefaults()
 
 	return func(ctx context.Context) error {
 		cmp := components.Daemon
 		hasNew, err := cmp.RetrieveVersion()
 		if err != nil {
-			log.Warningf("unable to list the available daemon versions on Docker Hub: ", err)
+			logging.Daemon().Warningf("unable to list the available daemon versions on Docker Hub: ", err)
 		}
 
 		if hasNew {
-			log.Warningf("new version of engine is available. Please download the latest release here: https://github.com/src-d/engine/releases")
+			logging.Daemon().Warningf("a new version of engine with breaking changes is available, run srcd update --check to see what it brings")
 		}
 
 		if err := docker.EnsureInstalled(cmp.Image, cmp.Version); err != nil {
 			return err
 		}
 
-		hostPort := strconv.Itoa(conf.Components.Daemon.Port)
+		hostIP, port, err := conf.DaemonListenAddr()
+		if err != nil {
+			return err
+		}
+
+		hostPort := strconv.Itoa(port)
+
+		policy, err := conf.RestartPolicy()
+		if err != nil {
+			return err
+		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -244,11 +1007,13 @@ func createDaemon(opts startOptions) docker.StartFunc {
 				fmt.Sprintf("--workdir=%s", workdir),
 				fmt.Sprintf("--host-os=%s", runtime.GOOS),
 				fmt.Sprintf("--config=%s", conf.AsYaml()),
+				fmt.Sprintf("--profile=%s", components.Profile()),
 			},
 		}
 
 		host := &container.HostConfig{
-			PortBindings: nat.PortMap{daemonPort: {{HostPort: hostPort}}},
+			PortBindings:  nat.PortMap{daemonPort: {{HostIP: hostIP, HostPort: hostPort}}},
+			RestartPolicy: policy,
 			Mounts: []mount.Mount{{
 				Type:   mount.TypeBind,
 				Source: dockerSocket,
@@ -256,15 +1021,179 @@ func createDaemon(opts startOptions) docker.StartFunc {
 			}},
 		}
 
+		// the UAST cache is kept in the host, owned by the user running srcd,
+		// so the daemon can write it when it runs as that user
+		cacheDir, err := uastCacheDir()
+		if err != nil {
+			return err
+		}
+
+		cacheHostPath, err := docker.HostPath(filepath.ToSlash(cacheDir), runtime.GOOS)
+		if err != nil {
+			return err
+		}
+
+		docker.ApplyOptions(config, host,
+			docker.WithSharedDirectory(cacheHostPath, uastCacheMountPath, runtime.GOOS))
+		config.Cmd = append(config.Cmd, fmt.Sprintf("--uast-cache-dir=%s", uastCacheMountPath))
+
+		// the audit log is kept in the host too, read by srcd audit tail
+		auditDir, err := AuditDir()
+		if err != nil {
+			return err
+		}
+
+		auditHostPath, err := docker.HostPath(filepath.ToSlash(auditDir), runtime.GOOS)
+		if err != nil {
+			return err
+		}
+
+		docker.ApplyOptions(config, host,
+			docker.WithSharedDirectory(auditHostPath, auditMountPath, runtime.GOOS))
+		config.Cmd = append(config.Cmd, fmt.Sprintf("--audit-dir=%s", auditMountPath))
+
+		// the fetch-dataset jobs download the datasets to the same directory
+		// as srcd datasets get
+		datasetsDir, err := DatasetsDir()
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(datasetsDir, 0755); err != nil {
+			return errors.Wrapf(err, "could not create datasets directory %s", datasetsDir)
+		}
+
+		datasetsHostPath, err := docker.HostPath(filepath.ToSlash(datasetsDir), runtime.GOOS)
+		if err != nil {
+			return err
+		}
+
+		docker.ApplyOptions(config, host,
+			docker.WithSharedDirectory(datasetsHostPath, datasetsMountPath, runtime.GOOS))
+		config.Cmd = append(config.Cmd, fmt.Sprintf("--datasets-dir=%s", datasetsMountPath))
+
+		if conf.Daemon.HTTPPort != 0 {
+			httpPort := nat.Port(strconv.Itoa(components.DaemonHTTPPort))
+			config.ExposedPorts[httpPort] = struct{}{}
+			config.Cmd = append(config.Cmd,
+				fmt.Sprintf("--http-address=0.0.0.0:%d", components.DaemonHTTPPort))
+			host.PortBindings[httpPort] = []nat.PortBinding{{
+				HostIP:   hostIP,
+				HostPort: strconv.Itoa(conf.Daemon.HTTPPort),
+			}}
+		}
+
+		// the pprof endpoints are only published on the loopback interface of
+		// the host, on a port picked by docker, see PprofAddr
+		pprofPort := nat.Port(strconv.Itoa(components.DaemonPprofPort))
+		config.ExposedPorts[pprofPort] = struct{}{}
+		config.Cmd = append(config.Cmd, "--profiler-http",
+			fmt.Sprintf("--profiler-endpoint=0.0.0.0:%d", components.DaemonPprofPort))
+		host.PortBindings[pprofPort] = []nat.PortBinding{{HostIP: "127.0.0.1"}}
+
+		// the log levels of the subsystems are the ones of the command that
+		// starts the daemon
+		config.Cmd = append(config.Cmd, logging.Args(logging.Levels())...)
+
+		// the daemon can't run the credential helpers of the host, so it gets
+		// the credentials of the registries of the components
+		env, err := registryAuthEnv()
+		if err != nil {
+			logging.Daemon().Warningf("could not read the registry credentials for the daemon, "+
+				"the component images will be pulled anonymously: %s", err)
+		}
+
+		config.Env = append(config.Env, env...)
+
+		// the daemon queries the docker registries, so it always gets the
+		// proxy settings, which it passes to the components
+		docker.ApplyOptions(config, host, docker.WithProxy(
+			docker.ProxyFromEnvironment(), components.NoProxyHosts()...))
+
+		// the daemon only runs as another user on Linux, where it can be
+		// given access to the docker socket of the host with its group
+		if conf.Security.User != "" && runtime.GOOS == "linux" {
+			gid, err := socketGroup(dockerSocket)
+			if err != nil {
+				return errors.Wrapf(err, "could not get the group of the docker socket")
+			}
+
+			docker.ApplyOptions(config, host,
+				docker.WithUser(conf.Security.User),
+				docker.WithGroupAdd(append([]string{gid}, conf.Security.GroupAdd...)...),
+			)
+		}
+
 		return docker.Start(ctx, config, host, cmp.Name)
 	}
 }
 
-func datadir() (string, error) {
-	homedir, err := homedir.Dir()
+// registryAuthEnv returns the environment variable with the credentials of
+// the registries of the component images for the daemon, if there are any
+func registryAuthEnv() ([]string, error) {
+	var images []string
+	for _, cmp := range components.Upgradable() {
+		images = append(images, cmp.Image)
+	}
+
+	auths, err := docker.RegistryAuths(images...)
+	if err != nil || len(auths) == 0 {
+		return nil, err
+	}
+
+	b, err := json.Marshal(auths)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{"SRCD_REGISTRY_AUTH=" + string(b)}, nil
+}
+
+// uastCacheDir returns the directory of the cache of parsed UASTs, creating
+// it if needed
+func uastCacheDir() (string, error) {
+	dir, err := datadir()
+	if err != nil {
+		return "", err
+	}
+
+	dir = filepath.Join(dir, uastCacheDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "could not create UAST cache directory %s", dir)
+	}
+
+	return dir, nil
+}
+
+// AuditDir returns the directory of the audit log of the local daemon, see
+// the audit package
+func AuditDir() (string, error) {
+	dir, err := datadir()
 	if err != nil {
-		return "", errors.Wrap(err, "unable to get home dir")
+		return "", err
 	}
 
-	return filepath.Join(homedir, ".srcd"), nil
+	dir = filepath.Join(dir, auditDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "could not create audit log directory %s", dir)
+	}
+
+	return dir, nil
+}
+
+// DatasetsDir returns the directory of the public datasets, with a directory
+// per dataset, see srcd datasets get
+func DatasetsDir() (string, error) {
+	dir, err := config.RootDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, datasetsDirName), nil
+}
+
+// datadir returns the directory of the state file, which depends on the
+// profile
+func datadir() (string, error) {
+	return config.Dir()
 }
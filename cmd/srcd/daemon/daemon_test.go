@@ -0,0 +1,306 @@
+package daemon
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/components"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStartOptionsSameAs(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := startOptions{WorkDir: "/repos", Config: &api.Config{}}.resolved()
+	assert.False(opts.sameAs(nil))
+	assert.False(opts.sameAs(&startOptions{WorkDir: "/repos"}))
+
+	// the state saved by start has the defaults resolved
+	saved := startOptions{WorkDir: "/repos", Config: &api.Config{}}.resolved()
+	assert.True(opts.sameAs(&saved))
+
+	other := saved
+	other.WorkDir = "/other"
+	assert.False(opts.sameAs(&other))
+
+	other = saved
+	other.CliVersion = "v0.11.0"
+	assert.False(opts.sameAs(&other))
+
+	conf := *saved.Config
+	conf.Components.Gitbase.Port = 3307
+	other = saved
+	other.Config = &conf
+	assert.False(opts.sameAs(&other))
+
+	conf = *saved.Config
+	conf.SetComponentVersion(components.Gitbase.Name, "v0.0.1")
+	other.Config = &conf
+	assert.False(opts.sameAs(&other))
+}
+
+func TestSaveStateKeepsPrevious(t *testing.T) {
+	require := require.New(t)
+
+	home, err := ioutil.TempDir("", "srcd-home")
+	require.NoError(err)
+	defer os.RemoveAll(home)
+
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	require.NoError(os.Setenv("HOME", home))
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	name := components.Gitbase.Name
+	require.NoError(SetPreviousVersion(name, "v0.23.0"))
+
+	// a state with only the previous versions does not start the daemon
+	old, err := loadState()
+	require.NoError(err)
+	require.Nil(old)
+
+	old, err = readState()
+	require.NoError(err)
+
+	// the state saved by start and restart keeps the previous versions
+	opts := newStartOptions("/repos", old)
+	require.NoError(opts.Save())
+
+	version, err := PreviousVersion(name)
+	require.NoError(err)
+	require.Equal("v0.23.0", version)
+
+	state, err := loadState()
+	require.NoError(err)
+	require.Equal("/repos", state.WorkDir)
+
+	require.NoError(SetPreviousVersion(name, ""))
+	version, err = PreviousVersion(name)
+	require.NoError(err)
+	require.Empty(version)
+
+	// the working directory is not lost either
+	state, err = loadState()
+	require.NoError(err)
+	require.Equal("/repos", state.WorkDir)
+}
+
+func TestLocalClient(t *testing.T) {
+	require := require.New(t)
+
+	SetCliVersion("v0.13.0")
+	SetNoDaemon(true)
+	defer func() {
+		SetCliVersion("")
+		SetNoDaemon(false)
+		local = nil
+		capabilities = nil
+	}()
+
+	require.True(IsDaemonless())
+
+	client, err := Client()
+	require.NoError(err)
+
+	res, err := client.Version(context.Background(), &api.VersionRequest{})
+	require.NoError(err)
+	require.Equal("v0.13.0", res.Version)
+	require.Equal("v0.13.0", Capabilities().Version)
+	require.True(Supports(api.FeatureSearch))
+
+	// the remote daemon takes precedence
+	SetHost("my-server")
+	defer SetHost("")
+	require.False(IsDaemonless())
+}
+
+// capabilitiesClient is an EngineClient that only implements the methods
+// used by negotiate. A nil capabilities means the daemon predates them
+type capabilitiesClient struct {
+	api.EngineClient
+	capabilities *api.CapabilitiesResponse
+}
+
+func (c *capabilitiesClient) Version(ctx context.Context, in *api.VersionRequest, opts ...grpc.CallOption) (*api.VersionResponse, error) {
+	return &api.VersionResponse{Version: "v0.10.0"}, nil
+}
+
+func (c *capabilitiesClient) Capabilities(ctx context.Context, in *api.CapabilitiesRequest, opts ...grpc.CallOption) (*api.CapabilitiesResponse, error) {
+	if c.capabilities == nil {
+		return nil, status.Error(codes.Unimplemented, "unknown method Capabilities")
+	}
+
+	return c.capabilities, nil
+}
+
+func TestNegotiate(t *testing.T) {
+	require := require.New(t)
+
+	SetCliVersion("v0.14.0")
+	defer func() {
+		SetCliVersion("")
+		negotiated = map[string]*api.CapabilitiesResponse{}
+		capabilities = nil
+	}()
+
+	ctx := context.Background()
+
+	// the daemons without Capabilities are too old, and don't support any
+	// feature, but they can still be used
+	err := negotiate(ctx, "legacy", &capabilitiesClient{})
+	require.EqualError(err, "the daemon v0.10.0 is too old for srcd v0.14.0, "+
+		"run srcd init to restart it with srcd v0.14.0")
+	require.True(err.(*IncompatibleErr).Usable)
+	require.Equal("v0.10.0", Capabilities().Version)
+	require.False(Supports(api.FeatureSearch))
+	require.EqualError(RequireFeature(api.FeatureSearch),
+		"the daemon v0.10.0 does not support search, run srcd init to restart it with srcd v0.14.0")
+
+	current := &api.CapabilitiesResponse{
+		ApiVersion:    api.APIVersion,
+		MinApiVersion: api.MinAPIVersion,
+		Version:       "v0.14.0",
+		Features:      []string{api.FeatureSearch},
+	}
+	require.NoError(negotiate(ctx, "current", &capabilitiesClient{capabilities: current}))
+	require.True(Supports(api.FeatureSearch))
+	require.False(Supports(api.FeatureEvents))
+	require.NoError(RequireFeature(api.FeatureSearch))
+
+	// the capabilities are read once by daemon
+	require.NoError(negotiate(ctx, "legacy", &capabilitiesClient{capabilities: current}))
+	require.Equal("v0.10.0", Capabilities().Version)
+
+	newer := &api.CapabilitiesResponse{
+		ApiVersion:    api.APIVersion + 1,
+		MinApiVersion: api.APIVersion + 1,
+		Version:       "v1.0.0",
+	}
+	err = negotiate(ctx, "newer", &capabilitiesClient{capabilities: newer})
+	require.IsType(&IncompatibleErr{}, err)
+	require.EqualError(err, "srcd v0.14.0 is too old for the daemon v1.0.0, "+
+		"upgrade srcd to the same version as the daemon")
+	require.Nil(Capabilities())
+	require.True(Supports(api.FeatureSearch))
+
+	older := &api.CapabilitiesResponse{
+		ApiVersion:    api.MinAPIVersion - 1,
+		MinApiVersion: api.MinAPIVersion - 1,
+		Version:       "v0.0.1",
+	}
+	err = negotiate(ctx, "older", &capabilitiesClient{capabilities: older})
+	require.EqualError(err, "the daemon v0.0.1 is too old for srcd v0.14.0, "+
+		"run srcd init to restart it with srcd v0.14.0")
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	require := require.New(t)
+
+	SetCliVersion("v0.14.0")
+	defer SetCliVersion("")
+
+	current := &api.CapabilitiesResponse{
+		ApiVersion:    api.APIVersion,
+		MinApiVersion: api.MinAPIVersion,
+		Version:       "v0.14.0",
+		Features:      []string{api.FeatureSearch},
+	}
+	require.NoError(checkCapabilities(current, false))
+
+	// the daemons that predate Capabilities are always too old
+	legacy := &api.CapabilitiesResponse{ApiVersion: 1, MinApiVersion: 1, Version: "v0.10.0"}
+	err := checkCapabilities(legacy, true)
+	require.Equal(&IncompatibleErr{DaemonVersion: "v0.10.0", DaemonTooOld: true, Usable: true}, err)
+
+	older := *current
+	older.Version = "v0.13.2"
+	err = checkCapabilities(&older, false)
+	require.Equal(&IncompatibleErr{DaemonVersion: "v0.13.2", DaemonTooOld: true, Usable: true}, err)
+
+	// the daemons of newer or development versions with the same API are
+	// used as they are
+	newer := *current
+	newer.Version = "v0.15.0"
+	require.NoError(checkCapabilities(&newer, false))
+
+	dev := *current
+	dev.Version = "dev"
+	require.NoError(checkCapabilities(&dev, false))
+
+	unusable := *current
+	unusable.ApiVersion = api.MinAPIVersion - 1
+	err = checkCapabilities(&unusable, false)
+	require.Equal(&IncompatibleErr{DaemonVersion: "v0.14.0", DaemonTooOld: true}, err)
+
+	// the missing features make the daemon too old for the commands that
+	// require them
+	require.NoError(checkFeature(current, api.FeatureSearch))
+	require.NoError(checkFeature(nil, api.FeatureSearch))
+	err = checkFeature(current, api.FeatureJobs)
+	require.Equal(&UnsupportedErr{DaemonVersion: "v0.14.0", Feature: api.FeatureJobs}, err)
+}
+
+func TestRequireFeatureUpgrade(t *testing.T) {
+	require := require.New(t)
+
+	SetCliVersion("v0.14.0")
+	defer func() {
+		SetCliVersion("")
+		SetUpgradeConfirm(nil)
+		capabilities = nil
+	}()
+
+	capabilities = &api.CapabilitiesResponse{
+		ApiVersion:    api.APIVersion,
+		MinApiVersion: api.MinAPIVersion,
+		Version:       "v0.13.0",
+	}
+
+	// the upgrade is offered with the version of the daemon, and declining
+	// it fails like without the prompt
+	var asked string
+	SetUpgradeConfirm(func(v string) bool {
+		asked = v
+		return false
+	})
+
+	err := RequireFeature(api.FeatureJobs)
+	require.IsType(&UnsupportedErr{}, err)
+	require.Equal("v0.13.0", asked)
+
+	// the remote daemons are never upgraded
+	asked = ""
+	SetHost("my-server")
+	defer SetHost("")
+	require.IsType(&UnsupportedErr{}, RequireFeature(api.FeatureJobs))
+	require.Equal("", asked)
+}
+
+func TestRemoteClientToken(t *testing.T) {
+	require := require.New(t)
+
+	defer SetToken("")
+	SetToken("secret")
+
+	_, err := remoteClient("192.0.2.1:4242")
+	require.IsType(&InsecureTokenErr{}, err)
+	require.EqualError(err, "the token can't be sent to the daemon at 192.0.2.1:4242 over an "+
+		"unencrypted connection, reach it through an SSH tunnel instead, e.g. run "+
+		"ssh -N -L 4242:localhost:4242 192.0.2.1 and use --host localhost:4242")
+
+	require.True(isLoopback("localhost:4242"))
+	require.True(isLoopback("127.0.0.1:4242"))
+	require.True(isLoopback("[::1]:4242"))
+	require.False(isLoopback("192.0.2.1:4242"))
+	require.False(isLoopback("10.0.0.1"))
+}
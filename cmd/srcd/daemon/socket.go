@@ -0,0 +1,25 @@
+// +build !windows
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// socketGroup returns the gid of the owner group of the given unix socket
+func socketGroup(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("unable to get the owner of %s", path)
+	}
+
+	return strconv.FormatUint(uint64(st.Gid), 10), nil
+}
@@ -0,0 +1,9 @@
+package daemon
+
+import "fmt"
+
+// socketGroup is not implemented on Windows, where the daemon always runs as
+// root, as the docker socket is in the docker VM
+func socketGroup(path string) (string, error) {
+	return "", fmt.Errorf("not supported on windows")
+}
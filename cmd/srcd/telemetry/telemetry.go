@@ -0,0 +1,302 @@
+// Package telemetry records anonymous usage metrics of the srcd command, only
+// if the user opts in. The events are kept in the srcd data directory and sent
+// in batches to an HTTP endpoint.
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultEndpoint is where the events are sent if no other endpoint is
+	// configured
+	DefaultEndpoint = "https://telemetry.sourced.tech/engine/v1/events"
+
+	stateFileName  = "telemetry.json"
+	eventsFileName = "telemetry-events.jsonl"
+
+	// the events are sent when there are batchSize of them, or when the
+	// oldest one is older than batchMaxAge
+	batchSize   = 20
+	batchMaxAge = 24 * time.Hour
+	// maxEvents is the number of events kept when they can't be sent
+	maxEvents = 500
+
+	sendTimeout = 5 * time.Second
+)
+
+// Event is the record of a command run
+type Event struct {
+	// ID is the anonymous identifier of the installation, a random value
+	// generated when telemetry is enabled
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Version string    `json:"version"`
+	OS      string    `json:"os"`
+	// Components has the versions of the main components
+	Components map[string]string `json:"components"`
+	// Error is the category of the error returned by the command, if any.
+	// The error message is never recorded
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// state is persisted in the state file
+type state struct {
+	// Enabled is nil until the user is asked
+	Enabled *bool  `json:"enabled"`
+	ID      string `json:"id,omitempty"`
+}
+
+// Client records and sends the events. Its zero value is not valid, use New
+type Client struct {
+	dir      string
+	endpoint string
+	config   *bool
+	state    state
+}
+
+// New returns a Client that keeps its files in dir. config is the
+// telemetry.enabled value of the config file, nil if it is not set, which
+// takes precedence over the choice stored with SetEnabled. If endpoint is
+// empty DefaultEndpoint is used.
+func New(dir string, config *bool, endpoint string) (*Client, error) {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	c := &Client{dir: dir, endpoint: endpoint, config: config}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, stateFileName))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read telemetry state")
+	}
+
+	if err := json.Unmarshal(b, &c.state); err != nil {
+		return nil, errors.Wrap(err, "invalid telemetry state file")
+	}
+
+	return c, nil
+}
+
+// Enabled returns true if the events are recorded
+func (c *Client) Enabled() bool {
+	if c.config != nil {
+		return *c.config
+	}
+
+	return c.state.Enabled != nil && *c.state.Enabled
+}
+
+// Configured returns true if the choice is set in the config file, so the
+// user can't change it with SetEnabled
+func (c *Client) Configured() bool {
+	return c.config != nil
+}
+
+// Asked returns true if the user already chose whether to enable telemetry,
+// either in the config file or with SetEnabled
+func (c *Client) Asked() bool {
+	return c.config != nil || c.state.Enabled != nil
+}
+
+// ID returns the anonymous identifier sent with the events, or an empty
+// string if telemetry was never enabled
+func (c *Client) ID() string {
+	return c.state.ID
+}
+
+// Endpoint returns the URL the events are sent to
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+// SetEnabled stores the choice of the user. Disabling telemetry removes the
+// identifier and the events not sent yet
+func (c *Client) SetEnabled(enabled bool) error {
+	c.state.Enabled = &enabled
+	if enabled {
+		if err := c.ensureID(); err != nil {
+			return err
+		}
+	} else {
+		c.state.ID = ""
+		if err := os.Remove(c.eventsFile()); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "could not remove telemetry events")
+		}
+	}
+
+	return c.save()
+}
+
+func (c *Client) ensureID() error {
+	if c.state.ID != "" {
+		return nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return errors.Wrap(err, "could not generate telemetry id")
+	}
+
+	c.state.ID = hex.EncodeToString(b)
+	return c.save()
+}
+
+func (c *Client) save() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return errors.Wrap(err, "could not create srcd data directory")
+	}
+
+	b, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(c.dir, stateFileName), b, 0644)
+	return errors.Wrap(err, "could not write telemetry state")
+}
+
+func (c *Client) eventsFile() string {
+	return filepath.Join(c.dir, eventsFileName)
+}
+
+// Record stores the event to be sent later, setting its ID. It does nothing if
+// telemetry is not enabled
+func (c *Client) Record(e Event) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	// the id is missing if telemetry is enabled in the config file
+	if err := c.ensureID(); err != nil {
+		return err
+	}
+
+	e.ID = c.state.ID
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(c.eventsFile(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "could not open telemetry events")
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(b, '\n'))
+	return errors.Wrap(err, "could not write telemetry event")
+}
+
+// Pending returns the recorded events that were not sent yet
+func (c *Client) Pending() ([]Event, error) {
+	f, err := os.Open(c.eventsFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open telemetry events")
+	}
+	defer f.Close()
+
+	var events []Event
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e Event
+		// a line can be truncated if srcd was killed while writing it
+		if err := json.Unmarshal(s.Bytes(), &e); err == nil {
+			events = append(events, e)
+		}
+	}
+
+	return events, s.Err()
+}
+
+// Flush sends the pending events if there are enough of them, or if they are
+// too old. If they can't be sent they are kept for the next time, up to a
+// limit
+func (c *Client) Flush(ctx context.Context) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	events, err := c.Pending()
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	if len(events) < batchSize && time.Since(events[0].Time) < batchMaxAge {
+		return nil
+	}
+
+	if err := c.send(ctx, events); err != nil {
+		if len(events) > maxEvents {
+			return c.rewrite(events[len(events)-maxEvents:])
+		}
+
+		return err
+	}
+
+	return c.rewrite(nil)
+}
+
+func (c *Client) send(ctx context.Context, events []Event) error {
+	b, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "could not send telemetry events")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("could not send telemetry events: %s", res.Status)
+	}
+
+	return nil
+}
+
+func (c *Client) rewrite(events []Event) error {
+	var buf bytes.Buffer
+	for _, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(append(b, '\n'))
+	}
+
+	err := ioutil.WriteFile(c.eventsFile(), buf.Bytes(), 0644)
+	return errors.Wrap(err, "could not write telemetry events")
+}
@@ -0,0 +1,148 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tmpDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "srcd-telemetry")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestClientNotAsked(t *testing.T) {
+	require := require.New(t)
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	c, err := New(dir, nil, "")
+	require.NoError(err)
+	require.False(c.Asked())
+	require.False(c.Enabled())
+	require.Equal(DefaultEndpoint, c.Endpoint())
+
+	require.NoError(c.Record(Event{Command: "init"}))
+	events, err := c.Pending()
+	require.NoError(err)
+	require.Empty(events)
+}
+
+func TestClientSetEnabled(t *testing.T) {
+	require := require.New(t)
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	c, err := New(dir, nil, "")
+	require.NoError(err)
+	require.NoError(c.SetEnabled(true))
+	require.True(c.Enabled())
+	require.NotEmpty(c.ID())
+
+	require.NoError(c.Record(Event{Command: "init"}))
+
+	// the choice and id are kept
+	c, err = New(dir, nil, "")
+	require.NoError(err)
+	require.True(c.Asked())
+	require.True(c.Enabled())
+
+	events, err := c.Pending()
+	require.NoError(err)
+	require.Len(events, 1)
+	require.Equal("init", events[0].Command)
+	require.Equal(c.ID(), events[0].ID)
+
+	require.NoError(c.SetEnabled(false))
+	require.True(c.Asked())
+	require.False(c.Enabled())
+	require.Empty(c.ID())
+
+	events, err = c.Pending()
+	require.NoError(err)
+	require.Empty(events)
+}
+
+func TestClientConfigured(t *testing.T) {
+	require := require.New(t)
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	disabled := false
+	c, err := New(dir, &disabled, "")
+	require.NoError(err)
+	require.NoError(c.SetEnabled(true))
+	require.True(c.Asked())
+	require.True(c.Configured())
+	require.False(c.Enabled())
+
+	enabled := true
+	c, err = New(dir, &enabled, "")
+	require.NoError(err)
+	require.NoError(c.SetEnabled(false))
+	require.True(c.Enabled())
+
+	require.NoError(c.Record(Event{Command: "init"}))
+	require.NotEmpty(c.ID())
+}
+
+func TestClientFlush(t *testing.T) {
+	require := require.New(t)
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	var received []Event
+	status := http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []Event
+		require.NoError(json.NewDecoder(r.Body).Decode(&events))
+		if status == http.StatusOK {
+			received = append(received, events...)
+		}
+
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c, err := New(dir, nil, srv.URL)
+	require.NoError(err)
+	require.NoError(c.SetEnabled(true))
+
+	// not enough events to send them
+	require.NoError(c.Record(Event{Command: "init", Time: time.Now()}))
+	require.NoError(c.Flush(context.Background()))
+	require.Empty(received)
+
+	for i := 1; i < batchSize; i++ {
+		require.NoError(c.Record(Event{Command: "sql", Time: time.Now()}))
+	}
+
+	// the events are kept if they can't be sent
+	require.Error(c.Flush(context.Background()))
+	events, err := c.Pending()
+	require.NoError(err)
+	require.Len(events, batchSize)
+
+	status = http.StatusOK
+	require.NoError(c.Flush(context.Background()))
+	require.Len(received, batchSize)
+	require.Equal("init", received[0].Command)
+
+	events, err = c.Pending()
+	require.NoError(err)
+	require.Empty(events)
+
+	// old events are sent even if there are not enough of them
+	received = nil
+	require.NoError(c.Record(Event{Command: "init", Time: time.Now().Add(-2 * batchMaxAge)}))
+	require.NoError(c.Flush(context.Background()))
+	require.Len(received, 1)
+}
@@ -0,0 +1,76 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+const changelogURL = "https://raw.githubusercontent.com/src-d/engine/master/CHANGELOG.md"
+
+// changelogHeaderRegexp matches the header of a release in the changelog, e.g.
+// "## [v0.12.0](https://github.com/src-d/engine/releases/tag/v0.12.0) - 2019-04-04"
+var changelogHeaderRegexp = regexp.MustCompile(`^## \[([^\]]+)\]`)
+
+// Notes are the changelog entries of a release
+type Notes struct {
+	Version semver.Version
+	Tag     string
+	Text    string
+}
+
+// ReleaseNotes returns the changelog entries of the released versions equal
+// to or newer than the given one, starting with the newest
+func ReleaseNotes(ctx context.Context, from semver.Version) ([]Notes, error) {
+	b, err := get(ctx, changelogURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseChangelog(b, from), nil
+}
+
+func parseChangelog(changelog []byte, from semver.Version) []Notes {
+	var notes []Notes
+	var current *Notes
+	var text []string
+
+	flush := func() {
+		if current != nil {
+			current.Text = strings.TrimSpace(strings.Join(text, "\n"))
+			notes = append(notes, *current)
+		}
+
+		current, text = nil, nil
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(changelog))
+	for s.Scan() {
+		line := s.Text()
+		match := changelogHeaderRegexp.FindStringSubmatch(line)
+		if match == nil {
+			if current != nil {
+				text = append(text, line)
+			}
+
+			continue
+		}
+
+		flush()
+
+		// the unreleased changes are skipped
+		v, err := semver.ParseTolerant(match[1])
+		if err != nil || v.LT(from) {
+			continue
+		}
+
+		current = &Notes{Version: v, Tag: match[1]}
+	}
+
+	flush()
+	return notes
+}
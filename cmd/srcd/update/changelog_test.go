@@ -0,0 +1,67 @@
+package update
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/require"
+)
+
+const testChangelog = `# Changelog
+
+## [Unreleased]
+<details>
+
+### New Features
+
+- Unreleased feature.
+
+</details>
+
+## [v0.13.0](https://github.com/src-d/engine/releases/tag/v0.13.0) - 2019-05-01
+
+### Breaking Changes
+
+- Something was removed.
+
+## [v0.12.1](https://github.com/src-d/engine/releases/tag/v0.12.1) - 2019-04-10
+
+### Bug Fixes
+
+- Something was fixed.
+
+## [v0.12.0](https://github.com/src-d/engine/releases/tag/v0.12.0) - 2019-04-04
+
+### New Features
+
+- Something was added.
+`
+
+func TestParseChangelog(t *testing.T) {
+	require := require.New(t)
+
+	notes := parseChangelog([]byte(testChangelog), semver.MustParse("0.12.1"))
+	require.Len(notes, 2)
+
+	require.Equal("v0.13.0", notes[0].Tag)
+	require.Equal("### Breaking Changes\n\n- Something was removed.", notes[0].Text)
+	require.Equal("v0.12.1", notes[1].Tag)
+	require.Equal("### Bug Fixes\n\n- Something was fixed.", notes[1].Text)
+
+	notes = parseChangelog([]byte(testChangelog), semver.MustParse("0.14.0"))
+	require.Len(notes, 0)
+}
+
+func TestReleaseNotes(t *testing.T) {
+	require := require.New(t)
+
+	githubClient = newMockedClient(map[string][]byte{
+		"/src-d/engine/master/CHANGELOG.md": []byte(testChangelog),
+	})
+
+	notes, err := ReleaseNotes(context.Background(), semver.MustParse("0.13.0"))
+	require.NoError(err)
+	require.Len(notes, 1)
+	require.Equal("v0.13.0", notes[0].Tag)
+}
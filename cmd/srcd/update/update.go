@@ -0,0 +1,288 @@
+// Package update finds the srcd releases published in GitHub, and replaces the
+// running binary with one of them.
+package update
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/src-d/engine/docker"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+const (
+	releasesURL = "https://api.github.com/repos/src-d/engine/releases"
+
+	// checksumsAsset is the release asset with the sha256 of the packages, in
+	// the format of sha256sum
+	checksumsAsset = "checksums.txt"
+
+	// maxBinarySize limits the size of the binary extracted from a package
+	maxBinarySize = 200 << 20
+)
+
+// put client into variable to make it mockable for tests
+var githubClient = &http.Client{Timeout: 5 * time.Minute}
+
+// Release is a published version of srcd
+type Release struct {
+	Version semver.Version
+	Tag     string
+	// Assets has the download URL of each file of the release, by name
+	Assets map[string]string
+}
+
+// Releases returns the published releases of srcd, excluding drafts and
+// pre-releases
+func Releases(ctx context.Context) ([]Release, error) {
+	req, err := http.NewRequest("GET", releasesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	res, err := githubClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't request the list of releases in GitHub")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("incorrect status code: %d while requesting the list of releases in GitHub", res.StatusCode)
+	}
+
+	var body []struct {
+		TagName    string `json:"tag_name"`
+		Draft      bool   `json:"draft"`
+		Prerelease bool   `json:"prerelease"`
+		Assets     []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "can't parse the list of releases in GitHub")
+	}
+
+	var releases []Release
+	for _, r := range body {
+		if r.Draft || r.Prerelease {
+			continue
+		}
+
+		v, err := semver.ParseTolerant(r.TagName)
+		if err != nil || len(v.Pre) > 0 {
+			continue
+		}
+
+		release := Release{Version: v, Tag: r.TagName, Assets: make(map[string]string)}
+		for _, a := range r.Assets {
+			release.Assets[a.Name] = a.URL
+		}
+
+		releases = append(releases, release)
+	}
+
+	return releases, nil
+}
+
+// Find returns the newest release compatible with the current version, and
+// the newest release, that can have breaking changes. Any of them is nil if
+// there is no such release newer than current
+func Find(releases []Release, current semver.Version) (compatible, latest *Release) {
+	tags := make([]string, len(releases))
+	for i, r := range releases {
+		tags[i] = r.Tag
+	}
+
+	newest, _ := docker.CompatibleVersion(tags, current)
+	for i, r := range releases {
+		if !r.Version.GT(current) {
+			continue
+		}
+
+		if r.Version.Equals(newest) {
+			compatible = &releases[i]
+		}
+
+		if latest == nil || r.Version.GT(latest.Version) {
+			latest = &releases[i]
+		}
+	}
+
+	return compatible, latest
+}
+
+// PackageName returns the name of the release asset with the srcd binary for
+// the given platform
+func PackageName(tag, goos, goarch string) string {
+	return fmt.Sprintf("engine_%s_%s_%s.tar.gz", tag, goos, goarch)
+}
+
+// Download returns the srcd binary of the release for the running platform,
+// after verifying the checksum of the package
+func Download(ctx context.Context, r *Release) ([]byte, error) {
+	name := PackageName(r.Tag, runtime.GOOS, runtime.GOARCH)
+	url, ok := r.Assets[name]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no package for %s/%s", r.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+
+	checksumsURL, ok := r.Assets[checksumsAsset]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no checksums, it can't be verified", r.Tag)
+	}
+
+	checksums, err := get(ctx, checksumsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := findChecksum(checksums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := sha256.Sum256(pkg); hex.EncodeToString(actual[:]) != sum {
+		return nil, fmt.Errorf("checksum of %s does not match, the download is corrupted", name)
+	}
+
+	binary := "srcd"
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+
+	return extract(pkg, path.Join(fmt.Sprintf("engine_%s_%s", runtime.GOOS, runtime.GOARCH), binary))
+}
+
+func get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := githubClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't download %s", url)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("incorrect status code: %d while downloading %s", res.StatusCode, url)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	return b, errors.Wrapf(err, "can't download %s", url)
+}
+
+// findChecksum returns the sha256 of the file in the output of sha256sum
+func findChecksum(checksums []byte, name string) (string, error) {
+	s := bufio.NewScanner(bytes.NewReader(checksums))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		// sha256sum prefixes the name with * in binary mode
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	return "", fmt.Errorf("there is no checksum for %s", name)
+}
+
+// extract returns the contents of the file with the given path in a gzipped
+// tarball
+func extract(pkg []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(pkg))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid release package")
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("release package has no file %s", name)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid release package")
+		}
+
+		if path.Clean(h.Name) != name {
+			continue
+		}
+
+		b, err := ioutil.ReadAll(io.LimitReader(tr, maxBinarySize))
+		return b, errors.Wrap(err, "invalid release package")
+	}
+}
+
+// Replace atomically replaces the file with the given binary, keeping
+// its permissions. The new file is written next to it and renamed, so the file is
+// never left half written
+func Replace(file string, binary []byte) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return errors.Wrap(err, "could not write the new binary")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "could not write the new binary")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "could not write the new binary")
+	}
+
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return errors.Wrap(err, "could not write the new binary")
+	}
+
+	if runtime.GOOS != "windows" {
+		return errors.Wrap(os.Rename(tmp.Name(), file), "could not replace the binary")
+	}
+
+	// windows can't replace a running executable, but it can rename it
+	old := file + ".old"
+	os.Remove(old)
+	if err := os.Rename(file, old); err != nil {
+		return errors.Wrap(err, "could not replace the binary")
+	}
+
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Rename(old, file)
+		return errors.Wrap(err, "could not replace the binary")
+	}
+
+	return nil
+}
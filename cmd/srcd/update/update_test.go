@@ -0,0 +1,160 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	require := require.New(t)
+
+	var releases []Release
+	for _, tag := range []string{"v0.10.0", "v0.10.1", "v0.11.0", "v0.12.0"} {
+		releases = append(releases, Release{Version: semver.MustParse(tag[1:]), Tag: tag})
+	}
+
+	compatible, latest := Find(releases, semver.MustParse("0.10.0"))
+	require.Equal("v0.10.1", compatible.Tag)
+	require.Equal("v0.12.0", latest.Tag)
+
+	compatible, latest = Find(releases, semver.MustParse("0.11.0"))
+	require.Nil(compatible)
+	require.Equal("v0.12.0", latest.Tag)
+
+	compatible, latest = Find(releases, semver.MustParse("0.12.0"))
+	require.Nil(compatible)
+	require.Nil(latest)
+}
+
+func TestReleases(t *testing.T) {
+	require := require.New(t)
+
+	githubClient = newMockedClient(map[string][]byte{
+		"/repos/src-d/engine/releases": []byte(`[
+			{"tag_name": "v0.12.0-rc1", "prerelease": true},
+			{"tag_name": "v0.11.0", "assets": [{"name": "checksums.txt", "browser_download_url": "https://github.com/checksums.txt"}]},
+			{"tag_name": "v0.10.0", "draft": true}
+		]`),
+	})
+
+	releases, err := Releases(context.Background())
+	require.NoError(err)
+	require.Len(releases, 1)
+	require.Equal("v0.11.0", releases[0].Tag)
+	require.Equal("https://github.com/checksums.txt", releases[0].Assets["checksums.txt"])
+}
+
+func TestDownload(t *testing.T) {
+	require := require.New(t)
+
+	name := PackageName("v0.11.0", runtime.GOOS, runtime.GOARCH)
+	binary := "srcd"
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+
+	pkg := newPackage(t, fmt.Sprintf("engine_%s_%s/%s", runtime.GOOS, runtime.GOARCH, binary), "new binary")
+	sum := sha256.Sum256(pkg)
+
+	release := &Release{Tag: "v0.11.0", Assets: map[string]string{
+		name:           "https://github.com/" + name,
+		checksumsAsset: "https://github.com/checksums.txt",
+	}}
+
+	githubClient = newMockedClient(map[string][]byte{
+		"/" + name:       pkg,
+		"/checksums.txt": []byte(hex.EncodeToString(sum[:]) + "  " + name + "\n"),
+	})
+
+	b, err := Download(context.Background(), release)
+	require.NoError(err)
+	require.Equal("new binary", string(b))
+
+	githubClient = newMockedClient(map[string][]byte{
+		"/" + name:       pkg,
+		"/checksums.txt": []byte(hex.EncodeToString(make([]byte, 32)) + "  " + name + "\n"),
+	})
+
+	_, err = Download(context.Background(), release)
+	require.EqualError(err, fmt.Sprintf("checksum of %s does not match, the download is corrupted", name))
+
+	delete(release.Assets, checksumsAsset)
+	_, err = Download(context.Background(), release)
+	require.EqualError(err, "release v0.11.0 has no checksums, it can't be verified")
+}
+
+func TestReplace(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-update")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "srcd")
+	require.NoError(ioutil.WriteFile(file, []byte("old binary"), 0755))
+
+	require.NoError(Replace(file, []byte("new binary")))
+
+	b, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	require.Equal("new binary", string(b))
+
+	info, err := os.Stat(file)
+	require.NoError(err)
+	if runtime.GOOS != "windows" {
+		require.Equal(os.FileMode(0755), info.Mode())
+	}
+}
+
+func newPackage(t *testing.T, name, content string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	return buf.Bytes()
+}
+
+func newMockedClient(files map[string][]byte) *http.Client {
+	mockedT := roundTripFunc(func(req *http.Request) *http.Response {
+		if b, ok := files[req.URL.Path]; ok {
+			return newResponse(200, b)
+		}
+
+		return newResponse(404, nil)
+	})
+	return &http.Client{Transport: mockedT}
+}
+
+type roundTripFunc func(req *http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+func newResponse(status int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Header:     make(http.Header),
+	}
+}
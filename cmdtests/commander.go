@@ -47,7 +47,22 @@ func (s *Commander) RunInit(workdir string) *icmd.Result {
 
 // RunInitWithTimeout runs srcd init with workdir and custom config for integration tests with timeout
 func (s *Commander) RunInitWithTimeout(workdir string, timeout time.Duration) *icmd.Result {
+	return s.runInit(workdir, timeout)
+}
+
+// RunInitForce runs srcd init --force with workdir and custom config for integration tests
+func (s *Commander) RunInitForce(workdir string) *icmd.Result {
+	return s.runInit(workdir, 0, "--force")
+}
+
+// RunInitStart runs srcd init --start with workdir and custom config for integration tests
+func (s *Commander) RunInitStart(workdir string) *icmd.Result {
+	return s.runInit(workdir, 0, "--start")
+}
+
+func (s *Commander) runInit(workdir string, timeout time.Duration, extraArgs ...string) *icmd.Result {
 	_, filename, _, _ := runtime.Caller(0)
 	configFile := path.Join(path.Dir(filename), "..", "integration-testing-config.yaml")
-	return s.RunCmd("init", []string{workdir, "--config", configFile}, icmd.WithTimeout(timeout))
+	args := append([]string{workdir, "--config", configFile}, extraArgs...)
+	return s.RunCmd("init", args, icmd.WithTimeout(timeout))
 }
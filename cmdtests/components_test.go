@@ -33,6 +33,9 @@ func (s *ComponentsTestSuite) TestListStopped() {
 		`^IMAGE +INSTALLED +RUNNING +PORT +CONTAINER NAME
 bblfsh/bblfshd:\S+ +(yes|no) +no +(\d+)? +srcd-cli-bblfshd
 bblfsh/web:\S+ +(yes|no) +no +(\d+)? +srcd-cli-bblfsh-web
+etsy/hound:\S+ +(yes|no) +no +(\d+)? +srcd-cli-search
+jupyter/pyspark-notebook:\S+ +(yes|no) +no +(\d+)? +srcd-cli-spark
+jupyter/scipy-notebook:\S+ +(yes|no) +no +(\d+)? +srcd-cli-notebook
 mysql:\S+ +(yes|no) +no +(\d+)? +srcd-cli-mysql-cli
 srcd/cli-daemon:\S+ +(yes|no) +no +(\d+)? +srcd-cli-daemon
 srcd/gitbase-web:\S+ +(yes|no) +no +(\d+)? +srcd-cli-gitbase-web
@@ -86,7 +89,7 @@ func (s *ComponentsTestSuite) TestInstall() {
 	require.Regexp(expected, r.Stdout())
 
 	// Install
-	r = s.RunCommand("components", "install", "srcd/gitbase")
+	r = s.RunCommand("components", "install", "--wait", "srcd/gitbase")
 	require.NoError(r.Error, r.Combined())
 
 	// Check it's installed
@@ -97,7 +100,7 @@ func (s *ComponentsTestSuite) TestInstall() {
 	require.Regexp(expected, r.Stdout())
 
 	// Call install again, should be an exit 0
-	r = s.RunCommand("components", "install", "srcd/gitbase")
+	r = s.RunCommand("components", "install", "--wait", "srcd/gitbase")
 	require.NoError(r.Error, r.Combined())
 }
 
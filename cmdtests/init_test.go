@@ -14,6 +14,7 @@ import (
 
 	"github.com/src-d/engine/cmdtests"
 	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -122,9 +123,16 @@ func (s *InitTestSuite) TestWithRunningDaemon() {
 	require.NoError(r.Error, r.Combined())
 
 	actualMsg := s.getLogMessages(r.Combined())
+	require.Contains(actualMsg, "daemon is already running with the same working directory and config, use --force to recreate it")
+	require.NotContains(actualMsg, logMsg("removing container %s", components.Daemon.Name))
+
+	r = s.RunInitForce(s.validWorkDir)
+	require.NoError(r.Error, r.Combined())
+
+	actualMsg = s.getLogMessages(r.Combined())
 
 	expectedMsg := [3]string{
-		logMsg("removing container %s", components.Daemon.Name),
+		logMsg("stopping container %s", components.Daemon.Name),
 		logMsg("starting daemon with working directory: %s", s.validWorkDir),
 		"daemon started",
 	}
@@ -143,15 +151,15 @@ func (s *InitTestSuite) TestWithRunningOtherComponents() {
 	r = s.RunCommand("sql", "select 1")
 	require.NoError(r.Error, r.Combined())
 
-	r = s.RunInit(s.validWorkDir)
+	r = s.RunInitForce(s.validWorkDir)
 	require.NoError(r.Error, r.Combined())
 
 	actualMsg := s.getLogMessages(r.Combined())
 
 	expectedMsg := [5]string{
-		logMsg("removing container %s", components.Bblfshd.Name),
-		logMsg("removing container %s", components.Daemon.Name),
-		logMsg("removing container %s", components.Gitbase.Name),
+		logMsg("stopping container %s", components.Bblfshd.Name),
+		logMsg("stopping container %s", components.Daemon.Name),
+		logMsg("stopping container %s", components.Gitbase.Name),
 		logMsg("starting daemon with working directory: %s", s.validWorkDir),
 		"daemon started",
 	}
@@ -161,6 +169,25 @@ func (s *InitTestSuite) TestWithRunningOtherComponents() {
 	}
 }
 
+func (s *InitTestSuite) TestStart() {
+	require := s.Require()
+
+	r := s.RunInitStart(s.validWorkDir)
+	require.NoError(r.Error, r.Combined())
+
+	for _, name := range []string{
+		components.Bblfshd.Name,
+		components.Gitbase.Name,
+		components.BblfshWeb.Name,
+		components.GitbaseWeb.Name,
+	} {
+		running, err := docker.IsRunning(name, "")
+		require.NoError(err)
+		require.Truef(running, "component %s should be running", name)
+		require.Contains(r.Combined(), fmt.Sprintf("started %s on port", name))
+	}
+}
+
 func (s *InitTestSuite) initGitRepo(path string) {
 	s.T().Helper()
 
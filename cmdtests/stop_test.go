@@ -68,3 +68,81 @@ func (s *StopTestSuite) TestMissingContainers() {
 
 	s.AllStopped()
 }
+
+func (s *StopTestSuite) TestStopComponent() {
+	require := s.Require()
+
+	r := s.RunInit(s.TestDir)
+	require.NoError(r.Error, r.Combined())
+
+	r = s.RunCommand("sql", "SELECT 1")
+	require.NoError(r.Error, r.Combined())
+
+	// gitbase depends on bblfshd, and keeps running
+	r = s.RunCommand("stop", "srcd-cli-bblfshd")
+	require.NoError(r.Error, r.Combined())
+	require.Contains(r.Stderr(), "srcd-cli-gitbase depends on srcd-cli-bblfshd")
+
+	for name, want := range map[string]bool{
+		"srcd-cli-daemon":  true,
+		"srcd-cli-gitbase": true,
+		"srcd-cli-bblfshd": false,
+	} {
+		running, err := docker.IsRunning(name, "")
+		require.NoError(err)
+		require.Equal(want, running, name)
+	}
+
+	r = s.RunCommand("stop", "--all", "srcd-cli-gitbase")
+	require.Error(r.Error)
+
+	r = s.RunCommand("stop", "--all")
+	require.NoError(r.Error, r.Combined())
+
+	s.AllStopped()
+}
+
+func (s *StopTestSuite) TestPauseStart() {
+	require := s.Require()
+
+	r := s.RunInit(s.TestDir)
+	require.NoError(r.Error, r.Combined())
+
+	r = s.RunCommand("sql", "SELECT 1")
+	require.NoError(r.Error, r.Combined())
+
+	names := []string{"srcd-cli-daemon", "srcd-cli-gitbase", "srcd-cli-bblfshd"}
+	ids := make(map[string]string)
+	for _, name := range names {
+		info, err := docker.Info(name)
+		require.NoError(err)
+		ids[name] = info.ID
+	}
+
+	r = s.RunCommand("stop", "--pause")
+	require.NoError(r.Error, r.Combined())
+
+	for _, name := range names {
+		running, err := docker.IsRunning(name, "")
+		require.NoError(err)
+		require.False(running, name)
+	}
+
+	r = s.RunCommand("start")
+	require.NoError(r.Error, r.Combined())
+
+	// the same containers are started again, not new ones
+	for _, name := range names {
+		info, err := docker.Info(name)
+		require.NoError(err)
+		require.Equal(ids[name], info.ID, name)
+		require.Equal("running", info.State, name)
+	}
+
+	// there is nothing left to start
+	r = s.RunCommand("start")
+	require.Error(r.Error)
+
+	r = s.RunCommand("stop")
+	require.NoError(r.Error, r.Combined())
+}
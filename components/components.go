@@ -22,10 +22,22 @@ func SetCliVersion(v string) {
 	Daemon.Version = v
 }
 
+// components of the plugins set by src-d command
+var plugins []Component
+
+// SetPlugins sets the plugin components, included in List along with the
+// built-in ones
+func SetPlugins(cmps []Component) {
+	plugins = cmps
+}
+
 type Component struct {
 	Name    string
 	Image   string
 	Version string // only if there's a required version
+	// StopTimeout is the grace period given to the container to exit after
+	// a SIGTERM before it is killed. If 0, DefaultStopTimeout is used
+	StopTimeout time.Duration
 
 	retrieveVersionFunc func(*Component) (string, bool, error)
 }
@@ -44,6 +56,25 @@ func (c *Component) Kill() error {
 	return nil
 }
 
+// Stop gracefully stops and removes the Component container, giving it its
+// grace period to exit. If it is not running it returns nil
+func (c *Component) Stop() error {
+	err := docker.StopContainer(c.Name, c.gracePeriod())
+	if err != nil && err != docker.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Component) gracePeriod() time.Duration {
+	if c.StopTimeout > 0 {
+		return c.StopTimeout
+	}
+
+	return DefaultStopTimeout
+}
+
 // IsInstalled returns true if the Component image is installed with the
 // exact version
 func (c *Component) IsInstalled() (bool, error) {
@@ -101,6 +132,8 @@ var (
 		Name:    "srcd-cli-gitbase",
 		Image:   "srcd/gitbase",
 		Version: "v0.19.0",
+		// gitbase needs time to flush the indexes to disk
+		StopTimeout: 60 * time.Second,
 	}
 
 	GitbaseWeb = Component{
@@ -121,15 +154,94 @@ var (
 		Version: "v0.9.0",
 	}
 
+	// Search is the optional code search component, only started when it is
+	// used for the first time
+	Search = Component{
+		Name:    "srcd-cli-search",
+		Image:   "etsy/hound",
+		Version: "v0.4.0",
+	}
+
+	// Analytics is the optional Metabase dashboard component, only started
+	// when it is used with srcd web analytics
+	Analytics = Component{
+		Name:    "srcd-cli-analytics",
+		Image:   "metabase/metabase",
+		Version: "v0.33.6",
+	}
+
+	Notebook = Component{
+		Name:    "srcd-cli-notebook",
+		Image:   "jupyter/scipy-notebook",
+		Version: "notebook-6.0.0",
+	}
+
+	// Spark is the Jupyter notebook with PySpark of srcd spark, which reads
+	// the gitbase tables with JDBC
+	Spark = Component{
+		Name:    "srcd-cli-spark",
+		Image:   "jupyter/pyspark-notebook",
+		Version: "notebook-6.0.0",
+	}
+
 	MysqlCli = Component{
 		Name:    "srcd-cli-mysql-cli",
 		Image:   "mysql",
 		Version: "8",
 	}
 
+	// Jaeger receives and shows the traces of srcd --trace, when no
+	// tracing endpoint is set. It is started by the cli
+	Jaeger = Component{
+		Name:    "srcd-cli-jaeger",
+		Image:   "jaegertracing/all-in-one",
+		Version: "1.57",
+	}
+
+	// RetrievalBroker and RetrievalDB are the RabbitMQ queue and the
+	// PostgreSQL database of the retrieval pipeline of srcd retrieve
+	RetrievalBroker = Component{
+		Name:    "srcd-cli-retrieval-broker",
+		Image:   "rabbitmq",
+		Version: "3.7-alpine",
+	}
+
+	RetrievalDB = Component{
+		Name:    "srcd-cli-retrieval-db",
+		Image:   "postgres",
+		Version: "9.6-alpine",
+	}
+
+	// Borges downloads the repositories queued by srcd retrieve into rooted
+	// siva files. BorgesProducer queues the ones found by Rovers
+	Borges = Component{
+		Name:    "srcd-cli-borges",
+		Image:   "srcd/borges",
+		Version: "v0.17.1",
+		// borges needs time to finish the siva file it is writing
+		StopTimeout: 60 * time.Second,
+	}
+
+	BorgesProducer = Component{
+		Name:    "srcd-cli-borges-producer",
+		Image:   "srcd/borges",
+		Version: "v0.17.1",
+	}
+
+	// Rovers discovers the repositories of GitHub for srcd retrieve
+	// --discover
+	Rovers = Component{
+		Name:    "srcd-cli-rovers",
+		Image:   "srcd/rovers",
+		Version: "v2.6.0",
+	}
+
 	Daemon = Component{
 		Name:  "srcd-cli-daemon",
 		Image: "srcd/cli-daemon",
+		// the daemon waits for the running SQL and parse requests before
+		// it exits, see DaemonShutdownTimeout
+		StopTimeout: DaemonShutdownTimeout + 15*time.Second,
 		// Version
 		retrieveVersionFunc: daemonRetrieveVersion,
 	}
@@ -137,10 +249,72 @@ var (
 	workDirDependants = []Component{
 		Daemon,
 		Gitbase,
+		Search,
 		Bblfshd, // does not depend on workdir but it does depend on user dir
 	}
 )
 
+// upgradable returns the components managed by the daemon, whose image
+// version can be changed with SetVersions, after their dependencies
+func upgradable() []*Component {
+	return []*Component{&Bblfshd, &Gitbase, &BblfshWeb, &GitbaseWeb, &Search, &Analytics}
+}
+
+// Upgradable returns the components managed by the daemon, with their current
+// image version, after their dependencies. The versions of the daemon and the
+// components run by the cli, like the notebook, are fixed
+func Upgradable() []Component {
+	var cmps []Component
+	for _, c := range upgradable() {
+		cmps = append(cmps, *c)
+	}
+
+	return cmps
+}
+
+// NoProxyHosts returns the hosts the containers reach without going through
+// an HTTP proxy: the loopback and the container names of the daemon and the
+// Upgradable components, which are their hostnames in the srcd network
+func NoProxyHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", Daemon.Name}
+	for _, c := range upgradable() {
+		hosts = append(hosts, c.Name)
+	}
+
+	return hosts
+}
+
+// SetVersions sets the image version of the Upgradable components, given by
+// container name. Other names are ignored
+func SetVersions(versions map[string]string) {
+	for _, c := range upgradable() {
+		if v, ok := versions[c.Name]; ok && v != "" {
+			c.Version = v
+		}
+	}
+}
+
+// DefaultStopTimeout is the grace period given to the components that don't
+// set their own StopTimeout
+const DefaultStopTimeout = 10 * time.Second
+
+// ComponentGracePeriod is the timeout to pass to Stop, Pause and
+// StopComponents to give each container the grace period of its component
+// instead of the same one for all
+const ComponentGracePeriod time.Duration = -1
+
+// the docker functions used to stop the containers, vars so the tests can
+// replace them
+var (
+	stopContainerFunc  = docker.StopContainer
+	pauseContainerFunc = docker.PauseContainer
+)
+
+// DaemonShutdownTimeout is how long the daemon waits for the running SQL and
+// parse requests when it is stopped. The grace period of Daemon is longer, so
+// docker doesn't kill it before they finish
+const DaemonShutdownTimeout = 45 * time.Second
+
 const (
 	// BblfshParsePort is the Bblfsh private port for parse requests
 	BblfshParsePort = 9432
@@ -155,8 +329,31 @@ const (
 	// GitbasePort is the Gitbase private port
 	GitbasePort = 3306
 
+	// SearchPort is the Search private port
+	SearchPort = 6080
+
+	// AnalyticsPort is the Analytics private port
+	AnalyticsPort = 3000
+
+	// NotebookPort is the Notebook private port
+	NotebookPort = 8888
+	// SparkNotebookPort is the default public port of the Spark notebook,
+	// whose private port is NotebookPort, so both notebooks can run at once
+	SparkNotebookPort = 8889
+	// SparkUIPort is the Spark private port for its web UI
+	SparkUIPort = 4040
+
+	// JaegerUIPort is the Jaeger private port for its web UI
+	JaegerUIPort = 16686
+	// JaegerOTLPPort is the Jaeger private port for the OTLP/HTTP spans
+	JaegerOTLPPort = 4318
+
 	// DaemonPort is the Daemon private port
 	DaemonPort = 4242
+	// DaemonHTTPPort is the Daemon private port for the REST/JSON gateway
+	DaemonHTTPPort = 4243
+	// DaemonPprofPort is the Daemon private port for the pprof endpoints
+	DaemonPprofPort = 6061
 )
 
 // FilterFunc is a filtering function for List.
@@ -224,7 +421,18 @@ func List(ctx context.Context, allVersions bool, filters ...FilterFunc) ([]Compo
 		MysqlCli,
 		Bblfshd,
 		BblfshWeb,
+		Search,
+		Analytics,
+		Notebook,
+		Spark,
+		Jaeger,
+		RetrievalBroker,
+		RetrievalDB,
+		Borges,
+		BorgesProducer,
+		Rovers,
 	}
+	componentsList = append(componentsList, plugins...)
 
 	if allVersions {
 		otherComponents := make([]Component, 0)
@@ -267,29 +475,275 @@ func List(ctx context.Context, allVersions bool, filters ...FilterFunc) ([]Compo
 	return componentsList, nil
 }
 
-func Stop() error {
+// Stop stops all the engine containers. Each container is sent a SIGTERM and
+// killed if it doesn't exit before the given timeout, so 0 kills them right
+// away. With ComponentGracePeriod, the grace period of each component is
+// used. The daemon is stopped first, see stopOrder.
+func Stop(timeout time.Duration) error {
 	log.Infof("stopping containers...")
 
 	// we actually not just stop but remove containers here
 	// it's needed to make sure configuration of the containers is correct
 	// without over-complicated logic for it
-	if err := removeContainers(); err != nil {
+	if err := stopContainers(timeout); err != nil {
 		return errors.Wrap(err, "unable to stop all containers")
 	}
 
 	return nil
 }
 
-func Prune(images bool) error {
+// StopComponents stops the containers of the given components like Stop, or
+// keeps them like Pause if pause is true. The dependents go first, and the
+// ones without a container are skipped.
+func StopComponents(cmps []Component, timeout time.Duration, pause bool) error {
+	sorted := StartOrder(cmps)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		name := sorted[i].Name
+		grace := containerGrace(name, timeout)
+
+		log.Infof("stopping container %s", name)
+
+		stop := stopContainerFunc
+		if pause {
+			stop = pauseContainerFunc
+		}
+
+		err := stop(name, grace)
+		if err == docker.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to stop %s", name)
+		}
+	}
+
+	return nil
+}
+
+// startOrder returns the names of the components in the order they must be
+// started, so every component starts after its dependencies
+func startOrder() []string {
+	var names []string
+	for _, c := range StartOrder(append([]Component{Daemon}, Upgradable()...)) {
+		names = append(names, c.Name)
+	}
+
+	return names
+}
+
+// Pause stops all the running engine containers without removing them, so
+// they can be started again with Resume keeping their state and
+// configuration. The containers are stopped in the reverse order they
+// were started, using the grace period described in Stop. The containers
+// stopped are recorded in the state directory, see SetStateDir.
+func Pause(timeout time.Duration) error {
+	cs, err := listContainers()
+	if err != nil {
+		return errors.Wrap(err, "unable to list containers")
+	}
+
+	paused, err := readPaused()
+	if err != nil {
+		return err
+	}
+
+	running := engineContainers(cs, "running")
+	ids := make(map[string]string, len(running))
+	reversed := make([]string, len(running))
+	for i, c := range running {
+		name := containerName(c)
+		ids[name] = c.ID
+		reversed[len(running)-1-i] = name
+	}
+
+	for _, name := range stopOrder(reversed) {
+		grace := containerGrace(name, timeout)
+
+		log.Infof("stopping container %s", name)
+
+		if err := pauseContainerFunc(name, grace); err != nil {
+			return errors.Wrap(err, "unable to stop all containers")
+		}
+
+		paused[name] = ids[name]
+		if err := writePaused(paused); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resume starts again the engine containers stopped by Pause, after their
+// dependencies, and forgets them. The containers that exited for any other
+// reason, such as a crash, are left as they are. It returns the names of the
+// started containers.
+func Resume(ctx context.Context) ([]string, error) {
+	cs, err := listContainers()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list containers")
+	}
+
+	paused, err := readPaused()
+	if err != nil {
+		return nil, err
+	}
+
+	var started []string
+	for _, name := range resumable(cs, paused) {
+		log.Infof("starting container %s", name)
+
+		if err := docker.ResumeContainer(ctx, name); err != nil {
+			return started, errors.Wrap(err, "unable to start all containers")
+		}
+
+		started = append(started, name)
+		delete(paused, name)
+		if err := writePaused(paused); err != nil {
+			return started, err
+		}
+	}
+
+	// the ones left were removed, recreated or can't be resumed
+	return started, writePaused(nil)
+}
+
+// resumable returns the names of the containers that Resume starts, the ones
+// stopped by Pause, given as their ids by container name, that were not
+// started or recreated since then
+func resumable(cs []docker.Container, paused map[string]string) []string {
+	var names []string
+	for _, c := range engineContainers(cs, "exited", "created") {
+		name := containerName(c)
+		if id, ok := paused[name]; !ok || id != c.ID {
+			continue
+		}
+
+		// these are run attached to the cli, and are useless without it
+		if name == MysqlCli.Name || name == Notebook.Name || name == Spark.Name {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// engineContainers returns the containers in any of the given states,
+// sorted by startOrder. Unknown containers, such as plugins, go last
+func engineContainers(cs []docker.Container, states ...string) []docker.Container {
+	var res []docker.Container
+	for _, c := range cs {
+		for _, st := range states {
+			if c.State == st {
+				res = append(res, c)
+				break
+			}
+		}
+	}
+
+	order := startOrder()
+	rank := func(name string) int {
+		for i, n := range order {
+			if n == name {
+				return i
+			}
+		}
+
+		return len(order)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return rank(containerName(res[i])) < rank(containerName(res[j]))
+	})
+
+	return res
+}
+
+// Resources are the docker resources created by the engine
+type Resources struct {
+	Containers []docker.Container
+	// Volumes include their disk usage, see docker.VolumeSize
+	Volumes []*docker.Volume
+	// Images are only listed when requested, as Prune keeps them by default
+	Images []string
+}
+
+// ListResources returns the docker resources that Prune removes, with the
+// images only if images is true.
+func ListResources(ctx context.Context, images bool) (*Resources, error) {
+	cs, err := listContainers()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list containers")
+	}
+
+	vols, err := docker.ListVolumes(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list volumes")
+	}
+
+	res := &Resources{Containers: cs}
+	for _, vol := range vols {
+		if isFromEngine(vol.Name) {
+			res.Volumes = append(res.Volumes, vol)
+		}
+	}
+
+	sort.Slice(res.Volumes, func(i, j int) bool {
+		return res.Volumes[i].Name < res.Volumes[j].Name
+	})
+
+	if images {
+		cmps, err := List(ctx, true, IsInstalled)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list images")
+		}
+
+		for _, cmp := range cmps {
+			res.Images = append(res.Images, cmp.ImageWithVersion())
+		}
+	}
+
+	return res, nil
+}
+
+// listContainers returns the engine containers in any state
+func listContainers() ([]docker.Container, error) {
+	cs, err := docker.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []docker.Container
+	for _, c := range cs {
+		if len(c.Names) > 0 && isFromEngine(containerName(c)) {
+			res = append(res, c)
+		}
+	}
+
+	return res, nil
+}
+
+func containerName(c docker.Container) string {
+	return strings.TrimLeft(c.Names[0], "/")
+}
+
+// Prune removes the containers, the volumes, unless keepVolumes is true, and
+// the network of the current profile, and the images of the components if
+// images is true
+func Prune(images, keepVolumes bool) error {
 	log.Infof("removing containers...")
 	if err := removeContainers(); err != nil {
 		return errors.Wrap(err, "unable to remove all containers")
 	}
 
-	log.Infof("removing volumes...")
+	if !keepVolumes {
+		log.Infof("removing volumes...")
 
-	if err := removeVolumes(); err != nil {
-		return errors.Wrap(err, "unable to remove volumes")
+		if err := removeVolumes(); err != nil {
+			return errors.Wrap(err, "unable to remove volumes")
+		}
 	}
 
 	log.Infof("removing network...")
@@ -333,6 +787,99 @@ func removeContainers() error {
 	return nil
 }
 
+func stopContainers(timeout time.Duration) error {
+	cs, err := docker.List()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, c := range cs {
+		if len(c.Names) == 0 {
+			continue
+		}
+
+		name := strings.TrimLeft(c.Names[0], "/")
+		if isFromEngine(name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range stopOrder(names) {
+		grace := containerGrace(name, timeout)
+
+		log.Infof("stopping container %s", name)
+
+		if err := stopContainerFunc(name, grace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopOrder returns the container names with the daemon first, and the rest
+// in the same order. The daemon waits for the queries and parse requests it is
+// running before it exits, so they must not lose gitbase or bblfshd first
+func stopOrder(names []string) []string {
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == Daemon.Name {
+			res = append([]string{name}, res...)
+		} else {
+			res = append(res, name)
+		}
+	}
+
+	return res
+}
+
+// containerGrace returns the given timeout, or the grace period of the
+// container with the given name if it is negative, see ComponentGracePeriod
+func containerGrace(name string, timeout time.Duration) time.Duration {
+	if timeout < 0 {
+		return gracePeriod(name)
+	}
+
+	return timeout
+}
+
+// gracePeriod returns the grace period of the known component with the given
+// container name, or DefaultStopTimeout for any other container. The gitbase
+// shards have the one of gitbase
+func gracePeriod(name string) time.Duration {
+	if _, ok := GitbaseShardIndex(name); ok {
+		return Gitbase.gracePeriod()
+	}
+
+	known := append([]Component{
+		Daemon,
+		Gitbase,
+		GitbaseWeb,
+		MysqlCli,
+		Bblfshd,
+		BblfshWeb,
+		Search,
+		Analytics,
+		Notebook,
+		Spark,
+		Jaeger,
+		RetrievalBroker,
+		RetrievalDB,
+		Borges,
+		BorgesProducer,
+		Rovers,
+	}, plugins...)
+
+	for _, cmp := range known {
+		if cmp.Name == name {
+			return cmp.gracePeriod()
+		}
+	}
+
+	return DefaultStopTimeout
+}
+
 func removeVolumes() error {
 	vols, err := docker.ListVolumes(context.Background())
 	if err != nil {
@@ -358,7 +905,14 @@ func removeImages() error {
 		return errors.Wrap(err, "unable to list images")
 	}
 
+	// borges and its producer share the image
+	removed := make(map[string]bool)
 	for _, cmp := range cmps {
+		if removed[cmp.ImageWithVersion()] {
+			continue
+		}
+
+		removed[cmp.ImageWithVersion()] = true
 		log.Infof("removing image %s", cmp.ImageWithVersion())
 
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
@@ -372,5 +926,5 @@ func removeImages() error {
 }
 
 func isFromEngine(name string) bool {
-	return strings.HasPrefix(name, "srcd-cli-")
+	return strings.HasPrefix(name, Prefix())
 }
@@ -0,0 +1,113 @@
+package components
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/docker"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGracePeriod(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(60*time.Second, gracePeriod(Gitbase.Name))
+	require.Equal(DefaultStopTimeout, gracePeriod(Bblfshd.Name))
+
+	// the shards are stopped like gitbase
+	require.Equal(60*time.Second, gracePeriod(GitbaseShardName(0)))
+	require.Equal(60*time.Second, gracePeriod(GitbaseShardName(3)))
+
+	defer SetPlugins(plugins)
+	SetPlugins([]Component{
+		{Name: "srcd-plugin-slow", StopTimeout: 2 * time.Minute},
+		{Name: "srcd-plugin-fast"},
+	})
+	require.Equal(2*time.Minute, gracePeriod("srcd-plugin-slow"))
+	require.Equal(DefaultStopTimeout, gracePeriod("srcd-plugin-fast"))
+
+	for _, name := range []string{"srcd-cli-unknown", Gitbase.Name + "-shard-x", ""} {
+		require.Equal(DefaultStopTimeout, gracePeriod(name), name)
+	}
+}
+
+func TestContainerGrace(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(60*time.Second, containerGrace(Gitbase.Name, ComponentGracePeriod))
+	require.Equal(DefaultStopTimeout, containerGrace("srcd-cli-unknown", ComponentGracePeriod))
+	require.Equal(60*time.Second, containerGrace(Gitbase.Name, -time.Second))
+
+	// 0 kills the container right away
+	require.Equal(time.Duration(0), containerGrace(Gitbase.Name, 0))
+	require.Equal(2*time.Minute, containerGrace(Bblfshd.Name, 2*time.Minute))
+}
+
+func TestStopComponentsTimeout(t *testing.T) {
+	require := require.New(t)
+
+	var stopped, paused map[string]time.Duration
+	defer func(stop, pause func(string, time.Duration) error) {
+		stopContainerFunc, pauseContainerFunc = stop, pause
+	}(stopContainerFunc, pauseContainerFunc)
+
+	stopContainerFunc = func(name string, grace time.Duration) error {
+		stopped[name] = grace
+		if name == Bblfshd.Name {
+			return docker.ErrNotFound
+		}
+
+		return nil
+	}
+	pauseContainerFunc = func(name string, grace time.Duration) error {
+		paused[name] = grace
+		return nil
+	}
+
+	cmps := []Component{Gitbase, Bblfshd, GitbaseWeb}
+	stop := func(timeout time.Duration, pause bool) {
+		stopped, paused = make(map[string]time.Duration), make(map[string]time.Duration)
+		require.NoError(StopComponents(cmps, timeout, pause))
+	}
+
+	stop(ComponentGracePeriod, false)
+	require.Equal(map[string]time.Duration{
+		Gitbase.Name:    60 * time.Second,
+		Bblfshd.Name:    DefaultStopTimeout,
+		GitbaseWeb.Name: DefaultStopTimeout,
+	}, stopped)
+	require.Empty(paused)
+
+	stop(0, false)
+	require.Equal(map[string]time.Duration{
+		Gitbase.Name:    0,
+		Bblfshd.Name:    0,
+		GitbaseWeb.Name: 0,
+	}, stopped)
+
+	stop(2*time.Minute, true)
+	require.Empty(stopped)
+	require.Equal(map[string]time.Duration{
+		Gitbase.Name:    2 * time.Minute,
+		Bblfshd.Name:    2 * time.Minute,
+		GitbaseWeb.Name: 2 * time.Minute,
+	}, paused)
+}
+
+func TestListRetrieval(t *testing.T) {
+	require := require.New(t)
+
+	cmps, err := List(context.Background(), false)
+	require.NoError(err)
+
+	names := make(map[string]bool)
+	for _, cmp := range cmps {
+		names[cmp.Name] = true
+	}
+
+	for _, cmp := range []Component{RetrievalBroker, RetrievalDB, Borges, BorgesProducer, Rovers} {
+		require.True(names[cmp.Name], cmp.Name)
+	}
+}
@@ -0,0 +1,105 @@
+package components
+
+// Dependencies returns the components that must be running, and ready, before
+// the one with the given container name is started
+func Dependencies(name string) []Component {
+	switch name {
+	case Gitbase.Name:
+		return []Component{Bblfshd}
+	case GitbaseWeb.Name:
+		return []Component{Gitbase, Bblfshd}
+	case BblfshWeb.Name:
+		return []Component{Bblfshd}
+	case Analytics.Name:
+		return []Component{Gitbase}
+	case MysqlCli.Name:
+		return []Component{Gitbase}
+	case Notebook.Name, Spark.Name:
+		return []Component{Gitbase, Bblfshd}
+	case Borges.Name, BorgesProducer.Name, Rovers.Name:
+		return []Component{RetrievalBroker, RetrievalDB}
+	default:
+		// the gitbase shards need the same components as gitbase
+		if _, ok := GitbaseShardIndex(name); ok {
+			return []Component{Bblfshd}
+		}
+
+		return nil
+	}
+}
+
+// Dependents returns the components that depend on the one with the given
+// container name, directly or not, see Dependencies
+func Dependents(name string) []Component {
+	var dependents []Component
+	for _, c := range append(Upgradable(), MysqlCli, Notebook, Spark) {
+		for _, dep := range WithDependencies(c.Name)[1:] {
+			if dep == name {
+				dependents = append(dependents, c)
+				break
+			}
+		}
+	}
+
+	return dependents
+}
+
+// WithDependencies returns the container names of the given components
+// followed by the ones they depend on, directly or not, without repeating any
+func WithDependencies(names ...string) []string {
+	var result []string
+	seen := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		result = append(result, name)
+
+		for _, dep := range Dependencies(name) {
+			visit(dep.Name)
+		}
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+
+	return result
+}
+
+// StartOrder returns the given components sorted so each one goes after its
+// Dependencies, keeping the order of the ones that don't depend on each other
+func StartOrder(cmps []Component) []Component {
+	included := make(map[string]bool, len(cmps))
+	for _, c := range cmps {
+		included[c.Name] = true
+	}
+
+	var sorted []Component
+	visited := make(map[string]bool, len(cmps))
+
+	var visit func(c Component)
+	visit = func(c Component) {
+		if visited[c.Name] {
+			return
+		}
+		visited[c.Name] = true
+
+		for _, dep := range Dependencies(c.Name) {
+			if included[dep.Name] {
+				visit(dep)
+			}
+		}
+
+		sorted = append(sorted, c)
+	}
+
+	for _, c := range cmps {
+		visit(c)
+	}
+
+	return sorted
+}
@@ -0,0 +1,131 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartOrder(t *testing.T) {
+	require := require.New(t)
+
+	names := func(cmps []Component) []string {
+		var res []string
+		for _, c := range cmps {
+			res = append(res, c.Name)
+		}
+
+		return res
+	}
+
+	require.Equal([]string{
+		"srcd-cli-bblfshd",
+		"srcd-cli-gitbase",
+		"srcd-cli-gitbase-web",
+		"srcd-cli-search",
+		"srcd-cli-bblfsh-web",
+	}, names(StartOrder([]Component{GitbaseWeb, Search, BblfshWeb, Gitbase, Bblfshd})))
+
+	// the dependencies that are not given are not added
+	require.Equal([]string{"srcd-cli-mysql-cli"}, names(StartOrder([]Component{MysqlCli})))
+
+	require.Equal([]string{
+		"srcd-cli-daemon",
+		"srcd-cli-bblfshd",
+		"srcd-cli-gitbase",
+		"srcd-cli-bblfsh-web",
+		"srcd-cli-gitbase-web",
+		"srcd-cli-search",
+		"srcd-cli-analytics",
+	}, startOrder())
+}
+
+func TestStopOrder(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]string{
+		"srcd-cli-daemon",
+		"srcd-cli-gitbase-web",
+		"srcd-cli-gitbase",
+		"srcd-cli-bblfshd",
+	}, stopOrder([]string{
+		"srcd-cli-gitbase-web",
+		"srcd-cli-gitbase",
+		"srcd-cli-daemon",
+		"srcd-cli-bblfshd",
+	}))
+
+	require.Equal([]string{"srcd-cli-gitbase"}, stopOrder([]string{"srcd-cli-gitbase"}))
+}
+
+func TestWithDependencies(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]string{
+		"srcd-cli-notebook",
+		"srcd-cli-gitbase",
+		"srcd-cli-bblfshd",
+	}, WithDependencies(Notebook.Name))
+
+	require.Equal([]string{
+		"srcd-cli-mysql-cli",
+		"srcd-cli-gitbase",
+		"srcd-cli-bblfshd",
+		"srcd-cli-bblfsh-web",
+	}, WithDependencies(MysqlCli.Name, Bblfshd.Name, BblfshWeb.Name))
+
+	require.Equal([]string{
+		"srcd-cli-borges",
+		"srcd-cli-retrieval-broker",
+		"srcd-cli-retrieval-db",
+		"srcd-cli-rovers",
+	}, WithDependencies(Borges.Name, Rovers.Name))
+}
+
+func TestDependents(t *testing.T) {
+	require := require.New(t)
+
+	names := func(cmps []Component) []string {
+		var res []string
+		for _, c := range cmps {
+			res = append(res, c.Name)
+		}
+
+		return res
+	}
+
+	require.Equal([]string{
+		"srcd-cli-gitbase",
+		"srcd-cli-bblfsh-web",
+		"srcd-cli-gitbase-web",
+		"srcd-cli-analytics",
+		"srcd-cli-mysql-cli",
+		"srcd-cli-notebook",
+		"srcd-cli-spark",
+	}, names(Dependents(Bblfshd.Name)))
+
+	require.Equal([]string{
+		"srcd-cli-gitbase-web",
+		"srcd-cli-analytics",
+		"srcd-cli-mysql-cli",
+		"srcd-cli-notebook",
+		"srcd-cli-spark",
+	}, names(Dependents(Gitbase.Name)))
+
+	require.Empty(Dependents(GitbaseWeb.Name))
+}
+
+func TestGitbaseShardDependencies(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]Component{Bblfshd}, Dependencies(GitbaseShardName(0)))
+	require.Equal([]string{GitbaseShardName(0), Bblfshd.Name}, WithDependencies(GitbaseShardName(0)))
+}
+
+func TestDaemonGracePeriod(t *testing.T) {
+	require := require.New(t)
+
+	// docker must not kill the daemon while it waits for the requests
+	require.True(Daemon.gracePeriod() > DaemonShutdownTimeout)
+	require.Equal(Daemon.gracePeriod(), gracePeriod(Daemon.Name))
+}
@@ -0,0 +1,35 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/src-d/engine/docker"
+)
+
+// memoryAdvice is the fix for the components killed for running out of memory
+const memoryAdvice = "Increase the memory available to Docker, in Settings > Resources with " +
+	"Docker Desktop, or use a smaller profile in the performance option of the config file."
+
+// ExitReason explains why the container of a component exited, with the
+// guidance to fix it. It returns false if the component was stopped on
+// purpose, see docker.ExitState.Stopped
+func ExitReason(st docker.ExitState) (string, bool) {
+	if st.Stopped() {
+		return "", false
+	}
+
+	switch {
+	case st.OOMKilled:
+		return fmt.Sprintf("%s was killed by the kernel OOM killer because it ran out of memory. %s",
+			st.Name, memoryAdvice), true
+	case st.ExitCode == docker.ExitCodeKilled:
+		return fmt.Sprintf("%s was killed (exit code %d), usually because the docker host ran out of memory. %s",
+			st.Name, st.ExitCode, memoryAdvice), true
+	case st.ExitCode == docker.ExitCodeSegfault:
+		return fmt.Sprintf("%s crashed with a segmentation fault (exit code %d). "+
+			"Run docker logs %s to see its last messages.", st.Name, st.ExitCode, st.Name), true
+	default:
+		return fmt.Sprintf("%s exited with code %d. Run docker logs %s to see why.",
+			st.Name, st.ExitCode, st.Name), true
+	}
+}
@@ -0,0 +1,39 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/src-d/engine/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitReason(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ok := ExitReason(docker.ExitState{Name: "srcd-cli-gitbase"})
+	assert.False(ok)
+	_, ok = ExitReason(docker.ExitState{Name: "srcd-cli-gitbase", ExitCode: docker.ExitCodeSigterm})
+	assert.False(ok)
+
+	// the OOM killer uses SIGKILL, but the flag is more specific
+	msg, ok := ExitReason(docker.ExitState{
+		Name:      "srcd-cli-gitbase",
+		ExitCode:  docker.ExitCodeKilled,
+		OOMKilled: true,
+	})
+	assert.True(ok)
+	assert.Contains(msg, "srcd-cli-gitbase was killed by the kernel OOM killer")
+	assert.Contains(msg, "Docker Desktop")
+
+	msg, ok = ExitReason(docker.ExitState{Name: "srcd-cli-bblfshd", ExitCode: docker.ExitCodeKilled})
+	assert.True(ok)
+	assert.Contains(msg, "srcd-cli-bblfshd was killed (exit code 137)")
+
+	msg, ok = ExitReason(docker.ExitState{Name: "srcd-cli-gitbase", ExitCode: docker.ExitCodeSegfault})
+	assert.True(ok)
+	assert.Contains(msg, "segmentation fault")
+
+	msg, ok = ExitReason(docker.ExitState{Name: "srcd-cli-gitbase", ExitCode: 1})
+	assert.True(ok)
+	assert.Equal("srcd-cli-gitbase exited with code 1. Run docker logs srcd-cli-gitbase to see why.", msg)
+}
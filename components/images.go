@@ -0,0 +1,148 @@
+package components
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/docker"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// OldImage is an installed image of a component with a version older than
+// the one it uses, left behind by an upgrade
+type OldImage struct {
+	// Image is the image with the version
+	Image string
+	// Size is the disk space used by the image, including the layers it
+	// shares with other images
+	Size int64
+}
+
+// OldImages returns the installed images of the given components with a
+// version older than the one they use, sorted by name. The newest retain old
+// versions of each component are kept, so they are not included, and neither
+// are the images used by a container and the versions in keep, by container
+// name, like the ones srcd components rollback switches back to. The versions
+// that can't be compared, like latest, are never old.
+func OldImages(
+	ctx context.Context,
+	cmps []Component,
+	keep map[string]string,
+	retain int,
+) ([]OldImage, error) {
+	imgs, err := docker.ListImages(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list images")
+	}
+
+	cs, err := docker.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list containers")
+	}
+
+	used := make(map[string]bool, 2*len(cs))
+	for _, c := range cs {
+		used[c.Image] = true
+		used[c.ImageID] = true
+	}
+
+	return oldImages(cmps, imgs, used, keep, retain), nil
+}
+
+func oldImages(
+	cmps []Component,
+	imgs []docker.Image,
+	used map[string]bool,
+	keep map[string]string,
+	retain int,
+) []OldImage {
+	type candidate struct {
+		OldImage
+		version semver.Version
+		// kept is true for the images that can't be removed
+		kept bool
+	}
+
+	var old []OldImage
+	for _, cmp := range cmps {
+		var cs []candidate
+		for _, img := range imgs {
+			for _, tag := range img.RepoTags {
+				image, version := docker.SplitImageID(tag)
+				if cmp.Image != image || !isOlder(version, cmp.Version) {
+					continue
+				}
+
+				v, _ := semver.ParseTolerant(version)
+				cs = append(cs, candidate{
+					OldImage: OldImage{Image: tag, Size: img.Size},
+					version:  v,
+					kept:     used[img.ID] || used[tag] || version == keep[cmp.Name],
+				})
+			}
+		}
+
+		// newest first, the kept images count as retained
+		sort.Slice(cs, func(i, j int) bool {
+			return cs[i].version.GT(cs[j].version)
+		})
+
+		for i, c := range cs {
+			if i >= retain && !c.kept {
+				old = append(old, c.OldImage)
+			}
+		}
+	}
+
+	sort.Slice(old, func(i, j int) bool {
+		return old[i].Image < old[j].Image
+	})
+
+	return old
+}
+
+// isOlder returns whether the version v is older than the version than. It
+// is false if any of them is not a semantic version
+func isOlder(v, than string) bool {
+	a, err := semver.ParseTolerant(v)
+	if err != nil {
+		return false
+	}
+
+	b, err := semver.ParseTolerant(than)
+	if err != nil {
+		return false
+	}
+
+	return a.LT(b)
+}
+
+// RemoveImages removes the given images, and returns the disk space freed,
+// which excludes the layers still used by other images.
+func RemoveImages(ctx context.Context, imgs []OldImage) (int64, error) {
+	before, err := docker.ImagesDiskUsage(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, img := range imgs {
+		log.Infof("removing image %s", img.Image)
+
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		err := docker.RemoveImage(ctx, img.Image)
+		cancel()
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to remove image %s", img.Image)
+		}
+	}
+
+	after, err := docker.ImagesDiskUsage(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return before - after, nil
+}
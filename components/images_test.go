@@ -0,0 +1,46 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/src-d/engine/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOldImages(t *testing.T) {
+	assert := assert.New(t)
+
+	cmps := []Component{
+		{Name: "srcd-cli-gitbase", Image: "srcd/gitbase", Version: "v0.24.0"},
+		{Name: "srcd-cli-bblfshd", Image: "bblfsh/bblfshd", Version: "v2.14.0-drivers"},
+		{Name: "srcd-cli-daemon", Image: "srcd/cli-daemon", Version: "dev"},
+	}
+
+	imgs := []docker.Image{
+		{ID: "1", RepoTags: []string{"srcd/gitbase:v0.24.0"}, Size: 10},
+		{ID: "2", RepoTags: []string{"srcd/gitbase:v0.23.0"}, Size: 20},
+		{ID: "3", RepoTags: []string{"srcd/gitbase:v0.22.0"}, Size: 30},
+		{ID: "4", RepoTags: []string{"srcd/gitbase:v0.21.0"}, Size: 40},
+		{ID: "5", RepoTags: []string{"srcd/gitbase:latest"}, Size: 50},
+		{ID: "6", RepoTags: []string{"bblfsh/bblfshd:v2.13.0-drivers"}, Size: 60},
+		{ID: "7", RepoTags: []string{"bblfsh/bblfshd:v2.15.0-drivers"}, Size: 70},
+		{ID: "8", RepoTags: []string{"srcd/cli-daemon:v0.10.0"}, Size: 80},
+		{ID: "9", RepoTags: []string{"other/image:v0.1.0"}, Size: 90},
+		{ID: "10", RepoTags: []string{"srcd/gitbase:v0.20.0"}, Size: 100},
+	}
+
+	used := map[string]bool{"srcd/gitbase:v0.21.0": true, "10": true}
+	keep := map[string]string{"srcd-cli-gitbase": "v0.23.0"}
+
+	assert.Equal([]OldImage{
+		{Image: "bblfsh/bblfshd:v2.13.0-drivers", Size: 60},
+		{Image: "srcd/gitbase:v0.22.0", Size: 30},
+	}, oldImages(cmps, imgs, used, keep, 0))
+
+	// the used and kept versions are among the retained ones
+	assert.Equal([]OldImage{
+		{Image: "srcd/gitbase:v0.22.0", Size: 30},
+	}, oldImages(cmps, imgs, used, keep, 1))
+
+	assert.Empty(oldImages(cmps, imgs, used, keep, 2))
+}
@@ -0,0 +1,91 @@
+package components
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// pausedFileName is the file, in the state directory, with the containers
+// stopped by Pause
+const pausedFileName = "paused.json"
+
+// stateDir returns the directory of the state of the CLI, set by src-d
+// command
+var stateDir func() (string, error)
+
+// SetStateDir sets the function that returns the directory where Pause
+// records the containers it stops, so Resume only starts those. It is called
+// each time, as the directory depends on the profile
+func SetStateDir(dir func() (string, error)) {
+	stateDir = dir
+}
+
+// pausedFile returns the path of the file with the paused containers
+func pausedFile() (string, error) {
+	if stateDir == nil {
+		return "", errors.New("the state directory of the paused containers is not set")
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, pausedFileName), nil
+}
+
+// readPaused returns the ids of the containers stopped by Pause, by
+// container name
+func readPaused() (map[string]string, error) {
+	path, err := pausedFile()
+	if err != nil {
+		return nil, err
+	}
+
+	paused := make(map[string]string)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return paused, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the paused containers")
+	}
+
+	if err := json.Unmarshal(b, &paused); err != nil {
+		return nil, errors.Wrapf(err, "could not read the paused containers from %s", path)
+	}
+
+	return paused, nil
+}
+
+// writePaused saves the ids of the containers stopped by Pause, by container
+// name. The file is removed if there are none
+func writePaused(paused map[string]string) error {
+	path, err := pausedFile()
+	if err != nil {
+		return err
+	}
+
+	if len(paused) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "could not save the paused containers")
+		}
+
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "could not save the paused containers")
+	}
+
+	b, err := json.Marshal(paused)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(ioutil.WriteFile(path, b, 0644), "could not save the paused containers")
+}
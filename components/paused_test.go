@@ -0,0 +1,68 @@
+package components
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/src-d/engine/docker"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumable(t *testing.T) {
+	require := require.New(t)
+
+	container := func(name, id, state string) docker.Container {
+		return docker.Container{ID: id, Names: []string{"/" + name}, State: state}
+	}
+
+	cs := []docker.Container{
+		container("srcd-cli-gitbase", "gitbase-1", "exited"),
+		container("srcd-cli-daemon", "daemon-1", "exited"),
+		// crashed after srcd stop --pause was run, it wasn't paused
+		container("srcd-cli-bblfshd", "bblfshd-1", "exited"),
+		// recreated since it was paused
+		container("srcd-cli-gitbase-web", "gitbase-web-2", "exited"),
+		container("srcd-cli-search", "search-1", "running"),
+		container("srcd-cli-mysql-cli", "mysql-cli-1", "exited"),
+	}
+
+	paused := map[string]string{
+		"srcd-cli-gitbase":     "gitbase-1",
+		"srcd-cli-daemon":      "daemon-1",
+		"srcd-cli-gitbase-web": "gitbase-web-1",
+		"srcd-cli-search":      "search-1",
+		"srcd-cli-mysql-cli":   "mysql-cli-1",
+	}
+
+	require.Equal([]string{"srcd-cli-daemon", "srcd-cli-gitbase"}, resumable(cs, paused))
+	require.Empty(resumable(cs, nil))
+}
+
+func TestPausedFile(t *testing.T) {
+	require := require.New(t)
+
+	defer SetStateDir(nil)
+	_, err := readPaused()
+	require.Error(err)
+
+	dir, err := ioutil.TempDir("", "srcd-paused")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	SetStateDir(func() (string, error) { return dir, nil })
+
+	paused, err := readPaused()
+	require.NoError(err)
+	require.Empty(paused)
+
+	require.NoError(writePaused(map[string]string{"srcd-cli-gitbase": "gitbase-1"}))
+	paused, err = readPaused()
+	require.NoError(err)
+	require.Equal(map[string]string{"srcd-cli-gitbase": "gitbase-1"}, paused)
+
+	require.NoError(writePaused(nil))
+	_, err = os.Stat(dir + "/" + pausedFileName)
+	require.True(os.IsNotExist(err))
+}
@@ -0,0 +1,79 @@
+package components
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/src-d/engine/docker"
+)
+
+// defaultPrefix is the prefix of the names of the containers, volumes and
+// network of the default profile
+const defaultPrefix = "srcd-cli-"
+
+// profile is the name of the current profile, empty for the default one
+var profile = ""
+
+// profileRegexp matches the valid profile names. They can't contain dashes,
+// so the prefix of a profile is never the prefix of another one
+var profileRegexp = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// SetProfile selects the profile, an independent engine stack with its own
+// containers, volumes and network, so several of them can run on the same
+// host. The names of the components are changed to use the profile prefix.
+// An empty name selects the default profile
+func SetProfile(name string) error {
+	if name != "" {
+		if err := ValidateProfileName(name); err != nil {
+			return err
+		}
+	}
+
+	old := Prefix()
+	profile = name
+
+	for _, c := range []*Component{
+		&Gitbase, &GitbaseWeb, &Bblfshd, &BblfshWeb,
+		&Search, &Analytics, &Notebook, &Spark, &MysqlCli, &Jaeger, &Daemon,
+		&RetrievalBroker, &RetrievalDB, &Borges, &BorgesProducer, &Rovers,
+	} {
+		c.Name = Prefix() + strings.TrimPrefix(c.Name, old)
+	}
+
+	docker.NetworkName = Prefix() + "network"
+	return nil
+}
+
+// ValidateProfileName checks that a profile name can be selected with
+// SetProfile
+func ValidateProfileName(name string) error {
+	if !profileRegexp.MatchString(name) || name == "cli" {
+		return fmt.Errorf("invalid profile name %q, it must contain only "+
+			"lowercase letters and digits, and can't be \"cli\"", name)
+	}
+
+	return nil
+}
+
+// Profile returns the name of the current profile, empty for the default one
+func Profile() string {
+	return profile
+}
+
+// Prefix returns the prefix of the names of the containers, volumes and
+// network of the current profile: srcd-cli- for the default one, and
+// srcd-<profile>- for the rest
+func Prefix() string {
+	if profile == "" {
+		return defaultPrefix
+	}
+
+	return fmt.Sprintf("srcd-%s-", profile)
+}
+
+// GitbaseIndexVolumePrefix returns the prefix of the gitbase index volumes of
+// the current profile, one per working directory
+func GitbaseIndexVolumePrefix() string {
+	return Gitbase.Name + "-"
+}
@@ -0,0 +1,34 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/src-d/engine/docker"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetProfile(t *testing.T) {
+	require := require.New(t)
+	defer SetProfile("")
+
+	require.NoError(SetProfile("work"))
+	require.Equal("work", Profile())
+	require.Equal("srcd-work-", Prefix())
+	require.Equal("srcd-work-gitbase", Gitbase.Name)
+	require.Equal("srcd-work-daemon", Daemon.Name)
+	require.Equal("srcd-work-gitbase-", GitbaseIndexVolumePrefix())
+	require.Equal("srcd-work-network", docker.NetworkName)
+	require.True(isFromEngine("srcd-work-bblfshd"))
+	require.False(isFromEngine("srcd-cli-bblfshd"))
+
+	require.NoError(SetProfile(""))
+	require.Equal("srcd-cli-gitbase", Gitbase.Name)
+	require.Equal("srcd-cli-network", docker.NetworkName)
+	require.False(isFromEngine("srcd-work-bblfshd"))
+
+	for _, name := range []string{"cli", "Work", "my-work", "work_2"} {
+		require.Error(SetProfile(name), name)
+	}
+	require.Equal("srcd-cli-gitbase", Gitbase.Name)
+}
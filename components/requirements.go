@@ -0,0 +1,38 @@
+package components
+
+import units "github.com/docker/go-units"
+
+// Requirements are the CPUs, memory and free disk space of the docker host
+// needed to run a component
+type Requirements struct {
+	CPUs int
+	// Memory in bytes
+	Memory int64
+	// Disk is the free disk space in bytes for the images and volumes
+	Disk int64
+}
+
+// Add returns the sum of both requirements
+func (r Requirements) Add(o Requirements) Requirements {
+	return Requirements{
+		CPUs:   r.CPUs + o.CPUs,
+		Memory: r.Memory + o.Memory,
+		Disk:   r.Disk + o.Disk,
+	}
+}
+
+// MinRequirements returns the resources of the docker host the component with
+// the given container name needs to run, without counting its Dependencies.
+// It is empty for the components that don't have any requirements
+func MinRequirements(name string) Requirements {
+	switch name {
+	case Gitbase.Name:
+		// the indexes and the caches of the repositories and UASTs
+		return Requirements{CPUs: 1, Memory: 1 * units.GiB, Disk: 2 * units.GB}
+	case Bblfshd.Name:
+		// the images of the drivers and one process per driver
+		return Requirements{CPUs: 1, Memory: 1 * units.GiB, Disk: 3 * units.GB}
+	default:
+		return Requirements{}
+	}
+}
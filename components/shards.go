@@ -0,0 +1,34 @@
+package components
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gitbaseShardInfix separates the name of gitbase from the index of a shard
+// in the names of the shard containers
+const gitbaseShardInfix = "-shard-"
+
+// GitbaseShardName returns the container name of the gitbase instance that
+// runs the shard with the given index, when the repositories are split
+// between several ones
+func GitbaseShardName(i int) string {
+	return fmt.Sprintf("%s%s%d", Gitbase.Name, gitbaseShardInfix, i)
+}
+
+// GitbaseShardIndex returns the index of the gitbase shard with the given
+// container name, and false if it is not the name of a shard
+func GitbaseShardIndex(name string) (int, bool) {
+	prefix := Gitbase.Name + gitbaseShardInfix
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+	if err != nil || i < 0 {
+		return 0, false
+	}
+
+	return i, true
+}
@@ -0,0 +1,22 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitbaseShardIndex(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("srcd-cli-gitbase-shard-2", GitbaseShardName(2))
+
+	i, ok := GitbaseShardIndex(GitbaseShardName(2))
+	require.True(ok)
+	require.Equal(2, i)
+
+	for _, name := range []string{Gitbase.Name, GitbaseWeb.Name, Gitbase.Name + "-shard-", Gitbase.Name + "-shard-x"} {
+		_, ok := GitbaseShardIndex(name)
+		require.False(ok, name)
+	}
+}
@@ -0,0 +1,651 @@
+package datasets
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	// downloadPartSuffix is added to the name of a file until it is
+	// downloaded and verified
+	downloadPartSuffix = ".part"
+	// downloadStateSuffix is added to the name of a file for the progress of
+	// its download, to resume it
+	downloadStateSuffix = ".part.json"
+	// downloadStateInterval is how often the progress of a download is saved
+	downloadStateInterval = 2 * time.Second
+	// downloadMaxRetryWait is the longest wait between retries
+	downloadMaxRetryWait = 30 * time.Second
+)
+
+var (
+	// downloadMinStreamSize is the minimum size of the range of each stream,
+	// so small files are downloaded with fewer requests. It is a var, like
+	// downloadRetryWait, so the tests can use small files
+	downloadMinStreamSize int64 = 16 << 20
+	// downloadRetryWait is the wait before the first retry, doubled for each
+	// failed one
+	downloadRetryWait = time.Second
+
+	// md5ETagRegexp matches the ETags that are the MD5 of the file, like the
+	// ones of S3 and Google Cloud Storage
+	md5ETagRegexp = regexp.MustCompile(`^"?([0-9a-fA-F]{32})"?$`)
+)
+
+// downloadOptions are the options of downloadFile
+type downloadOptions struct {
+	// Streams is the number of ranged requests that download a file in
+	// parallel, when the server supports them
+	Streams int
+	// Retries is the number of times a request is retried when it fails
+	// without downloading anything
+	Retries int
+}
+
+// downloadResult is a file downloaded by downloadFile
+type downloadResult struct {
+	Size   int64
+	SHA256 string
+	// Verified is the algorithm of the checksum given by the server the file
+	// was verified with, md5 or sha256, or empty if the server gave none
+	Verified string
+}
+
+// httpStatusError is returned when a request does not get the expected
+// status
+type httpStatusError struct {
+	Method string
+	URL    string
+	Code   int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s %s returned status %d", e.Method, e.URL, e.Code)
+}
+
+// temporary returns whether the request can succeed if it is retried
+func (e *httpStatusError) temporary() bool {
+	return e.Code >= 500 || e.Code == http.StatusTooManyRequests
+}
+
+// httpGet requests the url and returns the response, or an error if its
+// status is not 200
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, &httpStatusError{Method: http.MethodGet, URL: url, Code: res.StatusCode}
+	}
+
+	return res, nil
+}
+
+// downloadFile downloads the url to path. The file is written to
+// path.part, split in ranges downloaded in parallel if the server supports
+// them, and the failed requests are retried from the last byte received. The
+// progress is saved in path.part.json, so a download interrupted is resumed
+// by the next call, unless the file changed in the server.
+//
+// Once downloaded, the file is verified with the size and the checksums
+// given by the server, if any, and moved to path.
+func downloadFile(ctx context.Context, url, path string, opts downloadOptions) (*downloadResult, error) {
+	var res *downloadResult
+	var err error
+	// a file that does not match its checksum is downloaded again once, it
+	// could be corrupted in the way
+	for attempt := 0; attempt < 2; attempt++ {
+		res, err = downloadFileOnce(ctx, url, path, opts)
+		if _, ok := err.(*checksumError); !ok {
+			break
+		}
+
+		log.Warningf("%s, downloading it again", err)
+	}
+
+	return res, err
+}
+
+func downloadFileOnce(ctx context.Context, url, path string, opts downloadOptions) (*downloadResult, error) {
+	remote, err := statRemoteFile(ctx, url, opts.Retries)
+	if err != nil {
+		return nil, err
+	}
+
+	part := path + downloadPartSuffix
+	statePath := path + downloadStateSuffix
+	state := newDownloadState(url, remote, opts.Streams)
+
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if prev, err := readDownloadState(statePath); err == nil && prev.resumes(state) {
+		if _, err := os.Stat(part); err == nil {
+			log.Debugf("resuming the download of %s", url)
+			state = prev
+			state.ranges = true
+			flags = os.O_RDWR
+		}
+	}
+
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = state.download(ctx, f, opts.Retries, statePath)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		if err == errRemoteFileChanged {
+			os.Remove(part)
+			os.Remove(statePath)
+		}
+
+		return nil, fmt.Errorf("could not download %s: %s", url, err)
+	}
+
+	os.Remove(statePath)
+	res, err := verifyDownload(part, remote)
+	if err != nil {
+		os.Remove(part)
+		return nil, err
+	}
+
+	if res.Verified != "" {
+		log.Debugf("%s verified with its %s checksum", url, res.Verified)
+	}
+
+	if err := os.Rename(part, path); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// remoteFile is what the server tells about a file before downloading it
+type remoteFile struct {
+	URL string
+	// Size is -1 if the server doesn't tell it
+	Size int64
+	// Ranges is whether the server can send a part of the file
+	Ranges       bool
+	ETag         string
+	LastModified string
+	// Checksums are the hex encoded checksums given by the server, by
+	// algorithm: md5 or sha256
+	Checksums map[string]string
+}
+
+// statRemoteFile requests the headers of the file, retrying the request if
+// it fails
+func statRemoteFile(ctx context.Context, url string, retries int) (*remoteFile, error) {
+	var remote *remoteFile
+	err := retryDownload(ctx, retries, func() (int64, error) {
+		var err error
+		remote, err = headRemoteFile(ctx, url)
+		return 0, err
+	})
+
+	return remote, err
+}
+
+func headRemoteFile(ctx context.Context, url string) (*remoteFile, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	// without HEAD requests the file is downloaded in a single stream, and it
+	// can't be verified
+	if res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented {
+		return &remoteFile{URL: url, Size: -1}, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{Method: http.MethodHead, URL: url, Code: res.StatusCode}
+	}
+
+	remote := &remoteFile{
+		URL:          url,
+		Size:         res.ContentLength,
+		Ranges:       res.Header.Get("Accept-Ranges") == "bytes",
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		Checksums:    headerChecksums(res.Header),
+	}
+
+	// a weak ETag can't be used to resume a download
+	if strings.HasPrefix(remote.ETag, "W/") {
+		remote.ETag = ""
+	}
+
+	return remote, nil
+}
+
+// headerChecksums returns the checksums of the Digest, Content-MD5 and
+// x-goog-hash headers, and the one of the ETag if it is an MD5
+func headerChecksums(h http.Header) map[string]string {
+	sums := make(map[string]string)
+	add := func(alg, b64 string) {
+		if b, err := base64.StdEncoding.DecodeString(b64); err == nil {
+			sums[alg] = hex.EncodeToString(b)
+		}
+	}
+
+	if m := md5ETagRegexp.FindStringSubmatch(h.Get("ETag")); m != nil {
+		sums["md5"] = strings.ToLower(m[1])
+	}
+
+	for _, name := range []string{"Digest", "X-Goog-Hash"} {
+		for _, value := range h[http.CanonicalHeaderKey(name)] {
+			for _, v := range strings.Split(value, ",") {
+				parts := strings.SplitN(strings.TrimSpace(v), "=", 2)
+				if len(parts) != 2 {
+					continue
+				}
+
+				switch strings.ToLower(parts[0]) {
+				case "md5":
+					add("md5", parts[1])
+				case "sha-256":
+					add("sha256", parts[1])
+				}
+			}
+		}
+	}
+
+	if v := h.Get("Content-MD5"); v != "" {
+		add("md5", v)
+	}
+
+	return sums
+}
+
+// errRemoteFileChanged is returned when the file changes in the server while
+// it is downloaded
+var errRemoteFileChanged = fmt.Errorf("the file changed in the server while it was downloaded")
+
+// downloadState is the progress of a download, saved to resume it
+type downloadState struct {
+	URL          string             `json:"url"`
+	Size         int64              `json:"size"`
+	ETag         string             `json:"etag,omitempty"`
+	LastModified string             `json:"last_modified,omitempty"`
+	Segments     []*downloadSegment `json:"segments"`
+
+	ranges bool
+}
+
+// downloadSegment is a range of the file downloaded by a stream
+type downloadSegment struct {
+	Start int64 `json:"start"`
+	// End is the last byte of the range, or -1 for the end of the file when
+	// its size is not known
+	End int64 `json:"end"`
+	// Done is the number of bytes written, updated atomically
+	Done int64 `json:"done"`
+}
+
+// newDownloadState splits the file in ranges of downloadMinStreamSize bytes
+// at least, up to the given number of streams. A single stream downloads the
+// whole file if the server doesn't support ranges
+func newDownloadState(url string, remote *remoteFile, streams int) *downloadState {
+	s := &downloadState{
+		URL:          url,
+		Size:         remote.Size,
+		ETag:         remote.ETag,
+		LastModified: remote.LastModified,
+		ranges:       remote.Ranges,
+	}
+
+	if !remote.Ranges || remote.Size <= 0 {
+		s.Segments = []*downloadSegment{{End: remote.Size - 1}}
+		return s
+	}
+
+	n := int64(streams)
+	if max := remote.Size / downloadMinStreamSize; n > max {
+		n = max
+	}
+
+	if n < 1 {
+		n = 1
+	}
+
+	size := remote.Size / n
+	for i := int64(0); i < n; i++ {
+		seg := &downloadSegment{Start: i * size, End: (i+1)*size - 1}
+		if i == n-1 {
+			seg.End = remote.Size - 1
+		}
+
+		s.Segments = append(s.Segments, seg)
+	}
+
+	return s
+}
+
+// resumes returns whether the download saved in s is of the same file as the
+// new one, so it can be resumed
+func (s *downloadState) resumes(new *downloadState) bool {
+	return new.ranges && new.Size > 0 &&
+		s.URL == new.URL && s.Size == new.Size &&
+		(new.ETag != "" || new.LastModified != "") &&
+		s.ETag == new.ETag && s.LastModified == new.LastModified
+}
+
+// download downloads the segments at the same time into f, saving the
+// progress to path
+func (s *downloadState) download(ctx context.Context, f *os.File, retries int, path string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(s.Segments))
+	for _, seg := range s.Segments {
+		go func(seg *downloadSegment) {
+			errs <- retryDownload(ctx, retries, func() (int64, error) {
+				return s.fetch(ctx, f, seg)
+			})
+		}(seg)
+	}
+
+	ticker := time.NewTicker(downloadStateInterval)
+	defer ticker.Stop()
+
+	var firstErr error
+	for pending := len(s.Segments); pending > 0; {
+		select {
+		case err := <-errs:
+			pending--
+			if err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		case <-ticker.C:
+			s.save(path)
+		}
+	}
+
+	if firstErr != nil {
+		if firstErr != errRemoteFileChanged {
+			s.save(path)
+		}
+
+		return firstErr
+	}
+
+	// the size of the file is only known at the end if the server did not
+	// tell it, and a shorter download could have been written over a longer
+	// one
+	if seg := s.Segments[0]; seg.End < 0 {
+		return f.Truncate(seg.Done)
+	}
+
+	return nil
+}
+
+// fetch requests the rest of the segment and writes it to f, returning the
+// bytes written
+func (s *downloadState) fetch(ctx context.Context, f *os.File, seg *downloadSegment) (int64, error) {
+	if !s.ranges {
+		// the download starts again, it can't continue where it failed
+		atomic.StoreInt64(&seg.Done, 0)
+	}
+
+	start := seg.Start + atomic.LoadInt64(&seg.Done)
+	if seg.End >= 0 && start > seg.End {
+		return 0, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.ranges {
+		end := ""
+		if seg.End >= 0 {
+			end = strconv.FormatInt(seg.End, 10)
+		}
+
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%s", start, end))
+		if s.ETag != "" {
+			req.Header.Set("If-Range", s.ETag)
+		} else if s.LastModified != "" {
+			req.Header.Set("If-Range", s.LastModified)
+		}
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the whole file is sent when it changed, or the server ignores the
+		// range
+		if start != 0 {
+			return 0, errRemoteFileChanged
+		}
+	default:
+		return 0, &httpStatusError{Method: http.MethodGet, URL: s.URL, Code: res.StatusCode}
+	}
+
+	var body io.Reader = res.Body
+	if seg.End >= 0 {
+		body = io.LimitReader(res.Body, seg.End-start+1)
+	}
+
+	n, err := io.Copy(&segmentWriter{f: f, seg: seg}, body)
+	if err != nil {
+		return n, err
+	}
+
+	if seg.End >= 0 && start+n <= seg.End {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, nil
+}
+
+// save writes the progress to path. It is only used to resume the download,
+// so the errors are ignored
+func (s *downloadState) save(path string) {
+	snapshot := *s
+	snapshot.Segments = nil
+	for _, seg := range s.Segments {
+		snapshot.Segments = append(snapshot.Segments, &downloadSegment{
+			Start: seg.Start,
+			End:   seg.End,
+			Done:  atomic.LoadInt64(&seg.Done),
+		})
+	}
+
+	b, err := json.Marshal(snapshot)
+	if err == nil {
+		err = ioutil.WriteFile(path, b, 0644)
+	}
+
+	if err != nil {
+		log.Debugf("could not save the progress of the download of %s: %s", s.URL, err)
+	}
+}
+
+func readDownloadState(path string) (*downloadState, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s downloadState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+
+	if len(s.Segments) == 0 {
+		return nil, fmt.Errorf("no segments in %s", path)
+	}
+
+	return &s, nil
+}
+
+// segmentWriter writes to the file at the end of the bytes done of the
+// segment
+type segmentWriter struct {
+	f   *os.File
+	seg *downloadSegment
+}
+
+func (w *segmentWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.seg.Start+atomic.LoadInt64(&w.seg.Done))
+	atomic.AddInt64(&w.seg.Done, int64(n))
+	return n, err
+}
+
+// retryDownload calls fn until it succeeds, waiting more after each failed
+// call. It fails after the given number of retries in a row that did not
+// download anything, or if the error can't be fixed retrying
+func retryDownload(ctx context.Context, retries int, fn func() (int64, error)) error {
+	failures := 0
+	for {
+		n, err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if e, ok := err.(*httpStatusError); (ok && !e.temporary()) || err == errRemoteFileChanged {
+			return err
+		}
+
+		if n > 0 {
+			failures = 0
+		}
+
+		if failures >= retries {
+			return err
+		}
+
+		wait := downloadRetryWait << uint(failures)
+		if wait > downloadMaxRetryWait {
+			wait = downloadMaxRetryWait
+		}
+
+		failures++
+		log.Debugf("retrying in %s: %s", wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// checksumError is returned when a file downloaded doesn't match the
+// checksum given by the server
+type checksumError struct {
+	URL      string
+	Alg      string
+	Got      string
+	Expected string
+}
+
+func (e *checksumError) Error() string {
+	return fmt.Sprintf("the %s checksum of %s is %s, expected %s", e.Alg, e.URL, e.Got, e.Expected)
+}
+
+// verifyDownload checks the size and the checksums of the file downloaded
+// against the ones given by the server, and returns its SHA-256
+func verifyDownload(path string, remote *remoteFile) (*downloadResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sha, md := sha256.New(), md5.New()
+	n, err := io.Copy(io.MultiWriter(sha, md), f)
+	if err != nil {
+		return nil, err
+	}
+
+	if remote.Size >= 0 && n != remote.Size {
+		return nil, fmt.Errorf("%s has %d bytes, expected %d", remote.URL, n, remote.Size)
+	}
+
+	sums := map[string]string{
+		"sha256": hex.EncodeToString(sha.Sum(nil)),
+		"md5":    hex.EncodeToString(md.Sum(nil)),
+	}
+
+	res := &downloadResult{Size: n, SHA256: sums["sha256"]}
+	for _, alg := range []string{"sha256", "md5"} {
+		expected, ok := remote.Checksums[alg]
+		if !ok {
+			continue
+		}
+
+		if expected != sums[alg] {
+			return nil, &checksumError{URL: remote.URL, Alg: alg, Got: sums[alg], Expected: expected}
+		}
+
+		if res.Verified == "" {
+			res.Verified = alg
+		}
+	}
+
+	return res, nil
+}
+
+// fileSHA256 returns the hex encoded SHA-256 of the file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
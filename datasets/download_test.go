@@ -0,0 +1,205 @@
+package datasets
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// downloadServer serves content with ranges, and the Content-MD5 of md5sum
+type downloadServer struct {
+	content []byte
+	md5sum  []byte
+
+	mu     sync.Mutex
+	ranges []string
+	// failures is the number of GET requests that fail after sending half
+	// of the bytes they should
+	failures int
+}
+
+func newDownloadServer(content []byte) *downloadServer {
+	sum := md5.Sum(content)
+	return &downloadServer{content: content, md5sum: sum[:]}
+}
+
+func (s *downloadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", `"v1"`)
+	w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(s.md5sum))
+	if r.Method != http.MethodGet {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(s.content))
+		return
+	}
+
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	s.mu.Unlock()
+
+	if !fail {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(s.content))
+		return
+	}
+
+	var start, end int
+	fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+	w.Header().Set("Content-Length", fmt.Sprint(end-start+1))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(s.content[start : start+(end-start+1)/2])
+}
+
+// setupDownloadTest uses small ranges and retry waits, and returns a
+// temporary directory and the function that restores them and removes it
+func setupDownloadTest(t *testing.T) (string, func()) {
+	minStreamSize, retryWait := downloadMinStreamSize, downloadRetryWait
+	downloadMinStreamSize, downloadRetryWait = 10, time.Millisecond
+
+	dir, err := ioutil.TempDir("", "srcd-download")
+	require.NoError(t, err)
+
+	return dir, func() {
+		downloadMinStreamSize, downloadRetryWait = minStreamSize, retryWait
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	require := require.New(t)
+	dir, cleanup := setupDownloadTest(t)
+	defer cleanup()
+
+	content := []byte(strings.Repeat("0123456789", 10))
+	s := newDownloadServer(content)
+	s.failures = 2
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	path := filepath.Join(dir, "file")
+	res, err := downloadFile(context.Background(), srv.URL, path, downloadOptions{Streams: 4, Retries: 1})
+	require.NoError(err)
+
+	sum := sha256.Sum256(content)
+	require.Equal(&downloadResult{Size: 100, SHA256: hex.EncodeToString(sum[:]), Verified: "md5"}, res)
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	require.Equal(content, b)
+
+	// 4 streams, and the 2 failed ones retried from half their range
+	require.Len(s.ranges, 6)
+	for _, r := range []string{"bytes=0-24", "bytes=25-49", "bytes=50-74", "bytes=75-99"} {
+		require.Contains(s.ranges, r)
+	}
+
+	_, err = os.Stat(path + downloadPartSuffix)
+	require.True(os.IsNotExist(err))
+	_, err = os.Stat(path + downloadStateSuffix)
+	require.True(os.IsNotExist(err))
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	require := require.New(t)
+	dir, cleanup := setupDownloadTest(t)
+	defer cleanup()
+
+	content := []byte(strings.Repeat("0123456789", 10))
+	s := newDownloadServer(content)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	path := filepath.Join(dir, "file")
+	part := make([]byte, 100)
+	copy(part, content[:30])
+	require.NoError(ioutil.WriteFile(path+downloadPartSuffix, part, 0644))
+
+	state := &downloadState{
+		URL:  srv.URL,
+		Size: 100,
+		ETag: `"v1"`,
+		Segments: []*downloadSegment{
+			{Start: 0, End: 49, Done: 30},
+			{Start: 50, End: 99, Done: 0},
+		},
+	}
+	state.save(path + downloadStateSuffix)
+
+	_, err := downloadFile(context.Background(), srv.URL, path, downloadOptions{Streams: 1, Retries: 0})
+	require.NoError(err)
+	require.ElementsMatch([]string{"bytes=30-49", "bytes=50-99"}, s.ranges)
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	require.Equal(content, b)
+}
+
+func TestDownloadFileChecksum(t *testing.T) {
+	require := require.New(t)
+	dir, cleanup := setupDownloadTest(t)
+	defer cleanup()
+
+	s := newDownloadServer([]byte("content"))
+	s.md5sum = []byte("0123456789abcdef")
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	path := filepath.Join(dir, "file")
+	_, err := downloadFile(context.Background(), srv.URL, path, downloadOptions{Streams: 1})
+	require.Error(err)
+	require.IsType(&checksumError{}, err)
+
+	// it is downloaded again once
+	require.Len(s.ranges, 2)
+
+	for _, p := range []string{path, path + downloadPartSuffix} {
+		_, err = os.Stat(p)
+		require.True(os.IsNotExist(err))
+	}
+}
+
+func TestDownloadFileNotFound(t *testing.T) {
+	require := require.New(t)
+	dir, cleanup := setupDownloadTest(t)
+	defer cleanup()
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	_, err := downloadFile(context.Background(), srv.URL, filepath.Join(dir, "file"), downloadOptions{Streams: 1, Retries: 3})
+	require.EqualError(err, fmt.Sprintf("HEAD %s returned status 404", srv.URL))
+	require.Equal(1, requests)
+}
+
+func TestHeaderChecksums(t *testing.T) {
+	h := http.Header{}
+	h.Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+	h.Add("X-Goog-Hash", "crc32c=n03x6A==")
+	h.Add("X-Goog-Hash", "md5="+base64.StdEncoding.EncodeToString([]byte("0123456789ABCDEF")))
+	h.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString([]byte{0xca, 0xfe}))
+
+	require.Equal(t, map[string]string{
+		"md5":    hex.EncodeToString([]byte("0123456789ABCDEF")),
+		"sha256": "cafe",
+	}, headerChecksums(h))
+}
@@ -0,0 +1,592 @@
+// Package datasets downloads the public datasets of repositories, like the
+// Public Git Archive, so gitbase can analyze them without retrieving the
+// repositories one by one. It is used by srcd datasets get, and by the
+// daemon for the fetch-dataset jobs.
+package datasets
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	units "github.com/docker/go-units"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	// PGA is the name of the Public Git Archive
+	PGA = "pga"
+	// ManifestFile is the file with the repositories downloaded to the
+	// directory of a dataset
+	ManifestFile = "dataset.json"
+
+	// pgaIndexPath is the index of the last version of the archive, a CSV
+	// file compressed with gzip with a row per repository
+	pgaIndexPath = "/csv/latest.csv.gz"
+	// pgaSivaPath is the directory of the siva files of the last version,
+	// split in directories by the first two characters of their name
+	pgaSivaPath = "/siva/latest"
+	// progressInterval is how often the progress of a download is reported
+	progressInterval = 10 * time.Second
+)
+
+// Defaults of the options of srcd datasets get and the fetch-dataset jobs
+const (
+	DefaultLimit   = 1000 * 1000 * 1000
+	DefaultWorkers = 4
+	DefaultStreams = 4
+	DefaultRetries = 5
+)
+
+// PGAURL is the server of the Public Git Archive, a var so the tests can use
+// a local one
+var PGAURL = "http://pga.sourced.tech"
+
+// Options are the options of Fetch
+type Options struct {
+	// Langs selects the repositories with files of any of these languages,
+	// all of them if it is empty
+	Langs []string
+	// Limit is the size the siva files of the selected repositories can
+	// reach, no more files are downloaded once it is reached
+	Limit int64
+	// Bucket is the number of characters of the name of the siva files used
+	// to split them in directories, as gitbase reads them
+	Bucket int
+	// Workers is the number of files downloaded at the same time
+	Workers int
+	// Streams is the number of ranged requests that download each big file,
+	// when the server supports them
+	Streams int
+	// Retries is the number of times a failed request is retried
+	Retries int
+	// Verify checks the files downloaded before against their checksums, and
+	// downloads again the ones that do not match
+	Verify bool
+}
+
+// Validate returns an error if any of the options is not valid
+func (o Options) Validate() error {
+	if o.Limit <= 0 {
+		return fmt.Errorf("invalid size %d, it must be positive", o.Limit)
+	}
+
+	if o.Bucket < 0 {
+		return fmt.Errorf("invalid siva bucket %d, it can't be negative", o.Bucket)
+	}
+
+	if o.Workers < 1 {
+		return fmt.Errorf("invalid workers %d, it must be at least 1", o.Workers)
+	}
+
+	if o.Streams < 1 {
+		return fmt.Errorf("invalid streams %d, it must be at least 1", o.Streams)
+	}
+
+	if o.Retries < 0 {
+		return fmt.Errorf("invalid retries %d, it can't be negative", o.Retries)
+	}
+
+	return nil
+}
+
+// Result is the outcome of Fetch
+type Result struct {
+	Dir string `json:"dir" yaml:"dir"`
+	// Repositories is the number of repositories of the selection in the
+	// directory, Files the number of their siva files and Downloaded the
+	// ones downloaded now
+	Repositories int   `json:"repositories" yaml:"repositories"`
+	Files        int   `json:"files" yaml:"files"`
+	Downloaded   int64 `json:"downloaded" yaml:"downloaded"`
+	Size         int64 `json:"size" yaml:"size"`
+}
+
+// String returns the summary of the download, without the directory
+func (r *Result) String() string {
+	return fmt.Sprintf("%d repositories in %d siva files (%s, %d downloaded now)",
+		r.Repositories, r.Files, units.BytesSize(float64(r.Size)), r.Downloaded)
+}
+
+// Fetch downloads the repositories of the dataset with the given name to
+// dir, keeping the files downloaded before, and lists them in its manifest.
+// progress is called as the download advances. The only dataset available is
+// PGA.
+func Fetch(ctx context.Context, name, dir string, opts Options, progress func(string)) (*Result, error) {
+	if name != PGA {
+		return nil, fmt.Errorf("unknown dataset %q, the only one available is %s", name, PGA)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create the directory of the dataset: %s", err)
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the repositories downloaded before: %s", err)
+	}
+
+	progress("downloading the index of the Public Git Archive")
+	repos, err := readPGAIndex(ctx, PGAURL+pgaIndexPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not download the index of the Public Git Archive: %s", err)
+	}
+
+	repos = selectRepositories(repos, opts.Langs)
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no repository of the Public Git Archive has files of %s",
+			strings.Join(opts.Langs, ", "))
+	}
+
+	d := &pgaDownloader{
+		url:       PGAURL,
+		dir:       dir,
+		bucket:    opts.Bucket,
+		limit:     opts.Limit,
+		opts:      downloadOptions{Streams: opts.Streams, Retries: opts.Retries},
+		verify:    opts.Verify,
+		checksums: manifest.Checksums,
+		progress:  progress,
+	}
+
+	done, err := d.download(ctx, repos, opts.Workers)
+	if err != nil {
+		return nil, fmt.Errorf("could not download the Public Git Archive: %s", err)
+	}
+
+	manifest.Name = name
+	manifest.URL = PGAURL
+	manifest.SivaBucket = opts.Bucket
+	manifest.UpdatedAt = time.Now().UTC()
+	manifest.Checksums = d.checksums
+	manifest.add(done)
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, fmt.Errorf("could not save the repositories downloaded: %s", err)
+	}
+
+	return &Result{
+		Dir:          dir,
+		Repositories: len(done),
+		Files:        int(d.files),
+		Downloaded:   d.downloaded,
+		Size:         d.size,
+	}, nil
+}
+
+// Repository is a repository of the index of the Public Git Archive
+type Repository struct {
+	URL string `json:"url" yaml:"url"`
+	// SivaFiles are the names of the rooted siva files with the references
+	// of the repository, shared with its forks
+	SivaFiles []string `json:"siva_files" yaml:"siva_files"`
+	Langs     []string `json:"-" yaml:"-"`
+}
+
+// readPGAIndex downloads the index of the Public Git Archive and returns the
+// repositories in it, in the same order
+func readPGAIndex(ctx context.Context, url string) ([]Repository, error) {
+	res, err := httpGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	gz, err := gzip.NewReader(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	return parsePGAIndex(gz)
+}
+
+// parsePGAIndex reads the CSV index of the Public Git Archive. The columns
+// are found by the names of the header, as new versions of the archive add
+// more of them
+func parsePGAIndex(r io.Reader) ([]Repository, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the header of the index: %s", err)
+	}
+
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"URL", "SIVA_FILENAMES", "LANGS"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("the index does not have the column %s", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i := cols[name]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+
+		return ""
+	}
+
+	var repos []Repository
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return repos, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		repo := Repository{URL: field(record, "URL")}
+		repo.SivaFiles = splitList(field(record, "SIVA_FILENAMES"))
+		repo.Langs = splitList(field(record, "LANGS"))
+		if repo.URL == "" || len(repo.SivaFiles) == 0 {
+			continue
+		}
+
+		repos = append(repos, repo)
+	}
+}
+
+// splitList returns the non empty values of a comma separated list
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// selectRepositories returns the repositories with files of any of the
+// languages, compared ignoring the case, or all of them if there are none
+func selectRepositories(repos []Repository, langs []string) []Repository {
+	if len(langs) == 0 {
+		return repos
+	}
+
+	var selected []Repository
+	for _, repo := range repos {
+		if hasLang(repo.Langs, langs) {
+			selected = append(selected, repo)
+		}
+	}
+
+	return selected
+}
+
+func hasLang(repoLangs, langs []string) bool {
+	for _, l := range repoLangs {
+		for _, want := range langs {
+			if strings.EqualFold(l, want) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// pgaDownloader downloads the siva files of the Public Git Archive
+type pgaDownloader struct {
+	url string
+	dir string
+	// bucket is the number of characters of the name of the siva files used
+	// to split them in directories, as gitbase reads them
+	bucket int
+	// limit is the size the siva files of the repositories can reach, no
+	// more files are downloaded once it is reached
+	limit int64
+	opts  downloadOptions
+	// verify is whether the files in the directory are checked against
+	// checksums, the SHA-256 of the files by name
+	verify    bool
+	checksums map[string]string
+	// progress is called periodically with the files ready, if it is set
+	progress func(string)
+
+	// files, downloaded and size are the number of siva files of the
+	// repositories, the ones downloaded now and the size of all of them.
+	// They are updated atomically by the workers, and checksums with mu
+	mu         sync.Mutex
+	files      int64
+	downloaded int64
+	size       int64
+}
+
+// download downloads the siva files of the repositories, in order, until
+// their size reaches the limit, skipping the ones already in the directory.
+// It returns the repositories with all their siva files downloaded
+func (d *pgaDownloader) download(ctx context.Context, repos []Repository, workers int) ([]Repository, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		ok       = make(map[string]bool)
+	)
+
+	// a slot is taken before checking the size, so with the limit reached
+	// only the files being downloaded by the workers can exceed it
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	get := func(name string) {
+		defer wg.Done()
+		defer func() { <-slots }()
+
+		size, err := d.get(ctx, name)
+
+		mu.Lock()
+		if err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		ok[name] = err == nil
+		mu.Unlock()
+
+		if err == nil {
+			atomic.AddInt64(&d.files, 1)
+			atomic.AddInt64(&d.size, size)
+		}
+	}
+
+	stopProgress := make(chan struct{})
+	defer close(stopProgress)
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if d.progress != nil {
+					d.progress(fmt.Sprintf("%d siva files ready, %s",
+						atomic.LoadInt64(&d.files), units.BytesSize(float64(atomic.LoadInt64(&d.size)))))
+				}
+			case <-stopProgress:
+				return
+			}
+		}
+	}()
+
+	queued := make(map[string]bool)
+	var selected []Repository
+queue:
+	for _, repo := range repos {
+		for _, name := range repo.SivaFiles {
+			if queued[name] {
+				continue
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				break queue
+			}
+
+			if atomic.LoadInt64(&d.size) >= d.limit {
+				<-slots
+				break queue
+			}
+
+			queued[name] = true
+			wg.Add(1)
+			go get(name)
+		}
+
+		selected = append(selected, repo)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var done []Repository
+	for _, repo := range selected {
+		complete := true
+		for _, name := range repo.SivaFiles {
+			complete = complete && ok[name]
+		}
+
+		if complete {
+			done = append(done, repo)
+		}
+	}
+
+	return done, nil
+}
+
+// get downloads the siva file if it is not in the directory yet, or if it
+// does not match its checksum with verify, and returns its size
+func (d *pgaDownloader) get(ctx context.Context, name string) (int64, error) {
+	path := filepath.Join(d.dir, filepath.FromSlash(sivaFilePath(name, d.bucket)))
+	if info, err := os.Stat(path); err == nil {
+		ok, err := d.check(name, path)
+		if err != nil || ok {
+			return info.Size(), err
+		}
+
+		log.Warningf("%s does not match its checksum, downloading it again", path)
+		if err := os.Remove(path); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	url := d.url + pgaSivaPath + "/" + sivaFilePath(name, 2)
+	log.Debugf("downloading %s", url)
+	res, err := downloadFile(ctx, url, path, d.opts)
+	if err != nil {
+		return 0, err
+	}
+
+	d.setChecksum(name, res.SHA256)
+	atomic.AddInt64(&d.downloaded, 1)
+	return res.Size, nil
+}
+
+// check returns whether the file downloaded before matches its checksum.
+// They are only compared with verify, and the checksum is saved if the file
+// does not have one
+func (d *pgaDownloader) check(name, path string) (bool, error) {
+	if !d.verify {
+		return true, nil
+	}
+
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.Lock()
+	expected, ok := d.checksums[name]
+	d.mu.Unlock()
+	if !ok {
+		d.setChecksum(name, sum)
+		return true, nil
+	}
+
+	return sum == expected, nil
+}
+
+func (d *pgaDownloader) setChecksum(name, sum string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.checksums == nil {
+		d.checksums = make(map[string]string)
+	}
+
+	d.checksums[name] = sum
+}
+
+// sivaFilePath returns the slash separated path of the siva file, in the
+// directory named by its first bucket characters if bucket is not 0, as
+// borges and gitbase split them
+func sivaFilePath(name string, bucket int) string {
+	if !strings.HasSuffix(name, ".siva") {
+		name += ".siva"
+	}
+
+	if bucket > 0 && len(name) > bucket {
+		return path.Join(name[:bucket], name)
+	}
+
+	return name
+}
+
+// Manifest is the content of the dataset.json file of the directory of a
+// dataset
+type Manifest struct {
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	SivaBucket int       `json:"siva_bucket"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// Checksums are the SHA-256 of the siva files downloaded, by name
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// Repositories are all the repositories downloaded, sorted by URL
+	Repositories []Repository `json:"repositories"`
+}
+
+// add adds the repositories to the manifest, replacing the ones with the
+// same URL
+func (m *Manifest) add(repos []Repository) {
+	byURL := make(map[string]Repository)
+	for _, repo := range m.Repositories {
+		byURL[repo.URL] = repo
+	}
+
+	for _, repo := range repos {
+		byURL[repo.URL] = repo
+	}
+
+	m.Repositories = make([]Repository, 0, len(byURL))
+	for _, repo := range byURL {
+		m.Repositories = append(m.Repositories, repo)
+	}
+
+	sort.Slice(m.Repositories, func(i, j int) bool {
+		return m.Repositories[i].URL < m.Repositories[j].URL
+	})
+}
+
+// ReadManifest returns the manifest of the dataset in dir, an empty one if
+// it was not downloaded before
+func ReadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{}
+	b, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%s is not valid: %s", ManifestFile, err)
+	}
+
+	return m, nil
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, ManifestFile), append(b, '\n'), 0644)
+}
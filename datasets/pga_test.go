@@ -0,0 +1,150 @@
+package datasets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePGAIndex(t *testing.T) {
+	require := require.New(t)
+
+	index := "URL,SIVA_FILENAMES,FILE_COUNT,LANGS\n" +
+		"https://github.com/a/go,\"aa1.siva,bb2.siva\",10,\"Go,Shell\"\n" +
+		"https://github.com/b/empty,,0,\n" +
+		"https://github.com/c/py,cc3.siva,3,Python\n"
+
+	repos, err := parsePGAIndex(strings.NewReader(index))
+	require.NoError(err)
+	require.Equal([]Repository{
+		{URL: "https://github.com/a/go", SivaFiles: []string{"aa1.siva", "bb2.siva"}, Langs: []string{"Go", "Shell"}},
+		{URL: "https://github.com/c/py", SivaFiles: []string{"cc3.siva"}, Langs: []string{"Python"}},
+	}, repos)
+
+	require.Len(selectRepositories(repos, nil), 2)
+
+	selected := selectRepositories(repos, []string{"go", "ruby"})
+	require.Len(selected, 1)
+	require.Equal("https://github.com/a/go", selected[0].URL)
+
+	_, err = parsePGAIndex(strings.NewReader("URL,LANGS\n"))
+	require.EqualError(err, "the index does not have the column SIVA_FILENAMES")
+}
+
+func TestSivaFilePath(t *testing.T) {
+	require := require.New(t)
+	require.Equal("abc.siva", sivaFilePath("abc", 0))
+	require.Equal("ab/abc.siva", sivaFilePath("abc.siva", 2))
+}
+
+func TestPGADownloader(t *testing.T) {
+	require := require.New(t)
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			requests = append(requests, r.URL.Path)
+		}
+
+		w.Write([]byte(strings.Repeat("x", 10)))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "srcd-pga")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// a file downloaded before is not downloaded again
+	require.NoError(os.MkdirAll(filepath.Join(dir, "aa"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "aa", "aa1.siva"), []byte("12345"), 0644))
+
+	repos := []Repository{
+		{URL: "a", SivaFiles: []string{"aa1.siva", "bb2.siva"}},
+		{URL: "b", SivaFiles: []string{"bb2.siva"}},
+		{URL: "c", SivaFiles: []string{"cc3.siva"}},
+		{URL: "d", SivaFiles: []string{"dd4.siva"}},
+	}
+
+	d := &pgaDownloader{url: srv.URL, dir: dir, bucket: 2, limit: 20}
+	done, err := d.download(context.Background(), repos, 1)
+	require.NoError(err)
+	require.Equal(repos[:3], done)
+	require.Equal([]string{"/siva/latest/bb/bb2.siva", "/siva/latest/cc/cc3.siva"}, requests)
+	require.Equal(int64(3), d.files)
+	require.Equal(int64(2), d.downloaded)
+	require.Equal(int64(25), d.size)
+
+	_, err = os.Stat(filepath.Join(dir, "cc", "cc3.siva"))
+	require.NoError(err)
+
+	m := &Manifest{Repositories: []Repository{{URL: "c"}, {URL: "z"}}}
+	m.add(done)
+	var urls []string
+	for _, r := range m.Repositories {
+		urls = append(urls, r.URL)
+	}
+	require.Equal([]string{"a", "b", "c", "z"}, urls)
+}
+
+func TestFetch(t *testing.T) {
+	require := require.New(t)
+
+	var index bytes.Buffer
+	gz := gzip.NewWriter(&index)
+	gz.Write([]byte("URL,SIVA_FILENAMES,LANGS\n" +
+		"https://github.com/a/go,aa1.siva,Go\n" +
+		"https://github.com/b/py,bb2.siva,Python\n"))
+	require.NoError(gz.Close())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == pgaIndexPath {
+			w.Write(index.Bytes())
+			return
+		}
+
+		w.Write([]byte(strings.Repeat("x", 10)))
+	}))
+	defer srv.Close()
+
+	url := PGAURL
+	PGAURL = srv.URL
+	defer func() { PGAURL = url }()
+
+	dir, err := ioutil.TempDir("", "srcd-pga")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	opts := Options{Langs: []string{"go"}, Limit: 100, Bucket: 2, Workers: 1, Streams: 1}
+	var messages []string
+	res, err := Fetch(context.Background(), PGA, dir, opts, func(msg string) {
+		messages = append(messages, msg)
+	})
+	require.NoError(err)
+	require.Equal(&Result{Dir: dir, Repositories: 1, Files: 1, Downloaded: 1, Size: 10}, res)
+	require.Equal("1 repositories in 1 siva files (10B, 1 downloaded now)", res.String())
+	require.Equal([]string{"downloading the index of the Public Git Archive"}, messages)
+
+	m, err := ReadManifest(dir)
+	require.NoError(err)
+	require.Equal(PGA, m.Name)
+	require.Equal(2, m.SivaBucket)
+	require.Len(m.Repositories, 1)
+	require.Equal("https://github.com/a/go", m.Repositories[0].URL)
+	require.Len(m.Checksums, 1)
+
+	_, err = Fetch(context.Background(), "other", dir, opts, func(string) {})
+	require.EqualError(err, `unknown dataset "other", the only one available is pga`)
+
+	opts.Workers = 0
+	_, err = Fetch(context.Background(), PGA, dir, opts, func(string) {})
+	require.EqualError(err, "invalid workers 0, it must be at least 1")
+}
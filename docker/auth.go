@@ -0,0 +1,221 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+// dockerHubServer is the key of the Docker Hub credentials in the docker CLI
+// config and the credential helpers
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// registryAuths are the credentials set with SetRegistryAuths, by registry
+var registryAuths map[string]types.AuthConfig
+
+// SetRegistryAuths sets the credentials of the given registries, like
+// docker.io or gcr.io, which take precedence over the ones of the docker CLI
+// config. They are used by the daemon, which can't run the credential helpers
+// of the host
+func SetRegistryAuths(auths map[string]types.AuthConfig) {
+	registryAuths = make(map[string]types.AuthConfig, len(auths))
+	for registry, auth := range auths {
+		registryAuths[normalizeRegistry(registry)] = auth
+	}
+}
+
+// RegistryAuth returns the credentials for the registry of the image, set
+// with SetRegistryAuths or read from the docker CLI config, running its
+// credential helpers like osxkeychain, wincred or pass as docker login does.
+// It returns nil if there are no credentials for the registry
+func RegistryAuth(image string) (*types.AuthConfig, error) {
+	return registryAuth(parseReference(image).registry)
+}
+
+// RegistryAuths returns the credentials for the registries of the given
+// images, by registry, skipping the ones without credentials
+func RegistryAuths(images ...string) (map[string]types.AuthConfig, error) {
+	auths := make(map[string]types.AuthConfig)
+	for _, image := range images {
+		registry := parseReference(image).registry
+		if _, ok := auths[registry]; ok {
+			continue
+		}
+
+		auth, err := registryAuth(registry)
+		if err != nil {
+			return nil, err
+		}
+
+		if auth != nil {
+			auths[registry] = *auth
+		}
+	}
+
+	return auths, nil
+}
+
+func registryAuth(registry string) (*types.AuthConfig, error) {
+	if auth, ok := registryAuths[registry]; ok {
+		return &auth, nil
+	}
+
+	return configAuth(registry)
+}
+
+// dockerConfig holds the credentials of the docker CLI config file
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth string `json:"auth"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// dockerConfigFile returns the path of the docker CLI config,
+// $DOCKER_CONFIG/config.json or $HOME/.docker/config.json
+func dockerConfigFile() (string, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", errors.Wrap(err, "could not detect home directory")
+		}
+
+		dir = filepath.Join(home, ".docker")
+	}
+
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// configAuth reads the credentials of the registry from the docker CLI
+// config. The credential helper of the registry is used if there is one,
+// then the default credentials store, and then the auths of the file
+func configAuth(registry string) (*types.AuthConfig, error) {
+	path, err := dockerConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read docker config %s", path)
+	}
+
+	var conf dockerConfig
+	if err := json.Unmarshal(content, &conf); err != nil {
+		return nil, errors.Wrapf(err, "docker config %s does not follow the expected format", path)
+	}
+
+	server := registry
+	if registry == "docker.io" {
+		server = dockerHubServer
+	}
+
+	if helper := conf.CredHelpers[server]; helper != "" {
+		return credentialHelper(helper, server)
+	}
+
+	if conf.CredsStore != "" {
+		return credentialHelper(conf.CredsStore, server)
+	}
+
+	for _, key := range []string{server, "https://" + server} {
+		a, ok := conf.Auths[key]
+		if !ok || a.Auth == "" {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid auth of %s in docker config %s", key, path)
+		}
+
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid auth of %s in docker config %s", key, path)
+		}
+
+		return &types.AuthConfig{
+			Username:      parts[0],
+			Password:      parts[1],
+			ServerAddress: server,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// credentialHelper gets the credentials of the server running the
+// docker-credential-<helper> program, as the docker CLI does. It returns nil
+// if the helper has no credentials for the server
+var credentialHelper = func(helper, server string) (*types.AuthConfig, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(string(out), "credentials not found") {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "could not get the credentials of %s from docker-credential-%s", server, helper)
+	}
+
+	var creds struct {
+		ServerURL string
+		Username  string
+		Secret    string
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return nil, errors.Wrapf(err, "could not parse the credentials of docker-credential-%s", helper)
+	}
+
+	auth := &types.AuthConfig{ServerAddress: server}
+	// the helpers store the identity tokens with this username
+	if creds.Username == "<token>" {
+		auth.IdentityToken = creds.Secret
+	} else {
+		auth.Username, auth.Password = creds.Username, creds.Secret
+	}
+
+	return auth, nil
+}
+
+// encodeAuth encodes the credentials for the X-Registry-Auth header of the
+// docker API
+func encodeAuth(auth *types.AuthConfig) (string, error) {
+	b, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// setBasicAuth sets the credentials of the registry in a token request, if
+// there are any
+func setBasicAuth(req *http.Request, registry string) error {
+	auth, err := registryAuth(registry)
+	if err != nil {
+		return err
+	}
+
+	if auth != nil && auth.Username != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+func setDockerConfig(t *testing.T, config string) {
+	dir, err := ioutil.TempDir("", "docker-config")
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644)
+	require.NoError(t, err)
+
+	old := os.Getenv("DOCKER_CONFIG")
+	os.Setenv("DOCKER_CONFIG", dir)
+	t.Cleanup(func() {
+		os.Setenv("DOCKER_CONFIG", old)
+		os.RemoveAll(dir)
+		registryAuths = nil
+	})
+}
+
+func TestRegistryAuthConfig(t *testing.T) {
+	require := require.New(t)
+
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	setDockerConfig(t, `{"auths": {
+		"https://index.docker.io/v1/": {"auth": "`+auth+`"},
+		"gcr.io": {}
+	}}`)
+
+	a, err := RegistryAuth("srcd/gitbase")
+	require.NoError(err)
+	require.Equal(&types.AuthConfig{
+		Username:      "user",
+		Password:      "pass",
+		ServerAddress: dockerHubServer,
+	}, a)
+
+	a, err = RegistryAuth("gcr.io/project/image")
+	require.NoError(err)
+	require.Nil(a)
+
+	a, err = RegistryAuth("quay.io/org/image")
+	require.NoError(err)
+	require.Nil(a)
+}
+
+func TestRegistryAuthHelpers(t *testing.T) {
+	require := require.New(t)
+
+	setDockerConfig(t, `{
+		"credsStore": "desktop",
+		"credHelpers": {"gcr.io": "gcloud"}
+	}`)
+
+	var called []string
+	old := credentialHelper
+	defer func() { credentialHelper = old }()
+	credentialHelper = func(helper, server string) (*types.AuthConfig, error) {
+		called = append(called, helper+" "+server)
+		return &types.AuthConfig{Username: helper, ServerAddress: server}, nil
+	}
+
+	a, err := RegistryAuth("gcr.io/project/image")
+	require.NoError(err)
+	require.Equal("gcloud", a.Username)
+
+	a, err = RegistryAuth("bblfsh/bblfshd")
+	require.NoError(err)
+	require.Equal("desktop", a.Username)
+
+	require.Equal([]string{"gcloud gcr.io", "desktop " + dockerHubServer}, called)
+}
+
+func TestSetRegistryAuths(t *testing.T) {
+	require := require.New(t)
+
+	setDockerConfig(t, `{}`)
+	SetRegistryAuths(map[string]types.AuthConfig{
+		"index.docker.io": {Username: "user", Password: "pass"},
+	})
+
+	auths, err := RegistryAuths("srcd/gitbase", "bblfsh/bblfshd", "gcr.io/project/image")
+	require.NoError(err)
+	require.Equal(map[string]types.AuthConfig{
+		"docker.io": {Username: "user", Password: "pass"},
+	}, auths)
+}
+
+func TestEncodeAuth(t *testing.T) {
+	require := require.New(t)
+
+	auth := &types.AuthConfig{Username: "user", Password: "pass"}
+	s, err := encodeAuth(auth)
+	require.NoError(err)
+
+	b, err := base64.URLEncoding.DecodeString(s)
+	require.NoError(err)
+
+	var decoded types.AuthConfig
+	require.NoError(json.Unmarshal(b, &decoded))
+	require.Equal(*auth, decoded)
+}
@@ -2,16 +2,22 @@ package docker
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	gosignal "os/signal"
-	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/src-d/engine/logging"
+	"github.com/src-d/engine/tracing"
+
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
@@ -23,7 +29,6 @@ import (
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/go-connections/nat"
 	"github.com/pkg/errors"
-	"gopkg.in/src-d/go-log.v1"
 )
 
 type Port = types.Port
@@ -34,7 +39,7 @@ type Port = types.Port
 //   2. checks that the user is not running docker toolbox.
 //   3. checks that the client api version is supported by the docker engine,
 func GetClient() (*client.Client, error) {
-	log.Debugf("Creating docker client from env")
+	logging.Docker().Debugf("Creating docker client from env")
 	// This will fail in case of bad response from the daemon or in
 	// case of docker not installed/running
 	c, err := client.NewClientWithOpts(client.FromEnv)
@@ -42,23 +47,32 @@ func GetClient() (*client.Client, error) {
 		return nil, err
 	}
 
-	log.Debugf("Checking for Docker Toolbox")
+	// the docker operations are traced as children of the span of their
+	// context, see the tracing package
+	httpClient := *c.HTTPClient()
+	httpClient.Transport = tracing.Transport(httpClient.Transport)
+	c, err = client.NewClientWithOpts(client.FromEnv, client.WithHTTPClient(&httpClient))
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Docker().Debugf("Checking for Docker Toolbox")
 	var info types.Info
 	// Get information from running daemon to check whether is running
 	// docker toolbox
 	info, err = c.Info(context.Background())
 	if err != nil {
-		return nil, err
+		return nil, clientErr(err)
 	}
 
 	if strings.Contains(strings.ToLower(info.OperatingSystem), "boot2docker") {
 		return nil, fmt.Errorf("Docker Toolbox is not supported")
 	}
 
-	log.Debugf("Retrieving docker server version")
+	logging.Docker().Debugf("Retrieving docker server version")
 	// Call `ServerVersion` to force checking API version compatibility
 	if _, err = c.ServerVersion(context.Background()); err != nil {
-		return nil, err
+		return nil, clientErr(err)
 	}
 
 	return c, nil
@@ -81,6 +95,49 @@ func Version() (string, error) {
 	return ping.APIVersion, nil
 }
 
+// SystemInfo returns the information of the docker daemon, such as its version,
+// its operating system and the resources of the machine it runs in
+func SystemInfo(ctx context.Context) (types.Info, types.Version, error) {
+	c, err := GetClient()
+	if err != nil {
+		return types.Info{}, types.Version{}, errors.Wrap(err, "could not create docker client")
+	}
+
+	info, err := c.Info(ctx)
+	if err != nil {
+		return types.Info{}, types.Version{}, errors.Wrap(err, "could not get information about docker server")
+	}
+
+	version, err := c.ServerVersion(ctx)
+	if err != nil {
+		return types.Info{}, types.Version{}, errors.Wrap(err, "could not get docker server version")
+	}
+
+	return info, version, nil
+}
+
+// Stats returns a snapshot of the resource usage of the container with the
+// given name, such as its CPU time and memory
+func Stats(ctx context.Context, name string) (*types.StatsJSON, error) {
+	c, err := GetClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create docker client")
+	}
+
+	resp, err := c.ContainerStats(ctx, name, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get the stats of %s", name)
+	}
+	defer resp.Body.Close()
+
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, errors.Wrapf(err, "could not decode the stats of %s", name)
+	}
+
+	return &stats, nil
+}
+
 var ErrNotFound = errors.New("container not found")
 
 type Container = types.Container
@@ -181,6 +238,108 @@ func RemoveContainer(name string) error {
 	})
 }
 
+// StopContainer finds a container by name, sends it a SIGTERM and waits up to
+// the given grace period for it to exit before killing it. The container is
+// then removed, along with any anonymous volumes
+func StopContainer(name string, grace time.Duration) error {
+	return stopAndRemove(name, grace, true)
+}
+
+// StopContainerKeepingVolumes stops and removes a container like
+// StopContainer does, but its anonymous volumes are kept, so a new container
+// can reuse them, see AnonymousVolumes
+func StopContainerKeepingVolumes(name string, grace time.Duration) error {
+	return stopAndRemove(name, grace, false)
+}
+
+func stopAndRemove(name string, grace time.Duration, removeVolumes bool) error {
+	info, err := Info(name)
+	if err != nil {
+		return err
+	}
+
+	c, err := GetClient()
+	if err != nil {
+		return errors.Wrap(err, "could not create docker client")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace+5*time.Minute)
+	defer cancel()
+
+	if err := stopContainer(ctx, c, info, name, grace); err != nil {
+		return err
+	}
+
+	return c.ContainerRemove(ctx, info.ID, types.ContainerRemoveOptions{
+		Force:         true,
+		RemoveVolumes: removeVolumes,
+	})
+}
+
+// PauseContainer stops a container by name like StopContainer does, but the
+// container is kept, so it can be started again with ResumeContainer with the
+// same configuration and state. Unlike docker pause, its processes are
+// terminated
+func PauseContainer(name string, grace time.Duration) error {
+	info, err := Info(name)
+	if err != nil {
+		return err
+	}
+
+	c, err := GetClient()
+	if err != nil {
+		return errors.Wrap(err, "could not create docker client")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace+time.Minute)
+	defer cancel()
+
+	return stopContainer(ctx, c, info, name, grace)
+}
+
+func stopContainer(
+	ctx context.Context,
+	c *client.Client,
+	info *Container,
+	name string,
+	grace time.Duration,
+) error {
+	if info.State != "running" {
+		return nil
+	}
+
+	logging.Docker().Debugf("stopping container %s with a grace period of %s", name, grace)
+	if err := c.ContainerStop(ctx, info.ID, &grace); err != nil {
+		return errors.Wrapf(err, "could not stop container %s", name)
+	}
+
+	return nil
+}
+
+// ResumeContainer starts again a container stopped with PauseContainer. It
+// does nothing if the container is already running
+func ResumeContainer(ctx context.Context, name string) error {
+	info, err := Info(name)
+	if err != nil {
+		return err
+	}
+
+	if info.State == "running" {
+		return nil
+	}
+
+	c, err := GetClient()
+	if err != nil {
+		return errors.Wrap(err, "could not create docker client")
+	}
+
+	if err := c.ContainerStart(ctx, info.ID, types.ContainerStartOptions{}); err != nil {
+		return errors.Wrapf(err, "could not start container %s", name)
+	}
+
+	return nil
+}
+
 // IsInstalled checks whether an image is installed or not. If version is
 // empty, it will check that any version is installed, otherwise it will check
 // that the given version is installed.
@@ -239,25 +398,67 @@ func SplitImageID(id string) (image, version string) {
 	return
 }
 
-// Pull an image from docker hub with a specific version.
+// pullIdleTimeout is the time a pull can go without any progress before it
+// is aborted. Large images on slow links take longer than any fixed timeout,
+// so only the stalled pulls fail
+var pullIdleTimeout = 3 * time.Minute
+
+// Pull an image from docker hub with a specific version. It fails if there is
+// no progress for pullIdleTimeout, however long the whole pull takes.
 func Pull(ctx context.Context, image, version string) error {
 	c, err := GetClient()
 	if err != nil {
 		return errors.Wrap(err, "could not create docker client")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// docker pulls the image for its own platform by default, but it falls
+	// back to others in some setups, like amd64 images on apple silicon
+	var opts types.ImagePullOptions
+	if platform, err := Platform(); err == nil {
+		opts.Platform = platform
+	}
+
+	// the image is pulled anonymously if the credentials can't be read
+	if auth, err := RegistryAuth(image); err != nil {
+		logging.Registry().Warningf("could not read the credentials of the registry of %s, pulling it anonymously: %s", image, err)
+	} else if auth != nil {
+		if opts.RegistryAuth, err = encodeAuth(auth); err != nil {
+			return errors.Wrap(err, "could not encode the registry credentials")
+		}
+	}
+
 	id := image + ":" + version
-	rc, err := c.ImagePull(ctx, id, types.ImagePullOptions{})
+	// the pull request must be answered without stalling too
+	timer := time.AfterFunc(pullIdleTimeout, cancel)
+	rc, err := c.ImagePull(ctx, id, opts)
+	timer.Stop()
 	if err != nil {
+		if isPlatformNotSupported(err) {
+			return errors.Wrapf(ErrPlatformNotSupported, "could not pull image %q for %s", id, opts.Platform)
+		}
+
+		if isImageNotFound(err) {
+			return errors.Wrapf(ErrImageNotFound, "could not pull image %q", id)
+		}
+
 		return errors.Wrap(err, fmt.Sprintf("could not pull image %q", id))
 	}
 
-	io.Copy(ioutil.Discard, rc)
+	defer rc.Close()
+
+	// the progress of the pull is streamed until it finishes
+	w := newWatchdogReader(rc, pullIdleTimeout, cancel)
+	_, err = io.Copy(ioutil.Discard, w)
+	w.Stop()
+
+	if w.Stalled() {
+		return fmt.Errorf("could not pull image %q, there was no progress in %s", id, pullIdleTimeout)
+	}
 
-	return rc.Close()
+	return errors.Wrapf(err, "could not pull image %q", id)
 }
 
 // EnsureInstalled checks whether an image is installed or not. If version is
@@ -279,51 +480,125 @@ func EnsureInstalled(image, version string) error {
 	}
 	id := image + ":" + version
 
-	log.Infof("installing %q", id)
+	logging.Registry().Infof("installing %q", id)
 
 	if err := Pull(context.Background(), image, version); err != nil {
 		return err
 	}
 
-	log.Infof("installed %q", id)
+	logging.Registry().Infof("installed %q", id)
 
 	return nil
 }
 
-// HostPath returns the correct host path to use depending on the host OS
-func HostPath(hostPath string) (string, error) {
-	c, err := GetClient()
-	if err != nil {
-		return "", errors.Wrap(err, "could not create docker client")
+type ConfigOption func(*container.Config, *container.HostConfig)
+
+func WithEnv(key, value string) ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		cfg.Env = append(cfg.Env, key+"="+value)
 	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+// WithLabel sets a label of the container
+func WithLabel(key, value string) ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		if cfg.Labels == nil {
+			cfg.Labels = make(map[string]string)
+		}
 
-	info, err := c.Info(ctx)
-	if err != nil {
-		return "", errors.Wrap(err, "could not get information about docker server")
+		cfg.Labels[key] = value
 	}
+}
 
-	isWinHost := info.OSType == "windows" || strings.Contains(
-		strings.ToLower(info.OperatingSystem), "windows")
-	if isWinHost {
-		// For Windows we need to change paths like
-		// C:/Users/Windows10/go/src/github.com/src-d/engine to
-		// //c/Users/Windows10/go/src/github.com/src-d/engine
-		hostPath = regexp.MustCompile(`^(\w):`).ReplaceAllStringFunc(hostPath, func(m string) string {
-			return "//" + strings.ToLower(m[:len(m)-1])
-		})
+// WithUser sets the user the container runs as, in the form user[:group],
+// by name or id
+func WithUser(user string) ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		cfg.User = user
 	}
+}
 
-	return hostPath, nil
+// WithGroupAdd adds supplementary groups, by name or id, to the user the
+// container runs as
+func WithGroupAdd(groups ...string) ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		hc.GroupAdd = append(hc.GroupAdd, groups...)
+	}
 }
 
-type ConfigOption func(*container.Config, *container.HostConfig)
+// WithReadOnlyRootFS mounts the root filesystem of the container as read
+// only, so it can only write in its volumes
+func WithReadOnlyRootFS() ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		hc.ReadonlyRootfs = true
+	}
+}
 
-func WithEnv(key, value string) ConfigOption {
+// WithCapDrop drops the given Linux capabilities of the container, or all of
+// them with ALL
+func WithCapDrop(caps ...string) ConfigOption {
 	return func(cfg *container.Config, hc *container.HostConfig) {
-		cfg.Env = append(cfg.Env, key+"="+value)
+		hc.CapDrop = append(hc.CapDrop, caps...)
+	}
+}
+
+// WithSecurityOpt adds docker security options to the container, like
+// no-new-privileges or apparmor=<profile>
+func WithSecurityOpt(opts ...string) ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		hc.SecurityOpt = append(hc.SecurityOpt, opts...)
+	}
+}
+
+// WithMemoryLimit limits the memory the container can use, in bytes
+func WithMemoryLimit(bytes int64) ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		hc.Memory = bytes
+	}
+}
+
+// WithRestartPolicy sets the policy docker follows to restart the container
+// when it exits or docker is restarted, see ParseRestartPolicy
+func WithRestartPolicy(policy container.RestartPolicy) ConfigOption {
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		hc.RestartPolicy = policy
+	}
+}
+
+// ParseRestartPolicy parses a docker restart policy: no, always,
+// unless-stopped or on-failure[:max-retries]. An empty one is the same as no
+func ParseRestartPolicy(s string) (container.RestartPolicy, error) {
+	name, retries := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		name, retries = s[:i], s[i+1:]
+	}
+
+	switch name {
+	case "", "no", "always", "unless-stopped":
+		if retries != "" {
+			return container.RestartPolicy{}, fmt.Errorf("restart policy %s does not accept a maximum retry count", name)
+		}
+
+		if name == "no" {
+			name = ""
+		}
+
+		return container.RestartPolicy{Name: name}, nil
+	case "on-failure":
+		policy := container.RestartPolicy{Name: name}
+		if retries == "" {
+			return policy, nil
+		}
+
+		n, err := strconv.Atoi(retries)
+		if err != nil || n < 0 {
+			return container.RestartPolicy{}, fmt.Errorf("invalid maximum retry count %q of restart policy on-failure", retries)
+		}
+
+		policy.MaximumRetryCount = n
+		return policy, nil
+	default:
+		return container.RestartPolicy{}, fmt.Errorf("invalid restart policy %q, it must be one of no, always, unless-stopped or on-failure[:max-retries]", s)
 	}
 }
 
@@ -357,6 +632,12 @@ func withVolume(typ mount.Type, hostPath, containerPath string, readOnly bool, h
 // WithPort adds a port binding. If publicPort is 0 it means the port will be
 // chosen by docker, if it is -1 it will be the same one as privatePort
 func WithPort(publicPort, privatePort int) ConfigOption {
+	return WithPortOn("", publicPort, privatePort)
+}
+
+// WithPortOn publishes the private port of the container on the public port
+// of the host IP. All the interfaces are used if it is empty
+func WithPortOn(hostIP string, publicPort, privatePort int) ConfigOption {
 	return func(cfg *container.Config, hc *container.HostConfig) {
 		if cfg.ExposedPorts == nil {
 			cfg.ExposedPorts = make(nat.PortSet)
@@ -370,7 +651,7 @@ func WithPort(publicPort, privatePort int) ConfigOption {
 		cfg.ExposedPorts[port] = struct{}{}
 		hc.PortBindings[port] = append(
 			hc.PortBindings[port],
-			nat.PortBinding{HostPort: fmt.Sprint(publicPort)},
+			nat.PortBinding{HostIP: hostIP, HostPort: fmt.Sprint(publicPort)},
 		)
 	}
 }
@@ -405,14 +686,64 @@ func InfoOrStart(ctx context.Context, name string, start StartFunc) (*Container,
 	return Info(name)
 }
 
+// ConfigHashLabel is the container label with the ConfigHash of the
+// configuration it was created with
+const ConfigHashLabel = "srcd.config-hash"
+
+// ConfigHash returns a hash of the configuration of a container, to detect
+// when a container was created with a different one. ConfigHashLabel is not
+// part of it, nor the anonymous volumes a recreated container reuses, see
+// AnonymousVolumes. The network set with SetNetwork is, so the containers are
+// recreated when it changes
+func ConfigHash(config *container.Config, host *container.HostConfig) string {
+	c := *config
+	c.Labels = make(map[string]string, len(config.Labels))
+	for k, v := range config.Labels {
+		if k != ConfigHashLabel {
+			c.Labels[k] = v
+		}
+	}
+
+	if host != nil {
+		h := *host
+		h.Mounts = nil
+		for _, m := range host.Mounts {
+			if !isAnonymousMount(m) {
+				h.Mounts = append(h.Mounts, m)
+			}
+		}
+
+		host = &h
+	}
+
+	// maps are encoded with their keys sorted, so the hash is stable
+	// the network is omitted by default, to keep the hash of the containers
+	// created before it could be set
+	b, _ := json.Marshal(struct {
+		Config  *container.Config
+		Host    *container.HostConfig
+		Network string `json:",omitempty"`
+	}{&c, host, currentNetwork().Name})
+
+	h := sha1.Sum(b)
+	return hex.EncodeToString(h[:])
+}
+
 // Start creates, starts and connect new container to src-d network
-// if container already exists but stopped it removes it first to make sure it has correct configuration
+// if container already exists but stopped it removes it first to make sure it has correct configuration.
+// The container is labeled with the ConfigHash of its configuration
 func Start(ctx context.Context, config *container.Config, host *container.HostConfig, name string) error {
 	c, err := GetClient()
 	if err != nil {
 		return errors.Wrap(err, "could not create docker client")
 	}
 
+	WithLabel(ConfigHashLabel, ConfigHash(config, host))(config, host)
+
+	if err := verifyImage(ctx, c, config.Image); err != nil {
+		return err
+	}
+
 	res, err := forceContainerCreate(ctx, c, config, host, name)
 	if err != nil {
 		return errors.Wrapf(err, "could not create container %s", name)
@@ -451,7 +782,7 @@ func forceContainerCreate(
 
 	err = c.ContainerRemove(ctx, info.ID, types.ContainerRemoveOptions{Force: true})
 	if err != nil {
-		log.Errorf(err, "could not remove container after failing to create it")
+		logging.Docker().Errorf(err, "could not remove container after failing to create it")
 		return res, err
 	}
 
@@ -480,18 +811,47 @@ func CreateVolume(ctx context.Context, name string) error {
 
 type Volume = types.Volume
 
+// ListVolumes returns all the volumes with their disk usage, like docker system
+// df does. Use VolumeSize to read it
 func ListVolumes(ctx context.Context) ([]*Volume, error) {
 	c, err := GetClient()
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create docker client")
 	}
 
-	list, err := c.VolumeList(ctx, filters.Args{})
+	du, err := c.DiskUsage(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get list of volumes")
 	}
 
-	return list.Volumes, nil
+	return du.Volumes, nil
+}
+
+// ImagesDiskUsage returns the disk space in bytes used by the layers of all
+// the images, like docker system df does
+func ImagesDiskUsage(ctx context.Context) (int64, error) {
+	c, err := GetClient()
+	if err != nil {
+		return 0, errors.Wrap(err, "could not create docker client")
+	}
+
+	du, err := c.DiskUsage(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not get the disk usage of the images")
+	}
+
+	return du.LayersSize, nil
+}
+
+// VolumeSize returns the disk space in bytes used by a volume returned by
+// ListVolumes, or -1 if docker can't compute it, as happens with volumes not
+// created with the local driver
+func VolumeSize(v *Volume) int64 {
+	if v.UsageData == nil {
+		return -1
+	}
+
+	return v.UsageData.Size
 }
 
 type Image = types.ImageSummary
@@ -545,57 +905,47 @@ func RemoveImage(ctx context.Context, id string) error {
 	return err
 }
 
-// NetworkName is the name of the srcd docker network
-const NetworkName = "srcd-cli-network"
-
-func connectToNetwork(ctx context.Context, containerID string) error {
-	c, err := GetClient()
-	if err != nil {
-		return errors.Wrap(err, "could not create docker client")
-	}
-
-	if _, err := c.NetworkInspect(ctx, NetworkName, types.NetworkInspectOptions{}); err != nil {
-		log.Debugf("couldn't find network %s: %v", NetworkName, err)
-		log.Infof("creating %s docker network", NetworkName)
-		_, err = c.NetworkCreate(ctx, NetworkName, types.NetworkCreate{})
-		if err != nil {
-			return errors.Wrap(err, "could not create network")
-		}
-	}
-	return c.NetworkConnect(ctx, NetworkName, containerID, nil)
-}
-
-func RemoveNetwork(ctx context.Context) error {
+func GetLogs(ctx context.Context, containerID string) (io.ReadCloser, error) {
 	c, err := GetClient()
 	if err != nil {
-		return errors.Wrap(err, "could not create docker client")
+		return nil, errors.Wrap(err, "could not create docker client")
 	}
 
-	resp, err := c.NetworkInspect(ctx, NetworkName, types.NetworkInspectOptions{})
-	if client.IsErrNotFound(err) {
-		return nil
-	}
-	if err != nil {
-		return errors.Wrap(err, "could not inspect network")
-	}
+	reader, err := c.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Since:      time.Now().Format(time.RFC3339Nano),
+	})
 
-	return c.NetworkRemove(ctx, resp.ID)
+	return reader, err
 }
 
-func GetLogs(ctx context.Context, containerID string) (io.ReadCloser, error) {
+// CopyLogs follows the logs of the container with the given name since it
+// started, copying its standard output and error to w. It returns when ctx is
+// canceled or the container stops
+func CopyLogs(ctx context.Context, name string, w io.Writer) error {
 	c, err := GetClient()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not create docker client")
+		return errors.Wrap(err, "could not create docker client")
 	}
 
-	reader, err := c.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+	reader, err := c.ContainerLogs(ctx, name, types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     true,
-		Since:      time.Now().Format(time.RFC3339Nano),
 	})
+	if err != nil {
+		return errors.Wrapf(err, "could not get logs of container %s", name)
+	}
+	defer reader.Close()
 
-	return reader, err
+	err = demux(reader, w, w)
+	if err == context.Canceled {
+		return nil
+	}
+
+	return err
 }
 
 // Attach works similar to docker run -it
@@ -607,6 +957,10 @@ func Attach(ctx context.Context, config *container.Config, host *container.HostC
 		return nil, nil, errors.Wrap(err, "could not create docker client")
 	}
 
+	if err := verifyImage(ctx, c, config.Image); err != nil {
+		return nil, nil, err
+	}
+
 	// update config with attach options
 	config.AttachStdin = true
 	config.AttachStdout = true
@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigHash(t *testing.T) {
+	assert := assert.New(t)
+
+	newConfig := func() (*container.Config, *container.HostConfig) {
+		config := &container.Config{Image: "srcd/gitbase:v0.19.0"}
+		host := &container.HostConfig{}
+		ApplyOptions(config, host,
+			WithEnv("KEY", "value"),
+			WithPort(3306, 3306),
+			WithVolume("srcd-cli-gitbase-index", "/var/lib/gitbase/index", "linux"),
+		)
+
+		return config, host
+	}
+
+	config, host := newConfig()
+	hash := ConfigHash(config, host)
+	assert.Equal(hash, ConfigHash(newConfig()))
+
+	// the label with the hash is ignored
+	WithLabel(ConfigHashLabel, hash)(config, host)
+	assert.Equal(hash, ConfigHash(config, host))
+
+	// and so are the reused anonymous volumes
+	host.Mounts = append(host.Mounts, mount.Mount{
+		Type:   mount.TypeVolume,
+		Source: strings.Repeat("0f", 32),
+		Target: "/tmp/anonymous",
+	})
+	assert.Equal(hash, ConfigHash(config, host))
+	assert.Len(host.Mounts, 2)
+
+	for _, opt := range []ConfigOption{
+		WithEnv("KEY", "other"),
+		WithPort(3307, 3306),
+		WithVolume("other", "/var/lib/gitbase/index", "linux"),
+		WithCmd("-v"),
+		func(c *container.Config, hc *container.HostConfig) { c.Image = "srcd/gitbase:v0.20.0" },
+	} {
+		config, host := newConfig()
+		opt(config, host)
+		assert.NotEqual(hash, ConfigHash(config, host))
+	}
+}
+
+func TestParseRestartPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := map[string]container.RestartPolicy{
+		"":               {},
+		"no":             {},
+		"always":         {Name: "always"},
+		"unless-stopped": {Name: "unless-stopped"},
+		"on-failure":     {Name: "on-failure"},
+		"on-failure:5":   {Name: "on-failure", MaximumRetryCount: 5},
+	}
+
+	for s, expected := range cases {
+		policy, err := ParseRestartPolicy(s)
+		assert.NoError(err, s)
+		assert.Equal(expected, policy, s)
+	}
+
+	for _, s := range []string{"sometimes", "always:3", "on-failure:x", "on-failure:-1"} {
+		_, err := ParseRestartPolicy(s)
+		assert.Error(err, s)
+	}
+}
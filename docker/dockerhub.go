@@ -24,34 +24,49 @@ func GetCompatibleTag(image, currentVersion string) (string, bool, error) {
 		return "", false, err
 	}
 
-	tags, err := getTags(image)
+	token, err := registryToken(image)
 	if err != nil {
 		return "", false, err
 	}
 
-	var newestV semver.Version
-	var hasNewBreakingTag bool
-	if len(cliV.Pre) > 0 {
-		newestV, hasNewBreakingTag = getCompatibleTagForPre(tags, cliV)
-	} else {
-		newestV, hasNewBreakingTag = getCompatibleTag(tags, cliV)
+	tags, err := getTags(image, token)
+	if err != nil {
+		return "", false, err
 	}
 
-	if newestV.Equals(semver.Version{}) {
-		return "", false, fmt.Errorf("can't find compatible image in docker registry for %s", image)
+	newestV, hasNewBreakingTag, err := filterPlatform(image, token, tags, cliV)
+	if err != nil {
+		return "", false, err
 	}
 
 	return "v" + newestV.String(), hasNewBreakingTag, nil
 }
 
-func getCompatibleTag(tags []string, cliV semver.Version) (semver.Version, bool) {
-	var breakingV semver.Version
-	if cliV.Major >= 1 {
-		breakingV = semver.Version{Major: cliV.Major + 1}
-	} else {
-		breakingV = semver.Version{Minor: cliV.Minor + 1}
+// CompatibleVersion returns the newest of the given versions that is
+// compatible with current, and true if there are any newer versions with
+// breaking changes. A zero Version is returned if none is compatible.
+// Pre-releases are only compatible with themselves
+func CompatibleVersion(versions []string, current semver.Version) (semver.Version, bool) {
+	if len(current.Pre) > 0 {
+		return getCompatibleTagForPre(versions, current)
+	}
+
+	return getCompatibleTag(versions, current)
+}
+
+// BreakingVersion returns the first version with breaking changes after the
+// given one: the next major version, or the next minor one for v0.x versions
+func BreakingVersion(v semver.Version) semver.Version {
+	if v.Major >= 1 {
+		return semver.Version{Major: v.Major + 1}
 	}
 
+	return semver.Version{Minor: v.Minor + 1}
+}
+
+func getCompatibleTag(tags []string, cliV semver.Version) (semver.Version, bool) {
+	breakingV := BreakingVersion(cliV)
+
 	var newestV semver.Version
 	var hasNewBreakingTag bool
 	for _, tag := range tags {
@@ -115,20 +130,25 @@ func getCompatibleTagForPre(tags []string, cliV semver.Version) (semver.Version,
 // put client into variable to make it mockable for tests
 var dockerHubClient = &http.Client{Timeout: 10 * time.Second}
 
-func getTags(image string) ([]string, error) {
-	c := dockerHubClient
-
+// registryToken returns a token to pull the image from the docker registry
+func registryToken(image string) (string, error) {
 	v := url.Values{
 		"service": []string{"registry.docker.io"},
 		"scope":   []string{fmt.Sprintf("repository:%s:pull", image)},
 	}
-	r, err := c.Get(fmt.Sprintf("https://auth.docker.io/token?%s", v.Encode()))
+	req, _ := http.NewRequest("GET", fmt.Sprintf("https://auth.docker.io/token?%s", v.Encode()), nil)
+	if err := setBasicAuth(req, "docker.io"); err != nil {
+		return "", errors.Wrap(err, "can't read the docker registry credentials")
+	}
+
+	r, err := dockerHubClient.Do(req)
 	if err != nil {
-		return nil, errors.Wrap(err, "can't authorize in docker registry")
+		return "", errors.Wrap(err, "can't authorize in docker registry")
 	}
+	defer r.Body.Close()
 
 	if r.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("incorrect status code: %d while requesting docker registry token", r.StatusCode)
+		return "", fmt.Errorf("incorrect status code: %d while requesting docker registry token", r.StatusCode)
 	}
 
 	var authResp struct {
@@ -137,16 +157,21 @@ func getTags(image string) ([]string, error) {
 	jd := json.NewDecoder(r.Body)
 	err = jd.Decode(&authResp)
 	if err != nil {
-		return nil, errors.Wrap(err, "can't parse authorization response from docker registry")
+		return "", errors.Wrap(err, "can't parse authorization response from docker registry")
 	}
 
+	return authResp.Token, nil
+}
+
+func getTags(image, token string) ([]string, error) {
 	req, _ := http.NewRequest("GET", fmt.Sprintf("https://registry-1.docker.io/v2/%s/tags/list", image), nil)
-	req.Header.Add("Authorization", "Bearer "+authResp.Token)
+	req.Header.Add("Authorization", "Bearer "+token)
 
-	r, err = c.Do(req)
+	r, err := dockerHubClient.Do(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "can't request list of tags in docker registry")
 	}
+	defer r.Body.Close()
 
 	if r.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("incorrect status code: %d while requesting the list of tags in docker registry", r.StatusCode)
@@ -155,7 +180,7 @@ func getTags(image string) ([]string, error) {
 	var tagsResp struct {
 		Tags []string `json:"tags"`
 	}
-	jd = json.NewDecoder(r.Body)
+	jd := json.NewDecoder(r.Body)
 	err = jd.Decode(&tagsResp)
 	if err != nil {
 		return nil, errors.Wrap(err, "can't parse tags response from docker registry")
@@ -3,8 +3,67 @@ package docker
 import (
 	"regexp"
 	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrDaemonNotRunning is returned when the docker daemon can't be reached
+	ErrDaemonNotRunning = errors.New("docker daemon is not running")
+	// ErrIncompatibleAPIVersion is returned when the docker daemon is too old
+	// to support the API version used by the client
+	ErrIncompatibleAPIVersion = errors.New("docker API version is not supported")
+	// ErrImageNotFound is returned when an image can't be pulled because it
+	// does not exist in the registry
+	ErrImageNotFound = errors.New("docker image not found")
+	// ErrPlatformNotSupported is returned when an image exists, but not for
+	// the platform of the docker daemon, like linux/arm64
+	ErrPlatformNotSupported = errors.New("docker image is not available for this platform")
+	// ErrUnsignedImage is returned when a container is not started because
+	// its registry requires signed images, and the signature of the image
+	// can't be verified
+	ErrUnsignedImage = errors.New("docker image signature could not be verified")
+	// ErrPortInUse is the cause of a ContainerBindErr, returned when a port
+	// can't be published because it is already allocated
+	ErrPortInUse = errors.New("port is already allocated")
+	// ErrPathNotShared is returned when a host directory can't be mounted
+	// because Docker Desktop does not share it
+	ErrPathNotShared = errors.New("path is not shared with docker")
 )
 
+// typedErr returns an error with err's message and the given cause, so the
+// kind of error can be checked with errors.Cause
+func typedErr(cause error, err error) error {
+	return errors.Wrap(cause, err.Error())
+}
+
+// clientErr converts the errors returned by the docker client when the daemon
+// can't be used at all
+func clientErr(err error) error {
+	msg := err.Error()
+	switch {
+	case client.IsErrConnectionFailed(err),
+		strings.Contains(msg, "Cannot connect to the Docker daemon"),
+		strings.Contains(msg, "Is the docker daemon running"):
+		return typedErr(ErrDaemonNotRunning, err)
+	case strings.Contains(msg, "is too new. Maximum supported API version"):
+		return typedErr(ErrIncompatibleAPIVersion, err)
+	default:
+		return err
+	}
+}
+
+func isTyped(err error) bool {
+	switch errors.Cause(err) {
+	case ErrDaemonNotRunning, ErrIncompatibleAPIVersion, ErrImageNotFound, ErrPlatformNotSupported,
+		ErrUnsignedImage, ErrPortInUse, ErrPathNotShared:
+		return true
+	default:
+		return false
+	}
+}
+
 // Err is a basic docker errors to provide more context to the client
 //
 // docker API doesn't export internal errors so we parse error message to distinguish them
@@ -25,8 +84,40 @@ type ContainerBindErr struct {
 	Port string
 }
 
-// ParseErr parses error message and converts error to docker error if possible
+// Cause returns ErrPortInUse, for errors.Cause
+func (e *ContainerBindErr) Cause() error {
+	return ErrPortInUse
+}
+
+// ParseErr parses error message and converts error to docker error if possible.
+// Errors whose cause is one of the Err* values are returned as they are.
 func ParseErr(err error) error {
+	if isTyped(err) {
+		return err
+	}
+
+	// the cause is lost when the error went through the daemon or was
+	// wrapped without pkg/errors
+	if cErr := clientErr(err); cErr != err {
+		return cErr
+	}
+
+	if strings.Contains(err.Error(), ErrImageNotFound.Error()) {
+		return typedErr(ErrImageNotFound, err)
+	}
+
+	if isPlatformNotSupported(err) {
+		return typedErr(ErrPlatformNotSupported, err)
+	}
+
+	if strings.Contains(err.Error(), ErrUnsignedImage.Error()) {
+		return typedErr(ErrUnsignedImage, err)
+	}
+
+	if isPathNotShared(err) {
+		return typedErr(ErrPathNotShared, err)
+	}
+
 	if !strings.Contains(err.Error(), "Error response from daemon: ") {
 		return err
 	}
@@ -76,3 +167,30 @@ func parseContainerBindError(dErr *Err) (bool, error) {
 
 	return false, dErr
 }
+
+func isImageNotFound(err error) bool {
+	msg := err.Error()
+	return client.IsErrNotFound(err) ||
+		strings.Contains(msg, "manifest unknown") ||
+		strings.Contains(msg, "repository does not exist")
+}
+
+// isPlatformNotSupported detects the errors of docker when an image has no
+// variant for the platform, e.g. "no matching manifest for linux/arm64/v8 in
+// the manifest list entries"
+func isPlatformNotSupported(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, ErrPlatformNotSupported.Error()) ||
+		strings.Contains(msg, "no matching manifest for")
+}
+
+// isPathNotShared detects the errors of Docker Desktop when a bind mount
+// source is not shared, e.g. "Mounts denied: The path /opt/repos is not shared
+// from OS X and is not known to Docker", or "Drive has not been shared"
+func isPathNotShared(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, ErrPathNotShared.Error()) ||
+		strings.Contains(msg, "Mounts denied") ||
+		strings.Contains(msg, "has not been shared") ||
+		strings.Contains(msg, "is not shared from")
+}
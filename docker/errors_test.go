@@ -1,10 +1,10 @@
 package docker
 
 import (
-	"testing"
-
 	"errors"
+	"testing"
 
+	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,3 +34,37 @@ func TestContainerBindErr(t *testing.T) {
 	assert.Equal(dErr.Host, "0.0.0.0")
 	assert.Equal(dErr.Port, "9432")
 }
+
+func TestParseErrTyped(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		msg   string
+		cause error
+	}{
+		{"Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?", ErrDaemonNotRunning},
+		{"could not create docker client: Error response from daemon: client version 1.39 is too new. Maximum supported API version is 1.24", ErrIncompatibleAPIVersion},
+		{`rpc error: code = Unknown desc = could not pull image "srcd/gitbase:v0": docker image not found`, ErrImageNotFound},
+		{"Error response from daemon: driver failed programming external connectivity on endpoint srcd-cli-bblfshd (9669036f7e68): Bind for 0.0.0.0:9432 failed: port is already allocated", ErrPortInUse},
+	}
+
+	for _, c := range cases {
+		err := ParseErr(errors.New(c.msg))
+		assert.Equal(c.cause, pkgerrors.Cause(err), c.msg)
+		assert.Contains(err.Error(), c.msg)
+	}
+
+	// typed errors are kept, even when wrapped
+	err := pkgerrors.Wrap(ErrImageNotFound, "could not start gitbase")
+	assert.Equal(err, ParseErr(err))
+
+	err = errors.New("some other error")
+	assert.Equal(err, ParseErr(err))
+}
+
+func TestParseErrPathNotShared(t *testing.T) {
+	assert := assert.New(t)
+
+	err := ParseErr(errors.New("could not start container: Error response from daemon: Mounts denied: \r\nThe path /opt/repos\r\nis not shared from OS X and is not known to Docker."))
+	assert.Equal(ErrPathNotShared, pkgerrors.Cause(err))
+}
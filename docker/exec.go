@@ -0,0 +1,78 @@
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// Exec works similar to docker exec, it runs cmd inside the running container
+// with the given name, copying its standard output and error to stdout and
+// stderr. It returns the exit code of the command
+func Exec(ctx context.Context, name string, cmd []string, stdout, stderr io.Writer) (int, error) {
+	c, err := GetClient()
+	if err != nil {
+		return 0, errors.Wrap(err, "could not create docker client")
+	}
+
+	exec, err := c.ContainerExecCreate(ctx, name, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not create exec in container %s", name)
+	}
+
+	resp, err := c.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not attach to exec in container %s", name)
+	}
+	defer resp.Close()
+
+	if err := demux(resp.Reader, stdout, stderr); err != nil {
+		return 0, errors.Wrapf(err, "could not read output of exec in container %s", name)
+	}
+
+	inspect, err := c.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not inspect exec in container %s", name)
+	}
+
+	return inspect.ExitCode, nil
+}
+
+// demux splits the multiplexed output of a container attached without a tty.
+// Each frame has an 8 bytes header with the stream type in the first byte and
+// the payload size in the last 4, big endian
+func demux(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		var w io.Writer
+		switch header[0] {
+		case 0, 1:
+			w = stdout
+		case 2:
+			w = stderr
+		default:
+			return fmt.Errorf("unknown stream type %d", header[0])
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
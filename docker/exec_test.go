@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestDemux(t *testing.T) {
+	assert := assert.New(t)
+
+	var in bytes.Buffer
+	in.Write(frame(1, "hello "))
+	in.Write(frame(2, "oops"))
+	in.Write(frame(1, "world"))
+
+	var stdout, stderr bytes.Buffer
+	assert.NoError(demux(&in, &stdout, &stderr))
+	assert.Equal("hello world", stdout.String())
+	assert.Equal("oops", stderr.String())
+
+	err := demux(bytes.NewReader(frame(5, "x")), &stdout, &stderr)
+	assert.Error(err)
+
+	// truncated frame
+	err = demux(bytes.NewReader(frame(1, "hello")[:10]), &stdout, &stderr)
+	assert.Error(err)
+}
@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/pkg/errors"
+)
+
+// ExitState is how the process of a container finished
+type ExitState struct {
+	// Name is the container name
+	Name     string
+	ExitCode int
+	// OOMKilled is true if the kernel OOM killer killed the process
+	OOMKilled  bool
+	FinishedAt time.Time
+}
+
+// exit codes of the processes killed by SIGKILL, SIGSEGV and SIGTERM
+const (
+	ExitCodeKilled   = 128 + 9
+	ExitCodeSegfault = 128 + 11
+	ExitCodeSigterm  = 128 + 15
+)
+
+// Stopped returns whether the process exited because it was asked to, with
+// docker stop or a clean exit, and was not killed by the OOM killer
+func (s ExitState) Stopped() bool {
+	return !s.OOMKilled && (s.ExitCode == 0 || s.ExitCode == ExitCodeSigterm)
+}
+
+// InspectExit returns the exit state of the container with the given name,
+// see Inspect. It returns ErrNotFound if the container doesn't exist
+func InspectExit(ctx context.Context, name string) (*ExitState, error) {
+	in, err := Inspect(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &in.Exit, nil
+}
+
+// WatchDies calls fn with the exit state of each container that dies, until
+// the context is canceled or the docker events can't be read. The OOMKilled
+// flag is not part of the die events, so the container is inspected; if it
+// was already removed, the exit code of the event is used
+func WatchDies(ctx context.Context, fn func(ExitState)) error {
+	c, err := GetClient()
+	if err != nil {
+		return errors.Wrap(err, "could not create docker client")
+	}
+
+	filter := filters.NewArgs()
+	filter.Add("type", events.ContainerEventType)
+	filter.Add("event", "die")
+
+	msgs, errs := c.Events(ctx, types.EventsOptions{Filters: filter})
+	for {
+		select {
+		case msg := <-msgs:
+			fn(dieState(ctx, msg))
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return errors.Wrap(err, "could not read docker events")
+		}
+	}
+}
+
+// dieState returns the exit state of the container of a die event
+func dieState(ctx context.Context, msg events.Message) ExitState {
+	name := msg.Actor.Attributes["name"]
+	if st, err := InspectExit(ctx, name); err == nil {
+		return *st
+	}
+
+	code, _ := strconv.Atoi(msg.Actor.Attributes["exitCode"])
+	return ExitState{
+		Name:       name,
+		ExitCode:   code,
+		FinishedAt: time.Unix(0, msg.TimeNano),
+	}
+}
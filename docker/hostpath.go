@@ -0,0 +1,133 @@
+package docker
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// Backend is the kind of docker installation, which determines how the host
+// paths are seen by the docker daemon
+type Backend string
+
+const (
+	// BackendNative is a docker daemon running in the same host as the
+	// paths, such as docker for Linux
+	BackendNative Backend = "native"
+	// BackendDesktopMac is Docker Desktop for Mac. The paths must be shared
+	// in its File Sharing settings
+	BackendDesktopMac Backend = "desktop-mac"
+	// BackendHyperV is Docker Desktop for Windows running the Linux VM in
+	// Hyper-V. The drives must be shared in its settings, and the WSL
+	// filesystem is not accessible
+	BackendHyperV Backend = "hyper-v"
+	// BackendWSL2 is Docker Desktop for Windows with the WSL 2 backend. The
+	// Windows drives and the WSL distributions are accessible
+	BackendWSL2 Backend = "wsl2"
+	// BackendWindows is a docker daemon running Windows containers
+	BackendWindows Backend = "windows"
+)
+
+// GetBackend returns the kind of docker installation. hostOS is the operating
+// system of the host where the paths come from, such as the one running the
+// srcd command, which can't be told apart from the docker info on Docker
+// Desktop
+func GetBackend(hostOS string) (Backend, error) {
+	c, err := GetClient()
+	if err != nil {
+		return "", errors.Wrap(err, "could not create docker client")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	info, err := c.Info(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "could not get information about docker server")
+	}
+
+	return backendFromInfo(info, hostOS), nil
+}
+
+func backendFromInfo(info types.Info, hostOS string) Backend {
+	osName := strings.ToLower(info.OperatingSystem)
+	kernel := strings.ToLower(info.KernelVersion)
+
+	switch {
+	case info.OSType == "windows":
+		return BackendWindows
+	case strings.Contains(kernel, "microsoft"):
+		// e.g. 5.10.16.3-microsoft-standard-WSL2
+		return BackendWSL2
+	case strings.Contains(osName, "windows"):
+		// Docker for Windows, before it was renamed to Docker Desktop
+		return BackendHyperV
+	case strings.Contains(osName, "docker desktop"),
+		strings.Contains(osName, "docker for mac"),
+		strings.Contains(kernel, "linuxkit"):
+		if hostOS == "darwin" {
+			return BackendDesktopMac
+		}
+
+		return BackendHyperV
+	default:
+		return BackendNative
+	}
+}
+
+// HostPath returns the path to use in bind mounts for the given path of the
+// host, translating it for the docker backend. hostOS is the operating system
+// of the host, as in GetBackend. It returns an error whose cause is
+// ErrPathNotShared if the backend can't access the path
+func HostPath(hostPath, hostOS string) (string, error) {
+	backend, err := GetBackend(hostOS)
+	if err != nil {
+		return "", err
+	}
+
+	return translatePath(backend, hostPath)
+}
+
+var (
+	regexpWinDrive = regexp.MustCompile(`^([a-zA-Z]):(/|$)`)
+	regexpWSLMount = regexp.MustCompile(`^/mnt/([a-zA-Z])(/|$)`)
+)
+
+func translatePath(backend Backend, hostPath string) (string, error) {
+	if backend != BackendHyperV && backend != BackendWSL2 && backend != BackendWindows {
+		return hostPath, nil
+	}
+
+	p := strings.Replace(hostPath, `\`, "/", -1)
+	if strings.HasPrefix(p, "//") {
+		return "", errors.Wrapf(ErrPathNotShared,
+			"%s is a network path, docker can only access local drives", hostPath)
+	}
+
+	// For Windows we need to change paths like
+	// C:/Users/Windows10/go/src/github.com/src-d/engine to
+	// //c/Users/Windows10/go/src/github.com/src-d/engine
+	if m := regexpWinDrive.FindStringSubmatch(p); m != nil {
+		return "//" + strings.ToLower(m[1]) + "/" + strings.TrimPrefix(p[len(m[0]):], "/"), nil
+	}
+
+	if backend == BackendWSL2 {
+		// the srcd command runs inside a WSL distribution, whose paths,
+		// including the drives mounted in /mnt, are shared with docker
+		return hostPath, nil
+	}
+
+	// the srcd command runs inside WSL 1, but docker runs in a Hyper-V VM
+	// that can only see the Windows drives
+	if m := regexpWSLMount.FindStringSubmatch(p); m != nil {
+		return "//" + strings.ToLower(m[1]) + "/" + strings.TrimPrefix(p[len(m[0]):], "/"), nil
+	}
+
+	return "", errors.Wrapf(ErrPathNotShared,
+		"%s is in the WSL filesystem, which can't be accessed by Docker Desktop "+
+			"with the Hyper-V backend", hostPath)
+}
@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackendFromInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		osType, os, kernel, hostOS string
+		expected                   Backend
+	}{
+		{"linux", "Ubuntu 18.04.2 LTS", "4.15.0-47-generic", "linux", BackendNative},
+		{"linux", "Docker Desktop", "4.19.76-linuxkit", "darwin", BackendDesktopMac},
+		{"linux", "Docker Desktop", "4.19.76-linuxkit", "windows", BackendHyperV},
+		{"linux", "Docker Desktop", "4.19.76-linuxkit", "linux", BackendHyperV},
+		{"linux", "Docker for Windows", "4.9.125-linuxkit", "windows", BackendHyperV},
+		{"linux", "Docker Desktop", "5.10.16.3-microsoft-standard-WSL2", "windows", BackendWSL2},
+		{"windows", "Windows 10 Pro", "10.0 17763", "windows", BackendWindows},
+	}
+
+	for _, c := range cases {
+		info := types.Info{OSType: c.osType, OperatingSystem: c.os, KernelVersion: c.kernel}
+		assert.Equal(c.expected, backendFromInfo(info, c.hostOS), c.kernel+" "+c.hostOS)
+	}
+}
+
+func TestTranslatePath(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		backend  Backend
+		path     string
+		expected string
+	}{
+		{BackendNative, "/home/user/repos", "/home/user/repos"},
+		{BackendDesktopMac, "/Users/user/repos", "/Users/user/repos"},
+		{BackendHyperV, "C:/Users/user/repos", "//c/Users/user/repos"},
+		{BackendHyperV, `D:\repos\engine`, "//d/repos/engine"},
+		{BackendHyperV, "/mnt/c/Users/user/repos", "//c/Users/user/repos"},
+		{BackendWSL2, "C:/Users/user/repos", "//c/Users/user/repos"},
+		{BackendWSL2, "/mnt/c/Users/user/repos", "/mnt/c/Users/user/repos"},
+		{BackendWSL2, "/home/user/repos", "/home/user/repos"},
+		{BackendWindows, "C:/repos", "//c/repos"},
+	}
+
+	for _, c := range cases {
+		p, err := translatePath(c.backend, c.path)
+		assert.NoError(err, c.path)
+		assert.Equal(c.expected, p, c.path)
+	}
+
+	for _, path := range []string{"/home/user/repos", `\\server\share\repos`} {
+		_, err := translatePath(BackendHyperV, path)
+		assert.Equal(ErrPathNotShared, errors.Cause(err), path)
+	}
+}
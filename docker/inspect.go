@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// Inspection is the detailed state of a container, see Inspect. Unlike
+// Container, returned by Info and List, it includes the mounts, the
+// environment and the health of the container
+type Inspection struct {
+	ID string
+	// Name is the container name, without the leading slash
+	Name string
+	// Image is the image with the version the container was created from
+	Image string
+	// State is the docker state, such as running or exited
+	State string
+	// Health is the status of the docker health check: starting, healthy or
+	// unhealthy. It is empty for the containers without a health check
+	Health string
+	// RestartCount is the number of times docker restarted the container,
+	// following its restart policy
+	RestartCount int
+	// Exit is how the last process of the container finished, if it did
+	Exit ExitState
+	Env  []string
+	// Mounts are the volumes and the bind mounts of the container
+	Mounts []mount.Mount
+	// Ports are the port bindings published in the host
+	Ports []PortBinding
+	// ConfigHash is the hash of the configuration set by Start, see
+	// ConfigHash
+	ConfigHash string
+}
+
+// PortBinding is a port of a container published in the host
+type PortBinding struct {
+	Public  int
+	Private int
+	// Proto is tcp or udp
+	Proto string
+}
+
+// Inspect returns the detailed state of the container with the given name.
+// It returns ErrNotFound if the container doesn't exist
+func Inspect(ctx context.Context, name string) (*Inspection, error) {
+	c, err := GetClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create docker client")
+	}
+
+	info, err := c.ContainerInspect(ctx, name)
+	if client.IsErrNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not inspect container %s", name)
+	}
+
+	return newInspection(info), nil
+}
+
+func newInspection(info types.ContainerJSON) *Inspection {
+	in := &Inspection{}
+	if info.ContainerJSONBase != nil {
+		in.ID = info.ID
+		in.Name = strings.TrimPrefix(info.Name, "/")
+		in.RestartCount = info.RestartCount
+	}
+
+	if info.Config != nil {
+		in.Image = info.Config.Image
+		in.Env = info.Config.Env
+		in.ConfigHash = info.Config.Labels[ConfigHashLabel]
+	}
+
+	in.Exit.Name = in.Name
+	if info.ContainerJSONBase != nil && info.State != nil {
+		st := info.State
+		in.State = st.Status
+		if st.Health != nil {
+			in.Health = st.Health.Status
+		}
+
+		in.Exit.ExitCode = st.ExitCode
+		in.Exit.OOMKilled = st.OOMKilled
+		// a zero time is formatted as 0001-01-01T00:00:00Z
+		in.Exit.FinishedAt, _ = time.Parse(time.RFC3339Nano, st.FinishedAt)
+	}
+
+	for _, mp := range info.Mounts {
+		source := mp.Source
+		if mp.Type == mount.TypeVolume {
+			source = mp.Name
+		}
+
+		in.Mounts = append(in.Mounts, mount.Mount{
+			Type:     mp.Type,
+			Source:   source,
+			Target:   mp.Destination,
+			ReadOnly: !mp.RW,
+		})
+	}
+
+	if info.NetworkSettings != nil {
+		for port, bindings := range info.NetworkSettings.Ports {
+			for _, b := range bindings {
+				public, err := strconv.Atoi(b.HostPort)
+				if err != nil || public == 0 {
+					continue
+				}
+
+				in.Ports = append(in.Ports, PortBinding{
+					Public:  public,
+					Private: port.Int(),
+					Proto:   port.Proto(),
+				})
+			}
+		}
+
+		// the ports are a map
+		sort.Slice(in.Ports, func(i, j int) bool {
+			return in.Ports[i].Public < in.Ports[j].Public
+		})
+	}
+
+	return in
+}
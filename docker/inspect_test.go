@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewInspection(t *testing.T) {
+	assert := assert.New(t)
+
+	info := types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:           "abc",
+			Name:         "/srcd-cli-gitbase",
+			RestartCount: 2,
+			State: &types.ContainerState{
+				Status:     "exited",
+				ExitCode:   ExitCodeKilled,
+				OOMKilled:  true,
+				FinishedAt: "2019-03-01T10:00:00.5Z",
+				Health:     &types.Health{Status: "unhealthy"},
+			},
+		},
+		Mounts: []types.MountPoint{
+			{Type: mount.TypeVolume, Name: "srcd-cli-gitbase-index", Source: "/var/lib/docker/volumes/x", Destination: "/var/lib/gitbase/index", RW: true},
+			{Type: mount.TypeBind, Source: "/home/user/repos", Destination: "/opt/repos"},
+		},
+		Config: &container.Config{
+			Image:  "srcd/gitbase:v0.19.0",
+			Env:    []string{"KEY=value"},
+			Labels: map[string]string{ConfigHashLabel: "hash"},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{
+				Ports: nat.PortMap{
+					"3306/tcp": {{HostIP: "0.0.0.0", HostPort: "3307"}},
+					"8080/tcp": {{HostIP: "0.0.0.0", HostPort: "3000"}},
+					"9000/tcp": nil,
+				},
+			},
+		},
+	}
+
+	in := newInspection(info)
+	assert.Equal("abc", in.ID)
+	assert.Equal("srcd-cli-gitbase", in.Name)
+	assert.Equal("srcd/gitbase:v0.19.0", in.Image)
+	assert.Equal("exited", in.State)
+	assert.Equal("unhealthy", in.Health)
+	assert.Equal(2, in.RestartCount)
+	assert.Equal([]string{"KEY=value"}, in.Env)
+	assert.Equal("hash", in.ConfigHash)
+	assert.Equal(ExitState{
+		Name:       "srcd-cli-gitbase",
+		ExitCode:   ExitCodeKilled,
+		OOMKilled:  true,
+		FinishedAt: time.Date(2019, 3, 1, 10, 0, 0, 5e8, time.UTC),
+	}, in.Exit)
+	assert.Equal([]mount.Mount{
+		{Type: mount.TypeVolume, Source: "srcd-cli-gitbase-index", Target: "/var/lib/gitbase/index"},
+		{Type: mount.TypeBind, Source: "/home/user/repos", Target: "/opt/repos", ReadOnly: true},
+	}, in.Mounts)
+	assert.Equal([]PortBinding{
+		{Public: 3000, Private: 8080, Proto: "tcp"},
+		{Public: 3307, Private: 3306, Proto: "tcp"},
+	}, in.Ports)
+
+	// the created containers don't have a state yet
+	in = newInspection(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{Name: "/a"}})
+	assert.Equal("a", in.Name)
+	assert.Empty(in.State)
+	assert.True(in.Exit.FinishedAt.IsZero())
+}
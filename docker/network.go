@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/src-d/engine/logging"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// NetworkName is the name of the srcd docker network. It depends on the
+// profile, see components.SetProfile, unless a name is set with SetNetwork
+var NetworkName = "srcd-cli-network"
+
+// NetworkConfig configures the docker network the containers are connected to
+type NetworkConfig struct {
+	// Name overrides NetworkName if it is not empty
+	Name string
+	// Driver is the driver of the network created by srcd. Docker uses
+	// bridge if it is empty
+	Driver string
+	// Subnet is the subnet of the network created by srcd, in CIDR form.
+	// Docker picks one if it is empty
+	Subnet string
+	// External connects the containers to an existing network, like the one
+	// of a docker compose stack, instead of creating it. It is not removed
+	// by RemoveNetwork
+	External bool
+}
+
+var (
+	networkMu     sync.RWMutex
+	networkConfig NetworkConfig
+)
+
+// SetNetwork sets the docker network the containers are connected to. The
+// driver and the subnet only apply to the network created by srcd, so they
+// can't be set for an external one
+func SetNetwork(n NetworkConfig) error {
+	if n.Subnet != "" {
+		if _, _, err := net.ParseCIDR(n.Subnet); err != nil {
+			return fmt.Errorf("invalid network subnet %q, it must be in CIDR form, e.g. 172.30.0.0/16", n.Subnet)
+		}
+	}
+
+	if n.External && (n.Driver != "" || n.Subnet != "") {
+		return fmt.Errorf("the driver and the subnet can't be set for the external network %s", n.Name)
+	}
+
+	if n.Name != "" {
+		NetworkName = n.Name
+	}
+
+	networkMu.Lock()
+	networkConfig = n
+	networkMu.Unlock()
+	return nil
+}
+
+func currentNetwork() NetworkConfig {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return networkConfig
+}
+
+// IsExternalNetwork returns whether NetworkName is an existing network not
+// managed by srcd, see SetNetwork
+func IsExternalNetwork() bool {
+	return currentNetwork().External
+}
+
+// networkCreateOptions returns the options the srcd network is created with
+func networkCreateOptions(n NetworkConfig) types.NetworkCreate {
+	opts := types.NetworkCreate{
+		Driver: n.Driver,
+		// overlay networks are only attachable by standalone containers
+		// if they are created as such
+		Attachable: n.Driver == "overlay",
+	}
+
+	if n.Subnet != "" {
+		opts.IPAM = &network.IPAM{
+			Config: []network.IPAMConfig{{Subnet: n.Subnet}},
+		}
+	}
+
+	return opts
+}
+
+func connectToNetwork(ctx context.Context, containerID string) error {
+	c, err := GetClient()
+	if err != nil {
+		return errors.Wrap(err, "could not create docker client")
+	}
+
+	n := currentNetwork()
+	if _, err := c.NetworkInspect(ctx, NetworkName, types.NetworkInspectOptions{}); err != nil {
+		if n.External {
+			return errors.Wrapf(err, "the external network %s does not exist, create it or unset network.external in the config", NetworkName)
+		}
+
+		logging.Docker().Debugf("couldn't find network %s: %v", NetworkName, err)
+		logging.Docker().Infof("creating %s docker network", NetworkName)
+		_, err = c.NetworkCreate(ctx, NetworkName, networkCreateOptions(n))
+		if err != nil {
+			return errors.Wrap(err, "could not create network")
+		}
+	}
+	return c.NetworkConnect(ctx, NetworkName, containerID, nil)
+}
+
+// RemoveNetwork removes the srcd network, if it exists. External networks
+// are kept, see SetNetwork
+func RemoveNetwork(ctx context.Context) error {
+	if IsExternalNetwork() {
+		return nil
+	}
+
+	c, err := GetClient()
+	if err != nil {
+		return errors.Wrap(err, "could not create docker client")
+	}
+
+	resp, err := c.NetworkInspect(ctx, NetworkName, types.NetworkInspectOptions{})
+	if client.IsErrNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not inspect network")
+	}
+
+	return c.NetworkRemove(ctx, resp.ID)
+}
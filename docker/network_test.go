@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetNetwork(t *testing.T) {
+	require := require.New(t)
+
+	defer func(name string) {
+		NetworkName = name
+		SetNetwork(NetworkConfig{})
+	}(NetworkName)
+
+	NetworkName = "srcd-cli-network"
+	require.NoError(SetNetwork(NetworkConfig{Subnet: "172.30.0.0/16"}))
+	require.Equal("srcd-cli-network", NetworkName)
+	require.False(IsExternalNetwork())
+
+	require.NoError(SetNetwork(NetworkConfig{Name: "compose_default", External: true}))
+	require.Equal("compose_default", NetworkName)
+	require.True(IsExternalNetwork())
+
+	require.Error(SetNetwork(NetworkConfig{Subnet: "172.30.0.0"}))
+	require.Error(SetNetwork(NetworkConfig{Name: "compose_default", Subnet: "172.30.0.0/16", External: true}))
+}
+
+func TestNetworkCreateOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(types.NetworkCreate{}, networkCreateOptions(NetworkConfig{}))
+	assert.Equal(types.NetworkCreate{
+		Driver: "bridge",
+		IPAM: &network.IPAM{
+			Config: []network.IPAMConfig{{Subnet: "172.30.0.0/16"}},
+		},
+	}, networkCreateOptions(NetworkConfig{Driver: "bridge", Subnet: "172.30.0.0/16"}))
+	assert.Equal(types.NetworkCreate{Driver: "overlay", Attachable: true},
+		networkCreateOptions(NetworkConfig{Driver: "overlay"}))
+}
+
+func TestConfigHashNetwork(t *testing.T) {
+	require := require.New(t)
+
+	defer func(name string) {
+		NetworkName = name
+		SetNetwork(NetworkConfig{})
+	}(NetworkName)
+
+	config := &container.Config{Image: "srcd/gitbase:v0.19.0"}
+	host := &container.HostConfig{}
+	hash := ConfigHash(config, host)
+
+	require.NoError(SetNetwork(NetworkConfig{Subnet: "172.30.0.0/16"}))
+	require.Equal(hash, ConfigHash(config, host))
+
+	require.NoError(SetNetwork(NetworkConfig{Name: "compose_default"}))
+	require.NotEqual(hash, ConfigHash(config, host))
+}
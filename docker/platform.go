@@ -0,0 +1,211 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/src-d/engine/logging"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+// manifestMediaTypes are the kinds of manifests accepted from the registry,
+// manifest lists first so multi-arch images list all their platforms
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+var (
+	platformOnce  sync.Once
+	platformValue string
+	platformErr   error
+)
+
+// Platform returns the platform of the docker daemon in the os/arch format
+// used by the registry, e.g. linux/amd64 or linux/arm64. It is detected once.
+// Like in the docker CLI, DOCKER_DEFAULT_PLATFORM overrides it
+func Platform() (string, error) {
+	platformOnce.Do(func() {
+		if p := os.Getenv("DOCKER_DEFAULT_PLATFORM"); p != "" {
+			platformValue = p
+			return
+		}
+
+		platformValue, platformErr = detectPlatform()
+	})
+
+	return platformValue, platformErr
+}
+
+// put function into variable to make it mockable for tests
+var detectPlatform = func() (string, error) {
+	c, err := GetClient()
+	if err != nil {
+		return "", errors.Wrap(err, "could not create docker client")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	v, err := c.ServerVersion(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "could not get docker server version")
+	}
+
+	return v.Os + "/" + v.Arch, nil
+}
+
+// supportsPlatform returns whether the image tag has a variant for the given
+// platform. The architecture variant, like v7 for arm, is not considered
+func supportsPlatform(image, tag, token, platform string) (bool, error) {
+	platforms, err := imagePlatforms(image, tag, token)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range platforms {
+		if p == platform {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// imagePlatforms returns the platforms an image tag is published for
+func imagePlatforms(image, tag, token string) ([]string, error) {
+	var manifest struct {
+		// schema 1 manifests have a single architecture
+		Architecture string `json:"architecture"`
+		Manifests    []struct {
+			Platform struct {
+				Architecture string `json:"architecture"`
+				OS           string `json:"os"`
+			} `json:"platform"`
+		} `json:"manifests"`
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+
+	url := fmt.Sprintf("https://registry-1.docker.io/v2/%s/manifests/%s", image, tag)
+	if err := registryGet(url, token, strings.Join(manifestMediaTypes, ","), &manifest); err != nil {
+		return nil, errors.Wrapf(err, "can't get the manifest of %s:%s", image, tag)
+	}
+
+	if len(manifest.Manifests) > 0 {
+		platforms := make([]string, len(manifest.Manifests))
+		for i, m := range manifest.Manifests {
+			platforms[i] = m.Platform.OS + "/" + m.Platform.Architecture
+		}
+
+		return platforms, nil
+	}
+
+	if manifest.Architecture != "" {
+		return []string{"linux/" + manifest.Architecture}, nil
+	}
+
+	// single platform images only have it in their config
+	var config struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	}
+
+	url = fmt.Sprintf("https://registry-1.docker.io/v2/%s/blobs/%s", image, manifest.Config.Digest)
+	if err := registryGet(url, token, "", &config); err != nil {
+		return nil, errors.Wrapf(err, "can't get the config of %s:%s", image, tag)
+	}
+
+	return []string{config.OS + "/" + config.Architecture}, nil
+}
+
+// registryGet requests the url to the docker registry, decoding the JSON
+// response into v
+func registryGet(url, token, accept string, v interface{}) error {
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	if accept != "" {
+		req.Header.Add("Accept", accept)
+	}
+
+	r, err := dockerHubClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "can't request docker registry")
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("incorrect status code: %d while requesting docker registry", r.StatusCode)
+	}
+
+	return errors.Wrap(json.NewDecoder(r.Body).Decode(v), "can't parse docker registry response")
+}
+
+// filterPlatform returns the newest version of the tags compatible with
+// cliV that has an image for the platform of the docker daemon. If the
+// platform can't be known or checked, the newest compatible one is returned
+func filterPlatform(image, token string, tags []string, cliV semver.Version) (semver.Version, bool, error) {
+	platform, err := Platform()
+	if err != nil {
+		logging.Registry().Debugf("could not detect the docker platform: %s", err)
+	}
+
+	var skipped []string
+	for {
+		newestV, hasNewBreakingTag := CompatibleVersion(tags, cliV)
+		if newestV.Equals(semver.Version{}) {
+			if len(skipped) > 0 {
+				return newestV, false, errors.Wrapf(ErrPlatformNotSupported,
+					"%s has no image for %s, the compatible versions %s are only published for other platforms",
+					image, platform, strings.Join(skipped, ", "))
+			}
+
+			return newestV, false, fmt.Errorf("can't find compatible image in docker registry for %s", image)
+		}
+
+		if platform == "" {
+			return newestV, hasNewBreakingTag, nil
+		}
+
+		tag := "v" + newestV.String()
+		ok, err := supportsPlatform(image, tag, token, platform)
+		if err != nil {
+			// the pull reports the error if the image is really missing
+			logging.Registry().Debugf("could not check the platforms of %s:%s: %s", image, tag, err)
+			return newestV, hasNewBreakingTag, nil
+		}
+
+		if ok {
+			return newestV, hasNewBreakingTag, nil
+		}
+
+		logging.Registry().Debugf("skipping %s:%s, it has no image for %s", image, tag, platform)
+		skipped = append(skipped, tag)
+		tags = withoutVersion(tags, newestV)
+	}
+}
+
+// withoutVersion returns the tags that are not the given version
+func withoutVersion(tags []string, v semver.Version) []string {
+	var res []string
+	for _, tag := range tags {
+		if tv, err := semver.ParseTolerant(tag); err == nil && tv.Equals(v) {
+			continue
+		}
+
+		res = append(res, tag)
+	}
+
+	return res
+}
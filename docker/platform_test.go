@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCompatibleTagPlatform(t *testing.T) {
+	require := require.New(t)
+
+	setPlatform(t, "linux/arm64")
+
+	multiArch := `{"manifests": [
+		{"platform": {"os": "linux", "architecture": "amd64"}},
+		{"platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}}
+	]}`
+	singleArch := `{"config": {"digest": "sha256:abc"}}`
+
+	dockerHubClient = newMockedPlatformClient([]string{"v0.10.0", "v0.10.1", "v0.10.2"}, map[string]string{
+		"/v2/" + image + "/manifests/v0.10.2": singleArch,
+		"/v2/" + image + "/manifests/v0.10.1": multiArch,
+		"/v2/" + image + "/blobs/sha256:abc":  `{"os": "linux", "architecture": "amd64"}`,
+	})
+
+	tag, _, err := GetCompatibleTag(image, "v0.10.0")
+	require.NoError(err)
+	require.Equal("v0.10.1", tag)
+
+	dockerHubClient = newMockedPlatformClient([]string{"v0.10.0"}, map[string]string{
+		"/v2/" + image + "/manifests/v0.10.0": `{"architecture": "amd64"}`,
+	})
+
+	_, _, err = GetCompatibleTag(image, "v0.10.0")
+	require.EqualError(err, "srcd/cli-daemon has no image for linux/arm64, the compatible versions v0.10.0 "+
+		"are only published for other platforms: docker image is not available for this platform")
+	require.Equal(ErrPlatformNotSupported, errors.Cause(ParseErr(err)))
+}
+
+func TestGetCompatibleTagPlatformUnknown(t *testing.T) {
+	setPlatform(t, "linux/arm64")
+
+	// the manifests can't be read, so the newest version is used
+	dockerHubClient = newMockedPlatformClient([]string{"v0.10.0", "v0.10.1"}, nil)
+
+	tag, _, err := GetCompatibleTag(image, "v0.10.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "v0.10.1", tag)
+}
+
+func TestPlatformEnv(t *testing.T) {
+	setPlatform(t, "linux/arm64")
+
+	os.Setenv("DOCKER_DEFAULT_PLATFORM", "linux/amd64")
+	defer os.Unsetenv("DOCKER_DEFAULT_PLATFORM")
+
+	platform, err := Platform()
+	assert.NoError(t, err)
+	assert.Equal(t, "linux/amd64", platform)
+}
+
+// setPlatform makes Platform return the given platform until the test ends
+func setPlatform(t *testing.T, platform string) {
+	detect := detectPlatform
+	detectPlatform = func() (string, error) { return platform, nil }
+	platformOnce = sync.Once{}
+
+	t.Cleanup(func() {
+		detectPlatform = detect
+		platformOnce = sync.Once{}
+	})
+}
+
+func newMockedPlatformClient(tags []string, files map[string]string) *http.Client {
+	tagsClient := newMockedClient(tags)
+	mockedT := roundTripFunc(func(req *http.Request) *http.Response {
+		if b, ok := files[req.URL.Path]; ok {
+			return newResponse(200, b)
+		}
+
+		res, _ := tagsClient.Transport.RoundTrip(req)
+		return res
+	})
+	return &http.Client{Transport: mockedT}
+}
@@ -0,0 +1,148 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// PortConflictErr is returned by CheckPort when a port of the host is already
+// bound. Its cause is ErrPortInUse
+type PortConflictErr struct {
+	Port int
+	// Owner describes the container or process using the port, if it is
+	// known
+	Owner string
+}
+
+// Error implements error interface
+func (e *PortConflictErr) Error() string {
+	if e.Owner == "" {
+		return fmt.Sprintf("port %d is already in use", e.Port)
+	}
+
+	return fmt.Sprintf("port %d is already in use by %s", e.Port, e.Owner)
+}
+
+// Cause returns ErrPortInUse, for errors.Cause
+func (e *PortConflictErr) Cause() error {
+	return ErrPortInUse
+}
+
+// CheckPort returns a *PortConflictErr if the given port can't be published in
+// the docker host, because another container publishes it, or because another
+// process of the host listens on it. The containers whose name starts with
+// ignorePrefix are not taken into account, as they are replaced when the port
+// is published. The processes are only checked when docker runs in the local
+// host.
+func CheckPort(port int, ignorePrefix string) error {
+	cs, err := List()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cs {
+		if c.State != "running" || len(c.Names) == 0 {
+			continue
+		}
+
+		name := strings.TrimLeft(c.Names[0], "/")
+		for _, p := range c.Ports {
+			if int(p.PublicPort) != port {
+				continue
+			}
+
+			if ignorePrefix != "" && strings.HasPrefix(name, ignorePrefix) {
+				return nil
+			}
+
+			return &PortConflictErr{Port: port, Owner: "container " + name}
+		}
+	}
+
+	if !isLocalHost() {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+	if err != nil {
+		return &PortConflictErr{Port: port, Owner: portOwner(port)}
+	}
+
+	return l.Close()
+}
+
+// FreePort returns a port of the local host that no process listens on
+func FreePort() (int, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// isLocalHost returns true if the docker daemon runs in this host, so its
+// published ports are bound in it. That is also the case of Docker for Mac
+// and Windows, which forward them
+func isLocalHost() bool {
+	h := os.Getenv("DOCKER_HOST")
+	return h == "" || strings.HasPrefix(h, "unix://") || strings.HasPrefix(h, "npipe://")
+}
+
+// portOwner returns the name and pid of the process listening on the given
+// port, using lsof. It returns an empty string if it can't be found
+func portOwner(port int) string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+
+	out, err := exec.Command("lsof", "-nP",
+		fmt.Sprintf("-iTCP:%d", port), "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return ""
+	}
+
+	return parseLsof(out)
+}
+
+// parseLsof returns the first process in the lsof -Fpc output, e.g.
+// "p123\ncnginx\n", as "nginx (pid 123)"
+func parseLsof(out []byte) string {
+	var pid, cmd string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if len(line) < 2 {
+			continue
+		}
+
+		switch line[0] {
+		case 'p':
+			if pid == "" {
+				pid = line[1:]
+			}
+		case 'c':
+			if cmd == "" {
+				cmd = line[1:]
+			}
+		}
+	}
+
+	if pid == "" {
+		return ""
+	}
+
+	if cmd == "" {
+		return "process " + pid
+	}
+
+	return fmt.Sprintf("%s (pid %s)", cmd, pid)
+}
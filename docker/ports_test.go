@@ -0,0 +1,40 @@
+package docker
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLsof(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("nginx (pid 123)", parseLsof([]byte("p123\ncnginx\nf6\np456\ncother\n")))
+	assert.Equal("process 123", parseLsof([]byte("p123\n")))
+	assert.Equal("", parseLsof(nil))
+}
+
+func TestPortConflictErr(t *testing.T) {
+	assert := assert.New(t)
+
+	var err error = &PortConflictErr{Port: 3306, Owner: "mysqld (pid 42)"}
+	assert.EqualError(err, "port 3306 is already in use by mysqld (pid 42)")
+	assert.Equal(ErrPortInUse, errors.Cause(errors.Wrap(err, "could not start")))
+
+	err = &PortConflictErr{Port: 3306}
+	assert.EqualError(err, "port 3306 is already in use")
+}
+
+func TestFreePort(t *testing.T) {
+	assert := assert.New(t)
+
+	port, err := FreePort()
+	assert.NoError(err)
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	assert.NoError(err)
+	l.Close()
+}
@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ProxyConfig has the HTTP proxy settings, as in the HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY environment variables
+type ProxyConfig struct {
+	HTTP    string
+	HTTPS   string
+	NoProxy string
+}
+
+// IsEmpty returns whether there is no proxy set
+func (p ProxyConfig) IsEmpty() bool {
+	return p.HTTP == "" && p.HTTPS == ""
+}
+
+// vars returns the proxy settings by environment variable name
+func (p ProxyConfig) vars() map[string]string {
+	return map[string]string{
+		"HTTP_PROXY":  p.HTTP,
+		"HTTPS_PROXY": p.HTTPS,
+		"NO_PROXY":    p.NoProxy,
+	}
+}
+
+// SetProxy sets the proxy environment variables of the process to the
+// non-empty settings of p, so the requests to the docker registries are sent
+// through the proxy. It must be called before any request is made
+func SetProxy(p ProxyConfig) error {
+	for k, v := range p.vars() {
+		if v == "" {
+			continue
+		}
+
+		for _, name := range []string{k, strings.ToLower(k)} {
+			if err := os.Setenv(name, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ProxyFromEnvironment returns the proxy settings of the environment
+// variables, in upper or lower case
+func ProxyFromEnvironment() ProxyConfig {
+	get := func(name string) string {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+
+		return os.Getenv(strings.ToLower(name))
+	}
+
+	return ProxyConfig{
+		HTTP:    get("HTTP_PROXY"),
+		HTTPS:   get("HTTPS_PROXY"),
+		NoProxy: get("NO_PROXY"),
+	}
+}
+
+// ProxyEnv returns the environment variables that pass the proxy settings to
+// a container, in upper and lower case as tools read either, sorted. The
+// given hosts, like the names of the other containers, are added to NO_PROXY,
+// so they are reached directly. It is empty if there is no proxy
+func ProxyEnv(p ProxyConfig, hosts ...string) []string {
+	if p.IsEmpty() {
+		return nil
+	}
+
+	var noProxy []string
+	if p.NoProxy != "" {
+		noProxy = append(noProxy, p.NoProxy)
+	}
+	p.NoProxy = strings.Join(append(noProxy, hosts...), ",")
+
+	var env []string
+	for k, v := range p.vars() {
+		if v == "" {
+			continue
+		}
+
+		env = append(env, k+"="+v, strings.ToLower(k)+"="+v)
+	}
+
+	sort.Strings(env)
+	return env
+}
+
+// WithProxy passes the proxy settings to the container, see ProxyEnv
+func WithProxy(p ProxyConfig, hosts ...string) ConfigOption {
+	env := ProxyEnv(p, hosts...)
+	return func(cfg *container.Config, hc *container.HostConfig) {
+		cfg.Env = append(cfg.Env, env...)
+	}
+}
@@ -0,0 +1,63 @@
+package docker
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearProxyEnv unsets the proxy environment variables, returning the
+// function that restores them
+func clearProxyEnv() func() {
+	old := make(map[string]string)
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+		if v, ok := os.LookupEnv(name); ok {
+			old[name] = v
+		}
+		os.Unsetenv(name)
+	}
+
+	return func() {
+		for name, v := range old {
+			os.Setenv(name, v)
+		}
+	}
+}
+
+func TestSetProxy(t *testing.T) {
+	require := require.New(t)
+	defer clearProxyEnv()()
+
+	require.True(ProxyFromEnvironment().IsEmpty())
+
+	os.Setenv("https_proxy", "http://proxy:3128")
+	require.Equal(ProxyConfig{HTTPS: "http://proxy:3128"}, ProxyFromEnvironment())
+
+	require.NoError(SetProxy(ProxyConfig{HTTP: "http://other:3128", NoProxy: "corp.local"}))
+	require.Equal(ProxyConfig{
+		HTTP:    "http://other:3128",
+		HTTPS:   "http://proxy:3128",
+		NoProxy: "corp.local",
+	}, ProxyFromEnvironment())
+	require.Equal("http://other:3128", os.Getenv("http_proxy"))
+}
+
+func TestProxyEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(ProxyEnv(ProxyConfig{NoProxy: "corp.local"}, "srcd-cli-gitbase"))
+	assert.Equal([]string{
+		"HTTPS_PROXY=http://proxy:3128",
+		"NO_PROXY=srcd-cli-gitbase",
+		"https_proxy=http://proxy:3128",
+		"no_proxy=srcd-cli-gitbase",
+	}, ProxyEnv(ProxyConfig{HTTPS: "http://proxy:3128"}, "srcd-cli-gitbase"))
+	assert.Equal([]string{
+		"HTTP_PROXY=http://proxy:3128",
+		"NO_PROXY=corp.local,localhost",
+		"http_proxy=http://proxy:3128",
+		"no_proxy=corp.local,localhost",
+	}, ProxyEnv(ProxyConfig{HTTP: "http://proxy:3128", NoProxy: "corp.local"}, "localhost"))
+}
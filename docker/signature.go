@@ -0,0 +1,366 @@
+package docker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/src-d/engine/logging"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// cosignAnnotation is the annotation of the signature layers created by
+// cosign, with the base64 signature of the layer payload
+const cosignAnnotation = "dev.cosignproject.cosign/signature"
+
+var (
+	signatureMu   sync.RWMutex
+	signatureKeys map[string]*ecdsa.PublicKey
+)
+
+// SetSignatureKeys requires the images of each registry, like docker.io or
+// gcr.io, to be signed with cosign before their containers are started. The
+// keys are the PEM encoded public keys of the signers, by registry. Images of
+// any other registry are not verified
+func SetSignatureKeys(keys map[string]string) error {
+	parsed := make(map[string]*ecdsa.PublicKey, len(keys))
+	for registry, key := range keys {
+		k, err := parsePublicKey(key)
+		if err != nil {
+			return errors.Wrapf(err, "invalid signature key for registry %s", registry)
+		}
+
+		parsed[normalizeRegistry(registry)] = k
+	}
+
+	signatureMu.Lock()
+	signatureKeys = parsed
+	signatureMu.Unlock()
+	return nil
+}
+
+func signatureKey(registry string) *ecdsa.PublicKey {
+	signatureMu.RLock()
+	defer signatureMu.RUnlock()
+	return signatureKeys[registry]
+}
+
+func parsePublicKey(key string) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, fmt.Errorf("the key is not PEM encoded")
+	}
+
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ecKey, ok := k.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("only ECDSA keys, like the ones created by cosign generate-key-pair, are supported")
+	}
+
+	return ecKey, nil
+}
+
+// verifyImage checks the cosign signature of the installed image if its
+// registry requires it, returning ErrUnsignedImage if it can't be verified
+func verifyImage(ctx context.Context, c *client.Client, image string) error {
+	ref := parseReference(image)
+	key := signatureKey(ref.registry)
+	if key == nil {
+		return nil
+	}
+
+	info, _, err := c.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return errors.Wrapf(err, "could not inspect image %s", image)
+	}
+
+	digest := repoDigest(info.RepoDigests, ref)
+	if digest == "" {
+		return errors.Wrapf(ErrUnsignedImage, "%s was not pulled from %s, it has no digest to verify", image, ref.registry)
+	}
+
+	if err := verifySignature(ctx, ref, digest, key); err != nil {
+		return errors.Wrapf(ErrUnsignedImage, "could not verify %s: %s", image, err)
+	}
+
+	logging.Registry().Debugf("verified the signature of %s (%s)", image, digest)
+	return nil
+}
+
+// repoDigest returns the digest of the image in the repository of ref, from
+// the repo digests of an installed image, e.g. srcd/gitbase@sha256:abc
+func repoDigest(repoDigests []string, ref reference) string {
+	for _, rd := range repoDigests {
+		parts := strings.SplitN(rd, "@", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		r := parseReference(parts[0])
+		if r.registry == ref.registry && r.repository == ref.repository {
+			return parts[1]
+		}
+	}
+
+	return ""
+}
+
+// verifySignature checks that a cosign signature of the image digest, stored
+// in the sha256-<hex>.sig tag of its repository, was made with the key
+func verifySignature(ctx context.Context, ref reference, digest string, key *ecdsa.PublicKey) error {
+	var manifest struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+
+	tag := strings.Replace(digest, ":", "-", 1) + ".sig"
+	b, err := registryFetch(ctx, ref, "manifests/"+tag, "application/vnd.oci.image.manifest.v1+json")
+	if err != nil {
+		return errors.Wrap(err, "could not get its signatures")
+	}
+
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return errors.Wrap(err, "could not parse its signatures")
+	}
+
+	for _, l := range manifest.Layers {
+		sig, ok := l.Annotations[cosignAnnotation]
+		if !ok {
+			continue
+		}
+
+		payload, err := registryFetch(ctx, ref, "blobs/"+l.Digest, "")
+		if err != nil {
+			return errors.Wrap(err, "could not get its signatures")
+		}
+
+		if err := verifyPayload(payload, sig, digest, key); err != nil {
+			logging.Registry().Debugf("ignoring signature %s of %s: %s", l.Digest, digest, err)
+			continue
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("none of its signatures was made with the key of %s", ref.registry)
+}
+
+// verifyPayload checks the signature of a cosign payload, and that the payload
+// is about the given image digest
+func verifyPayload(payload []byte, signature, digest string, key *ecdsa.PublicKey) error {
+	der, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+
+	hash := sha256.Sum256(payload)
+	if !ecdsa.Verify(key, hash[:], sig.R, sig.S) {
+		return fmt.Errorf("signature does not match the key")
+	}
+
+	var p struct {
+		Critical struct {
+			Image struct {
+				Digest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return errors.Wrap(err, "invalid signature payload")
+	}
+
+	if p.Critical.Image.Digest != digest {
+		return fmt.Errorf("signature is for image %s", p.Critical.Image.Digest)
+	}
+
+	return nil
+}
+
+// reference is the location of an image in a registry
+type reference struct {
+	// registry is the host of the registry, with docker.io for Docker Hub
+	registry string
+	// repository is the path of the image in the registry, e.g. srcd/gitbase
+	// or library/mysql
+	repository string
+}
+
+// parseReference returns the registry and repository of an image name, like
+// srcd/gitbase:v0.19.0, mysql or gcr.io/project/image@sha256:abc
+func parseReference(image string) reference {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+
+	ref := reference{registry: "docker.io", repository: name}
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref = reference{registry: normalizeRegistry(host), repository: name[i+1:]}
+		}
+	}
+
+	if ref.registry == "docker.io" && !strings.Contains(ref.repository, "/") {
+		ref.repository = "library/" + ref.repository
+	}
+
+	return ref
+}
+
+func normalizeRegistry(registry string) string {
+	switch registry {
+	case "", "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	default:
+		return registry
+	}
+}
+
+func registryURL(ref reference, path string) string {
+	host := ref.registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+
+	return fmt.Sprintf("https://%s/v2/%s/%s", host, ref.repository, path)
+}
+
+// registryFetch requests the path of the repository to its registry. The
+// token required by the registry is requested if it answers with an
+// authentication challenge
+func registryFetch(ctx context.Context, ref reference, path, accept string) ([]byte, error) {
+	do := func(token string) (*http.Response, error) {
+		req, err := http.NewRequest("GET", registryURL(ref, path), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if accept != "" {
+			req.Header.Add("Accept", accept)
+		}
+
+		if token != "" {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+
+		return dockerHubClient.Do(req.WithContext(ctx))
+	}
+
+	r, err := do("")
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't request %s registry", ref.registry)
+	}
+
+	if r.StatusCode == http.StatusUnauthorized {
+		r.Body.Close()
+
+		token, err := challengeToken(ctx, r.Header.Get("WWW-Authenticate"), ref.registry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't authorize in %s registry", ref.registry)
+		}
+
+		if r, err = do(token); err != nil {
+			return nil, errors.Wrapf(err, "can't request %s registry", ref.registry)
+		}
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("incorrect status code: %d while requesting %s in %s registry", r.StatusCode, path, ref.registry)
+	}
+
+	return ioutil.ReadAll(r.Body)
+}
+
+// challengeToken requests a token for a challenge like
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="..."
+// with the credentials of the registry, or an anonymous one if it has none
+func challengeToken(ctx context.Context, challenge, registry string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	params := make(map[string]string)
+	for _, p := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	if params["realm"] == "" {
+		return "", fmt.Errorf("authentication challenge %q has no realm", challenge)
+	}
+
+	v := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			v.Set(k, params[k])
+		}
+	}
+
+	req, err := http.NewRequest("GET", params["realm"]+"?"+v.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if err := setBasicAuth(req, registry); err != nil {
+		return "", err
+	}
+
+	r, err := dockerHubClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("incorrect status code: %d while requesting a token", r.StatusCode)
+	}
+
+	var resp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return "", errors.Wrap(err, "can't parse the token")
+	}
+
+	if resp.Token == "" {
+		return resp.AccessToken, nil
+	}
+
+	return resp.Token, nil
+}
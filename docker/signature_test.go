@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	cases := map[string]reference{
+		"srcd/gitbase:v0.19.0":             {"docker.io", "srcd/gitbase"},
+		"mysql":                            {"docker.io", "library/mysql"},
+		"docker.io/srcd/gitbase":           {"docker.io", "srcd/gitbase"},
+		"gcr.io/project/image@sha256:abc":  {"gcr.io", "project/image"},
+		"localhost:5000/image:v1":          {"localhost:5000", "image"},
+		"registry-1.docker.io/library/foo": {"docker.io", "library/foo"},
+	}
+
+	for image, expected := range cases {
+		assert.Equal(t, expected, parseReference(image), image)
+	}
+}
+
+func TestRepoDigest(t *testing.T) {
+	digests := []string{"other/image@sha256:123", "srcd/gitbase@sha256:abc"}
+	assert.Equal(t, "sha256:abc", repoDigest(digests, parseReference("srcd/gitbase:v0.19.0")))
+	assert.Equal(t, "", repoDigest(digests, parseReference("gcr.io/srcd/gitbase")))
+}
+
+func TestVerifySignature(t *testing.T) {
+	require := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	digest := "sha256:abc"
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"index.docker.io/srcd/gitbase"},` +
+		`"image":{"docker-manifest-digest":"` + digest + `"},"type":"cosign container image signature"}}`)
+
+	hash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(err)
+
+	manifest := `{"layers": [{"digest": "sha256:payload", "annotations": {"` +
+		cosignAnnotation + `": "` + base64.StdEncoding.EncodeToString(sig) + `"}}]}`
+
+	dockerHubClient = newMockedRegistryClient(map[string]string{
+		"/v2/srcd/gitbase/manifests/sha256-abc.sig": manifest,
+		"/v2/srcd/gitbase/blobs/sha256:payload":     string(payload),
+	})
+
+	ref := parseReference("srcd/gitbase")
+	require.NoError(verifySignature(context.Background(), ref, digest, &key.PublicKey))
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+	err = verifySignature(context.Background(), ref, digest, &other.PublicKey)
+	require.EqualError(err, "none of its signatures was made with the key of docker.io")
+
+	err = verifySignature(context.Background(), ref, "sha256:def", &key.PublicKey)
+	require.EqualError(err, "could not get its signatures: incorrect status code: 404 while "+
+		"requesting manifests/sha256-def.sig in docker.io registry")
+}
+
+func TestSetSignatureKeys(t *testing.T) {
+	require := require.New(t)
+	defer SetSignatureKeys(nil)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(err)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	require.NoError(SetSignatureKeys(map[string]string{"index.docker.io": pemKey}))
+	require.Equal(&key.PublicKey, signatureKey("docker.io"))
+	require.Nil(signatureKey("gcr.io"))
+
+	err = SetSignatureKeys(map[string]string{"gcr.io": "not a key"})
+	require.EqualError(err, "invalid signature key for registry gcr.io: the key is not PEM encoded")
+}
+
+// newMockedRegistryClient returns a client for a registry that asks for a
+// token, and then serves the given files
+func newMockedRegistryClient(files map[string]string) *http.Client {
+	mockedT := roundTripFunc(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/token" {
+			return newResponse(200, `{"token":"test"}`)
+		}
+
+		if req.Header.Get("Authorization") != "Bearer test" {
+			res := newResponse(401, `{}`)
+			res.Header.Set("WWW-Authenticate",
+				`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:srcd/gitbase:pull"`)
+			return res
+		}
+
+		if b, ok := files[req.URL.Path]; ok {
+			return newResponse(200, b)
+		}
+
+		return newResponse(404, `{}`)
+	})
+	return &http.Client{Transport: mockedT}
+}
@@ -0,0 +1,19 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+)
+
+// HostUser returns the user running the current process, in the form
+// uid:gid, so the containers that write in the directories of the host don't
+// leave files owned by root. It returns an empty string if hostOS is not
+// linux, as the docker VM of the other systems already maps the owner of
+// the files written in the shared directories
+func HostUser(hostOS string) string {
+	if hostOS != "linux" {
+		return ""
+	}
+
+	return fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+}
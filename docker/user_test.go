@@ -0,0 +1,17 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostUser(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()), HostUser("linux"))
+	assert.Equal("", HostUser("darwin"))
+	assert.Equal("", HostUser("windows"))
+}
@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"regexp"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// volumeMountPath is where the volumes are mounted in the helper containers
+// used to copy their contents
+const volumeMountPath = "/volume"
+
+// anonymousVolumeRegexp matches the names docker gives to the anonymous
+// volumes, like the ones created for the VOLUME instructions of an image
+var anonymousVolumeRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// isAnonymousMount returns whether m mounts an anonymous volume
+func isAnonymousMount(m mount.Mount) bool {
+	return m.Type == mount.TypeVolume && anonymousVolumeRegexp.MatchString(m.Source)
+}
+
+// AnonymousVolumes returns the mounts of the anonymous volumes of the
+// container with the given name, so a new container can reuse them
+func AnonymousVolumes(ctx context.Context, name string) ([]mount.Mount, error) {
+	in, err := Inspect(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var mounts []mount.Mount
+	for _, m := range in.Mounts {
+		if isAnonymousMount(m) {
+			mounts = append(mounts, m)
+		}
+	}
+
+	return mounts, nil
+}
+
+// VolumeExists returns true if there is a volume with the given name
+func VolumeExists(ctx context.Context, name string) (bool, error) {
+	c, err := GetClient()
+	if err != nil {
+		return false, errors.Wrap(err, "could not create docker client")
+	}
+
+	_, err = c.VolumeInspect(ctx, name)
+	if client.IsErrNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "could not inspect volume %s", name)
+	}
+
+	return true, nil
+}
+
+// ExportVolume writes to w a tar archive with the contents of the volume with
+// the given name. The archive is read through a container, that is never
+// started, created from the given image, which must be installed
+func ExportVolume(ctx context.Context, name, image string, w io.Writer) error {
+	return withVolumeContainer(ctx, name, image, func(c *client.Client, id string) error {
+		r, _, err := c.CopyFromContainer(ctx, id, volumeMountPath)
+		if err != nil {
+			return errors.Wrapf(err, "could not read volume %s", name)
+		}
+		defer r.Close()
+
+		_, err = io.Copy(w, r)
+		return errors.Wrapf(err, "could not read volume %s", name)
+	})
+}
+
+// ImportVolume extracts into the volume with the given name the tar archive,
+// optionally compressed, read from r, as written by ExportVolume. The volume is
+// created if it does not exist
+func ImportVolume(ctx context.Context, name, image string, r io.Reader) error {
+	if err := CreateVolume(ctx, name); err != nil {
+		return errors.Wrapf(err, "could not create volume %s", name)
+	}
+
+	return withVolumeContainer(ctx, name, image, func(c *client.Client, id string) error {
+		err := c.CopyToContainer(ctx, id, "/", r, types.CopyToContainerOptions{})
+		return errors.Wrapf(err, "could not write volume %s", name)
+	})
+}
+
+func withVolumeContainer(
+	ctx context.Context,
+	name, image string,
+	fn func(c *client.Client, id string) error,
+) error {
+	c, err := GetClient()
+	if err != nil {
+		return errors.Wrap(err, "could not create docker client")
+	}
+
+	res, err := c.ContainerCreate(ctx,
+		&container.Config{Image: image},
+		&container.HostConfig{
+			Mounts: []mount.Mount{{
+				Type:   mount.TypeVolume,
+				Source: name,
+				Target: volumeMountPath,
+			}},
+		},
+		nil, "")
+	if err != nil {
+		return errors.Wrapf(err, "could not create container to access volume %s", name)
+	}
+
+	defer c.ContainerRemove(context.Background(), res.ID, types.ContainerRemoveOptions{Force: true})
+
+	return fn(c, res.ID)
+}
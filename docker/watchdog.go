@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// watchdogReader reads from r, calling cancel if no bytes are read for the
+// idle timeout. It is used for the streams whose duration can't be known in
+// advance, like image pulls, but that must keep making progress
+type watchdogReader struct {
+	r    io.Reader
+	idle time.Duration
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	stalled bool
+}
+
+func newWatchdogReader(r io.Reader, idle time.Duration, cancel func()) *watchdogReader {
+	w := &watchdogReader{r: r, idle: idle}
+	w.timer = time.AfterFunc(idle, func() {
+		w.mu.Lock()
+		w.stalled = true
+		w.mu.Unlock()
+
+		cancel()
+	})
+
+	return w
+}
+
+func (w *watchdogReader) Read(p []byte) (int, error) {
+	n, err := w.r.Read(p)
+	if n > 0 {
+		w.mu.Lock()
+		if !w.stalled {
+			w.timer.Reset(w.idle)
+		}
+		w.mu.Unlock()
+	}
+
+	return n, err
+}
+
+// Stop stops the watchdog, it must be called once the stream is read
+func (w *watchdogReader) Stop() {
+	w.timer.Stop()
+}
+
+// Stalled returns whether the idle timeout was reached
+func (w *watchdogReader) Stalled() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.stalled
+}
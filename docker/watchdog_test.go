@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// slowReader returns one byte every interval, until n bytes were read
+type slowReader struct {
+	n        int
+	interval time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+
+	time.Sleep(r.interval)
+	r.n--
+	p[0] = 'x'
+	return 1, nil
+}
+
+func TestWatchdogReaderProgress(t *testing.T) {
+	require := require.New(t)
+
+	canceled := make(chan struct{})
+	// the whole read takes longer than the idle timeout
+	w := newWatchdogReader(&slowReader{n: 10, interval: 10 * time.Millisecond},
+		50*time.Millisecond, func() { close(canceled) })
+
+	b, err := ioutil.ReadAll(w)
+	w.Stop()
+	require.NoError(err)
+	require.Len(b, 10)
+	require.False(w.Stalled())
+
+	select {
+	case <-canceled:
+		require.Fail("the reader was canceled")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatchdogReaderStalled(t *testing.T) {
+	require := require.New(t)
+
+	canceled := make(chan struct{})
+	w := newWatchdogReader(&slowReader{n: 1, interval: time.Second},
+		20*time.Millisecond, func() { close(canceled) })
+	defer w.Stop()
+
+	select {
+	case <-canceled:
+	case <-time.After(500 * time.Millisecond):
+		require.Fail("the reader was not canceled")
+	}
+
+	require.True(w.Stalled())
+}
@@ -0,0 +1,420 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	analyticsDataMountPath = "/metabase-data"
+	// AnalyticsPasswordFile is the file of the analytics container with the
+	// generated password of the admin, in the data volume, so it survives
+	// the container
+	AnalyticsPasswordFile = analyticsDataMountPath + "/admin-password"
+	// analyticsDatabase is the name of the gitbase database in Metabase
+	analyticsDatabase = "gitbase"
+	// analyticsDashboard is the name of the starter dashboard
+	analyticsDashboard = "Repositories"
+)
+
+var analytics = &components.Analytics
+
+// analyticsPasswordScript prints the generated password of the admin,
+// creating it the first time
+var analyticsPasswordScript = fmt.Sprintf(`set -e
+f=%s
+if [ ! -s $f ]; then
+  (umask 077; head -c 16 /dev/urandom | od -An -tx1 | tr -d ' \n' > $f)
+fi
+cat $f
+`, AnalyticsPasswordFile)
+
+// analyticsCard is a question of the starter dashboard, a native query shown
+// with the given Metabase visualization
+type analyticsCard struct {
+	Name    string
+	Display string
+	Query   string
+	// Width and Height are the size of the card in the dashboard grid, 18
+	// columns wide
+	Width, Height int
+}
+
+// analyticsCards are the questions of the starter dashboard, in the order
+// they are laid out
+var analyticsCards = []analyticsCard{
+	{
+		Name:    "Repositories",
+		Display: "scalar",
+		Query:   "SELECT COUNT(*) AS repositories FROM repositories",
+		Width:   6, Height: 4,
+	},
+	{
+		Name:    "Commits",
+		Display: "scalar",
+		Query:   "SELECT COUNT(*) AS commits FROM commits",
+		Width:   6, Height: 4,
+	},
+	{
+		Name:    "Authors",
+		Display: "scalar",
+		Query:   "SELECT COUNT(DISTINCT commit_author_email) AS authors FROM commits",
+		Width:   6, Height: 4,
+	},
+	{
+		Name:    "Commits per month",
+		Display: "line",
+		Query: "SELECT CONCAT(YEAR(committer_when), '-', LPAD(MONTH(committer_when), 2, '0')) AS month, " +
+			"COUNT(*) AS commits FROM commits GROUP BY month ORDER BY month",
+		Width: 18, Height: 6,
+	},
+	{
+		Name:    "Commits per repository",
+		Display: "row",
+		Query: "SELECT repository_id, COUNT(*) AS commits FROM commits " +
+			"GROUP BY repository_id ORDER BY commits DESC LIMIT 20",
+		Width: 9, Height: 8,
+	},
+	{
+		Name:    "Top authors",
+		Display: "table",
+		Query: "SELECT commit_author_name AS author, COUNT(*) AS commits FROM commits " +
+			"GROUP BY commit_author_name ORDER BY commits DESC LIMIT 20",
+		Width: 9, Height: 8,
+	},
+	{
+		Name:    "Files per language",
+		Display: "pie",
+		Query: "SELECT LANGUAGE(cf.file_path) AS language, COUNT(*) AS files " +
+			"FROM refs r NATURAL JOIN commit_files cf WHERE r.ref_name = 'HEAD' " +
+			"GROUP BY language ORDER BY files DESC",
+		Width: 18, Height: 8,
+	},
+}
+
+// AnalyticsCredentials returns the email and password of the admin of the
+// running analytics component. If no password is set in the config, the one
+// generated the first time it started is returned
+func AnalyticsCredentials(ctx context.Context, config *api.Config) (string, string, error) {
+	user, password := config.AnalyticsCredentials()
+	if password != "" {
+		return user, password, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	code, err := docker.Exec(ctx, analytics.Name, []string{"sh", "-c", analyticsPasswordScript}, &stdout, &stderr)
+	if err != nil {
+		return "", "", err
+	}
+
+	if code != 0 {
+		return "", "", fmt.Errorf("could not get the analytics password: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return user, strings.TrimSpace(stdout.String()), nil
+}
+
+func (e *Engine) analyticsComponent(port int) (*Component, error) {
+	port = e.publicPort(analytics.Name, port)
+
+	dataVolumeName := e.analyticsVolumeName()
+	if err := docker.CreateVolume(context.TODO(), dataVolumeName); err != nil {
+		return nil, errors.Wrapf(err, "can't create volume for the analytics data")
+	}
+
+	config, host := analyticsConfig(e.overrides(analytics.Name,
+		docker.WithVolume(dataVolumeName, analyticsDataMountPath, e.hostOS),
+		docker.WithPort(port, components.AnalyticsPort),
+	)...)
+
+	return e.newComponent(e.component(analytics), config, host), nil
+}
+
+func analyticsConfig(opts ...docker.ConfigOption) (*container.Config, *container.HostConfig) {
+	config := &container.Config{
+		Env: []string{
+			fmt.Sprintf("MB_DB_FILE=%s/metabase.db", analyticsDataMountPath),
+			fmt.Sprintf("MB_JETTY_PORT=%d", components.AnalyticsPort),
+			"MB_CHECK_FOR_UPDATES=false",
+			"MB_ANON_TRACKING_ENABLED=false",
+		},
+	}
+	host := &container.HostConfig{}
+	docker.ApplyOptions(config, host, opts...)
+
+	return config, host
+}
+
+// analyticsReady checks that Metabase replies, and sets it up the first time,
+// so the component is only ready once the gitbase database exists
+func (e *Engine) analyticsReady(ctx context.Context) error {
+	addr, err := e.addr(analytics.Name, components.AnalyticsPort)
+	if err != nil {
+		return err
+	}
+
+	mb := &metabase{base: "http://" + addr}
+
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	if err := mb.do(probeCtx, "GET", "/api/health", nil, nil); err != nil {
+		return err
+	}
+
+	return e.setupAnalytics(ctx, mb)
+}
+
+// setupAnalytics creates the admin of Metabase, the gitbase database and the
+// starter dashboard, unless it was already set up. The dashboard is optional,
+// so its errors are only logged
+func (e *Engine) setupAnalytics(ctx context.Context, mb *metabase) error {
+	var props map[string]interface{}
+	if err := mb.do(ctx, "GET", "/api/session/properties", nil, &props); err != nil {
+		return err
+	}
+
+	token, _ := metabaseProperty(props, "setup-token").(string)
+	if done, _ := metabaseProperty(props, "has-user-setup").(bool); done || token == "" {
+		return nil
+	}
+
+	email, password, err := AnalyticsCredentials(ctx, &e.config)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("setting up %s with the gitbase database", analytics.Name)
+
+	gitbaseUser, gitbasePassword := e.config.GitbaseCredentials()
+	var session struct {
+		ID string `json:"id"`
+	}
+	err = mb.do(ctx, "POST", "/api/setup", map[string]interface{}{
+		"token": token,
+		"user": map[string]string{
+			"email":      email,
+			"password":   password,
+			"first_name": "source{d}",
+			"last_name":  "Engine",
+			"site_name":  "source{d} Engine",
+		},
+		"prefs": map[string]interface{}{
+			"site_name":      "source{d} Engine",
+			"allow_tracking": false,
+		},
+		"database": map[string]interface{}{
+			"engine": "mysql",
+			"name":   analyticsDatabase,
+			"details": map[string]interface{}{
+				"host":     gitbase.Name,
+				"port":     components.GitbasePort,
+				"dbname":   "gitbase",
+				"user":     gitbaseUser,
+				"password": gitbasePassword,
+			},
+		},
+	}, &session)
+	if err != nil {
+		return errors.Wrap(err, "could not set up the analytics component")
+	}
+
+	mb.session = session.ID
+	if err := mb.createDashboard(ctx); err != nil {
+		log.Errorf(err, "could not create the starter dashboard")
+	}
+
+	return nil
+}
+
+// metabase is a client of the Metabase REST API
+type metabase struct {
+	base    string
+	session string
+}
+
+// do sends a request with the body encoded as JSON, and decodes the response
+// into res, if it is not nil
+func (m *metabase) do(ctx context.Context, method, path string, body, res interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, m.base+path, r)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if m.session != "" {
+		req.Header.Set("X-Metabase-Session", m.session)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if res == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// databaseID returns the id of the database with the given name. Older
+// versions of Metabase list the databases in an array, and newer ones in the
+// data field of an object
+func (m *metabase) databaseID(ctx context.Context, name string) (int, error) {
+	var raw json.RawMessage
+	if err := m.do(ctx, "GET", "/api/database", nil, &raw); err != nil {
+		return 0, err
+	}
+
+	type database struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	var dbs []database
+	if err := json.Unmarshal(raw, &dbs); err != nil {
+		var page struct {
+			Data []database `json:"data"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return 0, err
+		}
+
+		dbs = page.Data
+	}
+
+	for _, db := range dbs {
+		if db.Name == name {
+			return db.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("database %s not found", name)
+}
+
+// createDashboard creates the questions of analyticsCards over the gitbase
+// database, and a dashboard with all of them
+func (m *metabase) createDashboard(ctx context.Context) error {
+	dbID, err := m.databaseID(ctx, analyticsDatabase)
+	if err != nil {
+		return err
+	}
+
+	var dashboard struct {
+		ID int `json:"id"`
+	}
+	err = m.do(ctx, "POST", "/api/dashboard", map[string]interface{}{
+		"name":        analyticsDashboard,
+		"description": "Metrics of the repositories analyzed by gitbase",
+	}, &dashboard)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range analyticsLayout(analyticsCards) {
+		var card struct {
+			ID int `json:"id"`
+		}
+		err := m.do(ctx, "POST", "/api/card", map[string]interface{}{
+			"name":                   l.card.Name,
+			"display":                l.card.Display,
+			"visualization_settings": map[string]interface{}{},
+			"dataset_query": map[string]interface{}{
+				"type":     "native",
+				"database": dbID,
+				"native":   map[string]interface{}{"query": l.card.Query},
+			},
+		}, &card)
+		if err != nil {
+			return errors.Wrapf(err, "could not create question %q", l.card.Name)
+		}
+
+		path := fmt.Sprintf("/api/dashboard/%d/cards", dashboard.ID)
+		err = m.do(ctx, "POST", path, map[string]interface{}{
+			"cardId": card.ID,
+			"row":    l.row,
+			"col":    l.col,
+			"sizeX":  l.card.Width,
+			"sizeY":  l.card.Height,
+		}, nil)
+		if err != nil {
+			return errors.Wrapf(err, "could not add question %q to the dashboard", l.card.Name)
+		}
+	}
+
+	return nil
+}
+
+// analyticsDashboardColumns is the width of the Metabase dashboard grid
+const analyticsDashboardColumns = 18
+
+// cardPosition is the place of a card in the dashboard grid
+type cardPosition struct {
+	card     analyticsCard
+	row, col int
+}
+
+// analyticsLayout places the cards left to right, starting a new row when
+// the next one doesn't fit in the current one
+func analyticsLayout(cards []analyticsCard) []cardPosition {
+	var res []cardPosition
+	row, col, height := 0, 0, 0
+	for _, c := range cards {
+		if col+c.Width > analyticsDashboardColumns {
+			row, col, height = row+height, 0, 0
+		}
+
+		res = append(res, cardPosition{card: c, row: row, col: col})
+		col += c.Width
+		if c.Height > height {
+			height = c.Height
+		}
+	}
+
+	return res
+}
+
+// metabaseProperty returns the public setting with the given name, which is
+// dash separated in newer versions of Metabase and underscore separated in
+// older ones
+func metabaseProperty(props map[string]interface{}, name string) interface{} {
+	if v, ok := props[name]; ok {
+		return v
+	}
+
+	return props[strings.Replace(name, "-", "_", -1)]
+}
+
+func (e *Engine) analyticsVolumeName() string {
+	return analytics.Name + "-data"
+}
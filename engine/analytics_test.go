@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	layout := analyticsLayout([]analyticsCard{
+		{Name: "a", Width: 6, Height: 4},
+		{Name: "b", Width: 12, Height: 2},
+		{Name: "c", Width: 18, Height: 6},
+		{Name: "d", Width: 9, Height: 8},
+		{Name: "e", Width: 9, Height: 8},
+	})
+
+	var pos [][2]int
+	for _, l := range layout {
+		pos = append(pos, [2]int{l.row, l.col})
+	}
+
+	assert.Equal([][2]int{{0, 0}, {0, 6}, {4, 0}, {10, 0}, {10, 9}}, pos)
+
+	for _, l := range analyticsLayout(analyticsCards) {
+		assert.True(l.col+l.card.Width <= analyticsDashboardColumns, l.card.Name)
+	}
+}
+
+func TestMetabaseProperty(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("a", metabaseProperty(map[string]interface{}{"setup-token": "a"}, "setup-token"))
+	assert.Equal("b", metabaseProperty(map[string]interface{}{"setup_token": "b"}, "setup-token"))
+	assert.Nil(metabaseProperty(map[string]interface{}{}, "setup-token"))
+}
+
+func TestSetupAnalyticsDone(t *testing.T) {
+	require := require.New(t)
+
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"setup_token":    "token",
+			"has_user_setup": true,
+		})
+	}))
+	defer srv.Close()
+
+	e := New(Options{Workdir: "/tmp"})
+	require.NoError(e.setupAnalytics(context.Background(), &metabase{base: srv.URL}))
+	require.Equal([]string{"/api/session/properties"}, paths)
+}
+
+func TestMetabaseDatabaseID(t *testing.T) {
+	require := require.New(t)
+
+	for _, body := range []string{
+		`[{"id": 1, "name": "Sample"}, {"id": 2, "name": "gitbase"}]`,
+		`{"data": [{"id": 1, "name": "Sample"}, {"id": 2, "name": "gitbase"}]}`,
+	} {
+		body := body
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal("session", r.Header.Get("X-Metabase-Session"))
+			_, _ = w.Write([]byte(body))
+		}))
+
+		id, err := (&metabase{base: srv.URL, session: "session"}).databaseID(context.Background(), "gitbase")
+		srv.Close()
+		require.NoError(err)
+		require.Equal(2, id)
+	}
+}
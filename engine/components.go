@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// StateNotCreated is the state of a component without a container.
+const StateNotCreated = "not_created"
+
+// managedComponents are the components whose lifecycle is handled by the
+// Engine
+var managedComponents = []*components.Component{gitbase, gitbaseWeb, bblfshd, bblfshWeb, search, analytics}
+
+// ComponentStatus is the state of the container of a component.
+type ComponentStatus struct {
+	// Name is the container name.
+	Name string
+	// Image is the image with the version set in the config.
+	Image string
+	// State is the docker state of the container, such as running or
+	// exited, or StateNotCreated.
+	State string
+	// Ports are the public port bindings of the container.
+	Ports []int
+}
+
+// Running returns whether the container of the component is running.
+func (s *ComponentStatus) Running() bool {
+	return s.State == "running"
+}
+
+// Components returns the status of all the components managed by the Engine,
+// including the gitbase shards set in the config.
+func (e *Engine) Components(ctx context.Context) ([]*ComponentStatus, error) {
+	var res []*ComponentStatus
+	for _, c := range e.managed() {
+		st, err := e.Status(ctx, c.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, st)
+	}
+
+	return res, nil
+}
+
+// Status returns the status of the component with the given container name.
+func (e *Engine) Status(ctx context.Context, name string) (*ComponentStatus, error) {
+	var c *components.Component
+	for _, m := range e.managed() {
+		if m.Name == name {
+			c = m
+			break
+		}
+	}
+
+	if c == nil {
+		return nil, fmt.Errorf("unknown component %s", name)
+	}
+
+	cmp := e.component(c)
+	st := &ComponentStatus{
+		Name:  c.Name,
+		Image: cmp.ImageWithVersion(),
+		State: StateNotCreated,
+		Ports: []int{},
+	}
+
+	info, err := docker.Info(c.Name)
+	if err == docker.ErrNotFound {
+		return st, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get info of %s", c.Name)
+	}
+
+	st.State = info.State
+	for _, p := range info.Ports {
+		if p.PublicPort != 0 {
+			st.Ports = append(st.Ports, int(p.PublicPort))
+		}
+	}
+
+	return st, nil
+}
+
+// Restart stops the component with the given container name and starts it
+// again, see StartAtPort. Its container is recreated with the current
+// configuration, keeping its volumes, and also its anonymous ones unless hard
+// is true. A running component keeps its public port, any other one uses the
+// port set in the config. It returns the public port.
+func (e *Engine) Restart(ctx context.Context, name string, hard bool) (int, error) {
+	st, err := e.Status(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	port := 0
+	if st.Running() && len(st.Ports) > 0 {
+		port = st.Ports[0]
+	}
+
+	if st.State != StateNotCreated {
+		if err := e.stop(ctx, name, !hard); err != nil {
+			return 0, errors.Wrapf(err, "can't stop component %s", name)
+		}
+	}
+
+	return e.StartAtPort(ctx, name, port)
+}
+
+// Reconcile starts again the components whose containers exist but are not
+// running, like after the host or docker are restarted when they don't have a
+// restart policy, see api.Config.RestartPolicy. The running components are
+// kept, and the ones without a container, which were stopped, are not
+// started. The components that fail to start are logged.
+func (e *Engine) Reconcile(ctx context.Context) {
+	for _, c := range e.managed() {
+		st, err := e.Status(ctx, c.Name)
+		if err != nil {
+			log.Errorf(err, "could not check the state of %s", c.Name)
+			continue
+		}
+
+		// restarting containers are being started by docker
+		if st.State == StateNotCreated || st.Running() || st.State == "restarting" {
+			continue
+		}
+
+		log.Infof("starting %s again, its container is %s", c.Name, st.State)
+		if err := e.Start(ctx, c.Name); err != nil {
+			log.Errorf(err, "could not start %s again", c.Name)
+		}
+	}
+}
+
+// managed returns the managedComponents followed by the gitbase shards set in
+// the config
+func (e *Engine) managed() []*components.Component {
+	cmps := append([]*components.Component(nil), managedComponents...)
+	for i := range e.config.Components.Gitbase.Shards {
+		c := e.shardComponent(i)
+		cmps = append(cmps, &c)
+	}
+
+	return cmps
+}
+
+// managedComponent returns the managed component with the given container
+// name, or nil if there is none
+func managedComponent(name string) *components.Component {
+	for _, c := range managedComponents {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const gitbaseWebSelectLimit = 0
+
+// newComponent returns a Component that runs the container of cmp with the
+// given configuration. Its ConfigHash is the hash of the configuration, so a
+// running container created with another one is replaced
+func (e *Engine) newComponent(
+	cmp components.Component,
+	config *container.Config,
+	host *container.HostConfig,
+) *Component {
+	config.Image = cmp.ImageWithVersion()
+
+	return &Component{
+		Name: cmp.Name,
+		Start: func(ctx context.Context) error {
+			if err := e.ensureInstalled(cmp); err != nil {
+				return err
+			}
+
+			log.Infof("starting %s", cmp.Name)
+
+			// the container is created even if the request that started it
+			// is canceled
+			ctx, cancel := context.WithTimeout(context.Background(), startComponentTimeout)
+			defer cancel()
+
+			host := e.reuseVolumes(cmp.Name, host)
+			if err := docker.Start(ctx, config, host, cmp.Name); err != nil {
+				return err
+			}
+
+			e.events.publish(Event{
+				Kind:      EventComponentStarted,
+				Component: cmp.Name,
+				Image:     config.Image,
+				Message:   fmt.Sprintf("started %s", cmp.Name),
+			})
+
+			return nil
+		},
+		ConfigHash: docker.ConfigHash(config, host),
+	}
+}
+
+// reuseVolumes returns host with the anonymous volumes kept when the
+// previous container of the component was removed, see Engine.Restart. They
+// are only mounted in one container, and not if host already mounts a volume
+// at the same path. The ConfigHash doesn't change
+func (e *Engine) reuseVolumes(name string, host *container.HostConfig) *container.HostConfig {
+	e.reuseMu.Lock()
+	mounts := e.reused[name]
+	delete(e.reused, name)
+	e.reuseMu.Unlock()
+
+	if len(mounts) == 0 {
+		return host
+	}
+
+	targets := make(map[string]bool, len(host.Mounts))
+	for _, m := range host.Mounts {
+		targets[m.Target] = true
+	}
+
+	h := *host
+	h.Mounts = append([]mount.Mount(nil), host.Mounts...)
+	for _, m := range mounts {
+		if !targets[m.Target] {
+			log.Debugf("reusing volume %s at %s for %s", m.Source, m.Target, name)
+			h.Mounts = append(h.Mounts, m)
+		}
+	}
+
+	return &h
+}
+
+func gitbaseConfig(opts ...docker.ConfigOption) (*container.Config, *container.HostConfig) {
+	config := &container.Config{
+		Env: []string{
+			fmt.Sprintf("BBLFSH_ENDPOINT=%s:%d", bblfshd.Name, components.BblfshParsePort),
+		},
+	}
+	host := &container.HostConfig{}
+	docker.ApplyOptions(config, host, opts...)
+
+	return config, host
+}
+
+func bblfshdConfig(opts ...docker.ConfigOption) (*container.Config, *container.HostConfig) {
+	config := &container.Config{
+		Cmd: []string{
+			fmt.Sprintf("-ctl-address=0.0.0.0:%d", components.BblfshControlPort),
+			"-ctl-network=tcp"},
+	}
+
+	host := &container.HostConfig{Privileged: true}
+	docker.ApplyOptions(config, host, opts...)
+
+	return config, host
+}
+
+func bblfshWebConfig(opts ...docker.ConfigOption) (*container.Config, *container.HostConfig) {
+	config := &container.Config{
+		Cmd: []string{fmt.Sprintf("-bblfsh-addr=%s:%d", bblfshd.Name, components.BblfshParsePort)},
+	}
+	host := &container.HostConfig{
+		// TODO(erizocosmico): Bblfsh web tries to connect to bblfsh before
+		// we have a change to join to the network, so we have to link the two
+		// containers.
+		Links: []string{bblfshd.Name},
+	}
+	docker.ApplyOptions(config, host, opts...)
+
+	return config, host
+}
+
+// gitbaseWebConfig returns the configuration of gitbase-web, which connects
+// to gitbase with the given credentials
+func gitbaseWebConfig(user, password string, opts ...docker.ConfigOption) (*container.Config, *container.HostConfig) {
+	if password != "" {
+		user += ":" + password
+	}
+
+	config := &container.Config{
+		Env: []string{
+			fmt.Sprintf("GITBASEPG_DB_CONNECTION=%s@tcp(%s)/none?maxAllowedPacket=4194304", user, gitbase.Name),
+			fmt.Sprintf("GITBASEPG_BBLFSH_SERVER_URL=%s:%d", bblfshd.Name, components.BblfshParsePort),
+			fmt.Sprintf("GITBASEPG_PORT=%d", components.GitbaseWebPort),
+			fmt.Sprintf("GITBASEPG_SELECT_LIMIT=%d", gitbaseWebSelectLimit),
+		},
+	}
+	host := &container.HostConfig{}
+	docker.ApplyOptions(config, host, opts...)
+
+	return config, host
+}
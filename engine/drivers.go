@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	drivers "github.com/bblfsh/bblfshd/daemon/protocol"
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/tracing"
+	"google.golang.org/grpc"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// ErrDriverAlreadyInstalled is returned by InstallDriver when the language
+// already has a driver
+var ErrDriverAlreadyInstalled = errors.New("driver already installed")
+
+// DriverInfo describes a bblfsh driver installed in bblfshd.
+type DriverInfo struct {
+	Lang    string
+	Version string
+}
+
+func (e *Engine) bblfshDriverClient(ctx context.Context) (drivers.ProtocolServiceClient, *grpc.ClientConn, error) {
+	if err := e.Start(ctx, bblfshd.Name); err != nil {
+		return nil, nil, err
+	}
+
+	addr, err := e.addr(bblfshd.Name, components.BblfshControlPort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Infof("connecting to bblfsh management on %s", addr)
+	opts := append(tracing.DialOptions(), grpc.WithInsecure())
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not connect to bblfsh drivers")
+	}
+
+	return drivers.NewProtocolServiceClient(conn), conn, nil
+}
+
+// ListDrivers returns the drivers installed in bblfshd, starting it if needed.
+func (e *Engine) ListDrivers(ctx context.Context) ([]DriverInfo, error) {
+	client, conn, err := e.bblfshDriverClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	res, err := client.DriverStates(ctx, &drivers.DriverStatesRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list drivers from bblfsh")
+	}
+
+	var list []DriverInfo
+	for _, state := range res.State {
+		list = append(list, DriverInfo{
+			Lang:    state.Language,
+			Version: state.Version,
+		})
+	}
+
+	return list, nil
+}
+
+// InstallDriver installs the driver of the language in bblfshd, starting it if
+// needed. If image is empty, the one pinned in the config is used, or else the
+// latest bblfsh/<lang>-driver image. It returns ErrDriverAlreadyInstalled if
+// the language already has a driver.
+func (e *Engine) InstallDriver(ctx context.Context, lang, image string) error {
+	if image == "" {
+		image = e.driverImage(lang)
+	}
+
+	client, conn, err := e.bblfshDriverClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := client.InstallDriver(ctx, &drivers.InstallDriverRequest{
+		Language:       lang,
+		ImageReference: "docker://" + image,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not install driver %s", image)
+	}
+
+	if len(res.Errors) > 0 {
+		if strings.Contains(res.Errors[0], "already installed") {
+			return ErrDriverAlreadyInstalled
+		}
+
+		return errors.Errorf("could not install driver %s: %s",
+			image, strings.Join(res.Errors, ", "))
+	}
+
+	return nil
+}
+
+// driverImage returns the image of the driver of the language pinned in the
+// config, or the latest one
+func (e *Engine) driverImage(lang string) string {
+	if image, ok := e.config.DriverImage(lang); ok {
+		return image
+	}
+
+	return fmt.Sprintf("bblfsh/%s-driver:latest", lang)
+}
+
+// SyncDrivers makes the drivers of bblfshd match the ones pinned in the
+// config, installing the missing ones and replacing the ones installed from
+// other images. The drivers of the languages not pinned are left as they
+// are. It does nothing, without starting bblfshd, if there are no pins.
+func (e *Engine) SyncDrivers(ctx context.Context) error {
+	if len(e.config.Drivers) == 0 {
+		return nil
+	}
+
+	client, conn, err := e.bblfshDriverClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := client.DriverStates(ctx, &drivers.DriverStatesRequest{})
+	if err != nil {
+		return errors.Wrap(err, "could not list drivers from bblfsh")
+	}
+
+	installed := make(map[string]string, len(res.State))
+	for _, state := range res.State {
+		installed[state.Language] = strings.TrimPrefix(state.Reference, "docker://")
+	}
+
+	var langs []string
+	for lang := range e.config.Drivers {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	for _, lang := range langs {
+		image, ok := e.config.DriverImage(lang)
+		if !ok {
+			continue
+		}
+
+		current, isInstalled := installed[lang]
+		if current == image {
+			continue
+		}
+
+		if isInstalled {
+			log.Infof("replacing driver %s with %s", current, image)
+			res, err := client.RemoveDriver(ctx, &drivers.RemoveDriverRequest{Language: lang})
+			if err != nil {
+				return errors.Wrapf(err, "could not remove driver %s", current)
+			}
+
+			if len(res.Errors) > 0 {
+				return errors.Errorf("could not remove driver %s: %s",
+					current, strings.Join(res.Errors, ", "))
+			}
+		} else {
+			log.Infof("installing driver %s", image)
+		}
+
+		res, err := client.InstallDriver(ctx, &drivers.InstallDriverRequest{
+			Language:       lang,
+			ImageReference: "docker://" + image,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not install driver %s", image)
+		}
+
+		if len(res.Errors) > 0 {
+			return errors.Errorf("could not install driver %s: %s",
+				image, strings.Join(res.Errors, ", "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,700 @@
+// Package engine implements the orchestration of the source{d} Engine
+// components, so it can be embedded in other Go programs. It is used by the
+// srcd daemon, and has no dependencies on the srcd command line interface.
+//
+// The components are docker containers connected to the same docker network,
+// and their images are pulled the first time they are needed.
+//
+//	e := engine.New(engine.Options{Workdir: "/path/to/repos"})
+//	rows, err := e.RunSQL(ctx, "SELECT repository_id FROM repositories")
+package engine
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"github.com/src-d/engine/tracing"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	startComponentTimeout = 60 * time.Second
+
+	// readyTimeout is the maximum time to wait for a started component to
+	// accept requests, and readyInterval the time between checks
+	readyTimeout  = 5 * time.Minute
+	readyInterval = 500 * time.Millisecond
+
+	gitbaseMountPath      = "/opt/repos"
+	gitbaseIndexMountPath = "/var/lib/gitbase/index"
+	bblfshdStoragePath    = "/var/lib/bblfshd"
+)
+
+// the components are referenced, as their names depend on the profile set
+// after the package is initialized
+var (
+	gitbase    = &components.Gitbase
+	gitbaseWeb = &components.GitbaseWeb
+	bblfshd    = &components.Bblfshd
+	bblfshWeb  = &components.BblfshWeb
+)
+
+// Options configures an Engine.
+type Options struct {
+	// Workdir is the host directory with the repositories analyzed by gitbase.
+	Workdir string
+	// HostOS is the operating system of the docker host. If empty, the
+	// current one is used.
+	HostOS string
+	// Config holds the public ports and image versions of the components.
+	// Default values are used for any field that is not set.
+	Config api.Config
+	// InNetwork must be true when the program runs inside a container
+	// connected to the engine docker network, as the srcd daemon does. The
+	// components are then reached using their container name, instead of
+	// their ports published in the host.
+	InNetwork bool
+	// UASTCacheDir is the directory where the parsed UASTs are cached, see
+	// ParseUAST. The UASTs are not cached if it is empty.
+	UASTCacheDir string
+}
+
+// Engine manages the lifecycle of the components and gives access to them.
+type Engine struct {
+	workdir     string
+	hostOS      string
+	workdirHash string
+	config      api.Config
+	inNetwork   bool
+	uastCache   *uastCache
+	events      *eventBus
+	// starter is shared by all the calls to StartAtPort, so the ones made
+	// at the same time start each component once
+	starter *starter
+
+	memOnce sync.Once
+	memory  int64
+
+	// stopping has the time each component started being stopped with Stop,
+	// see WatchExits
+	stopMu   sync.Mutex
+	stopping map[string]time.Time
+
+	// reused are the anonymous volumes of the containers removed by Restart,
+	// mounted in the next container of each component
+	reuseMu sync.Mutex
+	reused  map[string][]mount.Mount
+
+	// dbs are the connection pools to gitbase and its shards, by container
+	// name
+	mu  sync.Mutex
+	dbs map[string]*sql.DB
+}
+
+// New returns a new Engine with the given options.
+func New(opts Options) *Engine {
+	hostOS := opts.HostOS
+	if hostOS == "" {
+		hostOS = runtime.GOOS
+	}
+
+	config := opts.Config
+	config.SetDefaults()
+
+	h := sha1.Sum([]byte(opts.Workdir))
+	return &Engine{
+		workdir:     opts.Workdir,
+		hostOS:      hostOS,
+		workdirHash: hex.EncodeToString(h[:]),
+		config:      config,
+		inNetwork:   opts.InNetwork,
+		uastCache:   newUASTCache(opts.UASTCacheDir),
+		events:      newEventBus(),
+		starter:     newStarter(),
+		stopping:    make(map[string]time.Time),
+		reused:      make(map[string][]mount.Mount),
+		dbs:         make(map[string]*sql.DB),
+	}
+}
+
+// Close releases the connections kept by the Engine. The components are not
+// stopped.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var first error
+	for name, db := range e.dbs {
+		if err := db.Close(); err != nil && first == nil {
+			first = err
+		}
+
+		delete(e.dbs, name)
+	}
+
+	return first
+}
+
+// Workdir returns the directory with the repositories analyzed by gitbase.
+func (e *Engine) Workdir() string {
+	return e.workdir
+}
+
+// Component to be run.
+type Component struct {
+	Name         string
+	Start        docker.StartFunc
+	Dependencies []Component
+	// ConfigHash identifies the configuration of the container, see
+	// docker.ConfigHash. A running container created with another one is
+	// replaced.
+	ConfigHash string
+	// Ready returns an error until the component accepts requests. It is
+	// optional.
+	Ready func(context.Context) error
+}
+
+// Run the given components if they're not already running, after their
+// dependencies, and wait until they are ready. The components that don't
+// depend on each other are started concurrently.
+func Run(ctx context.Context, cs ...Component) error {
+	return newStarter().runAll(ctx, cs)
+}
+
+// starter starts each component once at a time, even if several others that
+// are being started depend on it
+type starter struct {
+	mu sync.Mutex
+	// results are the components being started, by name
+	results map[string]*startResult
+}
+
+func newStarter() *starter {
+	return &starter{results: make(map[string]*startResult)}
+}
+
+// startResult is the error starting a component, set when done is closed
+type startResult struct {
+	done chan struct{}
+	err  error
+}
+
+// runAll runs the components concurrently, and returns the first error
+func (s *starter) runAll(ctx context.Context, cs []Component) error {
+	errs := make(chan error, len(cs))
+	for _, c := range cs {
+		go func(c Component) {
+			errs <- s.run(ctx, c)
+		}(c)
+	}
+
+	var first error
+	for range cs {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// run starts the component after its dependencies, or waits for the
+// result if it is already being started. Once it is started, the next call
+// checks it again
+func (s *starter) run(ctx context.Context, c Component) error {
+	s.mu.Lock()
+	r, ok := s.results[c.Name]
+	if !ok {
+		r = &startResult{done: make(chan struct{})}
+		s.results[c.Name] = r
+	}
+	s.mu.Unlock()
+
+	if ok {
+		<-r.done
+		return r.err
+	}
+
+	r.err = s.start(ctx, c)
+
+	s.mu.Lock()
+	delete(s.results, c.Name)
+	s.mu.Unlock()
+
+	close(r.done)
+	return r.err
+}
+
+func (s *starter) start(ctx context.Context, c Component) error {
+	if err := s.runAll(ctx, c.Dependencies); err != nil {
+		return err
+	}
+
+	if err := removeOutdated(c); err != nil {
+		return err
+	}
+
+	if _, err := docker.InfoOrStart(ctx, c.Name, c.Start); err != nil {
+		return err
+	}
+
+	if c.Ready == nil {
+		return nil
+	}
+
+	return waitReady(ctx, c)
+}
+
+// waitReady polls the readiness probe of the running component until it
+// succeeds, the component exits or readyTimeout is exceeded
+func waitReady(ctx context.Context, c Component) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	for {
+		err := c.Ready(ctx)
+		if err == nil {
+			return nil
+		}
+
+		log.Debugf("%s is not ready yet: %s", c.Name, err)
+
+		running, rerr := docker.IsRunning(c.Name, "")
+		if rerr == nil && !running {
+			return fmt.Errorf("%s exited before it was ready, check its logs with docker logs %s", c.Name, c.Name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "%s is not ready after %s", c.Name, readyTimeout)
+		case <-time.After(readyInterval):
+		}
+	}
+}
+
+// removeOutdated stops the container of the component if it was created with
+// another configuration, like different ports, env or image, so it is created
+// again with the current one
+func removeOutdated(c Component) error {
+	if c.ConfigHash == "" {
+		return nil
+	}
+
+	info, err := docker.Info(c.Name)
+	if err == docker.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Labels[docker.ConfigHashLabel] == c.ConfigHash {
+		return nil
+	}
+
+	log.Infof("recreating %s, its configuration changed", c.Name)
+	return docker.StopContainer(c.Name, stopTimeout(c.Name))
+}
+
+// Start starts the component with the given container name, and all its
+// dependencies, using the public port set in the config.
+func (e *Engine) Start(ctx context.Context, name string) error {
+	_, err := e.StartAtPort(ctx, name, 0)
+	return err
+}
+
+// StartAtPort starts the component with the given container name, and all its
+// dependencies, with the given public port binding. It returns the public port.
+// If port is 0, the one set in the config will be used.
+// If port is -1, the public port will be the same as the private one.
+func (e *Engine) StartAtPort(ctx context.Context, name string, port int) (int, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "start "+name)
+	defer span.Finish()
+
+	c, err := e.runnable(name, port)
+	if err != nil {
+		return 0, err
+	}
+
+	return e.publicPort(name, port), e.starter.runAll(ctx, []Component{*c})
+}
+
+// runnable returns the Component that runs the component with the given
+// container name, with the given public port, see StartAtPort, and its
+// dependencies, see components.Dependencies
+func (e *Engine) runnable(name string, port int) (*Component, error) {
+	var c *Component
+	var err error
+	switch name {
+	case gitbaseWeb.Name:
+		c = e.gitbaseWebComponent(port)
+	case bblfshWeb.Name:
+		c = e.bblfshWebComponent(port)
+	case bblfshd.Name:
+		c, err = e.bblfshComponent(port)
+	case gitbase.Name:
+		c, err = e.gitbaseComponent(port)
+	case search.Name:
+		c, err = e.searchComponent(port)
+	case analytics.Name:
+		c, err = e.analyticsComponent(port)
+	default:
+		i, ok := components.GitbaseShardIndex(name)
+		if !ok {
+			return nil, fmt.Errorf("can't start unknown component %s", name)
+		}
+
+		c, err = e.gitbaseShardComponent(i)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't start component %s", name)
+	}
+
+	for _, dep := range components.Dependencies(name) {
+		d, err := e.runnable(dep.Name, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Dependencies = append(c.Dependencies, *d)
+	}
+
+	c.Ready = e.readinessProbe(name)
+	return c, nil
+}
+
+// Stop gracefully stops and removes the container of the component with the
+// given name. The component is sent a SIGTERM and killed if it doesn't exit
+// before its grace period, see components.Component.StopTimeout.
+func (e *Engine) Stop(ctx context.Context, name string) error {
+	return e.stop(ctx, name, false)
+}
+
+// stop stops the component like Stop. With keepVolumes the anonymous volumes
+// of its container are kept, and mounted in the next one, see reuseVolumes
+func (e *Engine) stop(ctx context.Context, name string, keepVolumes bool) error {
+	var mounts []mount.Mount
+	if keepVolumes {
+		var err error
+		mounts, err = docker.AnonymousVolumes(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	e.markStopping(name)
+	stopContainer := docker.StopContainer
+	if keepVolumes {
+		stopContainer = docker.StopContainerKeepingVolumes
+	}
+
+	if err := stopContainer(name, stopTimeout(name)); err != nil {
+		return err
+	}
+
+	e.reuseMu.Lock()
+	e.reused[name] = mounts
+	e.reuseMu.Unlock()
+
+	e.events.publish(Event{
+		Kind:      EventComponentStopped,
+		Component: name,
+		Message:   fmt.Sprintf("stopped %s", name),
+	})
+
+	return nil
+}
+
+// stopTimeout returns the grace period of the component with the given name.
+// The gitbase shards have the one of gitbase
+func stopTimeout(name string) time.Duration {
+	if _, ok := components.GitbaseShardIndex(name); ok {
+		name = gitbase.Name
+	}
+
+	if c := managedComponent(name); c != nil && c.StopTimeout > 0 {
+		return c.StopTimeout
+	}
+
+	return components.DefaultStopTimeout
+}
+
+func (e *Engine) publicPort(name string, requestedPort int) int {
+	var defaultPort, privatePort int
+
+	switch name {
+	case gitbaseWeb.Name:
+		defaultPort = e.config.Components.GitbaseWeb.Port
+		privatePort = components.GitbaseWebPort
+	case bblfshWeb.Name:
+		defaultPort = e.config.Components.BblfshWeb.Port
+		privatePort = components.BblfshWebPort
+	case bblfshd.Name:
+		defaultPort = e.config.Components.Bblfshd.Port
+		privatePort = components.BblfshParsePort
+	case gitbase.Name:
+		defaultPort = e.config.Components.Gitbase.Port
+		privatePort = components.GitbasePort
+	case search.Name:
+		defaultPort = e.config.Components.Search.Port
+		privatePort = components.SearchPort
+	case analytics.Name:
+		defaultPort = e.config.Components.Analytics.Port
+		privatePort = components.AnalyticsPort
+	}
+
+	switch requestedPort {
+	case 0:
+		return defaultPort
+	case -1:
+		return privatePort
+	default:
+		return requestedPort
+	}
+}
+
+// addr returns the address to connect to the given private port of a running
+// component
+func (e *Engine) addr(name string, privatePort int) (string, error) {
+	if e.inNetwork {
+		return fmt.Sprintf("%s:%d", name, privatePort), nil
+	}
+
+	info, err := docker.Info(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get info of %s", name)
+	}
+
+	for _, p := range info.Ports {
+		if int(p.PrivatePort) == privatePort && p.PublicPort != 0 {
+			return net.JoinHostPort("127.0.0.1", strconv.Itoa(int(p.PublicPort))), nil
+		}
+	}
+
+	return "", fmt.Errorf("port %d of %s is not published", privatePort, name)
+}
+
+func (e *Engine) gitbaseComponent(port int) (*Component, error) {
+	port = e.publicPort(gitbase.Name, port)
+
+	indexVolumeName := e.gitbaseIndexVolumeName()
+	if err := docker.CreateVolume(context.TODO(), indexVolumeName); err != nil {
+		return nil, errors.Wrapf(err, "can't create volume for gitbase index")
+	}
+
+	workdirHostPath, err := docker.HostPath(e.workdir, e.hostOS)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't process host path for workdir %s", e.workdir)
+	}
+
+	opts := []docker.ConfigOption{
+		docker.WithROSharedDirectory(workdirHostPath, gitbaseMountPath, e.hostOS),
+		docker.WithVolume(indexVolumeName, gitbaseIndexMountPath, e.hostOS),
+	}
+
+	// the setting is validated when the config is read
+	if ip, ok, _ := e.config.GitbasePublishIP(); ok {
+		opts = append(opts, docker.WithPortOn(ip, port, components.GitbasePort))
+	}
+
+	opts = append(opts, e.gitbaseCredentialOptions()...)
+	opts = append(opts, e.gitbaseFormatOptions()...)
+	config, host := gitbaseConfig(e.overrides(gitbase.Name, opts...)...)
+
+	return e.newComponent(e.component(gitbase), config, host), nil
+}
+
+// gitbaseFormatOptions returns the options to set the format of the
+// repositories of the workdir, before the extra arguments of the config
+func (e *Engine) gitbaseFormatOptions() []docker.ConfigOption {
+	// the format is validated when the config is read
+	args, _ := e.config.GitbaseFormatArgs()
+	if len(args) == 0 {
+		return nil
+	}
+
+	return []docker.ConfigOption{docker.WithCmd(args...)}
+}
+
+// gitbaseCredentialOptions returns the options to set the credentials of
+// gitbase from the config. The default root user without password is left to
+// the image, so the existing containers are not recreated
+func (e *Engine) gitbaseCredentialOptions() []docker.ConfigOption {
+	if e.config.Components.Gitbase.User == "" && e.config.Components.Gitbase.Password == "" {
+		return nil
+	}
+
+	user, password := e.config.GitbaseCredentials()
+	return []docker.ConfigOption{
+		docker.WithEnv("GITBASE_USER", user),
+		docker.WithEnv("GITBASE_PASSWORD", password),
+	}
+}
+
+func (e *Engine) gitbaseWebComponent(port int) *Component {
+	user, password := e.config.GitbaseCredentials()
+	config, host := gitbaseWebConfig(user, password, e.overrides(gitbaseWeb.Name,
+		docker.WithPort(e.publicPort(gitbaseWeb.Name, port), components.GitbaseWebPort))...)
+
+	return e.newComponent(e.component(gitbaseWeb), config, host)
+}
+
+func (e *Engine) bblfshWebComponent(port int) *Component {
+	config, host := bblfshWebConfig(e.overrides(bblfshWeb.Name,
+		docker.WithPort(e.publicPort(bblfshWeb.Name, port), components.BblfshWebPort))...)
+
+	return e.newComponent(e.component(bblfshWeb), config, host)
+}
+
+func (e *Engine) bblfshComponent(port int) (*Component, error) {
+	port = e.publicPort(bblfshd.Name, port)
+
+	driversVolumeName := e.bblfshdDriversVolumeName()
+	if err := docker.CreateVolume(context.TODO(), driversVolumeName); err != nil {
+		return nil, errors.Wrapf(err, "can't create volume for bblfshd drivers")
+	}
+
+	config, host := bblfshdConfig(e.overrides(bblfshd.Name,
+		docker.WithVolume(driversVolumeName, bblfshdStoragePath, e.hostOS),
+		docker.WithPort(port, components.BblfshParsePort),
+	)...)
+
+	return e.newComponent(e.component(bblfshd), config, host), nil
+}
+
+// component returns the given component with the image version set in the
+// config, if any
+func (e *Engine) component(c *components.Component) components.Component {
+	cmp := *c
+	if v := e.config.ComponentVersion(cmp.Name); v != "" {
+		cmp.Version = v
+	}
+
+	return cmp
+}
+
+// overrides returns the given options followed by the ones setting the user,
+// the security options, the performance profile, the restart policy, the
+// proxy and the extra environment variables and arguments set in the config
+// for the component, so they take precedence
+func (e *Engine) overrides(name string, opts ...docker.ConfigOption) []docker.ConfigOption {
+	// bblfshd needs root and privileges to run the drivers
+	if name != bblfshd.Name {
+		opts = append(opts, e.userOptions()...)
+		opts = append(opts, e.securityOptions(name)...)
+	}
+
+	opts = append(opts, e.performanceOptions(name)...)
+
+	// the policy is validated when the config is read
+	if policy, err := e.config.RestartPolicy(); err == nil && policy.Name != "" {
+		opts = append(opts, docker.WithRestartPolicy(policy))
+	}
+
+	if proxy := docker.ProxyFromEnvironment(); e.config.InjectsProxy() && !proxy.IsEmpty() {
+		opts = append(opts, docker.WithProxy(proxy, components.NoProxyHosts()...))
+	}
+
+	env, args := e.config.ComponentOverrides(name)
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		opts = append(opts, docker.WithEnv(k, env[k]))
+	}
+
+	if len(args) > 0 {
+		opts = append(opts, docker.WithCmd(args...))
+	}
+
+	return opts
+}
+
+// userOptions returns the options to run a container as the user and with
+// the supplementary groups set in the config, if any
+func (e *Engine) userOptions() []docker.ConfigOption {
+	var opts []docker.ConfigOption
+	if user := e.config.Security.User; user != "" {
+		opts = append(opts, docker.WithUser(user))
+	}
+
+	if groups := e.config.Security.GroupAdd; len(groups) > 0 {
+		opts = append(opts, docker.WithGroupAdd(groups...))
+	}
+
+	return opts
+}
+
+// readOnlyComponents are the components whose containers work with a
+// read-only root filesystem by default, as they don't write any file
+var readOnlyComponents = []*components.Component{gitbaseWeb, bblfshWeb}
+
+// securityOptions returns the options to harden the container of the
+// component with the given name, according to the config
+func (e *Engine) securityOptions(name string) []docker.ConfigOption {
+	sec := e.config.Security
+
+	var opts []docker.ConfigOption
+	if e.config.IsHardened() {
+		opts = append(opts,
+			docker.WithCapDrop("ALL"),
+			docker.WithSecurityOpt("no-new-privileges"),
+		)
+	}
+
+	readOnly := false
+	if sec.ReadOnlyRootFS != nil {
+		readOnly = *sec.ReadOnlyRootFS
+	} else if e.config.IsHardened() {
+		for _, c := range readOnlyComponents {
+			readOnly = readOnly || c.Name == name
+		}
+	}
+
+	if readOnly {
+		opts = append(opts, docker.WithReadOnlyRootFS())
+	}
+
+	if len(sec.CapDrop) > 0 {
+		opts = append(opts, docker.WithCapDrop(sec.CapDrop...))
+	}
+
+	if len(sec.SecurityOpt) > 0 {
+		opts = append(opts, docker.WithSecurityOpt(sec.SecurityOpt...))
+	}
+
+	return opts
+}
+
+func (e *Engine) gitbaseIndexVolumeName() string {
+	return components.GitbaseIndexVolumePrefix() + e.workdirHash
+}
+
+func (e *Engine) searchVolumeName() string {
+	return fmt.Sprintf("%s-%s", search.Name, e.workdirHash)
+}
+
+// bblfshdDriversVolumeName depends on the bblfshd version, so the drivers
+// bundled in a new image are not hidden by the ones installed by an old one
+func (e *Engine) bblfshdDriversVolumeName() string {
+	return fmt.Sprintf("%s-%s", bblfshd.Name, e.component(bblfshd).Version)
+}
@@ -0,0 +1,345 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/src-d/engine/api"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicPort(t *testing.T) {
+	assert := assert.New(t)
+
+	var config api.Config
+	config.Components.Gitbase.Port = 3307
+	e := New(Options{Workdir: "/tmp", Config: config})
+
+	assert.Equal(3307, e.publicPort(gitbase.Name, 0))
+	assert.Equal(components.GitbasePort, e.publicPort(gitbase.Name, -1))
+	assert.Equal(4000, e.publicPort(gitbase.Name, 4000))
+	assert.Equal(components.BblfshParsePort, e.publicPort(bblfshd.Name, 0))
+}
+
+func TestAddrInNetwork(t *testing.T) {
+	assert := assert.New(t)
+
+	e := New(Options{Workdir: "/tmp", InNetwork: true})
+	addr, err := e.addr(bblfshd.Name, components.BblfshParsePort)
+	assert.NoError(err)
+	assert.Equal("srcd-cli-bblfshd:9432", addr)
+}
+
+func TestVolumes(t *testing.T) {
+	assert := assert.New(t)
+
+	a := New(Options{Workdir: "/tmp/a"}).Volumes()
+	b := New(Options{Workdir: "/tmp/b"}).Volumes()
+
+	assert.Len(a, 3)
+	kinds := make(map[string]bool)
+	for i, v := range a {
+		assert.False(kinds[v.Kind], v.Kind)
+		kinds[v.Kind] = true
+
+		assert.Equal(v.Kind, b[i].Kind)
+		assert.Contains(v.Name, "srcd-cli-")
+	}
+
+	// the indexes belong to a workdir, the drivers are shared
+	assert.NotEqual(a[0].Name, b[0].Name)
+	assert.Equal(a[2].Name, b[2].Name)
+}
+
+func TestComponentVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	e := New(Options{Workdir: "/tmp"})
+	assert.Equal(gitbase.Version, e.component(gitbase).Version)
+
+	var config api.Config
+	config.SetComponentVersion(gitbase.Name, "v0.19.1")
+	e = New(Options{Workdir: "/tmp", Config: config})
+	assert.Equal("v0.19.1", e.component(gitbase).Version)
+	assert.Equal(bblfshd.Version, e.component(bblfshd).Version)
+}
+
+func TestOverrides(t *testing.T) {
+	assert := assert.New(t)
+
+	e := New(Options{Workdir: "/tmp"})
+	opts := e.overrides(gitbase.Name, docker.WithEnv("BBLFSH_ENDPOINT", "bblfshd:9432"))
+	// the hardening options are applied by default
+	assert.Len(opts, 3)
+
+	var config api.Config
+	config.Components.Gitbase.Env = map[string]string{"GITBASE_UNSTABLE_SQUASH": "true", "BBLFSH_ENDPOINT": "other:9432"}
+	config.Components.Gitbase.Args = []string{"--index=/tmp"}
+	e = New(Options{Workdir: "/tmp", Config: config})
+
+	c := &container.Config{}
+	docker.ApplyOptions(c, &container.HostConfig{}, e.overrides(gitbase.Name, docker.WithEnv("BBLFSH_ENDPOINT", "bblfshd:9432"))...)
+	// the last value of a variable is the one used
+	assert.Equal([]string{
+		"BBLFSH_ENDPOINT=bblfshd:9432",
+		"BBLFSH_ENDPOINT=other:9432",
+		"GITBASE_UNSTABLE_SQUASH=true",
+	}, c.Env)
+	assert.Equal([]string{"--index=/tmp"}, []string(c.Cmd))
+}
+
+func TestComponentConfigHash(t *testing.T) {
+	assert := assert.New(t)
+
+	hash := func(e *Engine, port int) string {
+		config, host := bblfshdConfig(e.overrides(bblfshd.Name, docker.WithPort(port, components.BblfshParsePort))...)
+		return e.newComponent(e.component(bblfshd), config, host).ConfigHash
+	}
+
+	e := New(Options{Workdir: "/tmp"})
+	assert.Equal(hash(e, 9432), hash(e, 9432))
+	assert.NotEqual(hash(e, 9432), hash(e, 9433))
+
+	var config api.Config
+	config.Components.Bblfshd.Args = []string{"-log-level=debug"}
+	assert.NotEqual(hash(e, 9432), hash(New(Options{Workdir: "/tmp", Config: config}), 9432))
+
+	config = api.Config{}
+	config.Components.Bblfshd.Version = "v2.13.0"
+	assert.NotEqual(hash(e, 9432), hash(New(Options{Workdir: "/tmp", Config: config}), 9432))
+}
+
+func TestUserOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	apply := func(e *Engine, name string) (*container.Config, *container.HostConfig) {
+		c, h := &container.Config{}, &container.HostConfig{}
+		docker.ApplyOptions(c, h, e.overrides(name)...)
+		return c, h
+	}
+
+	e := New(Options{Workdir: "/tmp"})
+	c, h := apply(e, gitbase.Name)
+	assert.Equal("", c.User)
+	assert.Empty(h.GroupAdd)
+
+	var config api.Config
+	config.Security.User = "1000:1000"
+	config.Security.GroupAdd = []string{"docker"}
+	e = New(Options{Workdir: "/tmp", Config: config})
+
+	c, h = apply(e, gitbase.Name)
+	assert.Equal("1000:1000", c.User)
+	assert.Equal([]string{"docker"}, []string(h.GroupAdd))
+
+	// bblfshd needs root to run the drivers
+	c, h = apply(e, bblfshd.Name)
+	assert.Equal("", c.User)
+	assert.Empty(h.GroupAdd)
+}
+
+func TestRestartPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	apply := func(e *Engine, name string) *container.HostConfig {
+		h := &container.HostConfig{}
+		docker.ApplyOptions(&container.Config{}, h, e.overrides(name)...)
+		return h
+	}
+
+	e := New(Options{Workdir: "/tmp"})
+	assert.Equal(container.RestartPolicy{}, apply(e, gitbase.Name).RestartPolicy)
+
+	var config api.Config
+	config.Daemon.RestartPolicy = "unless-stopped"
+	e = New(Options{Workdir: "/tmp", Config: config})
+	assert.Equal("unless-stopped", apply(e, gitbase.Name).RestartPolicy.Name)
+	assert.Equal("unless-stopped", apply(e, bblfshd.Name).RestartPolicy.Name)
+}
+
+func TestPerformanceOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	apply := func(e *Engine, name string) (*container.Config, *container.HostConfig) {
+		c, h := &container.Config{}, &container.HostConfig{}
+		docker.ApplyOptions(c, h, e.overrides(name)...)
+		return c, h
+	}
+
+	e := New(Options{Workdir: "/tmp"})
+	c, h := apply(e, gitbase.Name)
+	assert.NotContains(c.Env, "GITBASE_CACHESIZE_MB=1024")
+	assert.Zero(h.Memory)
+
+	var config api.Config
+	config.Performance = api.PerformanceAuto
+	config.Components.Gitbase.Env = map[string]string{"GITBASE_CACHESIZE_MB": "100"}
+	e = New(Options{Workdir: "/tmp", Config: config})
+	e.memOnce.Do(func() { e.memory = 16 << 30 })
+
+	c, h = apply(e, gitbase.Name)
+	assert.Contains(c.Env, "GITBASE_CACHESIZE_MB=1024")
+	assert.Contains(c.Env, "GITBASE_UAST_CACHE_SIZE=10000")
+	// the env of the component is set last, so it takes precedence
+	assert.Equal("GITBASE_CACHESIZE_MB=100", c.Env[len(c.Env)-1])
+	assert.Equal(int64(8<<30), h.Memory)
+
+	_, h = apply(e, bblfshd.Name)
+	assert.Equal(int64(4<<30), h.Memory)
+
+	_, h = apply(e, gitbaseWeb.Name)
+	assert.Zero(h.Memory)
+}
+
+func TestProxyOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+		if old, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, old)
+		}
+		os.Unsetenv(name)
+	}
+
+	env := func(e *Engine, name string) []string {
+		c := &container.Config{}
+		docker.ApplyOptions(c, &container.HostConfig{}, e.overrides(name)...)
+		return c.Env
+	}
+
+	e := New(Options{Workdir: "/tmp"})
+	assert.Empty(env(e, gitbase.Name))
+
+	os.Setenv("HTTPS_PROXY", "http://proxy:3128")
+	assert.Contains(env(e, gitbase.Name), "HTTPS_PROXY=http://proxy:3128")
+	assert.Contains(env(e, bblfshd.Name), "https_proxy=http://proxy:3128")
+
+	var config api.Config
+	config.Components.Gitbase.Env = map[string]string{"HTTPS_PROXY": "http://other:3128"}
+	e = New(Options{Workdir: "/tmp", Config: config})
+
+	// the env of the component is set last, so it takes precedence
+	gitbaseEnv := env(e, gitbase.Name)
+	assert.Equal("HTTPS_PROXY=http://other:3128", gitbaseEnv[len(gitbaseEnv)-1])
+
+	inject := false
+	config.Proxy.Inject = &inject
+	e = New(Options{Workdir: "/tmp", Config: config})
+	assert.NotContains(env(e, bblfshd.Name), "HTTPS_PROXY=http://proxy:3128")
+}
+
+func TestSecurityOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	apply := func(e *Engine, name string) *container.HostConfig {
+		h := &container.HostConfig{}
+		docker.ApplyOptions(&container.Config{}, h, e.overrides(name)...)
+		return h
+	}
+
+	e := New(Options{Workdir: "/tmp"})
+	h := apply(e, gitbase.Name)
+	assert.Equal([]string{"ALL"}, []string(h.CapDrop))
+	assert.Equal([]string{"no-new-privileges"}, h.SecurityOpt)
+	assert.False(h.ReadonlyRootfs)
+	assert.True(apply(e, gitbaseWeb.Name).ReadonlyRootfs)
+
+	h = apply(e, bblfshd.Name)
+	assert.Empty(h.CapDrop)
+	assert.Empty(h.SecurityOpt)
+
+	var config api.Config
+	hardening, readOnly := false, true
+	config.Security.Hardening = &hardening
+	config.Security.ReadOnlyRootFS = &readOnly
+	config.Security.CapDrop = []string{"NET_RAW"}
+	config.Security.SecurityOpt = []string{"apparmor=engine"}
+	e = New(Options{Workdir: "/tmp", Config: config})
+
+	h = apply(e, gitbase.Name)
+	assert.Equal([]string{"NET_RAW"}, []string(h.CapDrop))
+	assert.Equal([]string{"apparmor=engine"}, h.SecurityOpt)
+	assert.True(h.ReadonlyRootfs)
+}
+
+func TestManagedComponent(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(gitbase, managedComponent(gitbase.Name))
+	assert.Equal(search, managedComponent(search.Name))
+	assert.Nil(managedComponent(components.Daemon.Name))
+
+	assert.Equal(components.DefaultStopTimeout, stopTimeout(components.Daemon.Name))
+
+	e := New(Options{Workdir: "/tmp"})
+	_, err := e.Status(context.Background(), components.Daemon.Name)
+	assert.EqualError(err, "unknown component srcd-cli-daemon")
+
+	_, err = e.Restart(context.Background(), "foo", false)
+	assert.EqualError(err, "unknown component foo")
+}
+
+func TestReuseVolumes(t *testing.T) {
+	assert := assert.New(t)
+
+	e := New(Options{Workdir: "/tmp"})
+	named := mount.Mount{Type: mount.TypeVolume, Source: "srcd-cli-bblfsh-drivers", Target: "/var/lib/bblfshd"}
+	host := &container.HostConfig{Mounts: []mount.Mount{named}}
+
+	// nothing to reuse
+	assert.Equal(host, e.reuseVolumes(bblfshd.Name, host))
+
+	anonymous := mount.Mount{Type: mount.TypeVolume, Source: strings.Repeat("ab", 32), Target: "/tmp"}
+	e.reused[bblfshd.Name] = []mount.Mount{
+		anonymous,
+		// the path is already mounted
+		{Type: mount.TypeVolume, Source: strings.Repeat("cd", 32), Target: "/var/lib/bblfshd"},
+	}
+
+	h := e.reuseVolumes(bblfshd.Name, host)
+	assert.Equal([]mount.Mount{named, anonymous}, h.Mounts)
+	assert.Equal([]mount.Mount{named}, host.Mounts)
+
+	// they are only reused once
+	assert.Equal(host, e.reuseVolumes(bblfshd.Name, host))
+}
+
+func TestGitbaseCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	config, _ := gitbaseWebConfig("root", "")
+	assert.Contains(config.Env, "GITBASEPG_DB_CONNECTION=root@tcp(srcd-cli-gitbase)/none?maxAllowedPacket=4194304")
+
+	config, _ = gitbaseWebConfig("analyst", "secret")
+	assert.Contains(config.Env, "GITBASEPG_DB_CONNECTION=analyst:secret@tcp(srcd-cli-gitbase)/none?maxAllowedPacket=4194304")
+}
+
+func TestGitbaseShards(t *testing.T) {
+	assert := assert.New(t)
+
+	var config api.Config
+	config.Components.Gitbase.Shards = []api.GitbaseShard{
+		{Repositories: []string{"engine"}},
+		{Repositories: []string{"gitbase"}},
+	}
+	e := New(Options{Workdir: "/tmp", Config: config})
+
+	managed := e.managed()
+	assert.Len(managed, len(managedComponents)+2)
+	assert.Equal("srcd-cli-gitbase-shard-0", managed[len(managedComponents)].Name)
+	assert.Equal(e.component(gitbase).Image, managed[len(managedComponents)].Image)
+	assert.Equal("srcd-cli-gitbase-shard-1", managed[len(managedComponents)+1].Name)
+	assert.Len(New(Options{Workdir: "/tmp"}).managed(), len(managedComponents))
+
+	assert.Equal(gitbase.StopTimeout, stopTimeout(components.GitbaseShardName(1)))
+	assert.Equal(e.gitbaseIndexVolumeName()+"-shard-1", e.gitbaseShardIndexVolumeName(1))
+
+	_, err := e.runnable(components.GitbaseShardName(2), 0)
+	assert.EqualError(err, "can't start component srcd-cli-gitbase-shard-2: gitbase shard 2 is not set in the config")
+}
@@ -0,0 +1,269 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// EventKind is the kind of an Event.
+type EventKind string
+
+const (
+	// EventPullStarted is sent when the image of a component starts being
+	// pulled.
+	EventPullStarted EventKind = "pull_started"
+	// EventPullFinished is sent when the image of a component was pulled.
+	EventPullFinished EventKind = "pull_finished"
+	// EventComponentStarted is sent when the container of a component is
+	// started.
+	EventComponentStarted EventKind = "component_started"
+	// EventComponentStopped is sent when the container of a component is
+	// stopped and removed.
+	EventComponentStopped EventKind = "component_stopped"
+	// EventHealthDegraded is sent when a running component becomes
+	// unhealthy, see WatchHealth, or exits without being stopped, see
+	// WatchExits.
+	EventHealthDegraded EventKind = "health_degraded"
+	// EventUpgradeAvailable is sent when there is a newer compatible version
+	// of the image of a component, see CheckUpgrades.
+	EventUpgradeAvailable EventKind = "upgrade_available"
+)
+
+// Event is something that happened to a component.
+type Event struct {
+	Kind EventKind
+	// Component is the container name of the component.
+	Component string
+	// Image is the image with version the event refers to.
+	Image string
+	// Message describes the event.
+	Message string
+	Time    time.Time
+}
+
+// eventBufferSize is the number of events kept for a subscriber that does
+// not read them. Newer events are dropped
+const eventBufferSize = 64
+
+// eventBus sends the events to all the subscribers, without blocking when
+// they are slow
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+	// upgrades are the last EventUpgradeAvailable of each component, sent to
+	// new subscribers too, as they are only checked once in a while
+	upgrades map[string]Event
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subs:     make(map[chan Event]struct{}),
+		upgrades: make(map[string]Event),
+	}
+}
+
+func (b *eventBus) publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ev.Kind == EventUpgradeAvailable {
+		b.upgrades[ev.Component] = ev
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			log.Debugf("dropping %s event of %s, the subscriber is not reading", ev.Kind, ev.Component)
+		}
+	}
+}
+
+func (b *eventBus) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	for _, ev := range b.upgrades {
+		ch <- ev
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Subscribe returns a channel with the events that happen from now on, and
+// the function that must be called to stop receiving them. The available
+// upgrades found before are sent first. Events are dropped if the channel is
+// not read.
+func (e *Engine) Subscribe() (<-chan Event, func()) {
+	return e.events.subscribe()
+}
+
+// ensureInstalled pulls the image of the component if it is not installed,
+// sending EventPullStarted and EventPullFinished
+func (e *Engine) ensureInstalled(cmp components.Component) error {
+	installed, err := docker.IsInstalled(context.Background(), cmp.Image, cmp.Version)
+	if err != nil || installed {
+		return err
+	}
+
+	image := cmp.ImageWithVersion()
+	e.events.publish(Event{
+		Kind:      EventPullStarted,
+		Component: cmp.Name,
+		Image:     image,
+		Message:   fmt.Sprintf("installing %s", image),
+	})
+
+	if err := docker.EnsureInstalled(cmp.Image, cmp.Version); err != nil {
+		return err
+	}
+
+	e.events.publish(Event{
+		Kind:      EventPullFinished,
+		Component: cmp.Name,
+		Image:     image,
+		Message:   fmt.Sprintf("installed %s", image),
+	})
+
+	return nil
+}
+
+// health states of a component, see WatchHealth
+const (
+	healthNotCreated = ""
+	healthOK         = "ok"
+	healthUnhealthy  = "unhealthy"
+	healthExited     = "exited"
+)
+
+// WatchHealth checks the containers of the components at the given interval
+// until the context is canceled, and sends EventHealthDegraded when the
+// docker health check of a running component fails. The exits are reported
+// by WatchExits.
+func (e *Engine) WatchHealth(ctx context.Context, interval time.Duration) {
+	states := make(map[string]string)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, c := range managedComponents {
+			state, err := healthState(ctx, c.Name)
+			if err != nil {
+				log.Debugf("could not check the health of %s: %s", c.Name, err)
+				continue
+			}
+
+			if msg, ok := healthDegraded(c.Name, states[c.Name], state); ok {
+				cmp := e.component(c)
+				e.events.publish(Event{
+					Kind:      EventHealthDegraded,
+					Component: c.Name,
+					Image:     cmp.ImageWithVersion(),
+					Message:   msg,
+				})
+			}
+
+			states[c.Name] = state
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// healthState returns the health state of the container with the given name
+func healthState(ctx context.Context, name string) (string, error) {
+	in, err := docker.Inspect(ctx, name)
+	if err == docker.ErrNotFound {
+		return healthNotCreated, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if in.State != "running" {
+		return healthExited, nil
+	}
+
+	if in.Health == "unhealthy" {
+		return healthUnhealthy, nil
+	}
+
+	return healthOK, nil
+}
+
+// healthDegraded returns the message of the EventHealthDegraded sent when the
+// health state of a component changes, if any. The exits are not reported
+// here, as WatchExits explains them
+func healthDegraded(name, prev, cur string) (string, bool) {
+	if prev != healthOK || cur != healthUnhealthy {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s is unhealthy", name), true
+}
+
+// CheckUpgrades looks for newer compatible versions of the images of the
+// installed components, sending EventUpgradeAvailable for each one found.
+// The components whose versions can't be compared, like the ones using a
+// floating tag, are skipped.
+func (e *Engine) CheckUpgrades(ctx context.Context) error {
+	for _, c := range managedComponents {
+		cmp := e.component(c)
+		if _, err := semver.ParseTolerant(cmp.Version); err != nil {
+			continue
+		}
+
+		installed, err := docker.IsInstalled(ctx, cmp.Image, cmp.Version)
+		if err != nil {
+			return err
+		}
+
+		if !installed {
+			continue
+		}
+
+		tag, _, err := docker.GetCompatibleTag(cmp.Image, cmp.Version)
+		if err != nil {
+			log.Debugf("could not find a newer version of %s: %s", cmp.ImageWithVersion(), err)
+			continue
+		}
+
+		if tag == cmp.Version {
+			continue
+		}
+
+		image := fmt.Sprintf("%s:%s", cmp.Image, tag)
+		e.events.publish(Event{
+			Kind:      EventUpgradeAvailable,
+			Component: cmp.Name,
+			Image:     image,
+			Message:   fmt.Sprintf("%s is available, %s uses %s", image, cmp.Name, cmp.Version),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/src-d/engine/components"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newEventBus()
+	b.publish(Event{Kind: EventComponentStarted, Component: "a"})
+	b.publish(Event{Kind: EventUpgradeAvailable, Component: "b", Image: "b:v1"})
+	b.publish(Event{Kind: EventUpgradeAvailable, Component: "b", Image: "b:v2"})
+
+	events, unsubscribe := b.subscribe()
+
+	// only the last available upgrade is replayed
+	ev := <-events
+	assert.Equal(EventUpgradeAvailable, ev.Kind)
+	assert.Equal("b:v2", ev.Image)
+	assert.False(ev.Time.IsZero())
+
+	b.publish(Event{Kind: EventComponentStopped, Component: "a"})
+	ev = <-events
+	assert.Equal(EventComponentStopped, ev.Kind)
+
+	unsubscribe()
+	unsubscribe()
+	b.publish(Event{Kind: EventComponentStarted, Component: "a"})
+	assert.Len(events, 0)
+}
+
+func TestEventBusSlowSubscriber(t *testing.T) {
+	b := newEventBus()
+	events, unsubscribe := b.subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < eventBufferSize+10; i++ {
+		b.publish(Event{Kind: EventComponentStarted})
+	}
+
+	assert.Len(t, events, eventBufferSize)
+}
+
+func TestHealthDegraded(t *testing.T) {
+	assert := assert.New(t)
+
+	// the exits are reported by WatchExits
+	_, ok := healthDegraded("srcd-cli-gitbase", healthOK, healthExited)
+	assert.False(ok)
+
+	msg, ok := healthDegraded("srcd-cli-gitbase", healthOK, healthUnhealthy)
+	assert.True(ok)
+	assert.Equal("srcd-cli-gitbase is unhealthy", msg)
+
+	_, ok = healthDegraded("srcd-cli-gitbase", healthOK, healthNotCreated)
+	assert.False(ok)
+	_, ok = healthDegraded("srcd-cli-gitbase", healthOK, healthOK)
+	assert.False(ok)
+	_, ok = healthDegraded("srcd-cli-gitbase", healthNotCreated, healthExited)
+	assert.False(ok)
+	_, ok = healthDegraded("srcd-cli-gitbase", healthUnhealthy, healthExited)
+	assert.False(ok)
+}
+
+func TestStoppedAt(t *testing.T) {
+	assert := assert.New(t)
+
+	e := New(Options{Workdir: "/tmp"})
+	name := components.Gitbase.Name
+	assert.False(e.stoppedAt(name, time.Now()))
+
+	e.markStopping(name)
+	now := time.Now()
+	assert.True(e.stoppedAt(name, now))
+	assert.False(e.stoppedAt(name, now.Add(-time.Hour)))
+	assert.False(e.stoppedAt(name, now.Add(time.Hour)))
+	assert.False(e.stoppedAt(components.Bblfshd.Name, now))
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// stopMargin is how long after the grace period of a component stopped with
+// Stop its exit is still considered on purpose
+const stopMargin = time.Minute
+
+// WatchExits sends EventHealthDegraded with the reason, see
+// components.ExitReason, when the container of a component dies without
+// being stopped, until the context is canceled. If the docker events can't
+// be read, it tries again after the given interval.
+func (e *Engine) WatchExits(ctx context.Context, interval time.Duration) {
+	for {
+		err := docker.WatchDies(ctx, e.exited)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Errorf(err, "could not watch the exits of the components")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// exited publishes the reason why a component exited, unless it was stopped
+// on purpose
+func (e *Engine) exited(st docker.ExitState) {
+	c := managedComponent(st.Name)
+	if c == nil || e.stoppedAt(st.Name, st.FinishedAt) {
+		return
+	}
+
+	msg, ok := components.ExitReason(st)
+	if !ok {
+		return
+	}
+
+	log.Warningf("%s", msg)
+	cmp := e.component(c)
+	e.events.publish(Event{
+		Kind:      EventHealthDegraded,
+		Component: st.Name,
+		Image:     cmp.ImageWithVersion(),
+		Message:   msg,
+	})
+}
+
+// markStopping records that the component with the given name is being
+// stopped with Stop, so its exit is not reported
+func (e *Engine) markStopping(name string) {
+	e.stopMu.Lock()
+	defer e.stopMu.Unlock()
+
+	e.stopping[name] = time.Now()
+}
+
+// stoppedAt returns whether the component with the given name exiting at t
+// was stopped with Stop
+func (e *Engine) stoppedAt(name string, t time.Time) bool {
+	e.stopMu.Lock()
+	defer e.stopMu.Unlock()
+
+	start, ok := e.stopping[name]
+	if !ok {
+		return false
+	}
+
+	return !t.Before(start) && t.Sub(start) < stopTimeout(name)+stopMargin
+}
+
+// ExitError returns err with the reason why the component with the given
+// container name exited, if it died after since, see components.ExitReason.
+// It is used for the errors of the requests made to a component, which
+// would be connection errors otherwise.
+func (e *Engine) ExitError(name string, since time.Time, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ierr := docker.InspectExit(context.Background(), name)
+	if ierr != nil || st.FinishedAt.Before(since) || e.stoppedAt(name, st.FinishedAt) {
+		return err
+	}
+
+	msg, ok := components.ExitReason(*st)
+	if !ok {
+		return err
+	}
+
+	return errors.Wrap(err, msg)
+}
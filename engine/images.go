@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/src-d/engine/components"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// CollectImages removes the old images of the daemon and the components,
+// keeping the number of versions of each one set by images.keep in the
+// config, the one in use included, see components.OldImages. It does nothing
+// if images.keep is 0.
+func (e *Engine) CollectImages(ctx context.Context) error {
+	keep := e.config.Images.Keep
+	if keep <= 0 {
+		return nil
+	}
+
+	cmps := []components.Component{components.Daemon}
+	for _, c := range managedComponents {
+		cmps = append(cmps, e.component(c))
+	}
+
+	imgs, err := components.OldImages(ctx, cmps, nil, keep-1)
+	if err != nil || len(imgs) == 0 {
+		return err
+	}
+
+	freed, err := components.RemoveImages(ctx, imgs)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("removed %d old images, reclaimed %s", len(imgs), units.HumanSize(float64(freed)))
+	return nil
+}
+
+// WatchImages runs CollectImages right away and then after each interval,
+// until the context is canceled.
+func (e *Engine) WatchImages(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.CollectImages(ctx); err != nil {
+			log.Errorf(err, "could not remove the old images")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
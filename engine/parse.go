@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	bblfsh "github.com/bblfsh/go-client/v4"
+	"github.com/bblfsh/go-client/v4/tools"
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/components"
+	"gopkg.in/bblfsh/sdk.v2/uast/nodes"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+// ParseRequest holds the parameters of ParseUAST.
+type ParseRequest struct {
+	// Name of the file, used to detect the language if Lang is empty.
+	Name    string
+	Content []byte
+	// Lang skips the language detection, using the given parser.
+	Lang string
+	// Query is an optional XPath query applied to the parsed UAST.
+	Query string
+	// Mode is the UAST transformation level. If zero, the bblfsh default is
+	// used.
+	Mode bblfsh.Mode
+	// Logf, if set, receives the progress messages.
+	Logf func(format string, args ...interface{})
+}
+
+// ParseResponse is the result of ParseUAST.
+type ParseResponse struct {
+	Lang string
+	// Nodes holds the UAST root node, or the nodes matching the query.
+	Nodes []nodes.Node
+}
+
+// DetectLanguage returns the lowercase name of the language of the file with
+// the given name and content.
+func DetectLanguage(name string, content []byte) string {
+	return strings.ToLower(enry.GetLanguage(name, content))
+}
+
+// ParseResult is the result of one of the files of ParseUASTBatch.
+type ParseResult struct {
+	Response *ParseResponse
+	Err      error
+}
+
+// ParseUAST parses the file with bblfshd, starting it if needed. If bblfshd
+// dies while parsing it, the error explains why.
+func (e *Engine) ParseUAST(ctx context.Context, req ParseRequest) (*ParseResponse, error) {
+	p, err := e.newParser(ctx, req.Logf)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	start := time.Now()
+	res, err := p.parse(ctx, req)
+	if err != nil {
+		return nil, e.ExitError(bblfshd.Name, start, err)
+	}
+
+	return res, nil
+}
+
+// ParseUASTBatch parses the files with bblfshd, starting it if needed, using
+// the same connection for all of them. A file that can't be parsed doesn't
+// stop the rest, its error is returned in its result. The Logf of the first
+// request is used for the connection messages.
+func (e *Engine) ParseUASTBatch(ctx context.Context, reqs []ParseRequest) ([]ParseResult, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	p, err := e.newParser(ctx, reqs[0].Logf)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	results := make([]ParseResult, len(reqs))
+	for i, req := range reqs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		res, err := p.parse(ctx, req)
+		results[i] = ParseResult{Response: res, Err: err}
+	}
+
+	return results, nil
+}
+
+// parser parses files with a bblfsh client, reusing the cached UASTs
+type parser struct {
+	client *bblfsh.Client
+	cache  *uastCache
+	// driverVersions are the versions of the installed drivers, by language,
+	// used in the cache keys. The UASTs of the languages without a known
+	// version are not cached
+	driverVersions map[string]string
+}
+
+func (e *Engine) newParser(ctx context.Context, logf func(string, ...interface{})) (*parser, error) {
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+
+	if err := e.Start(ctx, bblfshd.Name); err != nil {
+		return nil, err
+	}
+
+	addr, err := e.addr(bblfshd.Name, components.BblfshParsePort)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{cache: e.uastCache}
+	if p.cache != nil {
+		drivers, err := e.ListDrivers(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		p.driverVersions = make(map[string]string, len(drivers))
+		for _, d := range drivers {
+			p.driverVersions[d.Lang] = d.Version
+		}
+	}
+
+	logf("connecting to bblfsh parsing on %s", addr)
+	p.client, err = bblfsh.NewClient(addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to bblfsh")
+	}
+
+	return p, nil
+}
+
+// Close closes the connection to bblfshd.
+func (p *parser) Close() error {
+	return p.client.Close()
+}
+
+func (p *parser) parse(ctx context.Context, req ParseRequest) (*ParseResponse, error) {
+	lang := strings.ToLower(req.Lang)
+	if lang == "" {
+		lang = DetectLanguage(req.Name, req.Content)
+	}
+
+	root, err := p.uast(ctx, req, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var ns = []nodes.Node{root}
+	if req.Query != "" {
+		var filtered nodes.Array
+		iter, err := tools.Filter(root, req.Query)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not apply query %s", req.Query)
+		}
+		for iter.Next() {
+			filtered = append(filtered, iter.Node().(nodes.Node))
+		}
+		ns = filtered
+	}
+
+	return &ParseResponse{Lang: lang, Nodes: ns}, nil
+}
+
+// uast returns the UAST of the file, from the cache if it was parsed before
+// with the same driver version
+func (p *parser) uast(ctx context.Context, req ParseRequest, lang string) (nodes.Node, error) {
+	var key string
+	if version, ok := p.driverVersions[lang]; ok && version != "" {
+		key = uastCacheKey(req.Content, lang, version, req.Mode.String())
+		if n, ok := p.cache.Get(key); ok {
+			return n, nil
+		}
+	}
+
+	res, _, err := p.client.NewParseRequest().
+		Language(lang).
+		Content(string(req.Content)).
+		Filename(req.Name).
+		Context(ctx).
+		Mode(req.Mode).
+		UAST()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse")
+	}
+
+	if key != "" {
+		p.cache.Put(key, res)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/src-d/engine/docker"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// hostMemory returns the memory of the docker host in bytes, or 0 if it
+// can't be read. It is read once
+func (e *Engine) hostMemory() int64 {
+	e.memOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		info, _, err := docker.SystemInfo(ctx)
+		if err != nil {
+			log.Warningf("could not read the memory of the docker host, "+
+				"the components will run without memory limits: %s", err)
+			return
+		}
+
+		e.memory = info.MemTotal
+	})
+
+	return e.memory
+}
+
+// performanceOptions returns the options that apply the performance profile
+// set in the config to the component with the given name, if any
+func (e *Engine) performanceOptions(name string) []docker.ConfigOption {
+	if e.config.Performance == "" {
+		return nil
+	}
+
+	memory := e.hostMemory()
+
+	// the profile is validated when the config is read
+	p, ok, err := e.config.PerformanceProfile(memory)
+	if err != nil || !ok {
+		return nil
+	}
+
+	switch name {
+	case gitbase.Name:
+		return append([]docker.ConfigOption{
+			docker.WithEnv("GITBASE_CACHESIZE_MB", strconv.Itoa(p.GitbaseCacheMB)),
+			docker.WithEnv("GITBASE_UAST_CACHE_SIZE", strconv.Itoa(p.GitbaseUASTCache)),
+		}, memoryLimit(p.GitbaseMemory, memory)...)
+	case bblfshd.Name:
+		return memoryLimit(p.BblfshdMemory, memory)
+	default:
+		return nil
+	}
+}
+
+// memoryLimit returns the option to limit the memory of a container to the
+// given fraction of the memory of the docker host, if it is known
+func memoryLimit(fraction float64, hostMemory int64) []docker.ConfigOption {
+	if hostMemory <= 0 {
+		return nil
+	}
+
+	return []docker.ConfigOption{docker.WithMemoryLimit(int64(fraction * float64(hostMemory)))}
+}
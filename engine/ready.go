@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/src-d/engine/components"
+)
+
+// probeTimeout is the maximum time a single readiness check can take
+const probeTimeout = 2 * time.Second
+
+// readinessProbe returns the function that checks whether the component with
+// the given container name accepts requests, see Component.Ready
+func (e *Engine) readinessProbe(name string) func(context.Context) error {
+	switch name {
+	case gitbase.Name:
+		return e.gitbaseReady
+	case bblfshd.Name:
+		return e.tcpReady(name, components.BblfshParsePort)
+	case gitbaseWeb.Name:
+		return e.tcpReady(name, components.GitbaseWebPort)
+	case bblfshWeb.Name:
+		return e.tcpReady(name, components.BblfshWebPort)
+	case search.Name:
+		return e.tcpReady(name, components.SearchPort)
+	case analytics.Name:
+		return e.analyticsReady
+	default:
+		if _, ok := components.GitbaseShardIndex(name); ok {
+			return e.gitbaseShardReady(name)
+		}
+
+		return nil
+	}
+}
+
+// gitbaseReady checks that gitbase answers SQL queries, as it accepts
+// connections before it loads the repositories
+func (e *Engine) gitbaseReady(ctx context.Context) error {
+	return e.gitbaseShardReady(gitbase.Name)(ctx)
+}
+
+// gitbaseShardReady returns a probe that checks that the gitbase instance
+// with the given container name answers SQL queries, like gitbaseReady
+func (e *Engine) gitbaseShardReady(name string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		db, err := e.pool(name)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+
+		return db.PingContext(ctx)
+	}
+}
+
+// tcpReady returns a probe that checks that the given private port of the
+// component accepts connections
+func (e *Engine) tcpReady(name string, privatePort int) func(context.Context) error {
+	return func(ctx context.Context) error {
+		addr, err := e.addr(name, privatePort)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+}
@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	enry "gopkg.in/src-d/enry.v1"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	searchMountPath     = "/repos"
+	searchDataMountPath = "/data"
+	// searchReadyTimeout is the maximum time to wait for the repositories to
+	// be indexed after the search component is started
+	searchReadyTimeout = 10 * time.Minute
+)
+
+var search = &components.Search
+
+// searchEntrypoint writes the hound config with every directory of the
+// working directory as a repository, and starts hound
+var searchEntrypoint = fmt.Sprintf(`set -e
+conf=%[2]s/config.json
+printf '{"dbpath":"%[2]s/db","repos":{' > $conf
+sep=""
+for d in %[1]s/*/; do
+  [ -d "$d" ] || continue
+  d=${d%%/}
+  printf '%%s"%%s":{"url":"file://%%s","vcs":"local"}' "$sep" "$(basename "$d")" "$d" >> $conf
+  sep=","
+done
+printf '}}' >> $conf
+exec /go/bin/houndd -conf $conf -addr :%[3]d
+`, searchMountPath, searchDataMountPath, components.SearchPort)
+
+// SearchRequest holds the parameters of Search.
+type SearchRequest struct {
+	// Pattern is a regular expression.
+	Pattern string
+	// Lang restricts the search to the files of the given language.
+	Lang       string
+	IgnoreCase bool
+	// Repos restricts the search to the repositories with the given names.
+	Repos []string
+}
+
+// SearchMatch is a line matching a search.
+type SearchMatch struct {
+	Repository string
+	File       string
+	LineNumber int
+	Line       string
+}
+
+// houndResponse is the response of the hound search API
+type houndResponse struct {
+	Error   string
+	Results map[string]struct {
+		Matches []struct {
+			Filename string
+			Matches  []struct {
+				Line       string
+				LineNumber int
+			}
+		}
+	}
+}
+
+// Search looks for the pattern in the repositories of the working directory,
+// starting the search component if needed, and calls send for each matching
+// line. The repositories are indexed when the component starts, so the first
+// search may take a while.
+func (e *Engine) Search(ctx context.Context, req SearchRequest, send func(SearchMatch) error) error {
+	if strings.TrimSpace(req.Pattern) == "" {
+		return fmt.Errorf("search pattern is required")
+	}
+
+	params := url.Values{}
+	params.Set("q", req.Pattern)
+	params.Set("repos", "*")
+	params.Set("i", "nope")
+
+	if len(req.Repos) > 0 {
+		params.Set("repos", strings.Join(req.Repos, ","))
+	}
+
+	if req.IgnoreCase {
+		params.Set("i", "fosho")
+	}
+
+	if req.Lang != "" {
+		files, err := languageFilesRegexp(req.Lang)
+		if err != nil {
+			return err
+		}
+
+		params.Set("files", files)
+	}
+
+	if err := e.Start(ctx, search.Name); err != nil {
+		return err
+	}
+
+	addr, err := e.addr(search.Name, components.SearchPort)
+	if err != nil {
+		return err
+	}
+
+	base := fmt.Sprintf("http://%s", addr)
+	if err := waitSearchReady(ctx, base); err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest("GET", base+"/api/v1/search?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "could not connect to the search component")
+	}
+	defer res.Body.Close()
+
+	var hres houndResponse
+	if err := json.NewDecoder(res.Body).Decode(&hres); err != nil {
+		return errors.Wrap(err, "could not decode search response")
+	}
+
+	if hres.Error != "" {
+		return fmt.Errorf("search failed: %s", hres.Error)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("search failed with status %d", res.StatusCode)
+	}
+
+	repos := make([]string, 0, len(hres.Results))
+	for repo := range hres.Results {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	for _, repo := range repos {
+		for _, file := range hres.Results[repo].Matches {
+			for _, m := range file.Matches {
+				err := send(SearchMatch{
+					Repository: repo,
+					File:       file.Filename,
+					LineNumber: m.LineNumber,
+					Line:       m.Line,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// languageFilesRegexp returns a regular expression matching the file names
+// with any of the extensions of the given language
+func languageFilesRegexp(lang string) (string, error) {
+	name, ok := enry.GetLanguageByAlias(lang)
+	if !ok {
+		return "", fmt.Errorf("unknown language %s", lang)
+	}
+
+	exts := enry.GetLanguageExtensions(name)
+	if len(exts) == 0 {
+		return "", fmt.Errorf("language %s has no known file extensions", name)
+	}
+
+	quoted := make([]string, len(exts))
+	for i, ext := range exts {
+		quoted[i] = regexp.QuoteMeta(ext)
+	}
+
+	return fmt.Sprintf("(%s)$", strings.Join(quoted, "|")), nil
+}
+
+// waitSearchReady polls hound until it has indexed the repositories
+func waitSearchReady(ctx context.Context, base string) error {
+	ctx, cancel := context.WithTimeout(ctx, searchReadyTimeout)
+	defer cancel()
+
+	for {
+		req, err := http.NewRequest("GET", base+"/healthz", nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return nil
+			}
+
+			err = fmt.Errorf("status %d", res.StatusCode)
+		}
+
+		log.Debugf("search component is not ready yet: %s", err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(err, "search component did not become ready")
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (e *Engine) searchComponent(port int) (*Component, error) {
+	port = e.publicPort(search.Name, port)
+
+	dataVolumeName := e.searchVolumeName()
+	if err := docker.CreateVolume(context.TODO(), dataVolumeName); err != nil {
+		return nil, errors.Wrapf(err, "can't create volume for the search index")
+	}
+
+	workdirHostPath, err := docker.HostPath(e.workdir, e.hostOS)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't process host path for workdir %s", e.workdir)
+	}
+
+	config, host := searchConfig(e.overrides(search.Name,
+		docker.WithROSharedDirectory(workdirHostPath, searchMountPath, e.hostOS),
+		docker.WithVolume(dataVolumeName, searchDataMountPath, e.hostOS),
+		docker.WithPort(port, components.SearchPort),
+	)...)
+
+	return e.newComponent(e.component(search), config, host), nil
+}
+
+func searchConfig(opts ...docker.ConfigOption) (*container.Config, *container.HostConfig) {
+	config := &container.Config{
+		Entrypoint: []string{"/bin/sh", "-c", searchEntrypoint},
+	}
+	host := &container.HostConfig{}
+	docker.ApplyOptions(config, host, opts...)
+
+	return config, host
+}
@@ -0,0 +1,22 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguageFilesRegexp(t *testing.T) {
+	assert := assert.New(t)
+
+	re, err := languageFilesRegexp("go")
+	assert.NoError(err)
+	assert.Equal(`(\.go)$`, re)
+
+	re, err = languageFilesRegexp("Python")
+	assert.NoError(err)
+	assert.Contains(re, `\.py|`)
+
+	_, err = languageFilesRegexp("not-a-language")
+	assert.Error(err)
+}
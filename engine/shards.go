@@ -0,0 +1,318 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+)
+
+// shardComponent returns gitbase with the container name of the shard with
+// the given index
+func (e *Engine) shardComponent(i int) components.Component {
+	cmp := e.component(gitbase)
+	cmp.Name = components.GitbaseShardName(i)
+	return cmp
+}
+
+// gitbaseShardIndexVolumeName returns the name of the index volume of the
+// shard with the given index, which has the prefix of the gitbase ones
+func (e *Engine) gitbaseShardIndexVolumeName(i int) string {
+	return fmt.Sprintf("%s-shard-%d", e.gitbaseIndexVolumeName(), i)
+}
+
+// gitbaseShardComponent returns the gitbase instance of the shard with the
+// given index of the config. It mounts only the repositories of the shard,
+// at the same paths gitbase does, and has its own index volume. Its port is
+// not published, as only the Engine connects to it, unless it runs outside
+// the docker network
+func (e *Engine) gitbaseShardComponent(i int) (*Component, error) {
+	shards := e.config.Components.Gitbase.Shards
+	if i >= len(shards) {
+		return nil, fmt.Errorf("gitbase shard %d is not set in the config", i)
+	}
+
+	indexVolumeName := e.gitbaseShardIndexVolumeName(i)
+	if err := docker.CreateVolume(context.TODO(), indexVolumeName); err != nil {
+		return nil, errors.Wrapf(err, "can't create volume for gitbase index")
+	}
+
+	var opts []docker.ConfigOption
+	for _, repo := range shards[i].Repositories {
+		// the repositories are validated when the config is read
+		repo = path.Clean(repo)
+		hostPath, err := docker.HostPath(path.Join(e.workdir, repo), e.hostOS)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't process host path for repository %s", repo)
+		}
+
+		opts = append(opts, docker.WithROSharedDirectory(hostPath, path.Join(gitbaseMountPath, repo), e.hostOS))
+	}
+
+	opts = append(opts, docker.WithVolume(indexVolumeName, gitbaseIndexMountPath, e.hostOS))
+	if !e.inNetwork {
+		opts = append(opts, docker.WithPortOn("127.0.0.1", 0, components.GitbasePort))
+	}
+
+	opts = append(opts, e.gitbaseCredentialOptions()...)
+	opts = append(opts, e.gitbaseFormatOptions()...)
+	config, host := gitbaseConfig(e.overrides(gitbase.Name, opts...)...)
+
+	return e.newComponent(e.shardComponent(i), config, host), nil
+}
+
+// startShards starts the gitbase instances of all the shards of the config,
+// and their dependencies
+func (e *Engine) startShards(ctx context.Context) error {
+	var cs []Component
+	for i := range e.config.Components.Gitbase.Shards {
+		c, err := e.runnable(components.GitbaseShardName(i), 0)
+		if err != nil {
+			return err
+		}
+
+		cs = append(cs, *c)
+	}
+
+	return e.starter.runAll(ctx, cs)
+}
+
+// ShardedQueryError is returned by Query when the repositories are split in
+// shards and the query has a clause whose result depends on the rows of all
+// of them, since the rows of each shard are not merged
+type ShardedQueryError struct {
+	// Clause is the first clause or aggregate function found, in upper case
+	Clause string
+}
+
+func (e *ShardedQueryError) Error() string {
+	return fmt.Sprintf("%s can't be used when the repositories are split in gitbase shards, "+
+		"it would apply to the rows of each shard on its own", e.Clause)
+}
+
+// unmergeableRegexp matches the clauses and aggregate functions whose result
+// depends on the rows of all the shards
+var unmergeableRegexp = regexp.MustCompile(`(?i)\b(order\s+by|group\s+by|having|limit|offset|distinct)\b|` +
+	`\b(count|sum|avg|min|max|group_concat|std|stddev|stddev_pop|stddev_samp|` +
+	`variance|var_pop|var_samp|bit_and|bit_or|bit_xor)\s*\(`)
+
+// trailingLimitRegexp matches a LIMIT without offset at the end of the query,
+// which is applied once to the rows of all the shards
+var trailingLimitRegexp = regexp.MustCompile(`(?i)\blimit\s+(\d+)[\s;]*$`)
+
+// shardedLimit returns the LIMIT at the end of the query, or -1 if it has
+// none. It returns a *ShardedQueryError if the query can't run in every
+// shard on its own, see unmergeableRegexp. The quoted strings and
+// identifiers and the comments are not checked
+func shardedLimit(query string) (int64, error) {
+	query = withoutQuoted(query)
+
+	limit := int64(-1)
+	if m := trailingLimitRegexp.FindStringSubmatchIndex(query); m != nil {
+		n, err := strconv.ParseInt(query[m[2]:m[3]], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid LIMIT %s", query[m[2]:m[3]])
+		}
+
+		limit, query = n, query[:m[0]]
+	}
+
+	m := unmergeableRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return limit, nil
+	}
+
+	clause := m[1]
+	if clause == "" {
+		clause = m[2] + "()"
+	}
+
+	return 0, &ShardedQueryError{Clause: strings.ToUpper(strings.Join(strings.Fields(clause), " "))}
+}
+
+// withoutQuoted returns the query with the quoted strings and identifiers
+// and the comments replaced by spaces
+func withoutQuoted(query string) string {
+	b := []byte(query)
+	for i := 0; i < len(b); i++ {
+		var end string
+		var escapes bool
+		j := i + 1
+		switch {
+		case b[i] == '\'' || b[i] == '"' || b[i] == '`':
+			end, escapes = string(b[i]), b[i] != '`'
+		case b[i] == '#':
+			end = "\n"
+		case strings.HasPrefix(query[i:], "-- "):
+			end = "\n"
+		case strings.HasPrefix(query[i:], "/*"):
+			end, j = "*/", i+2
+		default:
+			continue
+		}
+
+		for j < len(b) && !strings.HasPrefix(query[j:], end) {
+			if escapes && b[j] == '\\' {
+				j++
+			}
+			j++
+		}
+
+		j += len(end)
+		if j > len(b) {
+			j = len(b)
+		}
+
+		for k := i; k < j; k++ {
+			b[k] = ' '
+		}
+		i = j - 1
+	}
+
+	return string(b)
+}
+
+// runShardedSQL runs the query in all the shards at the same time, see Query
+func (e *Engine) runShardedSQL(ctx context.Context, query string) (Rows, error) {
+	limit, err := shardedLimit(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.startShards(ctx); err != nil {
+		return nil, err
+	}
+
+	n := len(e.config.Components.Gitbase.Shards)
+	rows := make([]*sql.Rows, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := components.GitbaseShardName(i)
+			db, err := e.pool(name)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			rows[i], err = db.QueryContext(ctx, query)
+			errs[i] = errors.Wrapf(err, "SQL query failed in %s", name)
+		}(i)
+	}
+	wg.Wait()
+
+	r := &shardRows{rows: rows, limit: limit}
+	for _, err := range errs {
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	if err := r.checkColumns(); err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// shardRows are the rows of a query run in every shard, the ones of each
+// shard after the ones of the previous, up to the limit if it is not negative
+type shardRows struct {
+	rows  []*sql.Rows
+	cur   int
+	err   error
+	limit int64
+	read  int64
+}
+
+// checkColumns returns an error if the shards don't return the same columns
+func (r *shardRows) checkColumns() error {
+	columns, err := r.Columns()
+	if err != nil {
+		return err
+	}
+
+	for i, rows := range r.rows[1:] {
+		cs, err := rows.Columns()
+		if err != nil {
+			return errors.Wrap(err, "could not fetch columns")
+		}
+
+		if fmt.Sprint(cs) != fmt.Sprint(columns) {
+			return fmt.Errorf("the columns of %s don't match the ones of %s",
+				components.GitbaseShardName(i+1), components.GitbaseShardName(0))
+		}
+	}
+
+	return nil
+}
+
+func (r *shardRows) Columns() ([]string, error) {
+	return r.rows[0].Columns()
+}
+
+func (r *shardRows) Next() bool {
+	if r.limit >= 0 && r.read >= r.limit {
+		return false
+	}
+
+	for r.err == nil && r.cur < len(r.rows) {
+		rows := r.rows[r.cur]
+		if rows.Next() {
+			r.read++
+			return true
+		}
+
+		if err := rows.Err(); err != nil {
+			r.err = errors.Wrapf(err, "SQL query failed in %s", components.GitbaseShardName(r.cur))
+			return false
+		}
+
+		r.cur++
+	}
+
+	return false
+}
+
+func (r *shardRows) Scan(dest ...interface{}) error {
+	if r.cur >= len(r.rows) {
+		return fmt.Errorf("there are no more rows")
+	}
+
+	return r.rows[r.cur].Scan(dest...)
+}
+
+func (r *shardRows) Err() error {
+	return r.err
+}
+
+// Close closes the rows of every shard
+func (r *shardRows) Close() error {
+	var first error
+	for _, rows := range r.rows {
+		if rows == nil {
+			continue
+		}
+
+		if err := rows.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedLimit(t *testing.T) {
+	require := require.New(t)
+
+	for query, limit := range map[string]int64{
+		"SELECT repository_id FROM repositories":                          -1,
+		"SELECT * FROM commits WHERE commit_message LIKE '%order by%'":    -1,
+		"SELECT * FROM refs WHERE ref_name = 'it''s \\' limit'":           -1,
+		"SELECT `count` FROM t WHERE \"max(\" = x":                        -1,
+		"SELECT * FROM files # LIMIT 10\nWHERE file_path = 'a'":           -1,
+		"SELECT /* ORDER BY */ * FROM files -- GROUP BY x":                -1,
+		"SELECT countless, maxed, delimited FROM t":                       -1,
+		"SELECT * FROM files LIMIT 100":                                   100,
+		"select * from files where file_path = 'limit 5' limit 10 ;\n":    10,
+		"SELECT * FROM refs LIMIT 0":                                      0,
+		"SELECT * FROM refs /* LIMIT 3 */ LIMIT 5 -- the first ones only": 5,
+	} {
+		n, err := shardedLimit(query)
+		require.NoError(err, query)
+		require.Equal(limit, n, query)
+	}
+
+	for query, clause := range map[string]string{
+		"SELECT COUNT(*) FROM repositories":                    "COUNT()",
+		"select max (committer_when) from commits":             "MAX()",
+		"SELECT * FROM refs ORDER  BY ref_name":                "ORDER BY",
+		"SELECT * FROM refs ORDER BY ref_name LIMIT 10":        "ORDER BY",
+		"SELECT * FROM refs LIMIT 10, 5":                       "LIMIT",
+		"SELECT * FROM refs LIMIT 5 OFFSET 10":                 "LIMIT",
+		"SELECT * FROM (SELECT * FROM refs LIMIT 5) r":         "LIMIT",
+		"SELECT lang FROM files GROUP\tBY lang":                "GROUP BY",
+		"SELECT DISTINCT repository_id FROM refs":              "DISTINCT",
+		"SELECT * FROM refs WHERE 'a' = 'a' ORDER BY ref_name": "ORDER BY",
+	} {
+		_, err := shardedLimit(query)
+		require.IsType(&ShardedQueryError{}, err, query)
+		require.Equal(clause, err.(*ShardedQueryError).Clause, query)
+	}
+
+	_, err := shardedLimit("SELECT * FROM refs ORDER BY ref_name")
+	require.EqualError(err, "ORDER BY can't be used when the repositories are split in gitbase "+
+		"shards, it would apply to the rows of each shard on its own")
+}
@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/docker"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// Volume is a docker volume where a component keeps state that is expensive
+// to rebuild.
+type Volume struct {
+	// Kind identifies the volume in a snapshot. Unlike Name, it does not
+	// depend on the working directory, so snapshots can be restored for a
+	// different one.
+	Kind string
+	// Name of the docker volume.
+	Name string
+	// Component is the component that mounts the volume.
+	Component components.Component
+}
+
+// Volumes returns the volumes of the components for the working directory of
+// the Engine.
+func (e *Engine) Volumes() []Volume {
+	return []Volume{
+		{Kind: "gitbase-index", Name: e.gitbaseIndexVolumeName(), Component: e.component(gitbase)},
+		{Kind: "search-index", Name: e.searchVolumeName(), Component: e.component(search)},
+		{Kind: "bblfshd-drivers", Name: e.bblfshdDriversVolumeName(), Component: e.component(bblfshd)},
+	}
+}
+
+func snapshotFile(dir string, v Volume) string {
+	return filepath.Join(dir, v.Kind+".tar.gz")
+}
+
+// CreateSnapshot archives the contents of the component volumes into dir, one
+// gzipped tarball per volume, and returns the archived volumes. The volumes
+// that were never created are skipped. The components using the volumes must
+// be stopped, so the snapshot does not contain partially written files.
+func (e *Engine) CreateSnapshot(ctx context.Context, dir string) ([]Volume, error) {
+	for _, v := range e.Volumes() {
+		running, err := docker.IsRunning(v.Component.Name, "")
+		if err != nil {
+			return nil, err
+		}
+
+		if running {
+			return nil, fmt.Errorf("component %s must be stopped to archive its volume", v.Component.Name)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "could not create directory %s", dir)
+	}
+
+	var archived []Volume
+	for _, v := range e.Volumes() {
+		exists, err := docker.VolumeExists(ctx, v.Name)
+		if err != nil {
+			return archived, err
+		}
+
+		if !exists {
+			log.Debugf("skipping volume %s, it does not exist", v.Name)
+			continue
+		}
+
+		log.Infof("archiving volume %s", v.Name)
+		if err := exportVolume(ctx, v, snapshotFile(dir, v)); err != nil {
+			return archived, err
+		}
+
+		archived = append(archived, v)
+	}
+
+	return archived, nil
+}
+
+func exportVolume(ctx context.Context, v Volume, path string) error {
+	if err := docker.EnsureInstalled(v.Component.Image, v.Component.Version); err != nil {
+		return err
+	}
+
+	// the archive is written to a temporary file first, so a failure does
+	// not leave a truncated one behind
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return errors.Wrap(err, "could not create snapshot file")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	err = docker.ExportVolume(ctx, v.Name, v.Component.ImageWithVersion(), gz)
+	if err != nil {
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		return errors.Wrap(err, "could not write snapshot file")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "could not write snapshot file")
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// RestoreSnapshot replaces the contents of the component volumes with the
+// tarballs in dir, written by CreateSnapshot, and returns the restored
+// volumes. The components using the volumes must be stopped and removed.
+func (e *Engine) RestoreSnapshot(ctx context.Context, dir string) ([]Volume, error) {
+	var volumes []Volume
+	for _, v := range e.Volumes() {
+		if _, err := os.Stat(snapshotFile(dir, v)); err == nil {
+			volumes = append(volumes, v)
+		} else if !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "could not read snapshot")
+		}
+	}
+
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("there are no volumes in the snapshot at %s", dir)
+	}
+
+	// check all the components first, so the volumes are not left half
+	// restored
+	for _, v := range volumes {
+		_, err := docker.Info(v.Component.Name)
+		if err == nil {
+			return nil, fmt.Errorf("component %s must be stopped to restore its volume", v.Component.Name)
+		}
+
+		if err != docker.ErrNotFound {
+			return nil, err
+		}
+	}
+
+	var restored []Volume
+	for _, v := range volumes {
+		log.Infof("restoring volume %s", v.Name)
+		if err := importVolume(ctx, v, snapshotFile(dir, v)); err != nil {
+			return restored, err
+		}
+
+		restored = append(restored, v)
+	}
+
+	return restored, nil
+}
+
+func importVolume(ctx context.Context, v Volume, path string) error {
+	if err := docker.EnsureInstalled(v.Component.Image, v.Component.Version); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "could not open snapshot file")
+	}
+	defer f.Close()
+
+	exists, err := docker.VolumeExists(ctx, v.Name)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		if err := docker.RemoveVolume(ctx, v.Name); err != nil {
+			return errors.Wrapf(err, "could not remove volume %s", v.Name)
+		}
+	}
+
+	// docker extracts the gzipped tarball itself
+	return docker.ImportVolume(ctx, v.Name, v.Component.ImageWithVersion(), f)
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/pkg/errors"
+	"github.com/src-d/engine/components"
+	"github.com/src-d/engine/tracing"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// RunSQL runs the query in gitbase, starting it if needed. The caller must
+// close the returned rows.
+func (e *Engine) RunSQL(ctx context.Context, query string) (*sql.Rows, error) {
+	db, err := e.gitbaseDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "SQL query failed")
+	}
+
+	return rows, nil
+}
+
+// Rows is the result of Query, like *sql.Rows.
+type Rows interface {
+	Columns() ([]string, error)
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
+// Query runs the query like RunSQL. When the repositories are split between
+// several gitbase instances, see api.Config.GitbaseShards, the query runs in
+// all of them at the same time, starting them if needed, and the rows of each
+// one follow the ones of the previous. A LIMIT at the end of the query applies
+// to all the rows. The rows are not merged otherwise, so the queries with
+// aggregations, GROUP BY, ORDER BY, DISTINCT or any other LIMIT fail with a
+// *ShardedQueryError instead of returning a wrong result. The caller must
+// close the returned rows.
+//
+// The query is traced until its first rows are returned.
+func (e *Engine) Query(ctx context.Context, query string) (Rows, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "gitbase query")
+	defer span.Finish()
+	ext.DBType.Set(span, "sql")
+	ext.DBStatement.Set(span, query)
+
+	if len(e.config.Components.Gitbase.Shards) > 0 {
+		return e.runShardedSQL(ctx, query)
+	}
+
+	rows, err := e.RunSQL(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// gitbaseDB starts gitbase and returns the connection pool to it, which is
+// reused between queries
+func (e *Engine) gitbaseDB(ctx context.Context) (*sql.DB, error) {
+	if err := e.Start(ctx, gitbase.Name); err != nil {
+		return nil, err
+	}
+
+	return e.gitbasePool()
+}
+
+// gitbasePool returns the connection pool to the running gitbase, creating it
+// the first time
+func (e *Engine) gitbasePool() (*sql.DB, error) {
+	return e.pool(gitbase.Name)
+}
+
+// pool returns the connection pool to the running gitbase instance with the
+// given container name, gitbase or one of its shards, creating it the first
+// time
+func (e *Engine) pool(name string) (*sql.DB, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if db, ok := e.dbs[name]; ok {
+		return db, nil
+	}
+
+	addr, err := e.addr(name, components.GitbasePort)
+	if err != nil {
+		return nil, err
+	}
+
+	user, password := e.config.GitbaseCredentials()
+	cfg := mysql.Config{
+		User:                 user,
+		Passwd:               password,
+		Net:                  "tcp",
+		Addr:                 addr,
+		AllowNativePasswords: true,
+		MaxAllowedPacket:     32 << 20, // 32 MiB
+	}
+	// the DSN would log the password
+	log.Infof("connecting to mysql %s@%s", cfg.User, cfg.Addr)
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not connect to %s", name)
+	}
+
+	e.dbs[name] = db
+	return db, nil
+}
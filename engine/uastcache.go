@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"gopkg.in/bblfsh/sdk.v2/uast/nodes"
+	"gopkg.in/bblfsh/sdk.v2/uast/nodes/nodesproto"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// uastCache stores the parsed UASTs in a directory, so the files that didn't
+// change are not parsed again. The UASTs are keyed by the hash of the file
+// content, the language, the driver version and the UAST mode, so a new
+// driver version parses them again.
+type uastCache struct {
+	dir string
+	// disabled is set to 1, atomically, when the directory is not writable
+	disabled int32
+}
+
+// newUASTCache returns a cache in dir. It returns nil, which is a valid cache
+// that stores nothing, if dir is empty.
+func newUASTCache(dir string) *uastCache {
+	if dir == "" {
+		return nil
+	}
+
+	return &uastCache{dir: dir}
+}
+
+// uastCacheKey returns the key of the UAST of the content parsed with the
+// given language, driver version and mode.
+func uastCacheKey(content []byte, lang, driverVersion, mode string) string {
+	contentHash := sha256.Sum256(content)
+
+	h := sha256.New()
+	for _, s := range []string{hex.EncodeToString(contentHash[:]), lang, driverVersion, mode} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *uastCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Get returns the cached UAST of the key, and whether it was found.
+func (c *uastCache) Get(key string) (nodes.Node, bool) {
+	if c == nil || atomic.LoadInt32(&c.disabled) == 1 {
+		return nil, false
+	}
+
+	b, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warningf("could not read cached UAST: %s", err)
+		}
+
+		return nil, false
+	}
+
+	n, err := nodesproto.ReadTree(bytes.NewReader(b))
+	if err != nil {
+		log.Warningf("could not decode cached UAST %s: %s", key, err)
+		return nil, false
+	}
+
+	return n, true
+}
+
+// Put stores the UAST of the key. The cache is disabled if the directory is
+// not writable, as it happens when the daemon runs as a user that doesn't own
+// it, so the error is only logged once.
+func (c *uastCache) Put(key string, n nodes.Node) {
+	if c == nil || atomic.LoadInt32(&c.disabled) == 1 {
+		return
+	}
+
+	err := c.put(key, n)
+	if err != nil && atomic.CompareAndSwapInt32(&c.disabled, 0, 1) {
+		log.Warningf("disabling the UAST cache: %s", err)
+	}
+}
+
+func (c *uastCache) put(key string, n nodes.Node) error {
+	var buf bytes.Buffer
+	if err := nodesproto.WriteTo(&buf, n); err != nil {
+		return errors.Wrap(err, "could not encode UAST")
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "could not create cache directory")
+	}
+
+	// the file is renamed once complete, so a concurrent Get never reads it
+	// partially written
+	tmp, err := ioutil.TempFile(filepath.Dir(path), key+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "could not create cache file")
+	}
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "could not write cache file")
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "could not write cache file")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), path), "could not write cache file")
+}
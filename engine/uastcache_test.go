@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/bblfsh/sdk.v2/uast/nodes"
+)
+
+func TestUASTCache(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-uast-cache")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	c := newUASTCache(dir)
+	key := uastCacheKey([]byte("package main"), "go", "v2.7.1", "Semantic")
+
+	_, ok := c.Get(key)
+	require.False(ok)
+
+	n := nodes.Object{
+		"@type": nodes.String("go:File"),
+		"Name":  nodes.Array{nodes.Int(1), nodes.Bool(true)},
+	}
+	c.Put(key, n)
+
+	cached, ok := c.Get(key)
+	require.True(ok)
+	require.True(nodes.Equal(n, cached))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	require.NoError(err)
+	require.Len(files, 1)
+}
+
+func TestUASTCacheKey(t *testing.T) {
+	require := require.New(t)
+
+	key := uastCacheKey([]byte("a"), "go", "v1", "Semantic")
+	require.Equal(key, uastCacheKey([]byte("a"), "go", "v1", "Semantic"))
+	require.NotEqual(key, uastCacheKey([]byte("b"), "go", "v1", "Semantic"))
+	require.NotEqual(key, uastCacheKey([]byte("a"), "python", "v1", "Semantic"))
+	require.NotEqual(key, uastCacheKey([]byte("a"), "go", "v2", "Semantic"))
+	require.NotEqual(key, uastCacheKey([]byte("a"), "go", "v1", "Native"))
+}
+
+func TestUASTCacheDisabled(t *testing.T) {
+	require := require.New(t)
+
+	var c *uastCache
+	require.Nil(newUASTCache(""))
+	c.Put("key", nodes.String("x"))
+	_, ok := c.Get("key")
+	require.False(ok)
+
+	f, err := ioutil.TempFile("", "srcd-uast-cache")
+	require.NoError(err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// a file instead of a directory can't be written
+	c = newUASTCache(f.Name())
+	key := uastCacheKey([]byte("a"), "go", "v1", "Semantic")
+	c.Put(key, nodes.String("x"))
+	require.Equal(int32(1), c.disabled)
+	_, ok = c.Get(key)
+	require.False(ok)
+}
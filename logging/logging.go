@@ -0,0 +1,159 @@
+// Package logging provides the loggers of the subsystems of the engine, whose
+// levels can be set independently, e.g. to debug the docker calls only.
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-log.v1"
+)
+
+// All is the name of the level of the subsystems without their own level,
+// and of the messages of the rest of the engine
+const All = "all"
+
+// subsystems are the names of the subsystems whose level can be set
+var subsystems = []string{"docker", "daemon", "registry"}
+
+var (
+	mu      sync.RWMutex
+	levels  = map[string]string{}
+	loggers = map[string]log.Logger{}
+)
+
+// Docker returns the logger of the docker operations, such as the containers
+// and networks created.
+func Docker() log.Logger {
+	return logger("docker")
+}
+
+// Daemon returns the logger of the management of the daemon by srcd.
+func Daemon() log.Logger {
+	return logger("daemon")
+}
+
+// Registry returns the logger of the image pulls, the registry credentials
+// and the image signatures.
+func Registry() log.Logger {
+	return logger("registry")
+}
+
+// logger returns the logger of the subsystem created by Configure, or the
+// default logger until then. The loggers are returned instead of wrapped, so
+// the source of the debug messages is their caller.
+func logger(subsystem string) log.Logger {
+	mu.RLock()
+	l, ok := loggers[subsystem]
+	mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	if log.DefaultLogger == nil {
+		log.DefaultLogger = log.New(nil)
+	}
+
+	return log.DefaultLogger
+}
+
+// Subsystems returns the names of the subsystems whose level can be set.
+func Subsystems() []string {
+	return append([]string(nil), subsystems...)
+}
+
+// ParseLevels parses the levels by subsystem given with --verbose, in the
+// form subsystem[:level], where the level is debug by default. The level of
+// the all subsystem is the one of the subsystems without their own level.
+func ParseLevels(values []string) (map[string]string, error) {
+	known := map[string]bool{All: true}
+	for _, name := range Subsystems() {
+		known[name] = true
+	}
+
+	levels := make(map[string]string)
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		name, level := v, log.DebugLevel
+		if i := strings.Index(v, ":"); i >= 0 {
+			name, level = v[:i], strings.ToLower(v[i+1:])
+		}
+
+		if !known[name] {
+			return nil, fmt.Errorf("invalid --verbose %q, unknown subsystem %q, valid ones are: %s",
+				v, name, strings.Join(append([]string{All}, Subsystems()...), ", "))
+		}
+
+		switch level {
+		case log.DebugLevel, log.InfoLevel, log.WarningLevel, log.ErrorLevel:
+		default:
+			return nil, fmt.Errorf("invalid --verbose %q, unknown level %q, valid ones are: debug, info, warning, error", v, level)
+		}
+
+		levels[name] = level
+	}
+
+	return levels, nil
+}
+
+// Configure creates the loggers of the subsystems with the level given in
+// levels, or the one of the all subsystem, or the one of the factory, and
+// its format. The messages have the subsystem field with their subsystem.
+func Configure(factory *log.LoggerFactory, subsystemLevels map[string]string) error {
+	configured := make(map[string]log.Logger)
+	for _, name := range Subsystems() {
+		f := *factory
+		if level, ok := subsystemLevels[name]; ok {
+			f.Level = level
+		} else if level, ok := subsystemLevels[All]; ok {
+			f.Level = level
+		}
+
+		l, err := f.New(log.Fields{"subsystem": name})
+		if err != nil {
+			return err
+		}
+
+		configured[name] = l
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	loggers = configured
+	levels = copyLevels(subsystemLevels)
+	return nil
+}
+
+// Levels returns the levels by subsystem set with Configure.
+func Levels() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return copyLevels(levels)
+}
+
+// Args returns the --verbose arguments that set the given levels, sorted by
+// subsystem, so they can be passed to another process.
+func Args(levels map[string]string) []string {
+	var args []string
+	for name, level := range levels {
+		args = append(args, fmt.Sprintf("--verbose=%s:%s", name, level))
+	}
+
+	sort.Strings(args)
+	return args
+}
+
+func copyLevels(levels map[string]string) map[string]string {
+	result := make(map[string]string, len(levels))
+	for k, v := range levels {
+		result[k] = v
+	}
+
+	return result
+}
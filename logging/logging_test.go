@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+func TestParseLevels(t *testing.T) {
+	require := require.New(t)
+
+	levels, err := ParseLevels(nil)
+	require.NoError(err)
+	require.Empty(levels)
+
+	levels, err = ParseLevels([]string{"all"})
+	require.NoError(err)
+	require.Equal(map[string]string{All: "debug"}, levels)
+
+	levels, err = ParseLevels([]string{"docker", "registry:WARNING", " all:error "})
+	require.NoError(err)
+	require.Equal(map[string]string{
+		"docker":   "debug",
+		"registry": "warning",
+		All:        "error",
+	}, levels)
+
+	_, err = ParseLevels([]string{"bblfsh"})
+	require.EqualError(err, `invalid --verbose "bblfsh", unknown subsystem "bblfsh", valid ones are: all, docker, daemon, registry`)
+
+	_, err = ParseLevels([]string{"docker:trace"})
+	require.EqualError(err, `invalid --verbose "docker:trace", unknown level "trace", valid ones are: debug, info, warning, error`)
+}
+
+func TestConfigure(t *testing.T) {
+	require := require.New(t)
+
+	defer func() {
+		mu.Lock()
+		loggers = map[string]log.Logger{}
+		levels = map[string]string{}
+		mu.Unlock()
+	}()
+
+	require.NotNil(Docker())
+	require.Equal(log.DefaultLogger, Docker())
+
+	levels := map[string]string{"docker": "debug", All: "warning"}
+	require.NoError(Configure(&log.LoggerFactory{Level: "info", Format: "json"}, levels))
+	require.Equal(levels, Levels())
+
+	require.NotEqual(log.DefaultLogger, Docker())
+	require.NotEqual(Docker(), Daemon())
+	require.NotEqual(Docker(), Registry())
+
+	require.Equal([]string{
+		"--verbose=all:warning",
+		"--verbose=docker:debug",
+	}, Args(Levels()))
+
+	err := Configure(&log.LoggerFactory{Level: "info", Format: "xml"}, nil)
+	require.Error(err)
+}
@@ -0,0 +1,268 @@
+// Package plugins loads the manifests of third-party services managed by the
+// engine with the same lifecycle as the built-in components.
+//
+// Each plugin is described by a YAML file in the plugins directory,
+// $HOME/.srcd/plugins by default:
+//
+//	name: search
+//	description: Code search indexer
+//	image: example/indexer
+//	version: v1.0.0
+//	ports:
+//	  - private: 6070
+//	    public: 6070
+//	volumes:
+//	  - workdir: true
+//	    target: /data/repos
+//	  - name: index
+//	    target: /data/index
+//	env:
+//	  - INDEX_PATH=/data/index
+//	health:
+//	  port: 6070
+//	  path: /healthz
+//	  timeout: 60s
+//	verbs:
+//	  - name: reindex
+//	    description: Index the repositories again
+//	    cmd: [indexer, --reindex]
+package plugins
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/src-d/engine/components"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// containerPrefix is prepended, after the profile prefix, to the plugin name
+// to get its container name. The profile prefix makes the plugins stopped and
+// pruned along with the built-in components
+const containerPrefix = "plugin-"
+
+const defaultHealthTimeout = 60 * time.Second
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Manifest describes a plugin
+type Manifest struct {
+	Name        string
+	Description string
+	Image       string
+	Version     string
+	Ports       []Port
+	Volumes     []Volume
+	// Env holds the container environment variables, in the form KEY=value
+	Env    []string
+	Health *Health
+	Verbs  []Verb
+
+	// File is the path of the manifest file
+	File string `yaml:"-"`
+}
+
+// Port is a port exposed by the plugin container
+type Port struct {
+	Private int
+	// Public is the port published in the host, if it is 0 the port is only
+	// reachable from the engine docker network
+	Public int
+}
+
+// Volume is mounted in the plugin container at Target. It is either the
+// working directory, mounted read only, or a named docker volume
+type Volume struct {
+	Workdir bool
+	Name    string
+	Target  string
+}
+
+// Health is the probe used to decide when a started plugin is ready. The
+// plugin is considered healthy once its public Port accepts connections or,
+// if Path is set, replies to an HTTP GET with a 2xx status
+type Health struct {
+	Port    int
+	Path    string
+	Timeout time.Duration
+}
+
+// Verb is a CLI command provided by the plugin, run inside its container
+type Verb struct {
+	Name        string
+	Description string
+	Cmd         []string
+}
+
+// ContainerName returns the name of the plugin container
+func (m *Manifest) ContainerName() string {
+	return components.Prefix() + containerPrefix + m.Name
+}
+
+// VolumeName returns the docker volume name used for the given volume
+func (m *Manifest) VolumeName(v Volume) string {
+	return fmt.Sprintf("%s-%s", m.ContainerName(), v.Name)
+}
+
+// Component returns the plugin as a components.Component
+func (m *Manifest) Component() components.Component {
+	return components.Component{
+		Name:    m.ContainerName(),
+		Image:   m.Image,
+		Version: m.Version,
+	}
+}
+
+// Verb returns the verb with the given name, or nil if there is none
+func (m *Manifest) Verb(name string) *Verb {
+	for i, v := range m.Verbs {
+		if v.Name == name {
+			return &m.Verbs[i]
+		}
+	}
+
+	return nil
+}
+
+// Validate returns an error if the manifest is not valid, and sets the
+// default values of the optional fields
+func (m *Manifest) Validate() error {
+	if !nameRegexp.MatchString(m.Name) {
+		return fmt.Errorf("invalid name %q, it can only contain lowercase letters, digits and dashes", m.Name)
+	}
+
+	if m.Image == "" {
+		return fmt.Errorf("image is required")
+	}
+
+	if m.Version == "" {
+		m.Version = "latest"
+	}
+
+	for _, p := range m.Ports {
+		if p.Private <= 0 || p.Private > 65535 || p.Public < 0 || p.Public > 65535 {
+			return fmt.Errorf("invalid port %d:%d", p.Public, p.Private)
+		}
+	}
+
+	for _, v := range m.Volumes {
+		if v.Target == "" {
+			return fmt.Errorf("volume target is required")
+		}
+
+		if v.Workdir == (v.Name != "") {
+			return fmt.Errorf("volume %s must set either workdir or name", v.Target)
+		}
+	}
+
+	if h := m.Health; h != nil {
+		if m.publicPort(h.Port) == 0 {
+			return fmt.Errorf("health port %d must be a published port", h.Port)
+		}
+
+		if h.Timeout == 0 {
+			h.Timeout = defaultHealthTimeout
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range m.Verbs {
+		if v.Name == "" || len(v.Cmd) == 0 {
+			return fmt.Errorf("verbs require a name and a cmd")
+		}
+
+		if seen[v.Name] {
+			return fmt.Errorf("duplicated verb %s", v.Name)
+		}
+		seen[v.Name] = true
+	}
+
+	return nil
+}
+
+// publicPort returns the public port for the given private one, 0 if it is
+// not published
+func (m *Manifest) publicPort(private int) int {
+	for _, p := range m.Ports {
+		if p.Private == private {
+			return p.Public
+		}
+	}
+
+	return 0
+}
+
+// DefaultDir returns the default plugins directory, $HOME/.srcd/plugins
+func DefaultDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", errors.Wrapf(err, "could not detect home directory")
+	}
+
+	return filepath.Join(home, ".srcd", "plugins"), nil
+}
+
+// Parse decodes and validates a manifest
+func Parse(content []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.UnmarshalStrict(content, &m); err != nil {
+		return nil, err
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Load reads all the *.yml and *.yaml manifests in dir, sorted by name. A
+// missing directory is not an error. The manifests that can't be loaded are
+// skipped, and returned as errors along with the valid ones
+func Load(dir string) ([]*Manifest, []error) {
+	var files []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		files = append(files, matches...)
+	}
+
+	var ms []*Manifest
+	var errs []error
+	names := make(map[string]string)
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "could not read plugin manifest %s", f))
+			continue
+		}
+
+		m, err := Parse(content)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid plugin manifest %s", f))
+			continue
+		}
+
+		if prev, ok := names[m.Name]; ok {
+			errs = append(errs, fmt.Errorf("invalid plugin manifest %s: plugin %s is already defined in %s", f, m.Name, prev))
+			continue
+		}
+
+		m.File = f
+		names[m.Name] = f
+		ms = append(ms, m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name < ms[j].Name })
+	return ms, errs
+}
@@ -0,0 +1,98 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const searchManifest = `
+name: search
+image: example/indexer
+ports:
+  - private: 6070
+    public: 6080
+volumes:
+  - workdir: true
+    target: /data/repos
+  - name: index
+    target: /data/index
+health:
+  port: 6070
+  path: /healthz
+verbs:
+  - name: reindex
+    cmd: [indexer, --reindex]
+`
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := Parse([]byte(searchManifest))
+	assert.NoError(err)
+
+	assert.Equal("srcd-cli-plugin-search", m.ContainerName())
+	assert.Equal("srcd-cli-plugin-search-index", m.VolumeName(m.Volumes[1]))
+	cmp := m.Component()
+	assert.Equal("example/indexer:latest", cmp.ImageWithVersion())
+	assert.Equal(defaultHealthTimeout, m.Health.Timeout)
+	assert.Equal(6080, m.publicPort(6070))
+	assert.NotNil(m.Verb("reindex"))
+	assert.Nil(m.Verb("search"))
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]string{
+		"name":          "name: Search\nimage: a",
+		"no image":      "name: search",
+		"unknown field": "name: search\nimage: a\nfoo: bar",
+		"port":          "name: search\nimage: a\nports: [{private: 0}]",
+		"volume":        "name: search\nimage: a\nvolumes: [{target: /a}]",
+		"volume both":   "name: search\nimage: a\nvolumes: [{target: /a, name: b, workdir: true}]",
+		"health port":   "name: search\nimage: a\nports: [{private: 80}]\nhealth: {port: 80}",
+		"verb":          "name: search\nimage: a\nverbs: [{name: a}]",
+		"verb dup":      "name: search\nimage: a\nverbs: [{name: a, cmd: [a]}, {name: a, cmd: [b]}]",
+	}
+
+	for name, content := range cases {
+		_, err := Parse([]byte(content))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "srcd-plugins")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"search.yml":  searchManifest,
+		"other.yaml":  "name: other\nimage: example/other\nhealth:\n  port: 1\n  timeout: 5s\nports: [{private: 1, public: 1}]",
+		"invalid.yml": "name: invalid",
+		"dup.yml":     "name: search\nimage: example/dup",
+		"README.md":   "not a manifest",
+	}
+	for name, content := range files {
+		require.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	ms, errs := Load(dir)
+	assert.Len(errs, 2)
+	require.Len(ms, 2)
+	assert.Equal("other", ms[0].Name)
+	assert.Equal(5*time.Second, ms[0].Health.Timeout)
+	assert.Equal(filepath.Join(dir, "other.yaml"), ms[0].File)
+	assert.Equal("search", ms[1].Name)
+
+	ms, errs = Load(filepath.Join(dir, "missing"))
+	assert.Empty(ms)
+	assert.Empty(errs)
+}
@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/src-d/engine/docker"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	startTimeout        = 60 * time.Second
+	healthCheckInterval = 500 * time.Millisecond
+)
+
+// Start runs the plugin container, if it is not running already, and waits
+// for it to pass its health probe. The working directory is mounted in the
+// volumes with workdir set
+func Start(ctx context.Context, m *Manifest, workdir string) error {
+	if _, err := docker.InfoOrStart(ctx, m.ContainerName(), m.start(workdir)); err != nil {
+		return err
+	}
+
+	return m.waitHealthy(ctx)
+}
+
+// Stop gracefully stops and removes the plugin container. If it is not running
+// it returns nil
+func Stop(m *Manifest) error {
+	cmp := m.Component()
+	return cmp.Stop()
+}
+
+// Run executes the verb with the given name inside the running plugin
+// container, appending args to its command. It returns the command exit code
+func Run(ctx context.Context, m *Manifest, verb string, args []string, stdout, stderr io.Writer) (int, error) {
+	v := m.Verb(verb)
+	if v == nil {
+		return 0, fmt.Errorf("plugin %s does not have a %s verb", m.Name, verb)
+	}
+
+	cmd := append(append([]string{}, v.Cmd...), args...)
+	return docker.Exec(ctx, m.ContainerName(), cmd, stdout, stderr)
+}
+
+func (m *Manifest) start(workdir string) docker.StartFunc {
+	return func(ctx context.Context) error {
+		if err := docker.EnsureInstalled(m.Image, m.Version); err != nil {
+			return err
+		}
+
+		log.Infof("starting plugin %s", m.Name)
+
+		var opts []docker.ConfigOption
+		for _, p := range m.Ports {
+			if p.Public != 0 {
+				opts = append(opts, docker.WithPort(p.Public, p.Private))
+			}
+		}
+
+		for _, v := range m.Volumes {
+			if v.Workdir {
+				hostPath, err := docker.HostPath(workdir, runtime.GOOS)
+				if err != nil {
+					return errors.Wrapf(err, "can't process host path for workdir %s", workdir)
+				}
+
+				opts = append(opts, docker.WithROSharedDirectory(hostPath, v.Target, runtime.GOOS))
+				continue
+			}
+
+			name := m.VolumeName(v)
+			if err := docker.CreateVolume(ctx, name); err != nil {
+				return errors.Wrapf(err, "can't create volume %s", name)
+			}
+
+			opts = append(opts, docker.WithVolume(name, v.Target, runtime.GOOS))
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, startTimeout)
+		defer cancel()
+
+		cmp := m.Component()
+		config := &container.Config{
+			Image: cmp.ImageWithVersion(),
+			Env:   m.Env,
+		}
+		host := &container.HostConfig{}
+		docker.ApplyOptions(config, host, opts...)
+
+		return docker.Start(ctx, config, host, m.ContainerName())
+	}
+}
+
+// waitHealthy polls the health probe until it succeeds or its timeout is
+// reached. Plugins without a health probe are healthy once started
+func (m *Manifest) waitHealthy(ctx context.Context) error {
+	h := m.Health
+	if h == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort("localhost", strconv.Itoa(m.publicPort(h.Port)))
+	for {
+		err := probe(ctx, addr, h.Path)
+		if err == nil {
+			return nil
+		}
+
+		log.Debugf("plugin %s is not healthy yet: %s", m.Name, err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "plugin %s did not become healthy after %v", m.Name, h.Timeout)
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
+
+func probe(ctx context.Context, addr, path string) error {
+	if path == "" {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s%s", addr, path), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("health check returned status %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, with a test suite named after the
+// tool and a test case for each check. The details and findings of a check
+// are the text of its failure or error element
+func WriteJUnit(w io.Writer, r *Report) error {
+	suite := junitTestSuite{
+		Name:     r.Tool,
+		Tests:    len(r.Checks),
+		Failures: r.Count(Failed),
+		Errors:   r.Count(Error),
+		Time:     junitTime(r.Duration()),
+	}
+
+	for _, c := range r.Checks {
+		tc := junitTestCase{
+			Name:      c.Name,
+			ClassName: r.Tool,
+			Time:      junitTime(c.Duration),
+		}
+
+		lines := append([]string(nil), c.Details...)
+		for _, f := range c.Findings {
+			lines = append(lines, f.String())
+		}
+
+		msg := &junitMessage{Message: c.Message, Text: strings.Join(lines, "\n")}
+		switch c.Status {
+		case Failed:
+			tc.Failure = msg
+		case Error:
+			tc.Error = msg
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,226 @@
+// Package report writes the results of the checks run over the repositories,
+// like the SQL policy checks of srcd ci run or the rows of srcd sql --export,
+// in the formats understood by other tools: JUnit XML for the CI servers, and
+// SARIF for code scanning services like the one of GitHub.
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// Passed is the status of a check that met its expectations
+	Passed Status = "passed"
+	// Failed is the status of a check that did not meet its expectations
+	Failed Status = "failed"
+	// Error is the status of a check that could not be run
+	Error Status = "error"
+)
+
+// Location is the place in the repositories of a finding. All the fields are
+// optional
+type Location struct {
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty"`
+	// Path is relative to the repository, using forward slashes
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+	Line   int    `json:"line,omitempty" yaml:"line,omitempty"`
+	Column int    `json:"column,omitempty" yaml:"column,omitempty"`
+}
+
+// Finding is a problem found by a check, like a row returned by a SQL
+// policy check or a node matched by a UAST rule
+type Finding struct {
+	Message  string   `json:"message" yaml:"message"`
+	Location Location `json:"location" yaml:"location"`
+}
+
+// String returns the location as repository/path:line:column, omitting the
+// parts that are not known
+func (l Location) String() string {
+	var s string
+	if l.Repository != "" {
+		s = l.Repository + "/"
+	}
+
+	s += l.Path
+	if l.Line > 0 {
+		s += fmt.Sprintf(":%d", l.Line)
+		if l.Column > 0 {
+			s += fmt.Sprintf(":%d", l.Column)
+		}
+	}
+
+	return s
+}
+
+// String returns the finding as its location followed by its message
+func (f Finding) String() string {
+	loc := f.Location.String()
+	if loc == "" {
+		return f.Message
+	}
+
+	return loc + ": " + f.Message
+}
+
+// Severity is the importance of the findings of a check
+type Severity string
+
+const (
+	// SeverityError is the default severity, for the findings that must be
+	// fixed
+	SeverityError Severity = "error"
+	// SeverityWarning is for the findings that should be reviewed
+	SeverityWarning Severity = "warning"
+	// SeverityNote is for the informative findings
+	SeverityNote Severity = "note"
+)
+
+// Check is the result of a check
+type Check struct {
+	Name string
+	// Description explains what the check looks for, its name is used if it
+	// is empty
+	Description string
+	// Severity of the findings of the check, SeverityError if it is empty
+	Severity Severity
+	Status   Status
+	// Message explains why the check failed or could not be run
+	Message string
+	// Details are free-form lines about the result, like the rows of a query
+	Details  []string
+	Findings []Finding
+	Duration time.Duration
+}
+
+// Report is the result of a list of checks run by a tool
+type Report struct {
+	// Tool is the name of the program that run the checks, and Version its
+	// version
+	Tool    string
+	Version string
+	Checks  []Check
+}
+
+// Count returns the number of checks with the given status
+func (r *Report) Count(status Status) int {
+	var n int
+	for _, c := range r.Checks {
+		if c.Status == status {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Duration returns the time spent running all the checks
+func (r *Report) Duration() time.Duration {
+	var d time.Duration
+	for _, c := range r.Checks {
+		d += c.Duration
+	}
+
+	return d
+}
+
+// Format is the file format of a report
+type Format string
+
+const (
+	// JUnit is the JUnit XML format, with a test case for each check
+	JUnit Format = "junit"
+	// SARIF is the Static Analysis Results Interchange Format 2.1.0, with a
+	// rule for each check and a result for each finding
+	SARIF Format = "sarif"
+)
+
+// Write writes the report in the given format
+func Write(w io.Writer, format Format, r *Report) error {
+	switch format {
+	case JUnit:
+		return WriteJUnit(w, r)
+	case SARIF:
+		return WriteSARIF(w, r)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// WriteFile writes the report in the given format to a file
+func WriteFile(path string, format Format, r *Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := Write(f, format, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// RowFindings returns a finding for each row of a query result. The location
+// is read from the repository_id, file_path, line and column columns, if
+// there are any, and the message from the message column, or is made of all
+// the other cells otherwise
+func RowFindings(columns []string, rows [][]string) []Finding {
+	index := map[string]int{}
+	for i, c := range columns {
+		index[strings.ToLower(c)] = i
+	}
+
+	findings := make([]Finding, 0, len(rows))
+	for _, row := range rows {
+		cell := func(name string) (string, bool) {
+			i, ok := index[name]
+			if !ok || i >= len(row) {
+				return "", false
+			}
+
+			return row[i], true
+		}
+
+		var f Finding
+		f.Location.Repository, _ = cell("repository_id")
+		f.Location.Path, _ = cell("file_path")
+		if v, ok := cell("line"); ok {
+			f.Location.Line, _ = strconv.Atoi(strings.TrimSpace(v))
+		}
+		if v, ok := cell("column"); ok {
+			f.Location.Column, _ = strconv.Atoi(strings.TrimSpace(v))
+		}
+
+		if msg, ok := cell("message"); ok {
+			f.Message = msg
+		} else {
+			var parts []string
+			for i, v := range row {
+				if i < len(columns) {
+					switch strings.ToLower(columns[i]) {
+					case "repository_id", "file_path", "line", "column":
+						continue
+					}
+
+					parts = append(parts, columns[i]+"="+v)
+				}
+			}
+
+			f.Message = strings.Join(parts, ", ")
+		}
+
+		findings = append(findings, f)
+	}
+
+	return findings
+}
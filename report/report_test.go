@@ -0,0 +1,115 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testReport = &Report{
+	Tool:    "srcd ci",
+	Version: "v1.0.0",
+	Checks: []Check{
+		{Name: "ok", Status: Passed, Duration: time.Second},
+		{
+			Name:    "big files",
+			Status:  Failed,
+			Message: "the query returned 2 rows",
+			Findings: []Finding{
+				{Message: "size=3", Location: Location{Repository: "engine", Path: "a.bin"}},
+				{Message: "too long", Location: Location{Path: "main.go", Line: 10, Column: 2}},
+			},
+			Duration: 2 * time.Second,
+		},
+		{Name: "license", Status: Failed, Message: "no license", Duration: time.Second},
+		{Name: "broken", Status: Error, Message: "syntax error", Duration: 500 * time.Millisecond},
+	},
+}
+
+func TestRowFindings(t *testing.T) {
+	require := require.New(t)
+
+	findings := RowFindings(
+		[]string{"repository_id", "file_path", "LINE", "size"},
+		[][]string{{"engine", "main.go", "12", "3000"}, {"engine", "a.go", "x", "10"}},
+	)
+	require.Equal([]Finding{
+		{Message: "size=3000", Location: Location{Repository: "engine", Path: "main.go", Line: 12}},
+		{Message: "size=10", Location: Location{Repository: "engine", Path: "a.go"}},
+	}, findings)
+
+	findings = RowFindings([]string{"file_path", "message"}, [][]string{{"a.go", "bad"}})
+	require.Equal([]Finding{{Message: "bad", Location: Location{Path: "a.go"}}}, findings)
+}
+
+func TestFindingString(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("engine/a.go:1:2: bad", Finding{Message: "bad", Location: Location{
+		Repository: "engine", Path: "a.go", Line: 1, Column: 2,
+	}}.String())
+	require.Equal("a.go: bad", Finding{Message: "bad", Location: Location{Path: "a.go"}}.String())
+	require.Equal("bad", Finding{Message: "bad"}.String())
+}
+
+func TestWriteJUnit(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	require.NoError(Write(&buf, JUnit, testReport))
+	require.Equal(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="srcd ci" tests="4" failures="2" errors="1" time="4.500">
+    <testcase name="ok" classname="srcd ci" time="1.000"></testcase>
+    <testcase name="big files" classname="srcd ci" time="2.000">
+      <failure message="the query returned 2 rows">engine/a.bin: size=3&#xA;main.go:10:2: too long</failure>
+    </testcase>
+    <testcase name="license" classname="srcd ci" time="1.000">
+      <failure message="no license"></failure>
+    </testcase>
+    <testcase name="broken" classname="srcd ci" time="0.500">
+      <error message="syntax error"></error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
+
+func TestWriteSARIF(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	require.NoError(Write(&buf, SARIF, testReport))
+
+	var log sarifLog
+	require.NoError(json.Unmarshal(buf.Bytes(), &log))
+	require.Equal("2.1.0", log.Version)
+	require.Len(log.Runs, 1)
+
+	run := log.Runs[0]
+	require.Equal("v1.0.0", run.Tool.Driver.Version)
+	require.Len(run.Tool.Driver.Rules, 4)
+
+	require.Len(run.Results, 3)
+	require.Equal("big files", run.Results[0].RuleID)
+	require.Equal(1, run.Results[0].RuleIndex)
+	require.Equal("a.bin", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	require.Equal("engine", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.Properties["repository"])
+	require.Nil(run.Results[0].Locations[0].PhysicalLocation.Region)
+	require.Equal(&sarifRegion{StartLine: 10, StartColumn: 2}, run.Results[1].Locations[0].PhysicalLocation.Region)
+	require.Equal("license", run.Results[2].RuleID)
+	require.Equal("no license", run.Results[2].Message.Text)
+	require.Empty(run.Results[2].Locations)
+
+	require.Len(run.Invocations, 1)
+	require.False(run.Invocations[0].ExecutionSuccessful)
+	require.Equal("broken", run.Invocations[0].Notifications[0].Descriptor.ID)
+
+	buf.Reset()
+	require.NoError(Write(&buf, SARIF, &Report{Tool: "srcd sql"}))
+	require.Contains(buf.String(), `"results": []`)
+	require.Contains(buf.String(), `"executionSuccessful": true`)
+}
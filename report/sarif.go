@@ -0,0 +1,201 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// sarifInformationURI is the home of the engine, shown by the code
+	// scanning services for the tool
+	sarifInformationURI = "https://github.com/src-d/engine"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool                `json:"executionSuccessful"`
+	Notifications       []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level      string             `json:"level"`
+	Message    sarifMessage       `json:"message"`
+	Descriptor sarifRuleReference `json:"descriptor"`
+}
+
+type sarifRuleReference struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI        string            `json:"uri"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// WriteSARIF writes the report as a SARIF 2.1.0 log with a single run. Each
+// check is a rule, and each finding of a failed check a result, with a
+// location if its path is known; a failed check without findings is a result
+// with its message. The checks that could not be run are reported as errors of
+// the invocation
+func WriteSARIF(w io.Writer, r *Report) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           r.Tool,
+			Version:        r.Version,
+			InformationURI: sarifInformationURI,
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	invocation := sarifInvocation{ExecutionSuccessful: true}
+	for i, c := range r.Checks {
+		level := string(c.Severity)
+		if level == "" {
+			level = string(SeverityError)
+		}
+
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   c.Name,
+			ShortDescription:     sarifMessage{Text: sarifText(c.Description, c.Name)},
+			DefaultConfiguration: sarifConfiguration{Level: level},
+		})
+
+		switch c.Status {
+		case Error:
+			invocation.ExecutionSuccessful = false
+			invocation.Notifications = append(invocation.Notifications, sarifNotification{
+				Level:      "error",
+				Message:    sarifMessage{Text: c.Message},
+				Descriptor: sarifRuleReference{ID: c.Name},
+			})
+		case Failed:
+			if len(c.Findings) == 0 {
+				run.Results = append(run.Results, sarifResult{
+					RuleID:    c.Name,
+					RuleIndex: i,
+					Level:     level,
+					Message:   sarifMessage{Text: c.Message},
+				})
+			}
+
+			for _, f := range c.Findings {
+				run.Results = append(run.Results, sarifResult{
+					RuleID:    c.Name,
+					RuleIndex: i,
+					Level:     level,
+					Message:   sarifMessage{Text: sarifText(f.Message, c.Message)},
+					Locations: sarifLocations(f.Location),
+				})
+			}
+		}
+	}
+
+	run.Invocations = []sarifInvocation{invocation}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifText returns the given text, or the fallback if it is empty, as SARIF
+// requires a text for every message
+func sarifText(msg, fallback string) string {
+	if msg != "" {
+		return msg
+	}
+
+	return fallback
+}
+
+// sarifLocations returns the location of a finding, with the path relative to
+// the repository as the URI and the repository as a property, or none if the
+// path is not known
+func sarifLocations(loc Location) []sarifLocation {
+	if loc.Path == "" {
+		return nil
+	}
+
+	l := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: loc.Path},
+	}}
+
+	if loc.Repository != "" {
+		l.PhysicalLocation.ArtifactLocation.Properties = map[string]string{
+			"repository": loc.Repository,
+		}
+	}
+
+	if loc.Line > 0 {
+		l.PhysicalLocation.Region = &sarifRegion{
+			StartLine:   loc.Line,
+			StartColumn: loc.Column,
+		}
+	}
+
+	return []sarifLocation{l}
+}
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+)
+
+var (
+	rootMu sync.RWMutex
+	root   opentracing.Span
+)
+
+// SetRoot sets the parent of the spans of the gRPC calls and HTTP requests
+// whose context has no span, such as the ones of the srcd commands, which
+// don't pass the span of the command around. A nil span unsets it.
+func SetRoot(span opentracing.Span) {
+	rootMu.Lock()
+	defer rootMu.Unlock()
+	root = span
+}
+
+// withRoot returns the context with the span set with SetRoot, if it has no
+// span
+func withRoot(ctx context.Context) context.Context {
+	if opentracing.SpanFromContext(ctx) != nil {
+		return ctx
+	}
+
+	rootMu.RLock()
+	defer rootMu.RUnlock()
+	if root == nil {
+		return ctx
+	}
+
+	return opentracing.ContextWithSpan(ctx, root)
+}
+
+// StartSpanFromContext starts a span with the global tracer, as a child of
+// the span of the context or of the one set with SetRoot, and returns it
+// with a context that has it.
+func StartSpanFromContext(ctx context.Context, name string, opts ...opentracing.StartSpanOption) (opentracing.Span, context.Context) {
+	return opentracing.StartSpanFromContext(withRoot(ctx), name, opts...)
+}
+
+// globalTracer calls the global opentracing tracer, so the interceptors
+// created before Start use the tracer it sets.
+type globalTracer struct{}
+
+func (globalTracer) StartSpan(name string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	return opentracing.GlobalTracer().StartSpan(name, opts...)
+}
+
+func (globalTracer) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	return opentracing.GlobalTracer().Inject(sc, format, carrier)
+}
+
+func (globalTracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	return opentracing.GlobalTracer().Extract(format, carrier)
+}
+
+// withParent only traces the calls with a parent span, so the ones of the
+// processes that are not tracing a command don't start a trace
+var withParent = otgrpc.IncludingSpans(func(parent opentracing.SpanContext, method string, req, resp interface{}) bool {
+	return parent != nil
+})
+
+// DialOptions returns the options of the gRPC clients to trace their calls,
+// as children of the span of their context or of the one set with SetRoot.
+func DialOptions() []grpc.DialOption {
+	unary := otgrpc.OpenTracingClientInterceptor(globalTracer{}, withParent)
+	stream := otgrpc.OpenTracingStreamClientInterceptor(globalTracer{}, withParent)
+
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(func(
+			ctx context.Context,
+			method string,
+			req, reply interface{},
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			return unary(withRoot(ctx), method, req, reply, cc, invoker, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(
+			ctx context.Context,
+			desc *grpc.StreamDesc,
+			cc *grpc.ClientConn,
+			method string,
+			streamer grpc.Streamer,
+			opts ...grpc.CallOption,
+		) (grpc.ClientStream, error) {
+			return stream(withRoot(ctx), desc, cc, method, streamer, opts...)
+		}),
+	}
+}
+
+// ServerOptions returns the options of the gRPC servers to trace the calls
+// they receive, continuing the trace of the client.
+func ServerOptions() []grpc.ServerOption {
+	unary, stream := ServerInterceptors()
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(unary),
+		grpc.StreamInterceptor(stream),
+	}
+}
+
+// ServerInterceptors returns the interceptors of ServerOptions, to chain them
+// with others, as a server only accepts one of each kind.
+func ServerInterceptors() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	return otgrpc.OpenTracingServerInterceptor(globalTracer{}),
+		otgrpc.OpenTracingStreamServerInterceptor(globalTracer{})
+}
+
+// Transport returns an http.RoundTripper that traces the requests sent with
+// rt whose context has a span, or when one is set with SetRoot.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	return &transport{rt}
+}
+
+type transport struct {
+	rt http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := withRoot(req.Context())
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
+		return t.rt.RoundTrip(req)
+	}
+
+	span := opentracing.GlobalTracer().StartSpan(
+		req.Method+" "+spanPath(req.URL.Path),
+		opentracing.ChildOf(parent.Context()),
+		ext.SpanKindRPCClient,
+	)
+	defer span.Finish()
+
+	ext.HTTPMethod.Set(span, req.Method)
+	ext.HTTPUrl.Set(span, req.URL.String())
+
+	res, err := t.rt.RoundTrip(req)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("event", "error", "message", err.Error())
+		return nil, err
+	}
+
+	ext.HTTPStatusCode.Set(span, uint16(res.StatusCode))
+	if res.StatusCode >= 500 {
+		ext.Error.Set(span, true)
+	}
+
+	return res, nil
+}
+
+// spanPath returns the path of a request without the API version prefix of
+// the docker API, like /v1.38
+func spanPath(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) == 2 && strings.HasPrefix(parts[0], "v1.") {
+		return "/" + parts[1]
+	}
+
+	return path
+}
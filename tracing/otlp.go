@@ -0,0 +1,311 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+	golog "gopkg.in/src-d/go-log.v1"
+)
+
+const (
+	// flushInterval is how often the finished spans are sent
+	flushInterval = 5 * time.Second
+	// maxPendingSpans is the number of finished spans kept until they are
+	// sent. The next ones are dropped
+	maxPendingSpans = 10000
+	// exportTimeout is the time given to the endpoint to receive the spans
+	exportTimeout = 10 * time.Second
+	// tracesPath is the path of the OTLP/HTTP endpoint for the spans
+	tracesPath = "/v1/traces"
+)
+
+// OTLP span kinds and status codes
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+	otlpKindClient   = 3
+	otlpStatusError  = 2
+)
+
+// exporter sends the finished spans to an OTLP/HTTP endpoint, in the JSON
+// encoding.
+type exporter struct {
+	service  string
+	endpoint string
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending []*span
+	dropped int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newExporter(service, endpoint string, client *http.Client) *exporter {
+	return &exporter{
+		service:  service,
+		endpoint: strings.TrimRight(endpoint, "/") + tracesPath,
+		client:   client,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// add queues the finished span to be sent
+func (e *exporter) add(s *span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.pending) >= maxPendingSpans {
+		e.dropped++
+		return
+	}
+
+	e.pending = append(e.pending, s)
+}
+
+// run sends the spans every interval until close is called
+func (e *exporter) run(interval time.Duration) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.flush(context.Background()); err != nil {
+				golog.Debugf("could not send the trace spans: %s", err)
+			}
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// close stops run and sends the pending spans
+func (e *exporter) close() error {
+	close(e.stop)
+	<-e.done
+	return e.flush(context.Background())
+}
+
+// flush sends the pending spans
+func (e *exporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	spans, dropped := e.pending, e.dropped
+	e.pending, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		golog.Debugf("%d trace spans were dropped", dropped)
+	}
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "invalid endpoint %s", e.endpoint)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	res, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "could not send %d spans to %s", len(spans), e.endpoint)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("could not send %d spans to %s: %s", len(spans), e.endpoint, res.Status)
+	}
+
+	return nil
+}
+
+// The OTLP/HTTP JSON request, see
+// https://github.com/open-telemetry/opentelemetry-proto
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue has one of its fields set. The integers are encoded as strings
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// request returns the OTLP request with the spans
+func (e *exporter) request(spans []*span) otlpRequest {
+	converted := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		converted[i] = convertSpan(s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			attribute("service.name", e.service),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/src-d/engine/tracing"},
+			Spans: converted,
+		}},
+	}}}
+}
+
+func convertSpan(s *span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := otlpSpan{
+		TraceID:           hex.EncodeToString(s.context.traceID[:]),
+		SpanID:            hex.EncodeToString(s.context.spanID[:]),
+		Name:              s.name,
+		Kind:              otlpKindInternal,
+		StartTimeUnixNano: unixNano(s.start),
+		EndTimeUnixNano:   unixNano(s.end),
+	}
+
+	if s.hasParent {
+		o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+
+	for k, v := range s.tags {
+		switch k {
+		case string(ext.SpanKind):
+			switch fmt.Sprint(v) {
+			case string(ext.SpanKindRPCServerEnum):
+				o.Kind = otlpKindServer
+			case string(ext.SpanKindRPCClientEnum):
+				o.Kind = otlpKindClient
+			}
+		case string(ext.Error):
+			if b, ok := v.(bool); ok && b {
+				o.Status.Code = otlpStatusError
+			}
+		}
+
+		o.Attributes = append(o.Attributes, attribute(k, v))
+	}
+
+	for _, record := range s.logs {
+		event := otlpEvent{TimeUnixNano: unixNano(record.Timestamp), Name: "log"}
+		for _, f := range record.Fields {
+			if f.Key() == "event" {
+				event.Name = fmt.Sprint(f.Value())
+				continue
+			}
+
+			event.Attributes = append(event.Attributes, fieldAttribute(f))
+		}
+
+		o.Events = append(o.Events, event)
+	}
+
+	return o
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func fieldAttribute(f log.Field) otlpAttribute {
+	v := f.Value()
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+
+	return attribute(f.Key(), v)
+}
+
+// attribute returns the OTLP attribute with the given value, converted to a
+// string if it is not a number or a boolean
+func attribute(key string, value interface{}) otlpAttribute {
+	a := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case bool:
+		a.Value.BoolValue = &v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(v)
+		a.Value.IntValue = &s
+	case float32:
+		f := float64(v)
+		a.Value.DoubleValue = &f
+	case float64:
+		a.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		a.Value.StringValue = &s
+	}
+
+	return a
+}
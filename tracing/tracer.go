@@ -0,0 +1,344 @@
+// Package tracing records the spans of the srcd commands, the engine API
+// calls and the docker operations, and sends them to an OpenTelemetry
+// collector, or any other OTLP/HTTP endpoint such as the one of Jaeger.
+//
+// It implements the opentracing API, used by the gRPC interceptors and the
+// bblfsh client, and propagates the traces with the W3C traceparent header.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// traceparentHeader is the W3C header the span context is propagated with
+const traceparentHeader = "traceparent"
+
+// Tracer is an opentracing.Tracer that sends the finished spans to an
+// exporter.
+type Tracer struct {
+	exporter *exporter
+	// root starts a new trace for the spans without a parent. Otherwise only
+	// the spans of the traces started by another process, and propagated to
+	// this one with Inject and Extract, are recorded
+	root bool
+}
+
+var _ opentracing.Tracer = (*Tracer)(nil)
+
+// spanContext identifies a span and its trace. The spans of the traces that
+// are not sampled are not recorded.
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+	baggage map[string]string
+}
+
+func (c spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	for k, v := range c.baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
+
+// TraceID returns the hexadecimal ID of the trace of the span, or an empty
+// string if it is not recorded
+func TraceID(span opentracing.Span) string {
+	c, ok := span.Context().(spanContext)
+	if !ok || !c.sampled {
+		return ""
+	}
+
+	return hex.EncodeToString(c.traceID[:])
+}
+
+// StartSpan implements the opentracing.Tracer interface.
+func (t *Tracer) StartSpan(name string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	var o opentracing.StartSpanOptions
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+
+	s := &span{
+		tracer: t,
+		name:   name,
+		start:  o.StartTime,
+		tags:   make(map[string]interface{}, len(o.Tags)),
+	}
+
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+
+	for k, v := range o.Tags {
+		s.tags[k] = v
+	}
+
+	var parent *spanContext
+	for _, ref := range o.References {
+		if c, ok := ref.ReferencedContext.(spanContext); ok {
+			parent = &c
+			break
+		}
+	}
+
+	if parent != nil {
+		s.context.traceID = parent.traceID
+		s.context.sampled = parent.sampled
+		s.parentID = parent.spanID
+		s.hasParent = true
+		if len(parent.baggage) > 0 {
+			s.context.baggage = make(map[string]string, len(parent.baggage))
+			for k, v := range parent.baggage {
+				s.context.baggage[k] = v
+			}
+		}
+	} else {
+		rand.Read(s.context.traceID[:])
+		s.context.sampled = t.root
+	}
+
+	rand.Read(s.context.spanID[:])
+	return s
+}
+
+// Inject implements the opentracing.Tracer interface. Only the TextMap and
+// HTTPHeaders formats are supported, and the baggage is not propagated.
+func (t *Tracer) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	c, ok := sc.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return opentracing.ErrUnsupportedFormat
+	}
+
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	w.Set(traceparentHeader, formatTraceparent(c))
+	return nil
+}
+
+// Extract implements the opentracing.Tracer interface.
+func (t *Tracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+
+	r, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+
+	var value string
+	err := r.ForeachKey(func(k, v string) error {
+		if strings.ToLower(k) == traceparentHeader {
+			value = v
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if value == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+
+	return parseTraceparent(value)
+}
+
+// formatTraceparent returns the traceparent header of the span context
+func formatTraceparent(c spanContext) string {
+	flags := "00"
+	if c.sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s",
+		hex.EncodeToString(c.traceID[:]), hex.EncodeToString(c.spanID[:]), flags)
+}
+
+// parseTraceparent returns the span context of a traceparent header
+func parseTraceparent(value string) (spanContext, error) {
+	var c spanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[3]) != 2 {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != len(c.traceID) {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != len(c.spanID) {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+
+	copy(c.traceID[:], traceID)
+	copy(c.spanID[:], spanID)
+	c.sampled = flags[0]&1 == 1
+	return c, nil
+}
+
+// span is an opentracing.Span recorded by Tracer.
+type span struct {
+	tracer    *Tracer
+	context   spanContext
+	parentID  [8]byte
+	hasParent bool
+
+	mu    sync.Mutex
+	name  string
+	start time.Time
+	end   time.Time
+	tags  map[string]interface{}
+	logs  []opentracing.LogRecord
+}
+
+var _ opentracing.Span = (*span)(nil)
+
+func (s *span) Finish() {
+	s.FinishWithOptions(opentracing.FinishOptions{})
+}
+
+func (s *span) FinishWithOptions(opts opentracing.FinishOptions) {
+	s.mu.Lock()
+	s.end = opts.FinishTime
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+
+	s.logs = append(s.logs, opts.LogRecords...)
+	for _, ld := range opts.BulkLogData {
+		s.logs = append(s.logs, ld.ToLogRecord())
+	}
+	s.mu.Unlock()
+
+	if s.context.sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.add(s)
+	}
+}
+
+func (s *span) Context() opentracing.SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.context
+}
+
+func (s *span) SetOperationName(name string) opentracing.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+	return s
+}
+
+func (s *span) SetTag(key string, value interface{}) opentracing.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags[key] = value
+	return s
+}
+
+func (s *span) LogFields(fields ...log.Field) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, opentracing.LogRecord{Timestamp: time.Now(), Fields: fields})
+}
+
+func (s *span) LogKV(keyValues ...interface{}) {
+	fields, err := log.InterleavedKVToFields(keyValues...)
+	if err != nil {
+		fields = []log.Field{log.Error(err)}
+	}
+
+	s.LogFields(fields...)
+}
+
+// SetBaggageItem implements the opentracing.Span interface. The baggage is
+// only passed to the children of the span in the same process.
+func (s *span) SetBaggageItem(key, value string) opentracing.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	baggage := make(map[string]string, len(s.context.baggage)+1)
+	for k, v := range s.context.baggage {
+		baggage[k] = v
+	}
+
+	baggage[key] = value
+	s.context.baggage = baggage
+	return s
+}
+
+func (s *span) BaggageItem(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.context.baggage[key]
+}
+
+func (s *span) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *span) LogEvent(event string) {
+	s.Log(opentracing.LogData{Event: event})
+}
+
+func (s *span) LogEventWithPayload(event string, payload interface{}) {
+	s.Log(opentracing.LogData{Event: event, Payload: payload})
+}
+
+func (s *span) Log(data opentracing.LogData) {
+	record := data.ToLogRecord()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, record)
+}
+
+// Start sets a Tracer for the given service as the global opentracing one,
+// sending the spans to the OTLP/HTTP endpoint, like http://localhost:4318,
+// every few seconds. With root, the spans without a parent start a new
+// trace, otherwise only the traces started by another process are recorded.
+// The returned Tracer must be closed to send the last spans.
+func Start(service, endpoint string, root bool) *Tracer {
+	t := &Tracer{
+		exporter: newExporter(service, endpoint, http.DefaultClient),
+		root:     root,
+	}
+
+	go t.exporter.run(flushInterval)
+	opentracing.SetGlobalTracer(t)
+	return t
+}
+
+// Close sends the spans that were not sent yet, and stops sending them. The
+// global tracer is reset to a no-op one.
+func (t *Tracer) Close() error {
+	if opentracing.GlobalTracer() == opentracing.Tracer(t) {
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	}
+
+	return t.exporter.close()
+}
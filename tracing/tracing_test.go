@@ -0,0 +1,211 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/stretchr/testify/require"
+)
+
+// collector is an OTLP/HTTP endpoint that keeps the received requests
+type collector struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []otlpRequest
+}
+
+func newCollector(t *testing.T) *collector {
+	c := &collector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, tracesPath, r.URL.Path)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var req otlpRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+
+		c.mu.Lock()
+		c.requests = append(c.requests, req)
+		c.mu.Unlock()
+	}))
+
+	return c
+}
+
+func (c *collector) spans() []otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var spans []otlpSpan
+	for _, r := range c.requests {
+		for _, rs := range r.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+
+	return spans
+}
+
+func TestTracer(t *testing.T) {
+	require := require.New(t)
+
+	c := newCollector(t)
+	defer c.Close()
+
+	tracer := Start("srcd", c.URL, true)
+	require.Equal(opentracing.Tracer(tracer), opentracing.GlobalTracer())
+
+	root := opentracing.StartSpan("srcd sql")
+	child := opentracing.StartSpan("gitbase query", opentracing.ChildOf(root.Context()), ext.SpanKindRPCClient)
+	ext.DBStatement.Set(child, "SELECT 1")
+	ext.Error.Set(child, true)
+	child.LogKV("event", "error", "message", "timeout")
+	child.Finish()
+	root.Finish()
+
+	require.NoError(tracer.Close())
+	require.Equal(opentracing.Tracer(opentracing.NoopTracer{}), opentracing.GlobalTracer())
+
+	require.Len(c.requests, 1)
+	require.Equal("service.name", c.requests[0].ResourceSpans[0].Resource.Attributes[0].Key)
+	require.Equal("srcd", *c.requests[0].ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := c.spans()
+	require.Len(spans, 2)
+	require.Equal("gitbase query", spans[0].Name)
+	require.Equal("srcd sql", spans[1].Name)
+	require.Equal(TraceID(root), spans[0].TraceID)
+	require.Equal(spans[1].TraceID, spans[0].TraceID)
+	require.Equal(spans[1].SpanID, spans[0].ParentSpanID)
+	require.Empty(spans[1].ParentSpanID)
+	require.Equal(otlpKindClient, spans[0].Kind)
+	require.Equal(otlpKindInternal, spans[1].Kind)
+	require.Equal(otlpStatusError, spans[0].Status.Code)
+	require.Len(spans[0].Events, 1)
+	require.Equal("error", spans[0].Events[0].Name)
+	require.Equal("timeout", *spans[0].Events[0].Attributes[0].Value.StringValue)
+}
+
+func TestTracerNotRoot(t *testing.T) {
+	require := require.New(t)
+
+	c := newCollector(t)
+	defer c.Close()
+
+	tracer := Start("srcd-server", c.URL, false)
+
+	// a span without a parent is not recorded, nor its children
+	span := opentracing.StartSpan("Version")
+	opentracing.StartSpan("start", opentracing.ChildOf(span.Context())).Finish()
+	span.Finish()
+	require.Empty(TraceID(span))
+
+	// the traces started by another process are
+	remote := &Tracer{root: true}
+	carrier := opentracing.TextMapCarrier{}
+	parent := remote.StartSpan("srcd sql")
+	require.NoError(remote.Inject(parent.Context(), opentracing.TextMap, carrier))
+
+	sc, err := tracer.Extract(opentracing.TextMap, carrier)
+	require.NoError(err)
+	opentracing.StartSpan("SQL", ext.RPCServerOption(sc)).Finish()
+
+	require.NoError(tracer.Close())
+
+	spans := c.spans()
+	require.Len(spans, 1)
+	require.Equal("SQL", spans[0].Name)
+	require.Equal(otlpKindServer, spans[0].Kind)
+	require.Equal(TraceID(parent), spans[0].TraceID)
+}
+
+func TestTraceparent(t *testing.T) {
+	require := require.New(t)
+
+	tracer := &Tracer{root: true}
+	span := tracer.StartSpan("srcd init")
+	carrier := opentracing.HTTPHeadersCarrier(http.Header{})
+	require.NoError(tracer.Inject(span.Context(), opentracing.HTTPHeaders, carrier))
+	require.Regexp(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, http.Header(carrier).Get(traceparentHeader))
+
+	sc, err := tracer.Extract(opentracing.HTTPHeaders, carrier)
+	require.NoError(err)
+	require.Equal(span.Context().(spanContext).traceID, sc.(spanContext).traceID)
+	require.Equal(span.Context().(spanContext).spanID, sc.(spanContext).spanID)
+	require.True(sc.(spanContext).sampled)
+
+	_, err = tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{})
+	require.Equal(opentracing.ErrSpanContextNotFound, err)
+
+	_, err = tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{traceparentHeader: "00-abc-def-01"})
+	require.Equal(opentracing.ErrSpanContextCorrupted, err)
+
+	_, err = tracer.Extract(opentracing.Binary, opentracing.TextMapCarrier{})
+	require.Equal(opentracing.ErrUnsupportedFormat, err)
+
+	parsed, err := parseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	require.NoError(err)
+	require.False(parsed.sampled)
+}
+
+func TestTransport(t *testing.T) {
+	require := require.New(t)
+
+	c := newCollector(t)
+	defer c.Close()
+
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer docker.Close()
+
+	tracer := Start("srcd", c.URL, true)
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+	// without a parent, the requests are not traced
+	res, err := client.Get(docker.URL + "/v1.38/info")
+	require.NoError(err)
+	res.Body.Close()
+
+	root := opentracing.StartSpan("srcd init")
+	SetRoot(root)
+	defer SetRoot(nil)
+
+	res, err = client.Get(docker.URL + "/v1.38/containers/json")
+	require.NoError(err)
+	res.Body.Close()
+
+	span, ctx := StartSpanFromContext(context.Background(), "start gitbase")
+	req, err := http.NewRequest(http.MethodPost, docker.URL+"/v1.38/containers/create", nil)
+	require.NoError(err)
+	res, err = client.Do(req.WithContext(ctx))
+	require.NoError(err)
+	res.Body.Close()
+	span.Finish()
+
+	require.NoError(tracer.Close())
+
+	spans := c.spans()
+	require.Len(spans, 3)
+	require.Equal("GET /containers/json", spans[0].Name)
+	require.Equal(hexSpanID(root), spans[0].ParentSpanID)
+	require.Equal("POST /containers/create", spans[1].Name)
+	require.Equal(spans[2].SpanID, spans[1].ParentSpanID)
+	require.Equal("start gitbase", spans[2].Name)
+	require.Equal(hexSpanID(root), spans[2].ParentSpanID)
+}
+
+func hexSpanID(span opentracing.Span) string {
+	c := span.Context().(spanContext)
+	return hex.EncodeToString(c.spanID[:])
+}